@@ -0,0 +1,35 @@
+package admin
+
+import (
+	"errors"
+	"mamabloemetjes_server/lib"
+	"net/http"
+
+	"github.com/MonkyMars/gecho"
+)
+
+// WarmCache fills the product caches on demand, e.g. after a bulk import or a Redis flush
+// Only one warmup runs at a time, a concurrent request is answered with a 409
+func (ar *AdminRoutesManager) WarmCache(w http.ResponseWriter, r *http.Request) {
+	result, err := ar.productService.WarmCache(r.Context())
+	if errors.Is(err, lib.ErrCacheWarmInProgress) {
+		gecho.Conflict(w, gecho.WithMessage("error.cache.warmInProgress"), gecho.Send())
+		return
+	}
+	if err != nil {
+		ar.logger.Error("Failed to warm product cache", gecho.Field("error", lib.GetDetailForLogging(err)))
+		// Pages cached before the failure stay cached, so report the progress alongside the error
+		gecho.InternalServerError(w,
+			gecho.WithMessage("error.cache.warmFailed"),
+			gecho.WithData(result),
+			gecho.Send(),
+		)
+		return
+	}
+
+	gecho.Success(w,
+		gecho.WithMessage("success.cache.warmed"),
+		gecho.WithData(result),
+		gecho.Send(),
+	)
+}
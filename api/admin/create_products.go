@@ -1,6 +1,7 @@
 package admin
 
 import (
+	"errors"
 	"mamabloemetjes_server/lib"
 	"mamabloemetjes_server/structs/tables"
 	"net/http"
@@ -19,7 +20,7 @@ func (ar *AdminRoutesManager) CreateProduct(w http.ResponseWriter, r *http.Reque
 	body.SKU, err = lib.GenerateSKU(body.Name, 5)
 	ar.logger.Debug("Generated SKU", gecho.Field("sku", body.SKU))
 	if err != nil {
-		ar.logger.Error("Failed to generate SKU", gecho.Field("error", err))
+		ar.logger.Error("Failed to generate SKU", gecho.Field("error", lib.GetDetailForLogging(err)))
 		gecho.InternalServerError(w, gecho.WithMessage("error.products.unableToCreate"), gecho.Send())
 		return
 	}
@@ -42,8 +43,26 @@ func (ar *AdminRoutesManager) CreateProduct(w http.ResponseWriter, r *http.Reque
 
 	newProduct, err := ar.productService.CreateProduct(r.Context(), body)
 	if err != nil {
-		ar.logger.Error("Failed to create product", gecho.Field("error", err))
-		gecho.InternalServerError(w, gecho.WithMessage("error.products.unableToCreate"), gecho.Send())
+		var validationErr *lib.ValidationError
+		if errors.As(err, &validationErr) {
+			gecho.BadRequest(w,
+				gecho.WithMessage("error.products.checkProductInformation"),
+				gecho.WithData(validationErr),
+				gecho.Send(),
+			)
+			return
+		}
+
+		if errors.Is(err, lib.ErrDuplicateSKU) {
+			gecho.Conflict(w,
+				gecho.WithMessage("error.products.duplicateSku"),
+				gecho.Send(),
+			)
+			return
+		}
+
+		ar.logger.Error("Failed to create product", gecho.Field("error", lib.GetDetailForLogging(err)))
+		lib.RespondServerError(w, err, "error.products.unableToCreate")
 		return
 	}
 
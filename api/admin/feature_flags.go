@@ -0,0 +1,72 @@
+package admin
+
+import (
+	"errors"
+	"mamabloemetjes_server/lib"
+	"net/http"
+
+	"github.com/MonkyMars/gecho"
+	"github.com/go-chi/chi/v5"
+)
+
+type SetFeatureFlagRequest struct {
+	Enabled *bool `json:"enabled" validate:"required"`
+}
+
+// ListFeatureFlags returns all known feature flags with their current value
+func (ar *AdminRoutesManager) ListFeatureFlags(w http.ResponseWriter, r *http.Request) {
+	flags, err := ar.featureFlags.GetFlags(r.Context())
+	if err != nil {
+		ar.logger.Error("Failed to get feature flags", gecho.Field("error", lib.GetDetailForLogging(err)))
+		lib.RespondServerError(w, err, "error.featureFlags.fetchFailed")
+		return
+	}
+
+	gecho.Success(w,
+		gecho.WithMessage("success.featureFlags.fetched"),
+		gecho.WithData(flags),
+		gecho.Send(),
+	)
+}
+
+// SetFeatureFlag turns a feature flag on or off for all instances
+func (ar *AdminRoutesManager) SetFeatureFlag(w http.ResponseWriter, r *http.Request) {
+	name := chi.URLParam(r, "name")
+
+	body, err := lib.ExtractAndValidateBody[SetFeatureFlagRequest](r)
+	if err != nil {
+		gecho.BadRequest(w,
+			gecho.WithMessage("error.featureFlags.invalidRequestBody"),
+			gecho.WithData(lib.ClientErrorData(err)),
+			gecho.Send(),
+		)
+		return
+	}
+
+	err = ar.featureFlags.SetFlag(r.Context(), name, *body.Enabled)
+	if err != nil {
+		if errors.Is(err, lib.ErrInvalidFeatureFlag) {
+			gecho.BadRequest(w, gecho.WithMessage("error.featureFlags.invalidName"), gecho.Send())
+			return
+		}
+		ar.logger.Error("Failed to set feature flag",
+			gecho.Field("error", lib.GetDetailForLogging(err)),
+			gecho.Field("flag", name))
+		lib.RespondServerError(w, err, "error.featureFlags.updateFailed")
+		return
+	}
+
+	ar.logger.Info("Feature flag changed by admin",
+		gecho.Field("flag", name),
+		gecho.Field("enabled", *body.Enabled),
+		gecho.Field("admin_id", adminIdFromContext(r)))
+
+	gecho.Success(w,
+		gecho.WithMessage("success.featureFlags.updated"),
+		gecho.WithData(map[string]interface{}{
+			"name":    name,
+			"enabled": *body.Enabled,
+		}),
+		gecho.Send(),
+	)
+}
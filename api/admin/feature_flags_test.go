@@ -0,0 +1,54 @@
+package admin
+
+import (
+	"context"
+	"mamabloemetjes_server/services"
+	"mamabloemetjes_server/testutil"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+
+	"github.com/go-chi/chi/v5"
+)
+
+// setFlagRequest sends body to SetFeatureFlag for the named flag and returns the status
+func setFlagRequest(ar *AdminRoutesManager, name, body string) int {
+	r := httptest.NewRequest(http.MethodPut, "/admin/feature-flags/"+name, strings.NewReader(body))
+	routeCtx := chi.NewRouteContext()
+	routeCtx.URLParams.Add("name", name)
+	r = r.WithContext(context.WithValue(r.Context(), chi.RouteCtxKey, routeCtx))
+	w := httptest.NewRecorder()
+	ar.SetFeatureFlag(w, r)
+	return w.Code
+}
+
+func TestSetFeatureFlagToggles(t *testing.T) {
+	testutil.Redis(t)
+	cfg := testutil.Config()
+	logger := testutil.Logger()
+	flags := services.NewFeatureFlagService(logger, cfg, services.NewCacheService(logger, cfg))
+	ar := &AdminRoutesManager{logger: logger, featureFlags: flags}
+	ctx := context.Background()
+
+	if status := setFlagRequest(ar, "trending_section", `{"enabled": false}`); status != http.StatusOK {
+		t.Fatalf("expected status 200, got %d", status)
+	}
+	if flags.IsEnabled(ctx, "trending_section") {
+		t.Fatal("expected trending_section to be off after the admin turned it off")
+	}
+
+	if status := setFlagRequest(ar, "trending_section", `{"enabled": true}`); status != http.StatusOK {
+		t.Fatalf("expected status 200, got %d", status)
+	}
+	if !flags.IsEnabled(ctx, "trending_section") {
+		t.Fatal("expected trending_section to be on after the admin turned it on")
+	}
+
+	if status := setFlagRequest(ar, "trending_section", `{}`); status != http.StatusBadRequest {
+		t.Fatalf("expected a missing value to be rejected, got %d", status)
+	}
+	if status := setFlagRequest(ar, "Trending!", `{"enabled": true}`); status != http.StatusBadRequest {
+		t.Fatalf("expected an invalid name to be rejected, got %d", status)
+	}
+}
@@ -1,7 +1,9 @@
 package admin
 
 import (
+	"errors"
 	"mamabloemetjes_server/handling"
+	"mamabloemetjes_server/lib"
 	"net/http"
 
 	"github.com/MonkyMars/gecho"
@@ -14,13 +16,25 @@ func (ar *AdminRoutesManager) ListAllProducts(w http.ResponseWriter, r *http.Req
 		gecho.BadRequest(w, gecho.WithMessage("error.invalidQueryParameters"), gecho.Send())
 		return
 	}
+	// Admins manage seasonal products outside their window too
+	opts.IncludeUnavailable = true
+
 	products, err := ar.productService.GetAllProducts(r.Context(), opts)
 	if err != nil {
-		ar.logger.Error("Failed to list products", gecho.Field("error", err))
-		gecho.InternalServerError(w, gecho.WithMessage("error.products.failedToList"), gecho.Send())
+		var validationErr *lib.ValidationError
+		if errors.As(err, &validationErr) {
+			gecho.BadRequest(w, gecho.WithMessage("error.invalidQueryParameters"), gecho.WithData(validationErr), gecho.Send())
+			return
+		}
+		ar.logger.Error("Failed to list products", gecho.Field("error", lib.GetDetailForLogging(err)))
+		lib.RespondServerError(w, err, "error.products.failedToList")
 		return
 	}
 
+	if lib.WantsPaginationLinks(r) {
+		products.Links = lib.NewPaginationLinks(r, products.Pagination.Page, products.Pagination.TotalPages)
+	}
+
 	gecho.Success(w,
 		gecho.WithData(products),
 		gecho.WithMessage("success.products.retrieved"),
@@ -10,21 +10,27 @@ import (
 
 type AdminRoutesManager struct {
 	logger         *gecho.Logger
+	authService    *services.AuthService
 	productService *services.ProductService
 	orderService   *services.OrderService
+	featureFlags   *services.FeatureFlagService
 	mw             *middleware.Middleware
 }
 
 func NewAdminRoutesManager(
 	logger *gecho.Logger,
+	authService *services.AuthService,
 	productService *services.ProductService,
 	orderService *services.OrderService,
+	featureFlags *services.FeatureFlagService,
 	mw *middleware.Middleware,
 ) *AdminRoutesManager {
 	return &AdminRoutesManager{
 		logger:         logger,
+		authService:    authService,
 		productService: productService,
 		orderService:   orderService,
+		featureFlags:   featureFlags,
 		mw:             mw,
 	}
 }
@@ -39,17 +45,38 @@ func (ar *AdminRoutesManager) RegisterRoutes(r chi.Router) {
 		r.Get("/orders", ar.ListOrders)
 		r.Get("/orders/{id}", ar.GetOrderDetails)
 
+		// Customer lookup for support, rate limited on top of the admin limit
+		r.Group(func(r chi.Router) {
+			r.Use(ar.mw.UserLookupRateLimit())
+			r.Get("/users", ar.LookupUser)
+			r.Get("/users/{id}", ar.GetUser)
+		})
+
+		// Feature flags
+		r.Get("/feature-flags", ar.ListFeatureFlags)
+
 		// Protected routes behind CSRF
 		r.Group(func(r chi.Router) {
 			r.Use(ar.mw.CSRFMiddleware())
 			r.Post("/products", ar.CreateProduct)
 			r.Put("/products", ar.UpdateProducts)
+			r.Post("/products/images/migrate-host", ar.MigrateImageHost)
 
 			// Order update routes
 			r.Post("/orders/{id}/payment-link", ar.AttachPaymentLink)
 			r.Post("/orders/{id}/mark-paid", ar.MarkOrderAsPaid)
 			r.Put("/orders/{id}/status", ar.UpdateOrderStatus)
+			r.Patch("/orders/{id}/email", ar.UpdateOrderEmail)
+			r.Post("/orders/{id}/adjust", ar.AdjustOrder)
+			r.Post("/orders/status", ar.BulkUpdateOrderStatus)
 			r.Delete("/orders/{id}", ar.DeleteOrder)
+			r.Patch("/orders/{id}/lines/{lineId}", ar.UpdateOrderLine)
+			r.Delete("/orders/{id}/lines/{lineId}", ar.DeleteOrderLine)
+
+			r.Put("/feature-flags/{name}", ar.SetFeatureFlag)
+
+			// Cache warmup, rate limited on top of the admin limit
+			r.With(ar.mw.CacheWarmRateLimit()).Post("/cache/warm", ar.WarmCache)
 		})
 	})
 }
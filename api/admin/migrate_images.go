@@ -0,0 +1,56 @@
+package admin
+
+import (
+	"mamabloemetjes_server/lib"
+	"net/http"
+
+	"github.com/MonkyMars/gecho"
+)
+
+type MigrateImageHostRequest struct {
+	OldPrefix string `json:"old_prefix" validate:"required,url"`
+	NewPrefix string `json:"new_prefix" validate:"required,url,nefield=OldPrefix"`
+	DryRun    bool   `json:"dry_run"`
+	BatchSize int    `json:"batch_size" validate:"omitempty,min=1,max=1000"`
+}
+
+// MigrateImageHost rewrites product image URLs from an old CDN prefix to a new one
+// With dry_run set it only reports how many images and products would change
+func (ar *AdminRoutesManager) MigrateImageHost(w http.ResponseWriter, r *http.Request) {
+	body, err := lib.ExtractAndValidateBody[MigrateImageHostRequest](r)
+	if err != nil {
+		gecho.BadRequest(w,
+			gecho.WithMessage("error.products.invalidImageMigration"),
+			gecho.WithData(lib.ClientErrorData(err)),
+			gecho.Send(),
+		)
+		return
+	}
+
+	batchSize := body.BatchSize
+	if batchSize == 0 {
+		batchSize = 100
+	}
+
+	result, err := ar.productService.MigrateImageHost(r.Context(), body.OldPrefix, body.NewPrefix, body.DryRun, batchSize)
+	if err != nil {
+		ar.logger.Error("Failed to migrate product image host",
+			gecho.Field("error", lib.GetDetailForLogging(err)),
+			gecho.Field("old_prefix", body.OldPrefix),
+			gecho.Field("new_prefix", body.NewPrefix),
+		)
+		// Batches already committed stay migrated, so report the progress alongside the error
+		gecho.InternalServerError(w,
+			gecho.WithMessage("error.products.imageMigrationFailed"),
+			gecho.WithData(result),
+			gecho.Send(),
+		)
+		return
+	}
+
+	gecho.Success(w,
+		gecho.WithMessage("success.products.imageHostMigrated"),
+		gecho.WithData(result),
+		gecho.Send(),
+	)
+}
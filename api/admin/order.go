@@ -1,12 +1,14 @@
 package admin
 
 import (
+	"errors"
+	"mamabloemetjes_server/api/middleware"
 	"mamabloemetjes_server/lib"
 	"mamabloemetjes_server/structs/tables"
 	"net/http"
+	"strings"
 
 	"github.com/MonkyMars/gecho"
-	"github.com/go-chi/chi/v5"
 	"github.com/google/uuid"
 )
 
@@ -18,17 +20,32 @@ type UpdateOrderStatusRequest struct {
 	Status tables.OrderStatus `json:"status" validate:"required,oneof=pending paid processing shipped delivered cancelled refunded"`
 }
 
+type UpdateOrderLineRequest struct {
+	Quantity int `json:"quantity" validate:"required,min=1"`
+}
+
+type UpdateOrderEmailRequest struct {
+	Email              string `json:"email" validate:"required,email,max=254"`
+	ResendConfirmation bool   `json:"resend_confirmation"`
+}
+
+type AdjustOrderRequest struct {
+	Kind   tables.OrderAdjustmentKind `json:"kind" validate:"required,oneof=fixed percent"`
+	Value  uint64                     `json:"value"` // Cents for fixed, whole percent for percent; 0 removes the discount
+	Reason string                     `json:"reason" validate:"required,min=3,max=500"`
+}
+
+type BulkUpdateOrderStatusRequest struct {
+	// Map of order ID to target status
+	Orders map[uuid.UUID]tables.OrderStatus `json:"orders" validate:"required,min=1,max=100,dive,required,oneof=pending paid processing shipped delivered cancelled refunded"`
+}
+
 // AttachPaymentLink attaches a Tikkie payment link to an order and sends email to customer
 func (ar *AdminRoutesManager) AttachPaymentLink(w http.ResponseWriter, r *http.Request) {
 	// Get order ID from URL
-	orderIdStr := chi.URLParam(r, "id")
-	orderId, err := uuid.Parse(orderIdStr)
+	orderId, err := lib.ParseUUIDParam(r, "id")
 	if err != nil {
-		gecho.BadRequest(w,
-			gecho.WithMessage("error.order.invalidOrderId"),
-			gecho.WithData(map[string]string{"error": err.Error()}),
-			gecho.Send(),
-		)
+		lib.RespondInvalidUUID(w, err, "error.order.invalidOrderId")
 		return
 	}
 
@@ -37,7 +54,7 @@ func (ar *AdminRoutesManager) AttachPaymentLink(w http.ResponseWriter, r *http.R
 	if err != nil {
 		gecho.BadRequest(w,
 			gecho.WithMessage("error.order.invalidRequestBody"),
-			gecho.WithData(err),
+			gecho.WithData(lib.ClientErrorData(err)),
 			gecho.Send(),
 		)
 		return
@@ -45,15 +62,18 @@ func (ar *AdminRoutesManager) AttachPaymentLink(w http.ResponseWriter, r *http.R
 
 	// Attach payment link (service handles email sending)
 	err = ar.orderService.AttachPaymentLink(r.Context(), orderId, body.PaymentLink)
+	if errors.Is(err, lib.ErrReservationReleased) {
+		gecho.Conflict(w,
+			gecho.WithMessage(lib.GetUserMessage(err)),
+			gecho.Send(),
+		)
+		return
+	}
 	if err != nil {
 		ar.logger.Error("Failed to attach payment link",
-			gecho.Field("error", err),
+			gecho.Field("error", lib.GetDetailForLogging(err)),
 			gecho.Field("order_id", orderId))
-		gecho.InternalServerError(w,
-			gecho.WithMessage("error.order.attachingPaymentLink"),
-			gecho.WithData(map[string]string{"error": err.Error()}),
-			gecho.Send(),
-		)
+		lib.RespondServerError(w, err, "error.order.attachingPaymentLink")
 		return
 	}
 
@@ -66,28 +86,33 @@ func (ar *AdminRoutesManager) AttachPaymentLink(w http.ResponseWriter, r *http.R
 // MarkOrderAsPaid marks an order as paid
 func (ar *AdminRoutesManager) MarkOrderAsPaid(w http.ResponseWriter, r *http.Request) {
 	// Get order ID from URL
-	orderIdStr := chi.URLParam(r, "id")
-	orderId, err := uuid.Parse(orderIdStr)
+	orderId, err := lib.ParseUUIDParam(r, "id")
 	if err != nil {
+		lib.RespondInvalidUUID(w, err, "error.order.invalidOrderId")
+		return
+	}
+
+	// Mark order as paid
+	err = ar.orderService.MarkOrderAsPaid(r.Context(), orderId, adminIdFromContext(r))
+	if errors.Is(err, lib.ErrInvalidStatusTransition) {
 		gecho.BadRequest(w,
-			gecho.WithMessage("error.order.invalidOrderId"),
-			gecho.WithData(map[string]string{"error": err.Error()}),
+			gecho.WithMessage("error.order.invalidStatusTransition"),
+			gecho.Send(),
+		)
+		return
+	}
+	if errors.Is(err, lib.ErrReservationReleased) {
+		gecho.Conflict(w,
+			gecho.WithMessage(lib.GetUserMessage(err)),
 			gecho.Send(),
 		)
 		return
 	}
-
-	// Mark order as paid
-	err = ar.orderService.MarkOrderAsPaid(r.Context(), orderId)
 	if err != nil {
 		ar.logger.Error("Failed to mark order as paid",
-			gecho.Field("error", err),
+			gecho.Field("error", lib.GetDetailForLogging(err)),
 			gecho.Field("order_id", orderId))
-		gecho.InternalServerError(w,
-			gecho.WithMessage("error.order.markingAsPaid"),
-			gecho.WithData(map[string]string{"error": err.Error()}),
-			gecho.Send(),
-		)
+		lib.RespondServerError(w, err, "error.order.markingAsPaid")
 		return
 	}
 
@@ -100,51 +125,41 @@ func (ar *AdminRoutesManager) MarkOrderAsPaid(w http.ResponseWriter, r *http.Req
 // UpdateOrderStatus updates the status of an order
 func (ar *AdminRoutesManager) UpdateOrderStatus(w http.ResponseWriter, r *http.Request) {
 	// Get order ID from URL
-	orderIdStr := chi.URLParam(r, "id")
-	orderId, err := uuid.Parse(orderIdStr)
+	orderId, err := lib.ParseUUIDParam(r, "id")
 	if err != nil {
-		gecho.BadRequest(w,
-			gecho.WithMessage("error.order.invalidOrderId"),
-			gecho.WithData(map[string]string{"error": err.Error()}),
-			gecho.Send(),
-		)
+		lib.RespondInvalidUUID(w, err, "error.order.invalidOrderId")
 		return
 	}
 
-	// Parse status from form
+	// Parse status from body
 	body, err := lib.ExtractAndValidateBody[UpdateOrderStatusRequest](r)
-
-	if body.Status == "" {
+	if err != nil {
 		gecho.BadRequest(w,
-			gecho.WithMessage("Body incomplete"),
+			gecho.WithMessage("error.order.invalidRequestBody"),
+			gecho.WithData(lib.ClientErrorData(err)),
 			gecho.Send(),
 		)
 		return
 	}
 
 	// Update order status
-	err = ar.orderService.UpdateOrderStatus(r.Context(), orderId, body.Status)
+	err = ar.orderService.UpdateOrderStatus(r.Context(), orderId, body.Status, adminIdFromContext(r))
 	if err != nil {
 		ar.logger.Error("Failed to update order status",
-			gecho.Field("error", err),
+			gecho.Field("error", lib.GetDetailForLogging(err)),
 			gecho.Field("order_id", orderId),
 		)
 
 		// Check if it's a validation error
-		if err.Error() == "invalid status transition" {
+		if errors.Is(err, lib.ErrInvalidStatusTransition) {
 			gecho.BadRequest(w,
 				gecho.WithMessage("error.order.invalidStatusTransition"),
-				gecho.WithData(map[string]string{"error": err.Error()}),
 				gecho.Send(),
 			)
 			return
 		}
 
-		gecho.InternalServerError(w,
-			gecho.WithMessage("error.order.updatingStatus"),
-			gecho.WithData(map[string]string{"error": err.Error()}),
-			gecho.Send(),
-		)
+		lib.RespondServerError(w, err, "error.order.updatingStatus")
 		return
 	}
 
@@ -154,36 +169,273 @@ func (ar *AdminRoutesManager) UpdateOrderStatus(w http.ResponseWriter, r *http.R
 	)
 }
 
-// DeleteOrder soft deletes an order
-func (ar *AdminRoutesManager) DeleteOrder(w http.ResponseWriter, r *http.Request) {
-	// Get order ID from URL
-	orderIdStr := chi.URLParam(r, "id")
-	orderId, err := uuid.Parse(orderIdStr)
+// BulkUpdateOrderStatus updates the status of many orders at once and reports the outcome per order
+func (ar *AdminRoutesManager) BulkUpdateOrderStatus(w http.ResponseWriter, r *http.Request) {
+	body, err := lib.ExtractAndValidateBody[BulkUpdateOrderStatusRequest](r)
 	if err != nil {
 		gecho.BadRequest(w,
-			gecho.WithMessage("error.order.invalidOrderId"),
-			gecho.WithData(map[string]string{"error": err.Error()}),
+			gecho.WithMessage("error.order.invalidRequestBody"),
+			gecho.WithData(lib.ClientErrorData(err)),
 			gecho.Send(),
 		)
 		return
 	}
 
+	updates := ar.orderService.BulkUpdateOrderStatus(r.Context(), body.Orders, adminIdFromContext(r))
+
+	results := make([]lib.BatchItemResult, 0, len(updates))
+	for _, update := range updates {
+		if update.Err != nil {
+			results = append(results, lib.BatchItemFailed(update.OrderId.String(), update.Err))
+			continue
+		}
+		results = append(results, lib.BatchItemOK(update.OrderId.String(), map[string]any{"status": update.Status}))
+	}
+
+	lib.RespondBatch(w, results, "success.order.bulkStatusUpdated", "error.order.bulkStatusUpdateFailed")
+}
+
+// adminIdFromContext returns the ID of the authenticated admin, or nil when it is unavailable
+func adminIdFromContext(r *http.Request) *uuid.UUID {
+	claims, ok := middleware.GetClaimsFromContext(r.Context())
+	if !ok {
+		return nil
+	}
+	return &claims.Sub
+}
+
+// DeleteOrder soft deletes an order
+func (ar *AdminRoutesManager) DeleteOrder(w http.ResponseWriter, r *http.Request) {
+	// Get order ID from URL
+	orderId, err := lib.ParseUUIDParam(r, "id")
+	if err != nil {
+		lib.RespondInvalidUUID(w, err, "error.order.invalidOrderId")
+		return
+	}
+
 	// Soft delete order
 	err = ar.orderService.SoftDeleteOrder(r.Context(), orderId)
 	if err != nil {
 		ar.logger.Error("Failed to delete order",
-			gecho.Field("error", err),
+			gecho.Field("error", lib.GetDetailForLogging(err)),
 			gecho.Field("order_id", orderId))
-		gecho.InternalServerError(w,
-			gecho.WithMessage("error.order.deletingOrder"),
-			gecho.WithData(map[string]string{"error": err.Error()}),
+		lib.RespondServerError(w, err, "error.order.deletingOrder")
+		return
+	}
+
+	gecho.Success(w,
+		gecho.WithMessage("success.order.deleted"),
+		gecho.Send(),
+	)
+}
+
+// UpdateOrderLine changes the quantity of an order line while the order has not shipped yet
+func (ar *AdminRoutesManager) UpdateOrderLine(w http.ResponseWriter, r *http.Request) {
+	orderId, lineId, ok := parseOrderLineParams(w, r)
+	if !ok {
+		return
+	}
+
+	body, err := lib.ExtractAndValidateBody[UpdateOrderLineRequest](r)
+	if err != nil {
+		gecho.BadRequest(w,
+			gecho.WithMessage("error.order.invalidRequestBody"),
+			gecho.WithData(lib.ClientErrorData(err)),
 			gecho.Send(),
 		)
 		return
 	}
 
+	line, err := ar.orderService.UpdateOrderLineQuantity(r.Context(), orderId, lineId, body.Quantity, adminIdFromContext(r))
+	if err != nil {
+		ar.respondOrderLineError(w, err, orderId, lineId, "error.order.updatingLine")
+		return
+	}
+
 	gecho.Success(w,
-		gecho.WithMessage("success.order.deleted"),
+		gecho.WithMessage("success.order.lineUpdated"),
+		gecho.WithData(line),
+		gecho.Send(),
+	)
+}
+
+// DeleteOrderLine removes a line from an order while the order has not shipped yet
+func (ar *AdminRoutesManager) DeleteOrderLine(w http.ResponseWriter, r *http.Request) {
+	orderId, lineId, ok := parseOrderLineParams(w, r)
+	if !ok {
+		return
+	}
+
+	err := ar.orderService.DeleteOrderLine(r.Context(), orderId, lineId, adminIdFromContext(r))
+	if err != nil {
+		ar.respondOrderLineError(w, err, orderId, lineId, "error.order.deletingLine")
+		return
+	}
+
+	gecho.Success(w,
+		gecho.WithMessage("success.order.lineDeleted"),
+		gecho.Send(),
+	)
+}
+
+// parseOrderLineParams reads the order and line IDs from the URL, writing a 400 when either is invalid
+func parseOrderLineParams(w http.ResponseWriter, r *http.Request) (orderId, lineId uuid.UUID, ok bool) {
+	orderId, err := lib.ParseUUIDParam(r, "id")
+	if err != nil {
+		lib.RespondInvalidUUID(w, err, "error.order.invalidOrderId")
+		return uuid.Nil, uuid.Nil, false
+	}
+
+	lineId, err = lib.ParseUUIDParam(r, "lineId")
+	if err != nil {
+		lib.RespondInvalidUUID(w, err, "error.order.invalidLineId")
+		return uuid.Nil, uuid.Nil, false
+	}
+
+	return orderId, lineId, true
+}
+
+// respondOrderLineError maps the errors of the order line edit endpoints to responses
+func (ar *AdminRoutesManager) respondOrderLineError(w http.ResponseWriter, err error, orderId, lineId uuid.UUID, message string) {
+	var limitErr *lib.OrderLimitError
+	var stockErr *lib.InsufficientStockError
+	switch {
+	case errors.As(err, &limitErr):
+		gecho.BadRequest(w,
+			gecho.WithMessage(lib.GetUserMessage(err)),
+			gecho.WithData(limitErr),
+			gecho.Send(),
+		)
+	case errors.As(err, &stockErr):
+		gecho.Conflict(w,
+			gecho.WithMessage(lib.GetUserMessage(err)),
+			gecho.WithData(stockErr),
+			gecho.Send(),
+		)
+	case errors.Is(err, lib.ErrOrderNotEditable):
+		gecho.Conflict(w,
+			gecho.WithMessage(lib.GetUserMessage(err)),
+			gecho.Send(),
+		)
+	case errors.Is(err, lib.ErrLastOrderLine):
+		gecho.BadRequest(w,
+			gecho.WithMessage(lib.GetUserMessage(err)),
+			gecho.Send(),
+		)
+	case lib.IsNotFound(err):
+		gecho.NotFound(w,
+			gecho.WithMessage("error.order.lineNotFound"),
+			gecho.Send(),
+		)
+	default:
+		ar.logger.Error("Failed to edit order line",
+			gecho.Field("error", lib.GetDetailForLogging(err)),
+			gecho.Field("order_id", orderId),
+			gecho.Field("line_id", lineId))
+		lib.RespondServerError(w, err, message)
+	}
+}
+
+// UpdateOrderEmail corrects a mistyped customer email on an order that has not shipped yet,
+// optionally re-sending the order confirmation to the new address
+func (ar *AdminRoutesManager) UpdateOrderEmail(w http.ResponseWriter, r *http.Request) {
+	// Get order ID from URL
+	orderId, err := lib.ParseUUIDParam(r, "id")
+	if err != nil {
+		lib.RespondInvalidUUID(w, err, "error.order.invalidOrderId")
+		return
+	}
+
+	body, err := lib.ExtractAndValidateBody[UpdateOrderEmailRequest](r)
+	if err != nil {
+		gecho.BadRequest(w,
+			gecho.WithMessage("error.order.invalidRequestBody"),
+			gecho.WithData(lib.ClientErrorData(err)),
+			gecho.Send(),
+		)
+		return
+	}
+
+	order, err := ar.orderService.UpdateOrderEmail(r.Context(), orderId, lib.NormalizeEmail(body.Email), body.ResendConfirmation, adminIdFromContext(r))
+	if err != nil {
+		switch {
+		case errors.Is(err, lib.ErrOrderNotEditable):
+			gecho.Conflict(w,
+				gecho.WithMessage(lib.GetUserMessage(err)),
+				gecho.Send(),
+			)
+		case lib.IsNotFound(err):
+			gecho.NotFound(w,
+				gecho.WithMessage("error.order.notFound"),
+				gecho.Send(),
+			)
+		default:
+			ar.logger.Error("Failed to update order email",
+				gecho.Field("error", lib.GetDetailForLogging(err)),
+				gecho.Field("order_id", orderId),
+			)
+			lib.RespondServerError(w, err, "error.order.updatingEmail")
+		}
+		return
+	}
+
+	gecho.Success(w,
+		gecho.WithMessage("success.order.emailUpdated"),
+		gecho.WithData(order),
+		gecho.Send(),
+	)
+}
+
+// AdjustOrder applies a manual discount to an unpaid order that has not shipped yet, replacing any earlier one
+func (ar *AdminRoutesManager) AdjustOrder(w http.ResponseWriter, r *http.Request) {
+	// Get order ID from URL
+	orderId, err := lib.ParseUUIDParam(r, "id")
+	if err != nil {
+		lib.RespondInvalidUUID(w, err, "error.order.invalidOrderId")
+		return
+	}
+
+	body, err := lib.ExtractAndValidateBody[AdjustOrderRequest](r)
+	if err != nil {
+		gecho.BadRequest(w,
+			gecho.WithMessage("error.order.invalidRequestBody"),
+			gecho.WithData(lib.ClientErrorData(err)),
+			gecho.Send(),
+		)
+		return
+	}
+
+	order, err := ar.orderService.ApplyOrderDiscount(r.Context(), orderId, body.Kind, body.Value, strings.TrimSpace(body.Reason), adminIdFromContext(r))
+	if err != nil {
+		switch {
+		case errors.Is(err, lib.ErrOrderNotAdjustable):
+			gecho.Conflict(w,
+				gecho.WithMessage(lib.GetUserMessage(err)),
+				gecho.Send(),
+			)
+		case errors.Is(err, lib.ErrInvalidOrderDiscount):
+			gecho.BadRequest(w,
+				gecho.WithMessage(lib.GetUserMessage(err)),
+				gecho.Send(),
+			)
+		case lib.IsNotFound(err):
+			gecho.NotFound(w,
+				gecho.WithMessage("error.order.notFound"),
+				gecho.Send(),
+			)
+		default:
+			ar.logger.Error("Failed to adjust order",
+				gecho.Field("error", lib.GetDetailForLogging(err)),
+				gecho.Field("order_id", orderId),
+			)
+			lib.RespondServerError(w, err, "error.order.adjustingOrder")
+		}
+		return
+	}
+
+	gecho.Success(w,
+		gecho.WithMessage("success.order.adjusted"),
+		gecho.WithData(order),
 		gecho.Send(),
 	)
 }
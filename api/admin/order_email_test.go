@@ -0,0 +1,43 @@
+package admin
+
+import (
+	"context"
+	"mamabloemetjes_server/testutil"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+
+	"github.com/go-chi/chi/v5"
+	"github.com/google/uuid"
+)
+
+func TestUpdateOrderEmailValidation(t *testing.T) {
+	// Invalid requests are rejected before the order service is reached
+	ar := &AdminRoutesManager{logger: testutil.Logger()}
+
+	tests := []struct {
+		name    string
+		orderId string
+		body    string
+	}{
+		{"invalid order id", "not-a-uuid", `{"email": "jan@example.com"}`},
+		{"missing email", uuid.NewString(), `{"resend_confirmation": true}`},
+		{"malformed email", uuid.NewString(), `{"email": "jan@"}`},
+		{"unknown field", uuid.NewString(), `{"email": "jan@example.com", "name": "Jan"}`},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			r := httptest.NewRequest(http.MethodPatch, "/admin/orders/"+tt.orderId+"/email", strings.NewReader(tt.body))
+			routeCtx := chi.NewRouteContext()
+			routeCtx.URLParams.Add("id", tt.orderId)
+			r = r.WithContext(context.WithValue(r.Context(), chi.RouteCtxKey, routeCtx))
+			w := httptest.NewRecorder()
+
+			ar.UpdateOrderEmail(w, r)
+			if w.Code != http.StatusBadRequest {
+				t.Fatalf("expected status 400, got %d", w.Code)
+			}
+		})
+	}
+}
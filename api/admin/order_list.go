@@ -1,13 +1,13 @@
 package admin
 
 import (
+	"errors"
+	"mamabloemetjes_server/lib"
 	"mamabloemetjes_server/structs/tables"
 	"net/http"
-	"strconv"
+	"strings"
 
 	"github.com/MonkyMars/gecho"
-	"github.com/go-chi/chi/v5"
-	"github.com/google/uuid"
 )
 
 // ListOrders returns a paginated list of orders with optional filtering
@@ -16,17 +16,7 @@ func (ar *AdminRoutesManager) ListOrders(w http.ResponseWriter, r *http.Request)
 	query := r.URL.Query()
 
 	// Pagination
-	page, _ := strconv.Atoi(query.Get("page"))
-	if page < 1 {
-		page = 1
-	}
-
-	pageSize, _ := strconv.Atoi(query.Get("page_size"))
-	if pageSize < 1 || pageSize > 100 {
-		pageSize = 20
-	}
-
-	offset := (page - 1) * pageSize
+	page, pageSize := lib.ParsePagination(r)
 
 	// Filters
 	var status *tables.OrderStatus
@@ -41,35 +31,33 @@ func (ar *AdminRoutesManager) ListOrders(w http.ResponseWriter, r *http.Request)
 		paymentStatus = &ps
 	}
 
+	// Sorting, validated against the order allowlist by the service
+	sortBy := query.Get("sort_by")
+	sortDirection := strings.ToUpper(query.Get("sort_direction"))
+
 	// Get orders from service
-	orders, total, err := ar.orderService.GetAllOrders(r.Context(), status, paymentStatus, pageSize, offset)
+	result, err := ar.orderService.GetAllOrders(r.Context(), status, paymentStatus, page, pageSize, sortBy, sortDirection)
 	if err != nil {
+		var validationErr *lib.ValidationError
+		if errors.As(err, &validationErr) {
+			gecho.BadRequest(w, gecho.WithMessage("error.invalidQueryParameters"), gecho.WithData(validationErr), gecho.Send())
+			return
+		}
 		ar.logger.Error("Failed to get orders",
-			gecho.Field("error", err),
+			gecho.Field("error", lib.GetDetailForLogging(err)),
 			gecho.Field("page", page),
 			gecho.Field("page_size", pageSize))
-		gecho.InternalServerError(w,
-			gecho.WithMessage("error.order.fetchingOrders"),
-			gecho.WithData(map[string]string{"error": err.Error()}),
-			gecho.Send(),
-		)
+		lib.RespondServerError(w, err, "error.order.fetchingOrders")
 		return
 	}
 
-	// Calculate pagination metadata
-	totalPages := (total + pageSize - 1) / pageSize
+	if lib.WantsPaginationLinks(r) {
+		result.Links = lib.NewPaginationLinks(r, result.Pagination.Page, result.Pagination.TotalPages)
+	}
 
 	gecho.Success(w,
 		gecho.WithMessage("success.order.ordersFetched"),
-		gecho.WithData(map[string]interface{}{
-			"orders": orders,
-			"pagination": map[string]interface{}{
-				"page":        page,
-				"page_size":   pageSize,
-				"total":       total,
-				"total_pages": totalPages,
-			},
-		}),
+		gecho.WithData(result),
 		gecho.Send(),
 	)
 }
@@ -77,14 +65,9 @@ func (ar *AdminRoutesManager) ListOrders(w http.ResponseWriter, r *http.Request)
 // GetOrderDetails returns detailed information about a specific order
 func (ar *AdminRoutesManager) GetOrderDetails(w http.ResponseWriter, r *http.Request) {
 	// Get order ID from URL
-	orderIdStr := chi.URLParam(r, "id")
-	orderId, err := uuid.Parse(orderIdStr)
+	orderId, err := lib.ParseUUIDParam(r, "id")
 	if err != nil {
-		gecho.BadRequest(w,
-			gecho.WithMessage("error.order.invalidOrderId"),
-			gecho.WithData(map[string]string{"error": err.Error()}),
-			gecho.Send(),
-		)
+		lib.RespondInvalidUUID(w, err, "error.order.invalidOrderId")
 		return
 	}
 
@@ -92,27 +75,27 @@ func (ar *AdminRoutesManager) GetOrderDetails(w http.ResponseWriter, r *http.Req
 	order, err := ar.orderService.GetOrderById(r.Context(), orderId)
 	if err != nil {
 		ar.logger.Error("Failed to get order",
-			gecho.Field("error", err),
+			gecho.Field("error", lib.GetDetailForLogging(err)),
 			gecho.Field("order_id", orderId))
 		gecho.NotFound(w,
 			gecho.WithMessage("error.order.notFound"),
-			gecho.WithData(map[string]string{"error": err.Error()}),
 			gecho.Send(),
 		)
 		return
 	}
 
+	// Lines and address are only loaded when the client's copy is out of date
+	if lib.NotModified(w, r, order.UpdatedAt) {
+		return
+	}
+
 	// Get order lines
-	orderLines, err := ar.orderService.GetOrderLinesByOrderId(r.Context(), orderId)
+	orderLines, err := ar.orderService.GetOrderLinesWithProducts(r.Context(), orderId)
 	if err != nil {
 		ar.logger.Error("Failed to get order lines",
-			gecho.Field("error", err),
+			gecho.Field("error", lib.GetDetailForLogging(err)),
 			gecho.Field("order_id", orderId))
-		gecho.InternalServerError(w,
-			gecho.WithMessage("error.order.fetchingOrderLines"),
-			gecho.WithData(map[string]string{"error": err.Error()}),
-			gecho.Send(),
-		)
+		lib.RespondServerError(w, err, "error.order.fetchingOrderLines")
 		return
 	}
 
@@ -120,21 +103,13 @@ func (ar *AdminRoutesManager) GetOrderDetails(w http.ResponseWriter, r *http.Req
 	address, err := ar.orderService.GetAddressById(r.Context(), order.AddressId)
 	if err != nil {
 		ar.logger.Error("Failed to get address",
-			gecho.Field("error", err),
+			gecho.Field("error", lib.GetDetailForLogging(err)),
 			gecho.Field("address_id", order.AddressId))
-		gecho.InternalServerError(w,
-			gecho.WithMessage("error.order.fetchingAddress"),
-			gecho.WithData(map[string]string{"error": err.Error()}),
-			gecho.Send(),
-		)
+		lib.RespondServerError(w, err, "error.order.fetchingAddress")
 		return
 	}
 
-	// Calculate total
-	var total uint64
-	for _, line := range orderLines {
-		total += line.LineTotal
-	}
+	totals := order.Totals(orderLines)
 
 	gecho.Success(w,
 		gecho.WithMessage("success.order.orderDetailsFetched"),
@@ -142,7 +117,8 @@ func (ar *AdminRoutesManager) GetOrderDetails(w http.ResponseWriter, r *http.Req
 			"order":       order,
 			"order_lines": orderLines,
 			"address":     address,
-			"total":       total,
+			"total":       totals.Total,
+			"totals":      totals,
 		}),
 		gecho.Send(),
 	)
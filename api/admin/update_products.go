@@ -1,6 +1,7 @@
 package admin
 
 import (
+	"errors"
 	"mamabloemetjes_server/lib"
 	"mamabloemetjes_server/services"
 	"mamabloemetjes_server/structs/tables"
@@ -19,7 +20,8 @@ type UpdateProductRequest struct {
 	Subtotal    *uint64               `json:"subtotal,omitempty" validate:"omitempty,gte=0"`
 	Description *string               `json:"description,omitempty" validate:"omitempty,min=10,max=2000"`
 	IsActive    *bool                 `json:"is_active,omitempty"`
-	ProductType *string               `json:"product_type,omitempty" validate:"omitempty,min=2,max=100"`
+	MadeToOrder *bool                 `json:"made_to_order,omitempty"`
+	ProductType *string               `json:"product_type,omitempty" validate:"omitempty,product_type"`
 	Stock       *uint16               `json:"stock,omitempty" validate:"omitempty,gte=0"`
 	Images      []tables.ProductImage `json:"images,omitempty" validate:"omitempty,dive"`
 }
@@ -40,12 +42,13 @@ func (ar *AdminRoutesManager) UpdateProducts(w http.ResponseWriter, r *http.Requ
 		return
 	}
 
-	totalErrors := make(map[string]string)
+	results := make([]lib.BatchItemResult, 0, len(body.Products))
+	updates := make(map[uuid.UUID]*services.UpdateProductRequest, len(body.Products))
 	for productID, updateReq := range body.Products {
 		productUUID, parseErr := uuid.Parse(productID)
 		if parseErr != nil {
-			ar.logger.Error("Invalid product ID format", gecho.Field("error", parseErr), gecho.Field("product_id", productID))
-			totalErrors[productID] = "error.products.invalidIdFormat"
+			ar.logger.Warn("Invalid product ID format", gecho.Field("error", parseErr), gecho.Field("product_id", productID))
+			results = append(results, lib.BatchItemFailed(productID, lib.NewFieldError("id", "error.products.invalidIdFormat")))
 			continue
 		}
 
@@ -58,24 +61,27 @@ func (ar *AdminRoutesManager) UpdateProducts(w http.ResponseWriter, r *http.Requ
 			Tax:         updateReq.Tax,
 			Description: updateReq.Description,
 			IsActive:    updateReq.IsActive,
+			MadeToOrder: updateReq.MadeToOrder,
 			Images:      updateReq.Images,
 			ProductType: updateReq.ProductType,
 		}
+		updates[productUUID] = serviceReq
+	}
 
-		if err := ar.productService.UpdateProduct(r.Context(), productUUID, serviceReq); err != nil {
-			ar.logger.Error("Failed to update product", gecho.Field("error", err), gecho.Field("product_id", productID))
-			totalErrors[productID] = err.Error()
+	// Updated products share a single cache invalidation
+	for productUUID, err := range ar.productService.UpdateProducts(r.Context(), updates) {
+		productID := productUUID.String()
+		if err != nil {
+			var validationErr *lib.ValidationError
+			if !errors.As(err, &validationErr) {
+				ar.logger.Error("Failed to update product", gecho.Field("error", lib.GetDetailForLogging(err)), gecho.Field("product_id", productID))
+			}
+			results = append(results, lib.BatchItemFailed(productID, err))
+			continue
 		}
-	}
 
-	if len(totalErrors) > 0 {
-		gecho.InternalServerError(w,
-			gecho.WithMessage("error.products.someFailedToUpdate"),
-			gecho.WithData(map[string]any{"errors": totalErrors}),
-			gecho.Send(),
-		)
-		return
+		results = append(results, lib.BatchItemOK(productID, nil))
 	}
 
-	gecho.Success(w, gecho.WithMessage("success.products.updated"), gecho.Send())
+	lib.RespondBatch(w, results, "success.products.updated", "error.products.someFailedToUpdate")
 }
@@ -0,0 +1,121 @@
+package admin
+
+import (
+	"mamabloemetjes_server/lib"
+	"mamabloemetjes_server/structs/tables"
+	"net/http"
+	"strings"
+
+	"github.com/MonkyMars/gecho"
+	"github.com/google/uuid"
+)
+
+// LookupUser handles GET /admin/users?email= to find a customer by email address
+func (ar *AdminRoutesManager) LookupUser(w http.ResponseWriter, r *http.Request) {
+	email := strings.TrimSpace(r.URL.Query().Get("email"))
+	if email == "" {
+		gecho.BadRequest(w,
+			gecho.WithMessage("error.user.emailRequired"),
+			gecho.Send(),
+		)
+		return
+	}
+
+	user, err := ar.authService.GetUserByEmail(email)
+	if err != nil {
+		ar.respondUserLookupError(w, err)
+		return
+	}
+
+	ar.auditUserLookup(r, "email", user.Id)
+	ar.respondUserDetails(w, r, user)
+}
+
+// GetUser handles GET /admin/users/{id}
+func (ar *AdminRoutesManager) GetUser(w http.ResponseWriter, r *http.Request) {
+	userId, err := lib.ParseUUIDParam(r, "id")
+	if err != nil {
+		lib.RespondInvalidUUID(w, err, "error.user.invalidUserId")
+		return
+	}
+
+	user, err := ar.authService.GetUserByID(r.Context(), userId)
+	if err != nil {
+		ar.respondUserLookupError(w, err)
+		return
+	}
+
+	ar.auditUserLookup(r, "id", user.Id)
+	ar.respondUserDetails(w, r, user)
+}
+
+// respondUserDetails writes the user with their addresses, order summary and a page of their orders
+// The password hash is never serialized (json:"-"), it is cleared as well so it cannot leak through other encoders
+func (ar *AdminRoutesManager) respondUserDetails(w http.ResponseWriter, r *http.Request, user *tables.User) {
+	ctx := r.Context()
+	page, pageSize := lib.ParsePagination(r)
+
+	details := *user
+	details.PasswordHash = ""
+
+	addresses, err := ar.orderService.GetUserAddresses(ctx, user.Id)
+	if err != nil {
+		ar.logger.Error("Failed to get user addresses", gecho.Field("error", lib.GetDetailForLogging(err)), gecho.Field("user_id", user.Id))
+		lib.RespondServerError(w, err, "error.user.fetchingUser")
+		return
+	}
+
+	orders, err := ar.orderService.GetUserOrders(ctx, user.Id, page, pageSize)
+	if err != nil {
+		ar.logger.Error("Failed to get user orders", gecho.Field("error", lib.GetDetailForLogging(err)), gecho.Field("user_id", user.Id))
+		lib.RespondServerError(w, err, "error.user.fetchingUser")
+		return
+	}
+
+	summary, err := ar.orderService.GetUserOrderSummary(ctx, user.Id)
+	if err != nil {
+		ar.logger.Error("Failed to get user order summary", gecho.Field("error", lib.GetDetailForLogging(err)), gecho.Field("user_id", user.Id))
+		lib.RespondServerError(w, err, "error.user.fetchingUser")
+		return
+	}
+
+	data := map[string]any{
+		"user":       &details,
+		"addresses":  addresses,
+		"summary":    summary,
+		"orders":     orders.Orders,
+		"pagination": orders.Pagination,
+	}
+	if lib.WantsPaginationLinks(r) {
+		data["links"] = lib.NewPaginationLinks(r, orders.Pagination.Page, orders.Pagination.TotalPages)
+	}
+
+	gecho.Success(w,
+		gecho.WithMessage("success.user.fetched"),
+		gecho.WithData(data),
+		gecho.Send(),
+	)
+}
+
+// respondUserLookupError answers 404 for unknown users and a server error otherwise
+func (ar *AdminRoutesManager) respondUserLookupError(w http.ResponseWriter, err error) {
+	if lib.IsNotFound(err) {
+		gecho.NotFound(w,
+			gecho.WithMessage("error.user.notFound"),
+			gecho.Send(),
+		)
+		return
+	}
+
+	ar.logger.Error("Failed to look up user", gecho.Field("error", lib.GetDetailForLogging(err)))
+	lib.RespondServerError(w, err, "error.user.fetchingUser")
+}
+
+// auditUserLookup records which admin looked up which customer, customer data being personal data
+func (ar *AdminRoutesManager) auditUserLookup(r *http.Request, by string, userId uuid.UUID) {
+	ar.logger.Info("Admin user lookup",
+		gecho.Field("audit", true),
+		gecho.Field("admin_id", adminIdFromContext(r)),
+		gecho.Field("lookup_by", by),
+		gecho.Field("user_id", userId))
+}
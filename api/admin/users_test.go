@@ -0,0 +1,167 @@
+package admin
+
+import (
+	"bytes"
+	"context"
+	"encoding/json"
+	"mamabloemetjes_server/api/middleware"
+	"mamabloemetjes_server/services"
+	"mamabloemetjes_server/structs"
+	"mamabloemetjes_server/structs/tables"
+	"mamabloemetjes_server/testutil"
+	"net/http"
+	"net/http/httptest"
+	"net/url"
+	"strings"
+	"testing"
+
+	"github.com/go-chi/chi/v5"
+	"github.com/google/uuid"
+)
+
+// userLookupRequest returns a lookup request made by admin, with id as the route parameter when set
+func userLookupRequest(admin uuid.UUID, target, id string) *http.Request {
+	r := httptest.NewRequest(http.MethodGet, target, nil)
+	ctx := context.WithValue(r.Context(), middleware.ClaimsContextKey, &structs.AuthClaims{Sub: admin, Role: "admin"})
+	if id != "" {
+		routeCtx := chi.NewRouteContext()
+		routeCtx.URLParams.Add("id", id)
+		ctx = context.WithValue(ctx, chi.RouteCtxKey, routeCtx)
+	}
+	return r.WithContext(ctx)
+}
+
+func TestUserLookupValidation(t *testing.T) {
+	// Invalid requests are rejected before the services are reached
+	ar := &AdminRoutesManager{logger: testutil.Logger()}
+	admin := uuid.New()
+
+	w := httptest.NewRecorder()
+	ar.LookupUser(w, userLookupRequest(admin, "/admin/users?email=%20", ""))
+	if w.Code != http.StatusBadRequest {
+		t.Fatalf("expected status 400 without an email, got %d", w.Code)
+	}
+
+	w = httptest.NewRecorder()
+	ar.GetUser(w, userLookupRequest(admin, "/admin/users/not-a-uuid", "not-a-uuid"))
+	if w.Code != http.StatusBadRequest {
+		t.Fatalf("expected status 400 for an invalid id, got %d", w.Code)
+	}
+}
+
+func TestUserLookupByEmailAndId(t *testing.T) {
+	db := testutil.DB(t)
+	testutil.Redis(t)
+	cfg := testutil.Config()
+	logger := testutil.Logger()
+	ctx := context.Background()
+
+	cache := services.NewCacheService(logger, cfg)
+	authService := services.NewAuthService(cfg, logger, db, cache)
+	productService := services.NewProductService(logger, cfg, db, cache)
+	orderService := services.NewOrderService(logger, cfg, db, productService, services.NewEmailService(logger, cfg, db, authService))
+	// Only the handler logs to the buffer, the services log from background goroutines too
+	var logs bytes.Buffer
+	ar := &AdminRoutesManager{logger: testutil.LoggerTo(&logs), authService: authService, productService: productService, orderService: orderService}
+
+	user, err := authService.Register(&structs.RegisterRequest{Username: "Jan Jansen", Email: "jan@example.com", Password: "correct horse battery"})
+	if err != nil {
+		t.Fatalf("Register: %v", err)
+	}
+	product := &tables.Product{
+		ID:          uuid.New(),
+		Name:        "Rozenboeket",
+		SKU:         "SKU-USERS",
+		Price:       2500,
+		Subtotal:    2500,
+		Currency:    "EUR",
+		Description: "A bouquet of red roses",
+		IsActive:    true,
+		MadeToOrder: true,
+	}
+	if _, err := db.NewInsert().Model(product).Exec(ctx); err != nil {
+		t.Fatalf("failed to seed product: %v", err)
+	}
+	_, err = orderService.CreateOrderFromRequest(ctx, &structs.OrderRequest{
+		Name:       "Jan Jansen",
+		Email:      "jan@example.com",
+		Phone:      "0612345678",
+		Street:     "Dorpsstraat",
+		HouseNo:    "1",
+		PostalCode: "1234 AB",
+		City:       "Utrecht",
+		Country:    "NL",
+		Products:   map[string]int{product.ID.String(): 2},
+	}, &user.Id)
+	if err != nil {
+		t.Fatalf("CreateOrderFromRequest: %v", err)
+	}
+
+	// Register clears the hash on the returned user, so read the stored one
+	var passwordHash string
+	if err := db.NewSelect().Model((*tables.User)(nil)).Column("password_hash").Where("id = ?", user.Id).Scan(ctx, &passwordHash); err != nil {
+		t.Fatalf("failed to read the password hash: %v", err)
+	}
+
+	admin := uuid.New()
+	lookups := []struct {
+		name    string
+		handler http.HandlerFunc
+		request *http.Request
+	}{
+		{"by email", ar.LookupUser, userLookupRequest(admin, "/admin/users?"+url.Values{"email": {"Jan@Example.com"}}.Encode(), "")},
+		{"by id", ar.GetUser, userLookupRequest(admin, "/admin/users/"+user.Id.String(), user.Id.String())},
+	}
+	for _, lookup := range lookups {
+		t.Run(lookup.name, func(t *testing.T) {
+			logs.Reset()
+			w := httptest.NewRecorder()
+			lookup.handler(w, lookup.request)
+			if w.Code != http.StatusOK {
+				t.Fatalf("expected status 200, got %d: %s", w.Code, w.Body.String())
+			}
+
+			body := w.Body.String()
+			if strings.Contains(body, "password") || strings.Contains(body, passwordHash) {
+				t.Fatalf("expected the password hash to stay out of the lookup, got %s", body)
+			}
+
+			var response struct {
+				Data struct {
+					User      tables.User               `json:"user"`
+					Addresses []tables.Address          `json:"addresses"`
+					Summary   services.UserOrderSummary `json:"summary"`
+					Orders    []tables.Order            `json:"orders"`
+				} `json:"data"`
+			}
+			if err := json.Unmarshal(w.Body.Bytes(), &response); err != nil {
+				t.Fatalf("failed to decode the lookup: %v", err)
+			}
+			details := response.Data
+			if details.User.Id != user.Id || details.User.Email != "jan@example.com" {
+				t.Fatalf("expected user %s, got %+v", user.Id, details.User)
+			}
+			if len(details.Addresses) != 1 || len(details.Orders) != 1 || details.Summary.Totals["EUR"] != 5000 {
+				t.Fatalf("expected one address and one order worth 5000 cents, got %s", body)
+			}
+
+			if !strings.Contains(logs.String(), "Admin user lookup") || !strings.Contains(logs.String(), admin.String()) {
+				t.Fatalf("expected the lookup to be audited with the admin id, got %s", logs.String())
+			}
+		})
+	}
+
+	for _, unknown := range []struct {
+		handler http.HandlerFunc
+		request *http.Request
+	}{
+		{ar.LookupUser, userLookupRequest(admin, "/admin/users?email=piet%40example.com", "")},
+		{ar.GetUser, userLookupRequest(admin, "/admin/users/"+admin.String(), admin.String())},
+	} {
+		w := httptest.NewRecorder()
+		unknown.handler(w, unknown.request)
+		if w.Code != http.StatusNotFound {
+			t.Fatalf("expected status 404 for an unknown user, got %d", w.Code)
+		}
+	}
+}
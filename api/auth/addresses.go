@@ -11,7 +11,7 @@ func (ar *AuthRoutesManager) HandleGetAddresses(w http.ResponseWriter, r *http.R
 	// Get user ID from claims (set by UserAuthMiddleware)
 	claims, err := lib.ExtractClaims(r)
 	if err != nil {
-		ar.logger.Error("Failed to extract claims", gecho.Field("error", err))
+		ar.logger.Error("Failed to extract claims", gecho.Field("error", lib.GetDetailForLogging(err)))
 		gecho.Unauthorized(w, gecho.WithMessage("error.auth.unauthorized"), gecho.Send())
 		return
 	}
@@ -20,21 +20,21 @@ func (ar *AuthRoutesManager) HandleGetAddresses(w http.ResponseWriter, r *http.R
 	addresses, err := ar.orderService.GetUserAddresses(r.Context(), claims.Sub)
 	if err != nil {
 		ar.logger.Error("Failed to get user addresses",
-			gecho.Field("error", err),
+			gecho.Field("error", lib.GetDetailForLogging(err)),
 			gecho.Field("user_id", claims.Sub),
 		)
-		gecho.InternalServerError(w, gecho.WithMessage("error.addresses.fetchFailed"), gecho.Send())
+		lib.RespondServerError(w, err, "error.addresses.fetchFailed")
 		return
 	}
 
 	// Get user information
-	user, err := ar.authService.GetUserByID(claims.Sub)
+	user, err := ar.authService.GetUserByID(r.Context(), claims.Sub)
 	if err != nil {
 		ar.logger.Error("Failed to get user information",
-			gecho.Field("error", err),
+			gecho.Field("error", lib.GetDetailForLogging(err)),
 			gecho.Field("user_id", claims.Sub),
 		)
-		gecho.InternalServerError(w, gecho.WithMessage("error.user.fetchFailed"), gecho.Send())
+		lib.RespondServerError(w, err, "error.user.fetchFailed")
 		return
 	}
 
@@ -0,0 +1,61 @@
+package auth
+
+import (
+	"errors"
+	"mamabloemetjes_server/api/middleware"
+	"mamabloemetjes_server/lib"
+	"net/http"
+
+	"github.com/MonkyMars/gecho"
+)
+
+type ChangePasswordRequest struct {
+	CurrentPassword string `json:"current_password" validate:"required"`
+	NewPassword     string `json:"new_password" validate:"required,min=8,max=100,nefield=CurrentPassword"`
+}
+
+// HandleChangePassword changes the signed-in user's password
+// All sessions are revoked, so other devices are signed out; this client gets a new session right away
+func (ar *AuthRoutesManager) HandleChangePassword(w http.ResponseWriter, r *http.Request) {
+	claims, ok := middleware.GetClaimsFromContext(r.Context())
+	if !ok {
+		gecho.Unauthorized(w, gecho.WithMessage("error.auth.unauthorized"), gecho.Send())
+		return
+	}
+
+	body, err := lib.ExtractAndValidateBody[ChangePasswordRequest](r)
+	if err != nil {
+		ar.logger.Warn("Failed to extract and validate request body", gecho.Field("error", err))
+		gecho.BadRequest(w, gecho.WithMessage("error.invalidRequest"), gecho.WithData(lib.ClientErrorData(err)), gecho.Send())
+		return
+	}
+
+	if err := ar.authService.ChangePassword(claims.Sub, body.CurrentPassword, body.NewPassword); err != nil {
+		if errors.Is(err, lib.ErrInvalidCredentials) {
+			gecho.BadRequest(w, gecho.WithMessage("error.auth.invalidCurrentPassword"), gecho.Send())
+			return
+		}
+		ar.logger.Error("Failed to change password", gecho.Field("error", lib.GetDetailForLogging(err)), gecho.Field("user_id", claims.Sub))
+		lib.RespondServerError(w, err, "error.auth.failedToChangePassword")
+		return
+	}
+
+	// The access token used for this request belongs to the old password
+	if err := ar.cacheService.BlacklistToken(claims.Jti, claims.Exp); err != nil {
+		ar.logger.Warn("Failed to blacklist access token after password change", gecho.Field("error", lib.GetDetailForLogging(err)), gecho.Field("user_id", claims.Sub))
+	}
+
+	user, err := ar.authService.GetUserByID(r.Context(), claims.Sub)
+	if err == nil {
+		err = ar.startSession(w, r, user)
+	}
+	if err != nil {
+		// The password is changed, the user only has to log in again
+		lib.ClearCookie(lib.AccessCookieName, w)
+		lib.ClearCookie(lib.RefreshCookieName, w)
+		gecho.Success(w, gecho.WithMessage("success.auth.passwordChangedLoginAgain"), gecho.Send())
+		return
+	}
+
+	gecho.Success(w, gecho.WithMessage("success.auth.passwordChanged"), gecho.Send())
+}
@@ -0,0 +1,124 @@
+package auth
+
+import (
+	"context"
+	"encoding/json"
+	"mamabloemetjes_server/api/middleware"
+	"mamabloemetjes_server/lib"
+	"mamabloemetjes_server/services"
+	"mamabloemetjes_server/structs"
+	"mamabloemetjes_server/structs/tables"
+	"mamabloemetjes_server/testutil"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/google/uuid"
+)
+
+// changePassword posts the passwords to the change handler as the holder of claims, skipping the claims when nil
+func changePassword(t *testing.T, ar *AuthRoutesManager, claims *structs.AuthClaims, current, next string) (*httptest.ResponseRecorder, string) {
+	t.Helper()
+	body, err := json.Marshal(ChangePasswordRequest{CurrentPassword: current, NewPassword: next})
+	if err != nil {
+		t.Fatalf("failed to encode the request: %v", err)
+	}
+	r := httptest.NewRequest(http.MethodPost, "/auth/change-password", strings.NewReader(string(body)))
+	if claims != nil {
+		r = r.WithContext(context.WithValue(r.Context(), middleware.ClaimsContextKey, claims))
+	}
+	w := httptest.NewRecorder()
+	ar.HandleChangePassword(w, r)
+
+	var response struct {
+		Message string `json:"message"`
+	}
+	if err := json.Unmarshal(w.Body.Bytes(), &response); err != nil {
+		t.Fatalf("failed to decode the response: %v", err)
+	}
+	return w, response.Message
+}
+
+func TestChangePasswordRejectsInvalidRequests(t *testing.T) {
+	// Both are rejected before the services are reached
+	ar := &AuthRoutesManager{logger: testutil.Logger(), cfg: testutil.Config()}
+
+	if w, _ := changePassword(t, ar, nil, "correct horse battery", "a new password"); w.Code != http.StatusUnauthorized {
+		t.Fatalf("expected status 401 without a signed-in user, got %d", w.Code)
+	}
+	claims := &structs.AuthClaims{Sub: uuid.New(), Jti: uuid.New(), Exp: time.Now().Add(time.Hour)}
+	if w, _ := changePassword(t, ar, claims, "correct horse battery", "correct horse battery"); w.Code != http.StatusBadRequest {
+		t.Fatalf("expected status 400 for an unchanged password, got %d", w.Code)
+	}
+}
+
+func TestChangePassword(t *testing.T) {
+	db := testutil.DB(t)
+	testutil.Redis(t)
+	cfg := testutil.Config()
+	logger := testutil.Logger()
+	ctx := context.Background()
+
+	cache := services.NewCacheService(logger, cfg)
+	authService := services.NewAuthService(cfg, logger, db, cache)
+	ar := &AuthRoutesManager{logger: logger, authService: authService, cacheService: cache, cfg: cfg}
+
+	user, err := authService.Register(&structs.RegisterRequest{Username: "Jan Jansen", Email: "jan@example.com", Password: "correct horse battery"})
+	if err != nil {
+		t.Fatalf("Register: %v", err)
+	}
+	// A session on another device
+	otherDevice, err := authService.GenerateRefreshToken(user, "other-agent", "5.6.7.8")
+	if err != nil {
+		t.Fatalf("GenerateRefreshToken: %v", err)
+	}
+	passwordHash := func() string {
+		t.Helper()
+		var hash string
+		if err := db.NewSelect().Model((*tables.User)(nil)).Column("password_hash").Where("id = ?", user.Id).Scan(ctx, &hash); err != nil {
+			t.Fatalf("failed to read the password hash: %v", err)
+		}
+		return hash
+	}
+	original := passwordHash()
+	claims := &structs.AuthClaims{Sub: user.Id, Role: "user", Jti: uuid.New(), Exp: time.Now().Add(time.Hour)}
+
+	w, message := changePassword(t, ar, claims, "wrong horse battery", "a new password")
+	if w.Code != http.StatusBadRequest || message != "error.auth.invalidCurrentPassword" {
+		t.Fatalf("expected a wrong current password to be rejected, got %d %s", w.Code, message)
+	}
+	if passwordHash() != original {
+		t.Fatal("expected a rejected change to leave the password alone")
+	}
+
+	w, message = changePassword(t, ar, claims, "correct horse battery", "a new password")
+	if w.Code != http.StatusOK || message != "success.auth.passwordChanged" {
+		t.Fatalf("expected the password to be changed, got %d %s", w.Code, message)
+	}
+	if passwordHash() == original {
+		t.Fatal("expected the password hash to change")
+	}
+
+	// The other device is signed out and the access token used is revoked
+	jti, err := lib.RefreshTokenJti(otherDevice)
+	if err != nil {
+		t.Fatalf("RefreshTokenJti: %v", err)
+	}
+	if session, err := authService.GetLiveSession(context.Background(), jti); session != nil || !lib.IsNotFound(err) {
+		t.Fatalf("expected the other session to be revoked, got %+v (err %v)", session, err)
+	}
+	if blacklisted, err := cache.IsTokenBlacklisted(claims.Jti); err != nil || !blacklisted {
+		t.Fatalf("expected the old access token to be blacklisted (err %v)", err)
+	}
+
+	// This client continues in a new session
+	cookies := map[string]bool{}
+	for _, cookie := range w.Result().Cookies() {
+		cookies[cookie.Name] = cookie.Value != ""
+	}
+	if !cookies[lib.AccessCookieName] || !cookies[lib.RefreshCookieName] {
+		t.Fatalf("expected new auth cookies, got %v", w.Header().Values("Set-Cookie"))
+	}
+}
@@ -1,6 +1,7 @@
 package auth
 
 import (
+	"mamabloemetjes_server/lib"
 	"net/http"
 
 	"github.com/MonkyMars/gecho"
@@ -26,9 +27,9 @@ func (ar *AuthRoutesManager) HandleCheckVerification(w http.ResponseWriter, r *h
 	}
 
 	// Get user by ID
-	user, err := ar.authService.GetUserByID(userID)
+	user, err := ar.authService.GetUserByID(r.Context(), userID)
 	if err != nil {
-		ar.logger.Error("Failed to get user by ID", gecho.Field("error", err), gecho.Field("user_id", userID))
+		ar.logger.Error("Failed to get user by ID", gecho.Field("error", lib.GetDetailForLogging(err)), gecho.Field("user_id", userID))
 		// Don't reveal if user exists or not for security reasons
 		gecho.Success(w, gecho.WithData(map[string]interface{}{
 			"verified": false,
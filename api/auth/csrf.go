@@ -3,7 +3,6 @@ package auth
 import (
 	"mamabloemetjes_server/lib"
 	"net/http"
-	"time"
 
 	"github.com/MonkyMars/gecho"
 )
@@ -17,10 +16,10 @@ func (ar *AuthRoutesManager) HandleCSRF(w http.ResponseWriter, r *http.Request)
 		gecho.Field("referer", r.Header.Get("Referer")),
 	)
 
-	// Generate a new CSRF token
-	token, err := lib.GenerateRandomToken()
+	// Generate a new CSRF token for the caller's session and set it as a cookie
+	token, err := lib.IssueCSRFToken(lib.CSRFSession(r), w)
 	if err != nil {
-		ar.logger.Error("Failed to generate CSRF token", gecho.Field("error", err))
+		ar.logger.Error("Failed to generate CSRF token", gecho.Field("error", lib.GetDetailForLogging(err)))
 		gecho.InternalServerError(w,
 			gecho.WithMessage("error.csrf.failedToGenerate"),
 			gecho.Send(),
@@ -28,16 +27,18 @@ func (ar *AuthRoutesManager) HandleCSRF(w http.ResponseWriter, r *http.Request)
 		return
 	}
 
-	// Set CSRF cookie with 24 hour expiration
-	expiry := time.Now().Add(24 * time.Hour)
-	lib.SetCSRFCookie(token, expiry, w)
+	// A signed-in session only accepts the token last issued to it
+	if jti, ok := lib.CSRFSessionJti(r); ok {
+		if err := ar.authService.SetSessionCSRFToken(jti, token); err != nil {
+			gecho.InternalServerError(w,
+				gecho.WithMessage("error.csrf.failedToGenerate"),
+				gecho.Send(),
+			)
+			return
+		}
+	}
 
-	ar.logger.Info("CSRF token generated and cookie set",
-		gecho.Field("token_length", len(token)),
-		gecho.Field("token_preview", token[:min(10, len(token))]),
-		gecho.Field("expiry", expiry),
-		gecho.Field("cookie_header", w.Header().Get("Set-Cookie")),
-	)
+	ar.logger.Info("CSRF token generated and cookie set", gecho.Field("expiry", lib.CSRFTokenExpiry))
 
 	// Return the token in the response as well
 	gecho.Success(w,
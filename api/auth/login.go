@@ -1,8 +1,10 @@
 package auth
 
 import (
+	"mamabloemetjes_server/api/middleware"
 	"mamabloemetjes_server/lib"
 	"mamabloemetjes_server/structs"
+	"mamabloemetjes_server/structs/tables"
 	"net/http"
 
 	"github.com/MonkyMars/gecho"
@@ -12,7 +14,7 @@ func (ar *AuthRoutesManager) HandleLogin(w http.ResponseWriter, r *http.Request)
 	body, err := lib.ExtractAndValidateBody[structs.AuthRequest](r)
 	if err != nil {
 		ar.logger.Warn("Failed to extract and validate request body", gecho.Field("error", err))
-		gecho.BadRequest(w, gecho.WithMessage("error.auth.checkLoginInformation"), gecho.WithData(err), gecho.Send())
+		gecho.BadRequest(w, gecho.WithMessage("error.auth.checkLoginInformation"), gecho.WithData(lib.ClientErrorData(err)), gecho.Send())
 		return
 	}
 
@@ -29,28 +31,16 @@ func (ar *AuthRoutesManager) HandleLogin(w http.ResponseWriter, r *http.Request)
 		return
 	}
 
-	accessToken, err := ar.authService.GenerateAccessToken(user)
-	if err != nil {
-		ar.logger.Warn("Failed to generate access token", gecho.Field("error", err))
+	if err := ar.startSession(w, r, user); err != nil {
 		gecho.InternalServerError(w, gecho.WithMessage("error.auth.unableToCompleteLogin"), gecho.Send())
 		return
 	}
 
-	refreshToken, err := ar.authService.GenerateRefreshToken(user)
-	if err != nil {
-		ar.logger.Warn("Failed to generate refresh token", gecho.Field("error", err))
-		gecho.InternalServerError(w, gecho.WithMessage("error.auth.unableToCompleteLogin"), gecho.Send())
-		return
-	}
-
-	lib.SetCookie(lib.RefreshCookieName, refreshToken, ar.authService.GetRefreshTokenExpiration(), w)
-	lib.SetCookie(lib.AccessCookieName, accessToken, ar.authService.GetAccessTokenExpiration(), w)
-
 	// Send last login to db asynchronously
 	go func() {
 		err := ar.authService.UpdateLastLogin(user.Id)
 		if err != nil {
-			ar.logger.Error("Failed to update last login", gecho.Field("error", err), gecho.Field("userID", user.Id))
+			ar.logger.Error("Failed to update last login", gecho.Field("error", lib.GetDetailForLogging(err)), gecho.Field("userID", user.Id))
 		}
 	}()
 
@@ -63,3 +53,34 @@ func (ar *AuthRoutesManager) HandleLogin(w http.ResponseWriter, r *http.Request)
 		gecho.Send(),
 	)
 }
+
+// startSession signs user in on this client: a new token pair and session are issued as cookies
+func (ar *AuthRoutesManager) startSession(w http.ResponseWriter, r *http.Request, user *tables.User) error {
+	accessToken, err := ar.authService.GenerateAccessToken(user)
+	if err != nil {
+		ar.logger.Warn("Failed to generate access token", gecho.Field("error", err))
+		return err
+	}
+
+	refreshToken, err := ar.authService.GenerateRefreshToken(user, r.UserAgent(), middleware.ClientIP(r))
+	if err != nil {
+		ar.logger.Warn("Failed to generate refresh token", gecho.Field("error", err))
+		return err
+	}
+
+	lib.SetCookie(lib.RefreshCookieName, refreshToken, ar.authService.GetRefreshTokenExpiration(), w)
+	lib.SetCookie(lib.AccessCookieName, accessToken, ar.authService.GetAccessTokenExpiration(), w)
+
+	// Rotate the CSRF token: a token issued before is not valid for the new session.
+	// Its hash is stored on the new session, so only this session accepts it
+	csrfToken, err := lib.IssueCSRFToken(lib.UserCSRFSession(user.Id), w)
+	if err != nil {
+		ar.logger.Error("Failed to rotate CSRF token for new session", gecho.Field("error", lib.GetDetailForLogging(err)), gecho.Field("userID", user.Id))
+	} else if jti, err := lib.RefreshTokenJti(refreshToken); err != nil {
+		ar.logger.Error("Failed to read session of new refresh token", gecho.Field("error", err), gecho.Field("userID", user.Id))
+	} else if err := ar.authService.SetSessionCSRFToken(jti, csrfToken); err != nil {
+		ar.logger.Error("Failed to bind CSRF token to session", gecho.Field("error", lib.GetDetailForLogging(err)), gecho.Field("userID", user.Id))
+	}
+
+	return nil
+}
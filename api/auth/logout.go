@@ -5,44 +5,54 @@ import (
 	"net/http"
 
 	"github.com/MonkyMars/gecho"
+	"github.com/google/uuid"
 )
 
+// HandleLogout blacklists the access and refresh tokens, revokes the session and clears the auth cookies.
+// Tokens are read without checking their expiry and missing or invalid ones are skipped, so logout always
+// clears the cookies. It only fails when a token could not be blacklisted, after the cookies are cleared
 func (ar *AuthRoutesManager) HandleLogout(w http.ResponseWriter, r *http.Request) {
+	var userId uuid.UUID
+	blacklistFailed := false
 
-	accessToken, err := lib.GetCookieValue(lib.AccessCookieName, r)
-	if err != nil {
-		gecho.Success(w,
-			gecho.WithMessage("error.auth.noAccessTokenFound"),
-			gecho.Send(),
-		)
-		return
+	if accessToken, err := lib.GetCookieValue(lib.AccessCookieName, r); err == nil {
+		claims, err := lib.ParseTokenIgnoringExpiry(accessToken, ar.cfg.Auth.AccessTokenSecret)
+		if err != nil {
+			ar.logger.Warn("Failed to parse access token during logout", gecho.Field("error", lib.GetDetailForLogging(err)))
+		} else {
+			userId = claims.Sub
+			if err := ar.cacheService.BlacklistToken(claims.Jti, claims.Exp); err != nil {
+				ar.logger.Error("Failed to blacklist access token during logout", gecho.Field("error", lib.GetDetailForLogging(err)), gecho.Field("user_id", claims.Sub))
+				blacklistFailed = true
+			}
+		}
 	}
 
-	claims, err := lib.ParseToken(accessToken, true, ar.cfg.Auth.AccessTokenSecret)
-	if err != nil {
-		ar.logger.Error("Failed to parse access token during logout", gecho.Field("error", err))
-		gecho.Success(w,
-			gecho.WithMessage("error.auth.invalidAccessToken"),
-			gecho.Send(),
-		)
-		return
-	}
+	if refreshToken, err := lib.GetCookieValue(lib.RefreshCookieName, r); err == nil {
+		claims, err := lib.ParseTokenIgnoringExpiry(refreshToken, ar.cfg.Auth.RefreshTokenSecret)
+		if err != nil {
+			ar.logger.Warn("Failed to parse refresh token during logout", gecho.Field("error", lib.GetDetailForLogging(err)))
+		} else {
+			userId = claims.Sub
+			if err := ar.cacheService.BlacklistToken(claims.Jti, claims.Exp); err != nil {
+				ar.logger.Error("Failed to blacklist refresh token during logout", gecho.Field("error", lib.GetDetailForLogging(err)), gecho.Field("user_id", claims.Sub))
+				blacklistFailed = true
+			}
 
-	err = ar.cacheService.BlacklistToken(claims.Jti, claims.Exp)
-	if err != nil {
-		ar.logger.Error("Failed to blacklist access token during logout", gecho.Field("error", err))
-		gecho.InternalServerError(w,
-			gecho.WithMessage("error.auth.failedToLogout"),
-			gecho.Send(),
-		)
-		return
+			// Revoke the refresh token's session so it can no longer be used
+			if err := ar.authService.RevokeSession(claims.Jti); err != nil {
+				ar.logger.Warn("Failed to revoke session during logout", gecho.Field("error", lib.GetDetailForLogging(err)), gecho.Field("user_id", claims.Sub))
+			}
+		}
 	}
 
 	// Also clear user from cache
-	if err = ar.cacheService.DeleteUserFromCache(claims.Sub); err != nil {
-		ar.logger.Error("Failed to clear user cache during logout", gecho.Field("error", err), gecho.Field("user_id", claims.Sub))
-	} else {
-		ar.logger.Debug("User cache cleared during logout", gecho.Field("user_id", claims.Sub))
+	if userId != uuid.Nil {
+		if err := ar.cacheService.DeleteUserFromCache(userId); err != nil {
+			ar.logger.Error("Failed to clear user cache during logout", gecho.Field("error", lib.GetDetailForLogging(err)), gecho.Field("user_id", userId))
+		} else {
+			ar.logger.Debug("User cache cleared during logout", gecho.Field("user_id", userId))
+		}
 	}
 
 	// Clear access token cookie
@@ -50,7 +60,18 @@ func (ar *AuthRoutesManager) HandleLogout(w http.ResponseWriter, r *http.Request
 	// Clear refresh token cookie
 	lib.ClearCookie(lib.RefreshCookieName, w)
 
-	// Blacklist the token
+	// Rotate the CSRF token back to an anonymous one
+	if _, err := lib.IssueCSRFToken(lib.AnonymousCSRFSession, w); err != nil {
+		ar.logger.Error("Failed to rotate CSRF token after logout", gecho.Field("error", lib.GetDetailForLogging(err)))
+	}
+
+	if blacklistFailed {
+		gecho.InternalServerError(w,
+			gecho.WithMessage("error.auth.failedToLogout"),
+			gecho.Send(),
+		)
+		return
+	}
 
 	gecho.Success(w,
 		gecho.WithMessage("success.auth.loggedOut"),
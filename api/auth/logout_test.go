@@ -0,0 +1,206 @@
+package auth
+
+import (
+	"context"
+	"mamabloemetjes_server/api/middleware"
+	"mamabloemetjes_server/lib"
+	"mamabloemetjes_server/services"
+	"mamabloemetjes_server/structs"
+	"mamabloemetjes_server/structs/tables"
+	"mamabloemetjes_server/testutil"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+	"time"
+
+	"github.com/golang-jwt/jwt/v5"
+	"github.com/google/uuid"
+)
+
+// signToken signs claims with secret the way the auth service does, without checking the expiry
+func signToken(t *testing.T, secret string, claims *structs.AuthClaims) string {
+	t.Helper()
+	token, err := jwt.NewWithClaims(jwt.SigningMethodHS256, jwt.MapClaims{
+		"sub":   claims.Sub.String(),
+		"email": claims.Email,
+		"role":  claims.Role,
+		"iat":   claims.Iat.Unix(),
+		"exp":   claims.Exp.Unix(),
+		"jti":   claims.Jti.String(),
+		"ver":   claims.Ver,
+	}).SignedString([]byte(secret))
+	if err != nil {
+		t.Fatalf("failed to sign token: %v", err)
+	}
+	return token
+}
+
+// expiredClaims returns the claims of a token of userId that expired an hour ago
+func expiredClaims(userId uuid.UUID) *structs.AuthClaims {
+	return &structs.AuthClaims{Sub: userId, Email: "Jan Jansen", Role: "user", Iat: time.Now().Add(-2 * time.Hour), Exp: time.Now().Add(-time.Hour), Jti: uuid.New()}
+}
+
+// logout sends a logout request with the given access and refresh cookies, skipping empty ones
+func logout(ar *AuthRoutesManager, accessToken, refreshToken string) *httptest.ResponseRecorder {
+	r := httptest.NewRequest(http.MethodPost, "/auth/logout", nil)
+	if accessToken != "" {
+		r.AddCookie(&http.Cookie{Name: lib.AccessCookieName, Value: accessToken})
+	}
+	if refreshToken != "" {
+		r.AddCookie(&http.Cookie{Name: lib.RefreshCookieName, Value: refreshToken})
+	}
+	w := httptest.NewRecorder()
+	ar.HandleLogout(w, r)
+	return w
+}
+
+// assertLoggedOut checks that the logout succeeded and cleared both auth cookies
+func assertLoggedOut(t *testing.T, w *httptest.ResponseRecorder) {
+	t.Helper()
+	if w.Code != http.StatusOK {
+		t.Fatalf("expected status 200, got %d: %s", w.Code, w.Body.String())
+	}
+	cleared := map[string]bool{}
+	for _, cookie := range w.Result().Cookies() {
+		if cookie.Value == "" && cookie.MaxAge < 0 {
+			cleared[cookie.Name] = true
+		}
+	}
+	if !cleared[lib.AccessCookieName] || !cleared[lib.RefreshCookieName] {
+		t.Fatalf("expected both auth cookies to be cleared, got %v", w.Header().Values("Set-Cookie"))
+	}
+}
+
+// assertBlacklisted checks that every jti is on the token blacklist
+func assertBlacklisted(t *testing.T, cache *services.CacheService, jtis ...uuid.UUID) {
+	t.Helper()
+	for _, jti := range jtis {
+		if blacklisted, err := cache.IsTokenBlacklisted(jti); err != nil || !blacklisted {
+			t.Fatalf("expected token %s to be blacklisted (err %v)", jti, err)
+		}
+	}
+}
+
+func TestLogoutWithoutTokens(t *testing.T) {
+	// Nothing is revoked, so no services are reached
+	ar := &AuthRoutesManager{logger: testutil.Logger(), cfg: testutil.Config()}
+
+	assertLoggedOut(t, logout(ar, "", ""))
+	assertLoggedOut(t, logout(ar, "not-a-token", "not-a-token"))
+}
+
+func TestLogoutExpiredAccessToken(t *testing.T) {
+	testutil.Redis(t)
+	cfg := testutil.Config()
+	logger := testutil.Logger()
+	cache := services.NewCacheService(logger, cfg)
+	ar := &AuthRoutesManager{logger: logger, cacheService: cache, cfg: cfg}
+
+	claims := expiredClaims(uuid.New())
+	assertLoggedOut(t, logout(ar, signToken(t, cfg.Auth.AccessTokenSecret, claims), ""))
+	assertBlacklisted(t, cache, claims.Jti)
+}
+
+func TestLogoutRevokesTokens(t *testing.T) {
+	db := testutil.DB(t)
+	testutil.Redis(t)
+	cfg := testutil.Config()
+	logger := testutil.Logger()
+
+	cache := services.NewCacheService(logger, cfg)
+	authService := services.NewAuthService(cfg, logger, db, cache)
+	ar := &AuthRoutesManager{logger: logger, authService: authService, cacheService: cache, cfg: cfg}
+
+	// GetUserByID would cache the user in the background, racing the logout dropping it
+	user, err := authService.Register(&structs.RegisterRequest{Username: "Jan Jansen", Email: "jan@example.com", Password: "correct horse battery"})
+	if err != nil {
+		t.Fatalf("Register: %v", err)
+	}
+	accessToken, err := authService.GenerateAccessToken(user)
+	if err != nil {
+		t.Fatalf("GenerateAccessToken: %v", err)
+	}
+	refreshToken, err := authService.GenerateRefreshToken(user, "test-agent", "1.2.3.4")
+	if err != nil {
+		t.Fatalf("GenerateRefreshToken: %v", err)
+	}
+	if err := cache.SetUserInCache(&tables.User{Id: user.Id, Username: user.Username, Role: user.Role}); err != nil {
+		t.Fatalf("SetUserInCache: %v", err)
+	}
+
+	assertLoggedOut(t, logout(ar, accessToken, refreshToken))
+
+	accessClaims, err := lib.ParseTokenIgnoringExpiry(accessToken, cfg.Auth.AccessTokenSecret)
+	if err != nil {
+		t.Fatalf("failed to parse the access token: %v", err)
+	}
+	refreshJti, err := lib.RefreshTokenJti(refreshToken)
+	if err != nil {
+		t.Fatalf("RefreshTokenJti: %v", err)
+	}
+	assertBlacklisted(t, cache, accessClaims.Jti, refreshJti)
+	if session, err := authService.GetLiveSession(context.Background(), refreshJti); session != nil || !lib.IsNotFound(err) {
+		t.Fatalf("expected the session to be revoked, got %+v (err %v)", session, err)
+	}
+	if cached, err := cache.GetUserFromCache(user.Id); err != nil || cached != nil {
+		t.Fatalf("expected the cached user to be dropped, got %+v (err %v)", cached, err)
+	}
+
+	// Expired tokens without a live session are still blacklisted
+	expiredAccess, expiredRefresh := expiredClaims(user.Id), expiredClaims(user.Id)
+	assertLoggedOut(t, logout(ar, signToken(t, cfg.Auth.AccessTokenSecret, expiredAccess), signToken(t, cfg.Auth.RefreshTokenSecret, expiredRefresh)))
+	assertBlacklisted(t, cache, expiredAccess.Jti, expiredRefresh.Jti)
+}
+
+func TestLoggedOutAccessTokenIsRejected(t *testing.T) {
+	testutil.Redis(t)
+	cfg := testutil.Config()
+	logger := testutil.Logger()
+
+	// The user and its token version are cached, so no database is needed
+	cache := services.NewCacheService(logger, cfg)
+	authService := services.NewAuthService(cfg, logger, nil, cache)
+	mw := middleware.NewMiddleware(cfg, logger, authService, cache, nil)
+	ar := &AuthRoutesManager{logger: logger, authService: authService, cacheService: cache, cfg: cfg}
+
+	user := &tables.User{Id: uuid.New(), Username: "Jan Jansen", Email: "jan@example.com", Role: "user"}
+	if err := cache.SetUserInCache(user); err != nil {
+		t.Fatalf("SetUserInCache: %v", err)
+	}
+	if err := cache.CacheTokenVersionIfAbsent(user.Id, user.TokenVersion); err != nil {
+		t.Fatalf("CacheTokenVersionIfAbsent: %v", err)
+	}
+	accessToken, err := authService.GenerateAccessToken(user)
+	if err != nil {
+		t.Fatalf("GenerateAccessToken: %v", err)
+	}
+
+	authenticated := func(path string) *http.Request {
+		r := httptest.NewRequest(http.MethodGet, path, nil)
+		r.AddCookie(&http.Cookie{Name: lib.AccessCookieName, Value: accessToken})
+		return r
+	}
+	// statuses returns the status of a protected route and of /auth/me, and whether an optional auth route sees the user
+	statuses := func() (int, int, bool) {
+		protected := httptest.NewRecorder()
+		mw.UserAuthMiddleware(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+			w.WriteHeader(http.StatusOK)
+		})).ServeHTTP(protected, authenticated("/orders/me"))
+
+		me := httptest.NewRecorder()
+		ar.HandleMe(me, authenticated("/auth/me"))
+
+		_, optional, _ := mw.OptionalClaims(authenticated("/products"))
+		return protected.Code, me.Code, optional
+	}
+
+	if protected, me, optional := statuses(); protected != http.StatusOK || me != http.StatusOK || !optional {
+		t.Fatalf("expected the token to be accepted before logout, got %d, %d and optional %v", protected, me, optional)
+	}
+
+	assertLoggedOut(t, logout(ar, accessToken, ""))
+
+	if protected, me, optional := statuses(); protected != http.StatusUnauthorized || me != http.StatusUnauthorized || optional {
+		t.Fatalf("expected the logged out token to be rejected, got %d, %d and optional %v", protected, me, optional)
+	}
+}
@@ -51,15 +51,27 @@ func (rrm *AuthRoutesManager) RegisterRoutes(r chi.Router) {
 			r.Post("/login", rrm.HandleLogin)
 			r.Post("/logout", rrm.HandleLogout)
 			r.Post("/resend-verification", rrm.HandleResendVerification)
+			r.Post("/forgot-password", rrm.HandleForgotPassword)
+			r.Post("/reset-password", rrm.HandleResetPassword)
+			r.Post("/verify-email", rrm.HandleConfirmEmail) // Confirm from the frontend page; the GET link never consumes the token
 		})
 		r.Get("/me", rrm.HandleMe)
-		r.Get("/verify-email", rrm.HandleVerifyEmail)
+		r.Get("/verify-email", rrm.HandleVerifyEmail) // Emailed link, only checks the token and redirects to the confirm page
 		r.Get("/check-verification", rrm.HandleCheckVerification)
 
 		// Protected routes for user data
 		r.Group(func(r chi.Router) {
 			r.Use(rrm.mw.UserAuthMiddleware)
 			r.Get("/addresses", rrm.HandleGetAddresses)
+			r.Get("/profile", rrm.HandleGetProfile)
+		})
+
+		// Protected routes that change session state
+		r.Group(func(r chi.Router) {
+			r.Use(rrm.mw.UserAuthMiddleware)
+			r.Use(rrm.mw.CSRFMiddleware())
+			r.Post("/revoke-all", rrm.HandleRevokeAll)
+			r.Post("/change-password", rrm.HandleChangePassword)
 		})
 	})
 }
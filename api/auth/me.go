@@ -1,6 +1,7 @@
 package auth
 
 import (
+	"mamabloemetjes_server/api/middleware"
 	"mamabloemetjes_server/lib"
 	"net/http"
 
@@ -17,7 +18,7 @@ func (ar *AuthRoutesManager) HandleMe(w http.ResponseWriter, r *http.Request) {
 			return
 		}
 		// refresh automatically
-		authResponse, err := ar.authService.RefreshAccessToken(refreshToken)
+		authResponse, err := ar.authService.RefreshAccessToken(refreshToken, r.UserAgent(), middleware.ClientIP(r))
 		if err != nil {
 			ar.logger.Warn("Failed to refresh access token", gecho.Field("error", err))
 			gecho.Unauthorized(w, gecho.WithMessage("error.auth.sessionExpired"), gecho.Send())
@@ -37,14 +38,39 @@ func (ar *AuthRoutesManager) HandleMe(w http.ResponseWriter, r *http.Request) {
 	}
 
 	// Parse and validate access token if access token is still active
-	claims, err := lib.ParseToken(accessToken, true, ar.cfg.Auth.AccessTokenSecret)
+	claims, err := lib.ParseToken(accessToken, true, ar.cfg.Auth.AccessTokenSecret, ar.cfg.Auth.TokenLeeway, ar.cfg.Auth.TokenIssuer, ar.cfg.Auth.TokenAudience)
 	if err != nil {
-		ar.logger.Error("Failed to parse access token", gecho.Field("error", err))
+		ar.logger.Error("Failed to parse access token", gecho.Field("error", lib.GetDetailForLogging(err)))
 		gecho.Unauthorized(w, gecho.WithMessage("error.auth.invalidAccessToken"), gecho.Send())
 		return
 	}
 
-	user, err := ar.authService.GetUserByID(claims.Sub)
+	// A logged out access token stays valid until it expires, so it must not be on the blacklist
+	isRevoked, err := ar.cacheService.IsTokenBlacklisted(claims.Jti)
+	if err != nil {
+		ar.logger.Error("Failed to check if token is revoked", gecho.Field("error", err))
+		gecho.InternalServerError(w, gecho.WithMessage("error.internalServerError"), gecho.Send())
+		return
+	}
+	if isRevoked {
+		ar.logger.Warn("Revoked token used on /auth/me", gecho.Field("token_id", claims.Jti))
+		gecho.Unauthorized(w, gecho.WithMessage("error.auth.accessTokenRevoked"), gecho.Send())
+		return
+	}
+
+	user, err := ar.authService.GetUserByID(r.Context(), claims.Sub)
+	if err != nil || user == nil {
+		ar.logger.Warn("Failed to load user on /auth/me", gecho.Field("error", lib.GetDetailForLogging(err)), gecho.Field("user_id", claims.Sub))
+		gecho.Unauthorized(w, gecho.WithMessage("error.auth.invalidAccessToken"), gecho.Send())
+		return
+	}
+
+	// Tokens issued before the last password change carry an older token version
+	if version, err := ar.authService.CurrentTokenVersion(r.Context(), claims.Sub); err != nil || claims.Ver != version {
+		ar.logger.Warn("Access token with outdated or unverifiable token version used on /auth/me", gecho.Field("error", err), gecho.Field("user_id", claims.Sub))
+		gecho.Unauthorized(w, gecho.WithMessage("error.auth.accessTokenRevoked"), gecho.Send())
+		return
+	}
 
 	gecho.Success(w,
 		gecho.WithData(user),
@@ -0,0 +1,102 @@
+package auth
+
+import (
+	"context"
+	"errors"
+	"net/http"
+
+	"mamabloemetjes_server/database"
+	"mamabloemetjes_server/lib"
+	"mamabloemetjes_server/structs/tables"
+
+	"github.com/MonkyMars/gecho"
+)
+
+type ForgotPasswordRequest struct {
+	Email string `json:"email" validate:"required,email"`
+}
+
+type ResetPasswordRequest struct {
+	Token    string `json:"token" validate:"required"`
+	Password string `json:"password" validate:"required,min=8,max=100"`
+}
+
+// HandleForgotPassword emails a password reset link
+// The response is the same whether or not the email belongs to a user, or a link was sent recently,
+// and the email is sent in the background, so neither the answer nor its timing reveals an account
+func (ar *AuthRoutesManager) HandleForgotPassword(w http.ResponseWriter, r *http.Request) {
+	body, err := lib.ExtractAndValidateBody[ForgotPasswordRequest](r)
+	if err != nil {
+		ar.logger.Warn("Failed to extract and validate request body", gecho.Field("error", err))
+		gecho.BadRequest(w, gecho.WithMessage("error.invalidRequest"), gecho.WithData(lib.ClientErrorData(err)), gecho.Send())
+		return
+	}
+
+	email := lib.NormalizeEmail(body.Email)
+
+	go func() {
+		user, err := database.Query[tables.User](ar.authService.GetDB()).
+			Where("email", email).
+			First(context.Background())
+		if err != nil || user == nil {
+			// The address is not logged, it may belong to someone without an account
+			if err != nil && !lib.IsNotFound(lib.MapPgError(err)) {
+				ar.logger.Error("Failed to look up user for password reset", gecho.Field("error", lib.GetDetailForLogging(err)))
+				return
+			}
+			ar.logger.Info("Password reset requested for unknown email")
+			return
+		}
+
+		// At most one reset email per PasswordResetEmailWindow, so the endpoint cannot be used to flood a mailbox
+		claimed, err := ar.cacheService.ClaimPasswordResetEmail(user.Id)
+		if err != nil {
+			// Fail open: a missing reset email is worse than a duplicate one
+			ar.logger.Warn("Failed to claim password reset email, sending anyway", gecho.Field("error", err), gecho.Field("user_id", user.Id))
+		} else if !claimed {
+			ar.logger.Info("Password reset email already sent recently, skipping", gecho.Field("user_id", user.Id))
+			return
+		}
+
+		if _, err := ar.emailService.SendPasswordResetEmail(user); err != nil {
+			ar.logger.Error("Failed to queue password reset email", gecho.Field("error", lib.GetDetailForLogging(err)), gecho.Field("user_id", user.Id))
+			return
+		}
+		ar.logger.Info("Password reset email queued", gecho.Field("user_id", user.Id))
+	}()
+
+	gecho.Success(w, gecho.WithMessage("success.auth.passwordResetEmailSent"), gecho.Send())
+}
+
+// HandleResetPassword sets a new password with a reset token from HandleForgotPassword
+// Every session of the user is revoked, so the caller's auth cookies are cleared as well
+func (ar *AuthRoutesManager) HandleResetPassword(w http.ResponseWriter, r *http.Request) {
+	body, err := lib.ExtractAndValidateBody[ResetPasswordRequest](r)
+	if err != nil {
+		ar.logger.Warn("Failed to extract and validate request body", gecho.Field("error", err))
+		gecho.BadRequest(w, gecho.WithMessage("error.invalidRequest"), gecho.WithData(lib.ClientErrorData(err)), gecho.Send())
+		return
+	}
+
+	if err := ar.authService.ResetPassword(r.Context(), body.Token, body.Password); err != nil {
+		switch {
+		case errors.Is(err, lib.ErrExpiredToken):
+			gecho.BadRequest(w, gecho.WithMessage("error.auth.expiredResetToken"), gecho.Send())
+		case errors.Is(err, lib.ErrInvalidToken):
+			gecho.BadRequest(w, gecho.WithMessage("error.auth.invalidResetToken"), gecho.Send())
+		default:
+			ar.logger.Error("Failed to reset password", gecho.Field("error", lib.GetDetailForLogging(err)))
+			lib.RespondServerError(w, err, "error.auth.failedToResetPassword")
+		}
+		return
+	}
+
+	lib.ClearCookie(lib.AccessCookieName, w)
+	lib.ClearCookie(lib.RefreshCookieName, w)
+	if _, err := lib.IssueCSRFToken(lib.AnonymousCSRFSession, w); err != nil {
+		ar.logger.Error("Failed to rotate CSRF token after password reset", gecho.Field("error", lib.GetDetailForLogging(err)))
+	}
+
+	// The user needs to log in with the new password
+	gecho.Success(w, gecho.WithMessage("success.auth.passwordReset"), gecho.Send())
+}
@@ -0,0 +1,109 @@
+package auth
+
+import (
+	"context"
+	"encoding/json"
+	"mamabloemetjes_server/lib"
+	"mamabloemetjes_server/services"
+	"mamabloemetjes_server/structs"
+	"mamabloemetjes_server/structs/tables"
+	"mamabloemetjes_server/testutil"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+	"time"
+)
+
+// resetPassword posts token and password to the reset handler, returning the status and message
+func resetPassword(t *testing.T, ar *AuthRoutesManager, token, password string) (int, string) {
+	t.Helper()
+	body, err := json.Marshal(ResetPasswordRequest{Token: token, Password: password})
+	if err != nil {
+		t.Fatalf("failed to encode the request: %v", err)
+	}
+	w := httptest.NewRecorder()
+	ar.HandleResetPassword(w, httptest.NewRequest(http.MethodPost, "/auth/reset-password", strings.NewReader(string(body))))
+
+	var response struct {
+		Message string `json:"message"`
+	}
+	if err := json.Unmarshal(w.Body.Bytes(), &response); err != nil {
+		t.Fatalf("failed to decode the response: %v", err)
+	}
+	return w.Code, response.Message
+}
+
+func TestResetPasswordTokens(t *testing.T) {
+	db := testutil.DB(t)
+	testutil.Redis(t)
+	cfg := testutil.Config()
+	logger := testutil.Logger()
+	ctx := context.Background()
+
+	cache := services.NewCacheService(logger, cfg)
+	authService := services.NewAuthService(cfg, logger, db, cache)
+	ar := &AuthRoutesManager{logger: logger, authService: authService, cacheService: cache, cfg: cfg}
+
+	user, err := authService.Register(&structs.RegisterRequest{Username: "Jan Jansen", Email: "jan@example.com", Password: "correct horse battery"})
+	if err != nil {
+		t.Fatalf("Register: %v", err)
+	}
+	refreshToken, err := authService.GenerateRefreshToken(user, "test-agent", "1.2.3.4")
+	if err != nil {
+		t.Fatalf("GenerateRefreshToken: %v", err)
+	}
+
+	// seedReset stores a reset token for the user expiring at expiresAt
+	seedReset := func(token string, expiresAt time.Time) {
+		t.Helper()
+		reset := &tables.PasswordReset{UserId: user.Id, TokenHash: lib.HashToken(token), ExpiresAt: expiresAt, CreatedAt: time.Now()}
+		if _, err := db.NewInsert().Model(reset).Exec(ctx); err != nil {
+			t.Fatalf("failed to seed the reset token: %v", err)
+		}
+	}
+	passwordHash := func() string {
+		t.Helper()
+		var hash string
+		if err := db.NewSelect().Model((*tables.User)(nil)).Column("password_hash").Where("id = ?", user.Id).Scan(ctx, &hash); err != nil {
+			t.Fatalf("failed to read the password hash: %v", err)
+		}
+		return hash
+	}
+	original := passwordHash()
+
+	seedReset("expired-reset-token", time.Now().Add(-time.Minute))
+	if status, message := resetPassword(t, ar, "expired-reset-token", "a new password"); status != http.StatusBadRequest || message != "error.auth.expiredResetToken" {
+		t.Fatalf("expected an expired token to be rejected, got %d %s", status, message)
+	}
+	if status, message := resetPassword(t, ar, "unknown-reset-token", "a new password"); status != http.StatusBadRequest || message != "error.auth.invalidResetToken" {
+		t.Fatalf("expected an unknown token to be rejected, got %d %s", status, message)
+	}
+	if passwordHash() != original {
+		t.Fatal("expected rejected tokens to leave the password alone")
+	}
+
+	seedReset("valid-reset-token", time.Now().Add(time.Hour))
+	if status, message := resetPassword(t, ar, "valid-reset-token", "a new password"); status != http.StatusOK {
+		t.Fatalf("expected the reset to succeed, got %d %s", status, message)
+	}
+	changed := passwordHash()
+	if changed == original {
+		t.Fatal("expected the password hash to change")
+	}
+	jti, err := lib.RefreshTokenJti(refreshToken)
+	if err != nil {
+		t.Fatalf("RefreshTokenJti: %v", err)
+	}
+	if session, err := authService.GetLiveSession(context.Background(), jti); session != nil || !lib.IsNotFound(err) {
+		t.Fatalf("expected the sessions to be revoked, got %+v (err %v)", session, err)
+	}
+
+	// The token is single use
+	if status, message := resetPassword(t, ar, "valid-reset-token", "another password"); status != http.StatusBadRequest || message != "error.auth.invalidResetToken" {
+		t.Fatalf("expected a used token to be rejected, got %d %s", status, message)
+	}
+	if passwordHash() != changed {
+		t.Fatal("expected the reused token to leave the password alone")
+	}
+}
@@ -0,0 +1,70 @@
+package auth
+
+import (
+	"mamabloemetjes_server/lib"
+	"mamabloemetjes_server/structs/tables"
+	"net/http"
+
+	"github.com/MonkyMars/gecho"
+)
+
+func (ar *AuthRoutesManager) HandleGetProfile(w http.ResponseWriter, r *http.Request) {
+	// Get user ID from claims (set by UserAuthMiddleware)
+	claims, err := lib.ExtractClaims(r)
+	if err != nil {
+		ar.logger.Error("Failed to extract claims", gecho.Field("error", lib.GetDetailForLogging(err)))
+		gecho.Unauthorized(w, gecho.WithMessage("error.auth.unauthorized"), gecho.Send())
+		return
+	}
+
+	// User is served from cache when possible; the password hash is never serialized
+	user, err := ar.authService.GetUserByID(r.Context(), claims.Sub)
+	if err != nil {
+		if lib.IsNotFound(err) {
+			gecho.NotFound(w, gecho.WithMessage("error.user.notFound"), gecho.Send())
+			return
+		}
+		ar.logger.Error("Failed to get user information",
+			gecho.Field("error", lib.GetDetailForLogging(err)),
+			gecho.Field("user_id", claims.Sub),
+		)
+		lib.RespondServerError(w, err, "error.user.fetchFailed")
+		return
+	}
+
+	// Addresses are ordered newest first; the most recently used one is the default
+	addresses, err := ar.orderService.GetUserAddresses(r.Context(), claims.Sub)
+	if err != nil {
+		ar.logger.Error("Failed to get user addresses",
+			gecho.Field("error", lib.GetDetailForLogging(err)),
+			gecho.Field("user_id", claims.Sub),
+		)
+		lib.RespondServerError(w, err, "error.addresses.fetchFailed")
+		return
+	}
+
+	var defaultAddress *tables.Address
+	if len(addresses) > 0 {
+		defaultAddress = addresses[0]
+	}
+
+	summary, err := ar.orderService.GetUserOrderSummary(r.Context(), claims.Sub)
+	if err != nil {
+		ar.logger.Error("Failed to get user order summary",
+			gecho.Field("error", lib.GetDetailForLogging(err)),
+			gecho.Field("user_id", claims.Sub),
+		)
+		lib.RespondServerError(w, err, "error.order.fetchingOrders")
+		return
+	}
+
+	gecho.Success(w,
+		gecho.WithData(map[string]interface{}{
+			"user":            user,
+			"default_address": defaultAddress,
+			"orders":          summary,
+		}),
+		gecho.WithMessage("success.profile.fetched"),
+		gecho.Send(),
+	)
+}
@@ -0,0 +1,108 @@
+package auth
+
+import (
+	"context"
+	"encoding/json"
+	"mamabloemetjes_server/lib"
+	"mamabloemetjes_server/services"
+	"mamabloemetjes_server/structs"
+	"mamabloemetjes_server/structs/tables"
+	"mamabloemetjes_server/testutil"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+
+	"github.com/google/uuid"
+)
+
+func TestGetProfileComposesUserAddressAndOrders(t *testing.T) {
+	db := testutil.DB(t)
+	testutil.Redis(t)
+	cfg := testutil.Config()
+	logger := testutil.Logger()
+	ctx := context.Background()
+
+	cache := services.NewCacheService(logger, cfg)
+	authService := services.NewAuthService(cfg, logger, db, cache)
+	productService := services.NewProductService(logger, cfg, db, cache)
+	orderService := services.NewOrderService(logger, cfg, db, productService, services.NewEmailService(logger, cfg, db, authService))
+	ar := &AuthRoutesManager{logger: logger, authService: authService, cacheService: cache, orderService: orderService, cfg: cfg}
+
+	user, err := authService.Register(&structs.RegisterRequest{Username: "Jan Jansen", Email: "jan@example.com", Password: "correct horse battery"})
+	if err != nil {
+		t.Fatalf("Register: %v", err)
+	}
+
+	product := &tables.Product{
+		ID:          uuid.New(),
+		Name:        "Rozenboeket",
+		SKU:         "SKU-PROFILE",
+		Price:       2500,
+		Subtotal:    2500,
+		Currency:    "EUR",
+		Description: "A bouquet of red roses",
+		IsActive:    true,
+		MadeToOrder: true,
+	}
+	if _, err := db.NewInsert().Model(product).Exec(ctx); err != nil {
+		t.Fatalf("failed to seed product: %v", err)
+	}
+	_, err = orderService.CreateOrderFromRequest(ctx, &structs.OrderRequest{
+		Name:       "Jan Jansen",
+		Email:      "jan@example.com",
+		Phone:      "0612345678",
+		Street:     "Dorpsstraat",
+		HouseNo:    "1",
+		PostalCode: "1234 AB",
+		City:       "Utrecht",
+		Country:    "NL",
+		Products:   map[string]int{product.ID.String(): 2},
+	}, &user.Id)
+	if err != nil {
+		t.Fatalf("CreateOrderFromRequest: %v", err)
+	}
+
+	token, err := authService.GenerateAccessToken(user)
+	if err != nil {
+		t.Fatalf("GenerateAccessToken: %v", err)
+	}
+	r := httptest.NewRequest(http.MethodGet, "/auth/profile", nil)
+	r.AddCookie(&http.Cookie{Name: lib.AccessCookieName, Value: token})
+	w := httptest.NewRecorder()
+	ar.HandleGetProfile(w, r)
+
+	if w.Code != http.StatusOK {
+		t.Fatalf("expected status 200, got %d: %s", w.Code, w.Body.String())
+	}
+	// Register clears the hash on the returned user, so read the stored one
+	var passwordHash string
+	if err := db.NewSelect().Model((*tables.User)(nil)).Column("password_hash").Where("id = ?", user.Id).Scan(ctx, &passwordHash); err != nil {
+		t.Fatalf("failed to read the password hash: %v", err)
+	}
+	body := w.Body.String()
+	if strings.Contains(body, "password") || strings.Contains(body, passwordHash) {
+		t.Fatalf("expected the password hash to stay out of the profile, got %s", body)
+	}
+
+	var response struct {
+		Data struct {
+			User           tables.User               `json:"user"`
+			DefaultAddress *tables.Address           `json:"default_address"`
+			Orders         services.UserOrderSummary `json:"orders"`
+		} `json:"data"`
+	}
+	if err := json.Unmarshal(w.Body.Bytes(), &response); err != nil {
+		t.Fatalf("failed to decode the profile: %v", err)
+	}
+	profile := response.Data
+	if profile.User.Id != user.Id || profile.User.Email != "jan@example.com" || profile.User.Username != "Jan Jansen" {
+		t.Fatalf("expected the user fields of %s, got %+v", user.Id, profile.User)
+	}
+	if profile.DefaultAddress == nil || profile.DefaultAddress.Street != "Dorpsstraat" || profile.DefaultAddress.City != "Utrecht" {
+		t.Fatalf("expected the order address as the default address, got %+v", profile.DefaultAddress)
+	}
+	if profile.Orders.OrderCount != 1 || profile.Orders.Totals["EUR"] != 5000 {
+		t.Fatalf("expected one order worth 5000 cents, got %+v", profile.Orders)
+	}
+}
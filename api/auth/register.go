@@ -12,7 +12,7 @@ func (ar *AuthRoutesManager) HandleRegister(w http.ResponseWriter, r *http.Reque
 	body, err := lib.ExtractAndValidateBody[structs.RegisterRequest](r)
 	if err != nil {
 		ar.logger.Warn("Failed to extract and validate request body", gecho.Field("error", err))
-		gecho.BadRequest(w, gecho.WithMessage("error.auth.checkRegistrationInformation"), gecho.WithData(err), gecho.Send())
+		gecho.BadRequest(w, gecho.WithMessage("error.auth.checkRegistrationInformation"), gecho.WithData(lib.ClientErrorData(err)), gecho.Send())
 		return
 	}
 
@@ -21,14 +21,21 @@ func (ar *AuthRoutesManager) HandleRegister(w http.ResponseWriter, r *http.Reque
 		// Get user-friendly message from error
 		userMessage := lib.GetUserMessage(err)
 
-		// Unique violations return 409 Conflict (already logged as warn in service)
+		// Unique violations return 409 Conflict (already logged as warn in service).
+		// There is no pre-check, so a concurrent registration with the same email or username lands here too
 		if lib.IsUniqueViolation(err) {
 			gecho.Conflict(w, gecho.WithMessage(userMessage), gecho.Send())
 			return
 		}
 
 		// Other database errors return 500 (already logged as error in service)
-		gecho.InternalServerError(w, gecho.WithMessage(userMessage), gecho.Send())
+		lib.RespondServerError(w, err, userMessage)
+		return
+	}
+
+	if user == nil {
+		ar.logger.Error("Register returned no user and no error")
+		gecho.InternalServerError(w, gecho.WithMessage("error.internalServerError"), gecho.Send())
 		return
 	}
 
@@ -36,13 +43,23 @@ func (ar *AuthRoutesManager) HandleRegister(w http.ResponseWriter, r *http.Reque
 	user.PasswordHash = ""
 
 	go func() {
-		// Send verification email
+		// A retried or double-submitted registration must not send a second email and token
+		claimed, err := ar.cacheService.ClaimVerificationEmail(user.Id)
+		if err != nil {
+			// Fail open: a missing verification email is worse than a duplicate one
+			ar.logger.Warn("Failed to claim verification email, sending anyway", gecho.Field("error", err), gecho.Field("user_id", user.Id))
+		} else if !claimed {
+			ar.logger.Info("Verification email already sent recently, skipping", gecho.Field("user_id", user.Id))
+			return
+		}
+
+		// Queue verification email
 		result, err := ar.emailService.SendVerificationEmail(user)
 		if err != nil {
-			ar.logger.Error("Failed to send verification email", gecho.Field("error", err), gecho.Field("user_id", user.Id))
+			ar.logger.Error("Failed to queue verification email", gecho.Field("error", lib.GetDetailForLogging(err)), gecho.Field("user_id", user.Id))
 			return
 		}
-		ar.logger.Debug("Verification email sent", gecho.Field("email_verification_id", result.Id), gecho.Field("user_id", user.Id))
+		ar.logger.Debug("Verification email queued", gecho.Field("email_verification_id", result.Id), gecho.Field("user_id", user.Id))
 	}()
 
 	gecho.Success(w,
@@ -0,0 +1,100 @@
+package auth
+
+import (
+	"context"
+	"encoding/json"
+	"mamabloemetjes_server/services"
+	"mamabloemetjes_server/structs/tables"
+	"mamabloemetjes_server/testutil"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"sync"
+	"testing"
+	"time"
+)
+
+func TestConcurrentRegistrationsSendOneVerificationEmail(t *testing.T) {
+	db := testutil.DB(t)
+	testutil.Redis(t)
+	cfg := testutil.Config()
+	logger := testutil.Logger()
+	ctx := context.Background()
+
+	cache := services.NewCacheService(logger, cfg)
+	authService := services.NewAuthService(cfg, logger, db, cache)
+	emailService := services.NewEmailService(logger, cfg, db, authService)
+	ar := &AuthRoutesManager{logger: logger, authService: authService, cacheService: cache, emailService: emailService, cfg: cfg}
+
+	// A double submit of the same registration
+	const body = `{"username":"Jan Jansen","email":"jan@example.com","password":"correct horse battery"}`
+	var wg sync.WaitGroup
+	codes := make([]int, 2)
+	for i := range codes {
+		wg.Go(func() {
+			w := httptest.NewRecorder()
+			ar.HandleRegister(w, httptest.NewRequest(http.MethodPost, "/auth/register", strings.NewReader(body)))
+			codes[i] = w.Code
+		})
+	}
+	wg.Wait()
+
+	if !(codes[0] == http.StatusOK && codes[1] == http.StatusConflict) && !(codes[0] == http.StatusConflict && codes[1] == http.StatusOK) {
+		t.Fatalf("expected one registration and one conflict, got %v", codes)
+	}
+
+	// The verification email is sent in the background, each one stores a token
+	countTokens := func() int {
+		count, err := db.NewSelect().Model((*tables.EmailVerification)(nil)).
+			Join("JOIN users AS u ON u.id = ev.user_id").
+			Where("u.email = ?", "jan@example.com").
+			Count(ctx)
+		if err != nil {
+			t.Fatalf("failed to count verification tokens: %v", err)
+		}
+		return count
+	}
+	deadline := time.Now().Add(5 * time.Second)
+	for countTokens() == 0 && time.Now().Before(deadline) {
+		time.Sleep(20 * time.Millisecond)
+	}
+	time.Sleep(200 * time.Millisecond)
+	if count := countTokens(); count != 1 {
+		t.Fatalf("expected one verification email, got %d", count)
+	}
+}
+
+func TestDuplicateRegistrationReturnsConflict(t *testing.T) {
+	db := testutil.DB(t)
+	testutil.Redis(t)
+	cfg := testutil.Config()
+	logger := testutil.Logger()
+
+	cache := services.NewCacheService(logger, cfg)
+	authService := services.NewAuthService(cfg, logger, db, cache)
+	ar := &AuthRoutesManager{logger: logger, authService: authService, cacheService: cache, emailService: services.NewEmailService(logger, cfg, db, authService), cfg: cfg}
+
+	register := func(body string) *httptest.ResponseRecorder {
+		w := httptest.NewRecorder()
+		ar.HandleRegister(w, httptest.NewRequest(http.MethodPost, "/auth/register", strings.NewReader(body)))
+		return w
+	}
+	if w := register(`{"username":"Jan Jansen","email":"jan@example.com","password":"correct horse battery"}`); w.Code != http.StatusOK {
+		t.Fatalf("expected the first registration to succeed, got %d: %s", w.Code, w.Body.String())
+	}
+
+	// The email is normalized before the insert, so another case still hits the unique constraint
+	w := register(`{"username":"Jan de Vries","email":"Jan@Example.com","password":"correct horse battery"}`)
+	if w.Code != http.StatusConflict {
+		t.Fatalf("expected status 409, got %d: %s", w.Code, w.Body.String())
+	}
+	var response struct {
+		Message string `json:"message"`
+	}
+	if err := json.Unmarshal(w.Body.Bytes(), &response); err != nil {
+		t.Fatalf("failed to decode the response: %v", err)
+	}
+	if response.Message != "error.uniqueViolation.email" {
+		t.Fatalf("expected the friendly email message, got %q", response.Message)
+	}
+}
@@ -7,6 +7,7 @@ import (
 
 	"mamabloemetjes_server/database"
 	"mamabloemetjes_server/lib"
+	"mamabloemetjes_server/services"
 	"mamabloemetjes_server/structs/tables"
 
 	"github.com/MonkyMars/gecho"
@@ -21,16 +22,18 @@ func (ar *AuthRoutesManager) HandleResendVerification(w http.ResponseWriter, r *
 	body, err := lib.ExtractAndValidateBody[ResendVerificationRequest](r)
 	if err != nil {
 		ar.logger.Warn("Failed to extract and validate request body", gecho.Field("error", err))
-		gecho.BadRequest(w, gecho.WithMessage("error.invalidRequest"), gecho.WithData(err), gecho.Send())
+		gecho.BadRequest(w, gecho.WithMessage("error.invalidRequest"), gecho.WithData(lib.ClientErrorData(err)), gecho.Send())
 		return
 	}
 
+	body.Email = lib.NormalizeEmail(body.Email)
+
 	// Find the user by email
 	user, err := database.Query[tables.User](ar.authService.GetDB()).
 		Where("email", body.Email).
 		First(context.Background())
 	if err != nil {
-		ar.logger.Error("Failed to find user", gecho.Field("error", err), gecho.Field("email", body.Email))
+		ar.logger.Error("Failed to find user", gecho.Field("error", lib.GetDetailForLogging(err)), gecho.Field("email", body.Email))
 		// Don't reveal if user exists or not for security reasons
 		gecho.Success(w, gecho.WithMessage("success.auth.verificationEmailSent"), gecho.Send())
 		return
@@ -50,7 +53,7 @@ func (ar *AuthRoutesManager) HandleResendVerification(w http.ResponseWriter, r *
 		return
 	}
 
-	// Check for rate limiting - prevent spam (max 1 email per 2 minutes)
+	// Check for rate limiting - prevent spam (max 1 email per VerificationEmailWindow)
 	recentVerification, err := database.Query[tables.EmailVerification](ar.authService.GetDB()).
 		Where("user_id", user.Id).
 		OrderBy("created_at", "DESC").
@@ -58,14 +61,14 @@ func (ar *AuthRoutesManager) HandleResendVerification(w http.ResponseWriter, r *
 
 	if err == nil && recentVerification != nil {
 		timeSinceLastEmail := time.Since(recentVerification.CreatedAt)
-		if timeSinceLastEmail < 2*time.Minute {
+		if timeSinceLastEmail < services.VerificationEmailWindow {
 			ar.logger.Warn("Rate limit exceeded for verification email",
 				gecho.Field("user_id", user.Id),
 				gecho.Field("time_since_last", timeSinceLastEmail))
 			gecho.TooManyRequests(w,
 				gecho.WithMessage("error.rateLimitExceeded"),
 				gecho.WithData(map[string]interface{}{
-					"retry_after_seconds": int((2*time.Minute - timeSinceLastEmail).Seconds()),
+					"retry_after_seconds": int((services.VerificationEmailWindow - timeSinceLastEmail).Seconds()),
 				}),
 				gecho.Send())
 			return
@@ -84,7 +87,7 @@ func (ar *AuthRoutesManager) HandleResendVerification(w http.ResponseWriter, r *
 	// Send new verification email
 	_, err = ar.emailService.SendVerificationEmail(user)
 	if err != nil {
-		ar.logger.Error("Failed to send verification email", gecho.Field("error", err), gecho.Field("user_id", user.Id))
+		ar.logger.Error("Failed to send verification email", gecho.Field("error", lib.GetDetailForLogging(err)), gecho.Field("user_id", user.Id))
 		gecho.InternalServerError(w, gecho.WithMessage("error.failedToSendEmail"), gecho.Send())
 		return
 	}
@@ -0,0 +1,52 @@
+package auth
+
+import (
+	"mamabloemetjes_server/lib"
+	"net/http"
+
+	"github.com/MonkyMars/gecho"
+)
+
+// HandleRevokeAll signs the user out everywhere: all of their sessions are revoked and their token version
+// is bumped, so every outstanding refresh and access token is rejected
+func (ar *AuthRoutesManager) HandleRevokeAll(w http.ResponseWriter, r *http.Request) {
+	// Get user ID from claims (set by UserAuthMiddleware)
+	claims, err := lib.ExtractClaims(r)
+	if err != nil {
+		ar.logger.Error("Failed to extract claims", gecho.Field("error", lib.GetDetailForLogging(err)))
+		gecho.Unauthorized(w, gecho.WithMessage("error.auth.unauthorized"), gecho.Send())
+		return
+	}
+
+	revoked, err := ar.authService.RevokeAllSessions(claims.Sub)
+	if err != nil {
+		ar.logger.Error("Failed to revoke all sessions",
+			gecho.Field("error", lib.GetDetailForLogging(err)),
+			gecho.Field("user_id", claims.Sub),
+		)
+		lib.RespondServerError(w, err, "error.auth.failedToRevokeSessions")
+		return
+	}
+
+	// The bumped token version rejects every access token, the one used for this request is blacklisted as well, like on logout
+	if err := ar.cacheService.BlacklistToken(claims.Jti, claims.Exp); err != nil {
+		ar.logger.Warn("Failed to blacklist access token after revoking sessions",
+			gecho.Field("error", lib.GetDetailForLogging(err)),
+			gecho.Field("user_id", claims.Sub),
+		)
+	}
+
+	lib.ClearCookie(lib.AccessCookieName, w)
+	lib.ClearCookie(lib.RefreshCookieName, w)
+
+	// Rotate the CSRF token back to an anonymous one
+	if _, err := lib.IssueCSRFToken(lib.AnonymousCSRFSession, w); err != nil {
+		ar.logger.Error("Failed to rotate CSRF token after revoking sessions", gecho.Field("error", lib.GetDetailForLogging(err)))
+	}
+
+	gecho.Success(w,
+		gecho.WithMessage("success.auth.sessionsRevoked"),
+		gecho.WithData(map[string]int{"revoked": revoked}),
+		gecho.Send(),
+	)
+}
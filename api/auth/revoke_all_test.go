@@ -0,0 +1,67 @@
+package auth
+
+import (
+	"mamabloemetjes_server/api/middleware"
+	"mamabloemetjes_server/lib"
+	"mamabloemetjes_server/services"
+	"mamabloemetjes_server/structs"
+	"mamabloemetjes_server/testutil"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+)
+
+func TestRevokeAllRejectsOtherAccessTokens(t *testing.T) {
+	db := testutil.DB(t)
+	testutil.Redis(t)
+	cfg := testutil.Config()
+	logger := testutil.Logger()
+
+	cache := services.NewCacheService(logger, cfg)
+	authService := services.NewAuthService(cfg, logger, db, cache)
+	ar := &AuthRoutesManager{logger: logger, authService: authService, cacheService: cache, cfg: cfg}
+	mw := middleware.NewMiddleware(cfg, logger, authService, cache, nil)
+
+	user, err := authService.Register(&structs.RegisterRequest{Username: "Jan Jansen", Email: "jan@example.com", Password: "correct horse battery"})
+	if err != nil {
+		t.Fatalf("Register: %v", err)
+	}
+	// Signed in on this device and on another one
+	var tokens []string
+	for range 2 {
+		token, err := authService.GenerateAccessToken(user)
+		if err != nil {
+			t.Fatalf("GenerateAccessToken: %v", err)
+		}
+		tokens = append(tokens, token)
+	}
+
+	// authenticate reports the status of a request with accessToken through the auth middleware
+	authenticate := func(accessToken string) int {
+		handler := mw.UserAuthMiddleware(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+			w.WriteHeader(http.StatusOK)
+		}))
+		r := httptest.NewRequest(http.MethodGet, "/auth/me", nil)
+		r.AddCookie(&http.Cookie{Name: lib.AccessCookieName, Value: accessToken})
+		w := httptest.NewRecorder()
+		handler.ServeHTTP(w, r)
+		return w.Code
+	}
+	if status := authenticate(tokens[1]); status != http.StatusOK {
+		t.Fatalf("expected the other device's token to work before revoking, got %d", status)
+	}
+
+	r := httptest.NewRequest(http.MethodPost, "/auth/revoke-all", nil)
+	r.AddCookie(&http.Cookie{Name: lib.AccessCookieName, Value: tokens[0]})
+	w := httptest.NewRecorder()
+	ar.HandleRevokeAll(w, r)
+	if w.Code != http.StatusOK {
+		t.Fatalf("expected status 200, got %d: %s", w.Code, w.Body.String())
+	}
+
+	for i, token := range tokens {
+		if status := authenticate(token); status != http.StatusUnauthorized {
+			t.Fatalf("expected access token %d to be rejected after revoking all sessions, got %d", i+1, status)
+		}
+	}
+}
@@ -2,13 +2,22 @@ package auth
 
 import (
 	"fmt"
+	"mamabloemetjes_server/lib"
 	"net/http"
+	"net/url"
 
 	"github.com/MonkyMars/gecho"
 	"github.com/google/uuid"
 )
 
-// HandleVerifyEmail handles email verification requests and redirects to the frontend.
+type VerifyEmailRequest struct {
+	Token  string    `json:"token" validate:"required"`
+	UserID uuid.UUID `json:"user_id" validate:"required"`
+}
+
+// HandleVerifyEmail handles the verification link from the email and redirects to the frontend.
+// It only checks the token: link scanners in email clients follow GET links, so a GET must not consume it.
+// A valid link lands on the frontend confirm page, which POSTs the token to HandleConfirmEmail
 func (ar *AuthRoutesManager) HandleVerifyEmail(w http.ResponseWriter, r *http.Request) {
 	// Get the token from the query parameters and user id
 	params := r.URL.Query()
@@ -28,22 +37,47 @@ func (ar *AuthRoutesManager) HandleVerifyEmail(w http.ResponseWriter, r *http.Re
 		return
 	}
 
-	// Verify the email
-	err = ar.authService.VerifyEmail(userUUID, token)
-	if err != nil {
-		ar.logger.Warn("Email verification failed", gecho.Field("error", err), gecho.Field("user_id", userID))
+	// Check the token without consuming it
+	if err := ar.authService.CheckEmailVerification(userUUID, token); err != nil {
+		ar.logger.Warn("Email verification link rejected", gecho.Field("error", err), gecho.Field("user_id", userID))
 		// Redirect to frontend with failure
 		http.Redirect(w, r, getRedirectURL(ar.cfg.Server.FrontendURL, "err"), http.StatusSeeOther)
 		return
 	}
 
-	ar.logger.Info("Email verified successfully", gecho.Field("user_id", userID))
+	// Redirect to the frontend confirm page
+	http.Redirect(w, r, getConfirmURL(ar.cfg.Server.FrontendURL, token, userID), http.StatusSeeOther)
+}
+
+// HandleConfirmEmail verifies the email and consumes the token, on an explicit confirm by the user
+func (ar *AuthRoutesManager) HandleConfirmEmail(w http.ResponseWriter, r *http.Request) {
+	body, err := lib.ExtractAndValidateBody[VerifyEmailRequest](r)
+	if err != nil {
+		ar.logger.Warn("Failed to extract and validate request body", gecho.Field("error", err))
+		gecho.BadRequest(w, gecho.WithMessage("error.auth.missingTokenOrUserId"), gecho.WithData(lib.ClientErrorData(err)), gecho.Send())
+		return
+	}
+
+	// Verify the email
+	if err := ar.authService.VerifyEmail(body.UserID, body.Token); err != nil {
+		ar.logger.Warn("Email verification failed", gecho.Field("error", err), gecho.Field("user_id", body.UserID))
+		gecho.BadRequest(w, gecho.WithMessage("error.auth.invalidVerificationToken"), gecho.Send())
+		return
+	}
+
+	ar.logger.Info("Email verified successfully", gecho.Field("user_id", body.UserID))
 
-	// Redirect to frontend with success (user needs to log in manually)
-	http.Redirect(w, r, getRedirectURL(ar.cfg.Server.FrontendURL, "ok"), http.StatusSeeOther)
+	// The user needs to log in manually
+	gecho.Success(w, gecho.WithMessage("success.auth.emailVerified"), gecho.Send())
 }
 
 func getRedirectURL(cfgURL, status string) string {
 	url := fmt.Sprintf("%s/email/verified?status=%s", cfgURL, status)
 	return url
 }
+
+// getConfirmURL returns the frontend page that asks the user to confirm the verification
+func getConfirmURL(cfgURL, token, userID string) string {
+	query := url.Values{"token": {token}, "user_id": {userID}}
+	return fmt.Sprintf("%s/email/verify?%s", cfgURL, query.Encode())
+}
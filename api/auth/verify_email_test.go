@@ -0,0 +1,101 @@
+package auth
+
+import (
+	"context"
+	"mamabloemetjes_server/services"
+	"mamabloemetjes_server/structs"
+	"mamabloemetjes_server/structs/tables"
+	"mamabloemetjes_server/testutil"
+	"net/http"
+	"net/http/httptest"
+	"net/url"
+	"strings"
+	"testing"
+	"time"
+)
+
+func TestVerifyEmailRequiresTokenAndUserId(t *testing.T) {
+	ar := &AuthRoutesManager{logger: testutil.Logger(), cfg: testutil.Config()}
+
+	w := httptest.NewRecorder()
+	ar.HandleVerifyEmail(w, httptest.NewRequest(http.MethodGet, "/auth/verify-email?token=abc", nil))
+	if w.Code != http.StatusBadRequest {
+		t.Fatalf("expected status 400 for a link without user id, got %d", w.Code)
+	}
+
+	w = httptest.NewRecorder()
+	ar.HandleConfirmEmail(w, httptest.NewRequest(http.MethodPost, "/auth/verify-email", strings.NewReader(`{"token":"abc"}`)))
+	if w.Code != http.StatusBadRequest {
+		t.Fatalf("expected status 400 for a confirm without user id, got %d", w.Code)
+	}
+}
+
+func TestVerifyEmailOnlyPostConsumesToken(t *testing.T) {
+	db := testutil.DB(t)
+	testutil.Redis(t)
+	cfg := testutil.Config()
+	logger := testutil.Logger()
+	ctx := context.Background()
+
+	authService := services.NewAuthService(cfg, logger, db, services.NewCacheService(logger, cfg))
+	ar := &AuthRoutesManager{logger: logger, authService: authService, cfg: cfg}
+
+	user, err := authService.Register(&structs.RegisterRequest{Username: "Jan Jansen", Email: "jan@example.com", Password: "correct horse battery"})
+	if err != nil {
+		t.Fatalf("Register: %v", err)
+	}
+	const token = "0123456789abcdef0123456789abcdef"
+	verification := &tables.EmailVerification{UserId: user.Id, Token: token, ExpiresAt: time.Now().Add(time.Hour), CreatedAt: time.Now()}
+	if _, err := db.NewInsert().Model(verification).Exec(ctx); err != nil {
+		t.Fatalf("failed to seed the verification token: %v", err)
+	}
+
+	// tokenState reports whether the token is still stored and whether the email is verified
+	tokenState := func() (bool, bool) {
+		t.Helper()
+		stored, err := db.NewSelect().Model((*tables.EmailVerification)(nil)).Where("token = ?", token).Exists(ctx)
+		if err != nil {
+			t.Fatalf("failed to look up the token: %v", err)
+		}
+		var verified bool
+		if err := db.NewSelect().Model((*tables.User)(nil)).Column("email_verified").Where("id = ?", user.Id).Scan(ctx, &verified); err != nil {
+			t.Fatalf("failed to read the user: %v", err)
+		}
+		return stored, verified
+	}
+	link := "/auth/verify-email?" + url.Values{"token": {token}, "user_id": {user.Id.String()}}.Encode()
+	confirm := `{"token":"` + token + `","user_id":"` + user.Id.String() + `"}`
+
+	// A link scanner may open the link any number of times
+	for range 2 {
+		w := httptest.NewRecorder()
+		ar.HandleVerifyEmail(w, httptest.NewRequest(http.MethodGet, link, nil))
+		if w.Code != http.StatusSeeOther || w.Header().Get("Location") != getConfirmURL(cfg.Server.FrontendURL, token, user.Id.String()) {
+			t.Fatalf("expected a redirect to the confirm page, got %d to %s", w.Code, w.Header().Get("Location"))
+		}
+	}
+	if stored, verified := tokenState(); !stored || verified {
+		t.Fatalf("expected the GET to leave the token unused, got stored %v and verified %v", stored, verified)
+	}
+
+	w := httptest.NewRecorder()
+	ar.HandleConfirmEmail(w, httptest.NewRequest(http.MethodPost, "/auth/verify-email", strings.NewReader(confirm)))
+	if w.Code != http.StatusOK {
+		t.Fatalf("expected status 200 for the confirm, got %d: %s", w.Code, w.Body.String())
+	}
+	if stored, verified := tokenState(); stored || !verified {
+		t.Fatalf("expected the POST to consume the token and verify the email, got stored %v and verified %v", stored, verified)
+	}
+
+	// The consumed token no longer works on either path
+	w = httptest.NewRecorder()
+	ar.HandleConfirmEmail(w, httptest.NewRequest(http.MethodPost, "/auth/verify-email", strings.NewReader(confirm)))
+	if w.Code != http.StatusBadRequest {
+		t.Fatalf("expected status 400 for a second confirm, got %d", w.Code)
+	}
+	w = httptest.NewRecorder()
+	ar.HandleVerifyEmail(w, httptest.NewRequest(http.MethodGet, link, nil))
+	if w.Header().Get("Location") != getRedirectURL(cfg.Server.FrontendURL, "err") {
+		t.Fatalf("expected a used link to land on the error page, got %s", w.Header().Get("Location"))
+	}
+}
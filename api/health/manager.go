@@ -21,9 +21,10 @@ func NewHealthRoutesManager(healthService *services.HealthService) *HealthRoutes
 func (hrm *HealthRoutesManager) RegisterRoutes(r chi.Router) {
 	r.Get("/health/server", hrm.GetServerHealth)
 	r.Get("/health/database", hrm.GetDatabaseHealth)
+	r.Get("/version", hrm.GetVersion)
 
 	// Prometheus metrics endpoint
 	r.Get("/metrics", promhttp.Handler().ServeHTTP)
 	// Register Prometheus metrics
-	prometheus.MustRegister(HttpDuration, HttpRequests)
+	prometheus.MustRegister(HttpDuration, HttpRequests, services.CacheLookups)
 }
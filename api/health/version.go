@@ -0,0 +1,15 @@
+package health
+
+import (
+	"mamabloemetjes_server/lib"
+	"net/http"
+
+	"github.com/MonkyMars/gecho"
+)
+
+func (hrm *HealthRoutesManager) GetVersion(w http.ResponseWriter, r *http.Request) {
+	gecho.Success(w,
+		gecho.WithData(lib.GetBuildInfo()),
+		gecho.Send(),
+	)
+}
@@ -0,0 +1,36 @@
+package health
+
+import (
+	"encoding/json"
+	"mamabloemetjes_server/lib"
+	"net/http"
+	"net/http/httptest"
+	"runtime"
+	"testing"
+)
+
+func TestGetVersionReturnsBuildInfo(t *testing.T) {
+	// Placeholders for the values -ldflags injects at build time
+	version, commit, buildDate := lib.Version, lib.Commit, lib.BuildDate
+	t.Cleanup(func() { lib.Version, lib.Commit, lib.BuildDate = version, commit, buildDate })
+	lib.Version, lib.Commit, lib.BuildDate = "v1.2.3", "abc1234", "2026-01-01T00:00:00Z"
+
+	w := httptest.NewRecorder()
+	(&HealthRoutesManager{}).GetVersion(w, httptest.NewRequest(http.MethodGet, "/version", nil))
+
+	if w.Code != http.StatusOK {
+		t.Fatalf("expected status 200, got %d", w.Code)
+	}
+	var response struct {
+		Data map[string]string `json:"data"`
+	}
+	if err := json.Unmarshal(w.Body.Bytes(), &response); err != nil {
+		t.Fatalf("failed to decode the build info: %v", err)
+	}
+	want := map[string]string{"version": "v1.2.3", "commit": "abc1234", "build_date": "2026-01-01T00:00:00Z", "go_version": runtime.Version()}
+	for field, value := range want {
+		if response.Data[field] != value {
+			t.Fatalf("expected %s %q, got %q in %s", field, value, response.Data[field], w.Body.String())
+		}
+	}
+}
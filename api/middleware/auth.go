@@ -8,6 +8,7 @@ import (
 	"net/http"
 
 	"github.com/MonkyMars/gecho"
+	"github.com/google/uuid"
 )
 
 // Context keys for storing user data in request context
@@ -49,6 +50,24 @@ func (mw *Middleware) UserAuthMiddleware(next http.Handler) http.Handler {
 			return
 		}
 
+		// Tokens issued before the last password change carry an older token version
+		current, err := mw.hasCurrentTokenVersion(r.Context(), claims)
+		if lib.IsNotFound(err) {
+			mw.logger.Warn("Access token of a deleted user", gecho.Field("user_id", claims.Sub))
+			gecho.Unauthorized(w, gecho.WithMessage("error.auth.invalidOrMissingAccessToken"), gecho.Send())
+			return
+		}
+		if err != nil {
+			mw.logger.Error("Failed to check token version", gecho.Field("error", err), gecho.Field("user_id", claims.Sub))
+			gecho.ServiceUnavailable(w, gecho.WithMessage("error.serviceUnavailable"), gecho.Send())
+			return
+		}
+		if !current {
+			mw.logger.Warn("Access token with outdated token version", gecho.Field("user_id", claims.Sub), gecho.Field("token_version", claims.Ver))
+			gecho.Unauthorized(w, gecho.WithMessage("error.auth.accessTokenRevoked"), gecho.Send())
+			return
+		}
+
 		// Add user and claims to request context
 		ctx := context.WithValue(r.Context(), ClaimsContextKey, claims)
 
@@ -57,6 +76,48 @@ func (mw *Middleware) UserAuthMiddleware(next http.Handler) http.Handler {
 	})
 }
 
+// OptionalClaims returns the claims of the request's access token, for routes that also serve guests
+// A missing, invalid, blacklisted or outdated token counts as anonymous. A token that cannot be checked
+// because Redis or the database failed returns an error, so the caller does not quietly serve a signed-in
+// user as a guest
+func (mw *Middleware) OptionalClaims(r *http.Request) (*structs.AuthClaims, bool, error) {
+	claims, err := lib.ExtractClaims(r)
+	if err != nil {
+		return nil, false, nil
+	}
+
+	isRevoked, err := mw.cacheService.IsTokenBlacklisted(claims.Jti)
+	if err != nil {
+		mw.logger.Error("Failed to check if token is revoked on optional auth route", gecho.Field("error", err))
+		return nil, false, err
+	}
+	if isRevoked {
+		mw.logger.Debug("Revoked token on optional auth route", gecho.Field("token_id", claims.Jti))
+		return nil, false, nil
+	}
+
+	current, err := mw.hasCurrentTokenVersion(r.Context(), claims)
+	if err != nil && !lib.IsNotFound(err) {
+		mw.logger.Error("Failed to check token version on optional auth route", gecho.Field("error", err), gecho.Field("user_id", claims.Sub))
+		return nil, false, err
+	}
+	if !current {
+		mw.logger.Debug("Outdated token version or deleted user on optional auth route", gecho.Field("user_id", claims.Sub))
+		return nil, false, nil
+	}
+
+	return claims, true, nil
+}
+
+// hasCurrentTokenVersion reports whether the token was issued with the user's current token version
+func (mw *Middleware) hasCurrentTokenVersion(ctx context.Context, claims *structs.AuthClaims) (bool, error) {
+	version, err := mw.authService.CurrentTokenVersion(ctx, claims.Sub)
+	if err != nil {
+		return false, err
+	}
+	return claims.Ver == version, nil
+}
+
 // AdminAuthMiddleware protects routes to only admin users
 // Must be used after UserAuthMiddleware
 func (mw *Middleware) AdminAuthMiddleware(next http.Handler) http.Handler {
@@ -81,6 +142,53 @@ func (mw *Middleware) AdminAuthMiddleware(next http.Handler) http.Handler {
 	})
 }
 
+// RequireVerifiedEmail protects routes to only users with a verified email address
+// Must be used after UserAuthMiddleware
+func (mw *Middleware) RequireVerifiedEmail(next http.Handler) http.Handler {
+	return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		claims, ok := GetClaimsFromContext(r.Context())
+		if !ok {
+			mw.logger.Error("Claims not found in context - UserAuthMiddleware must be used before RequireVerifiedEmail")
+			gecho.Unauthorized(w, gecho.WithMessage("error.auth.invalidOrMissingAccessToken"), gecho.Send())
+			return
+		}
+
+		user, ok := mw.VerifiedUser(w, r, claims.Sub)
+		if !ok {
+			return
+		}
+
+		// Add user to request context for downstream handlers
+		ctx := context.WithValue(r.Context(), UserContextKey, user)
+		next.ServeHTTP(w, r.WithContext(ctx))
+	})
+}
+
+// VerifiedUser loads the user (cache-assisted) to check their current verification status, for routes that
+// also serve guests and so cannot use RequireVerifiedEmail. When the user may not continue it answers 401 for
+// a deleted user, 403 for an unverified one or 503 when the user cannot be loaded, and returns false
+func (mw *Middleware) VerifiedUser(w http.ResponseWriter, r *http.Request, userId uuid.UUID) (*tables.User, bool) {
+	user, err := mw.authService.GetUserByID(r.Context(), userId)
+	if lib.IsNotFound(err) {
+		mw.logger.Warn("Access token of a deleted user", gecho.Field("user_id", userId))
+		gecho.Unauthorized(w, gecho.WithMessage("error.auth.invalidOrMissingAccessToken"), gecho.Send())
+		return nil, false
+	}
+	if err != nil {
+		mw.logger.Error("Failed to load user for email verification check", gecho.Field("error", err), gecho.Field("user_id", userId))
+		gecho.ServiceUnavailable(w, gecho.WithMessage("error.serviceUnavailable"), gecho.Send())
+		return nil, false
+	}
+
+	if !user.EmailVerified {
+		mw.logger.Warn("Unverified user attempted to access verified-only route", gecho.Field("user_id", userId))
+		gecho.Forbidden(w, gecho.WithMessage("error.auth.verifyEmail"), gecho.Send())
+		return nil, false
+	}
+
+	return user, true
+}
+
 // GetUserFromContext is a helper function to extract the user from request context
 func GetUserFromContext(ctx context.Context) (*tables.User, bool) {
 	user, ok := ctx.Value(UserContextKey).(*tables.User)
@@ -0,0 +1,106 @@
+package middleware
+
+import (
+	"context"
+	"mamabloemetjes_server/lib"
+	"mamabloemetjes_server/services"
+	"mamabloemetjes_server/structs"
+	"mamabloemetjes_server/structs/tables"
+	"mamabloemetjes_server/testutil"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+
+	"github.com/google/uuid"
+)
+
+// newCachedUserMiddleware returns middleware whose auth service finds users in the in-memory Redis
+func newCachedUserMiddleware(t *testing.T) (*Middleware, *services.CacheService) {
+	t.Helper()
+	testutil.Redis(t)
+	cfg := testutil.Config()
+	logger := testutil.Logger()
+
+	cache := services.NewCacheService(logger, cfg)
+	auth := services.NewAuthService(cfg, logger, nil, cache)
+	return NewMiddleware(cfg, logger, auth, cache, nil), cache
+}
+
+// verifiedStatus sends a request for userId through RequireVerifiedEmail, reporting the status and whether
+// the user reached the handler
+func verifiedStatus(mw *Middleware, userId *uuid.UUID) (int, bool) {
+	reached := false
+	handler := mw.RequireVerifiedEmail(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		user, ok := GetUserFromContext(r.Context())
+		reached = ok && user.Id == *userId
+		w.WriteHeader(http.StatusOK)
+	}))
+
+	r := httptest.NewRequest(http.MethodPost, "/orders", nil)
+	if userId != nil {
+		r = r.WithContext(context.WithValue(r.Context(), ClaimsContextKey, &structs.AuthClaims{Sub: *userId}))
+	}
+	w := httptest.NewRecorder()
+	handler.ServeHTTP(w, r)
+	return w.Code, reached
+}
+
+func TestRequireVerifiedEmail(t *testing.T) {
+	mw, cache := newCachedUserMiddleware(t)
+
+	verified := &tables.User{Id: uuid.New(), Username: "Jan", Role: "user", EmailVerified: true}
+	unverified := &tables.User{Id: uuid.New(), Username: "Piet", Role: "user"}
+	for _, user := range []*tables.User{verified, unverified} {
+		if err := cache.SetUserInCache(user); err != nil {
+			t.Fatalf("SetUserInCache: %v", err)
+		}
+	}
+
+	if status, reached := verifiedStatus(mw, &verified.Id); status != http.StatusOK || !reached {
+		t.Fatalf("expected a verified user to pass with the user in context, got %d (reached %v)", status, reached)
+	}
+	if status, reached := verifiedStatus(mw, &unverified.Id); status != http.StatusForbidden || reached {
+		t.Fatalf("expected an unverified user to get 403, got %d (reached %v)", status, reached)
+	}
+	if status, reached := verifiedStatus(mw, nil); status != http.StatusUnauthorized || reached {
+		t.Fatalf("expected a request without claims to get 401, got %d (reached %v)", status, reached)
+	}
+}
+
+func TestOptionalClaimsReportsFailedChecks(t *testing.T) {
+	mw, cache := newCachedUserMiddleware(t)
+	redis := testutil.Redis(t)
+
+	user := &tables.User{Id: uuid.New(), Username: "Jan", Role: "user"}
+	token, err := mw.authService.GenerateAccessToken(user)
+	if err != nil {
+		t.Fatalf("GenerateAccessToken: %v", err)
+	}
+	request := func() *http.Request {
+		r := httptest.NewRequest(http.MethodPost, "/orders/create", nil)
+		r.AddCookie(&http.Cookie{Name: lib.AccessCookieName, Value: token})
+		return r
+	}
+
+	if err := cache.SetTokenVersion(user.Id, user.TokenVersion); err != nil {
+		t.Fatalf("SetTokenVersion: %v", err)
+	}
+	if claims, ok, err := mw.OptionalClaims(request()); err != nil || !ok || claims.Sub != user.Id {
+		t.Fatalf("expected the user's claims, got %+v, %v (err %v)", claims, ok, err)
+	}
+
+	// An outdated token is anonymous
+	if err := cache.SetTokenVersion(user.Id, user.TokenVersion+1); err != nil {
+		t.Fatalf("SetTokenVersion: %v", err)
+	}
+	if _, ok, err := mw.OptionalClaims(request()); err != nil || ok {
+		t.Fatalf("expected an outdated token to be anonymous, got %v (err %v)", ok, err)
+	}
+
+	// A token that cannot be checked is an error, not a guest
+	redis.SetError("ERR connection lost")
+	t.Cleanup(func() { redis.SetError("") })
+	if _, ok, err := mw.OptionalClaims(request()); err == nil || ok {
+		t.Fatalf("expected an error when Redis fails, got %v (err %v)", ok, err)
+	}
+}
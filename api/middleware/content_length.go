@@ -0,0 +1,48 @@
+package middleware
+
+import (
+	"bytes"
+	"net/http"
+	"strconv"
+)
+
+// bufferedResponseWriter holds the response body so its length is known before anything is sent
+type bufferedResponseWriter struct {
+	http.ResponseWriter
+	status int
+	buf    bytes.Buffer
+}
+
+func (bw *bufferedResponseWriter) WriteHeader(status int) {
+	if bw.status == 0 {
+		bw.status = status
+	}
+}
+
+func (bw *bufferedResponseWriter) Write(b []byte) (int, error) {
+	if bw.status == 0 {
+		bw.status = http.StatusOK
+	}
+	return bw.buf.Write(b)
+}
+
+// ContentLength buffers the response to always send an exact Content-Length header
+// For HEAD requests the headers are sent without a body, so HEAD mirrors GET
+// Only use on routes with reasonably sized responses (e.g. product reads)
+func ContentLength(next http.Handler) http.Handler {
+	return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		bw := &bufferedResponseWriter{ResponseWriter: w}
+		next.ServeHTTP(bw, r)
+
+		if bw.status == 0 {
+			bw.status = http.StatusOK
+		}
+
+		w.Header().Set("Content-Length", strconv.Itoa(bw.buf.Len()))
+		w.WriteHeader(bw.status)
+
+		if r.Method != http.MethodHead {
+			_, _ = w.Write(bw.buf.Bytes())
+		}
+	})
+}
@@ -0,0 +1,60 @@
+package middleware
+
+import (
+	"net/http"
+	"net/http/httptest"
+	"strconv"
+	"testing"
+
+	"github.com/MonkyMars/gecho"
+)
+
+func TestContentLengthHeadMatchesGet(t *testing.T) {
+	// A fixed body: the gecho timestamp drops trailing zeros, so two envelopes can differ in length
+	handler := ContentLength(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		w.Header().Set("Cache-Control", "public, max-age=60")
+		w.Header().Set("Content-Type", "application/json")
+		w.WriteHeader(http.StatusOK)
+		w.Write([]byte(`{"status":200,"success":true,"message":"success.product.fetched","data":{"name":"Rozenboeket"}}`))
+	}))
+
+	serve := func(method string) *httptest.ResponseRecorder {
+		w := httptest.NewRecorder()
+		handler.ServeHTTP(w, httptest.NewRequest(method, "/products/active", nil))
+		return w
+	}
+	get, head := serve(http.MethodGet), serve(http.MethodHead)
+
+	if get.Body.Len() == 0 {
+		t.Fatal("expected GET to return a body")
+	}
+	if length := get.Header().Get("Content-Length"); length != strconv.Itoa(get.Body.Len()) {
+		t.Fatalf("expected Content-Length %d, got %q", get.Body.Len(), length)
+	}
+
+	if head.Code != get.Code {
+		t.Fatalf("expected HEAD status %d, got %d", get.Code, head.Code)
+	}
+	for _, header := range []string{"Content-Length", "Content-Type", "Cache-Control"} {
+		if head.Header().Get(header) != get.Header().Get(header) {
+			t.Fatalf("expected HEAD %s %q, got %q", header, get.Header().Get(header), head.Header().Get(header))
+		}
+	}
+	if head.Body.Len() != 0 {
+		t.Fatalf("expected HEAD without a body, got %d bytes", head.Body.Len())
+	}
+}
+
+func TestContentLengthKeepsErrorStatus(t *testing.T) {
+	handler := ContentLength(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		gecho.NotFound(w, gecho.WithMessage("error.product.notFound"), gecho.Send())
+	}))
+
+	for _, method := range []string{http.MethodGet, http.MethodHead} {
+		w := httptest.NewRecorder()
+		handler.ServeHTTP(w, httptest.NewRequest(method, "/products/unknown", nil))
+		if w.Code != http.StatusNotFound {
+			t.Fatalf("expected %s to keep the 404, got %d", method, w.Code)
+		}
+	}
+}
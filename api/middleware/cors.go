@@ -1,21 +1,47 @@
 package middleware
 
 import (
-	"mamabloemetjes_server/config"
+	"net/url"
+	"slices"
 
 	"github.com/rs/cors"
 )
 
+// SetupCORS builds the CORS handler from config
+// With credentials enabled the specific request origin is echoed back (never "*"),
+// and preflight responses are cached by the browser for Cors.MaxAge seconds
 func (mw *Middleware) SetupCORS() *cors.Cors {
-	cfg := config.GetConfig()
-	corsMiddleware := cors.New(cors.Options{
-		AllowedOrigins:   cfg.Cors.AllowedOrigins,
-		AllowedMethods:   cfg.Cors.AllowedMethods,
-		AllowedHeaders:   cfg.Cors.AllowedHeaders,
-		ExposedHeaders:   cfg.Cors.ExposedHeaders,
-		AllowCredentials: cfg.Cors.AllowCredentials,
-		MaxAge:           cfg.Cors.MaxAge,
-	})
+	options := cors.Options{
+		AllowedOrigins:   mw.cfg.Cors.AllowedOrigins,
+		AllowedMethods:   mw.cfg.Cors.AllowedMethods,
+		AllowedHeaders:   mw.cfg.Cors.AllowedHeaders,
+		ExposedHeaders:   mw.cfg.Cors.ExposedHeaders,
+		AllowCredentials: mw.cfg.Cors.AllowCredentials,
+		MaxAge:           mw.cfg.Cors.MaxAge,
+	}
 
-	return corsMiddleware
+	// The development preset also accepts a frontend on any localhost port.
+	// cors ignores AllowedOrigins once AllowOriginFunc is set, so the configured origins are checked here too
+	if mw.cfg.Cors.AllowLocalhost {
+		allowed := mw.cfg.Cors.AllowedOrigins
+		options.AllowOriginFunc = func(origin string) bool {
+			return slices.Contains(allowed, origin) || isLocalhostOrigin(origin)
+		}
+	}
+
+	return cors.New(options)
+}
+
+// isLocalhostOrigin reports whether origin is an http(s) origin on localhost or a loopback address
+func isLocalhostOrigin(origin string) bool {
+	u, err := url.Parse(origin)
+	if err != nil || (u.Scheme != "http" && u.Scheme != "https") {
+		return false
+	}
+
+	switch u.Hostname() {
+	case "localhost", "127.0.0.1", "::1":
+		return true
+	}
+	return false
 }
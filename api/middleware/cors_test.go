@@ -0,0 +1,83 @@
+package middleware
+
+import (
+	"mamabloemetjes_server/testutil"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+)
+
+func TestCORSEchoesOriginWithCredentials(t *testing.T) {
+	cfg := *testutil.Config()
+	cors := *cfg.Cors
+	cors.AllowedOrigins = []string{"https://www.example.com"}
+	cors.AllowCredentials = true
+	cors.MaxAge = 600
+	cors.AllowLocalhost = false
+	cfg.Cors = &cors
+	handler := NewMiddleware(&cfg, testutil.Logger(), nil, nil, nil).SetupCORS().Handler(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		w.WriteHeader(http.StatusOK)
+	}))
+
+	preflight := func(origin string) http.Header {
+		r := httptest.NewRequest(http.MethodOptions, "/orders", nil)
+		r.Header.Set("Origin", origin)
+		r.Header.Set("Access-Control-Request-Method", http.MethodPost)
+		w := httptest.NewRecorder()
+		handler.ServeHTTP(w, r)
+		return w.Header()
+	}
+
+	headers := preflight("https://www.example.com")
+	if origin := headers.Get("Access-Control-Allow-Origin"); origin != "https://www.example.com" {
+		t.Fatalf("expected the request origin to be echoed, got %q", origin)
+	}
+	if headers.Get("Access-Control-Allow-Credentials") != "true" {
+		t.Fatal("expected credentials to be allowed")
+	}
+	if maxAge := headers.Get("Access-Control-Max-Age"); maxAge != "600" {
+		t.Fatalf("expected the preflight to be cached for 600 seconds, got %q", maxAge)
+	}
+
+	if origin := preflight("https://evil.example.com").Get("Access-Control-Allow-Origin"); origin != "" {
+		t.Fatalf("expected an unknown origin not to be allowed, got %q", origin)
+	}
+}
+
+func TestCORSAllowLocalhost(t *testing.T) {
+	// preflightOrigin returns the allowed origin of a preflight from origin, with the localhost preset on or off
+	preflightOrigin := func(allowLocalhost bool, origin string) (string, string) {
+		cfg := *testutil.Config()
+		cors := *cfg.Cors
+		cors.AllowedOrigins = []string{"https://www.example.com"}
+		cors.AllowCredentials = true
+		cors.AllowLocalhost = allowLocalhost
+		cfg.Cors = &cors
+		handler := NewMiddleware(&cfg, testutil.Logger(), nil, nil, nil).SetupCORS().Handler(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+			w.WriteHeader(http.StatusOK)
+		}))
+
+		r := httptest.NewRequest(http.MethodOptions, "/orders", nil)
+		r.Header.Set("Origin", origin)
+		r.Header.Set("Access-Control-Request-Method", http.MethodPost)
+		w := httptest.NewRecorder()
+		handler.ServeHTTP(w, r)
+		return w.Header().Get("Access-Control-Allow-Origin"), w.Header().Get("Access-Control-Allow-Credentials")
+	}
+
+	for _, origin := range []string{"http://localhost:5173", "https://localhost:3000", "http://127.0.0.1:8080", "http://[::1]:5173", "https://www.example.com"} {
+		if allowed, credentials := preflightOrigin(true, origin); allowed != origin || credentials != "true" {
+			t.Fatalf("expected %s to be echoed with credentials in development, got %q (credentials %q)", origin, allowed, credentials)
+		}
+	}
+	for _, origin := range []string{"https://evil.example.com", "http://localhost.evil.example.com", "ftp://localhost"} {
+		if allowed, _ := preflightOrigin(true, origin); allowed != "" {
+			t.Fatalf("expected %s not to be allowed in development, got %q", origin, allowed)
+		}
+	}
+
+	// Production only allows the configured origins
+	if allowed, _ := preflightOrigin(false, "http://localhost:5173"); allowed != "" {
+		t.Fatalf("expected localhost not to be allowed in production, got %q", allowed)
+	}
+}
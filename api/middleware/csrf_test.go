@@ -0,0 +1,154 @@
+package middleware
+
+import (
+	"context"
+	"mamabloemetjes_server/lib"
+	"mamabloemetjes_server/services"
+	"mamabloemetjes_server/structs"
+	"mamabloemetjes_server/testutil"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+
+	"github.com/google/uuid"
+)
+
+// csrfSession is a signed-in session with the CSRF token bound to it, as issued at login
+type csrfSession struct {
+	refreshToken string
+	csrfToken    string
+}
+
+// newCSRFSession starts a session for user and binds a fresh CSRF token to it
+func newCSRFSession(t *testing.T, auth *services.AuthService, userId uuid.UUID) csrfSession {
+	t.Helper()
+	user, err := auth.GetUserByID(context.Background(), userId)
+	if err != nil {
+		t.Fatalf("GetUserByID: %v", err)
+	}
+	refreshToken, err := auth.GenerateRefreshToken(user, "test-agent", "1.2.3.4")
+	if err != nil {
+		t.Fatalf("GenerateRefreshToken: %v", err)
+	}
+	csrfToken, err := lib.GenerateCSRFToken(lib.UserCSRFSession(userId))
+	if err != nil {
+		t.Fatalf("GenerateCSRFToken: %v", err)
+	}
+	jti, err := lib.RefreshTokenJti(refreshToken)
+	if err != nil {
+		t.Fatalf("RefreshTokenJti: %v", err)
+	}
+	if err := auth.SetSessionCSRFToken(jti, csrfToken); err != nil {
+		t.Fatalf("SetSessionCSRFToken: %v", err)
+	}
+	return csrfSession{refreshToken: refreshToken, csrfToken: csrfToken}
+}
+
+// csrfStatus sends a POST from session presenting csrfToken as cookie and header, returning the status
+func csrfStatus(mw *Middleware, session csrfSession, csrfToken string) int {
+	handler := mw.CSRFMiddleware()(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		w.WriteHeader(http.StatusNoContent)
+	}))
+
+	r := httptest.NewRequest(http.MethodPost, "/auth/logout", nil)
+	r.AddCookie(&http.Cookie{Name: lib.RefreshCookieName, Value: session.refreshToken})
+	r.AddCookie(&http.Cookie{Name: lib.CSRFCookieName, Value: csrfToken})
+	r.Header.Set("X-CSRF-Token", csrfToken)
+	w := httptest.NewRecorder()
+	handler.ServeHTTP(w, r)
+	return w.Code
+}
+
+func TestCSRFTokenSwappedBetweenSessions(t *testing.T) {
+	db := testutil.DB(t)
+	testutil.Redis(t)
+	cfg := testutil.Config()
+	logger := testutil.Logger()
+
+	cache := services.NewCacheService(logger, cfg)
+	auth := services.NewAuthService(cfg, logger, db, cache)
+	mw := NewMiddleware(cfg, logger, auth, cache, nil)
+
+	register := func(email string) uuid.UUID {
+		user, err := auth.Register(&structs.RegisterRequest{Username: "Test User", Email: email, Password: "correct horse battery"})
+		if err != nil {
+			t.Fatalf("Register: %v", err)
+		}
+		return user.Id
+	}
+	jan, piet := register("jan@example.com"), register("piet@example.com")
+
+	phone := newCSRFSession(t, auth, jan)
+	laptop := newCSRFSession(t, auth, jan)
+	other := newCSRFSession(t, auth, piet)
+
+	tests := []struct {
+		name      string
+		session   csrfSession
+		csrfToken string
+		status    int
+	}{
+		{"own token", phone, phone.csrfToken, http.StatusNoContent},
+		{"token of another session of the same user", phone, laptop.csrfToken, http.StatusForbidden},
+		{"token of the other session used back", laptop, phone.csrfToken, http.StatusForbidden},
+		{"token of another user", phone, other.csrfToken, http.StatusForbidden},
+		{"own token in the other user's session", other, phone.csrfToken, http.StatusForbidden},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			if status := csrfStatus(mw, tt.session, tt.csrfToken); status != tt.status {
+				t.Fatalf("expected status %d, got %d", tt.status, status)
+			}
+		})
+	}
+}
+
+func TestCSRFMiddlewareAnonymousRequests(t *testing.T) {
+	mw := NewMiddleware(testutil.Config(), testutil.Logger(), nil, nil, nil)
+	handler := mw.CSRFMiddleware()(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		w.WriteHeader(http.StatusNoContent)
+	}))
+
+	anonymous, err := lib.GenerateCSRFToken(lib.AnonymousCSRFSession)
+	if err != nil {
+		t.Fatalf("GenerateCSRFToken: %v", err)
+	}
+	other, err := lib.GenerateCSRFToken(lib.AnonymousCSRFSession)
+	if err != nil {
+		t.Fatalf("GenerateCSRFToken: %v", err)
+	}
+	userToken, err := lib.GenerateCSRFToken(lib.UserCSRFSession(uuid.New()))
+	if err != nil {
+		t.Fatalf("GenerateCSRFToken: %v", err)
+	}
+
+	tests := []struct {
+		name   string
+		cookie string
+		header string
+		status int
+	}{
+		{"matching anonymous token", anonymous, anonymous, http.StatusNoContent},
+		{"missing cookie", "", anonymous, http.StatusForbidden},
+		{"missing header", anonymous, "", http.StatusForbidden},
+		{"header differs from cookie", anonymous, other, http.StatusForbidden},
+		{"token of a signed-in user", userToken, userToken, http.StatusForbidden},
+		{"unsigned token", "forged", "forged", http.StatusForbidden},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			r := httptest.NewRequest(http.MethodPost, "/contact", nil)
+			if tt.cookie != "" {
+				r.AddCookie(&http.Cookie{Name: lib.CSRFCookieName, Value: tt.cookie})
+			}
+			if tt.header != "" {
+				r.Header.Set("X-CSRF-Token", tt.header)
+			}
+			w := httptest.NewRecorder()
+			handler.ServeHTTP(w, r)
+			if w.Code != tt.status {
+				t.Fatalf("expected status %d, got %d", tt.status, w.Code)
+			}
+		})
+	}
+}
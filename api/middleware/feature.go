@@ -0,0 +1,27 @@
+package middleware
+
+import (
+	"net/http"
+
+	"github.com/MonkyMars/gecho"
+)
+
+// Known feature flags; defaults come from the FEATURE_FLAGS setting
+const (
+	FeatureNewCheckout     = "new_checkout"
+	FeatureTrendingSection = "trending_section"
+)
+
+// RequireFeature only serves the route while the flag is enabled and answers 404 otherwise,
+// so a disabled feature looks the same as a route that does not exist
+func (mw *Middleware) RequireFeature(flag string) func(http.Handler) http.Handler {
+	return func(next http.Handler) http.Handler {
+		return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+			if !mw.featureFlags.IsEnabled(r.Context(), flag) {
+				gecho.NotFound(w, gecho.WithMessage("error.notFound"), gecho.Send())
+				return
+			}
+			next.ServeHTTP(w, r)
+		})
+	}
+}
@@ -1,7 +1,6 @@
 package middleware
 
 import (
-	"mamabloemetjes_server/database"
 	"mamabloemetjes_server/services"
 	"mamabloemetjes_server/structs"
 
@@ -12,14 +11,16 @@ type Middleware struct {
 	logger       *gecho.Logger
 	authService  *services.AuthService
 	cacheService *services.CacheService
+	featureFlags *services.FeatureFlagService
 	cfg          *structs.Config
 }
 
-func NewMiddleware(cfg *structs.Config, logger *gecho.Logger, db *database.DB) *Middleware {
+func NewMiddleware(cfg *structs.Config, logger *gecho.Logger, authService *services.AuthService, cacheService *services.CacheService, featureFlags *services.FeatureFlagService) *Middleware {
 	return &Middleware{
 		logger:       logger,
-		authService:  services.NewAuthService(cfg, logger, db),
-		cacheService: services.NewCacheService(logger, cfg),
+		authService:  authService,
+		cacheService: cacheService,
+		featureFlags: featureFlags,
 		cfg:          cfg,
 	}
 }
@@ -6,25 +6,55 @@ import (
 	"strconv"
 	"time"
 
+	"github.com/MonkyMars/gecho"
+	"github.com/go-chi/chi/v5"
 	"github.com/go-chi/chi/v5/middleware"
 	"github.com/prometheus/client_golang/prometheus"
 )
 
-func MetricsMiddleware(next http.Handler) http.Handler {
-	return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
-		start := time.Now()
+// MetricsMiddleware records request metrics and warns when a handler exceeds the slow request budget
+func (mw *Middleware) MetricsMiddleware() func(http.Handler) http.Handler {
+	budget := mw.cfg.Server.SlowRequestThreshold
 
-		ww := middleware.NewWrapResponseWriter(w, r.ProtoMajor)
-		next.ServeHTTP(ww, r)
+	return func(next http.Handler) http.Handler {
+		return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+			start := time.Now()
 
-		labels := prometheus.Labels{
-			"method": r.Method,
-			"path":   r.URL.Path,
-			"status": strconv.Itoa(ww.Status()),
-		}
+			ww := middleware.NewWrapResponseWriter(w, r.ProtoMajor)
+			next.ServeHTTP(ww, r)
+
+			duration := time.Since(start)
+
+			labels := prometheus.Labels{
+				"method": r.Method,
+				"path":   r.URL.Path,
+				"status": strconv.Itoa(ww.Status()),
+			}
+
+			health.HttpRequests.With(labels).Inc()
+			health.HttpDuration.With(labels).
+				Observe(duration.Seconds())
 
-		health.HttpRequests.With(labels).Inc()
-		health.HttpDuration.With(labels).
-			Observe(time.Since(start).Seconds())
-	})
+			if budget > 0 && duration > budget {
+				mw.logger.Warn("Slow request exceeded response-time budget",
+					gecho.Field("method", r.Method),
+					gecho.Field("route", routePattern(r)),
+					gecho.Field("path", r.URL.Path),
+					gecho.Field("status", ww.Status()),
+					gecho.Field("duration", duration.String()),
+					gecho.Field("budget", budget.String()),
+				)
+			}
+		})
+	}
+}
+
+// routePattern returns the matched chi route pattern, falling back to the raw path
+func routePattern(r *http.Request) string {
+	if rctx := chi.RouteContext(r.Context()); rctx != nil {
+		if pattern := rctx.RoutePattern(); pattern != "" {
+			return pattern
+		}
+	}
+	return r.URL.Path
 }
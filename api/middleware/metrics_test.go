@@ -0,0 +1,50 @@
+package middleware
+
+import (
+	"bytes"
+	"mamabloemetjes_server/testutil"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+	"time"
+)
+
+// newBudgetMiddleware returns middleware with the given slow request budget, logging to logs
+func newBudgetMiddleware(budget time.Duration, logs *bytes.Buffer) *Middleware {
+	cfg := *testutil.Config()
+	server := *cfg.Server
+	server.SlowRequestThreshold = budget
+	cfg.Server = &server
+	return NewMiddleware(&cfg, testutil.LoggerTo(logs), nil, nil, nil)
+}
+
+// serveWithDelay sends a request through the metrics middleware to a handler taking delay
+func serveWithDelay(mw *Middleware, delay time.Duration) {
+	handler := mw.MetricsMiddleware()(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		time.Sleep(delay)
+		w.WriteHeader(http.StatusOK)
+	}))
+	handler.ServeHTTP(httptest.NewRecorder(), httptest.NewRequest(http.MethodGet, "/products", nil))
+}
+
+func TestSlowRequestBudget(t *testing.T) {
+	var logs bytes.Buffer
+	serveWithDelay(newBudgetMiddleware(10*time.Millisecond, &logs), 30*time.Millisecond)
+	if !strings.Contains(logs.String(), "Slow request exceeded response-time budget") {
+		t.Fatalf("expected a warning for a handler over budget, got:\n%s", logs.String())
+	}
+
+	logs.Reset()
+	serveWithDelay(newBudgetMiddleware(time.Second, &logs), 0)
+	if strings.Contains(logs.String(), "Slow request") {
+		t.Fatalf("expected no warning for a handler within budget, got:\n%s", logs.String())
+	}
+
+	// A zero budget turns the warning off
+	logs.Reset()
+	serveWithDelay(newBudgetMiddleware(0, &logs), 20*time.Millisecond)
+	if strings.Contains(logs.String(), "Slow request") {
+		t.Fatalf("expected no warning with the budget disabled, got:\n%s", logs.String())
+	}
+}
@@ -0,0 +1,54 @@
+package middleware
+
+import (
+	"mamabloemetjes_server/database"
+	"net/http"
+	"strconv"
+)
+
+// QueryCountHeader carries the number of database queries a request ran, outside production
+const QueryCountHeader = "X-DB-Queries"
+
+// QueryCountMiddleware counts the database queries each request runs and reports them in the X-DB-Queries
+// header, to spot N+1 patterns during development. Only queries run with the request context are counted,
+// which includes the auth middleware's user, token version and session lookups; work moved to a goroutine or
+// the email queue is not. It does nothing in production
+func (mw *Middleware) QueryCountMiddleware() func(http.Handler) http.Handler {
+	return func(next http.Handler) http.Handler {
+		if mw.cfg.Server.Environment == "production" {
+			return next
+		}
+
+		return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+			r = r.WithContext(database.WithQueryCounter(r.Context()))
+			next.ServeHTTP(&queryCountWriter{ResponseWriter: w, r: r}, r)
+		})
+	}
+}
+
+// queryCountWriter sets the query count header right before the response headers are written
+type queryCountWriter struct {
+	http.ResponseWriter
+	r           *http.Request
+	wroteHeader bool
+}
+
+func (w *queryCountWriter) WriteHeader(status int) {
+	if !w.wroteHeader {
+		w.wroteHeader = true
+		w.Header().Set(QueryCountHeader, strconv.Itoa(database.QueryCount(w.r.Context())))
+	}
+	w.ResponseWriter.WriteHeader(status)
+}
+
+func (w *queryCountWriter) Write(b []byte) (int, error) {
+	if !w.wroteHeader {
+		w.WriteHeader(http.StatusOK)
+	}
+	return w.ResponseWriter.Write(b)
+}
+
+// Unwrap lets http.ResponseController reach the underlying writer
+func (w *queryCountWriter) Unwrap() http.ResponseWriter {
+	return w.ResponseWriter
+}
@@ -0,0 +1,69 @@
+package middleware
+
+import (
+	"mamabloemetjes_server/lib"
+	"mamabloemetjes_server/services"
+	"mamabloemetjes_server/structs"
+	"mamabloemetjes_server/testutil"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+)
+
+func TestQueryCountHeader(t *testing.T) {
+	// serve returns the query count header of a request in environment
+	serve := func(environment string) (string, bool) {
+		cfg := *testutil.Config()
+		server := *cfg.Server
+		server.Environment = environment
+		cfg.Server = &server
+		handler := NewMiddleware(&cfg, testutil.Logger(), nil, nil, nil).QueryCountMiddleware()(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+			w.Write([]byte("ok"))
+		}))
+
+		w := httptest.NewRecorder()
+		handler.ServeHTTP(w, httptest.NewRequest(http.MethodGet, "/products", nil))
+		values := w.Header().Values(QueryCountHeader)
+		return w.Header().Get(QueryCountHeader), len(values) > 0
+	}
+
+	if count, ok := serve("development"); !ok || count != "0" {
+		t.Fatalf("expected a query count of 0 in development, got %q", count)
+	}
+	if count, ok := serve("production"); ok {
+		t.Fatalf("expected no query count header in production, got %q", count)
+	}
+}
+
+func TestQueryCountIncludesAuthLookups(t *testing.T) {
+	db := testutil.DB(t)
+	testutil.Redis(t)
+	cfg := testutil.Config()
+	logger := testutil.Logger()
+
+	cache := services.NewCacheService(logger, cfg)
+	auth := services.NewAuthService(cfg, logger, db, cache)
+	mw := NewMiddleware(cfg, logger, auth, cache, nil)
+
+	user, err := auth.Register(&structs.RegisterRequest{Username: "Jan Jansen", Email: "jan@example.com", Password: "correct horse battery"})
+	if err != nil {
+		t.Fatalf("Register: %v", err)
+	}
+	token, err := auth.GenerateAccessToken(user)
+	if err != nil {
+		t.Fatalf("GenerateAccessToken: %v", err)
+	}
+	// The token version is not cached yet, so the middleware reads it from the database
+
+	handler := mw.QueryCountMiddleware()(mw.UserAuthMiddleware(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		w.Write([]byte("ok"))
+	})))
+	r := httptest.NewRequest(http.MethodGet, "/auth/profile", nil)
+	r.AddCookie(&http.Cookie{Name: lib.AccessCookieName, Value: token})
+	w := httptest.NewRecorder()
+	handler.ServeHTTP(w, r)
+
+	if w.Code != http.StatusOK || w.Header().Get(QueryCountHeader) != "1" {
+		t.Fatalf("expected the token version lookup to be counted, got %d with %q queries", w.Code, w.Header().Get(QueryCountHeader))
+	}
+}
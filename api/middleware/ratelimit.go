@@ -2,6 +2,7 @@ package middleware
 
 import (
 	"fmt"
+	"mamabloemetjes_server/lib"
 	"net/http"
 	"strings"
 	"time"
@@ -21,7 +22,10 @@ func (mw *Middleware) getRateLimitForEndpoint(path, method string) (int, time.Du
 	if strings.HasPrefix(path, "/auth/login") ||
 		strings.HasPrefix(path, "/auth/register") ||
 		strings.HasPrefix(path, "/auth/logout") ||
-		strings.HasPrefix(path, "/auth/refresh") {
+		strings.HasPrefix(path, "/auth/refresh") ||
+		strings.HasPrefix(path, "/auth/forgot-password") ||
+		strings.HasPrefix(path, "/auth/reset-password") ||
+		strings.HasPrefix(path, "/auth/change-password") {
 		return mw.cfg.RateLimit.AuthLimit, mw.cfg.RateLimit.AuthWindow
 	}
 
@@ -32,7 +36,8 @@ func (mw *Middleware) getRateLimitForEndpoint(path, method string) (int, time.Du
 
 	// Expensive read operations
 	if method == http.MethodGet && (strings.Contains(path, "/products") ||
-		strings.Contains(path, "/search")) {
+		strings.Contains(path, "/search") ||
+		strings.HasPrefix(path, "/orders/lookup")) {
 		return mw.cfg.RateLimit.ExpensiveLimit, mw.cfg.RateLimit.ExpensiveWindow
 	}
 
@@ -42,6 +47,11 @@ func (mw *Middleware) getRateLimitForEndpoint(path, method string) (int, time.Du
 
 // getClientIP extracts the real client IP from request headers
 func (mw *Middleware) getClientIP(r *http.Request) string {
+	return ClientIP(r)
+}
+
+// ClientIP extracts the real client IP from request headers, falling back to the remote address
+func ClientIP(r *http.Request) string {
 	// Try X-Forwarded-For first (if behind proxy/load balancer)
 	if xff := r.Header.Get("X-Forwarded-For"); xff != "" {
 		// X-Forwarded-For can contain multiple IPs, take the first one
@@ -66,6 +76,25 @@ func (mw *Middleware) getClientIP(r *http.Request) string {
 	return ip
 }
 
+// rateLimitSubject returns who a request is counted against: "user:<id>" for an authenticated request when
+// per-user limiting is enabled, the client IP otherwise. The global limiter runs before the auth middleware,
+// so without claims in the context the access token is read from the request; an invalid token counts as anonymous
+func (mw *Middleware) rateLimitSubject(r *http.Request) string {
+	if mw.cfg.RateLimit.PerUser {
+		claims, ok := GetClaimsFromContext(r.Context())
+		if !ok {
+			if tokenClaims, err := lib.ExtractClaims(r); err == nil {
+				claims, ok = tokenClaims, true
+			}
+		}
+		if ok {
+			return "user:" + claims.Sub.String()
+		}
+	}
+
+	return mw.getClientIP(r)
+}
+
 // generateRateLimitKey creates a unique cache key for rate limiting
 func (mw *Middleware) generateRateLimitKey(ip, endpoint string) string {
 	// Normalize endpoint to group similar requests
@@ -87,6 +116,23 @@ func (mw *Middleware) generateRateLimitKey(ip, endpoint string) string {
 	return fmt.Sprintf("%s:%s", ip, normalizedEndpoint)
 }
 
+// respondRateLimited writes a 429 in the standard envelope, with the limit, window and retry_after (seconds)
+// as data and the matching Retry-After and X-RateLimit-* headers
+func respondRateLimited(w http.ResponseWriter, message string, limit int, window time.Duration) {
+	retryAfter := int(window.Seconds())
+
+	w.Header().Set("X-RateLimit-Limit", fmt.Sprintf("%d", limit))
+	w.Header().Set("X-RateLimit-Remaining", "0")
+	w.Header().Set("X-RateLimit-Reset", fmt.Sprintf("%d", time.Now().Add(window).Unix()))
+	w.Header().Set("Retry-After", fmt.Sprintf("%d", retryAfter))
+
+	gecho.TooManyRequests(w,
+		gecho.WithMessage(message),
+		gecho.WithData(map[string]any{"limit": limit, "window": window.String(), "retry_after": retryAfter}),
+		gecho.Send(),
+	)
+}
+
 // RateLimitMiddleware implements sliding window rate limiting with minimal latency
 func (mw *Middleware) RateLimitMiddleware() func(http.Handler) http.Handler {
 	return func(next http.Handler) http.Handler {
@@ -103,8 +149,8 @@ func (mw *Middleware) RateLimitMiddleware() func(http.Handler) http.Handler {
 				return
 			}
 
-			// Extract client IP
-			clientIP := mw.getClientIP(r)
+			// Count the request against the user or the client IP
+			subject := mw.rateLimitSubject(r)
 
 			// Get rate limit for this endpoint
 			limit, window := mw.getRateLimitForEndpoint(r.URL.Path, r.Method)
@@ -113,12 +159,12 @@ func (mw *Middleware) RateLimitMiddleware() func(http.Handler) http.Handler {
 			endpoint := r.URL.Path
 
 			// Increment rate limit counter (synchronous call)
-			count, err := mw.cacheService.IncrementRateLimit(clientIP, endpoint, window)
+			count, err := mw.cacheService.IncrementRateLimit(subject, endpoint, limit, window)
 			if err != nil {
 				// Cache error - log and allow request (fail open)
 				mw.logger.Warn("Rate limit cache error, allowing request",
 					gecho.Field("error", err),
-					gecho.Field("ip", clientIP),
+					gecho.Field("subject", subject),
 					gecho.Field("endpoint", endpoint),
 				)
 				next.ServeHTTP(w, r)
@@ -128,24 +174,13 @@ func (mw *Middleware) RateLimitMiddleware() func(http.Handler) http.Handler {
 			// Check if limit exceeded
 			if count > limit {
 				mw.logger.Warn("Rate limit exceeded",
-					gecho.Field("ip", clientIP),
+					gecho.Field("subject", subject),
 					gecho.Field("endpoint", endpoint),
 					gecho.Field("count", count),
 					gecho.Field("limit", limit),
 				)
 
-				// Add rate limit headers
-				w.Header().Set("X-RateLimit-Limit", fmt.Sprintf("%d", limit))
-				w.Header().Set("X-RateLimit-Remaining", "0")
-				w.Header().Set("X-RateLimit-Reset", fmt.Sprintf("%d", time.Now().Add(window).Unix()))
-				w.Header().Set("Retry-After", fmt.Sprintf("%d", int(window.Seconds())))
-				w.Header().Set("Content-Type", "application/json")
-
-				gecho.TooManyRequests(w,
-					gecho.WithMessage("error.rateLimitExceeded"),
-					gecho.WithData(map[string]any{"limit": limit, "window": window.String(), "retry_after": int(window.Seconds())}),
-					gecho.Send(),
-				)
+				respondRateLimited(w, "error.rateLimitExceeded", limit, window)
 				return
 			}
 
@@ -158,7 +193,7 @@ func (mw *Middleware) RateLimitMiddleware() func(http.Handler) http.Handler {
 			// Log if getting close to limit (80% threshold)
 			if count > int(float64(limit)*0.8) {
 				mw.logger.Debug("Rate limit warning",
-					gecho.Field("ip", clientIP),
+					gecho.Field("subject", subject),
 					gecho.Field("endpoint", endpoint),
 					gecho.Field("count", count),
 					gecho.Field("limit", limit),
@@ -175,17 +210,26 @@ func (mw *Middleware) RateLimitMiddleware() func(http.Handler) http.Handler {
 // StrictRateLimitMiddleware is a stricter version that fails closed on cache errors
 // Use this for critical endpoints where you prefer to block on cache failure
 func (mw *Middleware) StrictRateLimitMiddleware(limit int, window time.Duration) func(http.Handler) http.Handler {
+	return mw.StrictRateLimitBucketMiddleware("", limit, window)
+}
+
+// StrictRateLimitBucketMiddleware is StrictRateLimitMiddleware counting every request of a client in one named
+// bucket instead of per path, so routes with path parameters share a single limit. An empty bucket uses the path
+func (mw *Middleware) StrictRateLimitBucketMiddleware(bucket string, limit int, window time.Duration) func(http.Handler) http.Handler {
 	return func(next http.Handler) http.Handler {
 		return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
-			clientIP := mw.getClientIP(r)
+			subject := mw.rateLimitSubject(r)
 			endpoint := r.URL.Path
+			if bucket != "" {
+				endpoint = bucket
+			}
 
-			count, err := mw.cacheService.IncrementRateLimit(clientIP, endpoint, window)
+			count, err := mw.cacheService.IncrementRateLimit(subject, endpoint, limit, window)
 			if err != nil {
 				// Fail closed - block request on cache error
 				mw.logger.Error("Rate limit cache error, blocking request",
 					gecho.Field("error", err),
-					gecho.Field("ip", clientIP),
+					gecho.Field("subject", subject),
 					gecho.Field("endpoint", endpoint),
 				)
 
@@ -198,18 +242,13 @@ func (mw *Middleware) StrictRateLimitMiddleware(limit int, window time.Duration)
 
 			if count > limit {
 				mw.logger.Warn("Strict rate limit exceeded",
-					gecho.Field("ip", clientIP),
+					gecho.Field("subject", subject),
 					gecho.Field("endpoint", endpoint),
 					gecho.Field("count", count),
 					gecho.Field("limit", limit),
 				)
 
-				w.Header().Set("X-RateLimit-Limit", fmt.Sprintf("%d", limit))
-				w.Header().Set("X-RateLimit-Remaining", "0")
-				w.Header().Set("Retry-After", fmt.Sprintf("%d", int(window.Seconds())))
-				w.Header().Set("Content-Type", "application/json")
-
-				gecho.TooManyRequests(w, gecho.WithMessage("error.rateLimitExceeded.strict"), gecho.Send())
+				respondRateLimited(w, "error.rateLimitExceeded.strict", limit, window)
 				return
 			}
 
@@ -223,6 +262,16 @@ func (mw *Middleware) StrictRateLimitMiddleware(limit int, window time.Duration)
 	}
 }
 
+// UserLookupRateLimit limits admin customer lookups, failing closed
+func (mw *Middleware) UserLookupRateLimit() func(http.Handler) http.Handler {
+	return mw.StrictRateLimitBucketMiddleware("admin:user-lookup", mw.cfg.RateLimit.UserLookupLimit, mw.cfg.RateLimit.UserLookupWindow)
+}
+
+// CacheWarmRateLimit limits admin cache warmups, failing closed
+func (mw *Middleware) CacheWarmRateLimit() func(http.Handler) http.Handler {
+	return mw.StrictRateLimitBucketMiddleware("admin:cache-warm", mw.cfg.RateLimit.CacheWarmLimit, mw.cfg.RateLimit.CacheWarmWindow)
+}
+
 // IPWhitelistMiddleware allows bypassing rate limits for whitelisted IPs
 func (mw *Middleware) IPWhitelistMiddleware(whitelistedIPs []string) func(http.Handler) http.Handler {
 	// Convert to map for O(1) lookup
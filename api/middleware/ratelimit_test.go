@@ -0,0 +1,142 @@
+package middleware
+
+import (
+	"context"
+	"encoding/json"
+	"fmt"
+	"mamabloemetjes_server/services"
+	"mamabloemetjes_server/structs"
+	"mamabloemetjes_server/testutil"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+	"time"
+
+	"github.com/google/uuid"
+)
+
+// newRateLimitMiddleware returns middleware allowing limit general requests per window, counted per user when perUser is set
+func newRateLimitMiddleware(t *testing.T, limit int, perUser bool) *Middleware {
+	t.Helper()
+	testutil.Redis(t)
+	cfg := *testutil.Config()
+	rateLimit := *cfg.RateLimit
+	rateLimit.Enabled = true
+	rateLimit.PerUser = perUser
+	rateLimit.GeneralLimit = limit
+	cfg.RateLimit = &rateLimit
+	logger := testutil.Logger()
+
+	return NewMiddleware(&cfg, logger, nil, services.NewCacheService(logger, &cfg), nil)
+}
+
+// rateLimitedStatus sends a request from the shared client IP through the limiter, as userId when set
+func rateLimitedStatus(mw *Middleware, userId *uuid.UUID) int {
+	handler := mw.RateLimitMiddleware()(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		w.WriteHeader(http.StatusOK)
+	}))
+
+	r := httptest.NewRequest(http.MethodPost, "/contact", nil)
+	r.RemoteAddr = "203.0.113.7:51234"
+	if userId != nil {
+		r = r.WithContext(context.WithValue(r.Context(), ClaimsContextKey, &structs.AuthClaims{Sub: *userId}))
+	}
+	w := httptest.NewRecorder()
+	handler.ServeHTTP(w, r)
+	return w.Code
+}
+
+func TestRateLimitCountsUsersOnSharedIPSeparately(t *testing.T) {
+	mw := newRateLimitMiddleware(t, 2, true)
+	jan, piet := uuid.New(), uuid.New()
+
+	for range 2 {
+		if status := rateLimitedStatus(mw, &jan); status != http.StatusOK {
+			t.Fatalf("expected the first user within the limit, got %d", status)
+		}
+	}
+	if status := rateLimitedStatus(mw, &jan); status != http.StatusTooManyRequests {
+		t.Fatalf("expected the first user to be limited, got %d", status)
+	}
+
+	// Same IP, but a different user and the anonymous IP have counters of their own
+	for range 2 {
+		if status := rateLimitedStatus(mw, &piet); status != http.StatusOK {
+			t.Fatalf("expected the second user within the limit, got %d", status)
+		}
+		if status := rateLimitedStatus(mw, nil); status != http.StatusOK {
+			t.Fatalf("expected an anonymous request within the limit, got %d", status)
+		}
+	}
+	if status := rateLimitedStatus(mw, nil); status != http.StatusTooManyRequests {
+		t.Fatalf("expected anonymous requests to be limited per IP, got %d", status)
+	}
+}
+
+func TestRateLimitPerUserDisabledCountsPerIP(t *testing.T) {
+	mw := newRateLimitMiddleware(t, 2, false)
+	jan, piet := uuid.New(), uuid.New()
+
+	if rateLimitedStatus(mw, &jan) != http.StatusOK || rateLimitedStatus(mw, &piet) != http.StatusOK {
+		t.Fatal("expected both users within the shared limit")
+	}
+	if status := rateLimitedStatus(mw, &jan); status != http.StatusTooManyRequests {
+		t.Fatalf("expected the users to share the IP counter, got %d", status)
+	}
+}
+
+func TestRateLimitRejectionUsesStandardEnvelope(t *testing.T) {
+	mw := newRateLimitMiddleware(t, 1, true)
+	handler := func(w http.ResponseWriter, r *http.Request) { w.WriteHeader(http.StatusOK) }
+
+	limiters := []struct {
+		name    string
+		limiter func(http.Handler) http.Handler
+		message string
+		window  time.Duration
+	}{
+		{"general", mw.RateLimitMiddleware(), "error.rateLimitExceeded", mw.cfg.RateLimit.GeneralWindow},
+		{"strict", mw.StrictRateLimitMiddleware(1, 30*time.Minute), "error.rateLimitExceeded.strict", 30 * time.Minute},
+	}
+	for _, limiter := range limiters {
+		t.Run(limiter.name, func(t *testing.T) {
+			testutil.Redis(t)
+			limited := limiter.limiter(http.HandlerFunc(handler))
+
+			var w *httptest.ResponseRecorder
+			for range 2 {
+				w = httptest.NewRecorder()
+				limited.ServeHTTP(w, httptest.NewRequest(http.MethodPost, "/contact", nil))
+			}
+			if w.Code != http.StatusTooManyRequests {
+				t.Fatalf("expected status 429, got %d", w.Code)
+			}
+
+			retryAfter := int(limiter.window.Seconds())
+			if w.Header().Get("Retry-After") != fmt.Sprint(retryAfter) || w.Header().Get("X-RateLimit-Limit") != "1" || w.Header().Get("X-RateLimit-Remaining") != "0" || w.Header().Get("X-RateLimit-Reset") == "" {
+				t.Fatalf("expected the Retry-After and X-RateLimit-* headers, got %v", w.Header())
+			}
+
+			var envelope struct {
+				Status    int    `json:"status"`
+				Success   *bool  `json:"success"`
+				Message   string `json:"message"`
+				Timestamp string `json:"timestamp"`
+				Data      struct {
+					Limit      int    `json:"limit"`
+					Window     string `json:"window"`
+					RetryAfter int    `json:"retry_after"`
+				} `json:"data"`
+			}
+			if err := json.Unmarshal(w.Body.Bytes(), &envelope); err != nil {
+				t.Fatalf("expected a JSON envelope, got %q: %v", w.Body.String(), err)
+			}
+			if envelope.Status != http.StatusTooManyRequests || envelope.Success == nil || *envelope.Success || envelope.Message != limiter.message || envelope.Timestamp == "" {
+				t.Fatalf("expected the standard error envelope, got %s", w.Body.String())
+			}
+			if data := envelope.Data; data.Limit != 1 || data.Window != limiter.window.String() || data.RetryAfter != retryAfter {
+				t.Fatalf("expected the limit, window and retry_after as data, got %s", w.Body.String())
+			}
+		})
+	}
+}
@@ -1,6 +1,7 @@
 package middleware
 
 import (
+	"mamabloemetjes_server/lib"
 	"net/http"
 
 	"github.com/MonkyMars/gecho"
@@ -46,7 +47,7 @@ func (mw *Middleware) CSRFMiddleware() func(http.Handler) http.Handler {
 			}
 			mw.logger.Debug("CSRF check - cookies received", gecho.Field("path", r.URL.Path), gecho.Field("cookies", cookieNames))
 
-			cookie, err := r.Cookie("csrf")
+			cookie, err := r.Cookie(lib.CSRFCookieName)
 			if err != nil {
 				mw.logger.Warn("CSRF cookie missing", gecho.Field("error", err), gecho.Field("path", r.URL.Path), gecho.Field("all_cookies", cookieNames))
 				gecho.Forbidden(w, gecho.WithMessage("invalid csrf token"), gecho.Send())
@@ -55,28 +56,45 @@ func (mw *Middleware) CSRFMiddleware() func(http.Handler) http.Handler {
 
 			token := r.Header.Get("X-CSRF-Token")
 			if token == "" {
-				mw.logger.Warn("CSRF header missing", gecho.Field("path", r.URL.Path), gecho.Field("cookie_value", cookie.Value[:min(10, len(cookie.Value))]))
-				http.Error(w, "invalid csrf token", http.StatusForbidden)
+				mw.logger.Warn("CSRF header missing", gecho.Field("path", r.URL.Path))
+				gecho.Forbidden(w, gecho.WithMessage("invalid csrf token"), gecho.Send())
 				return
 			}
 
-			if token != cookie.Value {
+			// Constant-time compare so the cookie cannot be guessed from response timings
+			if !lib.SecureCompare([]byte(token), []byte(cookie.Value)) {
 				mw.logger.Warn("CSRF token mismatch",
 					gecho.Field("path", r.URL.Path),
-					gecho.Field("header_full", token),
-					gecho.Field("cookie_full", cookie.Value),
 					gecho.Field("header_len", len(token)),
 					gecho.Field("cookie_len", len(cookie.Value)),
-					gecho.Field("match", token == cookie.Value),
-				)
-				mw.logger.Warn("CSRF mismatch details",
-					gecho.Field("header_bytes", []byte(token)),
-					gecho.Field("cookie_bytes", []byte(cookie.Value)),
 				)
-				http.Error(w, "invalid csrf token", http.StatusForbidden)
+				gecho.Forbidden(w, gecho.WithMessage("invalid csrf token"), gecho.Send())
 				return
 			}
 
+			// The token must have been issued to this session, a token taken from another session is rejected
+			if !lib.ValidCSRFToken(token, lib.CSRFSession(r)) {
+				mw.logger.Warn("CSRF token issued to another session", gecho.Field("path", r.URL.Path))
+				gecho.Forbidden(w, gecho.WithMessage("invalid csrf token"), gecho.Send())
+				return
+			}
+
+			// A signed-in session also keeps a hash of the token issued to it, so the token must be that one.
+			// Requests whose refresh token has no live session are left to the auth middleware
+			if jti, ok := lib.CSRFSessionJti(r); ok {
+				session, err := mw.authService.GetLiveSession(r.Context(), jti)
+				if err != nil && !lib.IsNotFound(err) {
+					mw.logger.Error("Failed to load session for CSRF check", gecho.Field("error", err), gecho.Field("path", r.URL.Path))
+					gecho.InternalServerError(w, gecho.WithMessage("error.internalServerError"), gecho.Send())
+					return
+				}
+				if session != nil && !lib.CSRFTokenMatchesHash(token, session.CSRFTokenHash) {
+					mw.logger.Warn("CSRF token does not match session", gecho.Field("path", r.URL.Path), gecho.Field("user_id", session.UserId))
+					gecho.Forbidden(w, gecho.WithMessage("invalid csrf token"), gecho.Send())
+					return
+				}
+			}
+
 			mw.logger.Info("CSRF token valid", gecho.Field("path", r.URL.Path))
 
 			next.ServeHTTP(w, r)
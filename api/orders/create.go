@@ -1,8 +1,11 @@
 package orders
 
 import (
+	"errors"
+	"fmt"
 	"mamabloemetjes_server/lib"
 	"mamabloemetjes_server/structs"
+	"math"
 	"net/http"
 
 	"github.com/MonkyMars/gecho"
@@ -14,7 +17,7 @@ func (orm *OrderRoutesManager) CreateOrder(w http.ResponseWriter, r *http.Reques
 	if err != nil {
 		gecho.BadRequest(w,
 			gecho.WithMessage("error.order.invalidRequestBody"),
-			gecho.WithData(err),
+			gecho.WithData(lib.ClientErrorData(err)),
 			gecho.Send(),
 		)
 		return
@@ -27,49 +30,89 @@ func (orm *OrderRoutesManager) CreateOrder(w http.ResponseWriter, r *http.Reques
 
 	// Check if user is authenticated (optional - for linking orders to user accounts)
 	var userId *uuid.UUID
-	if claims, err := lib.ExtractClaims(r); err == nil {
+	releaseOrderSlot := func() {}
+	claims, signedIn, err := orm.middleware.OptionalClaims(r)
+	if err != nil {
+		// Placing a signed-in user's order as a guest order would skip the per-account limit
+		gecho.ServiceUnavailable(w, gecho.WithMessage("error.serviceUnavailable"), gecho.Send())
+		return
+	}
+	if signedIn {
+		// Guests may check out, but a signed-in user must have verified their email first
+		if _, verified := orm.middleware.VerifiedUser(w, r, claims.Sub); !verified {
+			return
+		}
 		userId = &claims.Sub
+
+		// Guests are covered by the IP rate limiter; signed-in users also get a per-account limit.
+		// The slot is reserved up front and given back below if the order is not created
+		release, err := orm.orderService.ReserveUserOrder(claims.Sub, claims.Role)
+		if err != nil {
+			limit, window := orm.orderService.GetUserOrderLimit()
+			retryAfter := int(math.Ceil(orm.orderService.UserOrderRetryAfter(claims.Sub).Seconds()))
+			w.Header().Set("Retry-After", fmt.Sprintf("%d", max(retryAfter, 1)))
+			gecho.TooManyRequests(w,
+				gecho.WithMessage(lib.GetUserMessage(err)),
+				gecho.WithData(map[string]any{"limit": limit, "window": window.String()}),
+				gecho.Send(),
+			)
+			return
+		}
+		releaseOrderSlot = release
 	}
 
 	// Create order using service (handles validation, pricing snapshots, email sending)
 	order, err := orm.orderService.CreateOrderFromRequest(r.Context(), body, userId)
 	if err != nil {
-		// Check for specific business logic errors
-		errMsg := err.Error()
-		if errMsg == "product not found" ||
-			errMsg == "product is no longer available" ||
-			len(errMsg) > 0 && errMsg[:7] == "product" {
+		releaseOrderSlot()
+
+		var missingErr *lib.MissingProductsError
+		if errors.As(err, &missingErr) {
+			orm.logger.Warn("Order references unknown products", gecho.Field("error", err))
 			gecho.BadRequest(w,
-				gecho.WithMessage("error.order.productUnavailable"),
-				gecho.WithData(map[string]string{"error": err.Error()}),
+				gecho.WithMessage("error.order.productsNotFound"),
+				gecho.WithData(missingErr),
 				gecho.Send(),
 			)
 			return
 		}
 
-		gecho.InternalServerError(w,
-			gecho.WithMessage("error.order.creationFailed"),
-			gecho.WithData(map[string]string{"error": err.Error()}),
-			gecho.Send(),
-		)
-		return
-	}
+		var limitErr *lib.OrderLimitError
+		if errors.As(err, &limitErr) {
+			gecho.BadRequest(w,
+				gecho.WithMessage(lib.GetUserMessage(err)),
+				gecho.WithData(limitErr),
+				gecho.Send(),
+			)
+			return
+		}
 
-	// Send confirmation email to customer and shop owner
-	go func() {
-		err := orm.emailService.SendOrderConfirmationEmail(order.Order.Email, order.Order.Name, order.Order.OrderNumber, order.OrderLines, order.Address)
-		if err != nil {
-			orm.logger.Error("Failed to send order confirmation email",
-				gecho.Field("error", err),
-				gecho.Field("email", order.Order.Email),
-				gecho.Field("order_number", order.Order.OrderNumber),
+		var stockErr *lib.InsufficientStockError
+		if errors.As(err, &stockErr) {
+			orm.logger.Warn("Order lost a product to a concurrent order", gecho.Field("error", err))
+			gecho.Conflict(w,
+				gecho.WithMessage(lib.GetUserMessage(err)),
+				gecho.WithData(stockErr),
+				gecho.Send(),
+			)
+			return
+		}
+
+		if errors.Is(err, lib.ErrProductUnavailable) || errors.Is(err, lib.ErrMixedCurrencies) {
+			orm.logger.Warn("Order rejected for its products", gecho.Field("error", err))
+			gecho.BadRequest(w,
+				gecho.WithMessage(lib.GetUserMessage(err)),
+				gecho.Send(),
 			)
-		} else {
-			orm.logger.Info("Order confirmation email sent",
-				gecho.Field("order_number", order.Order.OrderNumber))
+			return
 		}
-	}()
 
+		orm.logger.Error("Failed to create order", gecho.Field("error", lib.GetDetailForLogging(err)))
+		lib.RespondServerError(w, err, "error.order.creationFailed")
+		return
+	}
+
+	// The confirmation email is sent by the order service once the order has committed
 	gecho.Success(w,
 		gecho.WithMessage("success.order.created"),
 		gecho.WithData(map[string]any{
@@ -0,0 +1,58 @@
+package orders
+
+import (
+	"encoding/json"
+	"mamabloemetjes_server/api/middleware"
+	"mamabloemetjes_server/lib"
+	"mamabloemetjes_server/services"
+	"mamabloemetjes_server/structs"
+	"mamabloemetjes_server/structs/tables"
+	"mamabloemetjes_server/testutil"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+
+	"github.com/google/uuid"
+)
+
+func TestCreateOrderRequiresVerifiedEmail(t *testing.T) {
+	testutil.Redis(t)
+	cfg := testutil.Config()
+	logger := testutil.Logger()
+
+	// The user is found in the cache, so the check is made before any query
+	cache := services.NewCacheService(logger, cfg)
+	authService := services.NewAuthService(cfg, logger, nil, cache)
+	orm := &OrderRoutesManager{middleware: middleware.NewMiddleware(cfg, logger, authService, cache, nil), logger: logger}
+
+	user := &tables.User{Id: uuid.New(), Username: "Jan", Email: "jan@example.com", Role: "user"}
+	if err := cache.SetUserInCache(user); err != nil {
+		t.Fatalf("SetUserInCache: %v", err)
+	}
+	if err := cache.SetTokenVersion(user.Id, user.TokenVersion); err != nil {
+		t.Fatalf("SetTokenVersion: %v", err)
+	}
+
+	body, err := json.Marshal(structs.OrderRequest{
+		Name:       "Jan Jansen",
+		Email:      "jan@example.com",
+		Phone:      "0612345678",
+		Street:     "Dorpsstraat",
+		HouseNo:    "1",
+		PostalCode: "1234 AB",
+		City:       "Utrecht",
+		Products:   map[string]int{uuid.NewString(): 1},
+	})
+	if err != nil {
+		t.Fatalf("failed to encode the request: %v", err)
+	}
+	r := httptest.NewRequest(http.MethodPost, "/orders/create", strings.NewReader(string(body)))
+	r.AddCookie(&http.Cookie{Name: lib.AccessCookieName, Value: accessToken(t, authService, user)})
+	w := httptest.NewRecorder()
+	orm.CreateOrder(w, r)
+
+	if w.Code != http.StatusForbidden || !strings.Contains(w.Body.String(), "error.auth.verifyEmail") {
+		t.Fatalf("expected an unverified user's order to be refused with 403, got %d: %s", w.Code, w.Body.String())
+	}
+}
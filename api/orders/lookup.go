@@ -0,0 +1,58 @@
+package orders
+
+import (
+	"mamabloemetjes_server/lib"
+	"net/http"
+	"strings"
+
+	"github.com/MonkyMars/gecho"
+)
+
+// LookupOrder returns order details for guests who know both the order number and the email used at checkout
+func (orm *OrderRoutesManager) LookupOrder(w http.ResponseWriter, r *http.Request) {
+	orderNumber := strings.TrimSpace(r.URL.Query().Get("number"))
+	email := strings.TrimSpace(r.URL.Query().Get("email"))
+	if orderNumber == "" || email == "" {
+		gecho.BadRequest(w,
+			gecho.WithMessage("error.order.lookupParamsRequired"),
+			gecho.Send(),
+		)
+		return
+	}
+
+	order, address, err := orm.orderService.LookupOrder(r.Context(), orderNumber, email)
+	if err != nil {
+		if lib.IsNotFound(err) {
+			gecho.NotFound(w,
+				gecho.WithMessage("error.order.notFound"),
+				gecho.Send(),
+			)
+			return
+		}
+		orm.logger.Error("Failed to look up order", gecho.Field("error", lib.GetDetailForLogging(err)))
+		lib.RespondServerError(w, err, "error.order.fetchingOrder")
+		return
+	}
+
+	// Get order lines
+	orderLines, err := orm.orderService.GetOrderLinesWithProducts(r.Context(), order.Id)
+	if err != nil {
+		orm.logger.Error("Failed to get order lines",
+			gecho.Field("error", lib.GetDetailForLogging(err)),
+			gecho.Field("order_id", order.Id))
+		lib.RespondServerError(w, err, "error.order.fetchingOrderLines")
+		return
+	}
+
+	gecho.Success(w,
+		gecho.WithMessage("success.order.orderDetailsFetched"),
+		gecho.WithData(map[string]any{
+			"order":       order,
+			"order_lines": orderLines,
+			"address":     address,
+			"total":       order.Total,
+			"totals":      order.Totals(orderLines),
+		}),
+		gecho.Send(),
+	)
+}
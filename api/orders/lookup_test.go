@@ -0,0 +1,171 @@
+package orders
+
+import (
+	"context"
+	"encoding/json"
+	"mamabloemetjes_server/services"
+	"mamabloemetjes_server/structs"
+	"mamabloemetjes_server/structs/tables"
+	"mamabloemetjes_server/testutil"
+	"net/http"
+	"net/http/httptest"
+	"net/url"
+	"strings"
+	"testing"
+
+	"github.com/google/uuid"
+)
+
+// lookupOrder calls the lookup handler with the given order number and email
+func lookupOrder(orm *OrderRoutesManager, number, email string) *httptest.ResponseRecorder {
+	query := url.Values{"number": {number}, "email": {email}}
+	r := httptest.NewRequest(http.MethodGet, "/orders/lookup?"+query.Encode(), nil)
+	w := httptest.NewRecorder()
+	orm.LookupOrder(w, r)
+	return w
+}
+
+func TestLookupOrderRequiresNumberAndEmail(t *testing.T) {
+	orm := &OrderRoutesManager{logger: testutil.Logger()}
+
+	for _, params := range [][2]string{{"", "jan@example.com"}, {"MB-2026-0001", ""}, {"  ", "  "}} {
+		if w := lookupOrder(orm, params[0], params[1]); w.Code != http.StatusBadRequest {
+			t.Fatalf("expected status 400 for %q, got %d", params, w.Code)
+		}
+	}
+}
+
+func TestLookupOrderMatchesEmail(t *testing.T) {
+	db := testutil.DB(t)
+	testutil.Redis(t)
+	cfg := testutil.Config()
+	logger := testutil.Logger()
+	ctx := context.Background()
+
+	cache := services.NewCacheService(logger, cfg)
+	authService := services.NewAuthService(cfg, logger, db, cache)
+	productService := services.NewProductService(logger, cfg, db, cache)
+	orderService := services.NewOrderService(logger, cfg, db, productService, services.NewEmailService(logger, cfg, db, authService))
+	orm := &OrderRoutesManager{logger: logger, productService: productService, orderService: orderService}
+
+	product := &tables.Product{
+		ID:          uuid.New(),
+		Name:        "Rozenboeket",
+		SKU:         "SKU-LOOKUP",
+		Price:       2500,
+		Subtotal:    2500,
+		Currency:    "EUR",
+		Description: "A bouquet of red roses",
+		IsActive:    true,
+		MadeToOrder: true,
+	}
+	if _, err := db.NewInsert().Model(product).Exec(ctx); err != nil {
+		t.Fatalf("failed to seed product: %v", err)
+	}
+	created, err := orderService.CreateOrderFromRequest(ctx, &structs.OrderRequest{
+		Name:       "Jan Jansen",
+		Email:      "jan@example.com",
+		Phone:      "0612345678",
+		Street:     "Dorpsstraat",
+		HouseNo:    "1",
+		PostalCode: "1234 AB",
+		City:       "Utrecht",
+		Country:    "NL",
+		Products:   map[string]int{product.ID.String(): 2},
+	}, nil)
+	if err != nil {
+		t.Fatalf("CreateOrderFromRequest: %v", err)
+	}
+	number := created.Order.OrderNumber
+
+	// The email is compared after normalizing, like at sign-in
+	w := lookupOrder(orm, number, " Jan@Example.com ")
+	if w.Code != http.StatusOK {
+		t.Fatalf("expected status 200 for the matching email, got %d: %s", w.Code, w.Body.String())
+	}
+	var response struct {
+		Data struct {
+			Order      tables.Order       `json:"order"`
+			OrderLines []json.RawMessage  `json:"order_lines"`
+			Address    tables.Address     `json:"address"`
+			Totals     tables.OrderTotals `json:"totals"`
+		} `json:"data"`
+	}
+	if err := json.Unmarshal(w.Body.Bytes(), &response); err != nil {
+		t.Fatalf("failed to decode the order: %v", err)
+	}
+	details := response.Data
+	if details.Order.OrderNumber != number || len(details.OrderLines) != 1 || details.Address.City != "Utrecht" || details.Totals.Due != 5495 {
+		t.Fatalf("expected the details of order %s, got %s", number, w.Body.String())
+	}
+
+	// A wrong email looks exactly like an unknown order number
+	mismatch := lookupOrder(orm, number, "piet@example.com")
+	unknown := lookupOrder(orm, "MB-0000-0000", "jan@example.com")
+	if mismatch.Code != http.StatusNotFound || unknown.Code != http.StatusNotFound {
+		t.Fatalf("expected status 404 for a wrong email and an unknown number, got %d and %d", mismatch.Code, unknown.Code)
+	}
+	if strings.Contains(mismatch.Body.String(), number) {
+		t.Fatalf("expected no order details for a wrong email, got %s", mismatch.Body.String())
+	}
+}
+
+func TestLookupOrderQueryBudget(t *testing.T) {
+	db := testutil.DB(t)
+	testutil.Redis(t)
+	cfg := testutil.Config()
+	logger := testutil.Logger()
+	ctx := context.Background()
+
+	cache := services.NewCacheService(logger, cfg)
+	authService := services.NewAuthService(cfg, logger, db, cache)
+	productService := services.NewProductService(logger, cfg, db, cache)
+	orderService := services.NewOrderService(logger, cfg, db, productService, services.NewEmailService(logger, cfg, db, authService))
+	orm := &OrderRoutesManager{logger: logger, productService: productService, orderService: orderService}
+
+	products := map[string]int{}
+	for _, name := range []string{"Rozenboeket", "Tulpenboeket", "Pioenboeket"} {
+		product := &tables.Product{
+			ID:          uuid.New(),
+			Name:        name,
+			SKU:         "SKU-" + strings.ToUpper(name[:5]),
+			Price:       2500,
+			Subtotal:    2500,
+			Currency:    "EUR",
+			Description: "A hand-tied bouquet of seasonal flowers",
+			IsActive:    true,
+			MadeToOrder: true,
+			Images:      []tables.ProductImage{{ID: uuid.New(), URL: "https://images.example.com/" + name + ".jpg", IsPrimary: true}},
+		}
+		if _, err := db.NewInsert().Model(product).Exec(ctx); err != nil {
+			t.Fatalf("failed to seed product: %v", err)
+		}
+		product.Images[0].ProductID = product.ID
+		if _, err := db.NewInsert().Model(&product.Images).Exec(ctx); err != nil {
+			t.Fatalf("failed to seed product image: %v", err)
+		}
+		products[product.ID.String()] = 1
+	}
+	created, err := orderService.CreateOrderFromRequest(ctx, &structs.OrderRequest{
+		Name:       "Jan Jansen",
+		Email:      "jan@example.com",
+		Phone:      "0612345678",
+		Street:     "Dorpsstraat",
+		HouseNo:    "1",
+		PostalCode: "1234 AB",
+		City:       "Utrecht",
+		Country:    "NL",
+		Products:   products,
+	}, nil)
+	if err != nil {
+		t.Fatalf("CreateOrderFromRequest: %v", err)
+	}
+
+	// The order, its address, the lines with their products and the primary images, however many lines there are
+	query := url.Values{"number": {created.Order.OrderNumber}, "email": {"jan@example.com"}}
+	r := httptest.NewRequest(http.MethodGet, "/orders/lookup?"+query.Encode(), nil)
+	w := testutil.ServeWithQueryBudget(t, http.HandlerFunc(orm.LookupOrder), r, 4)
+	if w.Code != http.StatusOK {
+		t.Fatalf("expected status 200, got %d: %s", w.Code, w.Body.String())
+	}
+}
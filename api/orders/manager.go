@@ -29,10 +29,13 @@ func NewOrderRoutesManager(productService *services.ProductService, orderService
 func (orm *OrderRoutesManager) RegisterRoutes(r chi.Router) {
 	r.Route("/orders", func(r chi.Router) {
 		r.Post("/create", orm.CreateOrder)
+		r.Get("/lookup", orm.LookupOrder) // Guest access by order number and email
 		r.Route("/", func(r chi.Router) {
 			r.Use(orm.middleware.UserAuthMiddleware)
+			r.Use(orm.middleware.RequireVerifiedEmail)
 			r.Get("/my-orders", orm.GetMyOrders)         // Requires authentication
 			r.Get("/my-orders/{id}", orm.GetMyOrderById) // Get specific order details
+			r.Get("/{id}/status", orm.GetMyOrderStatus)  // Lightweight status for polling after payment
 		})
 	})
 }
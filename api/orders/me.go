@@ -1,6 +1,8 @@
 package orders
 
 import (
+	"errors"
+	"fmt"
 	"mamabloemetjes_server/lib"
 	"net/http"
 
@@ -26,11 +28,10 @@ func (orm *OrderRoutesManager) GetMyOrders(w http.ResponseWriter, r *http.Reques
 	// Get orders for user
 	orders, err := orm.orderService.GetOrdersByUserId(r.Context(), claims.Sub)
 	if err != nil {
-		gecho.InternalServerError(w,
-			gecho.WithMessage("error.order.fetchingOrders"),
-			gecho.WithData(map[string]string{"error": err.Error()}),
-			gecho.Send(),
-		)
+		orm.logger.Error("Failed to get orders for user",
+			gecho.Field("error", lib.GetDetailForLogging(err)),
+			gecho.Field("user_id", claims.Sub))
+		lib.RespondServerError(w, err, "error.order.fetchingOrders")
 		return
 	}
 
@@ -57,87 +58,104 @@ func (orm *OrderRoutesManager) GetMyOrderById(w http.ResponseWriter, r *http.Req
 	}
 
 	// Get order ID from URL
-	orderIdStr := chi.URLParam(r, "id")
-	orderId, err := uuid.Parse(orderIdStr)
+	orderId, err := lib.ParseUUIDParam(r, "id")
 	if err != nil {
-		orm.logger.Warn("Invalid order ID format", gecho.Field("order_id", orderIdStr))
-		gecho.BadRequest(w,
-			gecho.WithMessage("error.order.invalidOrderId"),
-			gecho.Send(),
-		)
+		orm.logger.Warn("Invalid order ID format", gecho.Field("order_id", chi.URLParam(r, "id")))
+		lib.RespondInvalidUUID(w, err, "error.order.invalidOrderId")
 		return
 	}
 
 	orm.logger.Info("Fetching order details for user", gecho.Field("user_id", claims.Sub), gecho.Field("order_id", orderId))
 
-	// Get order
-	order, err := orm.orderService.GetOrderById(r.Context(), orderId)
+	// Get order, verifying that it belongs to this user (via address)
+	order, address, err := orm.orderService.GetOwnedOrder(r.Context(), orderId, claims.Sub)
 	if err != nil {
-		orm.logger.Error("Failed to get order", gecho.Field("error", err), gecho.Field("order_id", orderId))
-		gecho.NotFound(w,
-			gecho.WithMessage("error.order.notFound"),
-			gecho.WithData(map[string]string{"error": err.Error()}),
-			gecho.Send(),
-		)
+		orm.respondOrderAccessError(w, err, orderId)
+		return
+	}
+
+	// Lines are only loaded when the client's copy is out of date
+	if lib.NotModified(w, r, order.UpdatedAt) {
 		return
 	}
 
-	// Get address first to verify ownership
-	address, err := orm.orderService.GetAddressById(r.Context(), order.AddressId)
+	// Get order lines
+	orderLines, err := orm.orderService.GetOrderLinesWithProducts(r.Context(), orderId)
 	if err != nil {
-		orm.logger.Error("Failed to get address",
-			gecho.Field("error", err),
-			gecho.Field("address_id", order.AddressId))
-		gecho.InternalServerError(w,
-			gecho.WithMessage("error.order.fetchingAddress"),
-			gecho.WithData(map[string]string{"error": err.Error()}),
-			gecho.Send(),
-		)
+		orm.logger.Error("Failed to get order lines",
+			gecho.Field("error", lib.GetDetailForLogging(err)),
+			gecho.Field("order_id", orderId))
+		lib.RespondServerError(w, err, "error.order.fetchingOrderLines")
 		return
 	}
 
-	// Verify that the order belongs to this user (via address)
-	if address.UserId == nil || *address.UserId != claims.Sub {
-		orm.logger.Warn("User attempted to access order they don't own",
-			gecho.Field("user_id", claims.Sub),
-			gecho.Field("order_id", orderId),
-			gecho.Field("address_user_id", address.UserId),
-		)
+	totals := order.Totals(orderLines)
+
+	gecho.Success(w,
+		gecho.WithMessage("success.order.orderDetailsFetched"),
+		gecho.WithData(map[string]interface{}{
+			"order":       order,
+			"order_lines": orderLines,
+			"address":     address,
+			"total":       totals.Total,
+			"totals":      totals,
+		}),
+		gecho.Send(),
+	)
+}
+
+// respondOrderAccessError writes the response for a failed owned-order lookup, following the configured ownership policy
+func (orm *OrderRoutesManager) respondOrderAccessError(w http.ResponseWriter, err error, orderId uuid.UUID) {
+	switch {
+	case errors.Is(err, lib.ErrOrderNotOwned):
 		gecho.Forbidden(w,
 			gecho.WithMessage("error.auth.accessDenied"),
 			gecho.Send(),
 		)
-		return
+	case lib.IsNotFound(err):
+		gecho.NotFound(w,
+			gecho.WithMessage("error.order.notFound"),
+			gecho.Send(),
+		)
+	default:
+		orm.logger.Error("Failed to get order", gecho.Field("error", lib.GetDetailForLogging(err)), gecho.Field("order_id", orderId))
+		lib.RespondServerError(w, err, "error.order.fetchingOrder")
 	}
+}
 
-	// Get order lines
-	orderLines, err := orm.orderService.GetOrderLinesByOrderId(r.Context(), orderId)
+// GetMyOrderStatus returns only the status, payment status and last update of an order owned by the authenticated user.
+// Clients poll it after paying, so it is cached briefly and answers 304 when their ETag is still current
+func (orm *OrderRoutesManager) GetMyOrderStatus(w http.ResponseWriter, r *http.Request) {
+	claims, err := lib.ExtractClaims(r)
 	if err != nil {
-		orm.logger.Error("Failed to get order lines",
-			gecho.Field("error", err),
-			gecho.Field("order_id", orderId))
-		gecho.InternalServerError(w,
-			gecho.WithMessage("error.order.fetchingOrderLines"),
-			gecho.WithData(map[string]string{"error": err.Error()}),
+		orm.logger.Warn("Failed to extract claims in GetMyOrderStatus", gecho.Field("error", err))
+		gecho.Unauthorized(w,
+			gecho.WithMessage("error.auth.invalidOrMissingAccessToken"),
 			gecho.Send(),
 		)
 		return
 	}
 
-	// Calculate total
-	var total uint64
-	for _, line := range orderLines {
-		total += line.LineTotal
+	orderId, err := lib.ParseUUIDParam(r, "id")
+	if err != nil {
+		lib.RespondInvalidUUID(w, err, "error.order.invalidOrderId")
+		return
+	}
+
+	status, err := orm.orderService.GetOwnedOrderStatus(r.Context(), orderId, claims.Sub)
+	if err != nil {
+		orm.respondOrderAccessError(w, err, orderId)
+		return
+	}
+
+	etag := fmt.Sprintf(`"%s-%s-%d"`, status.Status, status.PaymentStatus, status.UpdatedAt.UnixMicro())
+	if lib.NotModifiedETag(w, r, etag) {
+		return
 	}
 
 	gecho.Success(w,
-		gecho.WithMessage("success.order.orderDetailsFetched"),
-		gecho.WithData(map[string]interface{}{
-			"order":       order,
-			"order_lines": orderLines,
-			"address":     address,
-			"total":       total,
-		}),
+		gecho.WithMessage("success.order.statusFetched"),
+		gecho.WithData(status),
 		gecho.Send(),
 	)
 }
@@ -0,0 +1,197 @@
+package orders
+
+import (
+	"context"
+	"encoding/json"
+	"fmt"
+	"mamabloemetjes_server/lib"
+	"mamabloemetjes_server/services"
+	"mamabloemetjes_server/structs"
+	"mamabloemetjes_server/structs/tables"
+	"mamabloemetjes_server/testutil"
+	"net/http"
+	"net/http/httptest"
+	"slices"
+	"strings"
+	"testing"
+
+	"github.com/go-chi/chi/v5"
+	"github.com/google/uuid"
+)
+
+func TestRespondOrderAccessError(t *testing.T) {
+	orm := &OrderRoutesManager{logger: testutil.Logger()}
+
+	tests := []struct {
+		name    string
+		err     error
+		status  int
+		message string
+	}{
+		{"foreign order under the forbidden policy", lib.ErrOrderNotOwned, http.StatusForbidden, ""},
+		{"foreign order under the not found policy", lib.ErrNotFound, http.StatusNotFound, ""},
+		{"unexpected error", fmt.Errorf("read tcp 10.0.0.2:5432: connection reset"), http.StatusInternalServerError, "error.order.fetchingOrder"},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			w := httptest.NewRecorder()
+			orm.respondOrderAccessError(w, tt.err, uuid.New())
+			if w.Code != tt.status {
+				t.Fatalf("expected status %d, got %d", tt.status, w.Code)
+			}
+			body := w.Body.String()
+			if !strings.Contains(body, tt.message) || strings.Contains(body, tt.err.Error()) {
+				t.Fatalf("expected the body to carry %q and not the raw error, got %s", tt.message, body)
+			}
+		})
+	}
+}
+
+// accessToken signs an access token for user
+func accessToken(t *testing.T, authService *services.AuthService, user *tables.User) string {
+	t.Helper()
+	token, err := authService.GenerateAccessToken(user)
+	if err != nil {
+		t.Fatalf("GenerateAccessToken: %v", err)
+	}
+	return token
+}
+
+// orderStatusRequest returns a status poll for order id signed in with token, with ifNoneMatch as the client's ETag when set
+func orderStatusRequest(token, id, ifNoneMatch string) *http.Request {
+	r := httptest.NewRequest(http.MethodGet, "/orders/"+id+"/status", nil)
+	if token != "" {
+		r.AddCookie(&http.Cookie{Name: lib.AccessCookieName, Value: token})
+	}
+	if ifNoneMatch != "" {
+		r.Header.Set("If-None-Match", ifNoneMatch)
+	}
+	routeCtx := chi.NewRouteContext()
+	routeCtx.URLParams.Add("id", id)
+	return r.WithContext(context.WithValue(r.Context(), chi.RouteCtxKey, routeCtx))
+}
+
+func TestGetMyOrderStatusValidation(t *testing.T) {
+	// Invalid requests are rejected before the services are reached
+	cfg := testutil.Config()
+	orm := &OrderRoutesManager{logger: testutil.Logger()}
+	token := accessToken(t, services.NewAuthService(cfg, testutil.Logger(), nil, nil), &tables.User{Id: uuid.New(), Role: "user"})
+
+	w := httptest.NewRecorder()
+	orm.GetMyOrderStatus(w, orderStatusRequest(token, "not-a-uuid", ""))
+	if w.Code != http.StatusBadRequest {
+		t.Fatalf("expected status 400 for an invalid id, got %d", w.Code)
+	}
+
+	w = httptest.NewRecorder()
+	orm.GetMyOrderStatus(w, orderStatusRequest("", uuid.NewString(), ""))
+	if w.Code != http.StatusUnauthorized {
+		t.Fatalf("expected status 401 without an access token, got %d", w.Code)
+	}
+}
+
+func TestGetMyOrderStatus(t *testing.T) {
+	db := testutil.DB(t)
+	testutil.Redis(t)
+	cfg := testutil.Config()
+	logger := testutil.Logger()
+	ctx := context.Background()
+
+	cache := services.NewCacheService(logger, cfg)
+	authService := services.NewAuthService(cfg, logger, db, cache)
+	productService := services.NewProductService(logger, cfg, db, cache)
+	orderService := services.NewOrderService(logger, cfg, db, productService, services.NewEmailService(logger, cfg, db, authService))
+	orm := &OrderRoutesManager{logger: logger, productService: productService, orderService: orderService}
+
+	user, err := authService.Register(&structs.RegisterRequest{Username: "Jan Jansen", Email: "jan@example.com", Password: "correct horse battery"})
+	if err != nil {
+		t.Fatalf("Register: %v", err)
+	}
+	product := &tables.Product{
+		ID:          uuid.New(),
+		Name:        "Rozenboeket",
+		SKU:         "SKU-STATUS",
+		Price:       2500,
+		Subtotal:    2500,
+		Currency:    "EUR",
+		Description: "A bouquet of red roses",
+		IsActive:    true,
+		MadeToOrder: true,
+	}
+	if _, err := db.NewInsert().Model(product).Exec(ctx); err != nil {
+		t.Fatalf("failed to seed product: %v", err)
+	}
+	created, err := orderService.CreateOrderFromRequest(ctx, &structs.OrderRequest{
+		Name:       "Jan Jansen",
+		Email:      "jan@example.com",
+		Phone:      "0612345678",
+		Street:     "Dorpsstraat",
+		HouseNo:    "1",
+		PostalCode: "1234 AB",
+		City:       "Utrecht",
+		Country:    "NL",
+		Products:   map[string]int{product.ID.String(): 1},
+	}, &user.Id)
+	if err != nil {
+		t.Fatalf("CreateOrderFromRequest: %v", err)
+	}
+	orderId := created.Order.Id.String()
+	token := accessToken(t, authService, user)
+
+	w := httptest.NewRecorder()
+	orm.GetMyOrderStatus(w, orderStatusRequest(token, orderId, ""))
+	if w.Code != http.StatusOK {
+		t.Fatalf("expected status 200, got %d: %s", w.Code, w.Body.String())
+	}
+	var response struct {
+		Data map[string]json.RawMessage `json:"data"`
+	}
+	if err := json.Unmarshal(w.Body.Bytes(), &response); err != nil {
+		t.Fatalf("failed to decode the status: %v", err)
+	}
+	fields := make([]string, 0, len(response.Data))
+	for field := range response.Data {
+		fields = append(fields, field)
+	}
+	slices.Sort(fields)
+	if !slices.Equal(fields, []string{"order_id", "payment_status", "status", "updated_at"}) {
+		t.Fatalf("expected only the status fields, got %s", w.Body.String())
+	}
+	if string(response.Data["status"]) != `"pending"` {
+		t.Fatalf("expected a pending order, got %s", response.Data["status"])
+	}
+
+	// A client with the current ETag gets an empty 304
+	etag := w.Header().Get("ETag")
+	if etag == "" {
+		t.Fatal("expected an ETag on the status")
+	}
+	w = httptest.NewRecorder()
+	orm.GetMyOrderStatus(w, orderStatusRequest(token, orderId, etag))
+	if w.Code != http.StatusNotModified || w.Body.Len() != 0 {
+		t.Fatalf("expected an empty 304 for the current ETag, got %d: %s", w.Code, w.Body.String())
+	}
+
+	// Someone else's order looks like an unknown one, or is forbidden under that policy
+	stranger, err := authService.Register(&structs.RegisterRequest{Username: "Piet Pietersen", Email: "piet@example.com", Password: "correct horse battery"})
+	if err != nil {
+		t.Fatalf("Register: %v", err)
+	}
+	strangerToken := accessToken(t, authService, stranger)
+	w = httptest.NewRecorder()
+	orm.GetMyOrderStatus(w, orderStatusRequest(strangerToken, orderId, ""))
+	if w.Code != http.StatusNotFound || strings.Contains(w.Body.String(), "pending") {
+		t.Fatalf("expected status 404 without the order status, got %d: %s", w.Code, w.Body.String())
+	}
+
+	forbiddenCfg := *cfg
+	ordersCfg := *forbiddenCfg.Orders
+	ordersCfg.OwnershipPolicy = "forbidden"
+	forbiddenCfg.Orders = &ordersCfg
+	forbidden := &OrderRoutesManager{logger: logger, productService: productService, orderService: services.NewOrderService(logger, &forbiddenCfg, db, productService, nil)}
+	w = httptest.NewRecorder()
+	forbidden.GetMyOrderStatus(w, orderStatusRequest(strangerToken, orderId, ""))
+	if w.Code != http.StatusForbidden {
+		t.Fatalf("expected status 403 under the forbidden policy, got %d", w.Code)
+	}
+}
@@ -1,8 +1,10 @@
 package products
 
 import (
+	"errors"
 	"mamabloemetjes_server/handling"
 	"mamabloemetjes_server/lib"
+	"mamabloemetjes_server/services"
 	"net/http"
 	"strconv"
 
@@ -21,12 +23,13 @@ func (p *ProductRoutesManager) FetchAllProducts(w http.ResponseWriter, r *http.R
 		p.logger.Warn("Invalid query parameters", "error", err)
 		gecho.BadRequest(w,
 			gecho.WithMessage("error.invalidQueryParameters"),
-			gecho.WithData(err.Error()),
 			gecho.Send(),
 		)
 		return
 	}
 
+	opts.IncludeUnavailable = p.isPreviewRequest(r)
+
 	// Log the request
 	p.logger.Debug("Fetching products",
 		gecho.Field("include_images", opts.IncludeImages),
@@ -37,26 +40,17 @@ func (p *ProductRoutesManager) FetchAllProducts(w http.ResponseWriter, r *http.R
 	// Fetch products using the service
 	result, err := p.productService.GetAllProducts(ctx, opts)
 	if err != nil {
-		p.logger.Error("Failed to fetch products", "error", err)
-		gecho.InternalServerError(w,
-			gecho.WithMessage("error.products.failedToFetch"),
-			gecho.WithData(err.Error()),
-			gecho.Send(),
-		)
+		if respondInvalidFilters(w, err) {
+			return
+		}
+		p.logger.Error("Failed to fetch products", "error", lib.GetDetailForLogging(err))
+		lib.RespondServerError(w, err, "error.products.failedToFetch")
 		return
 	}
 
 	// Return successful response with metadata
 	gecho.Success(w,
-		gecho.WithData(map[string]any{
-			"products":   result.Products,
-			"pagination": result.Pagination,
-			"filters":    result.Filters,
-			"meta": map[string]any{
-				"query_time_ms": result.QueryTime.Milliseconds(),
-				"count":         len(result.Products),
-			},
-		}),
+		gecho.WithData(productListData(r, result)),
 		gecho.Send(),
 	)
 }
@@ -65,17 +59,11 @@ func (p *ProductRoutesManager) FetchAllProducts(w http.ResponseWriter, r *http.R
 func (p *ProductRoutesManager) FetchProductByID(w http.ResponseWriter, r *http.Request) {
 	ctx := r.Context()
 
-	// Get ID from URL parameter using chi
-	idStr := chi.URLParam(r, "id")
-
-	// Validate and parse ID
-	id, err := uuid.Parse(idStr)
+	// Validate and parse ID from the URL
+	id, err := lib.ParseUUIDParam(r, "id")
 	if err != nil {
-		p.logger.Warn("Invalid product ID format", "id", idStr, "error", err)
-		gecho.BadRequest(w,
-			gecho.WithMessage("error.products.invalidProductId"),
-			gecho.Send(),
-		)
+		p.logger.Warn("Invalid product ID format", "id", chi.URLParam(r, "id"), "error", err)
+		lib.RespondInvalidUUID(w, err, "error.products.invalidProductId")
 		return
 	}
 
@@ -93,9 +81,9 @@ func (p *ProductRoutesManager) FetchProductByID(w http.ResponseWriter, r *http.R
 	includeImages := r.URL.Query().Get("include_images") == "true"
 
 	// Fetch product using the service
-	product, err := p.productService.GetProductByID(ctx, id, includeImages)
+	product, err := p.productService.GetProductByID(ctx, id, includeImages, p.isAdminRequest(r))
 	if err != nil {
-		if err.Error() == "product not found" {
+		if lib.IsNotFound(err) {
 			gecho.NotFound(w,
 				gecho.WithMessage("error.products.notFound"),
 				gecho.Send(),
@@ -103,15 +91,16 @@ func (p *ProductRoutesManager) FetchProductByID(w http.ResponseWriter, r *http.R
 			return
 		}
 
-		p.logger.Error("Failed to fetch product by ID", "id", id, "error", err)
-		gecho.InternalServerError(w,
-			gecho.WithMessage("error.products.failedToFetchOne"),
-			gecho.WithData(err.Error()),
-			gecho.Send(),
-		)
+		p.logger.Error("Failed to fetch product by ID", "id", id, "error", lib.GetDetailForLogging(err))
+		lib.RespondServerError(w, err, "error.products.failedToFetchOne")
 		return
 	}
 
+	// Track the view for trending (best-effort, HEAD probes don't count)
+	if r.Method == http.MethodGet {
+		p.productService.RecordView(ctx, product.ID)
+	}
+
 	// Return successful response
 	gecho.Success(w,
 		gecho.WithData(map[string]any{
@@ -125,50 +114,29 @@ func (p *ProductRoutesManager) FetchProductByID(w http.ResponseWriter, r *http.R
 func (p *ProductRoutesManager) FetchActiveProducts(w http.ResponseWriter, r *http.Request) {
 	ctx := r.Context()
 
-	// Parse pagination parameters
-	page := 1
-	pageSize := 20
-
-	if pageStr := lib.SanitizeString(r.URL.Query().Get("page"), true, false); pageStr != "" {
-		if val, err := strconv.Atoi(pageStr); err == nil && val > 0 {
-			page = val
-		}
-	}
-
-	if pageSizeStr := lib.SanitizeString(r.URL.Query().Get("page_size"), true, false); pageSizeStr != "" {
-		if val, err := strconv.Atoi(pageSizeStr); err == nil && val > 0 {
-			pageSize = val
-		}
+	// Parse query parameters; only pagination, product_type and include_images apply here
+	opts, err := handling.ParseProductListOptions(r)
+	if err != nil {
+		p.logger.Warn("Invalid query parameters", "error", err)
+		gecho.BadRequest(w,
+			gecho.WithMessage("error.invalidQueryParameters"),
+			gecho.Send(),
+		)
+		return
 	}
 
-	productType := lib.SanitizeString(r.URL.Query().Get("product_type"), true, false)
-
-	// Check if images should be included
-	includeImages := lib.SanitizeString(r.URL.Query().Get("include_images"), true, false) == "true"
-
 	// Fetch active products using the service
-	result, err := p.productService.GetActiveProducts(ctx, page, pageSize, includeImages, productType)
+	scope := services.ProductScope{IncludeUnavailable: p.isPreviewRequest(r)}
+	result, err := p.productService.GetActiveProducts(ctx, opts.Page, opts.PageSize, opts.IncludeImages, opts.ProductType, scope)
 	if err != nil {
-		p.logger.Error("Failed to fetch active products", "error", err)
-		gecho.InternalServerError(w,
-			gecho.WithMessage("error.products.failedToFetchActive"),
-			gecho.WithData(err.Error()),
-			gecho.Send(),
-		)
+		p.logger.Error("Failed to fetch active products", "error", lib.GetDetailForLogging(err))
+		lib.RespondServerError(w, err, "error.products.failedToFetchActive")
 		return
 	}
 
 	// Return successful response with metadata
 	gecho.Success(w,
-		gecho.WithData(map[string]any{
-			"products":   result.Products,
-			"pagination": result.Pagination,
-			"filters":    result.Filters,
-			"meta": map[string]any{
-				"query_time_ms": result.QueryTime.Milliseconds(),
-				"count":         len(result.Products),
-			},
-		}),
+		gecho.WithData(productListData(r, result)),
 		gecho.Send(),
 	)
 }
@@ -183,21 +151,21 @@ func (p *ProductRoutesManager) GetProductCount(w http.ResponseWriter, r *http.Re
 		p.logger.Warn("Invalid query parameters", "error", err)
 		gecho.BadRequest(w,
 			gecho.WithMessage("error.invalidQueryParameters"),
-			gecho.WithData(err.Error()),
 			gecho.Send(),
 		)
 		return
 	}
 
+	opts.IncludeUnavailable = p.isPreviewRequest(r)
+
 	// Get count using the service
 	count, err := p.productService.GetProductCount(ctx, opts)
 	if err != nil {
-		p.logger.Error("Failed to count products", "error", err)
-		gecho.InternalServerError(w,
-			gecho.WithMessage("error.products.failedToCount"),
-			gecho.WithData(err.Error()),
-			gecho.Send(),
-		)
+		if respondInvalidFilters(w, err) {
+			return
+		}
+		p.logger.Error("Failed to count products", "error", lib.GetDetailForLogging(err))
+		lib.RespondServerError(w, err, "error.products.failedToCount")
 		return
 	}
 
@@ -210,3 +178,158 @@ func (p *ProductRoutesManager) GetProductCount(w http.ResponseWriter, r *http.Re
 		gecho.Send(),
 	)
 }
+
+// GetProductStats handles GET /products/stats, the lightweight figures shown on the homepage
+func (p *ProductRoutesManager) GetProductStats(w http.ResponseWriter, r *http.Request) {
+	count, err := p.productService.GetActiveProductCount(r.Context())
+	if err != nil {
+		p.logger.Error("Failed to count active products", "error", lib.GetDetailForLogging(err))
+		lib.RespondServerError(w, err, "error.products.failedToCount")
+		return
+	}
+
+	gecho.Success(w,
+		gecho.WithData(map[string]any{
+			"active_count": count,
+		}),
+		gecho.Send(),
+	)
+}
+
+// FetchTrendingProducts handles GET /products/trending to fetch the most viewed active products
+func (p *ProductRoutesManager) FetchTrendingProducts(w http.ResponseWriter, r *http.Request) {
+	ctx := r.Context()
+
+	limit := 10
+	if limitStr := r.URL.Query().Get("limit"); limitStr != "" {
+		if val, err := strconv.Atoi(limitStr); err == nil && val > 0 {
+			limit = min(val, 50)
+		}
+	}
+
+	products, err := p.productService.GetTrendingProducts(ctx, limit)
+	if err != nil {
+		p.logger.Error("Failed to fetch trending products", "error", lib.GetDetailForLogging(err))
+		lib.RespondServerError(w, err, "error.products.failedToFetchTrending")
+		return
+	}
+
+	gecho.Success(w,
+		gecho.WithData(map[string]any{
+			"products": products,
+			"count":    len(products),
+		}),
+		gecho.Send(),
+	)
+}
+
+// FetchRelatedProducts handles GET /products/{id}/related to fetch active products similar to the given one
+func (p *ProductRoutesManager) FetchRelatedProducts(w http.ResponseWriter, r *http.Request) {
+	id, err := lib.ParseUUIDParam(r, "id")
+	if err != nil {
+		p.logger.Warn("Invalid product ID format", "id", chi.URLParam(r, "id"), "error", err)
+		lib.RespondInvalidUUID(w, err, "error.products.invalidProductId")
+		return
+	}
+
+	limit := 8
+	if limitStr := r.URL.Query().Get("limit"); limitStr != "" {
+		if val, err := strconv.Atoi(limitStr); err == nil && val > 0 {
+			limit = min(val, 50)
+		}
+	}
+
+	products, err := p.productService.GetRelatedProducts(r.Context(), id, limit)
+	if err != nil {
+		if lib.IsNotFound(err) {
+			gecho.NotFound(w,
+				gecho.WithMessage("error.products.notFound"),
+				gecho.Send(),
+			)
+			return
+		}
+
+		p.logger.Error("Failed to fetch related products", "id", id, "error", lib.GetDetailForLogging(err))
+		lib.RespondServerError(w, err, "error.products.failedToFetchRelated")
+		return
+	}
+
+	gecho.Success(w,
+		gecho.WithData(map[string]any{
+			"products": products,
+			"count":    len(products),
+		}),
+		gecho.Send(),
+	)
+}
+
+// SuggestProducts handles GET /products/suggest?q= returning active products whose name or SKU starts with q
+func (p *ProductRoutesManager) SuggestProducts(w http.ResponseWriter, r *http.Request) {
+	query := lib.SanitizeString(r.URL.Query().Get("q"), false, true)
+	if len(query) > 100 {
+		gecho.BadRequest(w,
+			gecho.WithMessage("error.invalidQueryParameters"),
+			gecho.Send(),
+		)
+		return
+	}
+
+	products, err := p.productService.SuggestProducts(r.Context(), query)
+	if err != nil {
+		p.logger.Error("Failed to fetch product suggestions", "error", lib.GetDetailForLogging(err))
+		lib.RespondServerError(w, err, "error.products.failedToFetchSuggestions")
+		return
+	}
+
+	gecho.Success(w,
+		gecho.WithData(map[string]any{
+			"products": products,
+			"count":    len(products),
+		}),
+		gecho.Send(),
+	)
+}
+
+// isAdminRequest reports whether the request carries a valid, not revoked admin access token
+// Admins read around the product cache so they see their own edits straight away; a token that cannot be
+// checked gets the cached view
+func (p *ProductRoutesManager) isAdminRequest(r *http.Request) bool {
+	claims, ok, err := p.mw.OptionalClaims(r)
+	return err == nil && ok && claims.Role == "admin"
+}
+
+// respondInvalidFilters answers 400 when err rejects the requested filters, reporting whether it did
+func respondInvalidFilters(w http.ResponseWriter, err error) bool {
+	var validationErr *lib.ValidationError
+	if !errors.As(err, &validationErr) {
+		return false
+	}
+	gecho.BadRequest(w,
+		gecho.WithMessage("error.invalidQueryParameters"),
+		gecho.WithData(validationErr),
+		gecho.Send(),
+	)
+	return true
+}
+
+// isPreviewRequest reports whether an admin asked (?preview=true) to also see products outside their availability window
+func (p *ProductRoutesManager) isPreviewRequest(r *http.Request) bool {
+	return r.URL.Query().Get("preview") == "true" && p.isAdminRequest(r)
+}
+
+// productListData is the response body of a product list, with pagination links when the client asks for them
+func productListData(r *http.Request, result *services.ProductListResult) map[string]any {
+	data := map[string]any{
+		"products":   result.Products,
+		"pagination": result.Pagination,
+		"filters":    result.Filters,
+		"meta": map[string]any{
+			"query_time_ms": result.QueryTime.Milliseconds(),
+			"count":         len(result.Products),
+		},
+	}
+	if lib.WantsPaginationLinks(r) {
+		data["links"] = lib.NewPaginationLinks(r, result.Pagination.Page, result.Pagination.TotalPages)
+	}
+	return data
+}
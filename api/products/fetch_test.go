@@ -0,0 +1,167 @@
+package products
+
+import (
+	"context"
+	"encoding/json"
+	"fmt"
+	"mamabloemetjes_server/api/middleware"
+	"mamabloemetjes_server/lib"
+	"mamabloemetjes_server/services"
+	"mamabloemetjes_server/structs/tables"
+	"mamabloemetjes_server/testutil"
+	"net/http"
+	"net/http/httptest"
+	"net/url"
+	"strings"
+	"testing"
+
+	"github.com/go-chi/chi/v5"
+	"github.com/google/uuid"
+)
+
+func TestIsAdminRequest(t *testing.T) {
+	testutil.Redis(t)
+	cfg := testutil.Config()
+	logger := testutil.Logger()
+	cache := services.NewCacheService(logger, cfg)
+	auth := services.NewAuthService(cfg, logger, nil, cache)
+	p := &ProductRoutesManager{logger: logger, mw: middleware.NewMiddleware(cfg, logger, auth, cache, nil)}
+
+	// Token versions come from the cache, as there is no database
+	tokenFor := func(role string) string {
+		user := &tables.User{Id: uuid.New(), Username: "Jan", Role: role}
+		if err := cache.CacheTokenVersionIfAbsent(user.Id, user.TokenVersion); err != nil {
+			t.Fatalf("CacheTokenVersionIfAbsent: %v", err)
+		}
+		token, err := auth.GenerateAccessToken(user)
+		if err != nil {
+			t.Fatalf("GenerateAccessToken: %v", err)
+		}
+		return token
+	}
+
+	tests := []struct {
+		name  string
+		token string
+		admin bool
+	}{
+		{"admin token", tokenFor("admin"), true},
+		{"user token", tokenFor("user"), false},
+		{"invalid token", "not-a-token", false},
+		{"anonymous", "", false},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			r := httptest.NewRequest(http.MethodGet, "/products/1", nil)
+			if tt.token != "" {
+				r.AddCookie(&http.Cookie{Name: lib.AccessCookieName, Value: tt.token})
+			}
+			if admin := p.isAdminRequest(r); admin != tt.admin {
+				t.Fatalf("expected isAdminRequest to be %v, got %v", tt.admin, admin)
+			}
+		})
+	}
+}
+
+func TestProductListRejectsExpensiveFilters(t *testing.T) {
+	testutil.Redis(t)
+	cfg := testutil.Config()
+	logger := testutil.Logger()
+	cache := services.NewCacheService(logger, cfg)
+	// The filters are rejected before any query runs, so no database is needed
+	p := &ProductRoutesManager{logger: logger, productService: services.NewProductService(logger, cfg, nil, cache)}
+
+	skus := make([]string, cfg.Products.MaxFilterSKUs+1)
+	for i := range skus {
+		skus[i] = fmt.Sprintf("SKU-%d", i)
+	}
+	short := strings.Repeat("r", cfg.Products.MinSearchLength-1)
+
+	tests := []struct {
+		name    string
+		query   url.Values
+		handler http.HandlerFunc
+	}{
+		{"too many skus", url.Values{"skus": {strings.Join(skus, ",")}}, p.FetchAllProducts},
+		{"too many excluded skus", url.Values{"exclude_skus": {strings.Join(skus, ",")}}, p.FetchAllProducts},
+		{"short search", url.Values{"search": {short}}, p.FetchAllProducts},
+		{"short search on the count", url.Values{"search": {short}}, p.GetProductCount},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			w := httptest.NewRecorder()
+			tt.handler(w, httptest.NewRequest(http.MethodGet, "/products?"+tt.query.Encode(), nil))
+			if w.Code != http.StatusBadRequest || !strings.Contains(w.Body.String(), "error.invalidQueryParameters") {
+				t.Fatalf("expected status 400 with the invalid parameters message, got %d: %s", w.Code, w.Body.String())
+			}
+		})
+	}
+}
+
+// relatedRequest returns a related products request for product id with the given query string
+func relatedRequest(id, query string) *http.Request {
+	routeCtx := chi.NewRouteContext()
+	routeCtx.URLParams.Add("id", id)
+	r := httptest.NewRequest(http.MethodGet, "/products/"+id+"/related?"+query, nil)
+	return r.WithContext(context.WithValue(r.Context(), chi.RouteCtxKey, routeCtx))
+}
+
+func TestFetchRelatedProductsLimit(t *testing.T) {
+	testutil.Redis(t)
+	cfg := testutil.Config()
+	logger := testutil.Logger()
+	cache := services.NewCacheService(logger, cfg)
+	// Every request is answered from the cache, so no database is needed
+	p := &ProductRoutesManager{logger: logger, productService: services.NewProductService(logger, cfg, nil, cache)}
+
+	id := uuid.New()
+	for _, limit := range []int{8, 3, 50} {
+		products := make([]tables.Product, min(limit, 3))
+		for i := range products {
+			products[i] = tables.Product{ID: uuid.New(), Name: fmt.Sprintf("Bouquet %d of %d", i+1, limit)}
+		}
+		if err := cache.SetRelatedProducts(id, limit, products); err != nil {
+			t.Fatalf("SetRelatedProducts: %v", err)
+		}
+	}
+
+	tests := []struct {
+		name  string
+		query string
+		limit int
+	}{
+		{"default", "", 8},
+		{"explicit", "limit=3", 3},
+		{"above the maximum", "limit=500", 50},
+		{"not a number", "limit=many", 8},
+		{"zero", "limit=0", 8},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			w := httptest.NewRecorder()
+			p.FetchRelatedProducts(w, relatedRequest(id.String(), tt.query))
+			if w.Code != http.StatusOK {
+				t.Fatalf("expected status 200, got %d: %s", w.Code, w.Body.String())
+			}
+			var response struct {
+				Data struct {
+					Products []tables.Product `json:"products"`
+					Count    int              `json:"count"`
+				} `json:"data"`
+			}
+			if err := json.Unmarshal(w.Body.Bytes(), &response); err != nil {
+				t.Fatalf("failed to decode the response: %v", err)
+			}
+			want := fmt.Sprintf("of %d", tt.limit)
+			if response.Data.Count == 0 || response.Data.Count != len(response.Data.Products) || !strings.HasSuffix(response.Data.Products[0].Name, want) {
+				t.Fatalf("expected the products cached for limit %d, got %s", tt.limit, w.Body.String())
+			}
+		})
+	}
+
+	w := httptest.NewRecorder()
+	p.FetchRelatedProducts(w, relatedRequest("not-a-uuid", ""))
+	if w.Code != http.StatusBadRequest {
+		t.Fatalf("expected status 400 for an invalid id, got %d", w.Code)
+	}
+}
@@ -1,7 +1,9 @@
 package products
 
 import (
+	"mamabloemetjes_server/api/middleware"
 	"mamabloemetjes_server/services"
+	"net/http"
 
 	"github.com/MonkyMars/gecho"
 	"github.com/go-chi/chi/v5"
@@ -11,23 +13,45 @@ type ProductRoutesManager struct {
 	logger         *gecho.Logger
 	productService *services.ProductService
 	emailService   *services.EmailService
+	mw             *middleware.Middleware
 }
 
 func NewProductRoutesManager(
 	logger *gecho.Logger,
 	productService *services.ProductService,
 	emailService *services.EmailService,
+	mw *middleware.Middleware,
 ) *ProductRoutesManager {
 	return &ProductRoutesManager{
 		logger:         logger,
 		productService: productService,
 		emailService:   emailService,
+		mw:             mw,
 	}
 }
 
 func (prm *ProductRoutesManager) RegisterRoutes(r chi.Router) {
 	// Register product-related routes here
-	r.Get("/products/{id}", prm.FetchProductByID)
-	r.Get("/products/active", prm.FetchActiveProducts)
-	r.Get("/products/count", prm.GetProductCount)
+	r.Group(func(r chi.Router) {
+		// Read routes also answer HEAD (same headers as GET, no body) with an exact Content-Length
+		r.Use(middleware.ContentLength)
+
+		readRoutes := map[string]http.HandlerFunc{
+			"/products/{id}":         prm.FetchProductByID,
+			"/products/{id}/related": prm.FetchRelatedProducts,
+			"/products/active":       prm.FetchActiveProducts,
+			"/products/count":        prm.GetProductCount,
+			"/products/stats":        prm.GetProductStats,
+			"/products/suggest":      prm.SuggestProducts,
+		}
+		for pattern, handler := range readRoutes {
+			r.Get(pattern, handler)
+			r.Head(pattern, handler)
+		}
+
+		// Trending section can be switched off without a redeploy
+		trending := r.With(prm.mw.RequireFeature(middleware.FeatureTrendingSection))
+		trending.Get("/products/trending", prm.FetchTrendingProducts)
+		trending.Head("/products/trending", prm.FetchTrendingProducts)
+	})
 }
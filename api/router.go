@@ -29,11 +29,27 @@ func App(
 
 	// Observability
 	r.Use(mw.SetupLoggerMiddleware())
-	r.Use(middleware.MetricsMiddleware)
+	r.Use(mw.MetricsMiddleware())
+	r.Use(mw.QueryCountMiddleware())
 
 	// CORS (must be before auth / csrf)
 	r.Use(mw.SetupCORS().Handler)
 
+	// Unknown routes and methods answer with the same JSON envelope as everything else
+	r.NotFound(func(w http.ResponseWriter, r *http.Request) {
+		gecho.NotFound(w,
+			gecho.WithMessage("error.notFound"),
+			gecho.Send(),
+		)
+	})
+	r.MethodNotAllowed(func(w http.ResponseWriter, r *http.Request) {
+		gecho.NewErr(w,
+			gecho.WithStatus(http.StatusMethodNotAllowed),
+			gecho.WithMessage("error.methodNotAllowed"),
+			gecho.Send(),
+		)
+	})
+
 	// Register all routes
 	routerManager.RegisterRoutes(r)
 
@@ -44,11 +60,5 @@ func App(
 		)
 	})
 
-	r.Get("/*", func(w http.ResponseWriter, r *http.Request) {
-		gecho.NotFound(w,
-			gecho.Send(),
-		)
-	})
-
 	return r
 }
@@ -0,0 +1,78 @@
+package api
+
+import (
+	"encoding/json"
+	"mamabloemetjes_server/api/admin"
+	"mamabloemetjes_server/api/auth"
+	"mamabloemetjes_server/api/debug"
+	"mamabloemetjes_server/api/health"
+	"mamabloemetjes_server/api/middleware"
+	"mamabloemetjes_server/api/orders"
+	"mamabloemetjes_server/api/products"
+	"mamabloemetjes_server/services"
+	"mamabloemetjes_server/testutil"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+)
+
+// newTestApp wires the full router the way main does, without a database
+func newTestApp(t *testing.T) http.Handler {
+	t.Helper()
+	testutil.Redis(t)
+	cfg := testutil.Config()
+	logger := testutil.Logger()
+
+	sm := services.NewServiceManager(logger, cfg, nil)
+	mw := middleware.NewMiddleware(cfg, logger, sm.AuthService, sm.CacheService, sm.FeatureFlags)
+	routerManager := NewRouterManager(
+		products.NewProductRoutesManager(logger, sm.ProductService, sm.EmailService, mw),
+		health.NewHealthRoutesManager(sm.HealthService),
+		auth.NewAuthRoutesManager(logger, sm.AuthService, sm.EmailService, sm.CacheService, sm.OrderService, cfg, mw),
+		admin.NewAdminRoutesManager(logger, sm.AuthService, sm.ProductService, sm.OrderService, sm.FeatureFlags, mw),
+		orders.NewOrderRoutesManager(sm.ProductService, sm.OrderService, sm.EmailService, mw, logger),
+		debug.NewDebugRoutesManager(sm.CacheService),
+	)
+	return App(routerManager, mw, cfg)
+}
+
+func TestUnknownRoutesAnswerWithJSON(t *testing.T) {
+	app := newTestApp(t)
+
+	tests := []struct {
+		name    string
+		method  string
+		path    string
+		status  int
+		message string
+	}{
+		{"unknown path", http.MethodGet, "/does-not-exist", http.StatusNotFound, "error.notFound"},
+		{"wrong method", http.MethodDelete, "/", http.StatusMethodNotAllowed, "error.methodNotAllowed"},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			w := httptest.NewRecorder()
+			app.ServeHTTP(w, httptest.NewRequest(tt.method, tt.path, nil))
+
+			if w.Code != tt.status {
+				t.Fatalf("expected status %d, got %d", tt.status, w.Code)
+			}
+			if contentType := w.Header().Get("Content-Type"); !strings.HasPrefix(contentType, "application/json") {
+				t.Fatalf("expected a JSON response, got %q", contentType)
+			}
+			var envelope struct {
+				Status  int    `json:"status"`
+				Success bool   `json:"success"`
+				Message string `json:"message"`
+			}
+			if err := json.Unmarshal(w.Body.Bytes(), &envelope); err != nil {
+				t.Fatalf("expected the JSON envelope, got %s", w.Body.String())
+			}
+			if envelope.Status != tt.status || envelope.Success || envelope.Message != tt.message {
+				t.Fatalf("expected a failed envelope with %q, got %+v", tt.message, envelope)
+			}
+		})
+	}
+}
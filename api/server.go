@@ -0,0 +1,27 @@
+package api
+
+import (
+	"mamabloemetjes_server/structs"
+	"net/http"
+)
+
+// NewServer builds the HTTP server with the timeouts and header limit from the server config
+// ReadHeaderTimeout bounds how long a client may take to send its headers, which stops slowloris style connections.
+// HTTP/2 is negotiated over TLS automatically; EnableH2C also accepts it unencrypted for a proxy in front of the server
+func NewServer(cfg *structs.ServerConfig, handler http.Handler) *http.Server {
+	protocols := new(http.Protocols)
+	protocols.SetHTTP1(true)
+	protocols.SetHTTP2(true)
+	protocols.SetUnencryptedHTTP2(cfg.EnableH2C)
+
+	return &http.Server{
+		Addr:              cfg.Port,
+		Handler:           handler,
+		ReadTimeout:       cfg.ReadTimeout,
+		WriteTimeout:      cfg.WriteTimeout,
+		IdleTimeout:       cfg.IdleTimeout,
+		ReadHeaderTimeout: cfg.ReadHeaderTimeout,
+		MaxHeaderBytes:    cfg.MaxHeaderBytes,
+		Protocols:         protocols,
+	}
+}
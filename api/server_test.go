@@ -0,0 +1,42 @@
+package api
+
+import (
+	"mamabloemetjes_server/structs"
+	"net/http"
+	"testing"
+	"time"
+)
+
+func TestNewServerUsesConfig(t *testing.T) {
+	cfg := &structs.ServerConfig{
+		Port:              ":8081",
+		ReadTimeout:       15 * time.Second,
+		WriteTimeout:      20 * time.Second,
+		IdleTimeout:       60 * time.Second,
+		ReadHeaderTimeout: 5 * time.Second,
+		MaxHeaderBytes:    8192,
+	}
+	handler := http.NotFoundHandler()
+
+	server := NewServer(cfg, handler)
+
+	if server.Addr != ":8081" || server.Handler == nil {
+		t.Fatalf("expected the server on :8081 with the handler, got %q", server.Addr)
+	}
+	if server.ReadTimeout != cfg.ReadTimeout || server.WriteTimeout != cfg.WriteTimeout ||
+		server.IdleTimeout != cfg.IdleTimeout || server.ReadHeaderTimeout != cfg.ReadHeaderTimeout {
+		t.Fatalf("expected the config timeouts, got read %s, write %s, idle %s, read header %s",
+			server.ReadTimeout, server.WriteTimeout, server.IdleTimeout, server.ReadHeaderTimeout)
+	}
+	if server.MaxHeaderBytes != cfg.MaxHeaderBytes {
+		t.Fatalf("expected a header limit of %d, got %d", cfg.MaxHeaderBytes, server.MaxHeaderBytes)
+	}
+	if !server.Protocols.HTTP1() || !server.Protocols.HTTP2() || server.Protocols.UnencryptedHTTP2() {
+		t.Fatalf("expected HTTP/1 and HTTP/2 without h2c, got %s", server.Protocols)
+	}
+
+	cfg.EnableH2C = true
+	if server := NewServer(cfg, handler); !server.Protocols.UnencryptedHTTP2() {
+		t.Fatal("expected EnableH2C to accept unencrypted HTTP/2")
+	}
+}
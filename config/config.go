@@ -4,6 +4,8 @@ import (
 	"fmt"
 	"log"
 	"mamabloemetjes_server/structs"
+	"net/http"
+	"slices"
 	"sync"
 	"time"
 
@@ -18,19 +20,23 @@ var (
 
 func GetConfig() *structs.Config {
 	configOnce.Do(func() {
+		environment := getEnvAsString("APP_ENV", "development")
+
 		configInstance = &structs.Config{
 			Server: &structs.ServerConfig{
-				AppName:           getEnvAsString("APP_NAME", "Mamabloemetjes_no_env"),
-				Environment:       getEnvAsString("APP_ENV", "development"),
-				Port:              getEnvAsString("APP_PORT", ":8082"),
-				LogLevel:          getEnvAsString("APP_LOG_LEVEL", "info"),
-				ServerURL:         getEnvAsString("APP_SERVER_URL", "http://localhost:8082"),
-				FrontendURL:       getEnvAsString("APP_FRONTEND_URL", "http://localhost:3000"),
-				ReadTimeout:       getEnvAsTimeDuration("SERVER_READ_TIME_OUT", 15*time.Second),
-				WriteTimeout:      getEnvAsTimeDuration("SERVER_WRITE_TIME_OUT", 15*time.Second),
-				IdleTimeout:       getEnvAsTimeDuration("SERVER_IDLE_TIME_OUT", 60*time.Second),
-				ReadHeaderTimeout: getEnvAsTimeDuration("SERVER_READ_HEADER_TIMEOUT", 10*time.Second),
-				MaxHeaderBytes:    getEnvAsInt("SERVER_MAX_HEADER_BYTES", 1<<20), // 1 MB
+				AppName:              getEnvAsString("APP_NAME", "Mamabloemetjes_no_env"),
+				Environment:          environment,
+				Port:                 getEnvAsString("APP_PORT", ":8082"),
+				LogLevel:             getEnvAsString("APP_LOG_LEVEL", "info"),
+				ServerURL:            getEnvAsString("APP_SERVER_URL", "http://localhost:8082"),
+				FrontendURL:          getEnvAsString("APP_FRONTEND_URL", "http://localhost:3000"),
+				ReadTimeout:          getEnvAsTimeDuration("SERVER_READ_TIME_OUT", 15*time.Second),
+				WriteTimeout:         getEnvAsTimeDuration("SERVER_WRITE_TIME_OUT", 15*time.Second),
+				IdleTimeout:          getEnvAsTimeDuration("SERVER_IDLE_TIME_OUT", 60*time.Second),
+				ReadHeaderTimeout:    getEnvAsTimeDuration("SERVER_READ_HEADER_TIMEOUT", 10*time.Second),
+				MaxHeaderBytes:       getEnvAsInt("SERVER_MAX_HEADER_BYTES", 1<<20), // 1 MB
+				SlowRequestThreshold: getEnvAsTimeDuration("SERVER_SLOW_REQUEST_THRESHOLD", 2*time.Second),
+				EnableH2C:            getEnvAsBool("SERVER_ENABLE_H2C", false),
 			},
 			Cors: &structs.CorsConfig{
 				AllowedOrigins:   getEnvAsSlice("CORS_ALLOW_ORIGINS", []string{"http://localhost:3000"}),
@@ -39,7 +45,9 @@ func GetConfig() *structs.Config {
 				AllowCredentials: getEnvAsBool("CORS_ALLOW_CREDENTIALS", true),
 				ExposedHeaders:   getEnvAsSlice("CORS_EXPOSED_HEADERS", []string{"Content-Length", "Authorization", "Set-Cookie"}),
 				MaxAge:           getEnvAsInt("CORS_MAX_AGE", 600),
+				AllowLocalhost:   environment != "production",
 			},
+			Cookie: cookiePreset(environment),
 			Database: &structs.DatabaseConfig{
 				Host:         getEnvAsString("DB_HOST", "localhost"),
 				Port:         getEnvAsInt("DB_PORT", 5432),
@@ -54,12 +62,27 @@ func GetConfig() *structs.Config {
 				WriteTimeout: getEnvAsTimeDuration("DB_WRITE_TIMEOUT", 5*time.Second),
 			},
 			Auth: &structs.AuthConfig{
-				AccessTokenSecret:  getEnvAsString("AUTH_ACCESS_TOKEN_SECRET", "default_access_secret"),
-				AccessTokenExpiry:  getEnvAsTimeDuration("AUTH_ACCESS_TOKEN_EXPIRY", 15*time.Minute),
-				RefreshTokenSecret: getEnvAsString("AUTH_REFRESH_TOKEN_SECRET", "default_refresh_secret"),
-				RefreshTokenExpiry: getEnvAsTimeDuration("AUTH_REFRESH_TOKEN_EXPIRY", 7*24*time.Hour),
-				CacheUserTTL:       getEnvAsTimeDuration("AUTH_CACHE_USER_TTL", 30*time.Minute),
-				BlacklistCacheTTL:  getEnvAsTimeDuration("AUTH_BLACKLIST_CACHE_TTL", 7*24*time.Hour),
+				AccessTokenSecret:     getEnvAsString("AUTH_ACCESS_TOKEN_SECRET", "default_access_secret"),
+				AccessTokenExpiry:     getEnvAsTimeDuration("AUTH_ACCESS_TOKEN_EXPIRY", 15*time.Minute),
+				RefreshTokenSecret:    getEnvAsString("AUTH_REFRESH_TOKEN_SECRET", "default_refresh_secret"),
+				RefreshTokenExpiry:    getEnvAsTimeDuration("AUTH_REFRESH_TOKEN_EXPIRY", 7*24*time.Hour),
+				CacheUserTTL:          getEnvAsTimeDuration("AUTH_CACHE_USER_TTL", 30*time.Minute),
+				BlacklistCacheTTL:     getEnvAsTimeDuration("AUTH_BLACKLIST_CACHE_TTL", 7*24*time.Hour),
+				TokenLeeway:           getEnvAsTimeDuration("AUTH_TOKEN_LEEWAY", 30*time.Second),
+				TokenIssuer:           getEnvAsString("AUTH_TOKEN_ISSUER", ""),
+				TokenAudience:         getEnvAsString("AUTH_TOKEN_AUDIENCE", ""),
+				PasswordMaxMemory:     getEnvAsInt("AUTH_PASSWORD_MAX_MEMORY", 256*1024),
+				PasswordMaxTime:       getEnvAsInt("AUTH_PASSWORD_MAX_TIME", 10),
+				PasswordMaxThreads:    getEnvAsInt("AUTH_PASSWORD_MAX_THREADS", 16),
+				PasswordPepperVersion: getEnvAsInt("AUTH_PASSWORD_PEPPER_VERSION", 0),
+				PasswordPeppers:       getEnvAsIntStringMap("AUTH_PASSWORD_PEPPERS", map[int]string{}),
+			},
+			Argon: &structs.ArgonConfig{
+				Memory:  getEnvAsInt("ARGON_MEMORY", 64*1024), // 64 MB
+				Time:    getEnvAsInt("ARGON_TIME", 1),
+				Threads: getEnvAsInt("ARGON_THREADS", 4),
+				KeyLen:  getEnvAsInt("ARGON_KEY_LEN", 32),
+				SaltLen: getEnvAsInt("ARGON_SALT_LEN", 16),
 			},
 			Cache: &structs.CacheConfig{
 				Address:         getEnvAsString("CACHE_ADDRESS", "localhost:6379"),
@@ -79,9 +102,15 @@ func GetConfig() *structs.Config {
 				MaxRetryBackoff: getEnvAsTimeDuration("CACHE_MAX_RETRY_BACKOFF", 512*time.Millisecond),
 				ProductListTTL:  getEnvAsTimeDuration("CACHE_PRODUCT_LIST_TTL", 5*time.Minute),
 				ProductCountTTL: getEnvAsTimeDuration("CACHE_PRODUCT_COUNT_TTL", 10*time.Minute),
+				TrendingWindow:  getEnvAsTimeDuration("CACHE_TRENDING_WINDOW", 24*time.Hour),
+				SuggestTTL:      getEnvAsTimeDuration("CACHE_SUGGEST_TTL", 30*time.Second),
+				ProfileTTL:      getEnvAsTimeDuration("CACHE_PROFILE_TTL", 5*time.Minute),
+				OrderStatusTTL:  getEnvAsTimeDuration("CACHE_ORDER_STATUS_TTL", 5*time.Second),
+				WarmPages:       getEnvAsInt("CACHE_WARM_PAGES", 3),
 			},
 			RateLimit: &structs.RateLimitConfig{
 				Enabled:         getEnvAsBool("RATE_LIMIT_ENABLED", true),
+				PerUser:         getEnvAsBool("RATE_LIMIT_PER_USER", true),
 				GeneralLimit:    getEnvAsInt("RATE_LIMIT_GENERAL_LIMIT", 100),
 				GeneralWindow:   getEnvAsTimeDuration("RATE_LIMIT_GENERAL_WINDOW", 1*time.Minute),
 				AuthLimit:       getEnvAsInt("RATE_LIMIT_AUTH_LIMIT", 5),
@@ -90,6 +119,12 @@ func GetConfig() *structs.Config {
 				ExpensiveWindow: getEnvAsTimeDuration("RATE_LIMIT_EXPENSIVE_WINDOW", 1*time.Minute),
 				AdminLimit:      getEnvAsInt("RATE_LIMIT_ADMIN_LIMIT", 50),
 				AdminWindow:     getEnvAsTimeDuration("RATE_LIMIT_ADMIN_WINDOW", 1*time.Minute),
+
+				UserLookupLimit:  getEnvAsInt("RATE_LIMIT_USER_LOOKUP_LIMIT", 20),
+				UserLookupWindow: getEnvAsTimeDuration("RATE_LIMIT_USER_LOOKUP_WINDOW", 1*time.Minute),
+
+				CacheWarmLimit:  getEnvAsInt("RATE_LIMIT_CACHE_WARM_LIMIT", 3),
+				CacheWarmWindow: getEnvAsTimeDuration("RATE_LIMIT_CACHE_WARM_WINDOW", 10*time.Minute),
 			},
 			Email: &structs.EmailConfig{
 				ApiKey:                  getEnvAsString("EMAIL_API_KEY", "no_api_key"),
@@ -97,10 +132,58 @@ func GetConfig() *structs.Config {
 				SupportEmail:            getEnvAsString("EMAIL_SUPPORT_ADDRESS", "support@example.com"),
 				OrderConfirmationFrom:   getEnvAsString("EMAIL_ORDER_CONFIRMATION_FROM", "orders@example.com"),
 				VerificationTokenExpiry: getEnvAsTimeDuration("EMAIL_VERIFICATION_TOKEN_EXPIRY", 15*time.Minute),
+				PasswordResetExpiry:     getEnvAsTimeDuration("EMAIL_PASSWORD_RESET_EXPIRY", 30*time.Minute),
+				AdminEmails:             getEnvAsSlice("EMAIL_ADMIN_ADDRESSES", []string{}),
+				FromOverrides:           getEnvAsStringMap("EMAIL_FROM_OVERRIDES", map[string]string{}),
+				ReplyToOverrides:        getEnvAsStringMap("EMAIL_REPLY_TO_OVERRIDES", map[string]string{}),
+				SendMaxAttempts:         getEnvAsInt("EMAIL_SEND_MAX_ATTEMPTS", 3),
+				SendRetryDelay:          getEnvAsTimeDuration("EMAIL_SEND_RETRY_DELAY", 500*time.Millisecond),
+				SendMaxRetryDelay:       getEnvAsTimeDuration("EMAIL_SEND_MAX_RETRY_DELAY", 10*time.Second),
+				QueueSize:               getEnvAsInt("EMAIL_QUEUE_SIZE", 256),
+				QueueWorkers:            getEnvAsInt("EMAIL_QUEUE_WORKERS", 2),
+				QueueDrainTimeout:       getEnvAsTimeDuration("EMAIL_QUEUE_DRAIN_TIMEOUT", 15*time.Second),
 			},
 			Encryption: &structs.EncryptionConfig{
 				Key: getEnvAsString("ENCRYPTION_KEY", ""),
 			},
+			Orders: &structs.OrderConfig{
+				PendingTTL:      getEnvAsTimeDuration("ORDER_PENDING_TTL", 72*time.Hour),
+				SweepInterval:   getEnvAsTimeDuration("ORDER_SWEEP_INTERVAL", 15*time.Minute),
+				SweepBatchSize:  getEnvAsInt("ORDER_SWEEP_BATCH_SIZE", 50),
+				SweepEnabled:    getEnvAsBool("ORDER_SWEEP_ENABLED", true),
+				CancelExpired:   getEnvAsBool("ORDER_SWEEP_CANCEL_EXPIRED", true),
+				DefaultCurrency: getEnvAsString("ORDER_DEFAULT_CURRENCY", "EUR"),
+				OwnershipPolicy: getEnvAsString("ORDER_OWNERSHIP_POLICY", "not_found"),
+
+				GuestRetention:     getEnvAsTimeDuration("ORDER_GUEST_RETENTION", 2*365*24*time.Hour),
+				AnonymizeInterval:  getEnvAsTimeDuration("ORDER_ANONYMIZE_INTERVAL", 24*time.Hour),
+				AnonymizeBatchSize: getEnvAsInt("ORDER_ANONYMIZE_BATCH_SIZE", 100),
+				AnonymizeEnabled:   getEnvAsBool("ORDER_ANONYMIZE_ENABLED", true),
+
+				UserOrderLimit:  getEnvAsInt("ORDER_USER_LIMIT", 5),
+				UserOrderWindow: getEnvAsTimeDuration("ORDER_USER_LIMIT_WINDOW", time.Hour),
+				UserLimitBypass: getEnvAsSlice("ORDER_USER_LIMIT_BYPASS", []string{}),
+
+				ShippingCents: getEnvAsInt("ORDER_SHIPPING_CENTS", 495),
+
+				MaxOrderTotal:   uint64(getEnvAsInt("ORDER_MAX_TOTAL", 100000)),
+				MaxLineQuantity: getEnvAsInt("ORDER_MAX_LINE_QUANTITY", 10),
+			},
+			Products: &structs.ProductConfig{
+				MaxImages:    getEnvAsInt("PRODUCT_MAX_IMAGES", 10),
+				SuggestLimit: getEnvAsInt("PRODUCT_SUGGEST_LIMIT", 8),
+
+				MaxFilterSKUs:    getEnvAsInt("PRODUCT_MAX_FILTER_SKUS", 50),
+				MinSearchLength:  getEnvAsInt("PRODUCT_MIN_SEARCH_LENGTH", 3),
+				ListQueryTimeout: getEnvAsTimeDuration("PRODUCT_LIST_QUERY_TIMEOUT", 5*time.Second),
+			},
+			Features: &structs.FeatureConfig{
+				Defaults: getEnvAsBoolMap("FEATURE_FLAGS", map[string]bool{
+					"new_checkout":     false,
+					"trending_section": true,
+				}),
+				CacheTTL: getEnvAsTimeDuration("FEATURE_FLAG_CACHE_TTL", 30*time.Second),
+			},
 		}
 
 		// Validate the configuration
@@ -116,6 +199,25 @@ func GetConfig() *structs.Config {
 	return configInstance
 }
 
+// cookiePreset derives the cookie attributes from the environment
+// Production shares cookies across the www and api subdomains, which needs SameSite=None and Secure.
+// Development uses host-only Lax cookies, so they work on plain http://localhost without any setup
+func cookiePreset(environment string) *structs.CookieConfig {
+	if environment == "production" {
+		return &structs.CookieConfig{
+			Domain:   getEnvAsString("COOKIE_DOMAIN", ".roosvansharon.nl"),
+			Secure:   true,
+			SameSite: http.SameSiteNoneMode,
+		}
+	}
+
+	return &structs.CookieConfig{
+		Domain:   "",
+		Secure:   false,
+		SameSite: http.SameSiteLaxMode,
+	}
+}
+
 // validateConfig performs additional custom validation checks
 func validateConfig(cfg *structs.Config) error {
 	// Ensure MaxIdleConns doesn't exceed PoolSize for cache
@@ -143,6 +245,50 @@ func validateConfig(cfg *structs.Config) error {
 		return fmt.Errorf("access token expiry (%v) must be less than refresh token expiry (%v)", cfg.Auth.AccessTokenExpiry, cfg.Auth.RefreshTokenExpiry)
 	}
 
+	// The active pepper must be configured, and every pepper must be long enough to be worth keeping secret
+	if cfg.Auth.PasswordPepperVersion > 0 {
+		if _, ok := cfg.Auth.PasswordPeppers[cfg.Auth.PasswordPepperVersion]; !ok {
+			return fmt.Errorf("auth PasswordPepperVersion (%d) has no pepper in AUTH_PASSWORD_PEPPERS", cfg.Auth.PasswordPepperVersion)
+		}
+	}
+	for version, pepper := range cfg.Auth.PasswordPeppers {
+		if version <= 0 {
+			return fmt.Errorf("auth password pepper version (%d) must be positive", version)
+		}
+		if len(pepper) < 32 {
+			return fmt.Errorf("auth password pepper version %d must be at least 32 characters", version)
+		}
+	}
+
+	// New hashes must stay verifiable, so they may not exceed the limits applied to stored hashes
+	if cfg.Argon.Memory > cfg.Auth.PasswordMaxMemory || cfg.Argon.Time > cfg.Auth.PasswordMaxTime || cfg.Argon.Threads > cfg.Auth.PasswordMaxThreads {
+		return fmt.Errorf("argon parameters (m=%d,t=%d,p=%d) exceed the auth password limits (m=%d,t=%d,p=%d)",
+			cfg.Argon.Memory, cfg.Argon.Time, cfg.Argon.Threads,
+			cfg.Auth.PasswordMaxMemory, cfg.Auth.PasswordMaxTime, cfg.Auth.PasswordMaxThreads)
+	}
+
+	// Email overrides must name a known email type, otherwise a typo silently falls back to the default sender
+	for _, overrides := range []map[string]string{cfg.Email.FromOverrides, cfg.Email.ReplyToOverrides} {
+		for emailType := range overrides {
+			if !slices.Contains(structs.EmailTypes, structs.EmailType(emailType)) {
+				return fmt.Errorf("email override for unknown email type %q", emailType)
+			}
+		}
+	}
+
+	if cfg.Email.SendRetryDelay > cfg.Email.SendMaxRetryDelay {
+		return fmt.Errorf("email SendRetryDelay (%v) cannot exceed SendMaxRetryDelay (%v)", cfg.Email.SendRetryDelay, cfg.Email.SendMaxRetryDelay)
+	}
+
+	// Browsers reject credentialed responses with a wildcard origin, so never combine the two
+	if cfg.Cors.AllowCredentials {
+		for _, origin := range cfg.Cors.AllowedOrigins {
+			if origin == "*" {
+				return fmt.Errorf("cors AllowCredentials cannot be combined with a wildcard (*) origin; list the allowed origins explicitly")
+			}
+		}
+	}
+
 	return nil
 }
 
@@ -0,0 +1,134 @@
+package config
+
+import (
+	"mamabloemetjes_server/structs"
+	"net/http"
+	"sync"
+	"testing"
+)
+
+// requiredEnv holds the settings without a usable default
+var requiredEnv = map[string]string{
+	"AUTH_ACCESS_TOKEN_SECRET":  "test-access-token-secret-0123456789",
+	"AUTH_REFRESH_TOKEN_SECRET": "test-refresh-token-secret-0123456789",
+	"ENCRYPTION_KEY":            "0123456789abcdef0123456789abcdef",
+}
+
+// loadConfig loads the config afresh from requiredEnv plus env, restoring the singleton when the test ends
+func loadConfig(t *testing.T, env map[string]string) *structs.Config {
+	t.Helper()
+	for key, value := range requiredEnv {
+		t.Setenv(key, value)
+	}
+	for key, value := range env {
+		t.Setenv(key, value)
+	}
+
+	configOnce, configInstance = sync.Once{}, nil
+	t.Cleanup(func() {
+		configOnce, configInstance = sync.Once{}, nil
+	})
+	return GetConfig()
+}
+
+func TestCORSCredentialsRejectWildcardOrigin(t *testing.T) {
+	cfg := loadConfig(t, map[string]string{"CORS_ALLOW_ORIGINS": "https://www.example.com", "CORS_ALLOW_CREDENTIALS": "true"})
+	if err := validateConfig(cfg); err != nil {
+		t.Fatalf("expected explicit origins with credentials to be valid, got %v", err)
+	}
+
+	cors := *cfg.Cors
+	cors.AllowedOrigins = []string{"https://www.example.com", "*"}
+	cfg.Cors = &cors
+	if err := validateConfig(cfg); err == nil {
+		t.Fatal("expected a wildcard origin with credentials to be rejected")
+	}
+
+	// Without credentials a wildcard is fine
+	cors.AllowCredentials = false
+	if err := validateConfig(cfg); err != nil {
+		t.Fatalf("expected a wildcard origin without credentials to be valid, got %v", err)
+	}
+}
+
+func TestAdminEmailsValidated(t *testing.T) {
+	// Loading validates the config, so valid addresses got through
+	cfg := loadConfig(t, map[string]string{"EMAIL_ADMIN_ADDRESSES": "eigenaar@mamabloemetjes.nl,support@mamabloemetjes.nl"})
+	if len(cfg.Email.AdminEmails) != 2 {
+		t.Fatalf("expected 2 admin emails, got %v", cfg.Email.AdminEmails)
+	}
+
+	email := *cfg.Email
+	email.AdminEmails = []string{"eigenaar@mamabloemetjes.nl", "not-an-email"}
+	cfg.Email = &email
+	if err := validate.Struct(cfg); err == nil {
+		t.Fatal("expected an invalid admin email to be rejected at startup")
+	}
+}
+
+func TestEmailSenderOverrides(t *testing.T) {
+	cfg := loadConfig(t, map[string]string{
+		"EMAIL_FROM_OVERRIDES":     "order_confirmation=Mamabloemetjes <orders@mamabloemetjes.nl>, malformed",
+		"EMAIL_REPLY_TO_OVERRIDES": "order_confirmation=support@mamabloemetjes.nl",
+	})
+	if from := cfg.Email.FromOverrides["order_confirmation"]; from != "Mamabloemetjes <orders@mamabloemetjes.nl>" || len(cfg.Email.FromOverrides) != 1 {
+		t.Fatalf("expected only the order confirmation sender, got %v", cfg.Email.FromOverrides)
+	}
+	if replyTo := cfg.Email.ReplyToOverrides["order_confirmation"]; replyTo != "support@mamabloemetjes.nl" {
+		t.Fatalf("expected the order confirmation reply-to, got %v", cfg.Email.ReplyToOverrides)
+	}
+	if err := validateConfig(cfg); err != nil {
+		t.Fatalf("expected the overrides to be valid, got %v", err)
+	}
+
+	email := *cfg.Email
+	email.ReplyToOverrides = map[string]string{"order_confirmaton": "support@mamabloemetjes.nl"}
+	cfg.Email = &email
+	if err := validateConfig(cfg); err == nil {
+		t.Fatal("expected an override for an unknown email type to be rejected")
+	}
+}
+
+func TestEnvironmentPresets(t *testing.T) {
+	development := loadConfig(t, map[string]string{"APP_ENV": "development"})
+	if cookie := *development.Cookie; cookie != (structs.CookieConfig{Domain: "", Secure: false, SameSite: http.SameSiteLaxMode}) {
+		t.Fatalf("expected host-only Lax cookies without Secure in development, got %+v", cookie)
+	}
+	if !development.Cors.AllowLocalhost {
+		t.Fatal("expected development to allow localhost origins")
+	}
+
+	production := loadConfig(t, map[string]string{"APP_ENV": "production", "COOKIE_DOMAIN": ".example.com"})
+	if cookie := *production.Cookie; cookie != (structs.CookieConfig{Domain: ".example.com", Secure: true, SameSite: http.SameSiteNoneMode}) {
+		t.Fatalf("expected cross-subdomain Secure cookies in production, got %+v", cookie)
+	}
+	if production.Cors.AllowLocalhost {
+		t.Fatal("expected production not to allow localhost origins")
+	}
+}
+
+func TestArgonConfigFromEnv(t *testing.T) {
+	unset := map[string]string{"ARGON_MEMORY": "", "ARGON_TIME": "", "ARGON_THREADS": "", "ARGON_KEY_LEN": "", "ARGON_SALT_LEN": ""}
+	defaults := loadConfig(t, unset).Argon
+	if *defaults != (structs.ArgonConfig{Memory: 64 * 1024, Time: 1, Threads: 4, KeyLen: 32, SaltLen: 16}) {
+		t.Fatalf("expected the previous hardcoded parameters by default, got %+v", *defaults)
+	}
+
+	cfg := loadConfig(t, map[string]string{"ARGON_MEMORY": "131072", "ARGON_TIME": "3", "ARGON_THREADS": "2", "ARGON_KEY_LEN": "64", "ARGON_SALT_LEN": "32"})
+	if *cfg.Argon != (structs.ArgonConfig{Memory: 131072, Time: 3, Threads: 2, KeyLen: 64, SaltLen: 32}) {
+		t.Fatalf("expected the env overrides, got %+v", *cfg.Argon)
+	}
+
+	// Hashes made with parameters above the verification limits could never be checked
+	argon := *cfg.Argon
+	argon.Memory = cfg.Auth.PasswordMaxMemory + 1
+	cfg.Argon = &argon
+	if err := validateConfig(cfg); err == nil {
+		t.Fatal("expected argon memory above the auth password limit to be rejected")
+	}
+
+	argon.Memory, argon.SaltLen = 64*1024, 8
+	if err := validate.Struct(cfg); err == nil {
+		t.Fatal("expected a salt shorter than 16 bytes to be rejected")
+	}
+}
@@ -57,6 +57,69 @@ func getEnvAsSlice(key string, defaultVal []string) []string {
 	return defaultVal
 }
 
+// getEnvAsBoolMap parses a comma-separated list of name=bool pairs, skipping malformed entries
+func getEnvAsBoolMap(key string, defaultVal map[string]bool) map[string]bool {
+	if valueStr, exists := lookupEnv(key); exists {
+		result := make(map[string]bool)
+		for _, pair := range strings.Split(valueStr, ",") {
+			name, valueStr, ok := strings.Cut(pair, "=")
+			if !ok {
+				continue
+			}
+			name = strings.TrimSpace(name)
+			value, err := strconv.ParseBool(strings.TrimSpace(valueStr))
+			if name == "" || err != nil {
+				continue
+			}
+			result[name] = value
+		}
+		return result
+	}
+	return defaultVal
+}
+
 func lookupEnv(key string) (string, bool) {
 	return os.LookupEnv(key)
 }
+
+// getEnvAsIntStringMap parses a comma-separated list of int=string pairs, skipping malformed entries
+func getEnvAsIntStringMap(key string, defaultVal map[int]string) map[int]string {
+	if valueStr, exists := lookupEnv(key); exists {
+		result := make(map[int]string)
+		for _, pair := range strings.Split(valueStr, ",") {
+			keyStr, value, ok := strings.Cut(pair, "=")
+			if !ok {
+				continue
+			}
+			k, err := strconv.Atoi(strings.TrimSpace(keyStr))
+			value = strings.TrimSpace(value)
+			if err != nil || value == "" {
+				continue
+			}
+			result[k] = value
+		}
+		return result
+	}
+	return defaultVal
+}
+
+// getEnvAsStringMap parses a comma-separated list of name=string pairs, skipping malformed entries
+func getEnvAsStringMap(key string, defaultVal map[string]string) map[string]string {
+	if valueStr, exists := lookupEnv(key); exists {
+		result := make(map[string]string)
+		for _, pair := range strings.Split(valueStr, ",") {
+			name, value, ok := strings.Cut(pair, "=")
+			if !ok {
+				continue
+			}
+			name = strings.TrimSpace(name)
+			value = strings.TrimSpace(value)
+			if name == "" || value == "" {
+				continue
+			}
+			result[name] = value
+		}
+		return result
+	}
+	return defaultVal
+}
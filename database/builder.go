@@ -9,6 +9,12 @@ import (
 	"github.com/uptrace/bun"
 )
 
+// SoftDeleteColumn is the column a SoftDelete query stamps on delete
+const SoftDeleteColumn = "deleted_at"
+
+// notTrashedCondition matches rows that are not soft-deleted, qualified with the model's alias so it stays unambiguous with joins
+const notTrashedCondition = "?TableAlias." + SoftDeleteColumn + " IS NULL"
+
 // JoinType represents the type of SQL JOIN operation
 type JoinType int
 
@@ -48,6 +54,7 @@ type QueryBuilder[T any] struct {
 	joins       []*JoinClause
 	wheres      []*WhereClause
 	whereGroups []*WhereGroup
+	groupJoin   string // Connector between the top-level WHERE groups, "AND" unless set with GroupConnector
 	orders      []*OrderClause
 	groupBys    []string
 	havings     []*WhereClause
@@ -55,17 +62,22 @@ type QueryBuilder[T any] struct {
 	offsetVal   *int
 
 	// Relations to preload
-	relations []string
+	relations []*RelationClause
 
 	// Options
-	distinct  bool
-	forUpdate bool
+	distinct    bool
+	forUpdate   bool
+	softDelete  bool // Delete sets deleted_at instead of removing rows, and trashed rows are excluded
+	withTrashed bool // Include soft-deleted rows in reads and updates
 
 	// Timeout
 	timeout time.Duration
 
 	// Retry configuration
 	retryConfig RetryConfig
+
+	// First error made while building the query, returned by the executors instead of running it
+	err error
 }
 
 // JoinClause represents a SQL JOIN operation
@@ -86,13 +98,14 @@ type JoinCondition struct {
 
 // WhereClause represents a WHERE condition
 type WhereClause struct {
-	Column   string
-	Operator string
-	Value    any
-	IsRaw    bool
-	RawSQL   string
-	RawArgs  []any
-	Negate   bool // For NOT conditions
+	Column     string
+	Operator   string
+	Value      any
+	IsRaw      bool
+	RawSQL     string
+	RawArgs    []any
+	Negate     bool   // For NOT conditions
+	Quantifier string // ANY or ALL for array comparisons, Value holds the []any
 }
 
 // WhereGroup represents a grouped WHERE condition (for OR/AND grouping)
@@ -103,10 +116,18 @@ type WhereGroup struct {
 	Negate     bool
 }
 
+// RelationClause represents a relation to preload with optional query modifiers
+type RelationClause struct {
+	Name  string
+	Apply []func(*bun.SelectQuery) *bun.SelectQuery
+}
+
 // OrderClause represents an ORDER BY clause
 type OrderClause struct {
 	Column    string
 	Direction string // "ASC" or "DESC"
+	RawSQL    string // Raw ORDER BY expression, used instead of Column/Direction when set
+	RawArgs   []any
 }
 
 // OrderDirection represents sort direction
@@ -127,6 +148,7 @@ type JoinBuilder[T any] struct {
 type WhereGroupBuilder[T any] struct {
 	parent *QueryBuilder[T]
 	group  *WhereGroup
+	outer  *WhereGroupBuilder[T] // Enclosing group for nested groups, nil at the top level
 }
 
 // QueryResult represents the result of a database operation
@@ -153,7 +175,7 @@ func Query[T any](db *DB) *QueryBuilder[T] {
 		orders:      []*OrderClause{},
 		groupBys:    []string{},
 		havings:     []*WhereClause{},
-		relations:   []string{},
+		relations:   []*RelationClause{},
 		retryConfig: DefaultRetryConfig(),
 	}
 }
@@ -290,6 +312,61 @@ func (q *QueryBuilder[T]) WhereNotIn(column string, values []any) *QueryBuilder[
 	return q
 }
 
+// WhereAny adds a WHERE condition matching when the operator holds for at least one value (column op ANY(ARRAY[...])).
+// An operator outside arrayComparisonOperators fails the query when it is executed
+func (q *QueryBuilder[T]) WhereAny(column, operator string, values []any) *QueryBuilder[T] {
+	normalized, err := arrayOperator(operator)
+	if err != nil {
+		q.setErr(err)
+		return q
+	}
+	q.wheres = append(q.wheres, &WhereClause{
+		Column:     column,
+		Operator:   normalized,
+		Value:      values,
+		Quantifier: "ANY",
+	})
+	return q
+}
+
+// WhereAll adds a WHERE condition matching when the operator holds for every value (column op ALL(ARRAY[...])).
+// An operator outside arrayComparisonOperators fails the query when it is executed
+func (q *QueryBuilder[T]) WhereAll(column, operator string, values []any) *QueryBuilder[T] {
+	normalized, err := arrayOperator(operator)
+	if err != nil {
+		q.setErr(err)
+		return q
+	}
+	q.wheres = append(q.wheres, &WhereClause{
+		Column:     column,
+		Operator:   normalized,
+		Value:      values,
+		Quantifier: "ALL",
+	})
+	return q
+}
+
+// WhereBetween adds a WHERE BETWEEN condition; both bounds are inclusive
+func (q *QueryBuilder[T]) WhereBetween(column string, low, high any) *QueryBuilder[T] {
+	q.wheres = append(q.wheres, &WhereClause{
+		Column:   column,
+		Operator: "BETWEEN",
+		Value:    []any{low, high},
+	})
+	return q
+}
+
+// WhereNotBetween adds a WHERE NOT BETWEEN condition
+func (q *QueryBuilder[T]) WhereNotBetween(column string, low, high any) *QueryBuilder[T] {
+	q.wheres = append(q.wheres, &WhereClause{
+		Column:   column,
+		Operator: "BETWEEN",
+		Value:    []any{low, high},
+		Negate:   true,
+	})
+	return q
+}
+
 // WhereNull adds a WHERE IS NULL condition
 func (q *QueryBuilder[T]) WhereNull(column string) *QueryBuilder[T] {
 	q.wheres = append(q.wheres, &WhereClause{
@@ -346,6 +423,14 @@ func (q *QueryBuilder[T]) Or() *WhereGroupBuilder[T] {
 	return q.WhereGroup("OR")
 }
 
+// GroupConnector sets how the top-level WHERE groups combine with each other: "AND" (the default) or "OR".
+// The groups are always parenthesized together and ANDed with the other conditions, so
+// Where("a", 1) with groups x and y OR'd renders as: a = 1 AND ((x) OR (y))
+func (q *QueryBuilder[T]) GroupConnector(connector string) *QueryBuilder[T] {
+	q.groupJoin = strings.ToUpper(strings.TrimSpace(connector))
+	return q
+}
+
 // OrderBy adds an ORDER BY clause
 func (q *QueryBuilder[T]) OrderBy(column string, direction OrderDirection) *QueryBuilder[T] {
 	q.orders = append(q.orders, &OrderClause{
@@ -355,6 +440,15 @@ func (q *QueryBuilder[T]) OrderBy(column string, direction OrderDirection) *Quer
 	return q
 }
 
+// OrderByRaw adds a raw ORDER BY expression with placeholders, e.g. a rank computed from user input
+func (q *QueryBuilder[T]) OrderByRaw(sql string, args ...any) *QueryBuilder[T] {
+	q.orders = append(q.orders, &OrderClause{
+		RawSQL:  sql,
+		RawArgs: args,
+	})
+	return q
+}
+
 // GroupBy adds a GROUP BY clause
 func (q *QueryBuilder[T]) GroupBy(columns ...string) *QueryBuilder[T] {
 	q.groupBys = append(q.groupBys, columns...)
@@ -385,7 +479,7 @@ func (q *QueryBuilder[T]) Offset(offset int) *QueryBuilder[T] {
 
 // Relation specifies a relation to preload (Bun style)
 func (q *QueryBuilder[T]) Relation(relation string, apply ...func(*bun.SelectQuery) *bun.SelectQuery) *QueryBuilder[T] {
-	q.relations = append(q.relations, relation)
+	q.relations = append(q.relations, &RelationClause{Name: relation, Apply: apply})
 	return q
 }
 
@@ -395,6 +489,24 @@ func (q *QueryBuilder[T]) ForUpdate() *QueryBuilder[T] {
 	return q
 }
 
+// SoftDelete makes Delete set deleted_at instead of removing rows, and excludes soft-deleted
+// rows from every other query unless WithTrashed is used. The model must have a deleted_at column
+func (q *QueryBuilder[T]) SoftDelete() *QueryBuilder[T] {
+	q.softDelete = true
+	return q
+}
+
+// WithTrashed includes soft-deleted rows in a SoftDelete query
+func (q *QueryBuilder[T]) WithTrashed() *QueryBuilder[T] {
+	q.withTrashed = true
+	return q
+}
+
+// excludesTrashed reports whether soft-deleted rows are filtered out of the query
+func (q *QueryBuilder[T]) excludesTrashed() bool {
+	return q.softDelete && !q.withTrashed
+}
+
 // Timeout sets a timeout for the query
 func (q *QueryBuilder[T]) Timeout(duration time.Duration) *QueryBuilder[T] {
 	q.timeout = duration
@@ -480,8 +592,34 @@ func (w *WhereGroupBuilder[T]) WhereRaw(sql string, args ...any) *WhereGroupBuil
 	return w
 }
 
-// End completes the group builder and returns to the query builder
+// Group starts a nested group, rendered in parentheses inside this group
+func (w *WhereGroupBuilder[T]) Group(connector string) *WhereGroupBuilder[T] {
+	return &WhereGroupBuilder[T]{
+		parent: w.parent,
+		group: &WhereGroup{
+			Conditions: []*WhereClause{},
+			Groups:     []*WhereGroup{},
+			Connector:  connector,
+		},
+		outer: w,
+	}
+}
+
+// EndGroup completes a nested group and returns to the enclosing group
+func (w *WhereGroupBuilder[T]) EndGroup() *WhereGroupBuilder[T] {
+	if w.outer == nil {
+		// Top-level group, there is no enclosing group to return to
+		return w
+	}
+	w.outer.group.Groups = append(w.outer.group.Groups, w.group)
+	return w.outer
+}
+
+// End completes the group builder, closing any open nested groups, and returns to the query builder
 func (w *WhereGroupBuilder[T]) End() *QueryBuilder[T] {
+	if w.outer != nil {
+		return w.EndGroup().End()
+	}
 	w.parent.whereGroups = append(w.parent.whereGroups, w.group)
 	return w.parent
 }
@@ -547,6 +685,9 @@ func (q *QueryBuilder[T]) buildBunQueryWithModel(model any) *bun.SelectQuery {
 
 	// Apply WHERE conditions
 	query = q.applyWhereConditions(query)
+	if q.excludesTrashed() {
+		query = query.Where(notTrashedCondition)
+	}
 
 	// Apply JOINs
 	for _, join := range q.joins {
@@ -569,6 +710,10 @@ func (q *QueryBuilder[T]) buildBunQueryWithModel(model any) *bun.SelectQuery {
 
 	// Apply ORDER BY
 	for _, order := range q.orders {
+		if order.RawSQL != "" {
+			query = query.OrderExpr(order.RawSQL, order.RawArgs...)
+			continue
+		}
 		query = query.Order(fmt.Sprintf("%s %s", order.Column, order.Direction))
 	}
 
@@ -584,7 +729,7 @@ func (q *QueryBuilder[T]) buildBunQueryWithModel(model any) *bun.SelectQuery {
 
 	// Apply relations (preloading)
 	for _, relation := range q.relations {
-		query = query.Relation(relation)
+		query = query.Relation(relation.Name, relation.Apply...)
 	}
 
 	// Apply FOR UPDATE
@@ -612,6 +757,18 @@ func (q *QueryBuilder[T]) applyWhereConditions(query *bun.SelectQuery) *bun.Sele
 				continue
 			}
 
+			if where.Quantifier != "" {
+				condition, args := arrayComparison(where)
+				query = query.Where(condition, args...)
+				continue
+			}
+
+			if where.Operator == "BETWEEN" {
+				condition, args := betweenComparison(where)
+				query = query.Where(condition, args...)
+				continue
+			}
+
 			var condition string
 			if where.Negate {
 				condition = fmt.Sprintf("NOT (%s %s ?)", where.Column, where.Operator)
@@ -628,21 +785,114 @@ func (q *QueryBuilder[T]) applyWhereConditions(query *bun.SelectQuery) *bun.Sele
 	}
 
 	// Apply WHERE groups
-	for _, group := range q.whereGroups {
-		query = q.applyWhereGroup(query, group)
-	}
+	query = applyWhereGroups(query, q.whereGroups, q.groupJoin)
 
 	return query
 }
 
-// applyWhereGroup applies a WHERE group to a Bun query
-func (q *QueryBuilder[T]) applyWhereGroup(query *bun.SelectQuery, group *WhereGroup) *bun.SelectQuery {
-	if len(group.Conditions) == 0 && len(group.Groups) == 0 {
+// arrayComparisonOperators lists the operators allowed in WhereAny/WhereAll since they are written into the SQL verbatim
+var arrayComparisonOperators = map[string]bool{
+	"=": true, "<>": true, "!=": true, "<": true, "<=": true, ">": true, ">=": true,
+	"LIKE": true, "ILIKE": true, "NOT LIKE": true, "NOT ILIKE": true,
+}
+
+// arrayOperator normalizes a WhereAny/WhereAll operator. An unknown operator is rejected rather than being
+// interpolated into the SQL or silently matching nothing
+func arrayOperator(operator string) (string, error) {
+	normalized := strings.ToUpper(strings.TrimSpace(operator))
+	if !arrayComparisonOperators[normalized] {
+		return "", fmt.Errorf("database: unsupported operator %q for ANY/ALL comparison", operator)
+	}
+	return normalized, nil
+}
+
+// setErr records the first error made while building the query
+func (q *QueryBuilder[T]) setErr(err error) {
+	if q.err == nil {
+		q.err = err
+	}
+}
+
+// arrayComparison builds the SQL for a WhereAny/WhereAll clause, whose operator was checked when it was added
+// An empty list follows Postgres semantics: ANY never matches, ALL always matches
+func arrayComparison(where *WhereClause) (string, []any) {
+	operator := where.Operator
+
+	values, _ := where.Value.([]any)
+	if len(values) == 0 {
+		if where.Quantifier == "ALL" {
+			return "TRUE", nil
+		}
+		return "FALSE", nil
+	}
+
+	placeholders := make([]string, len(values))
+	for i := range values {
+		placeholders[i] = "?"
+	}
+
+	condition := fmt.Sprintf("%s %s %s(ARRAY[%s])", where.Column, operator, where.Quantifier, joinStrings(placeholders, ", "))
+	if where.Negate {
+		condition = "NOT (" + condition + ")"
+	}
+	return condition, values
+}
+
+// betweenComparison builds the SQL for a WhereBetween/WhereNotBetween clause
+func betweenComparison(where *WhereClause) (string, []any) {
+	operator := "BETWEEN"
+	if where.Negate {
+		operator = "NOT BETWEEN"
+	}
+	return fmt.Sprintf("%s %s ? AND ?", where.Column, operator), where.Value.([]any)
+}
+
+// whereQuery is the part of the Bun select, update and delete queries used to apply WHERE groups
+type whereQuery[Q any] interface {
+	Where(query string, args ...any) Q
+	WhereOr(query string, args ...any) Q
+	WhereGroup(sep string, fn func(Q) Q) Q
+}
+
+// applyWhereGroups applies the top-level WHERE groups to a Bun query. With the "OR" connector the
+// groups are OR'd inside one parenthesized group, otherwise each group is ANDed like any other condition
+func applyWhereGroups[Q whereQuery[Q]](query Q, groups []*WhereGroup, connector string) Q {
+	if connector != "OR" {
+		for _, group := range groups {
+			if groupSQL, args, ok := whereGroupSQL(group); ok {
+				query = query.Where(groupSQL, args...)
+			}
+		}
+		return query
+	}
+
+	var rendered []*WhereGroup
+	for _, group := range groups {
+		if _, _, ok := whereGroupSQL(group); ok {
+			rendered = append(rendered, group)
+		}
+	}
+	if len(rendered) == 0 {
 		return query
 	}
 
+	return query.WhereGroup(" AND ", func(q Q) Q {
+		for i, group := range rendered {
+			groupSQL, args, _ := whereGroupSQL(group)
+			if i == 0 {
+				q = q.Where(groupSQL, args...)
+			} else {
+				q = q.WhereOr(groupSQL, args...)
+			}
+		}
+		return q
+	})
+}
+
+// whereGroupSQL renders a WHERE group and its nested groups as a parenthesized condition.
+// ok is false when the group (including every nested group) has no conditions
+func whereGroupSQL(group *WhereGroup) (sql string, args []any, ok bool) {
 	var conditions []string
-	var args []any
 
 	// Build conditions
 	for _, cond := range group.Conditions {
@@ -661,16 +911,24 @@ func (q *QueryBuilder[T]) applyWhereGroup(query *bun.SelectQuery, group *WhereGr
 		}
 	}
 
-	// Build group SQL
-	if len(conditions) > 0 {
-		groupSQL := "(" + joinStrings(conditions, " "+group.Connector+" ") + ")"
-		if group.Negate {
-			groupSQL = "NOT " + groupSQL
+	// Nested groups join the conditions with this group's connector
+	for _, nested := range group.Groups {
+		if nestedSQL, nestedArgs, ok := whereGroupSQL(nested); ok {
+			conditions = append(conditions, nestedSQL)
+			args = append(args, nestedArgs...)
 		}
-		query = query.Where(groupSQL, args...)
 	}
 
-	return query
+	if len(conditions) == 0 {
+		return "", nil, false
+	}
+
+	// Build group SQL
+	sql = "(" + joinStrings(conditions, " "+group.Connector+" ") + ")"
+	if group.Negate {
+		sql = "NOT " + sql
+	}
+	return sql, args, true
 }
 
 // Helper function to join strings
@@ -3,12 +3,18 @@ package database
 import (
 	"context"
 	"fmt"
+	"slices"
+	"time"
 
 	"github.com/uptrace/bun"
 )
 
 // All executes the query and returns all matching records with automatic retry
 func (q *QueryBuilder[T]) All(ctx context.Context) ([]T, error) {
+	if q.err != nil {
+		return nil, q.err
+	}
+
 	var data []T
 
 	// Apply timeout if specified
@@ -43,6 +49,10 @@ func (q *QueryBuilder[T]) All(ctx context.Context) ([]T, error) {
 
 // First executes the query and returns the first matching record with automatic retry
 func (q *QueryBuilder[T]) First(ctx context.Context) (*T, error) {
+	if q.err != nil {
+		return nil, q.err
+	}
+
 	var data T
 
 	// Apply timeout if specified
@@ -78,6 +88,10 @@ func (q *QueryBuilder[T]) First(ctx context.Context) (*T, error) {
 
 // Count executes the query and returns the count of matching records with automatic retry
 func (q *QueryBuilder[T]) Count(ctx context.Context) (int, error) {
+	if q.err != nil {
+		return 0, q.err
+	}
+
 	var count int
 
 	// Apply timeout if specified
@@ -173,6 +187,10 @@ func (q *QueryBuilder[T]) InsertMany(ctx context.Context, data []T) ([]T, error)
 
 // Update updates records matching the query with automatic retry
 func (q *QueryBuilder[T]) Update(ctx context.Context, data any) (int, error) {
+	if q.err != nil {
+		return 0, q.err
+	}
+
 	var rowsAffected int64
 
 	// Apply timeout if specified
@@ -225,6 +243,10 @@ func (q *QueryBuilder[T]) Update(ctx context.Context, data any) (int, error) {
 
 // UpdateReturning updates records and returns them with automatic retry
 func (q *QueryBuilder[T]) UpdateReturning(ctx context.Context, data any) ([]T, error) {
+	if q.err != nil {
+		return nil, q.err
+	}
+
 	var results []T
 
 	// Apply timeout if specified
@@ -273,8 +295,17 @@ func (q *QueryBuilder[T]) UpdateReturning(ctx context.Context, data any) ([]T, e
 	return results, nil
 }
 
-// Delete deletes records matching the query with automatic retry
+// Delete deletes records matching the query with automatic retry.
+// On a SoftDelete query it sets deleted_at on the matching rows that are not already deleted
 func (q *QueryBuilder[T]) Delete(ctx context.Context) (int, error) {
+	if q.err != nil {
+		return 0, q.err
+	}
+
+	if q.softDelete {
+		return q.untrashed().Update(ctx, map[string]any{SoftDeleteColumn: time.Now()})
+	}
+
 	var rowsAffected int64
 
 	// Apply timeout if specified
@@ -311,8 +342,17 @@ func (q *QueryBuilder[T]) Delete(ctx context.Context) (int, error) {
 	return int(rowsAffected), nil
 }
 
-// DeleteReturning deletes records and returns them with automatic retry
+// DeleteReturning deletes records and returns them with automatic retry.
+// On a SoftDelete query it returns the rows it soft-deleted
 func (q *QueryBuilder[T]) DeleteReturning(ctx context.Context) ([]T, error) {
+	if q.err != nil {
+		return nil, q.err
+	}
+
+	if q.softDelete {
+		return q.untrashed().UpdateReturning(ctx, map[string]any{SoftDeleteColumn: time.Now()})
+	}
+
 	var results []T
 
 	// Apply timeout if specified
@@ -349,13 +389,44 @@ func (q *QueryBuilder[T]) DeleteReturning(ctx context.Context) ([]T, error) {
 	return results, nil
 }
 
+// Restore clears deleted_at on the soft-deleted records matching the query
+func (q *QueryBuilder[T]) Restore(ctx context.Context) (int, error) {
+	restore := *q
+	restore.softDelete = false
+	restore.wheres = append(slices.Clone(q.wheres), &WhereClause{Column: SoftDeleteColumn, Operator: "IS NOT NULL"})
+	return restore.Update(ctx, map[string]any{SoftDeleteColumn: nil})
+}
+
+// untrashed returns a copy of the query that ignores WithTrashed, so a soft delete never re-stamps deleted rows
+func (q *QueryBuilder[T]) untrashed() *QueryBuilder[T] {
+	scoped := *q
+	scoped.withTrashed = false
+	return &scoped
+}
+
 // applyWhereConditionsToUpdate applies WHERE conditions to a Bun UpdateQuery
 func (q *QueryBuilder[T]) applyWhereConditionsToUpdate(query *bun.UpdateQuery) *bun.UpdateQuery {
+	if q.excludesTrashed() {
+		query = query.Where(notTrashedCondition)
+	}
+
 	// Apply simple WHERE conditions
 	for _, where := range q.wheres {
 		if where.IsRaw {
 			query = query.Where(where.RawSQL, where.RawArgs...)
 		} else {
+			if where.Quantifier != "" {
+				condition, args := arrayComparison(where)
+				query = query.Where(condition, args...)
+				continue
+			}
+
+			if where.Operator == "BETWEEN" {
+				condition, args := betweenComparison(where)
+				query = query.Where(condition, args...)
+				continue
+			}
+
 			var condition string
 			if where.Negate {
 				condition = fmt.Sprintf("NOT (%s %s ?)", where.Column, where.Operator)
@@ -372,56 +443,34 @@ func (q *QueryBuilder[T]) applyWhereConditionsToUpdate(query *bun.UpdateQuery) *
 	}
 
 	// Apply WHERE groups
-	for _, group := range q.whereGroups {
-		query = q.applyWhereGroupToUpdate(query, group)
-	}
-
-	return query
-}
-
-// applyWhereGroupToUpdate applies a WHERE group to a Bun UpdateQuery
-func (q *QueryBuilder[T]) applyWhereGroupToUpdate(query *bun.UpdateQuery, group *WhereGroup) *bun.UpdateQuery {
-	if len(group.Conditions) == 0 && len(group.Groups) == 0 {
-		return query
-	}
-
-	var conditions []string
-	var args []any
-
-	for _, cond := range group.Conditions {
-		if cond.IsRaw {
-			conditions = append(conditions, cond.RawSQL)
-			args = append(args, cond.RawArgs...)
-		} else {
-			var condStr string
-			if cond.Operator == "IS NULL" || cond.Operator == "IS NOT NULL" {
-				condStr = fmt.Sprintf("%s %s", cond.Column, cond.Operator)
-			} else {
-				condStr = fmt.Sprintf("%s %s ?", cond.Column, cond.Operator)
-				args = append(args, cond.Value)
-			}
-			conditions = append(conditions, condStr)
-		}
-	}
-
-	if len(conditions) > 0 {
-		groupSQL := "(" + joinStrings(conditions, " "+group.Connector+" ") + ")"
-		if group.Negate {
-			groupSQL = "NOT " + groupSQL
-		}
-		query = query.Where(groupSQL, args...)
-	}
+	query = applyWhereGroups(query, q.whereGroups, q.groupJoin)
 
 	return query
 }
 
 // applyWhereConditionsToDelete applies WHERE conditions to a Bun DeleteQuery
 func (q *QueryBuilder[T]) applyWhereConditionsToDelete(query *bun.DeleteQuery) *bun.DeleteQuery {
+	if q.excludesTrashed() {
+		query = query.Where(notTrashedCondition)
+	}
+
 	// Apply simple WHERE conditions
 	for _, where := range q.wheres {
 		if where.IsRaw {
 			query = query.Where(where.RawSQL, where.RawArgs...)
 		} else {
+			if where.Quantifier != "" {
+				condition, args := arrayComparison(where)
+				query = query.Where(condition, args...)
+				continue
+			}
+
+			if where.Operator == "BETWEEN" {
+				condition, args := betweenComparison(where)
+				query = query.Where(condition, args...)
+				continue
+			}
+
 			var condition string
 			if where.Negate {
 				condition = fmt.Sprintf("NOT (%s %s ?)", where.Column, where.Operator)
@@ -438,45 +487,7 @@ func (q *QueryBuilder[T]) applyWhereConditionsToDelete(query *bun.DeleteQuery) *
 	}
 
 	// Apply WHERE groups
-	for _, group := range q.whereGroups {
-		query = q.applyWhereGroupToDelete(query, group)
-	}
-
-	return query
-}
-
-// applyWhereGroupToDelete applies a WHERE group to a Bun DeleteQuery
-func (q *QueryBuilder[T]) applyWhereGroupToDelete(query *bun.DeleteQuery, group *WhereGroup) *bun.DeleteQuery {
-	if len(group.Conditions) == 0 && len(group.Groups) == 0 {
-		return query
-	}
-
-	var conditions []string
-	var args []any
-
-	for _, cond := range group.Conditions {
-		if cond.IsRaw {
-			conditions = append(conditions, cond.RawSQL)
-			args = append(args, cond.RawArgs...)
-		} else {
-			var condStr string
-			if cond.Operator == "IS NULL" || cond.Operator == "IS NOT NULL" {
-				condStr = fmt.Sprintf("%s %s", cond.Column, cond.Operator)
-			} else {
-				condStr = fmt.Sprintf("%s %s ?", cond.Column, cond.Operator)
-				args = append(args, cond.Value)
-			}
-			conditions = append(conditions, condStr)
-		}
-	}
-
-	if len(conditions) > 0 {
-		groupSQL := "(" + joinStrings(conditions, " "+group.Connector+" ") + ")"
-		if group.Negate {
-			groupSQL = "NOT " + groupSQL
-		}
-		query = query.Where(groupSQL, args...)
-	}
+	query = applyWhereGroups(query, q.whereGroups, q.groupJoin)
 
 	return query
 }
@@ -5,6 +5,7 @@ import (
 	"database/sql"
 	"errors"
 	"fmt"
+	"mamabloemetjes_server/lib"
 	"time"
 
 	"github.com/uptrace/bun"
@@ -68,6 +69,11 @@ func RawExec(db *DB, ctx context.Context, query string, args ...any) (int, error
 }
 
 // Transaction executes a function within a database transaction with automatic retry
+//
+// On a retryable error the whole closure runs again in a fresh transaction, so it must be safe to repeat:
+// generate IDs, order numbers and other one-off values before calling, and keep side effects
+// (emails, cache writes, external calls) out of the closure until Transaction has returned nil.
+// Use TransactionNoRetry for closures that must run at most once
 func Transaction(db *DB, ctx context.Context, fn func(bun.Tx) error) error {
 	return WithRetry(ctx, func() error {
 		return db.RunInTx(ctx, &sql.TxOptions{}, func(ctx context.Context, tx bun.Tx) error {
@@ -76,7 +82,16 @@ func Transaction(db *DB, ctx context.Context, fn func(bun.Tx) error) error {
 	})
 }
 
+// TransactionNoRetry executes a function within a database transaction exactly once
+// Retryable errors are returned to the caller instead of re-running the closure
+func TransactionNoRetry(db *DB, ctx context.Context, fn func(bun.Tx) error) error {
+	return db.RunInTx(ctx, &sql.TxOptions{}, func(ctx context.Context, tx bun.Tx) error {
+		return fn(tx)
+	})
+}
+
 // TransactionWithResult executes a function within a transaction and returns a result with automatic retry
+// The closure may run more than once; see Transaction
 func TransactionWithResult[T any](db *DB, ctx context.Context, fn func(bun.Tx) (T, error)) (T, error) {
 	var result T
 	err := WithRetry(ctx, func() error {
@@ -106,9 +121,29 @@ func ReadOnlyTransaction(db *DB, ctx context.Context, fn func(bun.Tx) error) err
 
 // Pagination represents pagination parameters
 type Pagination struct {
-	Page     int `json:"page" validate:"required,min=1"`
-	PageSize int `json:"page_size" validate:"required,min=1,max=100"`
-	Total    int `json:"total" validate:"min=0"`
+	Page       int  `json:"page" validate:"required,min=1"`
+	PageSize   int  `json:"page_size" validate:"required,min=1,max=100"`
+	Total      int  `json:"total" validate:"min=0"`
+	TotalPages int  `json:"total_pages" validate:"min=0"`
+	HasNext    bool `json:"has_next"`
+	HasPrev    bool `json:"has_prev"`
+}
+
+// NewPagination builds pagination metadata, deriving the page count and navigation flags from total
+func NewPagination(page, pageSize, total int) Pagination {
+	totalPages := 0
+	if pageSize > 0 {
+		totalPages = (total + pageSize - 1) / pageSize
+	}
+
+	return Pagination{
+		Page:       page,
+		PageSize:   pageSize,
+		Total:      total,
+		TotalPages: totalPages,
+		HasNext:    page < totalPages,
+		HasPrev:    page > 1,
+	}
 }
 
 // PaginationResult wraps paginated data with metadata
@@ -119,15 +154,7 @@ type PaginationResult[T any] struct {
 
 // Paginate applies pagination to a query builder and returns results with metadata
 func Paginate[T any](q *QueryBuilder[T], ctx context.Context, page, pageSize int) (*PaginationResult[T], error) {
-	if page < 1 {
-		page = 1
-	}
-	if pageSize < 1 {
-		pageSize = 10
-	}
-	if pageSize > 100 {
-		pageSize = 100 // Max page size
-	}
+	page, pageSize = lib.ClampPagination(page, pageSize)
 
 	// Get total count
 	total, err := q.Count(ctx)
@@ -145,12 +172,8 @@ func Paginate[T any](q *QueryBuilder[T], ctx context.Context, page, pageSize int
 	}
 
 	return &PaginationResult[T]{
-		Data: data,
-		Pagination: Pagination{
-			Page:     page,
-			PageSize: pageSize,
-			Total:    total,
-		},
+		Data:       data,
+		Pagination: NewPagination(page, pageSize, total),
 	}, nil
 }
 
@@ -200,20 +223,12 @@ func DeleteByID[T any](db *DB, ctx context.Context, id any) (int, error) {
 
 // SoftDelete performs a soft delete by setting deleted_at timestamp
 func SoftDelete[T any](db *DB, ctx context.Context, id any) (int, error) {
-	return Query[T](db).
-		Where("id", id).
-		Update(ctx, map[string]any{
-			"deleted_at": time.Now(),
-		})
+	return Query[T](db).SoftDelete().Where("id", id).Delete(ctx)
 }
 
 // Restore restores a soft-deleted record
 func Restore[T any](db *DB, ctx context.Context, id any) (int, error) {
-	return Query[T](db).
-		Where("id", id).
-		Update(ctx, map[string]any{
-			"deleted_at": nil,
-		})
+	return Query[T](db).SoftDelete().Where("id", id).Restore(ctx)
 }
 
 // ExcludeSoftDeleted adds a WHERE clause to exclude soft-deleted records
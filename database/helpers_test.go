@@ -0,0 +1,27 @@
+package database
+
+import "testing"
+
+func TestNewPagination(t *testing.T) {
+	tests := []struct {
+		name     string
+		page     int
+		pageSize int
+		total    int
+		expected Pagination
+	}{
+		{"first of several pages", 1, 20, 45, Pagination{Page: 1, PageSize: 20, Total: 45, TotalPages: 3, HasNext: true}},
+		{"middle page", 2, 20, 45, Pagination{Page: 2, PageSize: 20, Total: 45, TotalPages: 3, HasNext: true, HasPrev: true}},
+		{"last page", 3, 20, 45, Pagination{Page: 3, PageSize: 20, Total: 45, TotalPages: 3, HasPrev: true}},
+		{"exactly full pages", 2, 20, 40, Pagination{Page: 2, PageSize: 20, Total: 40, TotalPages: 2, HasPrev: true}},
+		{"no results", 1, 20, 0, Pagination{Page: 1, PageSize: 20}},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			if got := NewPagination(tt.page, tt.pageSize, tt.total); got != tt.expected {
+				t.Fatalf("expected %+v, got %+v", tt.expected, got)
+			}
+		})
+	}
+}
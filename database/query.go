@@ -5,6 +5,7 @@ import (
 	"database/sql"
 	"fmt"
 	"mamabloemetjes_server/config"
+	"sync/atomic"
 	"time"
 
 	"github.com/MonkyMars/gecho"
@@ -34,6 +35,13 @@ func Connect(logger *gecho.Logger) (*DB, error) {
 		dbCfg.Name,
 	)
 
+	return ConnectDSN(logger, dsn)
+}
+
+// ConnectDSN establishes a connection to the database at dsn, using the pool settings from the centralized configuration
+func ConnectDSN(logger *gecho.Logger, dsn string) (*DB, error) {
+	dbCfg := config.GetConfig().Database
+
 	// Create pgdriver connector with connection pool settings
 	connector := pgdriver.NewConnector(
 		pgdriver.WithDSN(dsn),
@@ -110,6 +118,31 @@ func (db *DB) GetStats() sql.DBStats {
 	return db.sqlDB.Stats()
 }
 
+type queryCounterKey struct{}
+
+// WithQueryCounter returns a context that counts the queries run with it, or any context derived from it
+// Only queries given this context are counted, queries run with context.Background() are not
+func WithQueryCounter(ctx context.Context) context.Context {
+	return context.WithValue(ctx, queryCounterKey{}, new(atomic.Int64))
+}
+
+// QueryCount returns the number of queries counted on ctx so far, 0 when ctx has no counter
+func QueryCount(ctx context.Context) int {
+	counter, ok := ctx.Value(queryCounterKey{}).(*atomic.Int64)
+	if !ok {
+		return 0
+	}
+	return int(counter.Load())
+}
+
+// CountQueries runs fn with a counting context and returns how many queries it ran
+// Meant for tests that hold a code path to a query budget, to catch N+1 regressions
+func CountQueries(ctx context.Context, fn func(ctx context.Context)) int {
+	ctx = WithQueryCounter(ctx)
+	fn(ctx)
+	return QueryCount(ctx)
+}
+
 // queryHook implements bun.QueryHook to monitor queries and handle errors
 type queryHook struct {
 	logger *gecho.Logger
@@ -122,6 +155,10 @@ func (h *queryHook) BeforeQuery(ctx context.Context, event *bun.QueryEvent) cont
 func (h *queryHook) AfterQuery(ctx context.Context, event *bun.QueryEvent) {
 	duration := time.Since(event.StartTime)
 
+	if counter, ok := ctx.Value(queryCounterKey{}).(*atomic.Int64); ok {
+		counter.Add(1)
+	}
+
 	// Log slow queries (over 400ms)
 	if duration > 400*time.Millisecond {
 		h.logger.Warn("Slow database query detected",
@@ -17,6 +17,12 @@ type RetryConfig struct {
 	MaxDelay     time.Duration
 	Multiplier   float64
 	EnableRetry  bool
+
+	// Retryable decides which errors are retried, isRetryableError (transient database errors) when nil
+	Retryable func(error) bool
+	// RetryAfter returns the wait an error asks for (e.g. a provider's Retry-After), 0 when none.
+	// It replaces the backoff delay when longer; a wait above MaxDelay ends the retries
+	RetryAfter func(error) time.Duration
 }
 
 // DefaultRetryConfig returns sensible defaults for retry behavior
@@ -152,6 +158,11 @@ func RetryWithBackoff(ctx context.Context, config RetryConfig, operation func()
 		return operation()
 	}
 
+	retryable := config.Retryable
+	if retryable == nil {
+		retryable = isRetryableError
+	}
+
 	var lastErr error
 	delay := config.InitialDelay
 
@@ -167,7 +178,7 @@ func RetryWithBackoff(ctx context.Context, config RetryConfig, operation func()
 		lastErr = err
 
 		// Check if we should retry
-		if !isRetryableError(err) {
+		if !retryable(err) {
 			return err
 		}
 
@@ -176,11 +187,20 @@ func RetryWithBackoff(ctx context.Context, config RetryConfig, operation func()
 			break
 		}
 
+		wait := delay
+		if config.RetryAfter != nil {
+			if requested := config.RetryAfter(err); requested > config.MaxDelay {
+				return err
+			} else if requested > wait {
+				wait = requested
+			}
+		}
+
 		// Check if context is cancelled
 		select {
 		case <-ctx.Done():
 			return ctx.Err()
-		case <-time.After(delay):
+		case <-time.After(wait):
 			// Calculate next delay with exponential backoff
 			delay = time.Duration(float64(delay) * config.Multiplier)
 			if delay > config.MaxDelay {
@@ -0,0 +1,41 @@
+package database
+
+import (
+	"context"
+	"errors"
+	"testing"
+	"time"
+
+	"github.com/jackc/pgx/v5/pgconn"
+)
+
+func TestRetryWithBackoffRetriesOnlyTransientErrors(t *testing.T) {
+	config := DefaultRetryConfig()
+	config.InitialDelay = time.Millisecond
+
+	tests := []struct {
+		name     string
+		err      error
+		attempts int
+	}{
+		{"serialization failure", &pgconn.PgError{Code: "40001"}, config.MaxAttempts},
+		{"deadlock", &pgconn.PgError{Code: "40P01"}, config.MaxAttempts},
+		{"unique violation", &pgconn.PgError{Code: "23505"}, 1},
+		{"cancelled", context.Canceled, 1},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			attempts := 0
+			err := RetryWithBackoff(context.Background(), config, func() error {
+				attempts++
+				return tt.err
+			})
+			if !errors.Is(err, tt.err) {
+				t.Fatalf("expected %v, got %v", tt.err, err)
+			}
+			if attempts != tt.attempts {
+				t.Fatalf("expected %d attempts, got %d", tt.attempts, attempts)
+			}
+		})
+	}
+}
@@ -0,0 +1,77 @@
+package database_test
+
+import (
+	"context"
+	"mamabloemetjes_server/database"
+	"mamabloemetjes_server/lib"
+	"mamabloemetjes_server/structs/tables"
+	"mamabloemetjes_server/testutil"
+	"testing"
+	"time"
+
+	"github.com/google/uuid"
+)
+
+// seedOrder inserts a bare order; soft delete only needs the deleted_at column
+func seedOrder(t *testing.T, db *database.DB) uuid.UUID {
+	t.Helper()
+	order := &tables.Order{
+		Id:            uuid.New(),
+		OrderNumber:   lib.GenerateOrderNumber(),
+		AddressId:     uuid.New(),
+		PaymentStatus: tables.PaymentStatusUnpaid,
+		Status:        tables.OrderStatusPending,
+		Currency:      "EUR",
+		CreatedAt:     time.Now(),
+		UpdatedAt:     time.Now(),
+	}
+	if _, err := db.NewInsert().Model(order).Exec(context.Background()); err != nil {
+		t.Fatalf("failed to seed order: %v", err)
+	}
+	return order.Id
+}
+
+func TestSoftDeleteRestoreAndWithTrashed(t *testing.T) {
+	db := testutil.DB(t)
+	ctx := context.Background()
+	deleted, kept := seedOrder(t, db), seedOrder(t, db)
+
+	deletedCount, err := database.Query[tables.Order](db).SoftDelete().Where("id", deleted).Delete(ctx)
+	if err != nil || deletedCount != 1 {
+		t.Fatalf("expected one order soft-deleted, got %d (err %v)", deletedCount, err)
+	}
+
+	// The row is still there, only hidden
+	if count, err := db.NewSelect().Model((*tables.Order)(nil)).Count(ctx); err != nil || count != 2 {
+		t.Fatalf("expected both rows to remain in the table, got %d (err %v)", count, err)
+	}
+
+	visible, err := database.Query[tables.Order](db).SoftDelete().All(ctx)
+	if err != nil {
+		t.Fatalf("All: %v", err)
+	}
+	if len(visible) != 1 || visible[0].Id != kept {
+		t.Fatalf("expected only the kept order to be visible, got %d orders", len(visible))
+	}
+	if order, err := database.Query[tables.Order](db).SoftDelete().Where("id", deleted).First(ctx); err == nil && order != nil {
+		t.Fatal("expected First to skip the soft-deleted order")
+	}
+
+	trashed, err := database.Query[tables.Order](db).SoftDelete().WithTrashed().Where("id", deleted).First(ctx)
+	if err != nil || trashed == nil || trashed.DeletedAt == nil {
+		t.Fatalf("expected WithTrashed to return the soft-deleted order, got %v (err %v)", trashed, err)
+	}
+
+	// Deleting again does not touch rows already in the trash
+	if again, err := database.Query[tables.Order](db).SoftDelete().WithTrashed().Where("id", deleted).Delete(ctx); err != nil || again != 0 {
+		t.Fatalf("expected a second soft delete to change nothing, got %d (err %v)", again, err)
+	}
+
+	restored, err := database.Query[tables.Order](db).SoftDelete().Where("id", deleted).Restore(ctx)
+	if err != nil || restored != 1 {
+		t.Fatalf("expected one order restored, got %d (err %v)", restored, err)
+	}
+	if order, err := database.Query[tables.Order](db).SoftDelete().Where("id", deleted).First(ctx); err != nil || order == nil || order.DeletedAt != nil {
+		t.Fatalf("expected the restored order to be visible again, got %v (err %v)", order, err)
+	}
+}
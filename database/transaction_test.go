@@ -0,0 +1,89 @@
+package database_test
+
+import (
+	"context"
+	"errors"
+	"mamabloemetjes_server/database"
+	"mamabloemetjes_server/lib"
+	"mamabloemetjes_server/structs/tables"
+	"mamabloemetjes_server/testutil"
+	"testing"
+	"time"
+
+	"github.com/google/uuid"
+	"github.com/jackc/pgx/v5/pgconn"
+	"github.com/uptrace/bun"
+)
+
+// serializationFailure is a transient conflict Transaction retries
+var serializationFailure = &pgconn.PgError{Code: "40001", Message: "could not serialize access"}
+
+// insertOrder inserts a bare order within tx, standing in for the writes of a real closure
+func insertOrder(ctx context.Context, tx bun.Tx) error {
+	_, err := tx.NewInsert().Model(&tables.Order{
+		Id:            uuid.New(),
+		OrderNumber:   lib.GenerateOrderNumber(),
+		AddressId:     uuid.New(),
+		PaymentStatus: tables.PaymentStatusUnpaid,
+		Status:        tables.OrderStatusPending,
+		Currency:      "EUR",
+		CreatedAt:     time.Now(),
+		UpdatedAt:     time.Now(),
+	}).Exec(ctx)
+	return err
+}
+
+func TestRetriedTransactionAppliesSideEffectsOnce(t *testing.T) {
+	db := testutil.DB(t)
+	ctx := context.Background()
+
+	attempts, emailsSent := 0, 0
+	err := database.Transaction(db, ctx, func(tx bun.Tx) error {
+		attempts++
+		if err := insertOrder(ctx, tx); err != nil {
+			return err
+		}
+		if attempts == 1 {
+			return serializationFailure
+		}
+		return nil
+	})
+	if err != nil {
+		t.Fatalf("Transaction: %v", err)
+	}
+	// The side effect runs once the transaction has committed, never from within the closure
+	emailsSent++
+
+	if attempts != 2 {
+		t.Fatalf("expected the closure to be retried once, ran %d times", attempts)
+	}
+	if orders, err := db.NewSelect().Model((*tables.Order)(nil)).Count(ctx); err != nil || orders != 1 {
+		t.Fatalf("expected the first attempt to be rolled back, got %d orders (err %v)", orders, err)
+	}
+	if emailsSent != 1 {
+		t.Fatalf("expected one email, got %d", emailsSent)
+	}
+}
+
+func TestTransactionNoRetryRunsOnce(t *testing.T) {
+	db := testutil.DB(t)
+	ctx := context.Background()
+
+	attempts := 0
+	err := database.TransactionNoRetry(db, ctx, func(tx bun.Tx) error {
+		attempts++
+		if err := insertOrder(ctx, tx); err != nil {
+			return err
+		}
+		return serializationFailure
+	})
+	if !errors.Is(err, serializationFailure) {
+		t.Fatalf("expected the retryable error to be returned, got %v", err)
+	}
+	if attempts != 1 {
+		t.Fatalf("expected the closure to run once, ran %d times", attempts)
+	}
+	if orders, err := db.NewSelect().Model((*tables.Order)(nil)).Count(ctx); err != nil || orders != 0 {
+		t.Fatalf("expected the attempt to be rolled back, got %d orders (err %v)", orders, err)
+	}
+}
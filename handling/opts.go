@@ -1,14 +1,18 @@
 package handling
 
 import (
+	"mamabloemetjes_server/lib"
 	"mamabloemetjes_server/services"
 	"net/http"
+	"net/url"
 	"strconv"
 	"strings"
 	"time"
 )
 
-// parseProductListOptions parses HTTP query parameters into ProductListOptions
+// ParseProductListOptions parses HTTP query parameters into ProductListOptions
+// Every product route parses its query through here so all parameters are sanitized the same way:
+// control characters are stripped and values are trimmed; keyword-like values are also lowercased
 func ParseProductListOptions(r *http.Request) (*services.ProductListOptions, error) {
 	query := r.URL.Query()
 
@@ -20,61 +24,57 @@ func ParseProductListOptions(r *http.Request) (*services.ProductListOptions, err
 	opts := &services.ProductListOptions{}
 	var err error
 	var val64 uint64
-	var valInt int
 	var valBool bool
 
 	// Parse pagination parameters
-	if page := query.Get("page"); page != "" {
-		if valInt, err = strconv.Atoi(page); err != nil {
-			return nil, err
-		}
-		opts.Page = valInt
-	}
-
-	if pageSize := query.Get("page_size"); pageSize != "" {
-		if valInt, err = strconv.Atoi(pageSize); err != nil {
-			return nil, err
-		}
-		opts.PageSize = valInt
-	}
+	opts.Page, opts.PageSize = lib.ParsePagination(r)
 
 	// Parse boolean filters
-	if isActive := query.Get("is_active"); isActive != "" {
+	if isActive := keyword(query, "is_active"); isActive != "" {
 		if valBool, err = strconv.ParseBool(isActive); err != nil {
 			return nil, err
 		}
 		opts.IsActive = &valBool
 	}
 
-	if searchTerm := query.Get("search"); searchTerm != "" {
+	if searchTerm := lib.SanitizeString(query.Get("search"), false, true); searchTerm != "" {
 		opts.SearchTerm = searchTerm
 	}
 
+	if searchMode := keyword(query, "search_mode"); searchMode != "" {
+		opts.SearchMode = services.SearchMode(searchMode)
+	}
+
 	// Parse price filters
-	if minPrice := query.Get("min_price"); minPrice != "" {
+	if minPrice := value(query, "min_price"); minPrice != "" {
 		if val64, err = strconv.ParseUint(minPrice, 10, 64); err != nil {
 			return nil, err
 		}
 		opts.MinPrice = &val64
 	}
 
-	if maxPrice := query.Get("max_price"); maxPrice != "" {
+	if maxPrice := value(query, "max_price"); maxPrice != "" {
 		if val64, err = strconv.ParseUint(maxPrice, 10, 64); err != nil {
 			return nil, err
 		}
 		opts.MaxPrice = &val64
 	}
 
-	if skus := query.Get("skus"); skus != "" {
+	if productType := keyword(query, "product_type"); productType != "" {
+		opts.ProductType = strings.ToLower(productType)
+	}
+
+	// SKUs are matched exactly, so their case is kept
+	if skus := value(query, "skus"); skus != "" {
 		opts.SKUs = splitAndTrim(skus)
 	}
 
-	if excludeSKUs := query.Get("exclude_skus"); excludeSKUs != "" {
+	if excludeSKUs := value(query, "exclude_skus"); excludeSKUs != "" {
 		opts.ExcludeSKUs = splitAndTrim(excludeSKUs)
 	}
 
 	// Parse date filters
-	if createdAfter := query.Get("created_after"); createdAfter != "" {
+	if createdAfter := value(query, "created_after"); createdAfter != "" {
 		t, err := time.Parse(time.RFC3339, createdAfter)
 		if err != nil {
 			return nil, err
@@ -82,7 +82,7 @@ func ParseProductListOptions(r *http.Request) (*services.ProductListOptions, err
 		opts.CreatedAfter = &t
 	}
 
-	if createdBefore := query.Get("created_before"); createdBefore != "" {
+	if createdBefore := value(query, "created_before"); createdBefore != "" {
 		t, err := time.Parse(time.RFC3339, createdBefore)
 		if err != nil {
 			return nil, err
@@ -91,17 +91,17 @@ func ParseProductListOptions(r *http.Request) (*services.ProductListOptions, err
 	}
 
 	// Parse sorting parameters
-	if sortBy := query.Get("sort_by"); sortBy != "" {
+	if sortBy := keyword(query, "sort_by"); sortBy != "" {
 		opts.SortBy = sortBy
 	}
 
-	if sortDirection := query.Get("sort_direction"); sortDirection != "" {
+	if sortDirection := keyword(query, "sort_direction"); sortDirection != "" {
 		// Avoid allocation by converting in-place if needed
 		opts.SortDirection = strings.ToUpper(sortDirection)
 	}
 
 	// Parse include_images flag
-	if includeImages := query.Get("include_images"); includeImages != "" {
+	if includeImages := keyword(query, "include_images"); includeImages != "" {
 		if valBool, err = strconv.ParseBool(includeImages); err != nil {
 			return nil, err
 		}
@@ -111,6 +111,16 @@ func ParseProductListOptions(r *http.Request) (*services.ProductListOptions, err
 	return opts, nil
 }
 
+// value returns a query parameter with control characters and surrounding whitespace removed
+func value(query url.Values, key string) string {
+	return strings.TrimSpace(lib.StripControlChars(query.Get(key)))
+}
+
+// keyword returns a query parameter sanitized into a single lowercase token
+func keyword(query url.Values, key string) string {
+	return lib.SanitizeString(query.Get(key), false, false)
+}
+
 // splitAndTrim splits a comma-separated string and trims whitespace efficiently
 func splitAndTrim(s string) []string {
 	if s == "" {
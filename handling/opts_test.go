@@ -0,0 +1,43 @@
+package handling
+
+import (
+	"net/http"
+	"net/http/httptest"
+	"net/url"
+	"slices"
+	"testing"
+)
+
+func TestParseProductListOptionsSanitizesControlCharacters(t *testing.T) {
+	query := url.Values{
+		"search":         {" Rode\x00 rozen\x1b "},
+		"search_mode":    {"Prefix\x07"},
+		"product_type":   {"\x00Wedding"},
+		"skus":           {"ROSE-01\x00, TULIP-02\x7f"},
+		"exclude_skus":   {"\x1bLILY-03"},
+		"min_price":      {"1000\x00"},
+		"created_after":  {"2026-01-01T00:00:00Z\x1b"},
+		"sort_by":        {"Price\x00"},
+		"sort_direction": {"desc\x07"},
+		"include_images": {"true\x00"},
+	}
+	r := httptest.NewRequest(http.MethodGet, "/products?"+query.Encode(), nil)
+
+	opts, err := ParseProductListOptions(r)
+	if err != nil {
+		t.Fatalf("expected control characters to be stripped before parsing, got %v", err)
+	}
+
+	if opts.SearchTerm != "rode rozen" {
+		t.Fatalf("expected the search term %q, got %q", "rode rozen", opts.SearchTerm)
+	}
+	if opts.SearchMode != "prefix" || opts.ProductType != "wedding" || opts.SortBy != "price" || opts.SortDirection != "DESC" {
+		t.Fatalf("expected sanitized keywords, got mode %q, type %q, sort %q %q", opts.SearchMode, opts.ProductType, opts.SortBy, opts.SortDirection)
+	}
+	if !slices.Equal(opts.SKUs, []string{"ROSE-01", "TULIP-02"}) || !slices.Equal(opts.ExcludeSKUs, []string{"LILY-03"}) {
+		t.Fatalf("expected sanitized SKUs with their case kept, got %v and %v", opts.SKUs, opts.ExcludeSKUs)
+	}
+	if opts.MinPrice == nil || *opts.MinPrice != 1000 || opts.CreatedAfter == nil || !opts.IncludeImages {
+		t.Fatalf("expected the price, date and image flag to parse, got %+v", opts)
+	}
+}
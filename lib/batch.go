@@ -0,0 +1,125 @@
+package lib
+
+import (
+	"context"
+	"errors"
+	"net/http"
+
+	"github.com/MonkyMars/gecho"
+)
+
+// BatchItemResult is the outcome of a single item in a batch request
+type BatchItemResult struct {
+	Id     string `json:"id"`
+	Status int    `json:"status"`          // HTTP status the item would get if it had been sent on its own
+	Error  any    `json:"error,omitempty"` // User message or *ValidationError, never the raw error text
+	Data   any    `json:"data,omitempty"`
+}
+
+// Succeeded reports whether the item was processed successfully
+func (r BatchItemResult) Succeeded() bool {
+	return r.Status < http.StatusBadRequest
+}
+
+// BatchItemOK records a successful item, data is optional
+func BatchItemOK(id string, data any) BatchItemResult {
+	return BatchItemResult{Id: id, Status: http.StatusOK, Data: data}
+}
+
+// BatchItemFailed records a failed item with the status and client-safe data derived from err
+func BatchItemFailed(id string, err error) BatchItemResult {
+	return BatchItemResult{Id: id, Status: ErrorStatus(err), Error: ClientErrorData(err)}
+}
+
+// ErrorStatus maps an error to the HTTP status handlers use for it
+func ErrorStatus(err error) int {
+	var validationErr *ValidationError
+	switch {
+	case errors.As(err, &validationErr),
+		errors.Is(err, ErrProductUnavailable),
+		errors.Is(err, ErrMixedCurrencies),
+		errors.Is(err, ErrInvalidOrderDiscount):
+		return http.StatusBadRequest
+	case IsNotFound(err):
+		return http.StatusNotFound
+	case IsUniqueViolation(err),
+		errors.Is(err, ErrConflict),
+		errors.Is(err, ErrDuplicateSKU),
+		errors.Is(err, ErrInvalidStatusTransition),
+		errors.Is(err, ErrOrderNotEditable),
+		errors.Is(err, ErrOrderNotAdjustable),
+		errors.Is(err, ErrLastOrderLine),
+		errors.Is(err, ErrReservationReleased):
+		return http.StatusConflict
+	case IsTimeout(err):
+		return http.StatusGatewayTimeout
+	case errors.Is(err, context.Canceled):
+		return StatusClientClosedRequest
+	default:
+		return http.StatusInternalServerError
+	}
+}
+
+// RespondBatch writes the response for a batch request with per-item outcomes:
+// 200 when every item succeeded, 207 Multi-Status for a mix, and when every item failed
+// the items' shared status (or 500 if any failed on the server side, 400 otherwise)
+func RespondBatch(w http.ResponseWriter, results []BatchItemResult, successMessage, failureMessage string) {
+	succeeded := 0
+	for _, result := range results {
+		if result.Succeeded() {
+			succeeded++
+		}
+	}
+
+	data := map[string]any{
+		"results":   results,
+		"succeeded": succeeded,
+		"failed":    len(results) - succeeded,
+	}
+
+	switch {
+	case succeeded == len(results):
+		gecho.Success(w,
+			gecho.WithMessage(successMessage),
+			gecho.WithData(data),
+			gecho.Send(),
+		)
+	case succeeded > 0:
+		gecho.NewOK(w,
+			gecho.WithStatus(http.StatusMultiStatus),
+			gecho.WithMessage(failureMessage),
+			gecho.WithData(data),
+			gecho.Send(),
+		)
+	default:
+		gecho.NewErr(w,
+			gecho.WithStatus(batchFailureStatus(results)),
+			gecho.WithMessage(failureMessage),
+			gecho.WithData(data),
+			gecho.Send(),
+		)
+	}
+}
+
+// batchFailureStatus picks the status for a batch in which every item failed
+func batchFailureStatus(results []BatchItemResult) int {
+	status := results[0].Status
+	serverError := false
+	for _, result := range results {
+		if result.Status >= http.StatusInternalServerError {
+			serverError = true
+		}
+		if result.Status != status {
+			status = 0
+		}
+	}
+
+	switch {
+	case status != 0:
+		return status
+	case serverError:
+		return http.StatusInternalServerError
+	default:
+		return http.StatusBadRequest
+	}
+}
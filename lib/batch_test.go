@@ -0,0 +1,63 @@
+package lib
+
+import (
+	"encoding/json"
+	"errors"
+	"fmt"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+)
+
+func TestRespondBatch(t *testing.T) {
+	notFound := BatchItemFailed("c", ErrNotFound)
+	conflict := BatchItemFailed("d", fmt.Errorf("sku taken: %w", ErrDuplicateSKU))
+	broken := BatchItemFailed("e", errors.New("connection reset"))
+
+	tests := []struct {
+		name      string
+		results   []BatchItemResult
+		status    int
+		succeeded int
+	}{
+		{"all succeeded", []BatchItemResult{BatchItemOK("a", nil), BatchItemOK("b", nil)}, http.StatusOK, 2},
+		{"mixed", []BatchItemResult{BatchItemOK("a", nil), notFound, broken}, http.StatusMultiStatus, 1},
+		{"all failed alike", []BatchItemResult{notFound, notFound}, http.StatusNotFound, 0},
+		{"all failed on the client side", []BatchItemResult{notFound, conflict}, http.StatusBadRequest, 0},
+		{"all failed with a server error", []BatchItemResult{notFound, broken}, http.StatusInternalServerError, 0},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			w := httptest.NewRecorder()
+			RespondBatch(w, tt.results, "success.batch", "error.batch")
+
+			if w.Code != tt.status {
+				t.Fatalf("expected status %d, got %d", tt.status, w.Code)
+			}
+			var response struct {
+				Data struct {
+					Results   []BatchItemResult `json:"results"`
+					Succeeded int               `json:"succeeded"`
+					Failed    int               `json:"failed"`
+				} `json:"data"`
+			}
+			if err := json.Unmarshal(w.Body.Bytes(), &response); err != nil {
+				t.Fatalf("failed to decode the response: %v", err)
+			}
+			if response.Data.Succeeded != tt.succeeded || response.Data.Failed != len(tt.results)-tt.succeeded {
+				t.Fatalf("expected %d succeeded, got %+v", tt.succeeded, response.Data)
+			}
+			if len(response.Data.Results) != len(tt.results) {
+				t.Fatalf("expected an outcome per item, got %d", len(response.Data.Results))
+			}
+		})
+	}
+}
+
+func TestBatchItemFailedHidesRawError(t *testing.T) {
+	result := BatchItemFailed("a", errors.New(`pq: relation "products" does not exist`))
+	if result.Status != http.StatusInternalServerError || result.Error != "error.generic" {
+		t.Fatalf("expected a 500 with the generic message, got %+v", result)
+	}
+}
@@ -7,28 +7,18 @@ import (
 )
 
 // SetCookie sets a secure, HttpOnly cookie for authentication/session usage
+// Domain, Secure and SameSite come from the environment's cookie preset
 func SetCookie(key, val string, expiry time.Time, w http.ResponseWriter) {
-	isProduction := config.IsProduction()
-
-	sameSite := http.SameSiteLaxMode
-	secure := false
-	domain := ""
-
-	if isProduction {
-		// Required for cross-subdomain cookies (www <-> api)
-		sameSite = http.SameSiteNoneMode
-		secure = true
-		domain = ".roosvansharon.nl"
-	}
+	cookieCfg := config.GetConfig().Cookie
 
 	cookie := &http.Cookie{
 		Name:     key,
 		Value:    val,
 		Expires:  expiry,
 		Path:     "/",
-		Domain:   domain,
-		Secure:   secure,
-		SameSite: sameSite,
+		Domain:   cookieCfg.Domain,
+		Secure:   cookieCfg.Secure,
+		SameSite: cookieCfg.SameSite,
 		HttpOnly: true,
 	}
 
@@ -45,27 +35,17 @@ func GetCookieValue(key string, r *http.Request) (string, error) {
 
 // ClearCookie removes the cookie from the browser
 func ClearCookie(key string, w http.ResponseWriter) {
-	isProduction := config.IsProduction()
-
-	sameSite := http.SameSiteLaxMode
-	secure := false
-	domain := ""
-
-	if isProduction {
-		sameSite = http.SameSiteNoneMode
-		secure = true
-		domain = ".roosvansharon.nl"
-	}
+	cookieCfg := config.GetConfig().Cookie
 
 	cookie := &http.Cookie{
 		Name:     key,
 		Value:    "",
 		Path:     "/",
-		Domain:   domain,
+		Domain:   cookieCfg.Domain,
 		Expires:  time.Now().Add(-time.Hour),
 		MaxAge:   -1,
-		Secure:   secure,
-		SameSite: sameSite,
+		Secure:   cookieCfg.Secure,
+		SameSite: cookieCfg.SameSite,
 		HttpOnly: true,
 	}
 
@@ -74,18 +54,7 @@ func ClearCookie(key string, w http.ResponseWriter) {
 
 // SetCSRFCookie sets a CSRF token cookie that must be readable by JavaScript
 func SetCSRFCookie(val string, expiry time.Time, w http.ResponseWriter) {
-	isProduction := config.IsProduction()
-
-	sameSite := http.SameSiteLaxMode
-	secure := false
-	domain := ""
-
-	if isProduction {
-		// CSRF cookie must be sent cross-subdomain
-		sameSite = http.SameSiteNoneMode
-		secure = true
-		domain = ".roosvansharon.nl"
-	}
+	cookieCfg := config.GetConfig().Cookie
 
 	cookie := &http.Cookie{
 		Name:     CSRFCookieName,
@@ -93,9 +62,9 @@ func SetCSRFCookie(val string, expiry time.Time, w http.ResponseWriter) {
 		Expires:  expiry,
 		MaxAge:   int(time.Until(expiry).Seconds()),
 		Path:     "/",
-		Domain:   domain,
-		Secure:   secure,
-		SameSite: sameSite,
+		Domain:   cookieCfg.Domain,
+		Secure:   cookieCfg.Secure,
+		SameSite: cookieCfg.SameSite,
 		HttpOnly: false, // Must be readable by JS
 	}
 
@@ -0,0 +1,148 @@
+package lib
+
+import (
+	"crypto/hmac"
+	"crypto/rand"
+	"crypto/sha256"
+	"encoding/base64"
+	"fmt"
+	"mamabloemetjes_server/config"
+	"net/http"
+	"strings"
+	"time"
+
+	"github.com/golang-jwt/jwt/v5"
+	"github.com/google/uuid"
+)
+
+// CSRFTokenExpiry is how long an issued CSRF token (and its cookie) stays valid
+const CSRFTokenExpiry = 24 * time.Hour
+
+// AnonymousCSRFSession is the session of requests without a signed-in user
+const AnonymousCSRFSession = "anonymous"
+
+// GenerateCSRFToken returns a random CSRF token bound to session: a crypto/rand nonce and its HMAC
+// over the session, so a token issued to one session is rejected in another
+func GenerateCSRFToken(session string) (string, error) {
+	nonce := make([]byte, 32)
+	if _, err := rand.Read(nonce); err != nil {
+		return "", fmt.Errorf("failed to generate csrf token: %w", err)
+	}
+
+	encodedNonce := base64.RawURLEncoding.EncodeToString(nonce)
+	return encodedNonce + "." + csrfSignature(encodedNonce, session), nil
+}
+
+// ValidCSRFToken reports whether token was issued to session, comparing the signature in constant time
+func ValidCSRFToken(token, session string) bool {
+	nonce, signature, ok := strings.Cut(token, ".")
+	if !ok || nonce == "" {
+		return false
+	}
+	return SecureCompare([]byte(signature), []byte(csrfSignature(nonce, session)))
+}
+
+// IssueCSRFToken generates a CSRF token for session and sets it as the CSRF cookie
+func IssueCSRFToken(session string, w http.ResponseWriter) (string, error) {
+	token, err := GenerateCSRFToken(session)
+	if err != nil {
+		return "", err
+	}
+	SetCSRFCookie(token, time.Now().Add(CSRFTokenExpiry), w)
+	return token, nil
+}
+
+// UserCSRFSession returns the CSRF session of a signed-in user
+func UserCSRFSession(userId uuid.UUID) string {
+	return "user:" + userId.String()
+}
+
+// CSRFSession returns the CSRF session of a request: the signed-in user, or AnonymousCSRFSession
+// The user is read from the access token, or the refresh token once the access cookie is gone. Only the
+// signature is checked, an expired token still identifies its user, so tokens keep working across refreshes
+func CSRFSession(r *http.Request) string {
+	authConfig := config.GetConfig().Auth
+
+	if token, err := GetCookieValue(AccessCookieName, r); err == nil {
+		if sub, err := tokenSubject(token, authConfig.AccessTokenSecret); err == nil {
+			return UserCSRFSession(sub)
+		}
+	}
+	if token, err := GetCookieValue(RefreshCookieName, r); err == nil {
+		if sub, err := tokenSubject(token, authConfig.RefreshTokenSecret); err == nil {
+			return UserCSRFSession(sub)
+		}
+	}
+
+	return AnonymousCSRFSession
+}
+
+// CSRFSessionJti returns the jti of the request's refresh token, which identifies the server-side session
+// its CSRF token is stored on. As in CSRFSession only the signature is checked
+func CSRFSessionJti(r *http.Request) (uuid.UUID, bool) {
+	token, err := GetCookieValue(RefreshCookieName, r)
+	if err != nil {
+		return uuid.Nil, false
+	}
+	jti, err := RefreshTokenJti(token)
+	if err != nil {
+		return uuid.Nil, false
+	}
+	return jti, true
+}
+
+// RefreshTokenJti returns the jti claim of a refresh token, without validating exp, iat or nbf
+func RefreshTokenJti(token string) (uuid.UUID, error) {
+	return tokenClaim(token, config.GetConfig().Auth.RefreshTokenSecret, "jti")
+}
+
+// HashCSRFToken returns the hash of a CSRF token as stored on a session
+func HashCSRFToken(token string) string {
+	return HashToken(token)
+}
+
+// CSRFTokenMatchesHash reports whether token hashes to hash, comparing in constant time
+// A session without a stored hash matches no token
+func CSRFTokenMatchesHash(token, hash string) bool {
+	if hash == "" {
+		return false
+	}
+	return SecureCompare([]byte(HashCSRFToken(token)), []byte(hash))
+}
+
+// csrfSignature signs a nonce for a session with a key derived from the access token secret
+func csrfSignature(nonce, session string) string {
+	key := hmac.New(sha256.New, []byte(config.GetConfig().Auth.AccessTokenSecret))
+	key.Write([]byte("csrf"))
+
+	mac := hmac.New(sha256.New, key.Sum(nil))
+	mac.Write([]byte(session))
+	mac.Write([]byte{0})
+	mac.Write([]byte(nonce))
+	return base64.RawURLEncoding.EncodeToString(mac.Sum(nil))
+}
+
+// tokenSubject returns the sub claim of a JWT signed with secret, without validating exp, iat or nbf
+func tokenSubject(tokenStr, secret string) (uuid.UUID, error) {
+	return tokenClaim(tokenStr, secret, "sub")
+}
+
+// tokenClaim returns a UUID claim of a JWT signed with secret, without validating exp, iat or nbf
+func tokenClaim(tokenStr, secret, claim string) (uuid.UUID, error) {
+	claims := jwt.MapClaims{}
+	_, err := jwt.ParseWithClaims(tokenStr, claims, func(token *jwt.Token) (any, error) {
+		if _, ok := token.Method.(*jwt.SigningMethodHMAC); !ok {
+			return nil, jwt.ErrTokenMalformed
+		}
+		return []byte(secret), nil
+	}, jwt.WithoutClaimsValidation())
+	if err != nil {
+		return uuid.Nil, err
+	}
+
+	value, ok := claims[claim].(string)
+	if !ok {
+		return uuid.Nil, fmt.Errorf("token has no %s claim", claim)
+	}
+	return uuid.Parse(value)
+}
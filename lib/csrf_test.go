@@ -0,0 +1,80 @@
+package lib_test
+
+import (
+	"mamabloemetjes_server/lib"
+	"mamabloemetjes_server/testutil"
+	"testing"
+
+	"github.com/google/uuid"
+)
+
+func TestCSRFTokenBoundToSession(t *testing.T) {
+	testutil.Config()
+	jan, piet := lib.UserCSRFSession(uuid.New()), lib.UserCSRFSession(uuid.New())
+
+	token, err := lib.GenerateCSRFToken(jan)
+	if err != nil {
+		t.Fatalf("GenerateCSRFToken: %v", err)
+	}
+
+	if !lib.ValidCSRFToken(token, jan) {
+		t.Fatal("expected the token to be valid in its own session")
+	}
+	for _, session := range []string{piet, lib.AnonymousCSRFSession} {
+		if lib.ValidCSRFToken(token, session) {
+			t.Fatalf("expected the token to be rejected in session %s", session)
+		}
+	}
+
+	anonymous, err := lib.GenerateCSRFToken(lib.AnonymousCSRFSession)
+	if err != nil {
+		t.Fatalf("GenerateCSRFToken: %v", err)
+	}
+	if lib.ValidCSRFToken(anonymous, jan) {
+		t.Fatal("expected an anonymous token to be rejected once signed in")
+	}
+	if lib.ValidCSRFToken("not-a-token", jan) || lib.ValidCSRFToken("", jan) {
+		t.Fatal("expected malformed tokens to be rejected")
+	}
+}
+
+func TestGenerateCSRFTokenIsRandom(t *testing.T) {
+	testutil.Config()
+	session := lib.UserCSRFSession(uuid.New())
+
+	first, err := lib.GenerateCSRFToken(session)
+	if err != nil {
+		t.Fatalf("GenerateCSRFToken: %v", err)
+	}
+	second, err := lib.GenerateCSRFToken(session)
+	if err != nil {
+		t.Fatalf("GenerateCSRFToken: %v", err)
+	}
+	if first == second {
+		t.Fatal("expected a fresh nonce for every token")
+	}
+
+	// Changing a single character of the signature invalidates the token
+	tampered := []byte(first)
+	tampered[len(tampered)-1] ^= 1
+	if lib.ValidCSRFToken(string(tampered), session) {
+		t.Fatal("expected a tampered token to be rejected")
+	}
+}
+
+func TestSecureCompare(t *testing.T) {
+	tests := []struct {
+		a, b  string
+		equal bool
+	}{
+		{"token", "token", true},
+		{"token", "tokem", false},
+		{"token", "token-longer", false},
+		{"", "token", false},
+	}
+	for _, tt := range tests {
+		if got := lib.SecureCompare([]byte(tt.a), []byte(tt.b)); got != tt.equal {
+			t.Fatalf("expected SecureCompare(%q, %q) to be %v, got %v", tt.a, tt.b, tt.equal, got)
+		}
+	}
+}
@@ -1,10 +1,13 @@
 package lib
 
 import (
+	"context"
+	"database/sql"
 	"errors"
 	"fmt"
 	"strings"
 
+	"github.com/google/uuid"
 	"github.com/uptrace/bun/driver/pgdriver"
 )
 
@@ -16,6 +19,55 @@ var (
 	ErrDatabaseConnection  = errors.New("database connection error")
 )
 
+// Order errors
+var (
+	ErrMixedCurrencies = errors.New("order contains products with different currencies")
+	ErrOrderNotOwned   = errors.New("order does not belong to user")
+
+	ErrOrderNotEditable = errors.New("order can no longer be edited")
+	ErrLastOrderLine    = errors.New("cannot remove the last line of an order")
+
+	ErrOrderNotAdjustable   = errors.New("order can no longer be discounted")
+	ErrInvalidOrderDiscount = errors.New("discount exceeds the order subtotal")
+
+	ErrOrderRateLimited = errors.New("too many orders placed in a short time")
+
+	// The products of an unpaid order went back to the shop, so it can no longer be paid
+	ErrReservationReleased = errors.New("order no longer holds its products")
+
+	// Returned wrapped in an InsufficientStockError naming the product
+	ErrInsufficientStock = errors.New("insufficient stock")
+
+	// Returned wrapped in an OrderLimitError naming the limit
+	ErrOrderLimitExceeded = errors.New("order exceeds the allowed limits")
+
+	// Wrapped with details about the product that could not be ordered
+	ErrProductUnavailable = errors.New("product unavailable")
+
+	// The order's current status does not allow the requested change
+	ErrInvalidStatusTransition = errors.New("invalid status transition")
+)
+
+// Product errors
+var (
+	ErrDuplicateSKU = errors.New("a product with this SKU already exists")
+)
+
+// Cache errors
+var (
+	ErrCacheWarmInProgress = errors.New("a cache warmup is already running")
+)
+
+// Email errors
+var (
+	ErrEmailQueueFull = errors.New("email queue is full")
+)
+
+// Feature flag errors
+var (
+	ErrInvalidFeatureFlag = errors.New("invalid feature flag name")
+)
+
 // Auth errors
 var (
 	ErrInvalidToken       = errors.New("invalid token")
@@ -23,6 +75,58 @@ var (
 	ErrInvalidCredentials = errors.New("invalid credentials")
 )
 
+// InsufficientStockError reports a product that no longer has enough units for an order
+// Products are one of a kind: an active product has one unit available, an inactive one none
+type InsufficientStockError struct {
+	ProductId uuid.UUID `json:"product_id"`
+	Requested int       `json:"requested"`
+	Available int       `json:"available"`
+}
+
+func (e *InsufficientStockError) Error() string {
+	return fmt.Sprintf("%s: product %s (requested %d, available %d)", ErrInsufficientStock, e.ProductId, e.Requested, e.Available)
+}
+
+func (e *InsufficientStockError) Unwrap() error {
+	return ErrInsufficientStock
+}
+
+// MissingProductsError lists the product IDs of an order request that do not exist
+// It matches both ErrProductUnavailable and ErrNotFound
+type MissingProductsError struct {
+	ProductIds []string `json:"missing_product_ids"`
+}
+
+func (e *MissingProductsError) Error() string {
+	return fmt.Sprintf("%s: products not found: %v", ErrProductUnavailable, e.ProductIds)
+}
+
+func (e *MissingProductsError) Unwrap() []error {
+	return []error{ErrProductUnavailable, ErrNotFound}
+}
+
+// Limits checked by the order fraud guard
+const (
+	OrderLimitTotal        = "total"
+	OrderLimitLineQuantity = "line_quantity"
+)
+
+// OrderLimitError reports which order limit was exceeded; ProductId is set for line limits
+type OrderLimitError struct {
+	Limit     string     `json:"limit"`
+	Max       uint64     `json:"max"`
+	Actual    uint64     `json:"actual"`
+	ProductId *uuid.UUID `json:"product_id,omitempty"`
+}
+
+func (e *OrderLimitError) Error() string {
+	return fmt.Sprintf("%s: %s is %d, maximum %d", ErrOrderLimitExceeded, e.Limit, e.Actual, e.Max)
+}
+
+func (e *OrderLimitError) Unwrap() error {
+	return ErrOrderLimitExceeded
+}
+
 // DatabaseError represents a detailed database error with context
 type DatabaseError struct {
 	Type          string // "unique_violation", "foreign_key_violation", etc.
@@ -49,12 +153,23 @@ func (e *UniqueViolationError) Error() string {
 	return e.Message
 }
 
+// Is makes a unique violation match ErrConflict, so errors.Is(err, ErrConflict) catches
+// concurrent inserts that lost the race on a unique constraint
+func (e *UniqueViolationError) Is(target error) bool {
+	return target == ErrConflict
+}
+
 // MapPgError maps pgx/PostgreSQL errors to custom application errors with detailed context
 func MapPgError(err error) error {
 	if err == nil {
 		return nil
 	}
 
+	// No rows from a single-row select
+	if errors.Is(err, sql.ErrNoRows) {
+		return ErrNotFound
+	}
+
 	// Check if it's a pgdriver.Error (used by Bun)
 	var pgDriverErr pgdriver.Error
 	if !errors.As(err, &pgDriverErr) {
@@ -97,6 +212,13 @@ func MapPgError(err error) error {
 			Detail:        message,
 			OriginalError: err,
 		}
+	case "57014": // query_canceled (statement timeout or cancelled context)
+		return &DatabaseError{
+			Type:          "query_canceled",
+			Message:       "error.database.timeout",
+			Detail:        message,
+			OriginalError: err,
+		}
 	case "53300": // too_many_connections
 		return &DatabaseError{
 			Type:          "too_many_connections",
@@ -306,6 +428,19 @@ func IsNotFound(err error) bool {
 	return errors.Is(err, ErrNotFound)
 }
 
+// IsTimeout checks if the error comes from a context deadline or a query cancelled by the database
+func IsTimeout(err error) bool {
+	if errors.Is(err, context.DeadlineExceeded) {
+		return true
+	}
+	var dbErr *DatabaseError
+	if errors.As(err, &dbErr) {
+		return dbErr.Type == "query_canceled"
+	}
+	var pgDriverErr pgdriver.Error
+	return errors.As(err, &pgDriverErr) && pgDriverErr.Field('C') == "57014"
+}
+
 // GetUserMessage extracts a user-friendly message from any error
 func GetUserMessage(err error) string {
 	if err == nil {
@@ -335,6 +470,30 @@ func GetUserMessage(err error) string {
 		return "error.notFound"
 	case errors.Is(err, ErrConflict):
 		return "error.conflict"
+	case errors.Is(err, ErrProductUnavailable):
+		return "error.order.productUnavailable"
+	case errors.Is(err, ErrMixedCurrencies):
+		return "error.order.mixedCurrencies"
+	case errors.Is(err, ErrInvalidStatusTransition):
+		return "error.order.invalidStatusTransition"
+	case errors.Is(err, ErrOrderNotEditable):
+		return "error.order.notEditable"
+	case errors.Is(err, ErrLastOrderLine):
+		return "error.order.lastLine"
+	case errors.Is(err, ErrOrderNotAdjustable):
+		return "error.order.notAdjustable"
+	case errors.Is(err, ErrInvalidOrderDiscount):
+		return "error.order.invalidDiscount"
+	case errors.Is(err, ErrOrderRateLimited):
+		return "error.order.tooManyOrders"
+	case errors.Is(err, ErrReservationReleased):
+		return "error.order.reservationReleased"
+	case errors.Is(err, ErrInsufficientStock):
+		return "error.order.insufficientStock"
+	case errors.Is(err, ErrOrderLimitExceeded):
+		return "error.order.limitExceeded"
+	case errors.Is(err, ErrDuplicateSKU):
+		return "error.products.duplicateSku"
 	default:
 		// Generic error message
 		return "error.generic"
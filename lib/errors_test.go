@@ -0,0 +1,38 @@
+package lib
+
+import (
+	"errors"
+	"fmt"
+	"testing"
+)
+
+func TestUniqueViolationMatchesConflict(t *testing.T) {
+	tests := []struct {
+		name       string
+		detail     string
+		constraint string
+		message    string
+	}{
+		{"email constraint", "Key (email)=(jan@example.com) already exists.", "users_email_key", "error.uniqueViolation.email"},
+		{"username constraint", "Key (username)=(Jan Jansen) already exists.", "idx_users_username", "error.uniqueViolation.username"},
+		{"field from the detail", "Key (slug)=(rozen) already exists.", "uq_slug", "error.uniqueViolation.default Slug"},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			// As returned by a service that wraps the mapped error
+			err := fmt.Errorf("failed to register user: %w", handleUniqueViolation(tt.detail, tt.constraint, "users", "", errors.New("duplicate key value")))
+
+			if !errors.Is(err, ErrConflict) || !IsUniqueViolation(err) {
+				t.Fatalf("expected a unique violation matching ErrConflict, got %v", err)
+			}
+			if message := GetUserMessage(err); message != tt.message {
+				t.Fatalf("expected message %q, got %q", tt.message, message)
+			}
+		})
+	}
+
+	foreignKey := handleForeignKeyViolation(`Key (user_id)=(1) is not present in table "users".`, "orders_user_id_fkey", "orders", errors.New("fk"))
+	if errors.Is(foreignKey, ErrConflict) || IsUniqueViolation(foreignKey) {
+		t.Fatalf("expected a foreign key violation not to be a conflict, got %v", foreignKey)
+	}
+}
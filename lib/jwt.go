@@ -12,17 +12,48 @@ import (
 )
 
 // ParseToken parses and validates a JWT token string and returns the claims
-func ParseToken(tokenStr string, isAccessToken bool, secret string) (*structs.AuthClaims, error) {
+// leeway is the clock skew tolerated when checking exp, iat and nbf. A non-empty issuer or audience
+// must match the token's iss or aud claim; empty ones are not checked
+func ParseToken(tokenStr string, isAccessToken bool, secret string, leeway time.Duration, issuer, audience string) (*structs.AuthClaims, error) {
+	options := []jwt.ParserOption{jwt.WithLeeway(leeway), jwt.WithIssuedAt()}
+	if issuer != "" {
+		options = append(options, jwt.WithIssuer(issuer))
+	}
+	if audience != "" {
+		options = append(options, jwt.WithAudience(audience))
+	}
+
+	token, err := jwt.Parse(tokenStr, func(token *jwt.Token) (any, error) {
+		if _, ok := token.Method.(*jwt.SigningMethodHMAC); !ok {
+			return nil, jwt.ErrTokenMalformed
+		}
+		return []byte(secret), nil
+	}, options...)
+	if err != nil {
+		return nil, err
+	}
+
+	return authClaims(token)
+}
+
+// ParseTokenIgnoringExpiry checks only the signature of a JWT token string and returns the claims
+// Used when revoking tokens, where an expired token must still identify its jti and user
+func ParseTokenIgnoringExpiry(tokenStr, secret string) (*structs.AuthClaims, error) {
 	token, err := jwt.Parse(tokenStr, func(token *jwt.Token) (any, error) {
 		if _, ok := token.Method.(*jwt.SigningMethodHMAC); !ok {
 			return nil, jwt.ErrTokenMalformed
 		}
 		return []byte(secret), nil
-	})
+	}, jwt.WithoutClaimsValidation())
 	if err != nil {
 		return nil, err
 	}
 
+	return authClaims(token)
+}
+
+// authClaims extracts and validates the claims of a parsed token
+func authClaims(token *jwt.Token) (*structs.AuthClaims, error) {
 	if claims, ok := token.Claims.(jwt.MapClaims); ok && token.Valid {
 		// Safely extract and validate claims
 		subStr, ok := claims["sub"].(string)
@@ -65,6 +96,16 @@ func ParseToken(tokenStr string, isAccessToken bool, secret string) (*structs.Au
 			return nil, fmt.Errorf("invalid UUID in jti claim: %w", err)
 		}
 
+		// Tokens issued before token versions existed have no ver claim and count as version 0
+		var ver int
+		if rawVer, present := claims["ver"]; present {
+			verFloat, ok := rawVer.(float64)
+			if !ok {
+				return nil, fmt.Errorf("invalid ver claim")
+			}
+			ver = int(verFloat)
+		}
+
 		return &structs.AuthClaims{
 			Sub:   sub,
 			Email: email,
@@ -72,13 +113,14 @@ func ParseToken(tokenStr string, isAccessToken bool, secret string) (*structs.Au
 			Iat:   time.Unix(int64(iat), 0),
 			Exp:   time.Unix(int64(exp), 0),
 			Jti:   jti,
+			Ver:   ver,
 		}, nil
 	}
 	return nil, jwt.ErrInvalidKey
 }
 
 func ExtractClaims(r *http.Request) (*structs.AuthClaims, error) {
-	secret := config.GetConfig().Auth.AccessTokenSecret
+	authConfig := config.GetConfig().Auth
 	accessToken, err := GetCookieValue(AccessCookieName, r)
 	if err != nil {
 		return nil, err
@@ -87,7 +129,10 @@ func ExtractClaims(r *http.Request) (*structs.AuthClaims, error) {
 	claims, err := ParseToken(
 		accessToken,
 		true,
-		secret,
+		authConfig.AccessTokenSecret,
+		authConfig.TokenLeeway,
+		authConfig.TokenIssuer,
+		authConfig.TokenAudience,
 	)
 	if err != nil {
 		return nil, err
@@ -0,0 +1,96 @@
+package lib
+
+import (
+	"errors"
+	"testing"
+	"time"
+
+	"github.com/golang-jwt/jwt/v5"
+	"github.com/google/uuid"
+)
+
+const testTokenSecret = "test-token-secret-0123456789"
+
+// signTestToken signs a token with the claims ParseToken requires plus extra
+func signTestToken(t *testing.T, extra jwt.MapClaims) string {
+	t.Helper()
+	claims := jwt.MapClaims{
+		"sub":   uuid.NewString(),
+		"email": "Jan",
+		"role":  "user",
+		"iat":   time.Now().Unix(),
+		"exp":   time.Now().Add(time.Hour).Unix(),
+		"jti":   uuid.NewString(),
+	}
+	for key, value := range extra {
+		claims[key] = value
+	}
+	signed, err := jwt.NewWithClaims(jwt.SigningMethodHS256, claims).SignedString([]byte(testTokenSecret))
+	if err != nil {
+		t.Fatalf("failed to sign token: %v", err)
+	}
+	return signed
+}
+
+func TestParseTokenIssuerAndAudience(t *testing.T) {
+	const issuer, audience = "https://api.mamabloemetjes.nl", "mamabloemetjes-web"
+
+	tests := []struct {
+		name     string
+		claims   jwt.MapClaims
+		issuer   string
+		audience string
+		wantErr  error
+	}{
+		{"matching issuer and audience", jwt.MapClaims{"iss": issuer, "aud": audience}, issuer, audience, nil},
+		{"audience in a list", jwt.MapClaims{"iss": issuer, "aud": []string{"other", audience}}, issuer, audience, nil},
+		{"wrong issuer", jwt.MapClaims{"iss": "https://evil.example.com", "aud": audience}, issuer, audience, jwt.ErrTokenInvalidIssuer},
+		{"wrong audience", jwt.MapClaims{"iss": issuer, "aud": "other-service"}, issuer, audience, jwt.ErrTokenInvalidAudience},
+		{"missing issuer", jwt.MapClaims{"aud": audience}, issuer, audience, jwt.ErrTokenRequiredClaimMissing},
+		{"missing audience", jwt.MapClaims{"iss": issuer}, issuer, audience, jwt.ErrTokenRequiredClaimMissing},
+		{"not configured accepts tokens without claims", nil, "", "", nil},
+		{"not configured accepts tokens with claims", jwt.MapClaims{"iss": issuer, "aud": audience}, "", "", nil},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			_, err := ParseToken(signTestToken(t, tt.claims), true, testTokenSecret, 0, tt.issuer, tt.audience)
+			if tt.wantErr == nil && err != nil {
+				t.Fatalf("expected the token to be accepted, got %v", err)
+			}
+			if tt.wantErr != nil && !errors.Is(err, tt.wantErr) {
+				t.Fatalf("expected %v, got %v", tt.wantErr, err)
+			}
+		})
+	}
+}
+
+func TestParseTokenLeeway(t *testing.T) {
+	const leeway = 30 * time.Second
+	now := time.Now()
+
+	tests := []struct {
+		name    string
+		claims  jwt.MapClaims
+		wantErr error
+	}{
+		{"expired within the leeway", jwt.MapClaims{"exp": now.Add(-10 * time.Second).Unix()}, nil},
+		{"expired beyond the leeway", jwt.MapClaims{"exp": now.Add(-time.Minute).Unix()}, jwt.ErrTokenExpired},
+		{"issued within the leeway ahead", jwt.MapClaims{"iat": now.Add(10 * time.Second).Unix()}, nil},
+		{"issued beyond the leeway ahead", jwt.MapClaims{"iat": now.Add(time.Minute).Unix()}, jwt.ErrTokenUsedBeforeIssued},
+		{"valid within the leeway ahead", jwt.MapClaims{"nbf": now.Add(10 * time.Second).Unix()}, nil},
+		{"valid beyond the leeway ahead", jwt.MapClaims{"nbf": now.Add(time.Minute).Unix()}, jwt.ErrTokenNotValidYet},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			_, err := ParseToken(signTestToken(t, tt.claims), true, testTokenSecret, leeway, "", "")
+			if tt.wantErr == nil && err != nil {
+				t.Fatalf("expected the token to be accepted, got %v", err)
+			}
+			if tt.wantErr != nil && !errors.Is(err, tt.wantErr) {
+				t.Fatalf("expected %v, got %v", tt.wantErr, err)
+			}
+		})
+	}
+}
@@ -1,26 +1,49 @@
 package lib
 
 import (
+	"crypto/rand"
+	"errors"
 	"fmt"
-	"math/rand"
-	"time"
+	"strings"
 )
 
-// GenerateOrderNumber generates a unique order number in the format: MB-XXXX
-// where XXXX is a random 4-character alphanumeric string
-func GenerateOrderNumber() string {
-	// Use a local rand.Source + rand.Rand for thread safety
-	src := rand.NewSource(time.Now().UnixNano())
-	r := rand.New(src)
+// MaxOrderNumberAttempts is how many order numbers are tried before giving up on a collision
+const MaxOrderNumberAttempts = 5
 
+// GenerateOrderNumber generates an order number in the format: MB-XXXXXX
+// where XXXXXX is a random 6-character alphanumeric string from crypto/rand.
+// The number is not guaranteed to be unique; callers insert it and retry on
+// a unique violation (see IsOrderNumberCollision)
+func GenerateOrderNumber() string {
 	const chars = "ABCDEFGHIJKLMNOPQRSTUVWXYZ0123456789"
-	const length = 4
+	const length = 6
+	// Largest multiple of len(chars) that fits in a byte, to avoid modulo bias
+	const limit = 256 - 256%len(chars)
 
-	// Generate random part
-	randomPart := make([]byte, length)
-	for i := range randomPart {
-		randomPart[i] = chars[r.Intn(len(chars))]
+	randomPart := make([]byte, 0, length)
+	buf := make([]byte, length*2)
+	for len(randomPart) < length {
+		// crypto/rand.Read never returns an error
+		_, _ = rand.Read(buf)
+		for _, b := range buf {
+			if int(b) >= limit {
+				continue
+			}
+			randomPart = append(randomPart, chars[int(b)%len(chars)])
+			if len(randomPart) == length {
+				break
+			}
+		}
 	}
 
 	return fmt.Sprintf("MB-%s", string(randomPart))
 }
+
+// IsOrderNumberCollision reports whether err is a unique violation on the order number
+func IsOrderNumberCollision(err error) bool {
+	var uniqueErr *UniqueViolationError
+	if !IsUniqueViolation(err) || !errors.As(err, &uniqueErr) {
+		return false
+	}
+	return uniqueErr.Field == "order_number" || strings.Contains(uniqueErr.Constraint, "order_number")
+}
@@ -0,0 +1,45 @@
+package lib
+
+import (
+	"errors"
+	"fmt"
+	"regexp"
+	"testing"
+)
+
+func TestGenerateOrderNumberFormat(t *testing.T) {
+	format := regexp.MustCompile(`^MB-[A-Z0-9]{6}$`)
+	seen := make(map[string]bool)
+	for range 1000 {
+		number := GenerateOrderNumber()
+		if !format.MatchString(number) {
+			t.Fatalf("expected an order number like MB-XXXXXX, got %q", number)
+		}
+		seen[number] = true
+	}
+	// 36^6 possible numbers, so a handful of duplicates in 1000 would point at a broken source
+	if len(seen) < 995 {
+		t.Fatalf("expected nearly all of 1000 order numbers to differ, got %d distinct", len(seen))
+	}
+}
+
+func TestIsOrderNumberCollision(t *testing.T) {
+	tests := []struct {
+		name string
+		err  error
+		want bool
+	}{
+		{"order number field", &UniqueViolationError{Field: "order_number"}, true},
+		{"order number constraint", &UniqueViolationError{DatabaseError: DatabaseError{Constraint: "orders_order_number_key"}}, true},
+		{"wrapped", fmt.Errorf("insert order: %w", &UniqueViolationError{Field: "order_number"}), true},
+		{"other unique field", &UniqueViolationError{Field: "email"}, false},
+		{"not a unique violation", errors.New("connection reset"), false},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			if got := IsOrderNumberCollision(tt.err); got != tt.want {
+				t.Fatalf("expected %v, got %v", tt.want, got)
+			}
+		})
+	}
+}
@@ -0,0 +1,84 @@
+package lib
+
+import (
+	"net/http"
+	"strconv"
+	"strings"
+)
+
+// Shared pagination policy for all list endpoints
+const (
+	DefaultPage     = 1
+	DefaultPageSize = 20
+	MaxPageSize     = 100
+)
+
+// ParsePagination reads the page and page_size query parameters
+// Missing or invalid values fall back to the defaults and page_size is clamped to MaxPageSize
+func ParsePagination(r *http.Request) (page, pageSize int) {
+	query := r.URL.Query()
+
+	page = DefaultPage
+	if val, err := strconv.Atoi(strings.TrimSpace(query.Get("page"))); err == nil && val > 0 {
+		page = val
+	}
+
+	pageSize = DefaultPageSize
+	if val, err := strconv.Atoi(strings.TrimSpace(query.Get("page_size"))); err == nil && val > 0 {
+		pageSize = min(val, MaxPageSize)
+	}
+
+	return page, pageSize
+}
+
+// ClampPagination applies the shared defaults and max page size to already parsed values
+func ClampPagination(page, pageSize int) (int, int) {
+	if page < 1 {
+		page = DefaultPage
+	}
+	if pageSize < 1 {
+		pageSize = DefaultPageSize
+	}
+	return page, min(pageSize, MaxPageSize)
+}
+
+// PaginationLinks are ready-made URLs for navigating a paginated list, relative to the API root
+// Next and Prev are empty on the last and first page
+type PaginationLinks struct {
+	Self  string `json:"self"`
+	First string `json:"first"`
+	Last  string `json:"last"`
+	Next  string `json:"next,omitempty"`
+	Prev  string `json:"prev,omitempty"`
+}
+
+// WantsPaginationLinks reports whether the client asked for pagination links with ?links=true
+func WantsPaginationLinks(r *http.Request) bool {
+	wants, err := strconv.ParseBool(strings.TrimSpace(r.URL.Query().Get("links")))
+	return err == nil && wants
+}
+
+// NewPaginationLinks builds the links of a list page from the request URL, replacing only the page parameter
+func NewPaginationLinks(r *http.Request, page, totalPages int) *PaginationLinks {
+	// An empty list still has a first (and last) page
+	lastPage := max(totalPages, 1)
+
+	link := func(page int) string {
+		query := r.URL.Query()
+		query.Set("page", strconv.Itoa(page))
+		return r.URL.Path + "?" + query.Encode()
+	}
+
+	links := &PaginationLinks{
+		Self:  link(page),
+		First: link(1),
+		Last:  link(lastPage),
+	}
+	if page < lastPage {
+		links.Next = link(page + 1)
+	}
+	if page > 1 {
+		links.Prev = link(min(page-1, lastPage))
+	}
+	return links
+}
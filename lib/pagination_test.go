@@ -0,0 +1,102 @@
+package lib
+
+import (
+	"net/http"
+	"net/http/httptest"
+	"testing"
+)
+
+func TestParsePagination(t *testing.T) {
+	tests := []struct {
+		name     string
+		query    string
+		page     int
+		pageSize int
+	}{
+		{"defaults", "", DefaultPage, DefaultPageSize},
+		{"explicit values", "?page=3&page_size=50", 3, 50},
+		{"surrounding spaces", "?page=%202%20&page_size=%2010", 2, 10},
+		{"page size clamped", "?page=1&page_size=1000", 1, MaxPageSize},
+		{"page size at the max", "?page_size=100", DefaultPage, MaxPageSize},
+		{"zero falls back", "?page=0&page_size=0", DefaultPage, DefaultPageSize},
+		{"negative falls back", "?page=-2&page_size=-5", DefaultPage, DefaultPageSize},
+		{"not a number falls back", "?page=two&page_size=lots", DefaultPage, DefaultPageSize},
+		{"overflow falls back", "?page=99999999999999999999", DefaultPage, DefaultPageSize},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			page, pageSize := ParsePagination(httptest.NewRequest(http.MethodGet, "/products"+tt.query, nil))
+			if page != tt.page || pageSize != tt.pageSize {
+				t.Fatalf("expected page %d size %d, got page %d size %d", tt.page, tt.pageSize, page, pageSize)
+			}
+		})
+	}
+}
+
+func TestClampPagination(t *testing.T) {
+	if page, pageSize := ClampPagination(0, 0); page != DefaultPage || pageSize != DefaultPageSize {
+		t.Fatalf("expected the defaults, got page %d size %d", page, pageSize)
+	}
+	if page, pageSize := ClampPagination(4, 500); page != 4 || pageSize != MaxPageSize {
+		t.Fatalf("expected page 4 size %d, got page %d size %d", MaxPageSize, page, pageSize)
+	}
+}
+
+func TestWantsPaginationLinks(t *testing.T) {
+	for query, wants := range map[string]bool{"": false, "?links=true": true, "?links=1": true, "?links=false": false, "?links=yes": false} {
+		if got := WantsPaginationLinks(httptest.NewRequest(http.MethodGet, "/products"+query, nil)); got != wants {
+			t.Fatalf("expected %q to ask for links: %v, got %v", query, wants, got)
+		}
+	}
+}
+
+func TestNewPaginationLinks(t *testing.T) {
+	tests := []struct {
+		name       string
+		page       int
+		totalPages int
+		want       PaginationLinks
+	}{
+		{"first page", 1, 3, PaginationLinks{
+			Self:  "/products?links=true&page=1&sort_by=price",
+			First: "/products?links=true&page=1&sort_by=price",
+			Last:  "/products?links=true&page=3&sort_by=price",
+			Next:  "/products?links=true&page=2&sort_by=price",
+		}},
+		{"middle page", 2, 3, PaginationLinks{
+			Self:  "/products?links=true&page=2&sort_by=price",
+			First: "/products?links=true&page=1&sort_by=price",
+			Last:  "/products?links=true&page=3&sort_by=price",
+			Next:  "/products?links=true&page=3&sort_by=price",
+			Prev:  "/products?links=true&page=1&sort_by=price",
+		}},
+		{"last page", 3, 3, PaginationLinks{
+			Self:  "/products?links=true&page=3&sort_by=price",
+			First: "/products?links=true&page=1&sort_by=price",
+			Last:  "/products?links=true&page=3&sort_by=price",
+			Prev:  "/products?links=true&page=2&sort_by=price",
+		}},
+		{"beyond the last page", 5, 3, PaginationLinks{
+			Self:  "/products?links=true&page=5&sort_by=price",
+			First: "/products?links=true&page=1&sort_by=price",
+			Last:  "/products?links=true&page=3&sort_by=price",
+			Prev:  "/products?links=true&page=3&sort_by=price",
+		}},
+		{"empty list", 1, 0, PaginationLinks{
+			Self:  "/products?links=true&page=1&sort_by=price",
+			First: "/products?links=true&page=1&sort_by=price",
+			Last:  "/products?links=true&page=1&sort_by=price",
+		}},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			// The page parameter is replaced, the other parameters are kept
+			r := httptest.NewRequest(http.MethodGet, "/products?page=7&sort_by=price&links=true", nil)
+			if got := NewPaginationLinks(r, tt.page, tt.totalPages); *got != tt.want {
+				t.Fatalf("expected %+v, got %+v", tt.want, *got)
+			}
+		})
+	}
+}
@@ -1,6 +1,8 @@
 package lib
 
 import (
+	"crypto/hmac"
+	"crypto/sha256"
 	"crypto/subtle"
 	"encoding/base64"
 	"errors"
@@ -13,6 +15,8 @@ import (
 var (
 	ErrInvalidHash         = errors.New("invalid hash format")
 	ErrIncompatibleVersion = errors.New("incompatible version of argon2")
+	ErrHashParamsExceeded  = errors.New("argon2 hash parameters exceed configured limits")
+	ErrUnknownPepper       = errors.New("password hash uses an unknown pepper version")
 )
 
 // Argon2HashParts contains the decoded parts of an Argon2 hash
@@ -23,10 +27,12 @@ type Argon2HashParts struct {
 	KeyLen  uint32
 	Salt    []byte
 	Hash    []byte
+	// PepperVersion is the pepper the password was mixed with before hashing, 0 when unpeppered
+	PepperVersion int
 }
 
 // DecodeArgon2Hash decodes an Argon2id hash string into its component parts
-// Expected format: $argon2id$v=19$m=65536,t=1,p=4$<salt>$<hash>
+// Expected format: $argon2id$v=19$m=65536,t=1,p=4$<salt>$<hash>, with an optional ",pv=<n>" pepper version after p
 func DecodeArgon2Hash(encodedHash string) (*Argon2HashParts, error) {
 	parts := strings.Split(encodedHash, "$")
 	if len(parts) != 6 {
@@ -51,11 +57,19 @@ func DecodeArgon2Hash(encodedHash string) (*Argon2HashParts, error) {
 	// Parse parameters
 	var memory, time uint32
 	var threads uint8
-	_, err = fmt.Sscanf(parts[3], "m=%d,t=%d,p=%d", &memory, &time, &threads)
+	params, pepperParam, peppered := strings.Cut(parts[3], ",pv=")
+	_, err = fmt.Sscanf(params, "m=%d,t=%d,p=%d", &memory, &time, &threads)
 	if err != nil {
 		return nil, err
 	}
 
+	var pepperVersion int
+	if peppered {
+		if _, err := fmt.Sscanf(pepperParam, "%d", &pepperVersion); err != nil || pepperVersion <= 0 {
+			return nil, ErrInvalidHash
+		}
+	}
+
 	// Decode salt
 	salt, err := base64.RawStdEncoding.DecodeString(parts[4])
 	if err != nil {
@@ -75,9 +89,28 @@ func DecodeArgon2Hash(encodedHash string) (*Argon2HashParts, error) {
 		KeyLen:  uint32(len(hash)),
 		Salt:    salt,
 		Hash:    hash,
+		// PepperVersion stays 0 for hashes without a pv parameter
+		PepperVersion: pepperVersion,
 	}, nil
 }
 
+// PepperPassword mixes a server-side pepper into a password as HMAC-SHA256(pepper, password)
+// An empty pepper returns the password unchanged, which keeps unpeppered hashes verifiable
+func PepperPassword(password, pepper string) []byte {
+	if pepper == "" {
+		return []byte(password)
+	}
+	mac := hmac.New(sha256.New, []byte(pepper))
+	mac.Write([]byte(password))
+	return mac.Sum(nil)
+}
+
+// WithinLimits reports whether the hash parameters stay at or below the given maxima
+// Check this before verifying so a crafted hash cannot force an expensive computation
+func (p *Argon2HashParts) WithinLimits(maxMemory, maxTime uint32, maxThreads uint8) bool {
+	return p.Memory <= maxMemory && p.Time <= maxTime && p.Threads <= maxThreads
+}
+
 // SecureCompare performs a constant-time comparison of two byte slices
 // This prevents timing attacks when comparing password hashes
 func SecureCompare(a, b []byte) bool {
@@ -3,12 +3,16 @@ package lib
 import (
 	"encoding/json"
 	"errors"
+	"mamabloemetjes_server/structs/tables"
 	"net/http"
 	"regexp"
 	"strings"
 	"unicode"
 
+	"github.com/MonkyMars/gecho"
+	"github.com/go-chi/chi/v5"
 	"github.com/go-playground/validator/v10"
+	"github.com/google/uuid"
 )
 
 var validate = validator.New()
@@ -16,6 +20,13 @@ var validate = validator.New()
 // Register custom validators on init
 func init() {
 	validate.RegisterValidation("nl_postalcode", validateNLPostalCode)
+	validate.RegisterValidation("product_type", validateProductType)
+}
+
+// validateProductType accepts any known product type regardless of case; the service stores it lowercase
+func validateProductType(fl validator.FieldLevel) bool {
+	_, ok := tables.NormalizeProductType(fl.Field().String())
+	return ok
 }
 
 // validateNLPostalCode validates Dutch postal code format: 1234 AB (4 digits, space, 2 letters)
@@ -41,6 +52,11 @@ func (e *ValidationError) Error() string {
 	return "validation failed"
 }
 
+// NewFieldError creates a ValidationError for a single field
+func NewFieldError(field, message string) *ValidationError {
+	return &ValidationError{Errors: []FieldError{{Field: field, Message: message}}}
+}
+
 // ExtractAndValidateBody extracts and validates the request body into the provided struct type T
 func ExtractAndValidateBody[T any](r *http.Request) (*T, error) {
 	defer r.Body.Close()
@@ -93,6 +109,8 @@ func mapValidationErrors(errs validator.ValidationErrors) *ValidationError {
 			message = "must be less than or equal to " + e.Param()
 		case "oneof":
 			message = "must be one of: " + e.Param()
+		case "product_type":
+			message = "must be one of: " + strings.Join(tables.ProductTypes, " ")
 		case "nl_postalcode":
 			message = "must be in format: 1234 AB (4 digits, space, 2 letters)"
 		case "dive":
@@ -111,10 +129,20 @@ func mapValidationErrors(errs validator.ValidationErrors) *ValidationError {
 	return out
 }
 
+// StripControlChars removes control characters other than whitespace
+func StripControlChars(s string) string {
+	return strings.Map(func(r rune) rune {
+		if unicode.IsControl(r) && !unicode.IsSpace(r) {
+			return -1
+		}
+		return r
+	}, s)
+}
+
 // SanitizeString cleans a string robustly
 func SanitizeString(s string, removePunctuation bool, keepSpaces bool) string {
-	// Trim leading/trailing whitespace
-	s = strings.TrimSpace(s)
+	// Drop control characters and trim leading/trailing whitespace
+	s = strings.TrimSpace(StripControlChars(s))
 
 	// Convert to lowercase
 	s = strings.ToLower(s)
@@ -142,3 +170,28 @@ func SanitizeString(s string, removePunctuation bool, keepSpaces bool) string {
 
 	return s
 }
+
+// NormalizeEmail trims and lowercases an email address so lookups and uniqueness are case-insensitive
+func NormalizeEmail(email string) string {
+	return strings.ToLower(strings.TrimSpace(email))
+}
+
+// ParseUUIDParam reads the named URL parameter as a UUID
+// An invalid value is reported as a ValidationError on the parameter name
+func ParseUUIDParam(r *http.Request, name string) (uuid.UUID, error) {
+	id, err := uuid.Parse(chi.URLParam(r, name))
+	if err != nil {
+		return uuid.Nil, NewFieldError(name, "must be a valid UUID")
+	}
+	return id, nil
+}
+
+// RespondInvalidUUID writes the 400 for a URL parameter rejected by ParseUUIDParam
+// message is the resource-specific translation key, the data names the offending parameter
+func RespondInvalidUUID(w http.ResponseWriter, err error, message string) {
+	gecho.BadRequest(w,
+		gecho.WithMessage(message),
+		gecho.WithData(ClientErrorData(err)),
+		gecho.Send(),
+	)
+}
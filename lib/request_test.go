@@ -0,0 +1,98 @@
+package lib
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+
+	"github.com/go-chi/chi/v5"
+	"github.com/google/uuid"
+)
+
+func TestProductTypeValidation(t *testing.T) {
+	type productRequest struct {
+		ProductType string `json:"product_type" validate:"omitempty,product_type"`
+	}
+
+	tests := []struct {
+		name  string
+		body  string
+		valid bool
+	}{
+		{"enum value", `{"product_type": "wedding"}`, true},
+		{"different case", `{"product_type": "Funeral"}`, true},
+		{"empty", `{}`, true},
+		{"unknown value", `{"product_type": "Bouquet"}`, false},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			r := httptest.NewRequest(http.MethodPost, "/admin/products", strings.NewReader(tt.body))
+			_, err := ExtractAndValidateBody[productRequest](r)
+			if tt.valid {
+				if err != nil {
+					t.Fatalf("expected the body to be valid, got %v", err)
+				}
+				return
+			}
+
+			var validationErr *ValidationError
+			if !errors.As(err, &validationErr) || len(validationErr.Errors) != 1 {
+				t.Fatalf("expected a validation error, got %v", err)
+			}
+			if !strings.Contains(validationErr.Errors[0].Message, "wedding funeral birth") {
+				t.Fatalf("expected the message to list the product types, got %q", validationErr.Errors[0].Message)
+			}
+		})
+	}
+}
+
+// uuidParamRequest returns a request with value as the id URL parameter
+func uuidParamRequest(value string) *http.Request {
+	routeCtx := chi.NewRouteContext()
+	routeCtx.URLParams.Add("id", value)
+	r := httptest.NewRequest(http.MethodGet, "/orders/"+value, nil)
+	return r.WithContext(context.WithValue(r.Context(), chi.RouteCtxKey, routeCtx))
+}
+
+func TestParseUUIDParam(t *testing.T) {
+	want := uuid.New()
+	id, err := ParseUUIDParam(uuidParamRequest(want.String()), "id")
+	if err != nil || id != want {
+		t.Fatalf("expected %s, got %s (err %v)", want, id, err)
+	}
+
+	for _, value := range []string{"", "not-a-uuid", want.String()[:35]} {
+		id, err := ParseUUIDParam(uuidParamRequest(value), "id")
+		var validationErr *ValidationError
+		if !errors.As(err, &validationErr) || id != uuid.Nil {
+			t.Fatalf("expected a validation error for %q, got %s (err %v)", value, id, err)
+		}
+		if fieldErr := validationErr.Errors[0]; fieldErr.Field != "id" || fieldErr.Message != "must be a valid UUID" {
+			t.Fatalf("expected the error on id, got %+v", fieldErr)
+		}
+	}
+}
+
+func TestRespondInvalidUUID(t *testing.T) {
+	_, err := ParseUUIDParam(uuidParamRequest("not-a-uuid"), "id")
+	w := httptest.NewRecorder()
+	RespondInvalidUUID(w, err, "error.invalidOrderId")
+
+	if w.Code != http.StatusBadRequest {
+		t.Fatalf("expected status 400, got %d", w.Code)
+	}
+	var response struct {
+		Message string          `json:"message"`
+		Data    ValidationError `json:"data"`
+	}
+	if err := json.Unmarshal(w.Body.Bytes(), &response); err != nil {
+		t.Fatalf("failed to decode the response: %v", err)
+	}
+	if response.Message != "error.invalidOrderId" || len(response.Data.Errors) != 1 || response.Data.Errors[0].Field != "id" {
+		t.Fatalf("expected the message key and the field error on id, got %s", w.Body.String())
+	}
+}
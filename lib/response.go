@@ -0,0 +1,87 @@
+package lib
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"net/http"
+	"strings"
+	"time"
+
+	"github.com/MonkyMars/gecho"
+)
+
+// ClientErrorData returns the part of err that is safe to send to clients
+// Validation errors carry per-field messages meant for the client; anything else is reduced to its user message
+func ClientErrorData(err error) any {
+	var validationErr *ValidationError
+	if errors.As(err, &validationErr) {
+		return validationErr
+	}
+	return GetUserMessage(err)
+}
+
+// NotModified sets the Last-Modified header and an ETag of lastModified in microseconds, and answers
+// 304 Not Modified when the request's If-None-Match lists that ETag or, for clients sending no If-None-Match,
+// its If-Modified-Since is not older than lastModified. It reports whether the response was written
+func NotModified(w http.ResponseWriter, r *http.Request, lastModified time.Time) bool {
+	// HTTP dates have second precision, the ETag tells apart changes within the same second
+	w.Header().Set("Last-Modified", lastModified.UTC().Truncate(time.Second).Format(http.TimeFormat))
+	if NotModifiedETag(w, r, fmt.Sprintf(`"%d"`, lastModified.UnixMicro())) {
+		return true
+	}
+	if r.Header.Get("If-None-Match") != "" {
+		return false
+	}
+
+	since, err := http.ParseTime(r.Header.Get("If-Modified-Since"))
+	if err != nil || lastModified.UTC().Truncate(time.Second).After(since) {
+		return false
+	}
+
+	w.WriteHeader(http.StatusNotModified)
+	return true
+}
+
+// NotModifiedETag sets the ETag header and, when the request's If-None-Match lists etag (or is "*"),
+// answers 304 Not Modified. It reports whether the response was written
+func NotModifiedETag(w http.ResponseWriter, r *http.Request, etag string) bool {
+	w.Header().Set("ETag", etag)
+	w.Header().Set("Cache-Control", "private, no-cache")
+
+	for candidate := range strings.SplitSeq(r.Header.Get("If-None-Match"), ",") {
+		candidate = strings.TrimSpace(candidate)
+		if candidate == "*" || strings.TrimPrefix(candidate, "W/") == strings.TrimPrefix(etag, "W/") {
+			w.WriteHeader(http.StatusNotModified)
+			return true
+		}
+	}
+	return false
+}
+
+// StatusClientClosedRequest is the non-standard status (nginx convention) for requests the client abandoned
+const StatusClientClosedRequest = 499
+
+// RespondServerError writes the response for an unexpected error without exposing the error text
+// Timed out queries become 504 and requests cancelled by the client 499; anything else is a 500 with message
+func RespondServerError(w http.ResponseWriter, err error, message string) {
+	switch {
+	case IsTimeout(err):
+		gecho.NewErr(w,
+			gecho.WithStatus(http.StatusGatewayTimeout),
+			gecho.WithMessage("error.requestTimeout"),
+			gecho.Send(),
+		)
+	case errors.Is(err, context.Canceled):
+		gecho.NewErr(w,
+			gecho.WithStatus(StatusClientClosedRequest),
+			gecho.WithMessage("error.requestCancelled"),
+			gecho.Send(),
+		)
+	default:
+		gecho.InternalServerError(w,
+			gecho.WithMessage(message),
+			gecho.Send(),
+		)
+	}
+}
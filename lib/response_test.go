@@ -0,0 +1,165 @@
+package lib
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+	"time"
+)
+
+func TestNotModified(t *testing.T) {
+	updatedAt := time.Date(2026, 3, 14, 9, 26, 53, 589793000, time.UTC)
+	sameSecond := updatedAt.Add(-time.Millisecond)
+	etag := `"1773480413589793"`
+
+	tests := []struct {
+		name        string
+		header      string
+		value       string
+		notModified bool
+	}{
+		{"no conditional headers", "", "", false},
+		{"matching etag", "If-None-Match", etag, true},
+		{"weak matching etag", "If-None-Match", "W/" + etag, true},
+		{"etag of an earlier change in the same second", "If-None-Match", `"1773480413588793"`, false},
+		{"modified since within the same second", "If-Modified-Since", sameSecond.Format(http.TimeFormat), true},
+		{"modified since an earlier second", "If-Modified-Since", updatedAt.Add(-time.Second).Format(http.TimeFormat), false},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			r := httptest.NewRequest(http.MethodGet, "/orders/1", nil)
+			if tt.header != "" {
+				r.Header.Set(tt.header, tt.value)
+			}
+			w := httptest.NewRecorder()
+
+			if written := NotModified(w, r, updatedAt); written != tt.notModified {
+				t.Fatalf("expected NotModified to return %v, got %v", tt.notModified, written)
+			}
+			if tt.notModified && w.Code != http.StatusNotModified {
+				t.Fatalf("expected status 304, got %d", w.Code)
+			}
+			if got := w.Header().Get("ETag"); got != etag {
+				t.Fatalf("expected ETag %s, got %s", etag, got)
+			}
+		})
+	}
+}
+
+func TestNotModifiedPrefersETagOverDate(t *testing.T) {
+	updatedAt := time.Date(2026, 3, 14, 9, 26, 53, 589793000, time.UTC)
+
+	// The date alone would match, but the ETag shows the client's copy predates the change
+	r := httptest.NewRequest(http.MethodGet, "/orders/1", nil)
+	r.Header.Set("If-None-Match", `"1773480413000000"`)
+	r.Header.Set("If-Modified-Since", updatedAt.Format(http.TimeFormat))
+	w := httptest.NewRecorder()
+
+	if NotModified(w, r, updatedAt) {
+		t.Fatal("expected a stale ETag to win over a matching If-Modified-Since")
+	}
+}
+
+func TestNotModifiedETag(t *testing.T) {
+	etag := `"paid-completed-1773480413589793"`
+
+	tests := []struct {
+		name        string
+		ifNoneMatch string
+		notModified bool
+	}{
+		{"no conditional header", "", false},
+		{"matching etag", etag, true},
+		{"weak matching etag", "W/" + etag, true},
+		{"one of several etags", `"pending-pending-1", ` + etag, true},
+		{"wildcard", "*", true},
+		{"stale etag", `"pending-pending-1773480413589793"`, false},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			r := httptest.NewRequest(http.MethodGet, "/orders/1/status", nil)
+			if tt.ifNoneMatch != "" {
+				r.Header.Set("If-None-Match", tt.ifNoneMatch)
+			}
+			w := httptest.NewRecorder()
+
+			if written := NotModifiedETag(w, r, etag); written != tt.notModified {
+				t.Fatalf("expected NotModifiedETag to return %v, got %v", tt.notModified, written)
+			}
+			if tt.notModified && w.Code != http.StatusNotModified {
+				t.Fatalf("expected status 304, got %d", w.Code)
+			}
+			if w.Header().Get("ETag") != etag || w.Header().Get("Cache-Control") != "private, no-cache" {
+				t.Fatalf("expected the ETag and a private cache policy, got %v", w.Header())
+			}
+		})
+	}
+}
+
+func TestRespondServerError(t *testing.T) {
+	expired, cancel := context.WithTimeout(context.Background(), -time.Second)
+	defer cancel()
+	cancelled, cancelNow := context.WithCancel(context.Background())
+	cancelNow()
+
+	tests := []struct {
+		name    string
+		err     error
+		status  int
+		message string
+	}{
+		{"query past its deadline", fmt.Errorf("failed to get products: %w", expired.Err()), http.StatusGatewayTimeout, "error.requestTimeout"},
+		{"query cancelled by the database", &DatabaseError{Type: "query_canceled", Message: "canceling statement due to statement timeout"}, http.StatusGatewayTimeout, "error.requestTimeout"},
+		{"request cancelled by the client", fmt.Errorf("failed to get products: %w", cancelled.Err()), StatusClientClosedRequest, "error.requestCancelled"},
+		{"other error", errors.New(`pq: relation "products" does not exist`), http.StatusInternalServerError, "Failed to get products"},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			w := httptest.NewRecorder()
+			RespondServerError(w, tt.err, "Failed to get products")
+
+			if w.Code != tt.status {
+				t.Fatalf("expected status %d, got %d", tt.status, w.Code)
+			}
+			body := w.Body.String()
+			if !strings.Contains(body, tt.message) {
+				t.Fatalf("expected the body to contain %q, got %s", tt.message, body)
+			}
+			if strings.Contains(body, tt.err.Error()) {
+				t.Fatalf("expected the raw error to stay out of the body, got %s", body)
+			}
+		})
+	}
+}
+
+func TestClientErrorData(t *testing.T) {
+	tests := []struct {
+		name     string
+		err      error
+		expected string
+		logged   string
+	}{
+		{"database error", &DatabaseError{Type: "check_violation", Message: "error.database.invalidValue", Detail: `violates check constraint "orders_total_check"`}, "error.database.invalidValue", "orders_total_check"},
+		{"known error", fmt.Errorf("product 42: %w", ErrProductUnavailable), "error.order.productUnavailable", "product 42"},
+		{"unknown error", errors.New(`pq: column "secret" does not exist`), "error.generic", `column "secret"`},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			data := ClientErrorData(tt.err)
+			if data != tt.expected {
+				t.Fatalf("expected %q for the client, got %v", tt.expected, data)
+			}
+			if detail := GetDetailForLogging(tt.err); !strings.Contains(detail, tt.logged) {
+				t.Fatalf("expected the logged detail to keep the raw error, got %q", detail)
+			}
+		})
+	}
+}
@@ -0,0 +1,25 @@
+package lib
+
+import (
+	"maps"
+	"slices"
+	"strings"
+)
+
+// ValidateSortField rejects a sort field that is not in the model's allowlist
+// The error is a ValidationError on sort_by listing the allowed fields, so handlers answer it with a 400
+func ValidateSortField(field string, allowed map[string]bool) error {
+	if allowed[field] {
+		return nil
+	}
+	fields := slices.Sorted(maps.Keys(allowed))
+	return NewFieldError("sort_by", "must be one of: "+strings.Join(fields, " "))
+}
+
+// ValidateSortDirection rejects anything but ASC or DESC
+func ValidateSortDirection(direction string) error {
+	if direction == "ASC" || direction == "DESC" {
+		return nil
+	}
+	return NewFieldError("sort_direction", "must be ASC or DESC")
+}
@@ -0,0 +1,41 @@
+package lib
+
+import (
+	"errors"
+	"testing"
+)
+
+func TestValidateSortField(t *testing.T) {
+	allowed := map[string]bool{"name": true, "created_at": true}
+
+	for _, field := range []string{"name", "created_at"} {
+		if err := ValidateSortField(field, allowed); err != nil {
+			t.Fatalf("expected %q to be allowed, got %v", field, err)
+		}
+	}
+
+	for _, field := range []string{"", "password_hash", "Name", "name; DROP TABLE products"} {
+		err := ValidateSortField(field, allowed)
+		var validationErr *ValidationError
+		if !errors.As(err, &validationErr) || len(validationErr.Errors) != 1 {
+			t.Fatalf("expected a validation error for %q, got %v", field, err)
+		}
+		if fieldErr := validationErr.Errors[0]; fieldErr.Field != "sort_by" || fieldErr.Message != "must be one of: created_at name" {
+			t.Fatalf("expected the allowed fields on sort_by, got %+v", fieldErr)
+		}
+	}
+}
+
+func TestValidateSortDirection(t *testing.T) {
+	for _, direction := range []string{"ASC", "DESC"} {
+		if err := ValidateSortDirection(direction); err != nil {
+			t.Fatalf("expected %q to be allowed, got %v", direction, err)
+		}
+	}
+	for _, direction := range []string{"", "asc", "RANDOM()"} {
+		var validationErr *ValidationError
+		if err := ValidateSortDirection(direction); !errors.As(err, &validationErr) {
+			t.Fatalf("expected a validation error for %q, got %v", direction, err)
+		}
+	}
+}
@@ -2,7 +2,9 @@ package lib
 
 import (
 	"crypto/rand"
+	"crypto/sha256"
 	"encoding/base64"
+	"encoding/hex"
 	"fmt"
 	"strings"
 )
@@ -16,6 +18,13 @@ func GenerateRandomToken() (string, error) {
 	return base64.URLEncoding.EncodeToString(bytes), nil
 }
 
+// HashToken returns the hex SHA-256 of a random token, for storing tokens that are only ever looked up
+// A plain hash is enough: the tokens carry 256 bits of entropy, so they cannot be guessed from it
+func HashToken(token string) string {
+	sum := sha256.Sum256([]byte(token))
+	return hex.EncodeToString(sum[:])
+}
+
 // GenerateSKU generates a SKU from a product name and optional suffix length
 func GenerateSKU(productName string, suffixLength int) (string, error) {
 	// Take first 3 letters of the product name (uppercase, alphanumeric only)
@@ -0,0 +1,28 @@
+package lib
+
+import "runtime"
+
+// Build information, injected at build time with
+// -ldflags "-X mamabloemetjes_server/lib.Version=... -X mamabloemetjes_server/lib.Commit=... -X mamabloemetjes_server/lib.BuildDate=..."
+var (
+	Version   = "dev"
+	Commit    = "unknown"
+	BuildDate = "unknown"
+)
+
+type BuildInfo struct {
+	Version   string `json:"version"`
+	Commit    string `json:"commit"`
+	BuildDate string `json:"build_date"`
+	GoVersion string `json:"go_version"`
+}
+
+// GetBuildInfo returns the build information of the running binary
+func GetBuildInfo() BuildInfo {
+	return BuildInfo{
+		Version:   Version,
+		Commit:    Commit,
+		BuildDate: BuildDate,
+		GoVersion: runtime.Version(),
+	}
+}
@@ -15,6 +15,7 @@ import (
 	"mamabloemetjes_server/config"
 	"mamabloemetjes_server/database"
 	"mamabloemetjes_server/services"
+	"mamabloemetjes_server/structs"
 	"net/http"
 	"os"
 	"os/signal"
@@ -65,21 +66,37 @@ func run() error {
 	}
 
 	// Test email
-	err = serviceManager.EmailService.SendEmail([]string{"levinoppers@proton.me"}, "This is a test email from Mamabloemetjes server.", "levinoppers@proton.me")
+	err = serviceManager.EmailService.SendEmail(structs.EmailTypeAdminNotification, []string{"levinoppers@proton.me"}, "This is a test email from Mamabloemetjes server.", "levinoppers@proton.me")
 	if err != nil {
 		logger.Error("Failed to send test email", gecho.Field("error", err))
 	} else {
 		logger.Info("Successfully sent test email")
 	}
 
+	// Start background jobs
+	jobsCtx, stopJobs := context.WithCancel(context.Background())
+	defer stopJobs()
+	go serviceManager.OrderSweeper.Start(jobsCtx)
+	go serviceManager.OrderAnonymizer.Start(jobsCtx)
+	go serviceManager.FeatureFlags.Start(jobsCtx)
+
+	// The email queue outlives the other jobs: requests still being drained at shutdown may queue emails
+	emailCtx, stopEmails := context.WithCancel(context.Background())
+	defer stopEmails()
+	emailQueueDone := make(chan struct{})
+	go func() {
+		serviceManager.EmailService.StartQueue(emailCtx)
+		close(emailQueueDone)
+	}()
+
 	// Initialize middleware
-	mw := middleware.NewMiddleware(cfg, mwLogger, db)
+	mw := middleware.NewMiddleware(cfg, mwLogger, serviceManager.AuthService, serviceManager.CacheService, serviceManager.FeatureFlags)
 
 	// Initialize route managers
 	healthRoutes := health.NewHealthRoutesManager(serviceManager.HealthService)
-	productRoutes := products.NewProductRoutesManager(logger, serviceManager.ProductService, serviceManager.EmailService)
+	productRoutes := products.NewProductRoutesManager(logger, serviceManager.ProductService, serviceManager.EmailService, mw)
 	authRoutes := auth.NewAuthRoutesManager(logger, serviceManager.AuthService, serviceManager.EmailService, serviceManager.CacheService, serviceManager.OrderService, cfg, mw)
-	adminRoutes := admin.NewAdminRoutesManager(logger, serviceManager.ProductService, serviceManager.OrderService, mw)
+	adminRoutes := admin.NewAdminRoutesManager(logger, serviceManager.AuthService, serviceManager.ProductService, serviceManager.OrderService, serviceManager.FeatureFlags, mw)
 	ordersRoutes := orders.NewOrderRoutesManager(serviceManager.ProductService, serviceManager.OrderService, serviceManager.EmailService, mw, logger)
 	debugRoutes := debug.NewDebugRoutesManager(serviceManager.CacheService)
 
@@ -90,14 +107,7 @@ func run() error {
 	r := api.App(routerManager, mw, cfg)
 
 	// Setup server
-	server := &http.Server{
-		Addr:              cfg.Server.Port,
-		Handler:           r,
-		ReadTimeout:       cfg.Server.ReadTimeout,
-		WriteTimeout:      cfg.Server.WriteTimeout,
-		IdleTimeout:       cfg.Server.IdleTimeout,
-		ReadHeaderTimeout: cfg.Server.ReadHeaderTimeout,
-	}
+	server := api.NewServer(cfg.Server, r)
 
 	// Graceful shutdown context
 	serverCtx, serverStopCtx := context.WithCancel(context.Background())
@@ -108,6 +118,9 @@ func run() error {
 	go func() {
 		<-sig
 
+		// Stop background jobs before draining the server
+		stopJobs()
+
 		// Shutdown signal with grace period of 30 seconds
 		shutdownCtx, cancel := context.WithTimeout(context.Background(), 30*time.Second)
 		defer cancel()
@@ -136,6 +149,10 @@ func run() error {
 	// Wait for server context to be stopped
 	<-serverCtx.Done()
 
+	// Send the emails still queued before the database and cache go away
+	stopEmails()
+	<-emailQueueDone
+
 	// Close resources
 	var wg sync.WaitGroup
 	var closeErr error
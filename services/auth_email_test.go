@@ -0,0 +1,96 @@
+package services
+
+import (
+	"context"
+	"mamabloemetjes_server/structs"
+	"mamabloemetjes_server/structs/tables"
+	"mamabloemetjes_server/testutil"
+	"testing"
+	"time"
+
+	"github.com/google/uuid"
+)
+
+func TestMixedCaseRegistrationThenLogin(t *testing.T) {
+	ts := newTestServices(t)
+
+	registered, err := ts.auth.Register(&structs.RegisterRequest{
+		Username: "Jan Jansen",
+		Email:    "  Jan.Jansen@Example.COM ",
+		Password: "correct horse battery",
+	})
+	if err != nil {
+		t.Fatalf("Register: %v", err)
+	}
+	if registered.Email != "jan.jansen@example.com" {
+		t.Fatalf("expected the email to be stored normalized, got %q", registered.Email)
+	}
+
+	for _, email := range []string{"jan.jansen@example.com", "JAN.JANSEN@EXAMPLE.COM", "Jan.Jansen@example.com "} {
+		user, err := ts.auth.Login(&structs.AuthRequest{Email: email, Password: "correct horse battery"})
+		if err != nil {
+			t.Fatalf("Login(%q): %v", email, err)
+		}
+		if user.Id != registered.Id {
+			t.Fatalf("Login(%q) returned another account", email)
+		}
+	}
+
+	if _, err := ts.auth.Register(&structs.RegisterRequest{
+		Username: "Jan Jansen",
+		Email:    "JAN.JANSEN@example.com",
+		Password: "another password",
+	}); err == nil {
+		t.Fatal("expected registering the same email in another case to fail")
+	}
+}
+
+func TestEmailLowercaseMigrationResolvesDuplicates(t *testing.T) {
+	ts := newTestServices(t)
+	ctx := context.Background()
+	migration := testutil.SQLSection(t, "users_table.sql", "-- Accounts whose emails differ only in case", "    TABLESPACE pg_default;")
+
+	// Databases from before normalization have no case-insensitive index
+	if _, err := ts.db.ExecContext(ctx, "DROP INDEX idx_users_email_lower"); err != nil {
+		t.Fatalf("failed to drop the index: %v", err)
+	}
+
+	lastWeek := time.Now().Add(-7 * 24 * time.Hour)
+	users := []*tables.User{
+		{Username: "Old unverified", Email: "Anna@Example.com", CreatedAt: lastWeek.Add(-time.Hour)},
+		{Username: "Verified", Email: "anna@example.com ", EmailVerified: true, CreatedAt: lastWeek},
+		{Username: "Mixed case only", Email: "Piet@Example.com", CreatedAt: lastWeek},
+	}
+	for _, user := range users {
+		user.Id = uuid.New()
+		user.PasswordHash = "unused"
+		user.Role = "user"
+		user.LastLogin = user.CreatedAt
+		if _, err := ts.db.NewInsert().Model(user).Exec(ctx); err != nil {
+			t.Fatalf("failed to seed user: %v", err)
+		}
+	}
+
+	if _, err := ts.db.ExecContext(ctx, migration); err != nil {
+		t.Fatalf("migration failed: %v", err)
+	}
+
+	reload := func(user *tables.User) *tables.User {
+		reloaded := new(tables.User)
+		if err := ts.db.NewSelect().Model(reloaded).Where("id = ?", user.Id).Scan(ctx); err != nil {
+			t.Fatalf("failed to reload user: %v", err)
+		}
+		return reloaded
+	}
+
+	if kept := reload(users[1]); kept.Email != "anna@example.com" || kept.TokenVersion != 0 {
+		t.Fatalf("expected the verified account to keep the address, got %q (token version %d)", kept.Email, kept.TokenVersion)
+	}
+	duplicate := reload(users[0])
+	if duplicate.Email != "anna@example.com#duplicate-"+users[0].Id.String() || duplicate.TokenVersion != 1 {
+		t.Fatalf("expected the other account to be renamed and signed out, got %q (token version %d)", duplicate.Email, duplicate.TokenVersion)
+	}
+	if lowered := reload(users[2]); lowered.Email != "piet@example.com" {
+		t.Fatalf("expected the email to be lowercased, got %q", lowered.Email)
+	}
+}
@@ -0,0 +1,75 @@
+package services
+
+import (
+	"context"
+	"errors"
+	"mamabloemetjes_server/lib"
+	"mamabloemetjes_server/testutil"
+	"testing"
+)
+
+func TestRefreshTokenIsSingleUse(t *testing.T) {
+	ts := newTestServices(t)
+	user := ts.registerUser(t, "jan@example.com")
+
+	refreshToken, err := ts.auth.GenerateRefreshToken(user, "test-agent", "1.2.3.4")
+	if err != nil {
+		t.Fatalf("GenerateRefreshToken: %v", err)
+	}
+	jti, err := lib.RefreshTokenJti(refreshToken)
+	if err != nil {
+		t.Fatalf("RefreshTokenJti: %v", err)
+	}
+
+	rotated, err := ts.auth.RefreshAccessToken(refreshToken, "test-agent", "1.2.3.4")
+	if err != nil {
+		t.Fatalf("RefreshAccessToken: %v", err)
+	}
+
+	// The rotated token's session is revoked and its jti blacklisted
+	if session, err := ts.auth.GetLiveSession(context.Background(), jti); session != nil || !lib.IsNotFound(err) {
+		t.Fatalf("expected the rotated session to be revoked, got %+v (err %v)", session, err)
+	}
+	if session, err := ts.auth.GetSession(jti); err != nil || session.RevokedAt == nil {
+		t.Fatalf("expected the rotated session to carry a revoked_at, got %+v (err %v)", session, err)
+	}
+	if blacklisted, err := ts.cache.IsTokenBlacklisted(jti); err != nil || !blacklisted {
+		t.Fatalf("expected the rotated refresh token to be blacklisted (err %v)", err)
+	}
+
+	if _, err := ts.auth.RefreshAccessToken(refreshToken, "test-agent", "1.2.3.4"); !errors.Is(err, lib.ErrInvalidToken) {
+		t.Fatalf("expected a reused refresh token to be rejected, got %v", err)
+	}
+
+	// The new token is the one that works now
+	if _, err := ts.auth.RefreshAccessToken(rotated.RefreshToken, "test-agent", "1.2.3.4"); err != nil {
+		t.Fatalf("expected the rotated refresh token to work, got %v", err)
+	}
+}
+
+func TestRefreshRejectedWhenRedisFails(t *testing.T) {
+	redis := testutil.Redis(t)
+	ts := newTestServices(t)
+	user := ts.registerUser(t, "jan@example.com")
+
+	refreshToken, err := ts.auth.GenerateRefreshToken(user, "test-agent", "1.2.3.4")
+	if err != nil {
+		t.Fatalf("GenerateRefreshToken: %v", err)
+	}
+	jti, err := lib.RefreshTokenJti(refreshToken)
+	if err != nil {
+		t.Fatalf("RefreshTokenJti: %v", err)
+	}
+
+	redis.SetError("ERR connection lost")
+	_, err = ts.auth.RefreshAccessToken(refreshToken, "test-agent", "1.2.3.4")
+	redis.SetError("")
+	if !errors.Is(err, lib.ErrInvalidToken) {
+		t.Fatalf("expected the refresh to fail closed, got %v", err)
+	}
+
+	// Nothing was consumed, the session is still live
+	if _, err := ts.auth.GetLiveSession(context.Background(), jti); err != nil {
+		t.Fatalf("expected the session to survive a failed refresh, got %v", err)
+	}
+}
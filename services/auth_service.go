@@ -4,45 +4,49 @@ import (
 	"context"
 	"crypto/rand"
 	"encoding/base64"
+	"errors"
 	"fmt"
 	"mamabloemetjes_server/database"
 	"mamabloemetjes_server/lib"
 	"mamabloemetjes_server/structs"
 	"mamabloemetjes_server/structs/tables"
 	"time"
+	"unicode/utf8"
 
 	"github.com/MonkyMars/gecho"
 	"github.com/golang-jwt/jwt/v5"
 	"github.com/google/uuid"
+	"github.com/uptrace/bun"
 	"golang.org/x/crypto/argon2"
 )
 
-var DefaultParams = &structs.ArgonParams{
-	Memory:  64 * 1024, // 64 MB
-	Time:    1,
-	Threads: 4,
-	KeyLen:  32,
-	SaltLen: 16,
-}
-
 type AuthService struct {
 	logger       *gecho.Logger
 	cfg          *structs.Config
 	db           *database.DB
 	cacheService *CacheService
+	argonParams  *structs.ArgonParams // cost of new password hashes, from cfg.Argon
 }
 
-func NewAuthService(cfg *structs.Config, logger *gecho.Logger, db *database.DB) *AuthService {
+func NewAuthService(cfg *structs.Config, logger *gecho.Logger, db *database.DB, cacheService *CacheService) *AuthService {
 	return &AuthService{
 		logger:       logger,
 		cfg:          cfg,
 		db:           db,
-		cacheService: NewCacheService(logger, cfg),
+		cacheService: cacheService,
+		argonParams: &structs.ArgonParams{
+			Memory:  uint32(cfg.Argon.Memory),
+			Time:    uint32(cfg.Argon.Time),
+			Threads: uint8(cfg.Argon.Threads),
+			KeyLen:  uint32(cfg.Argon.KeyLen),
+			SaltLen: uint32(cfg.Argon.SaltLen),
+		},
 	}
 }
 
 func (as *AuthService) Login(authRequest *structs.AuthRequest) (*tables.User, error) {
 	startTime := time.Now()
+	authRequest.Email = lib.NormalizeEmail(authRequest.Email)
 	user, err := database.Query[tables.User](as.db).Where("email", authRequest.Email).First(context.Background())
 	if err != nil {
 		// Map database error to user-friendly message
@@ -89,6 +93,15 @@ func (as *AuthService) Login(authRequest *structs.AuthRequest) (*tables.User, er
 		return nil, lib.ErrInvalidCredentials
 	}
 
+	// Move the hash onto the active pepper and cost parameters while the plain-text password is at hand
+	if as.needsRehash(user.PasswordHash) {
+		if err := as.upgradePasswordHash(user.Id, authRequest.Password); err != nil {
+			as.logger.Warn("Failed to upgrade password hash", gecho.Field("error", err), gecho.Field("user_id", user.Id))
+		} else {
+			as.logger.Info("Password hash upgraded", gecho.Field("user_id", user.Id))
+		}
+	}
+
 	elapsedTime := time.Since(startTime)
 	as.logger.Debug("User logged in successfully", gecho.Field("user_id", user.Id), gecho.Field("elapsed_time_ms", elapsedTime.Milliseconds()))
 
@@ -106,7 +119,8 @@ func (as *AuthService) Login(authRequest *structs.AuthRequest) (*tables.User, er
 
 func (as *AuthService) Register(registerRequest *structs.RegisterRequest) (*tables.User, error) {
 	startTime := time.Now()
-	passwordHash, err := as.HashPassword(registerRequest.Password, DefaultParams)
+	registerRequest.Email = lib.NormalizeEmail(registerRequest.Email)
+	passwordHash, err := as.HashPassword(registerRequest.Password, as.argonParams)
 	if err != nil {
 		as.logger.Error("Failed to hash password", gecho.Field("error", err))
 		return nil, err
@@ -116,7 +130,8 @@ func (as *AuthService) Register(registerRequest *structs.RegisterRequest) (*tabl
 		Email:        registerRequest.Email,
 		PasswordHash: passwordHash,
 	}
-	user, err = database.Query[tables.User](as.db).Insert(context.Background(), user)
+	// The insert result is kept apart from the request struct, so a failed insert never hands back a half-built user
+	created, err := database.Query[tables.User](as.db).Insert(context.Background(), user)
 	if err != nil {
 		// Map the error to a user-friendly message
 		mappedErr := lib.MapPgError(err)
@@ -139,25 +154,33 @@ func (as *AuthService) Register(registerRequest *structs.RegisterRequest) (*tabl
 	}
 
 	elapsedTime := time.Since(startTime)
-	as.logger.Debug("User registered successfully", gecho.Field("user_id", user.Id), gecho.Field("elapsed_time_ms", elapsedTime.Milliseconds()))
+	as.logger.Debug("User registered successfully", gecho.Field("user_id", created.Id), gecho.Field("elapsed_time_ms", elapsedTime.Milliseconds()))
 
 	// Remove password hash before returning user
-	user.PasswordHash = ""
+	created.PasswordHash = ""
 
-	return user, nil
+	return created, nil
 }
 
-// HashPassword hashes a plain-text password and returns a string and possible error
+// HashPassword hashes a plain-text password, peppered with the active pepper version, and returns a string and possible error
 func (as *AuthService) HashPassword(password string, p *structs.ArgonParams) (string, error) {
 	salt, err := generateSalt(p.SaltLen)
 	if err != nil {
 		return "", err
 	}
-	hash := argon2.IDKey([]byte(password), salt, p.Time, p.Memory, p.Threads, p.KeyLen)
+	pepperVersion := as.cfg.Auth.PasswordPepperVersion
+	pepper, err := as.pepper(pepperVersion)
+	if err != nil {
+		return "", err
+	}
+	hash := argon2.IDKey(lib.PepperPassword(password, pepper), salt, p.Time, p.Memory, p.Threads, p.KeyLen)
 	b64Salt := base64.RawStdEncoding.EncodeToString(salt)
 	b64Hash := base64.RawStdEncoding.EncodeToString(hash)
-	// format: $argon2id$v=19$m=65536,t=1,p=4$<salt>$<hash>
+	// format: $argon2id$v=19$m=65536,t=1,p=4[,pv=1]$<salt>$<hash>
 	params := fmt.Sprintf("m=%d,t=%d,p=%d", p.Memory, p.Time, p.Threads)
+	if pepperVersion > 0 {
+		params += fmt.Sprintf(",pv=%d", pepperVersion)
+	}
 	encoded := fmt.Sprintf("$argon2id$v=19$%s$%s$%s", params, b64Salt, b64Hash)
 	return encoded, nil
 }
@@ -175,13 +198,75 @@ func (as *AuthService) VerifyPassword(password, hashedPassword string) (bool, er
 		return false, err
 	}
 
-	// Hash the input password with the same parameters
-	hash := argon2.IDKey([]byte(password), parts.Salt, parts.Time, parts.Memory, parts.Threads, parts.KeyLen)
+	// Refuse hashes that would make argon2 allocate or iterate beyond what we allow
+	if !parts.WithinLimits(
+		uint32(as.cfg.Auth.PasswordMaxMemory),
+		uint32(as.cfg.Auth.PasswordMaxTime),
+		uint8(as.cfg.Auth.PasswordMaxThreads),
+	) {
+		return false, lib.ErrHashParamsExceeded
+	}
+
+	// Hash the input password with the same parameters and pepper
+	pepper, err := as.pepper(parts.PepperVersion)
+	if err != nil {
+		return false, err
+	}
+	hash := argon2.IDKey(lib.PepperPassword(password, pepper), parts.Salt, parts.Time, parts.Memory, parts.Threads, parts.KeyLen)
 
 	// Compare the hashes
 	return lib.SecureCompare(hash, parts.Hash), nil
 }
 
+// pepper returns the configured pepper for a version; version 0 means no pepper
+func (as *AuthService) pepper(version int) (string, error) {
+	if version == 0 {
+		return "", nil
+	}
+	pepper, ok := as.cfg.Auth.PasswordPeppers[version]
+	if !ok {
+		return "", lib.ErrUnknownPepper
+	}
+	return pepper, nil
+}
+
+// needsRehash reports whether a stored hash was made with a pepper version other than the active one,
+// or with cost parameters weaker than the configured ones
+func (as *AuthService) needsRehash(hashedPassword string) bool {
+	parts, err := lib.DecodeArgon2Hash(hashedPassword)
+	if err != nil {
+		return false
+	}
+	return parts.PepperVersion != as.cfg.Auth.PasswordPepperVersion || weakerThan(parts, as.argonParams)
+}
+
+// weakerThan reports whether any cost parameter of a decoded hash falls below p
+func weakerThan(parts *lib.Argon2HashParts, p *structs.ArgonParams) bool {
+	return parts.Memory < p.Memory ||
+		parts.Time < p.Time ||
+		parts.Threads < p.Threads ||
+		parts.KeyLen < p.KeyLen ||
+		uint32(len(parts.Salt)) < p.SaltLen
+}
+
+// upgradePasswordHash rehashes a verified password with the active pepper and the configured cost parameters,
+// so retired peppers can be dropped and the hashing cost raised without forcing resets
+func (as *AuthService) upgradePasswordHash(userId uuid.UUID, password string) error {
+	passwordHash, err := as.HashPassword(password, as.argonParams)
+	if err != nil {
+		return err
+	}
+
+	_, err = database.Query[tables.User](as.db).
+		Where("id", userId).
+		Update(context.Background(), map[string]any{"password_hash": passwordHash})
+	if err != nil {
+		return lib.MapPgError(err)
+	}
+
+	return nil
+}
+
 // GenerateAccessToken generates a JWT access token for the given user
 func (as *AuthService) GenerateAccessToken(user *tables.User) (string, error) {
 	secret := as.cfg.Auth.AccessTokenSecret
@@ -196,17 +281,31 @@ func (as *AuthService) GenerateAccessToken(user *tables.User) (string, error) {
 		Iat:   now,
 		Exp:   exp,
 		Jti:   uuid.New(),
+		Ver:   user.TokenVersion,
 	}
 
-	token := jwt.NewWithClaims(jwt.SigningMethodHS256, jwt.MapClaims{
+	token := jwt.NewWithClaims(jwt.SigningMethodHS256, as.tokenClaims(claims))
+	return token.SignedString([]byte(secret))
+}
+
+// tokenClaims builds the JWT claims of a token, adding iss and aud when they are configured
+func (as *AuthService) tokenClaims(claims *structs.AuthClaims) jwt.MapClaims {
+	mapClaims := jwt.MapClaims{
 		"sub":   claims.Sub.String(),
 		"email": claims.Email,
 		"role":  claims.Role,
 		"iat":   claims.Iat.Unix(),
 		"exp":   claims.Exp.Unix(),
 		"jti":   claims.Jti.String(),
-	})
-	return token.SignedString([]byte(secret))
+		"ver":   claims.Ver,
+	}
+	if as.cfg.Auth.TokenIssuer != "" {
+		mapClaims["iss"] = as.cfg.Auth.TokenIssuer
+	}
+	if as.cfg.Auth.TokenAudience != "" {
+		mapClaims["aud"] = as.cfg.Auth.TokenAudience
+	}
+	return mapClaims
 }
 
 // GetAccessTokenExpiration returns the expiration time for access tokens
@@ -214,8 +313,13 @@ func (as *AuthService) GetAccessTokenExpiration() time.Time {
 	return time.Now().Add(time.Duration(as.cfg.Auth.AccessTokenExpiry))
 }
 
-// GenerateRefreshToken generates a JWT refresh token for the given user
-func (as *AuthService) GenerateRefreshToken(user *tables.User) (string, error) {
+// GenerateRefreshToken generates a JWT refresh token for the given user and records its session
+func (as *AuthService) GenerateRefreshToken(user *tables.User, userAgent, ip string) (string, error) {
+	return as.generateRefreshToken(user, userAgent, ip, "")
+}
+
+// generateRefreshToken issues a refresh token whose session starts with the given CSRF token hash
+func (as *AuthService) generateRefreshToken(user *tables.User, userAgent, ip, csrfTokenHash string) (string, error) {
 	secret := as.cfg.Auth.RefreshTokenSecret
 
 	now := time.Now()
@@ -228,17 +332,20 @@ func (as *AuthService) GenerateRefreshToken(user *tables.User) (string, error) {
 		Iat:   now,
 		Exp:   exp,
 		Jti:   uuid.New(),
+		Ver:   user.TokenVersion,
 	}
 
-	token := jwt.NewWithClaims(jwt.SigningMethodHS256, jwt.MapClaims{
-		"sub":   claims.Sub.String(),
-		"email": claims.Email,
-		"role":  claims.Role,
-		"iat":   claims.Iat.Unix(),
-		"exp":   claims.Exp.Unix(),
-		"jti":   claims.Jti.String(),
-	})
-	return token.SignedString([]byte(secret))
+	token := jwt.NewWithClaims(jwt.SigningMethodHS256, as.tokenClaims(claims))
+	signed, err := token.SignedString([]byte(secret))
+	if err != nil {
+		return "", err
+	}
+
+	if _, err := as.CreateSession(user.Id, claims.Jti, userAgent, ip, exp, csrfTokenHash); err != nil {
+		return "", err
+	}
+
+	return signed, nil
 }
 
 // GetRefreshTokenExpiration returns the expiration time for refresh tokens
@@ -246,37 +353,75 @@ func (as *AuthService) GetRefreshTokenExpiration() time.Time {
 	return time.Now().Add(time.Duration(as.cfg.Auth.RefreshTokenExpiry))
 }
 
-func (as *AuthService) RefreshAccessToken(refreshToken string) (*tables.AuthResponse, error) {
-	claims, err := lib.ParseToken(refreshToken, false, as.cfg.Auth.RefreshTokenSecret)
+// RefreshAccessToken rotates a refresh token: its session is revoked and a new token pair with a new session is issued
+func (as *AuthService) RefreshAccessToken(refreshToken, userAgent, ip string) (*tables.AuthResponse, error) {
+	claims, err := lib.ParseToken(refreshToken, false, as.cfg.Auth.RefreshTokenSecret, as.cfg.Auth.TokenLeeway, as.cfg.Auth.TokenIssuer, as.cfg.Auth.TokenAudience)
 	if err != nil {
+		if errors.Is(err, jwt.ErrTokenExpired) {
+			as.logger.Warn("Refresh token has expired", gecho.Field("error", err))
+			return nil, lib.ErrExpiredToken
+		}
 		as.logger.Error("Failed to parse refresh token", gecho.Field("error", err))
 		return nil, lib.ErrInvalidToken
 	}
 
-	if time.Now().After(claims.Exp) {
+	if time.Now().After(claims.Exp.Add(as.cfg.Auth.TokenLeeway)) {
 		as.logger.Warn("Refresh token has expired", gecho.Field("exp", claims.Exp))
 		return nil, lib.ErrExpiredToken
 	}
 
-	// TODO: check for blacklisted/revoked tokens
-	isBlacklisted, err := as.cacheService.IsTokenBlacklisted(claims.Jti)
+	// Refresh tokens are single use: blacklisting the jti and checking it was not already
+	// blacklisted (logout or an earlier rotation) happen in one step, so a leaked token cannot be replayed.
+	// When the blacklist is unreachable the refresh is rejected rather than risk accepting a revoked token
+	consumed, err := as.cacheService.ConsumeToken(claims.Jti, claims.Exp)
 	if err != nil {
-		as.logger.Error("Failed to check if token is blacklisted", gecho.Field("error", err), gecho.Field("jti", claims.Jti))
-		return nil, err
+		as.logger.Error("Failed to check refresh token blacklist, rejecting refresh", gecho.Field("error", err), gecho.Field("jti", claims.Jti))
+		return nil, lib.ErrInvalidToken
 	}
 
-	if isBlacklisted {
-		as.logger.Warn("Refresh token is blacklisted", gecho.Field("jti", claims.Jti))
+	if !consumed {
+		as.logger.Warn("Refresh token is blacklisted", gecho.Field("jti", claims.Jti), gecho.Field("user_id", claims.Sub))
 		return nil, lib.ErrInvalidToken
 	}
 
+	// The sessions table is the source of truth: the token must belong to a live session, which is revoked on rotation
+	revoked, err := as.revokeLiveSession(claims.Jti)
+	if err != nil {
+		as.logger.Error("Failed to revoke session during refresh", gecho.Field("error", err), gecho.Field("jti", claims.Jti))
+		return nil, lib.ErrInvalidToken
+	}
+	if !revoked {
+		as.logger.Warn("Refresh token has no live session", gecho.Field("jti", claims.Jti), gecho.Field("user_id", claims.Sub))
+		return nil, lib.ErrInvalidToken
+	}
+
+	// The CSRF token moves to the new session, so the client's token keeps working after the rotation
+	var csrfTokenHash string
+	if previous, err := as.GetSession(claims.Jti); err != nil {
+		as.logger.Warn("Failed to load rotated session, CSRF token is not carried over", gecho.Field("error", err), gecho.Field("jti", claims.Jti))
+	} else if previous != nil {
+		csrfTokenHash = previous.CSRFTokenHash
+	}
+
 	// get user
-	user, err := as.GetUserByID(claims.Sub)
+	user, err := as.GetUserByID(context.Background(), claims.Sub)
 	if err != nil {
 		as.logger.Error("Failed to get user by ID during token refresh", gecho.Field("error", err), gecho.Field("user_id", claims.Sub))
 		return nil, err
 	}
 
+	tokenVersion, err := as.CurrentTokenVersion(context.Background(), claims.Sub)
+	if err != nil {
+		as.logger.Error("Failed to get token version during token refresh", gecho.Field("error", err), gecho.Field("user_id", claims.Sub))
+		return nil, err
+	}
+	if claims.Ver != tokenVersion {
+		as.logger.Warn("Refresh token has an outdated token version", gecho.Field("user_id", claims.Sub), gecho.Field("token_version", claims.Ver))
+		return nil, lib.ErrInvalidToken
+	}
+	// The cached user may predate the last bump, the new tokens must carry the current version
+	user.TokenVersion = tokenVersion
+
 	// generate new tokens
 	newAccessToken, err := as.GenerateAccessToken(user)
 	if err != nil {
@@ -284,7 +429,7 @@ func (as *AuthService) RefreshAccessToken(refreshToken string) (*tables.AuthResp
 		return nil, err
 	}
 
-	newRefreshToken, err := as.GenerateRefreshToken(user)
+	newRefreshToken, err := as.generateRefreshToken(user, userAgent, ip, csrfTokenHash)
 	if err != nil {
 		as.logger.Error("Failed to generate new refresh token during refresh", gecho.Field("error", err), gecho.Field("user_id", user.Id))
 		return nil, err
@@ -297,7 +442,7 @@ func (as *AuthService) RefreshAccessToken(refreshToken string) (*tables.AuthResp
 	}, nil
 }
 
-func (as *AuthService) GetUserByID(userId uuid.UUID) (*tables.User, error) {
+func (as *AuthService) GetUserByID(ctx context.Context, userId uuid.UUID) (*tables.User, error) {
 	// Try to get user from cache first
 	cachedUser, err := as.cacheService.GetUserFromCache(userId)
 	if err != nil {
@@ -308,7 +453,7 @@ func (as *AuthService) GetUserByID(userId uuid.UUID) (*tables.User, error) {
 	}
 
 	// Cache miss - fetch user from database
-	user, err := database.Query[tables.User](as.db).Where("id", userId).First(context.Background())
+	user, err := database.Query[tables.User](as.db).Where("id", userId).First(ctx)
 	if err != nil {
 		as.logger.Error("Failed to find user by ID", gecho.Field("error", err), gecho.Field("user_id", userId))
 		return nil, lib.MapPgError(err)
@@ -324,6 +469,51 @@ func (as *AuthService) GetUserByID(userId uuid.UUID) (*tables.User, error) {
 	return user, nil
 }
 
+// CurrentTokenVersion returns the user's token version for checking a token against. It never trusts the cached
+// user, which is repopulated asynchronously and may predate a password change, and fills a cache miss synchronously
+func (as *AuthService) CurrentTokenVersion(ctx context.Context, userId uuid.UUID) (int, error) {
+	version, cached, err := as.cacheService.GetTokenVersion(userId)
+	if err != nil {
+		as.logger.Warn("Failed to get token version from cache", gecho.Field("error", err), gecho.Field("user_id", userId))
+	} else if cached {
+		return version, nil
+	}
+
+	err = as.db.NewSelect().
+		Model((*tables.User)(nil)).
+		Column("token_version").
+		Where("id = ?", userId).
+		Scan(ctx, &version)
+	if err != nil {
+		return 0, lib.MapPgError(err)
+	}
+
+	if err := as.cacheService.CacheTokenVersionIfAbsent(userId, version); err != nil {
+		as.logger.Warn("Failed to cache token version", gecho.Field("error", err), gecho.Field("user_id", userId))
+	}
+	return version, nil
+}
+
+// publishTokenVersion drops the cached user and caches the version a password change or sign-out everywhere bumped to, so no token
+// carrying an older version passes CurrentTokenVersion. It runs whether or not revoking the sessions succeeds
+func (as *AuthService) publishTokenVersion(userId uuid.UUID, version int) {
+	if err := as.cacheService.DeleteUserFromCache(userId); err != nil {
+		as.logger.Warn("Failed to invalidate user cache after token version bump", gecho.Field("error", err), gecho.Field("user_id", userId))
+	}
+	if err := as.cacheService.SetTokenVersion(userId, version); err != nil {
+		as.logger.Error("Failed to cache bumped token version", gecho.Field("error", err), gecho.Field("user_id", userId))
+	}
+}
+
+// GetUserByEmail retrieves a user by (normalized) email address
+func (as *AuthService) GetUserByEmail(email string) (*tables.User, error) {
+	user, err := database.Query[tables.User](as.db).Where("email", lib.NormalizeEmail(email)).First(context.Background())
+	if err != nil {
+		return nil, lib.MapPgError(err)
+	}
+	return user, nil
+}
+
 func (as *AuthService) GetAccessTokenSecret() string {
 	secret := as.cfg.Auth.AccessTokenSecret
 	return secret
@@ -345,7 +535,173 @@ func (as *AuthService) UpdateLastLogin(userId uuid.UUID) error {
 	return nil
 }
 
+// CheckEmailVerification reports whether token is a valid, unexpired verification token for the user
+// It does not consume the token, so it is safe for requests that link scanners may trigger
+func (as *AuthService) CheckEmailVerification(userId uuid.UUID, token string) error {
+	_, err := as.findEmailVerification(userId, token)
+	return err
+}
+
+// VerifyEmail marks the user's email as verified and consumes the verification token
 func (as *AuthService) VerifyEmail(userId uuid.UUID, token string) error {
+	verification, err := as.findEmailVerification(userId, token)
+	if err != nil {
+		return err
+	}
+
+	// Update user to set email as verified
+	updates := map[string]any{
+		"email_verified": true,
+	}
+	_, err = database.Query[tables.User](as.db).Where("id", userId).Update(context.Background(), updates)
+	if err != nil {
+		as.logger.Error("Failed to update user email verification status", gecho.Field("error", err), gecho.Field("user_id", userId))
+		return lib.MapPgError(err)
+	}
+
+	// Delete verification record
+	_, err = database.Query[tables.EmailVerification](as.db).Where("id", verification.Id).Delete(context.Background())
+	if err != nil {
+		as.logger.Error("Failed to delete email verification record", gecho.Field("error", err), gecho.Field("user_id", userId))
+		return lib.MapPgError(err)
+	}
+
+	// Drop the cached user so the verified status is picked up immediately
+	if err := as.cacheService.DeleteUserFromCache(userId); err != nil {
+		as.logger.Warn("Failed to invalidate user cache after email verification", gecho.Field("error", err), gecho.Field("user_id", userId))
+	}
+
+	as.logger.Info("Email verified successfully", gecho.Field("user_id", userId))
+	return nil
+}
+
+// ChangePassword sets a new password for a signed-in user after checking their current one
+// A wrong current password returns lib.ErrInvalidCredentials. On success the cached user is dropped and
+// every session of the user is revoked; the caller signs the requesting client back in with a new session
+func (as *AuthService) ChangePassword(userId uuid.UUID, current, newPassword string) error {
+	// Read from the database, the cached user may be stale
+	user, err := database.Query[tables.User](as.db).Where("id", userId).First(context.Background())
+	if err != nil {
+		as.logger.Error("Failed to find user for password change", gecho.Field("error", err), gecho.Field("user_id", userId))
+		return lib.MapPgError(err)
+	}
+	if user == nil {
+		return lib.ErrNotFound
+	}
+
+	valid, err := as.VerifyPassword(current, user.PasswordHash)
+	if err != nil {
+		as.logger.Error("Failed to verify password hash", gecho.Field("error", err), gecho.Field("user_id", userId))
+		return err
+	}
+	if !valid {
+		as.logger.Warn("Password change with wrong current password", gecho.Field("user_id", userId))
+		return lib.ErrInvalidCredentials
+	}
+
+	passwordHash, err := as.HashPassword(newPassword, as.argonParams)
+	if err != nil {
+		as.logger.Error("Failed to hash password", gecho.Field("error", err))
+		return err
+	}
+
+	// Bumping the token version invalidates every access and refresh token issued with the old password
+	var tokenVersion int
+	_, err = as.db.NewUpdate().
+		Model((*tables.User)(nil)).
+		Set("password_hash = ?", passwordHash).
+		Set("token_version = token_version + 1").
+		Where("id = ?", userId).
+		Returning("token_version").
+		Exec(context.Background(), &tokenVersion)
+	if err != nil {
+		as.logger.Error("Failed to update password", gecho.Field("error", err), gecho.Field("user_id", userId))
+		return lib.MapPgError(err)
+	}
+	as.publishTokenVersion(userId, tokenVersion)
+
+	if _, err := as.revokeSessions(userId); err != nil {
+		as.logger.Error("Failed to revoke sessions after password change", gecho.Field("error", err), gecho.Field("user_id", userId))
+	}
+
+	as.logger.Info("Password changed", gecho.Field("user_id", userId))
+	return nil
+}
+
+// ResetPassword sets a new password with a password reset token and consumes the token
+// Unknown and used tokens return lib.ErrInvalidToken, expired ones lib.ErrExpiredToken. The token is
+// locked while it is redeemed, so two concurrent requests cannot both use it. On success every session
+// of the user is revoked, signing out whoever knew the old password
+func (as *AuthService) ResetPassword(ctx context.Context, token, newPassword string) error {
+	// Hash before the transaction, argon2 is deliberately slow
+	passwordHash, err := as.HashPassword(newPassword, as.argonParams)
+	if err != nil {
+		as.logger.Error("Failed to hash password", gecho.Field("error", err))
+		return err
+	}
+
+	var userId uuid.UUID
+	var tokenVersion int
+	err = database.Transaction(as.db, ctx, func(tx bun.Tx) error {
+		reset := new(tables.PasswordReset)
+		err := tx.NewSelect().
+			Model(reset).
+			Where("token_hash = ?", lib.HashToken(token)).
+			For("UPDATE").
+			Scan(ctx)
+		if err != nil {
+			if err = lib.MapPgError(err); lib.IsNotFound(err) {
+				return lib.ErrInvalidToken
+			}
+			return err
+		}
+
+		if reset.UsedAt != nil {
+			as.logger.Warn("Password reset token already used", gecho.Field("user_id", reset.UserId))
+			return lib.ErrInvalidToken
+		}
+		if time.Now().After(reset.ExpiresAt) {
+			as.logger.Warn("Password reset token has expired", gecho.Field("user_id", reset.UserId), gecho.Field("expires_at", reset.ExpiresAt))
+			return lib.ErrExpiredToken
+		}
+
+		now := time.Now()
+		if _, err := tx.NewUpdate().
+			Model((*tables.PasswordReset)(nil)).
+			Set("used_at = ?", now).
+			Where("id = ?", reset.Id).
+			Exec(ctx); err != nil {
+			return lib.MapPgError(err)
+		}
+
+		if _, err := tx.NewUpdate().
+			Model((*tables.User)(nil)).
+			Set("password_hash = ?", passwordHash).
+			Set("token_version = token_version + 1").
+			Where("id = ?", reset.UserId).
+			Returning("token_version").
+			Exec(ctx, &tokenVersion); err != nil {
+			return lib.MapPgError(err)
+		}
+
+		userId = reset.UserId
+		return nil
+	})
+	if err != nil {
+		return err
+	}
+	as.publishTokenVersion(userId, tokenVersion)
+
+	if _, err := as.revokeSessions(userId); err != nil {
+		as.logger.Error("Failed to revoke sessions after password reset", gecho.Field("error", err), gecho.Field("user_id", userId))
+	}
+
+	as.logger.Info("Password reset", gecho.Field("user_id", userId))
+	return nil
+}
+
+// findEmailVerification returns the user's verification record for token, rejecting unknown and expired tokens
+func (as *AuthService) findEmailVerification(userId uuid.UUID, token string) (*tables.EmailVerification, error) {
 	// Get verification record
 	verification, err := database.Query[tables.EmailVerification](as.db).
 		Where("user_id", userId).
@@ -353,45 +709,179 @@ func (as *AuthService) VerifyEmail(userId uuid.UUID, token string) error {
 		First(context.Background())
 	if err != nil {
 		as.logger.Error("Failed to find email verification record", gecho.Field("error", err), gecho.Field("user_id", userId))
-		return lib.MapPgError(err)
+		return nil, lib.MapPgError(err)
 	}
 	if verification == nil {
 		as.logger.Warn("Email verification record not found", gecho.Field("user_id", userId))
-		return lib.ErrInvalidToken
+		return nil, lib.ErrInvalidToken
 	}
 
 	// Check if token is expired
 	if time.Now().After(verification.ExpiresAt) {
 		as.logger.Warn("Email verification token has expired", gecho.Field("user_id", userId), gecho.Field("expires_at", verification.ExpiresAt))
-		return lib.ErrExpiredToken
+		return nil, lib.ErrExpiredToken
 	}
 
 	if token != verification.Token {
 		as.logger.Warn("Email verification token does not match", gecho.Field("user_id", userId))
-		return lib.ErrInvalidToken
+		return nil, lib.ErrInvalidToken
 	}
 
-	// Update user to set email as verified
-	updates := map[string]any{
-		"email_verified": true,
+	return verification, nil
+}
+
+// CreateSession records a session for a newly issued refresh token
+func (as *AuthService) CreateSession(userId, jti uuid.UUID, userAgent, ip string, expiresAt time.Time, csrfTokenHash string) (*tables.Session, error) {
+	session := &tables.Session{
+		UserId:        userId,
+		Jti:           jti,
+		UserAgent:     truncate(userAgent, 512),
+		Ip:            truncate(ip, 64),
+		CreatedAt:     time.Now(),
+		ExpiresAt:     expiresAt,
+		CSRFTokenHash: csrfTokenHash,
 	}
-	_, err = database.Query[tables.User](as.db).Where("id", userId).Update(context.Background(), updates)
+
+	session, err := database.Query[tables.Session](as.db).Insert(context.Background(), session)
 	if err != nil {
-		as.logger.Error("Failed to update user email verification status", gecho.Field("error", err), gecho.Field("user_id", userId))
-		return lib.MapPgError(err)
+		as.logger.Error("Failed to create session", gecho.Field("error", err), gecho.Field("user_id", userId))
+		return nil, lib.MapPgError(err)
 	}
 
-	// Delete verification record
-	_, err = database.Query[tables.EmailVerification](as.db).Where("id", verification.Id).Delete(context.Background())
+	return session, nil
+}
+
+// GetSession returns the session for a refresh token jti, or lib.ErrNotFound
+func (as *AuthService) GetSession(jti uuid.UUID) (*tables.Session, error) {
+	session, err := database.Query[tables.Session](as.db).Where("jti", jti).First(context.Background())
 	if err != nil {
-		as.logger.Error("Failed to delete email verification record", gecho.Field("error", err), gecho.Field("user_id", userId))
+		return nil, lib.MapPgError(err)
+	}
+
+	return session, nil
+}
+
+// GetLiveSession returns the session for a refresh token jti if it is neither revoked nor expired, or lib.ErrNotFound
+func (as *AuthService) GetLiveSession(ctx context.Context, jti uuid.UUID) (*tables.Session, error) {
+	session, err := database.Query[tables.Session](as.db).
+		Where("jti", jti).
+		WhereNull("revoked_at").
+		WhereOp("expires_at", ">", time.Now()).
+		First(ctx)
+	if err != nil {
+		return nil, lib.MapPgError(err)
+	}
+	if session == nil {
+		return nil, lib.ErrNotFound
+	}
+
+	return session, nil
+}
+
+// ListSessions returns the live sessions of a user, newest first, so they can see where they are signed in
+func (as *AuthService) ListSessions(userId uuid.UUID) ([]tables.Session, error) {
+	sessions, err := database.Query[tables.Session](as.db).
+		Where("user_id", userId).
+		WhereNull("revoked_at").
+		WhereOp("expires_at", ">", time.Now()).
+		OrderBy("created_at", database.DESC).
+		All(context.Background())
+	if err != nil {
+		as.logger.Error("Failed to list user sessions", gecho.Field("error", err), gecho.Field("user_id", userId))
+		return nil, lib.MapPgError(err)
+	}
+
+	return sessions, nil
+}
+
+// SetSessionCSRFToken stores the hash of a CSRF token on the live session of a refresh token jti,
+// replacing the token issued before. Only the hash is stored, the token itself stays with the client
+func (as *AuthService) SetSessionCSRFToken(jti uuid.UUID, token string) error {
+	_, err := database.Query[tables.Session](as.db).
+		Where("jti", jti).
+		WhereNull("revoked_at").
+		Update(context.Background(), map[string]any{"csrf_token_hash": lib.HashCSRFToken(token)})
+	if err != nil {
+		as.logger.Error("Failed to store session CSRF token", gecho.Field("error", err), gecho.Field("jti", jti))
 		return lib.MapPgError(err)
 	}
 
-	as.logger.Info("Email verified successfully", gecho.Field("user_id", userId))
 	return nil
 }
 
+// RevokeSession revokes the session of a refresh token; revoking an already revoked session is a no-op
+func (as *AuthService) RevokeSession(jti uuid.UUID) error {
+	if _, err := as.revokeLiveSession(jti); err != nil {
+		return err
+	}
+	return nil
+}
+
+// RevokeAllSessions signs a user out everywhere. Bumping the token version rejects every access token
+// issued so far, and revoking the sessions rejects every refresh token. It returns the number of sessions revoked
+func (as *AuthService) RevokeAllSessions(userId uuid.UUID) (int, error) {
+	var tokenVersion int
+	_, err := as.db.NewUpdate().
+		Model((*tables.User)(nil)).
+		Set("token_version = token_version + 1").
+		Where("id = ?", userId).
+		Returning("token_version").
+		Exec(context.Background(), &tokenVersion)
+	if err != nil {
+		as.logger.Error("Failed to bump token version", gecho.Field("error", err), gecho.Field("user_id", userId))
+		return 0, lib.MapPgError(err)
+	}
+	as.publishTokenVersion(userId, tokenVersion)
+
+	return as.revokeSessions(userId)
+}
+
+// revokeSessions revokes every live session of a user, so none of their refresh tokens can be used again,
+// and drops the cached user. It returns the number of sessions revoked
+func (as *AuthService) revokeSessions(userId uuid.UUID) (int, error) {
+	affected, err := database.Query[tables.Session](as.db).
+		Where("user_id", userId).
+		WhereNull("revoked_at").
+		Update(context.Background(), map[string]any{"revoked_at": time.Now()})
+	if err != nil {
+		as.logger.Error("Failed to revoke user sessions", gecho.Field("error", err), gecho.Field("user_id", userId))
+		return 0, lib.MapPgError(err)
+	}
+
+	if err := as.cacheService.DeleteUserFromCache(userId); err != nil {
+		as.logger.Warn("Failed to invalidate user cache after revoking sessions", gecho.Field("error", err), gecho.Field("user_id", userId))
+	}
+
+	as.logger.Info("Revoked all user sessions", gecho.Field("user_id", userId), gecho.Field("revoked", affected))
+	return affected, nil
+}
+
+// revokeLiveSession revokes the session for jti if it is still live and reports whether it was.
+// The check and the update are one statement, so concurrent refreshes cannot both rotate the same session
+func (as *AuthService) revokeLiveSession(jti uuid.UUID) (bool, error) {
+	affected, err := database.Query[tables.Session](as.db).
+		Where("jti", jti).
+		WhereNull("revoked_at").
+		WhereOp("expires_at", ">", time.Now()).
+		Update(context.Background(), map[string]any{"revoked_at": time.Now()})
+	if err != nil {
+		return false, lib.MapPgError(err)
+	}
+
+	return affected > 0, nil
+}
+
+// truncate cuts s to at most n bytes without splitting a multi-byte character
+func truncate(s string, n int) string {
+	if len(s) <= n {
+		return s
+	}
+	for n > 0 && !utf8.RuneStart(s[n]) {
+		n--
+	}
+	return s[:n]
+}
+
 // GetDB returns the database instance (helper method for accessing db)
 func (as *AuthService) GetDB() *database.DB {
 	return as.db
@@ -0,0 +1,182 @@
+package services
+
+import (
+	"context"
+	"errors"
+	"mamabloemetjes_server/lib"
+	"mamabloemetjes_server/structs"
+	"mamabloemetjes_server/structs/tables"
+	"mamabloemetjes_server/testutil"
+	"testing"
+
+	"github.com/google/uuid"
+)
+
+const testPassword = "correct horse battery"
+
+// registerUser registers a user with testPassword
+func (ts *testServices) registerUser(t *testing.T, email string) *tables.User {
+	t.Helper()
+	user, err := ts.auth.Register(&structs.RegisterRequest{Username: "Test User", Email: email, Password: testPassword})
+	if err != nil {
+		t.Fatalf("Register: %v", err)
+	}
+	return user
+}
+
+func TestOldTokenRejectedAfterPasswordChange(t *testing.T) {
+	ts := newTestServices(t)
+	user := ts.registerUser(t, "jan@example.com")
+
+	refreshToken, err := ts.auth.GenerateRefreshToken(user, "test-agent", "1.2.3.4")
+	if err != nil {
+		t.Fatalf("GenerateRefreshToken: %v", err)
+	}
+	if version, err := ts.auth.CurrentTokenVersion(context.Background(), user.Id); err != nil || version != user.TokenVersion {
+		t.Fatalf("expected token version %d before the change, got %d (err %v)", user.TokenVersion, version, err)
+	}
+
+	if err := ts.auth.ChangePassword(user.Id, testPassword, "a brand new password"); err != nil {
+		t.Fatalf("ChangePassword: %v", err)
+	}
+
+	// A request that read the user before the change writes it back afterwards
+	if err := ts.cache.SetUserInCache(user); err != nil {
+		t.Fatalf("SetUserInCache: %v", err)
+	}
+	if err := ts.cache.CacheTokenVersionIfAbsent(user.Id, user.TokenVersion); err != nil {
+		t.Fatalf("CacheTokenVersionIfAbsent: %v", err)
+	}
+
+	version, err := ts.auth.CurrentTokenVersion(context.Background(), user.Id)
+	if err != nil {
+		t.Fatalf("CurrentTokenVersion: %v", err)
+	}
+	if version == user.TokenVersion {
+		t.Fatalf("expected the token version to move past %d after the password change", user.TokenVersion)
+	}
+
+	// With the cache emptied the version comes from the database
+	ts.cache.client.FlushAll(redisCtx)
+	if fromDB, err := ts.auth.CurrentTokenVersion(context.Background(), user.Id); err != nil || fromDB != version {
+		t.Fatalf("expected token version %d from the database, got %d (err %v)", version, fromDB, err)
+	}
+
+	if _, err := ts.auth.RefreshAccessToken(refreshToken, "test-agent", "1.2.3.4"); !errors.Is(err, lib.ErrInvalidToken) {
+		t.Fatalf("expected the old refresh token to be rejected, got %v", err)
+	}
+}
+
+func TestRevokeAllSessionsRejectsEveryRefreshToken(t *testing.T) {
+	ts := newTestServices(t)
+	user := ts.registerUser(t, "jan@example.com")
+
+	// Two logins, each with its own session
+	var refreshTokens []string
+	for _, agent := range []string{"phone", "laptop"} {
+		loggedIn, err := ts.auth.Login(&structs.AuthRequest{Email: "jan@example.com", Password: testPassword})
+		if err != nil {
+			t.Fatalf("Login: %v", err)
+		}
+		token, err := ts.auth.GenerateRefreshToken(loggedIn, agent, "1.2.3.4")
+		if err != nil {
+			t.Fatalf("GenerateRefreshToken: %v", err)
+		}
+		refreshTokens = append(refreshTokens, token)
+	}
+
+	revoked, err := ts.auth.RevokeAllSessions(user.Id)
+	if err != nil {
+		t.Fatalf("RevokeAllSessions: %v", err)
+	}
+	if revoked != 2 {
+		t.Fatalf("expected 2 sessions revoked, got %d", revoked)
+	}
+
+	for i, token := range refreshTokens {
+		if _, err := ts.auth.RefreshAccessToken(token, "phone", "1.2.3.4"); !errors.Is(err, lib.ErrInvalidToken) {
+			t.Fatalf("expected refresh token %d to be rejected, got %v", i+1, err)
+		}
+	}
+
+	// Logging in again starts a working session
+	loggedIn, err := ts.auth.Login(&structs.AuthRequest{Email: "jan@example.com", Password: testPassword})
+	if err != nil {
+		t.Fatalf("Login: %v", err)
+	}
+	if loggedIn.TokenVersion == user.TokenVersion {
+		t.Fatalf("expected the token version to move past %d", user.TokenVersion)
+	}
+	fresh, err := ts.auth.GenerateRefreshToken(loggedIn, "phone", "1.2.3.4")
+	if err != nil {
+		t.Fatalf("GenerateRefreshToken: %v", err)
+	}
+	if _, err := ts.auth.RefreshAccessToken(fresh, "phone", "1.2.3.4"); err != nil {
+		t.Fatalf("expected a new session to refresh, got %v", err)
+	}
+}
+
+// newScopedAuthService returns an auth service issuing tokens for issuer and audience, without a database
+func newScopedAuthService(issuer, audience string) *AuthService {
+	cfg := *testutil.Config()
+	auth := *cfg.Auth
+	auth.TokenIssuer = issuer
+	auth.TokenAudience = audience
+	cfg.Auth = &auth
+	return NewAuthService(&cfg, testutil.Logger(), nil, nil)
+}
+
+func TestAccessTokenIssuerAndAudience(t *testing.T) {
+	user := &tables.User{Id: uuid.New(), Username: "Jan", Role: "user"}
+	ours := newScopedAuthService("https://api.mamabloemetjes.nl", "mamabloemetjes-web")
+
+	token, err := ours.GenerateAccessToken(user)
+	if err != nil {
+		t.Fatalf("GenerateAccessToken: %v", err)
+	}
+
+	tests := []struct {
+		name     string
+		issuer   string
+		audience string
+		valid    bool
+	}{
+		{"matching issuer and audience", "https://api.mamabloemetjes.nl", "mamabloemetjes-web", true},
+		{"other issuer", "https://staging.mamabloemetjes.nl", "mamabloemetjes-web", false},
+		{"other audience", "https://api.mamabloemetjes.nl", "mamabloemetjes-admin", false},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			verifier := newScopedAuthService(tt.issuer, tt.audience)
+			cfg := verifier.cfg.Auth
+			claims, err := lib.ParseToken(token, true, cfg.AccessTokenSecret, cfg.TokenLeeway, cfg.TokenIssuer, cfg.TokenAudience)
+			if tt.valid && (err != nil || claims.Sub != user.Id) {
+				t.Fatalf("expected the token to be accepted, got %v", err)
+			}
+			if !tt.valid && err == nil {
+				t.Fatal("expected the token to be rejected")
+			}
+		})
+	}
+}
+
+func TestRegisterConflictReturnsNoUser(t *testing.T) {
+	ts := newTestServices(t)
+	first := ts.registerUser(t, "jan@example.com")
+
+	user, err := ts.auth.Register(&structs.RegisterRequest{Username: "Jan de Vries", Email: "Jan@Example.com", Password: testPassword})
+	if user != nil {
+		t.Fatalf("expected no user from a failed registration, got %+v", user)
+	}
+	if !lib.IsUniqueViolation(err) || !errors.Is(err, lib.ErrConflict) {
+		t.Fatalf("expected a unique violation, got %v", err)
+	}
+
+	count, err := ts.db.NewSelect().Model((*tables.User)(nil)).Where("email = ?", "jan@example.com").Count(context.Background())
+	if err != nil || count != 1 {
+		t.Fatalf("expected only the first user to be stored, got %d (err %v)", count, err)
+	}
+	if stored, err := ts.auth.GetUserByID(context.Background(), first.Id); err != nil || stored.Username != "Test User" {
+		t.Fatalf("expected the first user to be left as it was, got %+v (err %v)", stored, err)
+	}
+}
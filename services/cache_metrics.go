@@ -0,0 +1,51 @@
+package services
+
+import (
+	"strings"
+
+	"github.com/prometheus/client_golang/prometheus"
+)
+
+// CacheLookups counts cache reads by category and result (hit, miss, error) to help tune TTLs
+var CacheLookups = prometheus.NewCounterVec(
+	prometheus.CounterOpts{
+		Namespace: "api",
+		Subsystem: "cache",
+		Name:      "lookups_total",
+		Help:      "Cache lookups by category and result",
+	},
+	[]string{"category", "result"},
+)
+
+// cacheCategories maps key prefixes to metric categories; the first match wins, so longer prefixes come first
+var cacheCategories = []struct {
+	prefix   string
+	category string
+}{
+	{"product:id:", "product-by-id"},
+	{"product:sku:", "product-by-sku"},
+	{"products:active:", "active-list"},
+	{"products:count:", "count"},
+	{"products:suggest:", "suggest"},
+	{"user:", "user"},
+}
+
+// cacheCategory returns the metric category for a key, or "" for keys that are not cached data (rate limits, locks)
+func cacheCategory(key string) string {
+	for _, c := range cacheCategories {
+		if strings.HasPrefix(key, c.prefix) {
+			if c.category == "user" && strings.HasSuffix(key, ":orders") {
+				return "user-orders"
+			}
+			return c.category
+		}
+	}
+	return ""
+}
+
+// recordCacheLookup increments the lookup counter for key
+func recordCacheLookup(key, result string) {
+	if category := cacheCategory(key); category != "" {
+		CacheLookups.WithLabelValues(category, result).Inc()
+	}
+}
@@ -0,0 +1,56 @@
+package services
+
+import (
+	"mamabloemetjes_server/structs/tables"
+	"testing"
+
+	"github.com/google/uuid"
+	promtest "github.com/prometheus/client_golang/prometheus/testutil"
+)
+
+func TestCacheLookupsCountHitsAndMisses(t *testing.T) {
+	cs := newTestCacheService(t)
+	product := &tables.Product{ID: uuid.New(), Name: "Rozenboeket", SKU: "SKU-METRICS", Price: 2500}
+
+	lookups := func(category, result string) float64 {
+		return promtest.ToFloat64(CacheLookups.WithLabelValues(category, result))
+	}
+	hits, misses := lookups("product-by-id", "hit"), lookups("product-by-id", "miss")
+	userMisses := lookups("user", "miss")
+
+	if cached, err := cs.GetProductByID(product.ID, false); err != nil || cached != nil {
+		t.Fatalf("expected a miss, got %v (err %v)", cached, err)
+	}
+	if err := cs.SetProductByID(product, false); err != nil {
+		t.Fatalf("SetProductByID: %v", err)
+	}
+	if cached, err := cs.GetProductByID(product.ID, false); err != nil || cached == nil {
+		t.Fatalf("expected a hit, got %v (err %v)", cached, err)
+	}
+
+	if got := lookups("product-by-id", "miss") - misses; got != 1 {
+		t.Fatalf("expected one product-by-id miss, got %v", got)
+	}
+	if got := lookups("product-by-id", "hit") - hits; got != 1 {
+		t.Fatalf("expected one product-by-id hit, got %v", got)
+	}
+	if got := lookups("user", "miss") - userMisses; got != 0 {
+		t.Fatalf("expected the user category to be left alone, got %v misses", got)
+	}
+}
+
+func TestCacheCategory(t *testing.T) {
+	tests := map[string]string{
+		"product:id:123:false":  "product-by-id",
+		"products:active:1:20":  "active-list",
+		"products:count:active": "count",
+		"user:123":              "user",
+		"user:123:orders":       "user-orders",
+		"ratelimit:1.2.3.4":     "",
+	}
+	for key, expected := range tests {
+		if got := cacheCategory(key); got != expected {
+			t.Errorf("expected category %q for %s, got %q", expected, key, got)
+		}
+	}
+}
@@ -6,6 +6,7 @@ import (
 	"encoding/json"
 	"fmt"
 	"mamabloemetjes_server/config"
+	"mamabloemetjes_server/database"
 	"mamabloemetjes_server/structs"
 	"mamabloemetjes_server/structs/tables"
 	"strconv"
@@ -186,9 +187,16 @@ func (cs *CacheService) Get(key string) (string, error) {
 	}, 3)
 
 	if err != nil {
+		recordCacheLookup(key, "error")
 		return "", err
 	}
 
+	if result == "" {
+		recordCacheLookup(key, "miss")
+	} else {
+		recordCacheLookup(key, "hit")
+	}
+
 	return result, resultErr
 }
 
@@ -226,6 +234,61 @@ func (cs *CacheService) BlacklistToken(jti uuid.UUID, exp time.Time) error {
 	return cs.Set(key, "true", ttl)
 }
 
+// ConsumeToken atomically blacklists a token's jti, returning false when it was already blacklisted
+// Used for single-use tokens, so two concurrent requests cannot both redeem the same token
+func (cs *CacheService) ConsumeToken(jti uuid.UUID, exp time.Time) (bool, error) {
+	ttl := cs.config.Auth.BlacklistCacheTTL
+	if exp.After(time.Now()) {
+		ttl = time.Until(exp)
+	}
+
+	key := fmt.Sprintf("blacklist:%s", jti)
+	var consumed bool
+	err := cs.withRetry(func() error {
+		ok, err := cs.client.SetNX(redisCtx, key, "true", ttl).Result()
+		if err != nil {
+			return err
+		}
+		consumed = ok
+		return nil
+	}, 3)
+
+	return consumed, err
+}
+
+// VerificationEmailWindow is the minimum time between two verification emails to the same user
+const VerificationEmailWindow = 2 * time.Minute
+
+// ClaimVerificationEmail atomically claims the right to send a verification email to a user, returning
+// false when one was already sent within VerificationEmailWindow
+func (cs *CacheService) ClaimVerificationEmail(userID uuid.UUID) (bool, error) {
+	return cs.claimEmail(fmt.Sprintf("verification:sent:%s", userID), VerificationEmailWindow)
+}
+
+// PasswordResetEmailWindow is the minimum time between two password reset emails to the same user
+const PasswordResetEmailWindow = 2 * time.Minute
+
+// ClaimPasswordResetEmail atomically claims the right to send a password reset email to a user, returning
+// false when one was already sent within PasswordResetEmailWindow
+func (cs *CacheService) ClaimPasswordResetEmail(userID uuid.UUID) (bool, error) {
+	return cs.claimEmail(fmt.Sprintf("password-reset:sent:%s", userID), PasswordResetEmailWindow)
+}
+
+// claimEmail sets key for window unless it is already set, reporting whether it was
+func (cs *CacheService) claimEmail(key string, window time.Duration) (bool, error) {
+	var claimed bool
+	err := cs.withRetry(func() error {
+		ok, err := cs.client.SetNX(redisCtx, key, "true", window).Result()
+		if err != nil {
+			return err
+		}
+		claimed = ok
+		return nil
+	}, 3)
+
+	return claimed, err
+}
+
 // IsTokenBlacklisted checks if a JTI exists in Redis with retry logic
 func (cs *CacheService) IsTokenBlacklisted(jti uuid.UUID) (bool, error) {
 	key := fmt.Sprintf("blacklist:%s", jti.String())
@@ -279,53 +342,176 @@ func (cs *CacheService) DeleteUserFromCache(userID uuid.UUID) error {
 	return cs.Delete(key)
 }
 
-// SetRateLimit sets a rate limit counter for an IP/endpoint combination
-func (cs *CacheService) SetRateLimit(ip, endpoint string, count int, ttl time.Duration) error {
-	key := fmt.Sprintf("ratelimit:%s:%s", ip, endpoint)
-	return cs.Set(key, count, ttl)
+// tokenVersionKey returns the key caching a user's token version, kept apart from the cached user so that
+// repopulating the user cache from a stale read can never roll the version back
+func tokenVersionKey(userID uuid.UUID) string {
+	return fmt.Sprintf("user:%s:token_version", userID.String())
 }
 
-// GetRateLimit retrieves the current rate limit count for an IP/endpoint
-func (cs *CacheService) GetRateLimit(ip, endpoint string) (int, error) {
-	key := fmt.Sprintf("ratelimit:%s:%s", ip, endpoint)
-	val, err := cs.Get(key)
-	if err != nil {
-		return 0, err
+// GetTokenVersion returns a user's cached token version and whether it was cached
+func (cs *CacheService) GetTokenVersion(userID uuid.UUID) (int, bool, error) {
+	val, err := cs.Get(tokenVersionKey(userID))
+	if err != nil || val == "" {
+		return 0, false, err
 	}
 
-	if val == "" {
-		return 0, nil
-	}
-
-	count, err := strconv.Atoi(val)
+	version, err := strconv.Atoi(val)
 	if err != nil {
-		return 0, fmt.Errorf("invalid rate limit value: %w", err)
+		return 0, false, err
 	}
+	return version, true, nil
+}
 
-	return count, nil
+// SetTokenVersion caches a user's token version, overwriting what is cached. Only the code bumping the
+// version calls it; readers filling a miss use CacheTokenVersionIfAbsent
+func (cs *CacheService) SetTokenVersion(userID uuid.UUID, version int) error {
+	return cs.Set(tokenVersionKey(userID), version, cs.config.Auth.CacheUserTTL)
+}
+
+// CacheTokenVersionIfAbsent caches a token version read from the database unless a version is cached already.
+// A bump that lands between the read and this call has cached the new version, which then stays
+func (cs *CacheService) CacheTokenVersionIfAbsent(userID uuid.UUID, version int) error {
+	return cs.withRetry(func() error {
+		return cs.client.SetNX(redisCtx, tokenVersionKey(userID), version, cs.config.Auth.CacheUserTTL).Err()
+	}, 3)
+}
+
+// GetUserOrderSummary retrieves a user's cached order summary
+func (cs *CacheService) GetUserOrderSummary(userID uuid.UUID) (*UserOrderSummary, error) {
+	key := fmt.Sprintf("user:%s:orders", userID.String())
+	return getJSON[UserOrderSummary](cs, key)
+}
+
+// SetUserOrderSummary caches a user's order summary
+func (cs *CacheService) SetUserOrderSummary(userID uuid.UUID, summary *UserOrderSummary) error {
+	key := fmt.Sprintf("user:%s:orders", userID.String())
+	return setJSON(cs, key, summary, cs.config.Cache.ProfileTTL)
+}
+
+// InvalidateUserOrderSummary removes a user's cached order summary
+func (cs *CacheService) InvalidateUserOrderSummary(userID uuid.UUID) error {
+	key := fmt.Sprintf("user:%s:orders", userID.String())
+	return cs.Delete(key)
+}
+
+// GetOrderStatus retrieves the cached status of an order as seen by its owner
+func (cs *CacheService) GetOrderStatus(userID, orderID uuid.UUID) (*OrderStatusSnapshot, error) {
+	key := fmt.Sprintf("user:%s:order:%s:status", userID.String(), orderID.String())
+	return getJSON[OrderStatusSnapshot](cs, key)
+}
+
+// SetOrderStatus caches the status of an order for its owner
+// The key includes the user, so only owners who passed the ownership check are ever served from it
+func (cs *CacheService) SetOrderStatus(userID uuid.UUID, status *OrderStatusSnapshot) error {
+	key := fmt.Sprintf("user:%s:order:%s:status", userID.String(), status.OrderId.String())
+	return setJSON(cs, key, status, cs.config.Cache.OrderStatusTTL)
 }
 
-// IncrementRateLimit atomically increments a rate limit counter
-func (cs *CacheService) IncrementRateLimit(ip, endpoint string, ttl time.Duration) (int, error) {
-	key := fmt.Sprintf("ratelimit:%s:%s", ip, endpoint)
+// rateLimitKey returns the key of the sorted set logging the requests of an IP/endpoint combination
+func rateLimitKey(ip, endpoint string) string {
+	return fmt.Sprintf("ratelimit:window:%s:%s", ip, endpoint)
+}
+
+// slidingWindowScript drops the entries that fell out of the window from a sorted set scored by Redis server
+// time in microseconds, then logs the request only if the window still has room, so rejected requests never
+// grow the log and a client is let through again once its earlier requests age out. It returns the number of
+// requests in the window including this one, which is limit+1 for a rejected request. A member that is already
+// logged is not added again, so a retry of a script that ran but timed out is not counted twice
+// KEYS[1] is the log, ARGV[1] the window in microseconds, ARGV[2] a unique member, ARGV[3] the TTL in milliseconds,
+// ARGV[4] the limit
+var slidingWindowScript = redis.NewScript(`
+local now = redis.call("TIME")
+local nowUs = tonumber(now[1]) * 1000000 + tonumber(now[2])
+redis.call("ZREMRANGEBYSCORE", KEYS[1], "-inf", nowUs - tonumber(ARGV[1]))
+local count = redis.call("ZCARD", KEYS[1])
+if redis.call("ZSCORE", KEYS[1], ARGV[2]) then
+	return count
+end
+if count >= tonumber(ARGV[4]) then
+	return count + 1
+end
+redis.call("ZADD", KEYS[1], nowUs, ARGV[2])
+redis.call("PEXPIRE", KEYS[1], ARGV[3])
+return count + 1
+`)
+
+// slidingWindowResetScript returns the microseconds until the oldest entry of the window ages out, 0 for an empty log
+// KEYS[1] is the log, ARGV[1] the window in microseconds
+var slidingWindowResetScript = redis.NewScript(`
+local now = redis.call("TIME")
+local nowUs = tonumber(now[1]) * 1000000 + tonumber(now[2])
+local oldest = redis.call("ZRANGE", KEYS[1], 0, 0, "WITHSCORES")
+if #oldest == 0 then
+	return 0
+end
+return math.max(0, tonumber(oldest[2]) + tonumber(ARGV[1]) - nowUs)
+`)
+
+// GetRateLimit retrieves the number of requests logged for an IP/endpoint
+func (cs *CacheService) GetRateLimit(ip, endpoint string) (int, error) {
+	var count int64
+	err := cs.withRetry(func() error {
+		val, err := cs.client.ZCard(redisCtx, rateLimitKey(ip, endpoint)).Result()
+		if err != nil {
+			return err
+		}
+		count = val
+		return nil
+	}, 3)
+
+	return int(count), err
+}
+
+// IncrementRateLimit logs a request if fewer than limit requests were made within the sliding window, and returns
+// the number of requests in the window including this one; more than limit means the request was rejected and not
+// logged. Unlike a fixed window counter it cannot be burst at a window boundary: the count always covers the window
+// preceding the request
+func (cs *CacheService) IncrementRateLimit(ip, endpoint string, limit int, window time.Duration) (int, error) {
+	// Generated once for every attempt, so the script can recognize a request it already logged
+	return cs.ReserveRateLimit(ip, endpoint, uuid.NewString(), limit, window)
+}
+
+// ReserveRateLimit is IncrementRateLimit with the caller's member, so a reserved slot can be given back with
+// ReleaseRateLimit. The check and the reservation are one script, so concurrent callers cannot pass the limit
+func (cs *CacheService) ReserveRateLimit(ip, endpoint, member string, limit int, window time.Duration) (int, error) {
+	key := rateLimitKey(ip, endpoint)
 
 	var result int64
 	err := cs.withRetry(func() error {
-		val, err := cs.client.Incr(redisCtx, key).Result()
+		val, err := slidingWindowScript.Run(redisCtx, cs.client, []string{key},
+			window.Microseconds(), member, window.Milliseconds(), limit).Int64()
 		if err != nil {
 			return err
 		}
 		result = val
+		return nil
+	}, 3)
 
-		// Set expiration only on first increment
-		if val == 1 {
-			return cs.client.Expire(redisCtx, key, ttl).Err()
-		}
+	return int(result), err
+}
+
+// ReleaseRateLimit removes a slot taken with ReserveRateLimit, for a request that did not go through after all
+func (cs *CacheService) ReleaseRateLimit(ip, endpoint, member string) error {
+	return cs.withRetry(func() error {
+		return cs.client.ZRem(redisCtx, rateLimitKey(ip, endpoint), member).Err()
+	}, 3)
+}
 
+// RateLimitResetIn returns how long until the oldest request logged for an IP/endpoint ages out of the window,
+// which is when a client at the limit may try again
+func (cs *CacheService) RateLimitResetIn(ip, endpoint string, window time.Duration) (time.Duration, error) {
+	var micros int64
+	err := cs.withRetry(func() error {
+		val, err := slidingWindowResetScript.Run(redisCtx, cs.client, []string{rateLimitKey(ip, endpoint)},
+			window.Microseconds()).Int64()
+		if err != nil {
+			return err
+		}
+		micros = val
 		return nil
 	}, 3)
 
-	return int(result), err
+	return time.Duration(micros) * time.Microsecond, err
 }
 
 // Ping tests the Redis connection
@@ -351,60 +537,155 @@ func (cs *CacheService) GetConnectionStats() map[string]any {
 
 // GetRateLimitStatus returns current rate limit information for debugging
 func (cs *CacheService) GetRateLimitStatus(ip, endpoint string) (map[string]any, error) {
-	key := fmt.Sprintf("ratelimit:%s:%s", ip, endpoint)
+	key := rateLimitKey(ip, endpoint)
 
 	var result map[string]any
 
 	err := cs.withRetry(func() error {
-		// Get current count
-		val, err := cs.client.Get(redisCtx, key).Result()
-		if err == redis.Nil {
-			result = map[string]any{
-				"count": 0,
-				"ttl":   0,
-			}
-			return nil
-		}
-		if err != nil {
+		pipe := cs.client.Pipeline()
+		count := pipe.ZCard(redisCtx, key)
+		ttl := pipe.TTL(redisCtx, key)
+		if _, err := pipe.Exec(redisCtx); err != nil {
 			return err
 		}
 
-		// Get TTL
-		ttl, err := cs.client.TTL(redisCtx, key).Result()
-		if err != nil {
-			return err
+		result = map[string]any{
+			"count": int(count.Val()),
+			"ttl":   max(0, int(ttl.Val().Seconds())),
 		}
+		return nil
+	}, 3)
 
-		// Parse count
-		count, err := strconv.Atoi(val)
-		if err != nil {
-			return fmt.Errorf("invalid rate limit value: %w", err)
-		}
+	return result, err
+}
 
-		result = map[string]any{
-			"count": count,
-			"ttl":   int(ttl.Seconds()),
+// ============================================================================
+// Distributed Lock Methods
+// ============================================================================
+
+// releaseLockScript deletes the lock only if it is still held by the caller's token
+var releaseLockScript = redis.NewScript(`
+if redis.call("GET", KEYS[1]) == ARGV[1] then
+	return redis.call("DEL", KEYS[1])
+end
+return 0
+`)
+
+// AcquireLock tries to take a named lock for ttl, returning the owner token when acquired
+func (cs *CacheService) AcquireLock(name string, ttl time.Duration) (string, bool, error) {
+	key := fmt.Sprintf("lock:%s", name)
+	token := uuid.New().String()
+
+	var acquired bool
+	err := cs.withRetry(func() error {
+		ok, err := cs.client.SetNX(redisCtx, key, token, ttl).Result()
+		if err != nil {
+			return err
 		}
+		acquired = ok
 		return nil
 	}, 3)
+	if err != nil {
+		return "", false, err
+	}
 
-	return result, err
+	return token, acquired, nil
+}
+
+// ReleaseLock releases a named lock if it is still owned by token
+func (cs *CacheService) ReleaseLock(name, token string) error {
+	key := fmt.Sprintf("lock:%s", name)
+	return cs.withRetry(func() error {
+		return releaseLockScript.Run(redisCtx, cs.client, []string{key}, token).Err()
+	}, 3)
 }
 
 // ============================================================================
 // Product Caching Methods
 // ============================================================================
 
-// GetActiveProductsList retrieves cached active products list
-func (cs *CacheService) GetActiveProductsList(page, pageSize int, includeImages bool, productType string) ([]tables.Product, error) {
+// Tags are Redis sets holding the keys of cached product pages, products and counts, so they can be invalidated
+// without scanning the keyspace
+const (
+	productListTag  = "tag:products"
+	productSKUTag   = "tag:products:sku"
+	productCountTag = "tag:products:count"
+)
+
+// activeProductsListKey returns the cache key of a page of active products; the pagination metadata of the
+// same page is stored next to it under the key with a ":meta" suffix
+func activeProductsListKey(page, pageSize int, includeImages bool, productType string) string {
 	if productType == "" {
 		productType = "all"
 	}
-	key := fmt.Sprintf("products:active:page:%d:size:%d:type:%s:images:%v", page, pageSize, productType, includeImages)
+	return fmt.Sprintf("products:active:page:%d:size:%d:type:%s:images:%v", page, pageSize, productType, includeImages)
+}
+
+// GetActiveProductsList retrieves a cached page of active products with its pagination metadata
+// It reports a miss (nil, nil) unless both the products and the metadata are cached, so a total is never guessed
+func (cs *CacheService) GetActiveProductsList(page, pageSize int, includeImages bool, productType string) ([]tables.Product, *database.Pagination, error) {
+	key := activeProductsListKey(page, pageSize, includeImages, productType)
 
 	products, err := getJSON[[]tables.Product](cs, key)
 	if err != nil {
 		cs.logger.Warn("Failed to get active products from cache", "error", err, "key", key)
+		return nil, nil, err
+	}
+	if products == nil {
+		return nil, nil, nil
+	}
+
+	pagination, err := getJSON[database.Pagination](cs, key+":meta")
+	if err != nil {
+		cs.logger.Warn("Failed to get active products pagination from cache", "error", err, "key", key)
+		return nil, nil, err
+	}
+	if pagination == nil {
+		return nil, nil, nil
+	}
+
+	return *products, pagination, nil
+}
+
+// SetActiveProductsList caches a page of active products together with its pagination metadata
+func (cs *CacheService) SetActiveProductsList(page, pageSize int, includeImages bool, products []tables.Product, pagination database.Pagination, productType string) error {
+	key := activeProductsListKey(page, pageSize, includeImages, productType)
+	ttl := cs.getProductListTTL()
+
+	productsData, err := json.Marshal(products)
+	if err != nil {
+		return err
+	}
+	paginationData, err := json.Marshal(pagination)
+	if err != nil {
+		return err
+	}
+
+	// The page, its metadata and their tag membership are written together; the tag outlives its members
+	// by at most one TTL, and deleting an already expired member is harmless
+	return cs.withRetry(func() error {
+		pipe := cs.client.TxPipeline()
+		pipe.Set(redisCtx, key+":meta", paginationData, ttl)
+		pipe.Set(redisCtx, key, productsData, ttl)
+		pipe.SAdd(redisCtx, productListTag, key, key+":meta")
+		pipe.Expire(redisCtx, productListTag, ttl)
+		_, err := pipe.Exec(redisCtx)
+		return err
+	}, 3)
+}
+
+// relatedProductsKey lives under products:active: so the pattern fallback of InvalidateActiveProductsListCache covers it
+func relatedProductsKey(productID uuid.UUID, limit int) string {
+	return fmt.Sprintf("products:active:related:%s:limit:%d", productID.String(), limit)
+}
+
+// GetRelatedProducts retrieves the cached related products of a product
+func (cs *CacheService) GetRelatedProducts(productID uuid.UUID, limit int) ([]tables.Product, error) {
+	key := relatedProductsKey(productID, limit)
+
+	products, err := getJSON[[]tables.Product](cs, key)
+	if err != nil {
+		cs.logger.Warn("Failed to get related products from cache", "error", err, "key", key)
 		return nil, err
 	}
 
@@ -415,46 +696,163 @@ func (cs *CacheService) GetActiveProductsList(page, pageSize int, includeImages
 	return *products, nil
 }
 
-// SetActiveProductsList caches active products list
-func (cs *CacheService) SetActiveProductsList(page, pageSize int, includeImages bool, products []tables.Product, productType string) error {
-	if productType == "" {
-		productType = "all"
-	}
-	key := fmt.Sprintf("products:active:page:%d:size:%d:images:%v", page, pageSize, includeImages)
+// SetRelatedProducts caches the related products of a product under the product list tag,
+// so any product change that clears the active lists also drops them
+func (cs *CacheService) SetRelatedProducts(productID uuid.UUID, limit int, products []tables.Product) error {
+	key := relatedProductsKey(productID, limit)
 	ttl := cs.getProductListTTL()
 
-	return setJSON(cs, key, products, ttl)
+	data, err := json.Marshal(products)
+	if err != nil {
+		return err
+	}
+
+	return cs.withRetry(func() error {
+		pipe := cs.client.TxPipeline()
+		pipe.Set(redisCtx, key, data, ttl)
+		pipe.SAdd(redisCtx, productListTag, key)
+		pipe.Expire(redisCtx, productListTag, ttl)
+		_, err := pipe.Exec(redisCtx)
+		return err
+	}, 3)
 }
 
+// InvalidateActiveProductsListCache removes every cached active products page
+// It deletes the members of the product list tag in two round trips (read and drop the tag, then delete its
+// members), where DeletePattern needs a SCAN call per 100 keys of the whole keyspace plus a DEL per batch
+// with matches. When the tag cannot be used it falls back to DeletePattern
 func (cs *CacheService) InvalidateActiveProductsListCache() error {
-	return cs.DeletePattern("products:active:*")
+	return cs.invalidateTagOrPattern(productListTag, "products:active:*")
 }
 
-// GetProductBySKU retrieves a cached product by SKU
-func (cs *CacheService) GetProductBySKU(sku string) (*tables.Product, error) {
-	key := fmt.Sprintf("product:sku:%s", sku)
+// InvalidateProductsBySKUCache removes every product cached by SetProducts
+// All of them go at once: a product update does not know the SKU the product was cached under
+func (cs *CacheService) InvalidateProductsBySKUCache() error {
+	return cs.invalidateTagOrPattern(productSKUTag, "product:sku:*")
+}
 
-	product, err := getJSON[tables.Product](cs, key)
+// InvalidateProductCountCache removes every cached product count
+func (cs *CacheService) InvalidateProductCountCache() error {
+	return cs.invalidateTagOrPattern(productCountTag, "products:count:*")
+}
+
+// invalidateTagOrPattern invalidates a tag, falling back to deleting the keys matching pattern
+func (cs *CacheService) invalidateTagOrPattern(tag, pattern string) error {
+	if err := cs.invalidateTag(tag); err != nil {
+		cs.logger.Warn("Failed to invalidate cache tag, falling back to pattern delete", "tag", tag, "error", err)
+		return cs.DeletePattern(pattern)
+	}
+	return nil
+}
+
+// invalidateTag deletes every key recorded in the tag set and the set itself
+// The set is read and dropped atomically, so keys tagged while the members are being deleted stay tracked
+func (cs *CacheService) invalidateTag(tag string) error {
+	var keys []string
+	err := cs.withRetry(func() error {
+		pipe := cs.client.TxPipeline()
+		members := pipe.SMembers(redisCtx, tag)
+		pipe.Del(redisCtx, tag)
+		if _, err := pipe.Exec(redisCtx); err != nil {
+			return err
+		}
+		keys = members.Val()
+		return nil
+	}, 3)
+	if err != nil || len(keys) == 0 {
+		return err
+	}
+
+	return cs.withRetry(func() error {
+		return cs.client.Del(redisCtx, keys...).Err()
+	}, 3)
+}
+
+// productSKUKey returns the cache key of a product cached by SetProducts
+func productSKUKey(sku string, includeImages bool) string {
+	return fmt.Sprintf("product:sku:%s:images:%v", sku, includeImages)
+}
+
+// GetProducts retrieves cached products by SKU in a single MGET
+// It returns the cached products keyed by SKU and the SKUs that missed; an entry that cannot be decoded counts as a miss
+func (cs *CacheService) GetProducts(skus []string, includeImages bool) (map[string]*tables.Product, []string, error) {
+	if len(skus) == 0 {
+		return map[string]*tables.Product{}, nil, nil
+	}
+
+	keys := make([]string, len(skus))
+	for i, sku := range skus {
+		keys[i] = productSKUKey(sku, includeImages)
+	}
+
+	var values []any
+	err := cs.withRetry(func() error {
+		result, err := cs.client.MGet(redisCtx, keys...).Result()
+		if err != nil {
+			return err
+		}
+		values = result
+		return nil
+	}, 3)
 	if err != nil {
-		cs.logger.Warn("Failed to get product from cache", "error", err, "sku", sku)
-		return nil, err
+		cs.logger.Warn("Failed to get products from cache", "error", err, "skus", len(skus))
+		return nil, nil, err
 	}
 
-	if product == nil {
-		return nil, nil
+	products := make(map[string]*tables.Product, len(skus))
+	var missing []string
+	for i, value := range values {
+		sku := skus[i]
+		if _, seen := products[sku]; seen {
+			continue
+		}
+
+		data, ok := value.(string)
+		if !ok {
+			missing = append(missing, sku)
+			continue
+		}
+
+		var product tables.Product
+		if err := json.Unmarshal([]byte(data), &product); err != nil {
+			cs.logger.Warn("Failed to decode cached product", "error", err, "sku", sku)
+			missing = append(missing, sku)
+			continue
+		}
+		products[sku] = &product
 	}
 
-	return product, nil
+	return products, missing, nil
 }
 
-// SetProductBySKU caches a product by SKU
-func (cs *CacheService) SetProductBySKU(product *tables.Product) error {
-	key := fmt.Sprintf("product:sku:%s", product.SKU)
-	ttl := cs.getProductListTTL()
+// SetProducts caches products by SKU in a single pipeline and tags them for invalidation
+func (cs *CacheService) SetProducts(products []tables.Product, includeImages bool) error {
+	if len(products) == 0 {
+		return nil
+	}
 
-	cs.logger.Debug("Caching product by SKU", "sku", product.SKU, "ttl", ttl)
+	ttl := cs.getProductListTTL()
+	keys := make([]any, len(products))
+	values := make([][]byte, len(products))
+	for i := range products {
+		data, err := json.Marshal(&products[i])
+		if err != nil {
+			return err
+		}
+		keys[i] = productSKUKey(products[i].SKU, includeImages)
+		values[i] = data
+	}
 
-	return setJSON(cs, key, product, ttl)
+	return cs.withRetry(func() error {
+		pipe := cs.client.TxPipeline()
+		for i, key := range keys {
+			pipe.Set(redisCtx, key.(string), values[i], ttl)
+		}
+		pipe.SAdd(redisCtx, productSKUTag, keys...)
+		pipe.Expire(redisCtx, productSKUTag, ttl)
+		_, err := pipe.Exec(redisCtx)
+		return err
+	}, 3)
 }
 
 // GetProductByID retrieves a cached product by ID
@@ -482,6 +880,9 @@ func (cs *CacheService) SetProductByID(product *tables.Product, includeImages bo
 	return setJSON(cs, key, product, ttl)
 }
 
+// ActiveProductCountKey is the count-cache filter key of the active product count (products:count:active)
+const ActiveProductCountKey = "active"
+
 // GetProductCount retrieves cached product count
 func (cs *CacheService) GetProductCount(filterKey string) (*int, error) {
 	key := fmt.Sprintf("products:count:%s", filterKey)
@@ -504,7 +905,105 @@ func (cs *CacheService) SetProductCount(filterKey string, count int) error {
 	key := fmt.Sprintf("products:count:%s", filterKey)
 	ttl := cs.getProductCountTTL()
 
-	return setJSON(cs, key, count, ttl)
+	data, err := json.Marshal(count)
+	if err != nil {
+		return err
+	}
+
+	return cs.withRetry(func() error {
+		pipe := cs.client.TxPipeline()
+		pipe.Set(redisCtx, key, data, ttl)
+		pipe.SAdd(redisCtx, productCountTag, key)
+		pipe.Expire(redisCtx, productCountTag, ttl)
+		_, err := pipe.Exec(redisCtx)
+		return err
+	}, 3)
+}
+
+// GetProductSuggestions retrieves cached suggestions for a search prefix
+func (cs *CacheService) GetProductSuggestions(prefix string) ([]tables.Product, error) {
+	key := fmt.Sprintf("products:suggest:%s", prefix)
+
+	products, err := getJSON[[]tables.Product](cs, key)
+	if err != nil {
+		cs.logger.Warn("Failed to get product suggestions from cache", "error", err, "key", key)
+		return nil, err
+	}
+
+	if products == nil {
+		return nil, nil
+	}
+
+	return *products, nil
+}
+
+// SetProductSuggestions caches suggestions for a search prefix
+func (cs *CacheService) SetProductSuggestions(prefix string, products []tables.Product) error {
+	key := fmt.Sprintf("products:suggest:%s", prefix)
+
+	return setJSON(cs, key, products, cs.config.Cache.SuggestTTL)
+}
+
+// ============================================================================
+// Product View Tracking Methods
+// ============================================================================
+
+// productViewsKey returns the hourly bucket key holding product view counts
+func productViewsKey(t time.Time) string {
+	return fmt.Sprintf("products:views:%s", t.UTC().Format("2006010215"))
+}
+
+// IncrementProductView records a view for a product in the current hourly bucket
+func (cs *CacheService) IncrementProductView(productID uuid.UUID) error {
+	key := productViewsKey(time.Now())
+	ttl := cs.config.Cache.TrendingWindow + time.Hour
+
+	return cs.withRetry(func() error {
+		pipe := cs.client.TxPipeline()
+		pipe.ZIncrBy(redisCtx, key, 1, productID.String())
+		pipe.Expire(redisCtx, key, ttl)
+		_, err := pipe.Exec(redisCtx)
+		return err
+	}, 3)
+}
+
+// GetTrendingProductIDs returns up to limit product IDs ranked by views within the trending window
+func (cs *CacheService) GetTrendingProductIDs(limit int) ([]uuid.UUID, error) {
+	now := time.Now()
+	buckets := int(cs.config.Cache.TrendingWindow / time.Hour)
+	keys := make([]string, 0, buckets)
+	for i := range buckets {
+		keys = append(keys, productViewsKey(now.Add(-time.Duration(i)*time.Hour)))
+	}
+
+	var ranked []redis.Z
+	err := cs.withRetry(func() error {
+		result, err := cs.client.ZUnionWithScores(redisCtx, redis.ZStore{Keys: keys, Aggregate: "SUM"}).Result()
+		if err != nil {
+			return err
+		}
+		ranked = result
+		return nil
+	}, 3)
+	if err != nil {
+		return nil, err
+	}
+
+	// ZUNION returns ascending scores; walk backwards for most viewed first
+	ids := make([]uuid.UUID, 0, min(limit, len(ranked)))
+	for i := len(ranked) - 1; i >= 0 && len(ids) < limit; i-- {
+		member, ok := ranked[i].Member.(string)
+		if !ok {
+			continue
+		}
+		id, err := uuid.Parse(member)
+		if err != nil {
+			continue
+		}
+		ids = append(ids, id)
+	}
+
+	return ids, nil
 }
 
 // ============================================================================
@@ -520,37 +1019,55 @@ func (cs *CacheService) InvalidateUserCache(userID uuid.UUID) error {
 // InvalidateProductCaches removes all product-related caches
 // This should be called when any product is created, updated, or deleted
 func (cs *CacheService) InvalidateProductCaches(productID uuid.UUID) error {
-	cs.logger.Info("Invalidating product caches", "product_id", productID)
+	return cs.InvalidateProductCachesBulk([]uuid.UUID{productID})
+}
 
-	// First, get the product to find its SKU (if it exists in cache)
-	// This is best-effort - if it fails, we still delete pattern-based caches
-	productKey := fmt.Sprintf("product:id:%s:*", productID.String())
-	if err := cs.DeletePattern(productKey); err != nil {
-		cs.logger.Warn("Failed to delete product ID cache", "product_id", productID, "error", err)
+// InvalidateProductCachesBulk removes the caches of many products at once
+// The per-product keys are known, so they are deleted in a single round trip; the lists, SKU caches and counts
+// are cleared once through their tags. Nothing scans the keyspace unless a tag cannot be used
+func (cs *CacheService) InvalidateProductCachesBulk(productIDs []uuid.UUID) error {
+	if len(productIDs) == 0 {
+		return nil
 	}
 
-	// Delete all active product lists (they may contain this product)
-	if err := cs.DeletePattern("products:active:*"); err != nil {
+	cs.logger.Info("Invalidating product caches in bulk", "products", len(productIDs))
+
+	keys := make([]string, 0, len(productIDs)*2)
+	for _, productID := range productIDs {
+		keys = append(keys,
+			fmt.Sprintf("product:id:%s:images:%v", productID.String(), true),
+			fmt.Sprintf("product:id:%s:images:%v", productID.String(), false),
+		)
+	}
+	err := cs.withRetry(func() error {
+		return cs.client.Del(redisCtx, keys...).Err()
+	}, 3)
+	if err != nil {
+		cs.logger.Warn("Failed to delete product ID caches", "products", len(productIDs), "error", err)
+	}
+
+	// Delete all active product lists (they may contain these products)
+	if err := cs.InvalidateActiveProductsListCache(); err != nil {
 		cs.logger.Warn("Failed to delete active products cache", "error", err)
 		return err
 	}
 
+	// Delete all products cached by SKU
+	if err := cs.InvalidateProductsBySKUCache(); err != nil {
+		cs.logger.Warn("Failed to delete products by SKU cache", "error", err)
+		return err
+	}
+
 	// Delete all product counts
-	if err := cs.DeletePattern("products:count:*"); err != nil {
+	if err := cs.InvalidateProductCountCache(); err != nil {
 		cs.logger.Warn("Failed to delete product counts cache", "error", err)
 		return err
 	}
 
-	cs.logger.Info("Product caches invalidated successfully", "product_id", productID)
+	cs.logger.Info("Product caches invalidated successfully", "products", len(productIDs))
 	return nil
 }
 
-// InvalidateProductCacheBySKU removes a specific product cache by SKU
-func (cs *CacheService) InvalidateProductCacheBySKU(sku string) error {
-	key := fmt.Sprintf("product:sku:%s", sku)
-	return cs.Delete(key)
-}
-
 // InvalidateAllProductCaches removes ALL product-related caches
 // Use with caution - this is a heavy operation
 func (cs *CacheService) InvalidateAllProductCaches() error {
@@ -568,6 +1085,14 @@ func (cs *CacheService) InvalidateAllProductCaches() error {
 		}
 	}
 
+	// The tags only point at the keys deleted above
+	for _, tag := range []string{productListTag, productSKUTag, productCountTag} {
+		if err := cs.Delete(tag); err != nil {
+			cs.logger.Error("Failed to delete product cache tag", "tag", tag, "error", err)
+			return err
+		}
+	}
+
 	cs.logger.Info("All product caches invalidated successfully")
 	return nil
 }
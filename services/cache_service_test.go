@@ -0,0 +1,390 @@
+package services
+
+import (
+	"context"
+	"mamabloemetjes_server/database"
+	"mamabloemetjes_server/structs/tables"
+	"mamabloemetjes_server/testutil"
+	"maps"
+	"slices"
+	"sync"
+	"testing"
+	"time"
+
+	"github.com/google/uuid"
+	"github.com/redis/go-redis/v9"
+)
+
+func newTestCacheService(t *testing.T) *CacheService {
+	t.Helper()
+	testutil.Redis(t)
+	return NewCacheService(testutil.Logger(), testutil.Config())
+}
+
+func TestIncrementRateLimitAcrossWindowBoundary(t *testing.T) {
+	cs := newTestCacheService(t)
+	redis := testutil.Redis(t)
+	start := time.Date(2026, 1, 1, 12, 0, 0, 0, time.UTC)
+	const limit = 3
+	window := time.Minute
+
+	redis.SetTime(start)
+	for want := 1; want <= limit; want++ {
+		count, err := cs.IncrementRateLimit("1.2.3.4", "/auth/login", limit, window)
+		if err != nil {
+			t.Fatalf("IncrementRateLimit: %v", err)
+		}
+		if count != want {
+			t.Fatalf("request %d: expected count %d, got %d", want, want, count)
+		}
+	}
+
+	// Rejected requests just before the boundary are not logged
+	redis.SetTime(start.Add(window - time.Second))
+	for range 5 {
+		count, err := cs.IncrementRateLimit("1.2.3.4", "/auth/login", limit, window)
+		if err != nil {
+			t.Fatalf("IncrementRateLimit: %v", err)
+		}
+		if count != limit+1 {
+			t.Fatalf("expected a rejected request to count %d, got %d", limit+1, count)
+		}
+	}
+	logged, err := cs.GetRateLimit("1.2.3.4", "/auth/login")
+	if err != nil {
+		t.Fatalf("GetRateLimit: %v", err)
+	}
+	if logged != limit {
+		t.Fatalf("expected rejected requests not to be logged, found %d entries", logged)
+	}
+
+	// Once the first requests age out the client is let through again
+	redis.SetTime(start.Add(window + time.Millisecond))
+	count, err := cs.IncrementRateLimit("1.2.3.4", "/auth/login", limit, window)
+	if err != nil {
+		t.Fatalf("IncrementRateLimit: %v", err)
+	}
+	if count != 1 {
+		t.Fatalf("expected the window to have emptied, got count %d", count)
+	}
+}
+
+func TestIncrementRateLimitSlidesInsteadOfResetting(t *testing.T) {
+	cs := newTestCacheService(t)
+	redis := testutil.Redis(t)
+	start := time.Date(2026, 1, 1, 12, 0, 0, 0, time.UTC)
+	const limit = 2
+	window := time.Minute
+
+	// One request early in the window and one just before its end
+	for _, offset := range []time.Duration{0, window - time.Second} {
+		redis.SetTime(start.Add(offset))
+		if count, err := cs.IncrementRateLimit("1.2.3.4", "/orders", limit, window); err != nil || count > limit {
+			t.Fatalf("expected request at %v to be allowed, got count %d (err %v)", offset, count, err)
+		}
+	}
+
+	// Just after the boundary a fixed window would have reset; the sliding window still holds the second request
+	redis.SetTime(start.Add(window + time.Second))
+	if count, err := cs.IncrementRateLimit("1.2.3.4", "/orders", limit, window); err != nil || count != 2 {
+		t.Fatalf("expected count 2 after the first request aged out, got %d (err %v)", count, err)
+	}
+	if count, err := cs.IncrementRateLimit("1.2.3.4", "/orders", limit, window); err != nil || count != limit+1 {
+		t.Fatalf("expected a burst across the boundary to be rejected, got count %d (err %v)", count, err)
+	}
+}
+
+func TestSlidingWindowScriptIsIdempotent(t *testing.T) {
+	cs := newTestCacheService(t)
+	key := rateLimitKey("1.2.3.4", "/auth/login")
+	window := time.Minute
+
+	// A retry runs the script again with the same member
+	for range 3 {
+		count, err := slidingWindowScript.Run(redisCtx, cs.client, []string{key},
+			window.Microseconds(), "same-member", window.Milliseconds(), 5).Int()
+		if err != nil {
+			t.Fatalf("script: %v", err)
+		}
+		if count != 1 {
+			t.Fatalf("expected a retried request to be counted once, got %d", count)
+		}
+	}
+}
+
+// commandCounter is a Redis hook counting the commands sent, by name
+type commandCounter struct {
+	mu     sync.Mutex
+	counts map[string]int
+}
+
+func (c *commandCounter) DialHook(next redis.DialHook) redis.DialHook { return next }
+
+func (c *commandCounter) ProcessHook(next redis.ProcessHook) redis.ProcessHook {
+	return func(ctx context.Context, cmd redis.Cmder) error {
+		c.mu.Lock()
+		c.counts[cmd.Name()]++
+		c.mu.Unlock()
+		return next(ctx, cmd)
+	}
+}
+
+func (c *commandCounter) ProcessPipelineHook(next redis.ProcessPipelineHook) redis.ProcessPipelineHook {
+	return func(ctx context.Context, cmds []redis.Cmder) error {
+		c.mu.Lock()
+		for _, cmd := range cmds {
+			c.counts[cmd.Name()]++
+		}
+		c.mu.Unlock()
+		return next(ctx, cmds)
+	}
+}
+
+// take returns the counts so far and starts counting afresh
+func (c *commandCounter) take() map[string]int {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	counts := c.counts
+	c.counts = make(map[string]int)
+	return counts
+}
+
+func TestInvalidateProductCachesBulkClearsListsOnce(t *testing.T) {
+	cs, counter := newCountingCacheService(t)
+	server := testutil.Redis(t)
+
+	// seed caches the products by id and the lists and counts they may appear in
+	seed := func(ids []uuid.UUID) {
+		for _, id := range ids {
+			if err := cs.SetProductByID(&tables.Product{ID: id, Name: "Rozenboeket", SKU: "SKU-" + id.String()[:8]}, false); err != nil {
+				t.Fatalf("SetProductByID: %v", err)
+			}
+		}
+		for page := 1; page <= 2; page++ {
+			if err := cs.SetActiveProductsList(page, 20, false, nil, database.NewPagination(page, 20, 40), ""); err != nil {
+				t.Fatalf("SetActiveProductsList: %v", err)
+			}
+		}
+		if err := cs.SetProductCount("active", 40); err != nil {
+			t.Fatalf("SetProductCount: %v", err)
+		}
+		counter.take()
+	}
+	invalidate := func(ids []uuid.UUID) map[string]int {
+		if err := cs.InvalidateProductCachesBulk(ids); err != nil {
+			t.Fatalf("InvalidateProductCachesBulk: %v", err)
+		}
+		return counter.take()
+	}
+
+	single := []uuid.UUID{uuid.New()}
+	seed(single)
+	expected := invalidate(single)
+
+	many := make([]uuid.UUID, 100)
+	for i := range many {
+		many[i] = uuid.New()
+	}
+	seed(many)
+	// The per-id keys go in one DEL and the lists are cleared once, however many products changed
+	if counts := invalidate(many); !maps.Equal(counts, expected) {
+		t.Fatalf("expected the commands of a single product invalidation %v for 100 products, got %v", expected, counts)
+	}
+
+	if keys := server.Keys(); len(keys) != 0 {
+		t.Fatalf("expected every product cache to be cleared, %d keys left: %v", len(keys), keys[:min(len(keys), 5)])
+	}
+}
+
+// newCountingCacheService returns a cache service on its own client, counting the commands it sends
+func newCountingCacheService(t *testing.T) (*CacheService, *commandCounter) {
+	t.Helper()
+	client := redis.NewClient(&redis.Options{Addr: testutil.Redis(t).Addr()})
+	t.Cleanup(func() { _ = client.Close() })
+	counter := &commandCounter{counts: make(map[string]int)}
+	client.AddHook(counter)
+	return &CacheService{logger: testutil.Logger(), config: testutil.Config(), client: client}, counter
+}
+
+func TestProductInvalidationUsesTagsWithoutScan(t *testing.T) {
+	cs, counter := newCountingCacheService(t)
+	server := testutil.Redis(t)
+	product := &tables.Product{ID: uuid.New(), Name: "Rozenboeket", SKU: "SKU-TAGS"}
+
+	for _, images := range []bool{true, false} {
+		if err := cs.SetProductByID(product, images); err != nil {
+			t.Fatalf("SetProductByID: %v", err)
+		}
+	}
+	if err := cs.SetActiveProductsList(1, 20, false, []tables.Product{*product}, database.NewPagination(1, 20, 1), ""); err != nil {
+		t.Fatalf("SetActiveProductsList: %v", err)
+	}
+	if err := cs.SetRelatedProducts(uuid.New(), 8, []tables.Product{*product}); err != nil {
+		t.Fatalf("SetRelatedProducts: %v", err)
+	}
+	if err := cs.SetProducts([]tables.Product{*product}, false); err != nil {
+		t.Fatalf("SetProducts: %v", err)
+	}
+	for _, filter := range []string{ActiveProductCountKey, "type:wedding"} {
+		if err := cs.SetProductCount(filter, 1); err != nil {
+			t.Fatalf("SetProductCount: %v", err)
+		}
+	}
+	counter.take()
+
+	if err := cs.InvalidateProductCaches(product.ID); err != nil {
+		t.Fatalf("InvalidateProductCaches: %v", err)
+	}
+	if counts := counter.take(); counts["scan"] != 0 {
+		t.Fatalf("expected the tags to be used without a SCAN, got %v", counts)
+	}
+	if keys := server.Keys(); len(keys) != 0 {
+		t.Fatalf("expected every tagged key and the tags to be removed, got %v", keys)
+	}
+}
+
+func TestInvalidateTagFallsBackToPattern(t *testing.T) {
+	cs, counter := newCountingCacheService(t)
+	server := testutil.Redis(t)
+
+	if err := cs.SetProductCount(ActiveProductCountKey, 3); err != nil {
+		t.Fatalf("SetProductCount: %v", err)
+	}
+	// A tag that cannot be read as a set, so SMEMBERS fails
+	server.Del(productCountTag)
+	if err := server.Set(productCountTag, "not-a-set"); err != nil {
+		t.Fatalf("failed to break the tag: %v", err)
+	}
+	counter.take()
+
+	if err := cs.InvalidateProductCountCache(); err != nil {
+		t.Fatalf("InvalidateProductCountCache: %v", err)
+	}
+	if counts := counter.take(); counts["scan"] == 0 {
+		t.Fatalf("expected the pattern delete to scan for the keys, got %v", counts)
+	}
+	if server.Exists("products:count:" + ActiveProductCountKey) {
+		t.Fatal("expected the count to be removed by the pattern delete")
+	}
+}
+
+func TestClaimVerificationEmailOncePerWindow(t *testing.T) {
+	cs := newTestCacheService(t)
+	server := testutil.Redis(t)
+	userID := uuid.New()
+
+	// Concurrent registration retries race for the claim, exactly one wins
+	const attempts = 10
+	var wg sync.WaitGroup
+	claims := make(chan bool, attempts)
+	for range attempts {
+		wg.Go(func() {
+			claimed, err := cs.ClaimVerificationEmail(userID)
+			if err != nil {
+				t.Errorf("ClaimVerificationEmail: %v", err)
+			}
+			claims <- claimed
+		})
+	}
+	wg.Wait()
+	close(claims)
+
+	won := 0
+	for claimed := range claims {
+		if claimed {
+			won++
+		}
+	}
+	if won != 1 {
+		t.Fatalf("expected exactly one claim to win, got %d", won)
+	}
+
+	if claimed, err := cs.ClaimVerificationEmail(uuid.New()); err != nil || !claimed {
+		t.Fatalf("expected another user to claim independently, got %v (err %v)", claimed, err)
+	}
+
+	server.FastForward(VerificationEmailWindow)
+	if claimed, err := cs.ClaimVerificationEmail(userID); err != nil || !claimed {
+		t.Fatalf("expected a new claim once the window passed, got %v (err %v)", claimed, err)
+	}
+}
+
+func TestActiveProductsListCachesPagination(t *testing.T) {
+	cs := newTestCacheService(t)
+	server := testutil.Redis(t)
+	products := []tables.Product{{ID: uuid.New(), Name: "Rozenboeket"}}
+	pagination := database.NewPagination(2, 1, 3)
+
+	if err := cs.SetActiveProductsList(2, 1, false, products, pagination, ""); err != nil {
+		t.Fatalf("SetActiveProductsList: %v", err)
+	}
+	cached, cachedPagination, err := cs.GetActiveProductsList(2, 1, false, "")
+	if err != nil || len(cached) != 1 || cachedPagination == nil || *cachedPagination != pagination {
+		t.Fatalf("expected the page with pagination %+v, got %d products and %+v (err %v)", pagination, len(cached), cachedPagination, err)
+	}
+
+	// Without its metadata a cached page is a miss, rather than a page with a made-up total
+	server.Del(activeProductsListKey(2, 1, false, "") + ":meta")
+	cached, cachedPagination, err = cs.GetActiveProductsList(2, 1, false, "")
+	if err != nil || cached != nil || cachedPagination != nil {
+		t.Fatalf("expected a miss without the pagination metadata, got %d products and %+v (err %v)", len(cached), cachedPagination, err)
+	}
+}
+
+func TestCachedProductsHitsAndMisses(t *testing.T) {
+	rozen := tables.Product{ID: uuid.New(), SKU: "SKU-ROZEN", Name: "Rozenboeket"}
+	tulpen := tables.Product{ID: uuid.New(), SKU: "SKU-TULPEN", Name: "Tulpenboeket"}
+
+	tests := []struct {
+		name    string
+		cached  []tables.Product
+		hits    []string
+		missing []string
+	}{
+		{"all miss", nil, nil, []string{"SKU-ROZEN", "SKU-TULPEN"}},
+		{"all hit", []tables.Product{rozen, tulpen}, []string{"SKU-ROZEN", "SKU-TULPEN"}, nil},
+		{"partial hit", []tables.Product{rozen}, []string{"SKU-ROZEN"}, []string{"SKU-TULPEN"}},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			cs := newTestCacheService(t)
+			if err := cs.SetProducts(tt.cached, false); err != nil {
+				t.Fatalf("SetProducts: %v", err)
+			}
+
+			products, missing, err := cs.GetProducts([]string{"SKU-ROZEN", "SKU-TULPEN"}, false)
+			if err != nil {
+				t.Fatalf("GetProducts: %v", err)
+			}
+			if !slices.Equal(missing, tt.missing) || len(products) != len(tt.hits) {
+				t.Fatalf("expected hits %v and misses %v, got %d hits and misses %v", tt.hits, tt.missing, len(products), missing)
+			}
+			for _, sku := range tt.hits {
+				if products[sku] == nil || products[sku].SKU != sku {
+					t.Fatalf("expected %s to be served from the cache, got %+v", sku, products[sku])
+				}
+			}
+		})
+	}
+}
+
+func TestCachedProductsMissOtherVariantAndCorruptEntries(t *testing.T) {
+	cs := newTestCacheService(t)
+	server := testutil.Redis(t)
+	rozen := tables.Product{ID: uuid.New(), SKU: "SKU-ROZEN", Name: "Rozenboeket"}
+	if err := cs.SetProducts([]tables.Product{rozen}, false); err != nil {
+		t.Fatalf("SetProducts: %v", err)
+	}
+
+	// Products cached without images do not answer a lookup with images
+	if products, missing, err := cs.GetProducts([]string{"SKU-ROZEN"}, true); err != nil || len(products) != 0 || !slices.Equal(missing, []string{"SKU-ROZEN"}) {
+		t.Fatalf("expected a miss with images, got %d products and misses %v (err %v)", len(products), missing, err)
+	}
+
+	if err := server.Set(productSKUKey("SKU-ROZEN", false), "{not json"); err != nil {
+		t.Fatalf("failed to corrupt the entry: %v", err)
+	}
+	if products, missing, err := cs.GetProducts([]string{"SKU-ROZEN"}, false); err != nil || len(products) != 0 || !slices.Equal(missing, []string{"SKU-ROZEN"}) {
+		t.Fatalf("expected a corrupt entry to count as a miss, got %d products and misses %v (err %v)", len(products), missing, err)
+	}
+}
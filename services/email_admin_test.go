@@ -0,0 +1,121 @@
+package services
+
+import (
+	"context"
+	"mamabloemetjes_server/structs"
+	"mamabloemetjes_server/structs/tables"
+	"mamabloemetjes_server/testutil"
+	"slices"
+	"strings"
+	"testing"
+	"time"
+)
+
+// newAdminEmailService returns an email service with the given admin emails configured, queueing without sending
+func newAdminEmailService(t *testing.T, adminEmails []string) *EmailService {
+	t.Helper()
+	cfg := *testutil.Config()
+	email := *cfg.Email
+	email.AdminEmails = adminEmails
+	cfg.Email = &email
+	return &EmailService{logger: testutil.Logger(), cfg: &cfg, queue: make(chan EmailJob, 1)}
+}
+
+func TestAdminNotificationGoesToConfiguredRecipients(t *testing.T) {
+	admins := []string{"eigenaar@mamabloemetjes.nl", "support@mamabloemetjes.nl"}
+	es := newAdminEmailService(t, admins)
+
+	if err := es.SendAdminNotification("Nieuwe bestelling", "<p>Order</p>"); err != nil {
+		t.Fatalf("SendAdminNotification: %v", err)
+	}
+
+	select {
+	case job := <-es.queue:
+		if job.Type != structs.EmailTypeAdminNotification || !slices.Equal(job.To, admins) {
+			t.Fatalf("expected an admin notification to %v, got %s to %v", admins, job.Type, job.To)
+		}
+	default:
+		t.Fatal("expected the admin notification to be queued")
+	}
+}
+
+func TestAdminRecipientsFallBackToAdminUsers(t *testing.T) {
+	ts := newTestServices(t)
+	ctx := context.Background()
+
+	admin := ts.registerUser(t, "beheer@example.com")
+	ts.registerUser(t, "klant@example.com")
+	if _, err := ts.db.NewUpdate().Table("users").Set("role = 'admin'").Where("id = ?", admin.Id).Exec(ctx); err != nil {
+		t.Fatalf("failed to promote the admin: %v", err)
+	}
+
+	es := newAdminEmailService(t, nil)
+	es.db = ts.db
+	recipients, err := es.AdminRecipients(ctx)
+	if err != nil {
+		t.Fatalf("AdminRecipients: %v", err)
+	}
+	if !slices.Equal(recipients, []string{"beheer@example.com"}) {
+		t.Fatalf("expected only the admin user without configured emails, got %v", recipients)
+	}
+}
+
+func TestNewOrderAdminNotificationSummarizesOrder(t *testing.T) {
+	admins := []string{"eigenaar@mamabloemetjes.nl"}
+	es := newAdminEmailService(t, admins)
+	order := &tables.Order{OrderNumber: "MB-2026-0042", Name: "Jan Jansen", Email: "jan@example.com", Phone: "0612345678", Total: 6250, Currency: "EUR"}
+	lines := []*tables.OrderLine{
+		{Quantity: 2, ProductName: "Rozenboeket", ProductSKU: "SKU-ROZEN", LineTotal: 5000},
+		{Quantity: 1, ProductName: "Tulpenboeket", ProductSKU: "SKU-TULPEN", LineTotal: 1250},
+	}
+
+	if err := es.SendNewOrderAdminNotification(order, lines); err != nil {
+		t.Fatalf("SendNewOrderAdminNotification: %v", err)
+	}
+
+	select {
+	case job := <-es.queue:
+		if job.Type != structs.EmailTypeAdminNotification || !slices.Equal(job.To, admins) {
+			t.Fatalf("expected an admin notification to %v, got %s to %v", admins, job.Type, job.To)
+		}
+		if !strings.Contains(job.Subject, order.OrderNumber) {
+			t.Fatalf("expected the order number in the subject, got %q", job.Subject)
+		}
+		for _, want := range []string{"MB-2026-0042", "Jan Jansen", "jan@example.com", "2x Rozenboeket (SKU-ROZEN) - €50.00", "1x Tulpenboeket (SKU-TULPEN) - €12.50", "€62.50"} {
+			if !strings.Contains(job.Body, want) {
+				t.Fatalf("expected %q in the notification, got:\n%s", want, job.Body)
+			}
+		}
+	default:
+		t.Fatal("expected the new order notification to be queued")
+	}
+}
+
+func TestCreateOrderNotifiesAdmins(t *testing.T) {
+	ts := newTestServices(t)
+	// Configured recipients, so the notification does not depend on admin users in the database
+	ts.email.cfg = newAdminEmailService(t, []string{"eigenaar@mamabloemetjes.nl"}).cfg
+	product := ts.seedProduct(t, 2500, true)
+
+	created, err := ts.orders.CreateOrderFromRequest(context.Background(), orderRequest(product), nil)
+	if err != nil {
+		t.Fatalf("CreateOrderFromRequest: %v", err)
+	}
+
+	// The confirmation is queued first, the admin notification follows in the background
+	deadline := time.After(5 * time.Second)
+	for {
+		select {
+		case job := <-ts.email.queue:
+			if job.Type != structs.EmailTypeAdminNotification {
+				continue
+			}
+			if !strings.Contains(job.Subject, created.Order.OrderNumber) || !strings.Contains(job.Body, product.Name) {
+				t.Fatalf("expected the notification to summarize order %s, got %q", created.Order.OrderNumber, job.Subject)
+			}
+			return
+		case <-deadline:
+			t.Fatal("expected the admins to be notified of the new order")
+		}
+	}
+}
@@ -0,0 +1,84 @@
+package services
+
+import (
+	"context"
+	"encoding/json"
+	"mamabloemetjes_server/structs/tables"
+	"net/http"
+	"slices"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/google/uuid"
+)
+
+// deliveredEmail is the part of a Resend send request the confirmation test looks at
+type deliveredEmail struct {
+	To      []string `json:"to"`
+	Subject string   `json:"subject"`
+	Html    string   `json:"html"`
+}
+
+// capturingResend accepts every send and passes the decoded request on
+type capturingResend chan deliveredEmail
+
+func (c capturingResend) ServeHTTP(w http.ResponseWriter, req *http.Request) {
+	var email deliveredEmail
+	_ = json.NewDecoder(req.Body).Decode(&email)
+	c <- email
+	w.Header().Set("Content-Type", "application/json")
+	_, _ = w.Write([]byte(`{"id":"email-1"}`))
+}
+
+func TestSendOrderConfirmationToGuest(t *testing.T) {
+	provider := make(capturingResend, 1)
+	es := newTestEmailService(t, provider)
+
+	ctx, stop := context.WithCancel(context.Background())
+	done := make(chan struct{})
+	go func() {
+		es.StartQueue(ctx)
+		close(done)
+	}()
+	t.Cleanup(func() {
+		stop()
+		<-done
+	})
+
+	// A guest checkout: no user, the email is on the order
+	order := &tables.Order{
+		Id:            uuid.New(),
+		OrderNumber:   "MB-2026-0042",
+		Name:          "Jan Jansen",
+		Email:         "jan@example.com",
+		ShippingCents: 495,
+		DiscountCents: 500,
+		Currency:      "EUR",
+	}
+	lines := []*tables.OrderLine{
+		{Quantity: 2, ProductName: "Rozenboeket", UnitPrice: 2500, LineTotal: 5000},
+		{Quantity: 1, ProductName: "Tulpenboeket", UnitPrice: 1995, LineTotal: 1995},
+	}
+	address := &tables.Address{Street: "Dorpsstraat", HouseNo: "1", PostalCode: "1234 AB", City: "Utrecht", Country: "NL"}
+	if err := es.SendOrderConfirmation(order, lines, address); err != nil {
+		t.Fatalf("SendOrderConfirmation: %v", err)
+	}
+
+	var email deliveredEmail
+	select {
+	case email = <-provider:
+	case <-time.After(5 * time.Second):
+		t.Fatal("expected the confirmation to be sent")
+	}
+
+	if !slices.Contains(email.To, "jan@example.com") || !strings.Contains(email.Subject, order.OrderNumber) {
+		t.Fatalf("expected the confirmation for %s to go to the guest, got %v %q", order.OrderNumber, email.To, email.Subject)
+	}
+	// 2x 25.00 + 19.95 - 5.00 discount + 4.95 shipping
+	for _, want := range []string{order.OrderNumber, "2x Rozenboeket - €50.00", "1x Tulpenboeket - €19.95", "-€5.00", "€4.95", "€69.90"} {
+		if !strings.Contains(email.Html, want) {
+			t.Fatalf("expected %q in the confirmation, got:\n%s", want, email.Html)
+		}
+	}
+}
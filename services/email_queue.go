@@ -0,0 +1,108 @@
+package services
+
+import (
+	"context"
+	"mamabloemetjes_server/lib"
+	"mamabloemetjes_server/structs"
+	"sync"
+	"time"
+
+	"github.com/MonkyMars/gecho"
+)
+
+// EmailJob is an email waiting in the queue
+type EmailJob struct {
+	Type     structs.EmailType
+	To       []string
+	Subject  string
+	Body     string
+	queuedAt time.Time
+}
+
+// Enqueue hands an email to the background workers and returns without waiting for delivery.
+// It fails with lib.ErrEmailQueueFull instead of blocking when the queue is full
+func (es *EmailService) Enqueue(job EmailJob) error {
+	job.queuedAt = time.Now()
+
+	select {
+	case es.queue <- job:
+		return nil
+	default:
+		es.logger.Error("Email queue full, dropping email",
+			gecho.Field("type", job.Type),
+			gecho.Field("to", job.To),
+			gecho.Field("subject", job.Subject),
+			gecho.Field("queue_size", cap(es.queue)))
+		return lib.ErrEmailQueueFull
+	}
+}
+
+// StartQueue runs the queue workers until ctx is cancelled, then sends what is still queued for at most
+// Email.QueueDrainTimeout and returns
+func (es *EmailService) StartQueue(ctx context.Context) {
+	workers := es.cfg.Email.QueueWorkers
+	es.logger.Info("Email queue started",
+		gecho.Field("workers", workers),
+		gecho.Field("queue_size", cap(es.queue)))
+
+	var wg sync.WaitGroup
+	for range workers {
+		wg.Go(func() {
+			for {
+				select {
+				case job := <-es.queue:
+					es.deliver(context.Background(), job)
+				case <-ctx.Done():
+					drainCtx, cancel := context.WithTimeout(context.Background(), es.cfg.Email.QueueDrainTimeout)
+					es.drainQueue(drainCtx)
+					cancel()
+					return
+				}
+			}
+		})
+	}
+	wg.Wait()
+
+	es.logger.Info("Email queue stopped")
+}
+
+// drainQueue sends the jobs left in the queue without waiting for new ones, until ctx is done.
+// Jobs still queued by then are dropped, so a failing provider cannot hold up shutdown
+func (es *EmailService) drainQueue(ctx context.Context) {
+	for {
+		if ctx.Err() != nil {
+			if dropped := len(es.queue); dropped > 0 {
+				es.logger.Error("Email queue drain timed out, dropping queued emails",
+					gecho.Field("dropped", dropped),
+					gecho.Field("timeout", es.cfg.Email.QueueDrainTimeout.String()))
+			}
+			return
+		}
+
+		select {
+		case job := <-es.queue:
+			es.deliver(ctx, job)
+		default:
+			return
+		}
+	}
+}
+
+// deliver sends a queued email; sendEmail retries transient failures, so an error here is final
+func (es *EmailService) deliver(ctx context.Context, job EmailJob) {
+	err := es.sendEmail(ctx, job.Type, job.To, job.Subject, job.Body)
+	if err != nil {
+		es.logger.Error("Giving up on queued email",
+			gecho.Field("error", err),
+			gecho.Field("type", job.Type),
+			gecho.Field("to", job.To),
+			gecho.Field("subject", job.Subject),
+			gecho.Field("max_attempts", es.cfg.Email.SendMaxAttempts),
+			gecho.Field("queued_for", time.Since(job.queuedAt).String()))
+		return
+	}
+
+	es.logger.Debug("Queued email sent",
+		gecho.Field("type", job.Type),
+		gecho.Field("queued_for", time.Since(job.queuedAt).String()))
+}
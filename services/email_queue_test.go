@@ -0,0 +1,120 @@
+package services
+
+import (
+	"context"
+	"mamabloemetjes_server/structs"
+	"mamabloemetjes_server/testutil"
+	"net/http"
+	"net/http/httptest"
+	"net/url"
+	"sync"
+	"testing"
+	"time"
+
+	"github.com/resend/resend-go/v3"
+)
+
+// fakeResend answers the first failures sends with a 502, then accepts them, recording the idempotency keys
+type fakeResend struct {
+	mu       sync.Mutex
+	failures int
+	keys     []string
+	sent     chan struct{}
+}
+
+func (f *fakeResend) ServeHTTP(w http.ResponseWriter, r *http.Request) {
+	f.mu.Lock()
+	f.keys = append(f.keys, r.Header.Get("Idempotency-Key"))
+	fail := len(f.keys) <= f.failures
+	f.mu.Unlock()
+
+	if fail {
+		w.WriteHeader(http.StatusBadGateway)
+		return
+	}
+	w.Header().Set("Content-Type", "application/json")
+	_, _ = w.Write([]byte(`{"id":"email-1"}`))
+	f.sent <- struct{}{}
+}
+
+// newTestEmailService returns an email service sending to handler instead of Resend
+func newTestEmailService(t *testing.T, handler http.Handler) *EmailService {
+	t.Helper()
+	server := httptest.NewServer(handler)
+	t.Cleanup(server.Close)
+
+	cfg := testutil.Config()
+	client := resend.NewCustomClient(&http.Client{Transport: emailStatusTransport{base: http.DefaultTransport}}, "re_test")
+	baseURL, err := url.Parse(server.URL + "/")
+	if err != nil {
+		t.Fatalf("failed to parse the fake server URL: %v", err)
+	}
+	client.BaseURL = baseURL
+
+	return &EmailService{
+		logger: testutil.Logger(),
+		cfg:    cfg,
+		client: client,
+		queue:  make(chan EmailJob, cfg.Email.QueueSize),
+	}
+}
+
+func TestQueuedEmailFailsTwiceThenSucceeds(t *testing.T) {
+	provider := &fakeResend{failures: 2, sent: make(chan struct{}, 1)}
+	es := newTestEmailService(t, provider)
+	if es.cfg.Email.SendMaxAttempts < 3 {
+		t.Skipf("needs at least 3 send attempts, EMAIL_SEND_MAX_ATTEMPTS is %d", es.cfg.Email.SendMaxAttempts)
+	}
+
+	ctx, stop := context.WithCancel(context.Background())
+	done := make(chan struct{})
+	go func() {
+		es.StartQueue(ctx)
+		close(done)
+	}()
+	t.Cleanup(func() {
+		stop()
+		<-done
+	})
+
+	if err := es.Enqueue(EmailJob{Type: structs.EmailTypeVerification, To: []string{"jan@example.com"}, Subject: "Verify your email", Body: "<p>Hi</p>"}); err != nil {
+		t.Fatalf("Enqueue: %v", err)
+	}
+
+	select {
+	case <-provider.sent:
+	case <-time.After(5 * time.Second):
+		t.Fatal("expected the email to be sent after two failures")
+	}
+
+	provider.mu.Lock()
+	defer provider.mu.Unlock()
+	if len(provider.keys) != 3 {
+		t.Fatalf("expected 3 attempts, got %d", len(provider.keys))
+	}
+	for _, key := range provider.keys {
+		if key == "" || key != provider.keys[0] {
+			t.Fatalf("expected every attempt to carry the same idempotency key, got %v", provider.keys)
+		}
+	}
+}
+
+func TestDrainQueueStopsAtDeadline(t *testing.T) {
+	// The provider never recovers, so without a deadline every queued email would be retried in turn
+	provider := &fakeResend{failures: 1 << 30, sent: make(chan struct{}, 1)}
+	es := newTestEmailService(t, provider)
+
+	for range 3 {
+		if err := es.Enqueue(EmailJob{Type: structs.EmailTypeAdminNotification, To: []string{"admin@example.com"}, Subject: "New order", Body: "<p>Order</p>"}); err != nil {
+			t.Fatalf("Enqueue: %v", err)
+		}
+	}
+
+	ctx, cancel := context.WithCancel(context.Background())
+	cancel()
+	es.drainQueue(ctx)
+
+	if left := len(es.queue); left != 3 {
+		t.Fatalf("expected a drain past its deadline to send nothing, %d of 3 emails left", left)
+	}
+}
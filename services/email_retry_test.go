@@ -0,0 +1,136 @@
+package services
+
+import (
+	"errors"
+	"mamabloemetjes_server/structs"
+	"net/http"
+	"sync"
+	"testing"
+	"time"
+
+	"github.com/resend/resend-go/v3"
+)
+
+// scriptedResend answers each send with the next status of its script, accepting the send once the script runs out
+type scriptedResend struct {
+	mu         sync.Mutex
+	statuses   []int
+	retryAfter string
+	calls      int
+}
+
+func (s *scriptedResend) ServeHTTP(w http.ResponseWriter, r *http.Request) {
+	s.mu.Lock()
+	s.calls++
+	status := http.StatusOK
+	if s.calls <= len(s.statuses) {
+		status = s.statuses[s.calls-1]
+	}
+	s.mu.Unlock()
+
+	w.Header().Set("Content-Type", "application/json")
+	switch {
+	case status == http.StatusOK:
+		_, _ = w.Write([]byte(`{"id":"email-1"}`))
+	case status == http.StatusTooManyRequests:
+		w.Header().Set("Retry-After", s.retryAfter)
+		w.WriteHeader(status)
+		_, _ = w.Write([]byte(`{"statusCode":429,"name":"rate_limit_exceeded","message":"Too many requests"}`))
+	default:
+		w.WriteHeader(status)
+		_, _ = w.Write([]byte(`{"statusCode":422,"name":"validation_error","message":"Invalid to field"}`))
+	}
+}
+
+func (s *scriptedResend) attempts() int {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	return s.calls
+}
+
+// newRetryingEmailService returns an email service sending to provider with three quick attempts per send
+func newRetryingEmailService(t *testing.T, provider *scriptedResend) *EmailService {
+	t.Helper()
+	es := newTestEmailService(t, provider)
+	cfg := *es.cfg
+	email := *cfg.Email
+	email.SendMaxAttempts = 3
+	email.SendRetryDelay = 10 * time.Millisecond
+	email.SendMaxRetryDelay = 2 * time.Second
+	cfg.Email = &email
+	es.cfg = &cfg
+	return es
+}
+
+func sendTestEmail(es *EmailService) error {
+	return es.SendEmail(structs.EmailTypeVerification, []string{"jan@example.com"}, "Verify your email", "<p>Hi</p>")
+}
+
+func TestSendEmailRetriesThenSucceeds(t *testing.T) {
+	provider := &scriptedResend{statuses: []int{http.StatusBadGateway, http.StatusTooManyRequests}, retryAfter: "1"}
+	es := newRetryingEmailService(t, provider)
+
+	start := time.Now()
+	if err := sendTestEmail(es); err != nil {
+		t.Fatalf("expected the send to succeed on the third attempt, got %v", err)
+	}
+	if provider.attempts() != 3 {
+		t.Fatalf("expected 3 attempts, got %d", provider.attempts())
+	}
+	if elapsed := time.Since(start); elapsed < time.Second {
+		t.Fatalf("expected the rate limit's Retry-After to be honoured, retried after %s", elapsed)
+	}
+}
+
+func TestSendEmailGivesUpAfterMaxAttempts(t *testing.T) {
+	provider := &scriptedResend{statuses: []int{http.StatusServiceUnavailable, http.StatusServiceUnavailable, http.StatusServiceUnavailable, http.StatusServiceUnavailable}}
+	es := newRetryingEmailService(t, provider)
+
+	err := sendTestEmail(es)
+	var serverErr *emailServerError
+	if !errors.As(err, &serverErr) || serverErr.StatusCode != http.StatusServiceUnavailable {
+		t.Fatalf("expected the provider error after the last attempt, got %v", err)
+	}
+	if provider.attempts() != 3 {
+		t.Fatalf("expected 3 attempts, got %d", provider.attempts())
+	}
+}
+
+func TestSendEmailFinalErrorsAreNotRetried(t *testing.T) {
+	tests := []struct {
+		name     string
+		provider *scriptedResend
+	}{
+		{"rejected request", &scriptedResend{statuses: []int{http.StatusUnprocessableEntity}}},
+		{"rate limit asking for a wait above the max delay", &scriptedResend{statuses: []int{http.StatusTooManyRequests}, retryAfter: "60"}},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			es := newRetryingEmailService(t, tt.provider)
+			if err := sendTestEmail(es); err == nil {
+				t.Fatal("expected the send to fail")
+			}
+			if tt.provider.attempts() != 1 {
+				t.Fatalf("expected a single attempt, got %d", tt.provider.attempts())
+			}
+		})
+	}
+}
+
+func TestEmailRetryAfter(t *testing.T) {
+	tests := []struct {
+		name string
+		err  error
+		want time.Duration
+	}{
+		{"rate limit with a wait", &resend.RateLimitError{RetryAfter: "3"}, 3 * time.Second},
+		{"rate limit without a wait", &resend.RateLimitError{}, 0},
+		{"rate limit with a date", &resend.RateLimitError{RetryAfter: "Wed, 21 Oct 2026 07:28:00 GMT"}, 0},
+		{"provider error", &emailServerError{StatusCode: http.StatusBadGateway}, 0},
+	}
+	for _, tt := range tests {
+		if got := emailRetryAfter(tt.err); got != tt.want {
+			t.Fatalf("%s: expected %s, got %s", tt.name, tt.want, got)
+		}
+	}
+}
@@ -0,0 +1,57 @@
+package services
+
+import (
+	"encoding/json"
+	"mamabloemetjes_server/structs"
+	"net/http"
+	"testing"
+)
+
+// sentEmail is the part of a Resend send request the sender tests look at
+type sentEmail struct {
+	From    string `json:"from"`
+	ReplyTo string `json:"reply_to"`
+}
+
+// recordingResend accepts every send and passes the decoded request on
+type recordingResend chan sentEmail
+
+func (r recordingResend) ServeHTTP(w http.ResponseWriter, req *http.Request) {
+	var email sentEmail
+	_ = json.NewDecoder(req.Body).Decode(&email)
+	r <- email
+	w.Header().Set("Content-Type", "application/json")
+	_, _ = w.Write([]byte(`{"id":"email-1"}`))
+}
+
+func TestSendEmailAppliesSenderOverrides(t *testing.T) {
+	sent := make(recordingResend, 1)
+	es := newTestEmailService(t, sent)
+	cfg := *es.cfg
+	email := *cfg.Email
+	email.From = "Mamabloemetjes <noreply@mamabloemetjes.nl>"
+	email.FromOverrides = map[string]string{string(structs.EmailTypeOrderConfirmation): "Mamabloemetjes <orders@mamabloemetjes.nl>"}
+	email.ReplyToOverrides = map[string]string{string(structs.EmailTypeOrderConfirmation): "support@mamabloemetjes.nl"}
+	cfg.Email = &email
+	es.cfg = &cfg
+
+	tests := []struct {
+		emailType structs.EmailType
+		from      string
+		replyTo   string
+	}{
+		{structs.EmailTypeOrderConfirmation, "Mamabloemetjes <orders@mamabloemetjes.nl>", "support@mamabloemetjes.nl"},
+		{structs.EmailTypeVerification, "Mamabloemetjes <noreply@mamabloemetjes.nl>", ""},
+	}
+	for _, tt := range tests {
+		t.Run(string(tt.emailType), func(t *testing.T) {
+			if err := es.SendEmail(tt.emailType, []string{"jan@example.com"}, "Onderwerp", "<p>Hallo</p>"); err != nil {
+				t.Fatalf("SendEmail: %v", err)
+			}
+			got := <-sent
+			if got.From != tt.from || got.ReplyTo != tt.replyTo {
+				t.Fatalf("expected from %q and reply-to %q, got %q and %q", tt.from, tt.replyTo, got.From, got.ReplyTo)
+			}
+		})
+	}
+}
@@ -2,16 +2,22 @@ package services
 
 import (
 	"context"
+	"errors"
 	"fmt"
+	"io"
 	"mamabloemetjes_server/database"
 	"mamabloemetjes_server/lib"
 	"mamabloemetjes_server/structs"
 	"mamabloemetjes_server/structs/tables"
+	"net/http"
+	"net/url"
+	"strconv"
 	"strings"
 	"sync"
 	"time"
 
 	"github.com/MonkyMars/gecho"
+	"github.com/google/uuid"
 	"github.com/resend/resend-go/v3"
 )
 
@@ -26,42 +32,193 @@ type EmailService struct {
 	client      *resend.Client
 	db          *database.DB
 	authService *AuthService
+	queue       chan EmailJob // Emails waiting for a StartQueue worker, see Enqueue
 }
 
-func NewEmailService(logger *gecho.Logger, cfg *structs.Config, db *database.DB) *EmailService {
+func NewEmailService(logger *gecho.Logger, cfg *structs.Config, db *database.DB, authService *AuthService) *EmailService {
 	return &EmailService{
 		logger:      logger,
 		cfg:         cfg,
 		db:          db,
 		client:      getEmailClient(cfg.Email.ApiKey),
-		authService: NewAuthService(cfg, logger, db),
+		authService: authService,
+		queue:       make(chan EmailJob, cfg.Email.QueueSize),
 	}
 }
 
 func getEmailClient(apiKey string) *resend.Client {
 	clientOnce.Do(func() {
-		client = resend.NewClient(apiKey)
+		httpClient := &http.Client{
+			Timeout:   30 * time.Second,
+			Transport: emailStatusTransport{base: http.DefaultTransport},
+		}
+		client = resend.NewCustomClient(httpClient, strings.Trim(strings.TrimSpace(apiKey), "'"))
 	})
 	return client
 }
 
-func (es *EmailService) SendEmail(to []string, subject string, body string) error {
+// emailServerError reports a 5xx answer from the email provider
+// resend only keeps the message of such errors, so the transport raises this before resend sees the response
+type emailServerError struct {
+	StatusCode int
+}
+
+func (e *emailServerError) Error() string {
+	return fmt.Sprintf("email provider returned %d", e.StatusCode)
+}
+
+// emailStatusTransport turns 5xx responses into an emailServerError so they can be told apart and retried
+type emailStatusTransport struct {
+	base http.RoundTripper
+}
+
+func (t emailStatusTransport) RoundTrip(req *http.Request) (*http.Response, error) {
+	resp, err := t.base.RoundTrip(req)
+	if err != nil {
+		return nil, err
+	}
+	if resp.StatusCode >= http.StatusInternalServerError {
+		io.Copy(io.Discard, resp.Body)
+		resp.Body.Close()
+		return nil, &emailServerError{StatusCode: resp.StatusCode}
+	}
+	return resp, nil
+}
+
+// SendEmail sends an email with the sender and reply-to configured for its type.
+// Provider errors (5xx), rate limits and network errors are retried with backoff up to Email.SendMaxAttempts;
+// every attempt carries the same idempotency key, so a retry never delivers the email twice
+func (es *EmailService) SendEmail(emailType structs.EmailType, to []string, subject string, body string) error {
+	return es.sendEmail(context.Background(), emailType, to, subject, body)
+}
+
+// sendEmail is SendEmail giving up once ctx is done, including between retries
+func (es *EmailService) sendEmail(ctx context.Context, emailType structs.EmailType, to []string, subject string, body string) error {
+	from, replyTo := es.senderFor(emailType)
 	params := &resend.SendEmailRequest{
-		From:    es.cfg.Email.From,
+		From:    from,
 		To:      to,
 		Html:    body,
 		Subject: subject,
+		ReplyTo: replyTo,
+	}
+	options := &resend.SendEmailOptions{IdempotencyKey: uuid.NewString()}
+
+	retryConfig := database.RetryConfig{
+		MaxAttempts:  es.cfg.Email.SendMaxAttempts,
+		InitialDelay: es.cfg.Email.SendRetryDelay,
+		MaxDelay:     es.cfg.Email.SendMaxRetryDelay,
+		Multiplier:   2.0,
+		EnableRetry:  true,
+		Retryable:    isRetryableEmailError,
+		RetryAfter:   emailRetryAfter,
 	}
 
-	_, err := client.Emails.Send(params)
+	attempt := 0
+	err := database.RetryWithBackoff(ctx, retryConfig, func() error {
+		attempt++
+		_, err := es.client.Emails.SendWithOptions(ctx, params, options)
+		if err != nil {
+			es.logger.Warn("Email send attempt failed",
+				gecho.Field("error", err),
+				gecho.Field("type", emailType),
+				gecho.Field("attempt", attempt),
+				gecho.Field("max_attempts", retryConfig.MaxAttempts),
+				gecho.Field("retryable", isRetryableEmailError(err)))
+		}
+		return err
+	})
 	if err != nil {
-		es.logger.Error("Failed to send email", gecho.Field("error", err), gecho.Field("to", to))
+		es.logger.Error("Failed to send email",
+			gecho.Field("error", err),
+			gecho.Field("type", emailType),
+			gecho.Field("to", to),
+			gecho.Field("attempts", attempt))
 		return err
 	}
 
+	if attempt > 1 {
+		es.logger.Info("Email sent after retrying", gecho.Field("type", emailType), gecho.Field("attempts", attempt))
+	}
+
 	return nil
 }
 
+// isRetryableEmailError reports whether a failed send may succeed when tried again:
+// provider errors (5xx), rate limits (429) and network errors. Rejected requests (4xx) are final
+func isRetryableEmailError(err error) bool {
+	if errors.Is(err, resend.ErrRateLimit) {
+		return true
+	}
+
+	var serverErr *emailServerError
+	if errors.As(err, &serverErr) {
+		return true
+	}
+
+	// Transport failures reach us as *url.Error; resend's own errors for 4xx answers do not
+	var urlErr *url.Error
+	return errors.As(err, &urlErr) && !errors.Is(err, context.Canceled)
+}
+
+// emailRetryAfter returns the wait a rate limit error asks for, 0 for any other error
+func emailRetryAfter(err error) time.Duration {
+	var rateLimitErr *resend.RateLimitError
+	if !errors.As(err, &rateLimitErr) {
+		return 0
+	}
+	seconds, convErr := strconv.Atoi(strings.TrimSpace(rateLimitErr.RetryAfter))
+	if convErr != nil || seconds <= 0 {
+		return 0
+	}
+	return time.Duration(seconds) * time.Second
+}
+
+// senderFor returns the from and reply-to addresses for an email type. The from address falls back to
+// the default sender; the reply-to is empty (replies go to the sender) unless configured
+func (es *EmailService) senderFor(emailType structs.EmailType) (string, string) {
+	from := es.cfg.Email.From
+	if override, ok := es.cfg.Email.FromOverrides[string(emailType)]; ok {
+		from = override
+	}
+	return from, es.cfg.Email.ReplyToOverrides[string(emailType)]
+}
+
+// AdminRecipients returns the addresses admin notifications go to: the configured admin emails,
+// or the emails of all users with the admin role when none are configured
+func (es *EmailService) AdminRecipients(ctx context.Context) ([]string, error) {
+	if len(es.cfg.Email.AdminEmails) > 0 {
+		return es.cfg.Email.AdminEmails, nil
+	}
+
+	admins, err := database.Query[tables.User](es.db).Where("role", "admin").All(ctx)
+	if err != nil {
+		return nil, lib.MapPgError(err)
+	}
+
+	recipients := make([]string, 0, len(admins))
+	for _, admin := range admins {
+		recipients = append(recipients, admin.Email)
+	}
+	return recipients, nil
+}
+
+// SendAdminNotification queues an email to the admin recipients; it is a no-op when there are none
+func (es *EmailService) SendAdminNotification(subject, body string) error {
+	recipients, err := es.AdminRecipients(context.Background())
+	if err != nil {
+		es.logger.Error("Failed to resolve admin notification recipients", gecho.Field("error", err))
+		return err
+	}
+	if len(recipients) == 0 {
+		es.logger.Warn("No admin recipients configured, skipping admin notification", gecho.Field("subject", subject))
+		return nil
+	}
+
+	return es.Enqueue(EmailJob{Type: structs.EmailTypeAdminNotification, To: recipients, Subject: subject, Body: body})
+}
+
+// SendVerificationEmail stores a verification token for the user and queues the email with the verification link
 func (es *EmailService) SendVerificationEmail(user *tables.User) (*tables.EmailVerification, error) {
 	token, err := lib.GenerateRandomToken()
 	if err != nil {
@@ -91,265 +248,141 @@ func (es *EmailService) SendVerificationEmail(user *tables.User) (*tables.EmailV
 	verificationLink := fmt.Sprintf("%s/auth/verify-email?token=%s&user_id=%s", es.cfg.Server.ServerURL, token, user.Id.String())
 	resendLink := fmt.Sprintf("%s/email/resend?id=%s", es.cfg.Server.FrontendURL, user.Id.String())
 
-	emailBody := fmt.Sprintf(`
-		<!DOCTYPE html>
-		<html>
-		<head>
-			<meta charset="UTF-8">
-			<style>
-				body { font-family: Arial, sans-serif; line-height: 1.6; color: #333; }
-				.container { max-width: 600px; margin: 0 auto; padding: 20px; }
-				.header { background-color: #4CAF50; color: white; padding: 20px; text-align: center; }
-				.content { padding: 20px; background-color: #f9f9f9; }
-				.button { display: inline-block; padding: 15px 30px; background-color: #4CAF50; color: white; text-decoration: none; border-radius: 5px; margin: 20px 0; }
-				.footer { text-align: center; padding: 20px; color: #666; font-size: 12px; }
-				.divider { margin: 30px 0; border-top: 2px solid #ddd; }
-			</style>
-		</head>
-		<body>
-			<div class="container">
-				<!-- Dutch Version -->
-				<div class="header">
-					<h1>Verifieer je e-mailadres</h1>
-				</div>
-				<div class="content">
-					<p>Verifieer je e-mailadres door op de volgende link te klikken:</p>
-					<p style="text-align: center;">
-						<a href="%s" class="button">Verifieer E-mail</a>
-					</p>
-					<p>Deze link verloopt over %.0f minuten.</p>
-					<p>Als je geen account hebt aangemaakt, kun je deze e-mail negeren.</p>
-
-					<p>Link werkt niet? Kopieer en plak de volgende URL in je browser:</p>
-					<p style="word-break: break-all;">%s</p>
-
-					<p style="margin-top: 20px; padding: 15px; background-color: #f0f0f0; border-left: 4px solid #4CAF50;">
-						<strong>Link verlopen?</strong><br>
-						Als deze verificatielink is verlopen, kun je een nieuwe aanvragen: <a href="%s" style="color: #4CAF50; text-decoration: underline;">Klik hier om een nieuwe verificatie e-mail te ontvangen</a>
-					</p>
-				</div>
-
-				<div class="divider"></div>
-
-				<!-- English Version -->
-				<div class="header">
-					<h1>Verify your email address</h1>
-				</div>
-				<div class="content">
-					<p>Please verify your email by clicking the following link:</p>
-					<p style="text-align: center;">
-						<a href="%s" class="button">Verify Email</a>
-					</p>
-					<p>This link will expire in %.0f minutes.</p>
-					<p>If you did not create an account, please ignore this email.</p>
-
-					<p>Link not working? Copy and paste the following URL into your browser:</p>
-					<p style="word-break: break-all;">%s</p>
-
-					<p style="margin-top: 20px; padding: 15px; background-color: #f0f0f0; border-left: 4px solid #4CAF50;">
-						<strong>Link expired?</strong><br>
-						If this verification link has expired, you can request a new one: <a href="%s" style="color: #4CAF50; text-decoration: underline;">Click here to receive a new verification email</a>
-					</p>
-				</div>
-
-				<div class="footer">
-					<p>MamaBloemetjes | Fresh Flowers Delivered with Love</p>
-				</div>
-			</div>
-		</body>
-		</html>
-	`, verificationLink, time.Until(expiration).Minutes(), verificationLink, resendLink, verificationLink, time.Until(expiration).Minutes(), verificationLink, resendLink)
-
-	err = es.SendEmail([]string{user.Email}, "Verify your email", emailBody)
+	emailBody, err := es.render("verification.html", verificationEmailData{
+		VerificationLink: verificationLink,
+		ResendLink:       resendLink,
+		ExpiresInMinutes: int(time.Until(expiration).Round(time.Minute).Minutes()),
+	})
+	if err != nil {
+		es.logger.Error("Failed to render verification email", gecho.Field("error", err))
+		return nil, err
+	}
+
+	err = es.Enqueue(EmailJob{Type: structs.EmailTypeVerification, To: []string{user.Email}, Subject: "Verify your email", Body: emailBody})
 	if err != nil {
-		es.logger.Error("Failed to send verification email", gecho.Field("error", err), gecho.Field("to", user.Email))
+		es.logger.Error("Failed to queue verification email", gecho.Field("error", err), gecho.Field("user_id", user.Id))
 		return nil, err
 	}
 
 	return result, err
 }
 
-// SendOrderConfirmationEmail sends a bilingual order confirmation email
-func (es *EmailService) SendOrderConfirmationEmail(email, name, orderNumber string, orderLines []*tables.OrderLine, address *tables.Address) error {
-	// Calculate total
-	var total uint64
-	for _, line := range orderLines {
-		total += line.LineTotal
-	}
-
-	// Format total as currency
-	totalFormatted := fmt.Sprintf("€%.2f", float64(total)/100)
-
-	// Build order items list
-	itemsListNL := ""
-	itemsListEN := ""
-	var itemsBuilderNL, itemsBuilderEN strings.Builder
-	for _, line := range orderLines {
-		lineTotal := fmt.Sprintf("€%.2f", float64(line.LineTotal)/100)
-		fmt.Fprintf(&itemsBuilderNL, "<li>%dx %s - %s</li>", line.Quantity, line.ProductName, lineTotal)
-		fmt.Fprintf(&itemsBuilderEN, "<li>%dx %s - %s</li>", line.Quantity, line.ProductName, lineTotal)
-	}
-	itemsListNL = itemsBuilderNL.String()
-	itemsListEN = itemsBuilderEN.String()
-
-	// Format address
-	addressFormatted := fmt.Sprintf("%s %s<br>%s %s<br>%s",
-		address.Street, address.HouseNo, address.PostalCode, address.City, address.Country)
-
-	emailBody := fmt.Sprintf(`
-		<!DOCTYPE html>
-		<html>
-		<head>
-			<meta charset="UTF-8">
-			<style>
-				body { font-family: Arial, sans-serif; line-height: 1.6; color: #333; }
-				.container { max-width: 600px; margin: 0 auto; padding: 20px; }
-				.header { background-color: #4CAF50; color: white; padding: 20px; text-align: center; }
-				.content { padding: 20px; background-color: #f9f9f9; }
-				.order-details { background-color: white; padding: 15px; margin: 15px 0; border-radius: 5px; }
-				.footer { text-align: center; padding: 20px; color: #666; font-size: 12px; }
-				ul { list-style-type: none; padding: 0; }
-				li { padding: 5px 0; border-bottom: 1px solid #eee; }
-				.divider { margin: 30px 0; border-top: 2px solid #ddd; }
-			</style>
-		</head>
-		<body>
-			<div class="container">
-				<!-- Dutch Version -->
-				<div class="header">
-					<h1>Bedankt voor je bestelling!</h1>
-				</div>
-				<div class="content">
-					<p>Beste %s,</p>
-					<p>Je bestelling is ontvangen. Hieronder vind je de details van je bestelling.</p>
-
-					<div class="order-details">
-						<h3>Bestelnummer: <strong>%s</strong></h3>
-						<h4>Bestellijst:</h4>
-						<ul>%s</ul>
-						<p><strong>Totaal: %s</strong></p>
-
-						<h4>Bezorgadres:</h4>
-						<p>%s</p>
-					</div>
-
-					<p><strong>Betaling via Tikkie:</strong></p>
-					<p>Je ontvangt binnenkort een e-mail met een Tikkie betaallink. Zodra de betaling is ontvangen, ga ik aan de slag met je bestelling!</p>
-
-					<p>Vragen? Neem contact met mij op via %s</p>
-				</div>
-
-				<div class="divider"></div>
-
-				<!-- English Version -->
-				<div class="header">SendEmail
-					<h1>Thank you for your order!</h1>
-				</div>user
-				<div class="content">
-					<p>Dear %s,</p>
-					<p>The order has beenr recieved. Below you will find the details of your order.</p>
-
-					<div class="order-details">
-						<h3>Order Number: <strong>%s</strong></h3>
-						<h4>Order Items:</h4>
-						<ul>%s</ul>
-						<p><strong>Total: %s</strong></p>
-
-						<h4>Delivery Address:</h4>
-						<p>%s</p>
-					</div>
-
-					<p><strong>Payment via Tikkie:</strong></p>
-					<p>You will soon receive an email with a Tikkie payment link. Once the payment is received, I will start preparing your order!</p>
-
-					<p>Questions? Contact me at %s</p>
-				</div>
-
-				<div class="footer">
-					<p>MamaBloemetjes | Fresh Flowers Delivered with Love</p>
-				</div>
-			</div>
-		</body>
-		</html>
-	`, name, orderNumber, itemsListNL, totalFormatted, addressFormatted, es.cfg.Email.SupportEmail,
-		name, orderNumber, itemsListEN, totalFormatted, addressFormatted, es.cfg.Email.SupportEmail)
-
-	subject := fmt.Sprintf("Bevestiging van je bestelling %s / Order confirmation %s", orderNumber, orderNumber)
-
-	return es.SendEmail([]string{email, es.cfg.Email.SupportEmail}, subject, emailBody)
+// SendPasswordResetEmail stores a single-use password reset token for the user and queues the email with the reset link
+// Outstanding reset tokens of the user are deleted first, so only the newest link works
+func (es *EmailService) SendPasswordResetEmail(user *tables.User) (*tables.PasswordReset, error) {
+	token, err := lib.GenerateRandomToken()
+	if err != nil {
+		es.logger.Error("Failed to generate password reset token", gecho.Field("error", err))
+		return nil, err
+	}
+
+	if _, err := database.Query[tables.PasswordReset](es.db).Where("user_id", user.Id).Delete(context.Background()); err != nil {
+		es.logger.Warn("Failed to delete old password reset tokens", gecho.Field("error", err), gecho.Field("user_id", user.Id))
+	}
+
+	expiration := time.Now().Add(es.cfg.Email.PasswordResetExpiry)
+	reset := &tables.PasswordReset{
+		UserId:    user.Id,
+		TokenHash: lib.HashToken(token),
+		ExpiresAt: expiration,
+		CreatedAt: time.Now(),
+	}
+
+	// Only the hash is stored, the token itself is only in the email
+	result, err := database.Query[tables.PasswordReset](es.db).Insert(context.Background(), reset)
+	if err != nil {
+		es.logger.Error("Failed to store password reset token", gecho.Field("error", err))
+		return nil, err
+	}
+
+	// The frontend page asks for the new password and posts it with the token to /auth/reset-password
+	resetLink := fmt.Sprintf("%s/password/reset?token=%s", es.cfg.Server.FrontendURL, url.QueryEscape(token))
+
+	emailBody, err := es.render("password_reset.html", passwordResetEmailData{
+		ResetLink:        resetLink,
+		ExpiresInMinutes: int(time.Until(expiration).Round(time.Minute).Minutes()),
+	})
+	if err != nil {
+		es.logger.Error("Failed to render password reset email", gecho.Field("error", err))
+		return nil, err
+	}
+
+	err = es.Enqueue(EmailJob{Type: structs.EmailTypePasswordReset, To: []string{user.Email}, Subject: "Reset your password", Body: emailBody})
+	if err != nil {
+		es.logger.Error("Failed to queue password reset email", gecho.Field("error", err), gecho.Field("user_id", user.Id))
+		return nil, err
+	}
+
+	return result, nil
+}
+
+// SendOrderConfirmation queues a bilingual order confirmation email to the address on the order,
+// so guest checkouts get one too. It lists the items with quantities and line totals and the amount due
+func (es *EmailService) SendOrderConfirmation(order *tables.Order, orderLines []*tables.OrderLine, address *tables.Address) error {
+	emailBody, err := es.render("order_confirmation.html", orderConfirmationEmailData{
+		Name:         order.Name,
+		OrderNumber:  order.OrderNumber,
+		Currency:     order.Currency,
+		Lines:        orderLines,
+		Totals:       order.Totals(orderLines),
+		Address:      address,
+		SupportEmail: es.cfg.Email.SupportEmail,
+	})
+	if err != nil {
+		es.logger.Error("Failed to render order confirmation email", gecho.Field("error", err), gecho.Field("order_number", order.OrderNumber))
+		return err
+	}
+
+	subject := fmt.Sprintf("Bevestiging van je bestelling %s / Order confirmation %s", order.OrderNumber, order.OrderNumber)
+
+	return es.Enqueue(EmailJob{Type: structs.EmailTypeOrderConfirmation, To: []string{order.Email, es.cfg.Email.SupportEmail}, Subject: subject, Body: emailBody})
+}
+
+// SendNewOrderAdminNotification queues an email telling the admin recipients that an order was placed
+// Customer supplied values such as the name and note are escaped by the template
+func (es *EmailService) SendNewOrderAdminNotification(order *tables.Order, orderLines []*tables.OrderLine) error {
+	emailBody, err := es.render("admin_new_order.html", adminNewOrderEmailData{Order: order, Lines: orderLines})
+	if err != nil {
+		es.logger.Error("Failed to render new order admin notification", gecho.Field("error", err), gecho.Field("order_number", order.OrderNumber))
+		return err
+	}
+
+	subject := fmt.Sprintf("Nieuwe bestelling %s / New order %s", order.OrderNumber, order.OrderNumber)
+
+	return es.SendAdminNotification(subject, emailBody)
 }
 
-// SendPaymentLinkEmail sends a bilingual email with the Tikkie payment link
+// SendPaymentLinkEmail queues a bilingual email with the Tikkie payment link
 func (es *EmailService) SendPaymentLinkEmail(email, name, orderNumber, paymentLink string) error {
-	emailBody := fmt.Sprintf(`
-		<!DOCTYPE html>
-		<html>
-		<head>
-			<meta charset="UTF-8">
-			<style>
-				body { font-family: Arial, sans-serif; line-height: 1.6; color: #333; }
-				.container { max-width: 600px; margin: 0 auto; padding: 20px; }
-				.header { background-color: #4CAF50; color: white; padding: 20px; text-align: center; }
-				.content { padding: 20px; background-color: #f9f9f9; }
-				.button { display: inline-block; padding: 15px 30px; background-color: #4CAF50; color: white; text-decoration: none; border-radius: 5px; margin: 20px 0; }
-				.footer { text-align: center; padding: 20px; color: #666; font-size: 12px; }
-				.divider { margin: 30px 0; border-top: 2px solid #ddd; }
-			</style>
-		</head>
-		<body>
-			<div class="container">
-				<!-- Dutch Version -->
-				<div class="header">
-					<h1>Je betaallink is klaar!</h1>
-				</div>
-				<div class="content">
-					<p>Beste %s,</p>
-					<p>Je Tikkie betaallink voor bestelling <strong>%s</strong> is klaar!</p>
-
-					<p style="text-align: center;">
-						<a href="%s" class="button">Betaal via Tikkie</a>
-					</p>
-
-					<p>Of kopieer deze link naar je browser:</p>
-					<p style="word-break: break-all;">%s</p>
-
-					<p>Zodra de betaling is ontvangen, ga ik direct aan de slag met je bestelling!</p>
-
-					<p>Vragen? Neem contact met ons op via %s</p>
-				</div>
-
-				<div class="divider"></div>
-
-				<!-- English Version -->
-				<div class="header">
-					<h1>Your payment link is ready!</h1>
-				</div>
-				<div class="content">
-					<p>Dear %s,</p>
-					<p>Your Tikkie payment link for order <strong>%s</strong> is ready!</p>
-
-					<p style="text-align: center;">
-						<a href="%s" class="button">Pay via Tikkie</a>
-					</p>
-
-					<p>Or copy this link to your browser:</p>
-					<p style="word-break: break-all;">%s</p>
-
-					<p>Once the payment is recieved, I will immediately start preparing your order!</p>
-
-					<p>Questions? Contact me at %s</p>
-				</div>
-
-				<div class="footer">
-					<p>MamaBloemetjes | Fresh Flowers Delivered with Love</p>
-				</div>
-			</div>
-		</body>
-		</html>
-	`, name, orderNumber, paymentLink, paymentLink, es.cfg.Email.SupportEmail,
-		name, orderNumber, paymentLink, paymentLink, es.cfg.Email.SupportEmail)
+	emailBody, err := es.render("payment_link.html", paymentLinkEmailData{
+		Name:         name,
+		OrderNumber:  orderNumber,
+		PaymentLink:  paymentLink,
+		SupportEmail: es.cfg.Email.SupportEmail,
+	})
+	if err != nil {
+		es.logger.Error("Failed to render payment link email", gecho.Field("error", err), gecho.Field("order_number", orderNumber))
+		return err
+	}
 
 	subject := fmt.Sprintf("Betaallink voor bestelling %s / Payment link for order %s", orderNumber, orderNumber)
 
-	return es.SendEmail([]string{email}, subject, emailBody)
+	return es.Enqueue(EmailJob{Type: structs.EmailTypePaymentLink, To: []string{email}, Subject: subject, Body: emailBody})
+}
+
+// SendOrderCancelledEmail queues a bilingual email telling the customer an unpaid order was cancelled
+func (es *EmailService) SendOrderCancelledEmail(email, name, orderNumber string) error {
+	emailBody, err := es.render("order_cancelled.html", orderCancelledEmailData{
+		Name:         name,
+		OrderNumber:  orderNumber,
+		SupportEmail: es.cfg.Email.SupportEmail,
+	})
+	if err != nil {
+		es.logger.Error("Failed to render order cancelled email", gecho.Field("error", err), gecho.Field("order_number", orderNumber))
+		return err
+	}
+
+	subject := fmt.Sprintf("Bestelling %s geannuleerd / Order %s cancelled", orderNumber, orderNumber)
+
+	return es.Enqueue(EmailJob{Type: structs.EmailTypeOrderCancelled, To: []string{email}, Subject: subject, Body: emailBody})
 }
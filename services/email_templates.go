@@ -0,0 +1,83 @@
+package services
+
+import (
+	"bytes"
+	"embed"
+	"fmt"
+	"html/template"
+	"mamabloemetjes_server/structs/tables"
+)
+
+//go:embed templates/email/*.html
+var emailTemplateFS embed.FS
+
+// emailTemplates holds every email template, keyed by file name (e.g. "verification.html").
+// base.html defines the shared "start", "end" and "address" blocks the others are built from
+var emailTemplates = template.Must(template.New("email").Funcs(template.FuncMap{
+	"money": formatMoney,
+}).ParseFS(emailTemplateFS, "templates/email/*.html"))
+
+// currencySymbols holds the symbols of the common currencies, other currencies are written with their ISO code
+var currencySymbols = map[string]string{
+	"EUR": "€",
+	"USD": "$",
+	"GBP": "£",
+}
+
+// formatMoney formats an amount in cents in the given ISO 4217 currency, e.g. 1250 EUR as €12.50 and 1250 CHF as CHF 12.50
+func formatMoney(cents uint64, currency string) string {
+	amount := fmt.Sprintf("%.2f", float64(cents)/100)
+	if symbol, ok := currencySymbols[currency]; ok {
+		return symbol + amount
+	}
+	return currency + " " + amount
+}
+
+type verificationEmailData struct {
+	VerificationLink string
+	ResendLink       string
+	ExpiresInMinutes int
+}
+
+type passwordResetEmailData struct {
+	ResetLink        string
+	ExpiresInMinutes int
+}
+
+type orderConfirmationEmailData struct {
+	Name         string
+	OrderNumber  string
+	Currency     string
+	Lines        []*tables.OrderLine
+	Totals       tables.OrderTotals
+	Address      *tables.Address
+	SupportEmail string
+}
+
+type adminNewOrderEmailData struct {
+	Order *tables.Order
+	Lines []*tables.OrderLine
+}
+
+type paymentLinkEmailData struct {
+	Name         string
+	OrderNumber  string
+	PaymentLink  string
+	SupportEmail string
+}
+
+type orderCancelledEmailData struct {
+	Name         string
+	OrderNumber  string
+	SupportEmail string
+}
+
+// render executes the named email template with data. Values are escaped by html/template,
+// so customer input such as names and notes can be passed as-is
+func (es *EmailService) render(templateName string, data any) (string, error) {
+	var buf bytes.Buffer
+	if err := emailTemplates.ExecuteTemplate(&buf, templateName, data); err != nil {
+		return "", fmt.Errorf("failed to render email template %s: %w", templateName, err)
+	}
+	return buf.String(), nil
+}
@@ -0,0 +1,84 @@
+package services
+
+import (
+	"mamabloemetjes_server/structs/tables"
+	"strings"
+	"testing"
+)
+
+func TestRenderEscapesDisplayName(t *testing.T) {
+	es := &EmailService{}
+	name := `<script>alert("pwned")</script>`
+
+	body, err := es.render("order_confirmation.html", orderConfirmationEmailData{
+		Name:        name,
+		OrderNumber: "MB-2026-0001",
+		Currency:    "EUR",
+		Lines: []*tables.OrderLine{
+			{Quantity: 1, ProductName: `<img src=x onerror=alert(1)>`, LineTotal: 2500},
+		},
+		Totals:       tables.OrderTotals{Subtotal: 2500, Total: 2500, Shipping: 495, Due: 2995},
+		Address:      &tables.Address{Street: `"><b>Dorpsstraat</b>`, HouseNo: "1", PostalCode: "1234 AB", City: "Utrecht", Country: "NL"},
+		SupportEmail: "info@example.com",
+	})
+	if err != nil {
+		t.Fatalf("render: %v", err)
+	}
+
+	for _, raw := range []string{name, "<img src=x", "<b>Dorpsstraat</b>"} {
+		if strings.Contains(body, raw) {
+			t.Fatalf("expected %q to be escaped, got:\n%s", raw, body)
+		}
+	}
+	for _, escaped := range []string{"&lt;script&gt;alert(&#34;pwned&#34;)&lt;/script&gt;", "&lt;img src=x onerror=alert(1)&gt;", "&lt;b&gt;Dorpsstraat&lt;/b&gt;"} {
+		if !strings.Contains(body, escaped) {
+			t.Fatalf("expected the escaped %q in the email, got:\n%s", escaped, body)
+		}
+	}
+	if !strings.Contains(body, "€29.95") {
+		t.Fatal("expected the amount due to be rendered")
+	}
+}
+
+func TestFormatMoney(t *testing.T) {
+	tests := []struct {
+		cents    uint64
+		currency string
+		want     string
+	}{
+		{1250, "EUR", "€12.50"},
+		{1995, "USD", "$19.95"},
+		{5, "GBP", "£0.05"},
+		{1250, "CHF", "CHF 12.50"},
+	}
+	for _, tt := range tests {
+		if got := formatMoney(tt.cents, tt.currency); got != tt.want {
+			t.Fatalf("expected %d %s as %q, got %q", tt.cents, tt.currency, tt.want, got)
+		}
+	}
+}
+
+func TestOrderConfirmationUsesOrderCurrency(t *testing.T) {
+	es := &EmailService{}
+	body, err := es.render("order_confirmation.html", orderConfirmationEmailData{
+		Name:         "Jan Jansen",
+		OrderNumber:  "MB-2026-0001",
+		Currency:     "USD",
+		Lines:        []*tables.OrderLine{{Quantity: 2, ProductName: "Rozenboeket", LineTotal: 5000}},
+		Totals:       tables.OrderTotals{Subtotal: 5000, Total: 5000, Shipping: 495, Due: 5495},
+		Address:      &tables.Address{Street: "Dorpsstraat", HouseNo: "1", PostalCode: "1234 AB", City: "Utrecht", Country: "NL"},
+		SupportEmail: "info@example.com",
+	})
+	if err != nil {
+		t.Fatalf("render: %v", err)
+	}
+
+	for _, want := range []string{"2x Rozenboeket - $50.00", "$4.95", "$54.95"} {
+		if !strings.Contains(body, want) {
+			t.Fatalf("expected %q in the email, got:\n%s", want, body)
+		}
+	}
+	if strings.Contains(body, "€") {
+		t.Fatalf("expected no euro amounts in a dollar order, got:\n%s", body)
+	}
+}
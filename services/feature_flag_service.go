@@ -0,0 +1,158 @@
+package services
+
+import (
+	"context"
+	"errors"
+	"mamabloemetjes_server/lib"
+	"mamabloemetjes_server/structs"
+	"regexp"
+	"strconv"
+	"sync"
+	"time"
+
+	"github.com/MonkyMars/gecho"
+	"github.com/redis/go-redis/v9"
+)
+
+const (
+	featureFlagsKey     = "feature_flags"            // Redis hash of flag name -> "true"/"false"
+	featureFlagsChannel = "feature_flags:invalidate" // Pub/sub channel carrying the name of a changed flag
+)
+
+var featureFlagNamePattern = regexp.MustCompile(`^[a-z][a-z0-9_]{0,49}$`)
+
+type cachedFlag struct {
+	enabled   bool
+	expiresAt time.Time
+}
+
+// FeatureFlagService reads feature flags from Redis, falling back to the configured defaults
+// Flags are cached in memory per instance and invalidated through Redis pub/sub when changed
+type FeatureFlagService struct {
+	logger       *gecho.Logger
+	cfg          *structs.Config
+	cacheService *CacheService
+
+	mu    sync.RWMutex
+	local map[string]cachedFlag
+}
+
+func NewFeatureFlagService(logger *gecho.Logger, cfg *structs.Config, cacheService *CacheService) *FeatureFlagService {
+	return &FeatureFlagService{
+		logger:       logger,
+		cfg:          cfg,
+		cacheService: cacheService,
+		local:        make(map[string]cachedFlag),
+	}
+}
+
+// IsEnabled reports whether a flag is on. It never fails: when Redis is unavailable the
+// last known value is used, and unknown flags fall back to the configured default (or off)
+func (fs *FeatureFlagService) IsEnabled(ctx context.Context, name string) bool {
+	fs.mu.RLock()
+	cached, ok := fs.local[name]
+	fs.mu.RUnlock()
+	if ok && time.Now().Before(cached.expiresAt) {
+		return cached.enabled
+	}
+
+	enabled, err := fs.fetchFlag(ctx, name)
+	if err != nil {
+		fs.logger.Warn("Failed to read feature flag, using fallback", gecho.Field("error", err), gecho.Field("flag", name))
+		if ok {
+			return cached.enabled
+		}
+		return fs.cfg.Features.Defaults[name]
+	}
+
+	fs.mu.Lock()
+	fs.local[name] = cachedFlag{enabled: enabled, expiresAt: time.Now().Add(fs.cfg.Features.CacheTTL)}
+	fs.mu.Unlock()
+
+	return enabled
+}
+
+// fetchFlag reads a single flag from Redis, using the configured default when it has never been set
+func (fs *FeatureFlagService) fetchFlag(ctx context.Context, name string) (bool, error) {
+	val, err := fs.cacheService.client.HGet(ctx, featureFlagsKey, name).Result()
+	if errors.Is(err, redis.Nil) {
+		return fs.cfg.Features.Defaults[name], nil
+	}
+	if err != nil {
+		return false, err
+	}
+
+	return strconv.ParseBool(val)
+}
+
+// GetFlags returns every known flag: the configured defaults overlaid with the values stored in Redis
+func (fs *FeatureFlagService) GetFlags(ctx context.Context) (map[string]bool, error) {
+	stored, err := fs.cacheService.client.HGetAll(ctx, featureFlagsKey).Result()
+	if err != nil {
+		return nil, err
+	}
+
+	flags := make(map[string]bool, len(fs.cfg.Features.Defaults)+len(stored))
+	for name, enabled := range fs.cfg.Features.Defaults {
+		flags[name] = enabled
+	}
+	for name, val := range stored {
+		enabled, err := strconv.ParseBool(val)
+		if err != nil {
+			fs.logger.Warn("Ignoring malformed feature flag value", gecho.Field("flag", name), gecho.Field("value", val))
+			continue
+		}
+		flags[name] = enabled
+	}
+
+	return flags, nil
+}
+
+// SetFlag stores a flag in Redis and tells every instance to drop its cached copy
+func (fs *FeatureFlagService) SetFlag(ctx context.Context, name string, enabled bool) error {
+	if !featureFlagNamePattern.MatchString(name) {
+		return lib.ErrInvalidFeatureFlag
+	}
+
+	if err := fs.cacheService.client.HSet(ctx, featureFlagsKey, name, strconv.FormatBool(enabled)).Err(); err != nil {
+		return err
+	}
+
+	fs.forget(name)
+
+	if err := fs.cacheService.client.Publish(ctx, featureFlagsChannel, name).Err(); err != nil {
+		// Other instances pick up the change once their cached copy expires
+		fs.logger.Warn("Failed to publish feature flag invalidation", gecho.Field("error", err), gecho.Field("flag", name))
+	}
+
+	return nil
+}
+
+// forget drops a flag from the in-memory cache
+func (fs *FeatureFlagService) forget(name string) {
+	fs.mu.Lock()
+	delete(fs.local, name)
+	fs.mu.Unlock()
+}
+
+// Start listens for flag invalidations from other instances until ctx is cancelled
+func (fs *FeatureFlagService) Start(ctx context.Context) {
+	pubsub := fs.cacheService.client.Subscribe(ctx, featureFlagsChannel)
+	defer pubsub.Close()
+
+	fs.logger.Info("Feature flag invalidation listener started")
+
+	messages := pubsub.Channel()
+	for {
+		select {
+		case <-ctx.Done():
+			fs.logger.Info("Feature flag invalidation listener stopped")
+			return
+		case msg, ok := <-messages:
+			if !ok {
+				return
+			}
+			fs.forget(msg.Payload)
+		}
+	}
+}
@@ -0,0 +1,70 @@
+package services
+
+import (
+	"context"
+	"errors"
+	"mamabloemetjes_server/lib"
+	"mamabloemetjes_server/testutil"
+	"testing"
+)
+
+func newTestFeatureFlagService(t *testing.T) *FeatureFlagService {
+	t.Helper()
+	return NewFeatureFlagService(testutil.Logger(), testutil.Config(), newTestCacheService(t))
+}
+
+func TestFeatureFlagDefaults(t *testing.T) {
+	fs := newTestFeatureFlagService(t)
+	ctx := context.Background()
+
+	if !fs.IsEnabled(ctx, "trending_section") {
+		t.Fatal("expected trending_section to fall back to its default of on")
+	}
+	if fs.IsEnabled(ctx, "new_checkout") {
+		t.Fatal("expected new_checkout to fall back to its default of off")
+	}
+	if fs.IsEnabled(ctx, "unknown_flag") {
+		t.Fatal("expected an unknown flag to be off")
+	}
+
+	// Without Redis the defaults still apply
+	redis := testutil.Redis(t)
+	redis.SetError("connection refused")
+	t.Cleanup(func() { redis.SetError("") })
+	if fresh := newTestFeatureFlagService(t); !fresh.IsEnabled(ctx, "trending_section") {
+		t.Fatal("expected the default while Redis is unavailable")
+	}
+}
+
+func TestSetFeatureFlag(t *testing.T) {
+	fs := newTestFeatureFlagService(t)
+	ctx := context.Background()
+
+	// Cache the default first, so the toggle must drop the cached copy
+	if fs.IsEnabled(ctx, "new_checkout") {
+		t.Fatal("expected new_checkout to start off")
+	}
+	if err := fs.SetFlag(ctx, "new_checkout", true); err != nil {
+		t.Fatalf("SetFlag: %v", err)
+	}
+	if !fs.IsEnabled(ctx, "new_checkout") {
+		t.Fatal("expected new_checkout to be on after the toggle")
+	}
+
+	// Another instance reads the stored value
+	if other := NewFeatureFlagService(testutil.Logger(), testutil.Config(), fs.cacheService); !other.IsEnabled(ctx, "new_checkout") {
+		t.Fatal("expected another instance to read the stored flag")
+	}
+
+	flags, err := fs.GetFlags(ctx)
+	if err != nil {
+		t.Fatalf("GetFlags: %v", err)
+	}
+	if !flags["new_checkout"] || !flags["trending_section"] {
+		t.Fatalf("expected the stored flag overlaid on the defaults, got %v", flags)
+	}
+
+	if err := fs.SetFlag(ctx, "New-Checkout", true); !errors.Is(err, lib.ErrInvalidFeatureFlag) {
+		t.Fatalf("expected an invalid flag name to be rejected, got %v", err)
+	}
+}
@@ -8,28 +8,38 @@ import (
 )
 
 type ServiceManager struct {
-	AuthService    *AuthService
-	EmailService   *EmailService
-	CacheService   *CacheService
-	HealthService  *HealthService
-	ProductService *ProductService
-	OrderService   *OrderService
+	AuthService     *AuthService
+	EmailService    *EmailService
+	CacheService    *CacheService
+	HealthService   *HealthService
+	ProductService  *ProductService
+	OrderService    *OrderService
+	OrderSweeper    *OrderSweeper
+	OrderAnonymizer *OrderAnonymizer
+	FeatureFlags    *FeatureFlagService
 }
 
 func NewServiceManager(logger *gecho.Logger, cfg *structs.Config, db *database.DB) *ServiceManager {
-	authService := NewAuthService(cfg, logger, db)
+	// Services are created once here and shared, so every caller sees the same cache and auth state
 	cacheService := NewCacheService(logger, cfg)
-	emailService := NewEmailService(logger, cfg, db)
+	authService := NewAuthService(cfg, logger, db, cacheService)
+	emailService := NewEmailService(logger, cfg, db, authService)
 	healthService := NewHealthService(logger, db)
-	productService := NewProductService(logger, db, cacheService)
+	productService := NewProductService(logger, cfg, db, cacheService)
 	orderService := NewOrderService(logger, cfg, db, productService, emailService)
+	orderSweeper := NewOrderSweeper(logger, cfg, orderService, cacheService)
+	orderAnonymizer := NewOrderAnonymizer(logger, cfg, orderService, cacheService)
+	featureFlags := NewFeatureFlagService(logger, cfg, cacheService)
 
 	return &ServiceManager{
-		AuthService:    authService,
-		EmailService:   emailService,
-		CacheService:   cacheService,
-		HealthService:  healthService,
-		ProductService: productService,
-		OrderService:   orderService,
+		AuthService:     authService,
+		EmailService:    emailService,
+		CacheService:    cacheService,
+		HealthService:   healthService,
+		ProductService:  productService,
+		OrderService:    orderService,
+		OrderSweeper:    orderSweeper,
+		OrderAnonymizer: orderAnonymizer,
+		FeatureFlags:    featureFlags,
 	}
 }
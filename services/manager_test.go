@@ -0,0 +1,36 @@
+package services
+
+import (
+	"mamabloemetjes_server/testutil"
+	"testing"
+)
+
+func TestServiceManagerSharesOneCache(t *testing.T) {
+	testutil.Redis(t)
+	sm := NewServiceManager(testutil.Logger(), testutil.Config(), nil)
+
+	cache := sm.CacheService
+	for name, shared := range map[string]*CacheService{
+		"auth":           sm.AuthService.cacheService,
+		"products":       sm.ProductService.cacheService,
+		"order sweeper":  sm.OrderSweeper.cacheService,
+		"anonymizer":     sm.OrderAnonymizer.cacheService,
+		"feature flags":  sm.FeatureFlags.cacheService,
+		"auth via email": sm.EmailService.authService.cacheService,
+	} {
+		if shared != cache {
+			t.Fatalf("expected the %s service to use the shared cache service", name)
+		}
+	}
+	if sm.EmailService.authService != sm.AuthService {
+		t.Fatal("expected the email service to use the shared auth service")
+	}
+	if sm.OrderService.productService != sm.ProductService || sm.OrderService.emailService != sm.EmailService {
+		t.Fatal("expected the order service to use the shared product and email services")
+	}
+
+	// Every cache service talks to Redis through the one process-wide client
+	if other := NewCacheService(testutil.Logger(), testutil.Config()); other.client != cache.client {
+		t.Fatal("expected a single Redis client")
+	}
+}
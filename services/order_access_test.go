@@ -0,0 +1,53 @@
+package services
+
+import (
+	"context"
+	"errors"
+	"mamabloemetjes_server/lib"
+	"testing"
+	"time"
+
+	"github.com/google/uuid"
+)
+
+// withOwnershipPolicy switches the order service to policy for the rest of the test
+func (ts *testServices) withOwnershipPolicy(policy string) {
+	cfg := *ts.orders.cfg
+	orders := *cfg.Orders
+	orders.OwnershipPolicy = policy
+	cfg.Orders = &orders
+	ts.orders.cfg = &cfg
+}
+
+func TestGetOwnedOrderPolicies(t *testing.T) {
+	tests := []struct {
+		policy  string
+		foreign error
+	}{
+		{"not_found", lib.ErrNotFound},
+		{"forbidden", lib.ErrOrderNotOwned},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.policy, func(t *testing.T) {
+			ts := newTestServices(t)
+			ts.withOwnershipPolicy(tt.policy)
+			ctx := context.Background()
+			owner := uuid.New()
+
+			order := ts.seedOrder(t, time.Now(), ts.seedProduct(t, 2500, true))
+			ts.assignToUser(t, order, owner)
+
+			if owned, _, err := ts.orders.GetOwnedOrder(ctx, order.Id, owner); err != nil || owned.Id != order.Id {
+				t.Fatalf("expected the owner to get the order, got %v", err)
+			}
+			if _, _, err := ts.orders.GetOwnedOrder(ctx, order.Id, uuid.New()); !errors.Is(err, tt.foreign) {
+				t.Fatalf("expected %v for another user's order, got %v", tt.foreign, err)
+			}
+			// An unknown order is not found under either policy
+			if _, _, err := ts.orders.GetOwnedOrder(ctx, uuid.New(), owner); !lib.IsNotFound(err) {
+				t.Fatalf("expected an unknown order to be not found, got %v", err)
+			}
+		})
+	}
+}
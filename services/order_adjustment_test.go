@@ -0,0 +1,101 @@
+package services
+
+import (
+	"context"
+	"errors"
+	"mamabloemetjes_server/lib"
+	"mamabloemetjes_server/structs/tables"
+	"testing"
+	"time"
+
+	"github.com/google/uuid"
+	"github.com/uptrace/bun"
+)
+
+func TestApplyOrderDiscount(t *testing.T) {
+	ts := newTestServices(t)
+	ctx := context.Background()
+	order := ts.seedOrder(t, time.Now(), ts.seedProduct(t, 2500, true), ts.seedProduct(t, 1500, true))
+	admin := uuid.New()
+
+	// totals returns the breakdown of the stored order, as the order details show it
+	totals := func() tables.OrderTotals {
+		t.Helper()
+		lines, err := ts.orders.GetOrderLinesWithProducts(ctx, order.Id)
+		if err != nil {
+			t.Fatalf("GetOrderLinesWithProducts: %v", err)
+		}
+		return ts.reloadOrder(t, order.Id).Totals(lines)
+	}
+
+	adjusted, err := ts.orders.ApplyOrderDiscount(ctx, order.Id, tables.OrderAdjustmentPercent, 10, "Late delivery", &admin)
+	if err != nil {
+		t.Fatalf("ApplyOrderDiscount(percent): %v", err)
+	}
+	if adjusted.DiscountCents != 400 || adjusted.Total != 3600 {
+		t.Fatalf("expected 10%% of 4000 off, got discount %d and total %d", adjusted.DiscountCents, adjusted.Total)
+	}
+	if got, want := totals(), (tables.OrderTotals{Subtotal: 4000, Discount: 400, Total: 3600, Shipping: 495, Due: 4095}); got != want {
+		t.Fatalf("expected totals %+v, got %+v", want, got)
+	}
+
+	// A new discount replaces the earlier one
+	if _, err := ts.orders.ApplyOrderDiscount(ctx, order.Id, tables.OrderAdjustmentFixed, 1000, "Goodwill", &admin); err != nil {
+		t.Fatalf("ApplyOrderDiscount(fixed): %v", err)
+	}
+	if got, want := totals(), (tables.OrderTotals{Subtotal: 4000, Discount: 1000, Total: 3000, Shipping: 495, Due: 3495}); got != want {
+		t.Fatalf("expected totals %+v, got %+v", want, got)
+	}
+
+	for _, tt := range []struct {
+		kind  tables.OrderAdjustmentKind
+		value uint64
+	}{{tables.OrderAdjustmentFixed, 4001}, {tables.OrderAdjustmentPercent, 101}} {
+		if _, err := ts.orders.ApplyOrderDiscount(ctx, order.Id, tt.kind, tt.value, "Too much", &admin); !errors.Is(err, lib.ErrInvalidOrderDiscount) {
+			t.Fatalf("expected a %s discount of %d to be rejected, got %v", tt.kind, tt.value, err)
+		}
+	}
+	if stored := ts.reloadOrder(t, order.Id); stored.DiscountCents != 1000 || stored.Total != 3000 {
+		t.Fatalf("expected rejected discounts to leave the order alone, got discount %d and total %d", stored.DiscountCents, stored.Total)
+	}
+
+	// Every applied discount is recorded with its reason and admin
+	var adjustments []tables.OrderAdjustment
+	if err := ts.db.NewSelect().Model(&adjustments).Where("order_id = ?", order.Id).OrderExpr("created_at").Scan(ctx); err != nil {
+		t.Fatalf("failed to read adjustments: %v", err)
+	}
+	if len(adjustments) != 2 || adjustments[0].Reason != "Late delivery" || adjustments[0].DiscountCents != 400 ||
+		adjustments[1].Reason != "Goodwill" || adjustments[1].DiscountCents != 1000 || adjustments[1].AdjustedBy == nil || *adjustments[1].AdjustedBy != admin {
+		t.Fatalf("expected the two applied discounts to be recorded, got %+v", adjustments)
+	}
+}
+
+func TestApplyOrderDiscountOnlyBeforePaymentAndShipping(t *testing.T) {
+	ts := newTestServices(t)
+	ctx := context.Background()
+
+	tests := []struct {
+		name   string
+		column string
+		value  string
+	}{
+		{"paid", "payment_status", string(tables.PaymentStatusPaid)},
+		{"shipped", "status", string(tables.OrderStatusShipped)},
+		{"cancelled", "status", string(tables.OrderStatusCancelled)},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			order := ts.seedOrder(t, time.Now(), ts.seedProduct(t, 2500, true))
+			if _, err := ts.db.NewUpdate().Model((*tables.Order)(nil)).Set("? = ?", bun.Ident(tt.column), tt.value).Where("id = ?", order.Id).Exec(ctx); err != nil {
+				t.Fatalf("failed to update the order: %v", err)
+			}
+
+			if _, err := ts.orders.ApplyOrderDiscount(ctx, order.Id, tables.OrderAdjustmentFixed, 500, "Goodwill", nil); !errors.Is(err, lib.ErrOrderNotAdjustable) {
+				t.Fatalf("expected ErrOrderNotAdjustable, got %v", err)
+			}
+			if stored := ts.reloadOrder(t, order.Id); stored.DiscountCents != 0 || stored.Total != 2500 {
+				t.Fatalf("expected the order to stay undiscounted, got discount %d and total %d", stored.DiscountCents, stored.Total)
+			}
+		})
+	}
+}
@@ -0,0 +1,77 @@
+package services
+
+import (
+	"context"
+	"mamabloemetjes_server/structs/tables"
+	"testing"
+	"time"
+
+	"github.com/google/uuid"
+)
+
+// closeGuestOrder gives the order a guest address and moves it to the given final status
+func (ts *testServices) closeGuestOrder(t *testing.T, order *tables.Order, status tables.OrderStatus) {
+	t.Helper()
+	ctx := context.Background()
+	address := &tables.Address{
+		Id:         uuid.New(),
+		Street:     "Dorpsstraat",
+		HouseNo:    "1",
+		PostalCode: "1234 AB",
+		City:       "Utrecht",
+		Country:    "NL",
+	}
+	if _, err := ts.db.NewInsert().Model(address).Exec(ctx); err != nil {
+		t.Fatalf("failed to seed address: %v", err)
+	}
+	_, err := ts.db.NewUpdate().
+		Model((*tables.Order)(nil)).
+		Set("address_id = ?", address.Id).
+		Set("status = ?", status).
+		Where("id = ?", order.Id).
+		Exec(ctx)
+	if err != nil {
+		t.Fatalf("failed to close order: %v", err)
+	}
+	order.AddressId = address.Id
+}
+
+func TestAnonymizeExpiredGuestOrders(t *testing.T) {
+	ts := newTestServices(t)
+	ctx := context.Background()
+	expired := time.Now().Add(-ts.orders.cfg.Orders.GuestRetention - 24*time.Hour)
+
+	old := ts.seedOrder(t, expired, ts.seedProduct(t, 2500, true))
+	ts.closeGuestOrder(t, old, tables.OrderStatusDelivered)
+	recent := ts.seedOrder(t, time.Now().Add(-24*time.Hour), ts.seedProduct(t, 2500, true))
+	ts.closeGuestOrder(t, recent, tables.OrderStatusDelivered)
+	registered := ts.seedOrder(t, expired, ts.seedProduct(t, 2500, true))
+	ts.assignToUser(t, registered, uuid.New())
+
+	anonymized, err := ts.orders.AnonymizeExpiredGuestOrders(ctx)
+	if err != nil {
+		t.Fatalf("AnonymizeExpiredGuestOrders: %v", err)
+	}
+	if anonymized != 1 {
+		t.Fatalf("expected one order anonymized, got %d", anonymized)
+	}
+
+	reloaded := ts.reloadOrder(t, old.Id)
+	if reloaded.AnonymizedAt == nil || reloaded.Name != "" || reloaded.Email != "" || reloaded.Phone != "" {
+		t.Fatalf("expected the old guest order to be anonymized, got %+v", reloaded)
+	}
+	if reloaded.Total != old.Total || reloaded.Status != tables.OrderStatusDelivered {
+		t.Fatalf("expected the financial fields to be kept, got total %d and status %s", reloaded.Total, reloaded.Status)
+	}
+
+	for _, kept := range []*tables.Order{recent, registered} {
+		if reloaded := ts.reloadOrder(t, kept.Id); reloaded.AnonymizedAt != nil || reloaded.Email != kept.Email {
+			t.Fatalf("expected order %s to keep its customer data", kept.OrderNumber)
+		}
+	}
+
+	// A second run finds nothing left to do
+	if again, err := ts.orders.AnonymizeExpiredGuestOrders(ctx); err != nil || again != 0 {
+		t.Fatalf("expected nothing to anonymize on the second run, got %d (err %v)", again, err)
+	}
+}
@@ -0,0 +1,81 @@
+package services
+
+import (
+	"context"
+	"mamabloemetjes_server/structs"
+	"time"
+
+	"github.com/MonkyMars/gecho"
+)
+
+const guestOrderAnonymizerLock = "order-anonymizer"
+
+// OrderAnonymizer periodically anonymizes guest orders that are past the retention period
+type OrderAnonymizer struct {
+	logger       *gecho.Logger
+	cfg          *structs.Config
+	orderService *OrderService
+	cacheService *CacheService
+}
+
+func NewOrderAnonymizer(logger *gecho.Logger, cfg *structs.Config, orderService *OrderService, cacheService *CacheService) *OrderAnonymizer {
+	return &OrderAnonymizer{
+		logger:       logger,
+		cfg:          cfg,
+		orderService: orderService,
+		cacheService: cacheService,
+	}
+}
+
+// Start runs the anonymizer until ctx is cancelled
+func (a *OrderAnonymizer) Start(ctx context.Context) {
+	if !a.cfg.Orders.AnonymizeEnabled {
+		a.logger.Info("Guest order anonymizer disabled")
+		return
+	}
+
+	ticker := time.NewTicker(a.cfg.Orders.AnonymizeInterval)
+	defer ticker.Stop()
+
+	a.logger.Info("Guest order anonymizer started",
+		gecho.Field("interval", a.cfg.Orders.AnonymizeInterval.String()),
+		gecho.Field("retention", a.cfg.Orders.GuestRetention.String()))
+
+	for {
+		a.RunOnce(ctx)
+
+		select {
+		case <-ctx.Done():
+			a.logger.Info("Guest order anonymizer stopped")
+			return
+		case <-ticker.C:
+		}
+	}
+}
+
+// RunOnce performs a single run, guarded by a distributed lock so only one instance runs at a time
+func (a *OrderAnonymizer) RunOnce(ctx context.Context) {
+	token, acquired, err := a.cacheService.AcquireLock(guestOrderAnonymizerLock, a.cfg.Orders.AnonymizeInterval)
+	if err != nil {
+		a.logger.Warn("Failed to acquire guest order anonymizer lock", gecho.Field("error", err))
+		return
+	}
+	if !acquired {
+		a.logger.Debug("Guest order anonymization skipped, lock held by another instance")
+		return
+	}
+	defer func() {
+		if err := a.cacheService.ReleaseLock(guestOrderAnonymizerLock, token); err != nil {
+			a.logger.Warn("Failed to release guest order anonymizer lock", gecho.Field("error", err))
+		}
+	}()
+
+	anonymized, err := a.orderService.AnonymizeExpiredGuestOrders(ctx)
+	if err != nil {
+		a.logger.Error("Guest order anonymization failed", gecho.Field("error", err), gecho.Field("anonymized", anonymized))
+		return
+	}
+	if anonymized > 0 {
+		a.logger.Info("Guest order anonymization completed", gecho.Field("anonymized", anonymized))
+	}
+}
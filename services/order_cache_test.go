@@ -0,0 +1,82 @@
+package services
+
+import (
+	"context"
+	"mamabloemetjes_server/database"
+	"mamabloemetjes_server/structs/tables"
+	"testing"
+)
+
+// cacheProducts stores the products by ID and as the first page of active products, as the storefront reads them
+func (ts *testServices) cacheProducts(t *testing.T, products ...*tables.Product) {
+	t.Helper()
+	list := make([]tables.Product, 0, len(products))
+	for _, product := range products {
+		if err := ts.cache.SetProductByID(product, true); err != nil {
+			t.Fatalf("SetProductByID: %v", err)
+		}
+		list = append(list, *product)
+	}
+	pagination := database.Pagination{Page: 1, PageSize: 20, Total: len(list), TotalPages: 1}
+	if err := ts.cache.SetActiveProductsList(1, 20, true, list, pagination, ""); err != nil {
+		t.Fatalf("SetActiveProductsList: %v", err)
+	}
+}
+
+func TestOrderInvalidatesSoldProductCaches(t *testing.T) {
+	ts := newTestServices(t)
+	ctx := context.Background()
+	madeToOrder := ts.seedProduct(t, 2500, true)
+	unique := ts.seedProduct(t, 3500, false)
+	ts.cacheProducts(t, madeToOrder, unique)
+
+	if _, err := ts.orders.CreateOrderFromRequest(ctx, orderRequest(madeToOrder, unique), nil); err != nil {
+		t.Fatalf("CreateOrderFromRequest: %v", err)
+	}
+
+	// The sold product is read from the database again and shows as sold out right away
+	if cached, _ := ts.cache.GetProductByID(unique.ID, true); cached != nil {
+		t.Fatal("expected the sold product's cache to be cleared")
+	}
+	product, err := ts.products.GetProductByID(ctx, unique.ID, true, false)
+	if err != nil {
+		t.Fatalf("GetProductByID: %v", err)
+	}
+	if product.IsActive {
+		t.Fatal("expected the sold product to show as sold out")
+	}
+	if cached, _, _ := ts.cache.GetActiveProductsList(1, 20, true, ""); cached != nil {
+		t.Fatal("expected the cached active product lists to be cleared")
+	}
+	list, err := ts.products.GetActiveProducts(ctx, 1, 20, true, "", ProductScope{})
+	if err != nil {
+		t.Fatalf("GetActiveProducts: %v", err)
+	}
+	if len(list.Products) != 1 || list.Products[0].ID != madeToOrder.ID {
+		t.Fatalf("expected only the made-to-order product to be listed, got %d products", len(list.Products))
+	}
+
+	// The made-to-order product did not change and keeps its cache
+	if cached, _ := ts.cache.GetProductByID(madeToOrder.ID, true); cached == nil {
+		t.Fatal("expected the made-to-order product's cache to be kept")
+	}
+}
+
+func TestMadeToOrderOrderKeepsProductCaches(t *testing.T) {
+	ts := newTestServices(t)
+	madeToOrder := ts.seedProduct(t, 2500, true)
+	unique := ts.seedProduct(t, 3500, false)
+	ts.cacheProducts(t, madeToOrder, unique)
+
+	if _, err := ts.orders.CreateOrderFromRequest(context.Background(), orderRequest(madeToOrder), nil); err != nil {
+		t.Fatalf("CreateOrderFromRequest: %v", err)
+	}
+
+	// Nothing was reserved, so nothing is cleared
+	if cached, _ := ts.cache.GetProductByID(madeToOrder.ID, true); cached == nil {
+		t.Fatal("expected the made-to-order product's cache to be kept")
+	}
+	if cached, _, _ := ts.cache.GetActiveProductsList(1, 20, true, ""); len(cached) != 2 {
+		t.Fatalf("expected the cached list to be kept, got %d products", len(cached))
+	}
+}
@@ -0,0 +1,66 @@
+package services
+
+import (
+	"context"
+	"errors"
+	"mamabloemetjes_server/lib"
+	"mamabloemetjes_server/structs/tables"
+	"mamabloemetjes_server/testutil"
+	"testing"
+)
+
+func TestResolveOrderCurrency(t *testing.T) {
+	os := &OrderService{logger: testutil.Logger(), cfg: testutil.Config()}
+	defaultCurrency := os.cfg.Orders.DefaultCurrency
+
+	tests := []struct {
+		name       string
+		currencies []string
+		want       string
+		wantErr    error
+	}{
+		{"one currency", []string{"EUR", "EUR"}, "EUR", nil},
+		{"missing currency uses the default", []string{"", defaultCurrency}, defaultCurrency, nil},
+		{"no products uses the default", nil, defaultCurrency, nil},
+		{"mixed currencies", []string{"EUR", "USD"}, "", lib.ErrMixedCurrencies},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			products := make([]*tables.Product, 0, len(tt.currencies))
+			for _, currency := range tt.currencies {
+				products = append(products, &tables.Product{Currency: currency})
+			}
+
+			currency, err := os.resolveOrderCurrency(products)
+			if !errors.Is(err, tt.wantErr) || currency != tt.want {
+				t.Fatalf("expected %q (err %v), got %q (err %v)", tt.want, tt.wantErr, currency, err)
+			}
+		})
+	}
+}
+
+func TestCreateOrderRejectsMixedCurrencies(t *testing.T) {
+	ts := newTestServices(t)
+	ctx := context.Background()
+
+	first := ts.seedProduct(t, 2500, true)
+	second := ts.seedProduct(t, 3000, true)
+	created, err := ts.orders.CreateOrderFromRequest(ctx, orderRequest(first, second), nil)
+	if err != nil {
+		t.Fatalf("CreateOrderFromRequest: %v", err)
+	}
+	if created.Order.Currency != "EUR" || ts.reloadOrder(t, created.Order.Id).Currency != "EUR" {
+		t.Fatalf("expected a EUR order, got %s", created.Order.Currency)
+	}
+
+	dollars := ts.seedProduct(t, 2000, true)
+	if _, err := ts.db.NewUpdate().Model((*tables.Product)(nil)).Set("currency = ?", "USD").Where("id = ?", dollars.ID).Exec(ctx); err != nil {
+		t.Fatalf("failed to change the product currency: %v", err)
+	}
+	if _, err := ts.orders.CreateOrderFromRequest(ctx, orderRequest(first, dollars), nil); !errors.Is(err, lib.ErrMixedCurrencies) {
+		t.Fatalf("expected ErrMixedCurrencies, got %v", err)
+	}
+	if count, err := ts.db.NewSelect().Model((*tables.Order)(nil)).Count(ctx); err != nil || count != 1 {
+		t.Fatalf("expected the mixed order not to be stored, got %d orders (err %v)", count, err)
+	}
+}
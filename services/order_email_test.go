@@ -0,0 +1,76 @@
+package services
+
+import (
+	"context"
+	"errors"
+	"mamabloemetjes_server/lib"
+	"mamabloemetjes_server/structs"
+	"mamabloemetjes_server/structs/tables"
+	"slices"
+	"testing"
+	"time"
+
+	"github.com/google/uuid"
+)
+
+func TestUpdateOrderEmailAndResend(t *testing.T) {
+	ts := newTestServices(t)
+	ctx := context.Background()
+	key := ts.orders.cfg.Encryption.Key
+
+	order := ts.seedOrder(t, time.Now(), ts.seedProduct(t, 2500, true))
+	ts.assignToUser(t, order, uuid.New())
+	adminId := uuid.New()
+
+	updated, err := ts.orders.UpdateOrderEmail(ctx, order.Id, "jan.jansen@example.com", true, &adminId)
+	if err != nil {
+		t.Fatalf("UpdateOrderEmail: %v", err)
+	}
+	if updated.Email != "jan.jansen@example.com" {
+		t.Fatalf("expected the corrected email, got %s", updated.Email)
+	}
+	if stored, err := lib.Decrypt(ts.reloadOrder(t, order.Id).Email, key); err != nil || stored != "jan.jansen@example.com" {
+		t.Fatalf("expected the corrected email to be stored encrypted, got %q (err %v)", stored, err)
+	}
+
+	var changes []tables.OrderEmailChange
+	if err := ts.db.NewSelect().Model(&changes).Where("order_id = ?", order.Id).Scan(ctx); err != nil {
+		t.Fatalf("failed to read email changes: %v", err)
+	}
+	if len(changes) != 1 || !changes[0].Resent || changes[0].ChangedBy == nil || *changes[0].ChangedBy != adminId {
+		t.Fatalf("expected one audit entry for the resend by the admin, got %+v", changes)
+	}
+	if from, _ := lib.Decrypt(changes[0].FromEmail, key); from != "jan@example.com" {
+		t.Fatalf("expected the audit entry to keep the old email, got %q", from)
+	}
+
+	// The confirmation is queued for the new address once the change is committed
+	select {
+	case job := <-ts.email.queue:
+		if job.Type != structs.EmailTypeOrderConfirmation || !slices.Contains(job.To, "jan.jansen@example.com") {
+			t.Fatalf("expected the confirmation to go to the corrected email, got %s to %v", job.Type, job.To)
+		}
+	case <-time.After(5 * time.Second):
+		t.Fatal("expected the order confirmation to be resent")
+	}
+}
+
+func TestUpdateOrderEmailRejectsShippedOrders(t *testing.T) {
+	ts := newTestServices(t)
+	ctx := context.Background()
+
+	order := ts.seedOrder(t, time.Now(), ts.seedProduct(t, 2500, true))
+	if _, err := ts.db.NewUpdate().Model((*tables.Order)(nil)).Set("status = ?", tables.OrderStatusShipped).Where("id = ?", order.Id).Exec(ctx); err != nil {
+		t.Fatalf("failed to ship order: %v", err)
+	}
+
+	if _, err := ts.orders.UpdateOrderEmail(ctx, order.Id, "jan.jansen@example.com", true, nil); !errors.Is(err, lib.ErrOrderNotEditable) {
+		t.Fatalf("expected ErrOrderNotEditable for a shipped order, got %v", err)
+	}
+	if reloaded := ts.reloadOrder(t, order.Id); reloaded.Email != order.Email {
+		t.Fatal("expected the email of a shipped order to be left alone")
+	}
+	if _, err := ts.orders.UpdateOrderEmail(ctx, uuid.New(), "jan.jansen@example.com", false, nil); !lib.IsNotFound(err) {
+		t.Fatalf("expected ErrNotFound for an unknown order, got %v", err)
+	}
+}
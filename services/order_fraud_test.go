@@ -0,0 +1,74 @@
+package services
+
+import (
+	"context"
+	"errors"
+	"mamabloemetjes_server/lib"
+	"mamabloemetjes_server/structs"
+	"mamabloemetjes_server/structs/tables"
+	"mamabloemetjes_server/testutil"
+	"testing"
+
+	"github.com/google/uuid"
+)
+
+// withOrderLimits returns a copy of cfg with the fraud guard set to maxTotal cents and maxQuantity per line
+func withOrderLimits(cfg *structs.Config, maxTotal uint64, maxQuantity int) *structs.Config {
+	limited := *cfg
+	orders := *cfg.Orders
+	orders.MaxOrderTotal = maxTotal
+	orders.MaxLineQuantity = maxQuantity
+	limited.Orders = &orders
+	return &limited
+}
+
+func TestCheckLineQuantitiesAtAndAboveLimit(t *testing.T) {
+	os := &OrderService{logger: testutil.Logger(), cfg: withOrderLimits(testutil.Config(), 0, 3)}
+	product := &tables.Product{ID: uuid.New()}
+	products := map[string]*tables.Product{product.ID.String(): product}
+
+	if err := os.checkLineQuantities(products, map[string]int{product.ID.String(): 3}); err != nil {
+		t.Fatalf("expected a line at the limit to be accepted, got %v", err)
+	}
+
+	err := os.checkLineQuantities(products, map[string]int{product.ID.String(): 4})
+	var limitErr *lib.OrderLimitError
+	if !errors.As(err, &limitErr) || !errors.Is(err, lib.ErrOrderLimitExceeded) {
+		t.Fatalf("expected an order limit error, got %v", err)
+	}
+	if limitErr.Limit != lib.OrderLimitLineQuantity || limitErr.Max != 3 || limitErr.Actual != 4 ||
+		limitErr.ProductId == nil || *limitErr.ProductId != product.ID {
+		t.Fatalf("expected the line quantity limit for %s, got %+v", product.ID, limitErr)
+	}
+
+	os.cfg = withOrderLimits(testutil.Config(), 0, 0)
+	if err := os.checkLineQuantities(products, map[string]int{product.ID.String(): 100}); err != nil {
+		t.Fatalf("expected a zero limit to disable the check, got %v", err)
+	}
+}
+
+func TestCreateOrderMaxTotal(t *testing.T) {
+	ts := newTestServices(t)
+	ctx := context.Background()
+	ts.orders.cfg = withOrderLimits(ts.orders.cfg, 5000, 0)
+
+	// Shipping is excluded from the total, so 2 x 2500 is exactly at the limit
+	atLimit := ts.seedProduct(t, 2500, true)
+	req := orderRequest(atLimit)
+	req.Products[atLimit.ID.String()] = 2
+	if _, err := ts.orders.CreateOrderFromRequest(ctx, req, nil); err != nil {
+		t.Fatalf("expected an order at the limit to be accepted, got %v", err)
+	}
+
+	overLimit := ts.seedProduct(t, 5001, true)
+	_, err := ts.orders.CreateOrderFromRequest(ctx, orderRequest(overLimit), nil)
+	var limitErr *lib.OrderLimitError
+	if !errors.As(err, &limitErr) || limitErr.Limit != lib.OrderLimitTotal || limitErr.Max != 5000 || limitErr.Actual != 5001 {
+		t.Fatalf("expected the total limit to reject the order, got %v", err)
+	}
+
+	count, err := ts.db.NewSelect().Model((*tables.OrderLine)(nil)).Where("product_id = ?", overLimit.ID).Count(ctx)
+	if err != nil || count != 0 {
+		t.Fatalf("expected the rejected order to leave no lines, got %d (err %v)", count, err)
+	}
+}
@@ -0,0 +1,128 @@
+package services
+
+import (
+	"errors"
+	"mamabloemetjes_server/lib"
+	"mamabloemetjes_server/testutil"
+	"sync"
+	"sync/atomic"
+	"testing"
+	"time"
+
+	"github.com/google/uuid"
+)
+
+func newLimitedOrderService(t *testing.T, limit int) *OrderService {
+	t.Helper()
+	base := testutil.Config()
+	cfg := *base
+	orders := *base.Orders
+	orders.UserOrderLimit = limit
+	orders.UserOrderWindow = time.Hour
+	orders.UserLimitBypass = nil
+	cfg.Orders = &orders
+
+	cache := newTestCacheService(t)
+	return &OrderService{
+		logger:         testutil.Logger(),
+		cfg:            &cfg,
+		productService: &ProductService{logger: testutil.Logger(), cfg: &cfg, cacheService: cache},
+	}
+}
+
+func TestUserOrderLimitCountsOnlyCreatedOrders(t *testing.T) {
+	os := newLimitedOrderService(t, 2)
+	userId := uuid.New()
+
+	// Attempts that are reserved but then rejected (sold out, mixed currency, ...) give their slot back
+	for range 5 {
+		release, err := os.ReserveUserOrder(userId, "user")
+		if err != nil {
+			t.Fatalf("expected released attempts not to use the quota, got %v", err)
+		}
+		release()
+	}
+
+	for range 2 {
+		if _, err := os.ReserveUserOrder(userId, "user"); err != nil {
+			t.Fatalf("expected the order to be allowed, got %v", err)
+		}
+	}
+
+	if _, err := os.ReserveUserOrder(userId, "user"); !errors.Is(err, lib.ErrOrderRateLimited) {
+		t.Fatalf("expected ErrOrderRateLimited after %d created orders, got %v", 2, err)
+	}
+
+	// Other users have their own quota
+	if _, err := os.ReserveUserOrder(uuid.New(), "user"); err != nil {
+		t.Fatalf("expected another user to be allowed, got %v", err)
+	}
+}
+
+func TestUserOrderLimitHoldsUnderConcurrentRequests(t *testing.T) {
+	const limit = 3
+	os := newLimitedOrderService(t, limit)
+	userId := uuid.New()
+
+	var allowed atomic.Int32
+	var wg sync.WaitGroup
+	for range 20 {
+		wg.Go(func() {
+			if _, err := os.ReserveUserOrder(userId, "user"); err == nil {
+				allowed.Add(1)
+			} else if !errors.Is(err, lib.ErrOrderRateLimited) {
+				t.Errorf("expected ErrOrderRateLimited, got %v", err)
+			}
+		})
+	}
+	wg.Wait()
+
+	if allowed.Load() != limit {
+		t.Fatalf("expected exactly %d parallel orders to be allowed, got %d", limit, allowed.Load())
+	}
+}
+
+func TestUserOrderRetryAfter(t *testing.T) {
+	os := newLimitedOrderService(t, 2)
+	redis := testutil.Redis(t)
+	start := time.Date(2026, 1, 1, 12, 0, 0, 0, time.UTC)
+	userId := uuid.New()
+
+	// Without orders there is nothing to wait for but the window
+	if retryAfter := os.UserOrderRetryAfter(userId); retryAfter != time.Hour {
+		t.Fatalf("expected the window without logged orders, got %s", retryAfter)
+	}
+
+	redis.SetTime(start)
+	if _, err := os.ReserveUserOrder(userId, "user"); err != nil {
+		t.Fatalf("ReserveUserOrder: %v", err)
+	}
+	redis.SetTime(start.Add(20 * time.Minute))
+	if _, err := os.ReserveUserOrder(userId, "user"); err != nil {
+		t.Fatalf("ReserveUserOrder: %v", err)
+	}
+
+	// The oldest order frees its slot an hour after it was placed, not an hour from now
+	redis.SetTime(start.Add(45 * time.Minute))
+	if retryAfter := os.UserOrderRetryAfter(userId); retryAfter != 15*time.Minute {
+		t.Fatalf("expected the oldest order to age out in 15m, got %s", retryAfter)
+	}
+}
+
+func TestUserOrderLimitExemptions(t *testing.T) {
+	os := newLimitedOrderService(t, 1)
+	admin := uuid.New()
+	trusted := uuid.New()
+	os.cfg.Orders.UserLimitBypass = []string{trusted.String()}
+
+	for _, subject := range []struct {
+		id   uuid.UUID
+		role string
+	}{{admin, "admin"}, {trusted, "user"}} {
+		for range 3 {
+			if _, err := os.ReserveUserOrder(subject.id, subject.role); err != nil {
+				t.Fatalf("expected %s to be exempt, got %v", subject.role, err)
+			}
+		}
+	}
+}
@@ -0,0 +1,47 @@
+package services
+
+import (
+	"context"
+	"mamabloemetjes_server/database"
+	"mamabloemetjes_server/structs/tables"
+	"testing"
+	"time"
+
+	"github.com/google/uuid"
+)
+
+func TestGetOrderLinesWithProductsPreloadsProducts(t *testing.T) {
+	ts := newTestServices(t)
+
+	products := []*tables.Product{ts.seedProduct(t, 2500, true), ts.seedProduct(t, 3000, true), ts.seedProduct(t, 4500, true)}
+	for _, product := range products {
+		ts.seedImage(t, product, uuid.New(), "https://images.example.com/"+product.SKU+"-side.jpg", false)
+		ts.seedImage(t, product, uuid.New(), "https://images.example.com/"+product.SKU+".jpg", true)
+	}
+	order := ts.seedOrder(t, time.Now(), products...)
+
+	var lines []*tables.OrderLine
+	var err error
+	queries := database.CountQueries(context.Background(), func(ctx context.Context) {
+		lines, err = ts.orders.GetOrderLinesWithProducts(ctx, order.Id)
+	})
+	if err != nil {
+		t.Fatalf("GetOrderLinesWithProducts: %v", err)
+	}
+
+	// The lines with their products, then the images of all products at once, however many lines there are
+	if queries > 2 {
+		t.Fatalf("expected at most 2 queries for %d lines, got %d", len(lines), queries)
+	}
+	if len(lines) != len(products) {
+		t.Fatalf("expected %d lines, got %d", len(products), len(lines))
+	}
+	for _, line := range lines {
+		if line.Product == nil || line.Product.ID != line.ProductId || line.Product.SKU == "" || line.Product.Name == "" {
+			t.Fatalf("expected line %s to carry its product, got %+v", line.Id, line.Product)
+		}
+		if len(line.Product.Images) != 1 || line.Product.Images[0].URL != "https://images.example.com/"+line.Product.SKU+".jpg" {
+			t.Fatalf("expected only the primary image of %s, got %+v", line.Product.SKU, line.Product.Images)
+		}
+	}
+}
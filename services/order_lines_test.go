@@ -0,0 +1,162 @@
+package services
+
+import (
+	"context"
+	"errors"
+	"mamabloemetjes_server/lib"
+	"mamabloemetjes_server/structs/tables"
+	"testing"
+	"time"
+
+	"github.com/google/uuid"
+)
+
+// orderLines returns the lines of an order keyed by product
+func (ts *testServices) orderLines(t *testing.T, orderId uuid.UUID) map[uuid.UUID]*tables.OrderLine {
+	t.Helper()
+	var lines []*tables.OrderLine
+	if err := ts.db.NewSelect().Model(&lines).Where("order_id = ?", orderId).Scan(context.Background()); err != nil {
+		t.Fatalf("failed to read order lines: %v", err)
+	}
+
+	byProduct := make(map[uuid.UUID]*tables.OrderLine, len(lines))
+	for _, line := range lines {
+		byProduct[line.ProductId] = line
+	}
+	return byProduct
+}
+
+// lineChanges returns the recorded line edits of an order, oldest first
+func (ts *testServices) lineChanges(t *testing.T, orderId uuid.UUID) []tables.OrderLineChange {
+	t.Helper()
+	var changes []tables.OrderLineChange
+	err := ts.db.NewSelect().Model(&changes).Where("order_id = ?", orderId).OrderExpr("created_at").Scan(context.Background())
+	if err != nil {
+		t.Fatalf("failed to read line changes: %v", err)
+	}
+	return changes
+}
+
+func TestUpdateOrderLineQuantityIncreaseAndDecrease(t *testing.T) {
+	ts := newTestServices(t)
+	ctx := context.Background()
+	admin := uuid.New()
+
+	product := ts.seedProduct(t, 2500, true)
+	order := ts.seedOrder(t, time.Now(), product)
+	line := ts.orderLines(t, order.Id)[product.ID]
+
+	for _, quantity := range []int{3, 2} {
+		updated, err := ts.orders.UpdateOrderLineQuantity(ctx, order.Id, line.Id, quantity, &admin)
+		if err != nil {
+			t.Fatalf("UpdateOrderLineQuantity(%d): %v", quantity, err)
+		}
+		if updated.LineTotal != uint64(quantity)*2500 {
+			t.Fatalf("expected line total %d, got %d", quantity*2500, updated.LineTotal)
+		}
+		if total := ts.reloadOrder(t, order.Id).Total; total != uint64(quantity)*2500 {
+			t.Fatalf("expected order total %d, got %d", quantity*2500, total)
+		}
+	}
+
+	changes := ts.lineChanges(t, order.Id)
+	if len(changes) != 2 || changes[0].FromQuantity != 1 || changes[0].ToQuantity != 3 ||
+		changes[1].FromQuantity != 3 || changes[1].ToQuantity != 2 {
+		t.Fatalf("expected a change per edit (1->3, 3->2), got %+v", changes)
+	}
+	if changes[0].ChangedBy == nil || *changes[0].ChangedBy != admin {
+		t.Fatalf("expected the admin to be recorded, got %v", changes[0].ChangedBy)
+	}
+}
+
+func TestUpdateOrderLineQuantityChecksStockAndLimits(t *testing.T) {
+	ts := newTestServices(t)
+	ctx := context.Background()
+
+	unique := ts.seedProduct(t, 2500, false)
+	madeToOrder := ts.seedProduct(t, 2500, true)
+	order := ts.seedOrder(t, time.Now(), unique, madeToOrder)
+	lines := ts.orderLines(t, order.Id)
+
+	// A one-of-a-kind product cannot be ordered twice
+	_, err := ts.orders.UpdateOrderLineQuantity(ctx, order.Id, lines[unique.ID].Id, 2, nil)
+	var stockErr *lib.InsufficientStockError
+	if !errors.As(err, &stockErr) || stockErr.Available != 1 {
+		t.Fatalf("expected an InsufficientStockError with 1 available, got %v", err)
+	}
+
+	maxQuantity := ts.orders.cfg.Orders.MaxLineQuantity
+	_, err = ts.orders.UpdateOrderLineQuantity(ctx, order.Id, lines[madeToOrder.ID].Id, maxQuantity+1, nil)
+	var limitErr *lib.OrderLimitError
+	if !errors.As(err, &limitErr) || limitErr.Limit != lib.OrderLimitLineQuantity {
+		t.Fatalf("expected the line quantity limit, got %v", err)
+	}
+
+	// A pricey made-to-order product within the quantity limit can still exceed the total
+	expensive := ts.seedProduct(t, ts.orders.cfg.Orders.MaxOrderTotal/2, true)
+	pricey := ts.seedOrder(t, time.Now(), expensive)
+	_, err = ts.orders.UpdateOrderLineQuantity(ctx, pricey.Id, ts.orderLines(t, pricey.Id)[expensive.ID].Id, 3, nil)
+	if !errors.As(err, &limitErr) || limitErr.Limit != lib.OrderLimitTotal {
+		t.Fatalf("expected the order total limit, got %v", err)
+	}
+
+	if changes := ts.lineChanges(t, order.Id); len(changes) != 0 {
+		t.Fatalf("expected rejected edits not to be recorded, got %+v", changes)
+	}
+}
+
+func TestOrderLineEditsBlockedAfterShipment(t *testing.T) {
+	ts := newTestServices(t)
+	ctx := context.Background()
+
+	first := ts.seedProduct(t, 2500, true)
+	second := ts.seedProduct(t, 2500, true)
+	order := ts.seedOrder(t, time.Now(), first, second)
+	lines := ts.orderLines(t, order.Id)
+
+	for _, status := range []tables.OrderStatus{tables.OrderStatusPaid, tables.OrderStatusShipped} {
+		if err := ts.orders.UpdateOrderStatus(ctx, order.Id, status, nil); err != nil {
+			t.Fatalf("UpdateOrderStatus(%s): %v", status, err)
+		}
+	}
+
+	if _, err := ts.orders.UpdateOrderLineQuantity(ctx, order.Id, lines[first.ID].Id, 2, nil); !errors.Is(err, lib.ErrOrderNotEditable) {
+		t.Fatalf("expected ErrOrderNotEditable updating a shipped order, got %v", err)
+	}
+	if err := ts.orders.DeleteOrderLine(ctx, order.Id, lines[first.ID].Id, nil); !errors.Is(err, lib.ErrOrderNotEditable) {
+		t.Fatalf("expected ErrOrderNotEditable deleting from a shipped order, got %v", err)
+	}
+}
+
+func TestDeleteOrderLineReleasesProductAndRecordsChange(t *testing.T) {
+	ts := newTestServices(t)
+	ctx := context.Background()
+
+	kept := ts.seedProduct(t, 2500, false)
+	removed := ts.seedProduct(t, 4000, false)
+	order := ts.seedOrder(t, time.Now(), kept, removed)
+	lines := ts.orderLines(t, order.Id)
+
+	if err := ts.orders.DeleteOrderLine(ctx, order.Id, lines[removed.ID].Id, nil); err != nil {
+		t.Fatalf("DeleteOrderLine: %v", err)
+	}
+
+	if !ts.reloadProduct(t, removed.ID).IsActive {
+		t.Fatal("expected the removed product to be back on sale")
+	}
+	if ts.reloadProduct(t, kept.ID).IsActive {
+		t.Fatal("expected the remaining product to stay reserved")
+	}
+	if total := ts.reloadOrder(t, order.Id).Total; total != 2500 {
+		t.Fatalf("expected order total 2500, got %d", total)
+	}
+
+	changes := ts.lineChanges(t, order.Id)
+	if len(changes) != 1 || changes[0].LineId != lines[removed.ID].Id || changes[0].ToQuantity != 0 {
+		t.Fatalf("expected the removal to be recorded, got %+v", changes)
+	}
+
+	if err := ts.orders.DeleteOrderLine(ctx, order.Id, lines[kept.ID].Id, nil); !errors.Is(err, lib.ErrLastOrderLine) {
+		t.Fatalf("expected ErrLastOrderLine removing the last line, got %v", err)
+	}
+}
@@ -0,0 +1,48 @@
+package services
+
+import (
+	"context"
+	"encoding/json"
+	"testing"
+	"time"
+)
+
+func TestGetAllOrdersUsesStandardPagination(t *testing.T) {
+	ts := newTestServices(t)
+	for i := range 3 {
+		ts.seedOrder(t, time.Now().Add(-time.Duration(i)*time.Hour), ts.seedProduct(t, 2500, true))
+	}
+
+	result, err := ts.orders.GetAllOrders(context.Background(), nil, nil, 1, 2, "", "")
+	if err != nil {
+		t.Fatalf("GetAllOrders: %v", err)
+	}
+	if len(result.Orders) != 2 {
+		t.Fatalf("expected a page of 2 orders, got %d", len(result.Orders))
+	}
+
+	body, err := json.Marshal(result)
+	if err != nil {
+		t.Fatalf("failed to encode the result: %v", err)
+	}
+	var response struct {
+		Pagination map[string]any `json:"pagination"`
+	}
+	if err := json.Unmarshal(body, &response); err != nil {
+		t.Fatalf("failed to decode the result: %v", err)
+	}
+
+	expected := map[string]any{
+		"page":        float64(1),
+		"page_size":   float64(2),
+		"total":       float64(3),
+		"total_pages": float64(2),
+		"has_next":    true,
+		"has_prev":    false,
+	}
+	for field, value := range expected {
+		if response.Pagination[field] != value {
+			t.Fatalf("expected pagination %s to be %v, got %v", field, value, response.Pagination[field])
+		}
+	}
+}
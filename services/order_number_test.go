@@ -0,0 +1,40 @@
+package services
+
+import (
+	"context"
+	"testing"
+	"time"
+
+	"github.com/google/uuid"
+)
+
+func TestInsertOrderRetriesOnOrderNumberCollision(t *testing.T) {
+	ts := newTestServices(t)
+	ctx := context.Background()
+	taken := ts.seedOrder(t, time.Now(), ts.seedProduct(t, 2500, true))
+
+	// A second order that drew the same number as the first
+	order := ts.reloadOrder(t, taken.Id)
+	order.Id = uuid.New()
+
+	tx, err := ts.db.BeginTx(ctx, nil)
+	if err != nil {
+		t.Fatalf("BeginTx: %v", err)
+	}
+	defer func() { _ = tx.Rollback() }()
+
+	if err := ts.orders.insertOrder(ctx, tx, order); err != nil {
+		t.Fatalf("expected the collision to be retried, got %v", err)
+	}
+	if order.OrderNumber == taken.OrderNumber || order.OrderNumber == "" {
+		t.Fatalf("expected a second order number, got %q again", order.OrderNumber)
+	}
+
+	// The savepoint keeps the transaction usable after the collision
+	if err := tx.Commit(); err != nil {
+		t.Fatalf("Commit: %v", err)
+	}
+	if stored := ts.reloadOrder(t, order.Id); stored.OrderNumber != order.OrderNumber {
+		t.Fatalf("expected order number %q to be stored, got %q", order.OrderNumber, stored.OrderNumber)
+	}
+}
@@ -0,0 +1,51 @@
+package services
+
+import (
+	"context"
+	"errors"
+	"mamabloemetjes_server/lib"
+	"mamabloemetjes_server/structs/tables"
+	"slices"
+	"testing"
+
+	"github.com/google/uuid"
+)
+
+func TestCreateOrderNamesMissingProducts(t *testing.T) {
+	ts := newTestServices(t)
+	ctx := context.Background()
+	valid := ts.seedProduct(t, 2500, false)
+	unknown := []string{uuid.NewString(), uuid.NewString()}
+
+	req := orderRequest(valid)
+	for _, id := range unknown {
+		req.Products[id] = 1
+	}
+	_, err := ts.orders.CreateOrderFromRequest(ctx, req, nil)
+
+	var missingErr *lib.MissingProductsError
+	if !errors.As(err, &missingErr) || !errors.Is(err, lib.ErrNotFound) || !errors.Is(err, lib.ErrProductUnavailable) {
+		t.Fatalf("expected a missing products error, got %v", err)
+	}
+	slices.Sort(unknown)
+	slices.Sort(missingErr.ProductIds)
+	if !slices.Equal(missingErr.ProductIds, unknown) {
+		t.Fatalf("expected exactly the unknown IDs %v, got %v", unknown, missingErr.ProductIds)
+	}
+
+	// Malformed IDs are named before the products are looked up
+	req = orderRequest(valid)
+	req.Products["not-a-uuid"] = 1
+	_, err = ts.orders.CreateOrderFromRequest(ctx, req, nil)
+	if !errors.As(err, &missingErr) || !slices.Equal(missingErr.ProductIds, []string{"not-a-uuid"}) {
+		t.Fatalf("expected the malformed ID to be named, got %v", err)
+	}
+
+	count, err := ts.db.NewSelect().Model((*tables.OrderLine)(nil)).Where("product_id = ?", valid.ID).Count(ctx)
+	if err != nil || count != 0 {
+		t.Fatalf("expected no order lines for the rejected orders, got %d (err %v)", count, err)
+	}
+	if product := ts.reloadProduct(t, valid.ID); !product.IsActive {
+		t.Fatal("expected the one-of-a-kind product to stay available")
+	}
+}
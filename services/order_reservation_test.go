@@ -0,0 +1,115 @@
+package services
+
+import (
+	"context"
+	"errors"
+	"mamabloemetjes_server/lib"
+	"mamabloemetjes_server/structs/tables"
+	"slices"
+	"testing"
+	"time"
+
+	"github.com/google/uuid"
+)
+
+func TestReleaseExpiredReservationsCancelsWithHistory(t *testing.T) {
+	ts := newTestServices(t)
+	ctx := context.Background()
+	ttl := ts.orders.cfg.Orders.PendingTTL
+
+	expiredProduct := ts.seedProduct(t, 2500, false)
+	recentProduct := ts.seedProduct(t, 2500, false)
+	expired := ts.seedOrder(t, time.Now().Add(-ttl-time.Hour), expiredProduct)
+	recent := ts.seedOrder(t, time.Now(), recentProduct)
+
+	released, err := ts.orders.ReleaseExpiredReservations(ctx)
+	if err != nil {
+		t.Fatalf("ReleaseExpiredReservations: %v", err)
+	}
+	if released != 1 {
+		t.Fatalf("expected 1 released order, got %d", released)
+	}
+
+	if !ts.reloadProduct(t, expiredProduct.ID).IsActive {
+		t.Fatal("expected the expired order's product to be back on sale")
+	}
+	order := ts.reloadOrder(t, expired.Id)
+	if order.ReservationReleasedAt == nil {
+		t.Fatal("expected the expired order to be marked as released")
+	}
+	if ts.orders.cfg.Orders.CancelExpired {
+		if order.Status != tables.OrderStatusCancelled {
+			t.Fatalf("expected the expired order to be cancelled, got %s", order.Status)
+		}
+		if history := ts.statusHistory(t, expired.Id); !slices.Equal(history, []string{"pending->cancelled"}) {
+			t.Fatalf("expected the cancellation in the status history, got %v", history)
+		}
+	}
+
+	if ts.reloadProduct(t, recentProduct.ID).IsActive {
+		t.Fatal("expected a recent order to keep its product")
+	}
+	if ts.reloadOrder(t, recent.Id).ReservationReleasedAt != nil {
+		t.Fatal("expected a recent order to be left alone")
+	}
+}
+
+func TestReleaseOrderReservationKeepsAdminHiddenProducts(t *testing.T) {
+	ts := newTestServices(t)
+	ctx := context.Background()
+
+	reserved := ts.seedProduct(t, 2500, false)
+	hidden := ts.seedProduct(t, 3500, false)
+	order := ts.seedOrder(t, time.Now(), reserved, hidden)
+
+	// The admin takes the second product off the shop while the order is pending
+	inactive := false
+	if err := ts.products.UpdateProduct(ctx, hidden.ID, &UpdateProductRequest{IsActive: &inactive}); err != nil {
+		t.Fatalf("UpdateProduct: %v", err)
+	}
+
+	released, err := ts.orders.ReleaseOrderReservation(ctx, order.Id, true)
+	if err != nil {
+		t.Fatalf("ReleaseOrderReservation: %v", err)
+	}
+	if !slices.Equal(released, []uuid.UUID{reserved.ID}) {
+		t.Fatalf("expected only the reserved product to be released, got %v", released)
+	}
+	if !ts.reloadProduct(t, reserved.ID).IsActive {
+		t.Fatal("expected the reserved product to be back on sale")
+	}
+	if ts.reloadProduct(t, hidden.ID).IsActive {
+		t.Fatal("expected the product the admin hid to stay hidden")
+	}
+
+	// Releasing again is a no-op
+	released, err = ts.orders.ReleaseOrderReservation(ctx, order.Id, true)
+	if err != nil || released != nil {
+		t.Fatalf("expected a second release to do nothing, got %v (err %v)", released, err)
+	}
+}
+
+func TestReleasedOrderCannotBePaid(t *testing.T) {
+	ts := newTestServices(t)
+	ctx := context.Background()
+
+	product := ts.seedProduct(t, 2500, false)
+	order := ts.seedOrder(t, time.Now(), product)
+
+	if _, err := ts.orders.ReleaseOrderReservation(ctx, order.Id, false); err != nil {
+		t.Fatalf("ReleaseOrderReservation: %v", err)
+	}
+	if status := ts.reloadOrder(t, order.Id).Status; status != tables.OrderStatusPending {
+		t.Fatalf("expected a release without cancel to leave the order pending, got %s", status)
+	}
+
+	if err := ts.orders.AttachPaymentLink(ctx, order.Id, "https://pay.example.com/123"); !errors.Is(err, lib.ErrReservationReleased) {
+		t.Fatalf("expected ErrReservationReleased attaching a payment link, got %v", err)
+	}
+	if err := ts.orders.MarkOrderAsPaid(ctx, order.Id, nil); !errors.Is(err, lib.ErrReservationReleased) {
+		t.Fatalf("expected ErrReservationReleased marking the order as paid, got %v", err)
+	}
+	if reloaded := ts.reloadOrder(t, order.Id); reloaded.PaymentStatus != tables.PaymentStatusUnpaid || reloaded.PaymentLink != "" {
+		t.Fatalf("expected the released order to stay unpaid without a link, got %s %q", reloaded.PaymentStatus, reloaded.PaymentLink)
+	}
+}
@@ -2,16 +2,18 @@ package services
 
 import (
 	"context"
+	"errors"
 	"fmt"
 	"mamabloemetjes_server/database"
 	"mamabloemetjes_server/lib"
 	"mamabloemetjes_server/structs"
 	"mamabloemetjes_server/structs/tables"
-	"runtime/debug"
+	"slices"
 	"time"
 
 	"github.com/MonkyMars/gecho"
 	"github.com/google/uuid"
+	"github.com/uptrace/bun"
 )
 
 type OrderService struct {
@@ -48,43 +50,22 @@ type CreatedOrder struct {
 func (os *OrderService) CreateOrderFromRequest(ctx context.Context, req *structs.OrderRequest, userId *uuid.UUID) (orderRes *CreatedOrder, err error) {
 	os.logger.Info("CreateOrderFromRequest started", gecho.Field("products_count", len(req.Products)))
 
-	// Start a Bun transaction
-	os.logger.Info("Starting transaction")
-	tx, err := os.db.BeginTx(ctx, nil)
-	if err != nil {
-		os.logger.Error("Failed to begin transaction", gecho.Field("error", err))
-		return nil, lib.MapPgError(err)
-	}
-	os.logger.Info("Transaction started successfully", gecho.Field("tx_type", fmt.Sprintf("%T", tx)))
-
-	defer func() {
-		if p := recover(); p != nil {
-			stackTrace := string(debug.Stack())
-			os.logger.Error(fmt.Sprintf("PANIC RECOVERED: %v", p),
-				gecho.Field("panic_value", p),
-				gecho.Field("stack_trace", stackTrace))
-			tx.Rollback()
-			err = fmt.Errorf("panic recovered: %v", p)
-		} else if err != nil {
-			os.logger.Info("Rolling back transaction due to error", gecho.Field("error", err))
-			tx.Rollback()
-		} else {
-			os.logger.Info("Committing transaction")
-			err = tx.Commit()
-		}
-	}()
-
 	// Validate all products exist and are active
 	os.logger.Info("Validating product IDs")
 	productIds := make([]uuid.UUID, 0, len(req.Products))
+	invalidIds := []string{}
 	for idStr := range req.Products {
 		id, parseErr := uuid.Parse(idStr)
 		if parseErr != nil {
-			err = fmt.Errorf("invalid product ID: %s", idStr)
-			return nil, err
+			invalidIds = append(invalidIds, idStr)
+			continue
 		}
 		productIds = append(productIds, id)
 	}
+	if len(invalidIds) > 0 {
+		err = &lib.MissingProductsError{ProductIds: invalidIds}
+		return nil, err
+	}
 	os.logger.Info("Product IDs validated", gecho.Field("count", len(productIds)))
 
 	// Fetch all products
@@ -107,14 +88,24 @@ func (os *OrderService) CreateOrderFromRequest(ctx context.Context, req *structs
 			gecho.Field("active", product.IsActive))
 
 		if !product.IsActive {
-			err = fmt.Errorf("product %s (%s) is no longer available", product.Name, product.SKU)
+			err = fmt.Errorf("%w: product %s (%s) is no longer available", lib.ErrProductUnavailable, product.Name, product.SKU)
+			return nil, err
+		}
+		if !product.IsAvailableAt(time.Now()) {
+			err = fmt.Errorf("%w: product %s (%s) is outside its availability window", lib.ErrProductUnavailable, product.Name, product.SKU)
 			return nil, err
 		}
 		productMap[product.ID.String()] = product
 	}
 	os.logger.Info("Product map built", gecho.Field("map_size", len(productMap)))
 
-	// Check if all requested products exist
+	// All lines of an order must share one currency
+	currency, err := os.resolveOrderCurrency(products)
+	if err != nil {
+		return nil, err
+	}
+
+	// Check if all requested products exist, naming every missing one so no order line can hit the foreign key
 	unavailableProducts := []string{}
 	for idStr := range req.Products {
 		if _, exists := productMap[idStr]; !exists {
@@ -122,7 +113,12 @@ func (os *OrderService) CreateOrderFromRequest(ctx context.Context, req *structs
 		}
 	}
 	if len(unavailableProducts) > 0 {
-		err = fmt.Errorf("products not found: %v", unavailableProducts)
+		err = &lib.MissingProductsError{ProductIds: unavailableProducts}
+		return nil, err
+	}
+
+	// Fraud guard on quantities; the total is checked once the lines are priced
+	if err = os.checkLineQuantities(productMap, req.Products); err != nil {
 		return nil, err
 	}
 
@@ -202,13 +198,6 @@ func (os *OrderService) CreateOrderFromRequest(ctx context.Context, req *structs
 		UpdatedAt:  time.Now(),
 	}
 
-	os.logger.Info("Inserting address", gecho.Field("address_id", addressId))
-	_, err = tx.NewInsert().Model(address).Exec(ctx)
-	if err != nil {
-		return nil, lib.MapPgError(err)
-	}
-	os.logger.Info("Address inserted successfully")
-
 	// Create order
 	orderId := uuid.New()
 	orderNumber := lib.GenerateOrderNumber()
@@ -224,103 +213,133 @@ func (os *OrderService) CreateOrderFromRequest(ctx context.Context, req *structs
 		PaymentLink:   "",
 		PaymentStatus: tables.PaymentStatusUnpaid,
 		Status:        tables.OrderStatusPending,
-		ShippingCents: uint64(req.ShippingCents),
+		ShippingCents: uint64(os.cfg.Orders.ShippingCents),
+		Currency:      currency,
 		CreatedAt:     time.Now(),
 		UpdatedAt:     time.Now(),
 	}
 
-	os.logger.Info("Inserting order",
-		gecho.Field("order_id", orderId),
-		gecho.Field("order_number", orderNumber))
-	_, err = tx.NewInsert().Model(order).Exec(ctx)
-	if err != nil {
-		return nil, lib.MapPgError(err)
-	}
-
-	// Create order lines with pricing snapshots
-	orderLines := make([]*tables.OrderLine, 0, len(req.Products))
-	for idStr, quantity := range req.Products {
-		product := productMap[idStr]
+	// IDs and encrypted data are fixed above, so a retried transaction writes the same order; only the
+	// order number may be regenerated on insert. Nothing outside the database happens until it has committed.
+	// The lines are priced from the locked product rows, so the snapshot matches what was reserved
+	var orderLines []*tables.OrderLine
+	var reserved []uuid.UUID
+	err = database.Transaction(os.db, ctx, func(tx bun.Tx) error {
+		reserved = reserved[:0]
+
+		// Lock the purchased products for the rest of the transaction, in a fixed order so concurrent
+		// orders cannot deadlock, and recheck availability: a concurrent order may have taken them since they were read
+		var locked []*tables.Product
+		err := tx.NewSelect().
+			Model(&locked).
+			Where("id IN (?)", bun.In(productIds)).
+			OrderExpr("id").
+			For("UPDATE").
+			Scan(ctx)
+		if err != nil {
+			return lib.MapPgError(err)
+		}
+		lockedProducts := make(map[uuid.UUID]*tables.Product, len(locked))
+		for _, product := range locked {
+			lockedProducts[product.ID] = product
+		}
+		for idStr, quantity := range req.Products {
+			product := productMap[idStr]
+			available := 0
+			if current, ok := lockedProducts[product.ID]; ok && current.IsActive {
+				available = current.AvailableQuantity(quantity)
+			}
+			if quantity > available {
+				return &lib.InsufficientStockError{ProductId: product.ID, Requested: quantity, Available: available}
+			}
+		}
 
-		lineTotal := product.Subtotal * uint64(quantity)
+		var total uint64
+		orderLines, total = os.BuildOrderLines(orderId, lockedProducts, req.Products)
+		if len(orderLines) != len(req.Products) {
+			// Every requested product was checked above, so a missing line means the order would be incomplete
+			return fmt.Errorf("%w: built %d order lines for %d products", lib.ErrProductUnavailable, len(orderLines), len(req.Products))
+		}
+		if maxTotal := os.cfg.Orders.MaxOrderTotal; maxTotal > 0 && total > maxTotal {
+			os.logger.Warn("Order rejected for review: total above limit",
+				gecho.Field("order_number", orderNumber),
+				gecho.Field("total", total),
+				gecho.Field("max_total", maxTotal),
+				gecho.Field("user_id", userId))
+			return &lib.OrderLimitError{Limit: lib.OrderLimitTotal, Max: maxTotal, Actual: total}
+		}
+		order.Total = total
 
-		orderLine := &tables.OrderLine{
-			Id:           uuid.New(),
-			OrderId:      orderId,
-			ProductId:    product.ID,
-			Quantity:     quantity,
-			UnitPrice:    product.Price,
-			UnitDiscount: product.Discount,
-			UnitTax:      product.Tax,
-			UnitSubtotal: product.Subtotal,
-			LineTotal:    lineTotal,
-			ProductName:  product.Name,
-			ProductSKU:   product.SKU,
+		os.logger.Info("Inserting address", gecho.Field("address_id", addressId))
+		if _, err := tx.NewInsert().Model(address).Exec(ctx); err != nil {
+			return lib.MapPgError(err)
 		}
 
-		orderLines = append(orderLines, orderLine)
-	}
+		if err := os.insertOrder(ctx, tx, order); err != nil {
+			return err
+		}
+		orderNumber = order.OrderNumber
 
-	os.logger.Info("Inserting order lines", gecho.Field("count", len(orderLines)))
-	_, err = tx.NewInsert().Model(&orderLines).Exec(ctx)
-	if err != nil {
-		return nil, lib.MapPgError(err)
-	}
+		os.logger.Info("Inserting order lines", gecho.Field("count", len(orderLines)))
+		if _, err := tx.NewInsert().Model(&orderLines).Exec(ctx); err != nil {
+			return lib.MapPgError(err)
+		}
 
-	// Deactivate products that were purchased
-	os.logger.Info("Deactivating purchased products")
-	for idStr := range req.Products {
-		product := productMap[idStr]
+		// Deactivate products that were purchased, made-to-order products stay in stock
+		os.logger.Info("Deactivating purchased products")
+		for idStr := range req.Products {
+			product := productMap[idStr]
+			if lockedProducts[product.ID].MadeToOrder {
+				continue
+			}
 
-		os.logger.Info("Deactivating product",
-			gecho.Field("product_id", product.ID),
-			gecho.Field("product_name", product.Name),
-			gecho.Field("product_sku", product.SKU))
+			os.logger.Info("Deactivating product",
+				gecho.Field("product_id", product.ID),
+				gecho.Field("product_name", product.Name),
+				gecho.Field("product_sku", product.SKU))
+
+			// Set product as inactive, held by this order until it is paid or released
+			_, err := tx.NewUpdate().
+				Model((*tables.Product)(nil)).
+				Set("is_active = ?", false).
+				Set("reserved_order_id = ?", orderId).
+				Set("updated_at = ?", time.Now()).
+				Where("id = ?", product.ID).
+				Exec(ctx)
+			if err != nil {
+				os.logger.Error("Failed to deactivate product",
+					gecho.Field("error", err),
+					gecho.Field("product_id", product.ID))
+				return lib.MapPgError(err)
+			}
+			reserved = append(reserved, product.ID)
+		}
+		os.logger.Info("All purchased products deactivated successfully")
 
-		// Set product as inactive
-		_, err = tx.NewUpdate().
-			Model((*tables.Product)(nil)).
-			Set("is_active = ?", false).
-			Set("updated_at = ?", time.Now()).
-			Where("id = ?", product.ID).
-			Exec(ctx)
+		return nil
+	})
+	if err != nil {
+		return nil, err
+	}
 
-		if err != nil {
-			os.logger.Error("Failed to deactivate product",
-				gecho.Field("error", err),
-				gecho.Field("product_id", product.ID))
-			return nil, lib.MapPgError(err)
-		}
+	// Side effects only run once the order is committed. The deactivated products must drop out of the
+	// cached product and list pages right away, made-to-order products did not change and keep their caches
+	if cacheErr := os.productService.cacheService.InvalidateProductCachesBulk(reserved); cacheErr != nil {
+		os.logger.Warn("Failed to invalidate caches of purchased products", gecho.Field("error", cacheErr), gecho.Field("order_id", orderId))
 	}
-	os.logger.Info("All purchased products deactivated successfully")
 
-	// Send order confirmation email asynchronously (using original unencrypted data)
-	go func() {
-		emailErr := os.emailService.SendOrderConfirmationEmail(req.Email, req.Name, orderNumber, orderLines, &tables.Address{
-			Street:     req.Street,
-			HouseNo:    req.HouseNo,
-			PostalCode: req.PostalCode,
-			City:       req.City,
-			Country:    req.Country,
-		})
-		if emailErr != nil {
-			os.logger.Error("Failed to send order confirmation email",
-				gecho.Field("error", emailErr),
-				gecho.Field("order_id", orderId),
-				gecho.Field("email", req.Email))
-		} else {
-			os.logger.Info("Order confirmation email sent",
-				gecho.Field("order_id", orderId),
-				gecho.Field("order_number", orderNumber))
+	if userId != nil {
+		if cacheErr := os.productService.cacheService.InvalidateUserOrderSummary(*userId); cacheErr != nil {
+			os.logger.Warn("Failed to invalidate order summary cache", gecho.Field("error", cacheErr), gecho.Field("user_id", *userId))
 		}
-	}()
+	}
 
 	os.logger.Info("Order created successfully",
 		gecho.Field("order_id", orderId),
 		gecho.Field("order_number", orderNumber))
 
 	// Return data all unencrypted!
-	return &CreatedOrder{
+	created := &CreatedOrder{
 		Order: &tables.Order{
 			Id:            orderId,
 			OrderNumber:   orderNumber,
@@ -332,6 +351,9 @@ func (os *OrderService) CreateOrderFromRequest(ctx context.Context, req *structs
 			PaymentLink:   "",
 			PaymentStatus: tables.PaymentStatusUnpaid,
 			Status:        tables.OrderStatusPending,
+			ShippingCents: uint64(os.cfg.Orders.ShippingCents),
+			Total:         order.Total,
+			Currency:      currency,
 			CreatedAt:     time.Now(),
 			UpdatedAt:     time.Now(),
 		},
@@ -343,7 +365,150 @@ func (os *OrderService) CreateOrderFromRequest(ctx context.Context, req *structs
 			City:       req.City,
 			Country:    req.Country,
 		},
-	}, nil
+	}
+
+	// Queue the order confirmation email (using original unencrypted data)
+	if emailErr := os.emailService.SendOrderConfirmation(created.Order, created.OrderLines, created.Address); emailErr != nil {
+		os.logger.Error("Failed to queue order confirmation email",
+			gecho.Field("error", emailErr),
+			gecho.Field("order_id", orderId),
+			gecho.Field("email", req.Email))
+	} else {
+		os.logger.Info("Order confirmation email queued",
+			gecho.Field("order_id", orderId),
+			gecho.Field("order_number", orderNumber))
+	}
+
+	// Let the admins know, best-effort
+	go func() {
+		if emailErr := os.emailService.SendNewOrderAdminNotification(created.Order, created.OrderLines); emailErr != nil {
+			os.logger.Error("Failed to queue new order admin notification",
+				gecho.Field("error", emailErr),
+				gecho.Field("order_id", orderId))
+		}
+	}()
+
+	return created, nil
+}
+
+// insertOrder inserts the order, drawing a new order number when the current one is already taken.
+// Each attempt runs in a savepoint so a collision does not abort the surrounding transaction
+func (os *OrderService) insertOrder(ctx context.Context, tx bun.Tx, order *tables.Order) error {
+	for attempt := 1; ; attempt++ {
+		os.logger.Info("Inserting order",
+			gecho.Field("order_id", order.Id),
+			gecho.Field("order_number", order.OrderNumber))
+
+		if _, err := tx.ExecContext(ctx, "SAVEPOINT insert_order"); err != nil {
+			return lib.MapPgError(err)
+		}
+		_, err := tx.NewInsert().Model(order).Exec(ctx)
+		if err == nil {
+			if _, err := tx.ExecContext(ctx, "RELEASE SAVEPOINT insert_order"); err != nil {
+				return lib.MapPgError(err)
+			}
+			return nil
+		}
+
+		err = lib.MapPgError(err)
+		if !lib.IsOrderNumberCollision(err) || attempt >= lib.MaxOrderNumberAttempts {
+			return err
+		}
+		if _, rbErr := tx.ExecContext(ctx, "ROLLBACK TO SAVEPOINT insert_order"); rbErr != nil {
+			return lib.MapPgError(rbErr)
+		}
+
+		os.logger.Warn("Order number already taken, generating a new one",
+			gecho.Field("order_id", order.Id),
+			gecho.Field("order_number", order.OrderNumber),
+			gecho.Field("attempt", attempt))
+		order.OrderNumber = lib.GenerateOrderNumber()
+	}
+}
+
+// checkLineQuantities rejects orders with a line above the configured maximum quantity
+func (os *OrderService) checkLineQuantities(products map[string]*tables.Product, quantities map[string]int) error {
+	maxQuantity := os.cfg.Orders.MaxLineQuantity
+	if maxQuantity <= 0 {
+		return nil
+	}
+
+	for idStr, quantity := range quantities {
+		if quantity <= maxQuantity {
+			continue
+		}
+		product := products[idStr]
+		os.logger.Warn("Order rejected for review: line quantity above limit",
+			gecho.Field("product_id", product.ID),
+			gecho.Field("quantity", quantity),
+			gecho.Field("max_quantity", maxQuantity))
+		return &lib.OrderLimitError{Limit: lib.OrderLimitLineQuantity, Max: uint64(maxQuantity), Actual: uint64(quantity), ProductId: &product.ID}
+	}
+
+	return nil
+}
+
+// BuildOrderLines snapshots the current pricing of each product onto a new order line and returns the lines
+// with the order total (the sum of the line totals, shipping excluded). quantities maps product IDs to quantities
+func (os *OrderService) BuildOrderLines(orderId uuid.UUID, products map[uuid.UUID]*tables.Product, quantities map[string]int) ([]*tables.OrderLine, uint64) {
+	orderLines := make([]*tables.OrderLine, 0, len(quantities))
+	var total uint64
+	for idStr, quantity := range quantities {
+		id, err := uuid.Parse(idStr)
+		if err != nil {
+			continue
+		}
+		product, ok := products[id]
+		if !ok {
+			continue
+		}
+
+		lineTotal := product.Subtotal * uint64(quantity)
+		total += lineTotal
+
+		orderLines = append(orderLines, &tables.OrderLine{
+			Id:           uuid.New(),
+			OrderId:      orderId,
+			ProductId:    product.ID,
+			Quantity:     quantity,
+			UnitPrice:    product.Price,
+			UnitDiscount: product.Discount,
+			UnitTax:      product.Tax,
+			UnitSubtotal: product.Subtotal,
+			LineTotal:    lineTotal,
+			ProductName:  product.Name,
+			ProductSKU:   product.SKU,
+		})
+	}
+
+	return orderLines, total
+}
+
+// resolveOrderCurrency returns the currency shared by all products, rejecting mixed-currency carts
+// Products without a currency are treated as the configured default currency
+func (os *OrderService) resolveOrderCurrency(products []*tables.Product) (string, error) {
+	currency := ""
+	for _, product := range products {
+		productCurrency := product.Currency
+		if productCurrency == "" {
+			productCurrency = os.cfg.Orders.DefaultCurrency
+		}
+
+		if currency == "" {
+			currency = productCurrency
+		} else if productCurrency != currency {
+			os.logger.Warn("Rejected order with mixed currencies",
+				gecho.Field("currency", currency),
+				gecho.Field("other_currency", productCurrency),
+				gecho.Field("product_id", product.ID))
+			return "", lib.ErrMixedCurrencies
+		}
+	}
+
+	if currency == "" {
+		currency = os.cfg.Orders.DefaultCurrency
+	}
+	return currency, nil
 }
 
 // GetOrderById retrieves an order by ID with decrypted PII
@@ -461,8 +626,62 @@ func (os *OrderService) GetOrderByOrderNumber(ctx context.Context, orderNumber s
 	return order, nil
 }
 
-// GetAllOrders retrieves all orders with optional filtering
-func (os *OrderService) GetAllOrders(ctx context.Context, status *tables.OrderStatus, paymentStatus *tables.PaymentStatus, limit, offset int) ([]*tables.Order, int, error) {
+// LookupOrder finds an order by order number for a customer without an account, who proves ownership with the order email.
+// A wrong email and an unknown number both return lib.ErrNotFound so the lookup cannot be used to enumerate orders
+func (os *OrderService) LookupOrder(ctx context.Context, orderNumber, email string) (*tables.Order, *tables.Address, error) {
+	order, err := os.GetOrderByOrderNumber(ctx, orderNumber)
+	if err != nil {
+		return nil, nil, err
+	}
+
+	// Anonymized orders have an empty email and never match
+	if order.Email == "" || !lib.SecureCompare([]byte(lib.NormalizeEmail(order.Email)), []byte(lib.NormalizeEmail(email))) {
+		return nil, nil, lib.ErrNotFound
+	}
+
+	address, err := os.GetAddressById(ctx, order.AddressId)
+	if err != nil {
+		return nil, nil, err
+	}
+
+	return order, address, nil
+}
+
+// OrderListResult is a page of orders with the standard pagination metadata
+type OrderListResult struct {
+	Orders     []*tables.Order     `json:"orders"`
+	Pagination database.Pagination `json:"pagination"`
+
+	Links *lib.PaginationLinks `json:"links,omitempty"` // Set by handlers when the client asks for links
+}
+
+// OrderSortFields lists the columns orders can be sorted by
+var OrderSortFields = map[string]bool{
+	"created_at":     true,
+	"updated_at":     true,
+	"order_number":   true,
+	"status":         true,
+	"payment_status": true,
+	"total":          true,
+}
+
+// GetAllOrders retrieves all orders with optional filtering, sorted by sortBy (newest first when empty)
+func (os *OrderService) GetAllOrders(ctx context.Context, status *tables.OrderStatus, paymentStatus *tables.PaymentStatus, page, pageSize int, sortBy, sortDirection string) (*OrderListResult, error) {
+	page, pageSize = lib.ClampPagination(page, pageSize)
+
+	if sortBy == "" {
+		sortBy = "created_at"
+	}
+	if sortDirection == "" {
+		sortDirection = "DESC"
+	}
+	if err := lib.ValidateSortField(sortBy, OrderSortFields); err != nil {
+		return nil, err
+	}
+	if err := lib.ValidateSortDirection(sortDirection); err != nil {
+		return nil, err
+	}
+
 	query := database.Query[tables.Order](os.db).
 		WhereRaw("deleted_at IS NULL")
 
@@ -477,17 +696,18 @@ func (os *OrderService) GetAllOrders(ctx context.Context, status *tables.OrderSt
 	// Get total count
 	count, err := query.Count(ctx)
 	if err != nil {
-		return nil, 0, lib.MapPgError(err)
+		return nil, lib.MapPgError(err)
 	}
 
-	// Get paginated results
+	// Get paginated results; the ID keeps the order stable between pages
 	orders, err := query.
-		OrderBy("created_at", database.DESC).
-		Limit(limit).
-		Offset(offset).
+		OrderBy(sortBy, database.OrderDirection(sortDirection)).
+		OrderBy("id", database.ASC).
+		Limit(pageSize).
+		Offset((page - 1) * pageSize).
 		All(ctx)
 	if err != nil {
-		return nil, 0, lib.MapPgError(err)
+		return nil, lib.MapPgError(err)
 	}
 
 	// Convert to pointer slice and decrypt sensitive fields
@@ -521,7 +741,63 @@ func (os *OrderService) GetAllOrders(ctx context.Context, status *tables.OrderSt
 		}
 	}
 
-	return result, count, nil
+	return &OrderListResult{
+		Orders:     result,
+		Pagination: database.NewPagination(page, pageSize, count),
+	}, nil
+}
+
+// GetUserOrders retrieves a page of a user's orders, newest first, with decrypted fields and without ownership checks
+func (os *OrderService) GetUserOrders(ctx context.Context, userId uuid.UUID, page, pageSize int) (*OrderListResult, error) {
+	page, pageSize = lib.ClampPagination(page, pageSize)
+
+	query := database.Query[tables.Order](os.db).
+		WhereRaw("address_id IN (SELECT id FROM addresses WHERE user_id = ?)", userId).
+		WhereRaw("deleted_at IS NULL")
+
+	count, err := query.Count(ctx)
+	if err != nil {
+		return nil, lib.MapPgError(err)
+	}
+
+	orders, err := query.
+		OrderBy("created_at", database.DESC).
+		Limit(pageSize).
+		Offset((page - 1) * pageSize).
+		All(ctx)
+	if err != nil {
+		return nil, lib.MapPgError(err)
+	}
+
+	result := make([]*tables.Order, len(orders))
+	for i := range orders {
+		result[i] = &orders[i]
+		os.decryptOrder(result[i])
+	}
+
+	return &OrderListResult{
+		Orders:     result,
+		Pagination: database.NewPagination(page, pageSize, count),
+	}, nil
+}
+
+// decryptOrder decrypts the customer fields of an order in place; fields that fail to decrypt are logged and left as is
+func (os *OrderService) decryptOrder(order *tables.Order) {
+	var err error
+	if order.Name, err = lib.Decrypt(order.Name, os.cfg.Encryption.Key); err != nil {
+		os.logger.Warn("Failed to decrypt name", gecho.Field("error", err), gecho.Field("order_id", order.Id))
+	}
+	if order.Email, err = lib.Decrypt(order.Email, os.cfg.Encryption.Key); err != nil {
+		os.logger.Error("Failed to decrypt email", gecho.Field("error", err), gecho.Field("order_id", order.Id))
+	}
+	if order.Phone, err = lib.Decrypt(order.Phone, os.cfg.Encryption.Key); err != nil {
+		os.logger.Error("Failed to decrypt phone", gecho.Field("error", err), gecho.Field("order_id", order.Id))
+	}
+	if order.Note != "" {
+		if order.Note, err = lib.Decrypt(order.Note, os.cfg.Encryption.Key); err != nil {
+			os.logger.Warn("Failed to decrypt note", gecho.Field("error", err), gecho.Field("order_id", order.Id))
+		}
+	}
 }
 
 // GetOrdersByUserId retrieves all orders for a specific user
@@ -595,6 +871,34 @@ func (os *OrderService) GetOrdersByUserId(ctx context.Context, userId uuid.UUID)
 	return result, nil
 }
 
+// GetOwnedOrder retrieves an order and its address, verifying the user owns it (via the address)
+// Depending on Orders.OwnershipPolicy a foreign order yields lib.ErrNotFound (uniform 404) or lib.ErrOrderNotOwned
+func (os *OrderService) GetOwnedOrder(ctx context.Context, orderId, userId uuid.UUID) (*tables.Order, *tables.Address, error) {
+	order, err := os.GetOrderById(ctx, orderId)
+	if err != nil {
+		return nil, nil, err
+	}
+
+	address, err := os.GetAddressById(ctx, order.AddressId)
+	if err != nil {
+		return nil, nil, err
+	}
+
+	if address.UserId == nil || *address.UserId != userId {
+		os.logger.Warn("User attempted to access order they don't own",
+			gecho.Field("user_id", userId),
+			gecho.Field("order_id", orderId),
+			gecho.Field("address_user_id", address.UserId),
+		)
+		if os.cfg.Orders.OwnershipPolicy == "forbidden" {
+			return nil, nil, lib.ErrOrderNotOwned
+		}
+		return nil, nil, lib.ErrNotFound
+	}
+
+	return order, address, nil
+}
+
 // GetOrderLinesByOrderId retrieves all order lines for an order
 func (os *OrderService) GetOrderLinesByOrderId(ctx context.Context, orderId uuid.UUID) ([]*tables.OrderLine, error) {
 	orderLines, err := database.Query[tables.OrderLine](os.db).
@@ -613,44 +917,114 @@ func (os *OrderService) GetOrderLinesByOrderId(ctx context.Context, orderId uuid
 	return result, nil
 }
 
+// GetOrderLinesWithProducts retrieves all order lines for an order with their product and primary image preloaded
+// The product is joined in the same query; images are loaded in a single follow-up query for all lines
+func (os *OrderService) GetOrderLinesWithProducts(ctx context.Context, orderId uuid.UUID) ([]*tables.OrderLine, error) {
+	orderLines, err := database.Query[tables.OrderLine](os.db).
+		Where("ol.order_id", orderId).
+		Relation("Product").
+		Relation("Product.Images", func(q *bun.SelectQuery) *bun.SelectQuery {
+			return q.Where("pi.is_primary = ?", true)
+		}).
+		All(ctx)
+	if err != nil {
+		return nil, lib.MapPgError(err)
+	}
+
+	// Convert slice to pointer slice
+	result := make([]*tables.OrderLine, len(orderLines))
+	for i := range orderLines {
+		result[i] = &orderLines[i]
+	}
+
+	return result, nil
+}
+
 // UpdateOrderStatus updates the order status with validation
-func (os *OrderService) UpdateOrderStatus(ctx context.Context, orderId uuid.UUID, newStatus tables.OrderStatus) error {
+// changedBy is the admin applying the change and is recorded in the status history
+func (os *OrderService) UpdateOrderStatus(ctx context.Context, orderId uuid.UUID, newStatus tables.OrderStatus, changedBy *uuid.UUID) error {
 	// Get current order
 	order, err := os.GetOrderById(ctx, orderId)
 	if err != nil {
 		return err
 	}
 
+	if !order.Status.CanTransitionTo(newStatus) {
+		return lib.ErrInvalidStatusTransition
+	}
+
 	// Update status
-	tx, err := os.db.BeginTx(ctx, nil)
+	err = database.Transaction(os.db, ctx, func(tx bun.Tx) error {
+		return os.applyStatusChange(ctx, tx, order, newStatus, changedBy)
+	})
 	if err != nil {
-		return lib.MapPgError(err)
+		return err
 	}
-	defer func() {
-		if p := recover(); p != nil {
-			tx.Rollback()
-			os.logger.Error(fmt.Sprintf("panic in UpdateOrderStatus: %v", p))
-		} else if err != nil {
-			tx.Rollback()
-		} else {
-			err = tx.Commit()
+
+	// Cancelled and refunded orders drop out of the owner's lifetime spend
+	os.invalidateOrderSummary(ctx, orderId)
+
+	os.logger.Info("Order status updated",
+		gecho.Field("order_id", orderId),
+		gecho.Field("old_status", order.Status),
+		gecho.Field("new_status", newStatus))
+
+	return nil
+}
+
+// StatusUpdateResult reports the outcome of a single order in a bulk status update
+type StatusUpdateResult struct {
+	OrderId uuid.UUID
+	Status  tables.OrderStatus
+	Err     error // nil when the order was updated
+}
+
+// BulkUpdateOrderStatus applies a status change to many orders at once
+// Each order is updated in its own transaction so one invalid transition does not block the rest
+func (os *OrderService) BulkUpdateOrderStatus(ctx context.Context, updates map[uuid.UUID]tables.OrderStatus, changedBy *uuid.UUID) []StatusUpdateResult {
+	results := make([]StatusUpdateResult, 0, len(updates))
+
+	for orderId, newStatus := range updates {
+		err := os.UpdateOrderStatus(ctx, orderId, newStatus, changedBy)
+		if err != nil && !errors.Is(err, lib.ErrInvalidStatusTransition) && !lib.IsNotFound(err) {
+			os.logger.Error("Failed to update order status in bulk",
+				gecho.Field("error", err),
+				gecho.Field("order_id", orderId))
 		}
-	}()
 
-	_, err = tx.NewUpdate().
+		results = append(results, StatusUpdateResult{OrderId: orderId, Status: newStatus, Err: err})
+	}
+
+	return results
+}
+
+// applyStatusChange moves the order to newStatus and writes a history entry within tx
+// The update is conditional on the status read earlier so a concurrent change is not overwritten
+func (os *OrderService) applyStatusChange(ctx context.Context, tx bun.Tx, order *tables.Order, newStatus tables.OrderStatus, changedBy *uuid.UUID) error {
+	res, err := tx.NewUpdate().
 		Model(&tables.Order{}).
 		Set("status = ?", newStatus).
 		Set("updated_at = ?", time.Now()).
-		Where("id = ?", orderId).
+		Where("id = ?", order.Id).
+		Where("status = ?", order.Status).
 		Exec(ctx)
 	if err != nil {
 		return lib.MapPgError(err)
 	}
+	if affected, _ := res.RowsAffected(); affected == 0 {
+		return lib.ErrInvalidStatusTransition
+	}
 
-	os.logger.Info("Order status updated",
-		gecho.Field("order_id", orderId),
-		gecho.Field("old_status", order.Status),
-		gecho.Field("new_status", newStatus))
+	history := &tables.OrderStatusHistory{
+		OrderId:    order.Id,
+		FromStatus: order.Status,
+		ToStatus:   newStatus,
+		ChangedBy:  changedBy,
+	}
+	_, err = tx.NewInsert().Model(history).Exec(ctx)
+	if err != nil {
+		return lib.MapPgError(err)
+	}
 
 	return nil
 }
@@ -663,6 +1037,11 @@ func (os *OrderService) AttachPaymentLink(ctx context.Context, orderId uuid.UUID
 		return err
 	}
 
+	// Its products are back in the shop, so the customer could pay for something sold to someone else
+	if order.ReservationReleasedAt != nil {
+		return lib.ErrReservationReleased
+	}
+
 	// Update payment link
 	tx, err := os.db.BeginTx(ctx, nil)
 	if err != nil {
@@ -679,65 +1058,250 @@ func (os *OrderService) AttachPaymentLink(ctx context.Context, orderId uuid.UUID
 		}
 	}()
 
-	_, err = tx.NewUpdate().
+	res, err := tx.NewUpdate().
 		Model(&tables.Order{}).
 		Set("payment_link = ?", paymentLink).
 		Set("updated_at = ?", time.Now()).
 		Where("id = ?", orderId).
+		Where("reservation_released_at IS NULL").
 		Exec(ctx)
 	if err != nil {
 		return lib.MapPgError(err)
 	}
+	if affected, _ := res.RowsAffected(); affected == 0 {
+		err = lib.ErrReservationReleased
+		return err
+	}
 
-	// Send payment link email asynchronously
-	go func() {
-		emailErr := os.emailService.SendPaymentLinkEmail(order.Email, order.Name, order.OrderNumber, paymentLink)
-		if emailErr != nil {
-			os.logger.Error("Failed to send payment link email",
-				gecho.Field("error", emailErr),
-				gecho.Field("order_id", orderId),
-				gecho.Field("email", order.Email))
-		} else {
-			os.logger.Info("Payment link email sent",
-				gecho.Field("order_id", orderId),
-				gecho.Field("order_number", order.OrderNumber))
+	// Queue the payment link email
+	emailErr := os.emailService.SendPaymentLinkEmail(order.Email, order.Name, order.OrderNumber, paymentLink)
+	if emailErr != nil {
+		os.logger.Error("Failed to queue payment link email",
+			gecho.Field("error", emailErr),
+			gecho.Field("order_id", orderId),
+			gecho.Field("email", order.Email))
+	} else {
+		os.logger.Info("Payment link email queued",
+			gecho.Field("order_id", orderId),
+			gecho.Field("order_number", order.OrderNumber))
+	}
+
+	return nil
+}
+
+// MarkOrderAsPaid marks an order as paid and records the status change like UpdateOrderStatus does
+func (os *OrderService) MarkOrderAsPaid(ctx context.Context, orderId uuid.UUID, changedBy *uuid.UUID) error {
+	err := database.Transaction(os.db, ctx, func(tx bun.Tx) error {
+		order, err := os.lockOrder(ctx, tx, orderId)
+		if err != nil {
+			return err
 		}
-	}()
+
+		// A released order no longer holds its products, they may have been sold to someone else since
+		if order.ReservationReleasedAt != nil {
+			return lib.ErrReservationReleased
+		}
+		if !order.Status.CanTransitionTo(tables.OrderStatusPaid) {
+			return lib.ErrInvalidStatusTransition
+		}
+
+		_, err = tx.NewUpdate().
+			Model(&tables.Order{}).
+			Set("payment_status = ?", tables.PaymentStatusPaid).
+			Where("id = ?", orderId).
+			Exec(ctx)
+		if err != nil {
+			return lib.MapPgError(err)
+		}
+
+		return os.applyStatusChange(ctx, tx, order, tables.OrderStatusPaid, changedBy)
+	})
+	if err != nil {
+		return err
+	}
+
+	os.logger.Info("Order marked as paid", gecho.Field("order_id", orderId))
 
 	return nil
 }
 
-// MarkOrderAsPaid marks an order as paid
-func (os *OrderService) MarkOrderAsPaid(ctx context.Context, orderId uuid.UUID) error {
-	tx, err := os.db.BeginTx(ctx, nil)
+// UpdateOrderEmail corrects the customer email of an order that has not shipped yet and records the change.
+// With resend the order confirmation is sent again to the new address once the change is committed
+func (os *OrderService) UpdateOrderEmail(ctx context.Context, orderId uuid.UUID, email string, resend bool, changedBy *uuid.UUID) (*tables.Order, error) {
+	encryptedEmail, err := lib.Encrypt(email, os.cfg.Encryption.Key)
 	if err != nil {
-		return lib.MapPgError(err)
+		return nil, err
 	}
-	defer func() {
-		if p := recover(); p != nil {
-			tx.Rollback()
-			os.logger.Error(fmt.Sprintf("panic in MarkOrderAsPaid: %v", p))
-		} else if err != nil {
-			tx.Rollback()
-		} else {
-			err = tx.Commit()
+
+	err = database.Transaction(os.db, ctx, func(tx bun.Tx) error {
+		order, err := os.lockEditableOrder(ctx, tx, orderId)
+		if err != nil {
+			return err
 		}
-	}()
 
-	_, err = tx.NewUpdate().
-		Model(&tables.Order{}).
-		Set("payment_status = ?", tables.PaymentStatusPaid).
-		Set("status = ?", tables.OrderStatusPaid).
-		Set("updated_at = ?", time.Now()).
-		Where("id = ?", orderId).
-		Exec(ctx)
+		_, err = tx.NewUpdate().
+			Model((*tables.Order)(nil)).
+			Set("email = ?", encryptedEmail).
+			Set("updated_at = ?", time.Now()).
+			Where("id = ?", orderId).
+			Exec(ctx)
+		if err != nil {
+			return lib.MapPgError(err)
+		}
+
+		change := &tables.OrderEmailChange{
+			OrderId:   orderId,
+			FromEmail: order.Email,
+			ToEmail:   encryptedEmail,
+			Resent:    resend,
+			ChangedBy: changedBy,
+		}
+		_, err = tx.NewInsert().Model(change).Exec(ctx)
+		if err != nil {
+			return lib.MapPgError(err)
+		}
+
+		return nil
+	})
 	if err != nil {
-		return lib.MapPgError(err)
+		return nil, err
 	}
 
-	os.logger.Info("Order marked as paid", gecho.Field("order_id", orderId))
+	order, err := os.GetOrderById(ctx, orderId)
+	if err != nil {
+		return nil, err
+	}
 
-	return nil
+	os.logger.Info("Order email updated",
+		gecho.Field("order_id", orderId),
+		gecho.Field("resend", resend))
+
+	if resend {
+		os.resendOrderConfirmation(*order)
+	}
+
+	return order, nil
+}
+
+// ApplyOrderDiscount sets a manual discount on an unpaid order that has not shipped yet and records it with a reason.
+// The discount replaces any earlier one; percent discounts are converted to cents against the current subtotal
+func (os *OrderService) ApplyOrderDiscount(ctx context.Context, orderId uuid.UUID, kind tables.OrderAdjustmentKind, value uint64, reason string, adjustedBy *uuid.UUID) (*tables.Order, error) {
+	var discount uint64
+
+	err := database.Transaction(os.db, ctx, func(tx bun.Tx) error {
+		order, err := os.lockEditableOrder(ctx, tx, orderId)
+		if err != nil {
+			if errors.Is(err, lib.ErrOrderNotEditable) {
+				return lib.ErrOrderNotAdjustable
+			}
+			return err
+		}
+		if order.PaymentStatus != tables.PaymentStatusUnpaid {
+			return lib.ErrOrderNotAdjustable
+		}
+
+		var subtotal uint64
+		err = tx.NewSelect().
+			Model((*tables.OrderLine)(nil)).
+			ColumnExpr("coalesce(sum(line_total), 0)").
+			Where("order_id = ?", orderId).
+			Scan(ctx, &subtotal)
+		if err != nil {
+			return lib.MapPgError(err)
+		}
+
+		switch kind {
+		case tables.OrderAdjustmentPercent:
+			if value > 100 {
+				return lib.ErrInvalidOrderDiscount
+			}
+			discount = subtotal * value / 100
+		default:
+			if value > subtotal {
+				return lib.ErrInvalidOrderDiscount
+			}
+			discount = value
+		}
+
+		_, err = tx.NewUpdate().
+			Model((*tables.Order)(nil)).
+			Set("discount_cents = ?", discount).
+			Where("id = ?", orderId).
+			Exec(ctx)
+		if err != nil {
+			return lib.MapPgError(err)
+		}
+
+		if err := updateOrderTotal(ctx, tx, orderId); err != nil {
+			return err
+		}
+
+		adjustment := &tables.OrderAdjustment{
+			OrderId:       orderId,
+			Kind:          kind,
+			Value:         value,
+			DiscountCents: discount,
+			Reason:        reason,
+			AdjustedBy:    adjustedBy,
+		}
+		_, err = tx.NewInsert().Model(adjustment).Exec(ctx)
+		if err != nil {
+			return lib.MapPgError(err)
+		}
+
+		return nil
+	})
+	if err != nil {
+		return nil, err
+	}
+	os.invalidateOrderSummary(ctx, orderId)
+
+	order, err := os.GetOrderById(ctx, orderId)
+	if err != nil {
+		return nil, err
+	}
+
+	os.logger.Info("Order discount applied",
+		gecho.Field("order_id", orderId),
+		gecho.Field("kind", kind),
+		gecho.Field("value", value),
+		gecho.Field("discount_cents", discount))
+
+	return order, nil
+}
+
+// resendOrderConfirmation sends the order confirmation again in the background, reading lines and address from the database
+func (os *OrderService) resendOrderConfirmation(order tables.Order) {
+	go func() {
+		ctx := context.Background()
+
+		orderLines, err := os.GetOrderLinesByOrderId(ctx, order.Id)
+		if err != nil {
+			os.logger.Error("Failed to load order lines for confirmation resend",
+				gecho.Field("error", err),
+				gecho.Field("order_id", order.Id))
+			return
+		}
+
+		address, err := os.GetAddressById(ctx, order.AddressId)
+		if err != nil {
+			os.logger.Error("Failed to load address for confirmation resend",
+				gecho.Field("error", err),
+				gecho.Field("order_id", order.Id))
+			return
+		}
+
+		if err := os.emailService.SendOrderConfirmation(&order, orderLines, address); err != nil {
+			os.logger.Error("Failed to queue order confirmation resend",
+				gecho.Field("error", err),
+				gecho.Field("order_id", order.Id))
+			return
+		}
+
+		os.logger.Info("Order confirmation resend queued",
+			gecho.Field("order_id", order.Id),
+			gecho.Field("order_number", order.OrderNumber))
+	}()
 }
 
 // SoftDeleteOrder soft deletes an order
@@ -837,3 +1401,699 @@ func (os *OrderService) GetUserAddresses(ctx context.Context, userId uuid.UUID)
 
 	return result, nil
 }
+
+// GetExpiredPendingOrders retrieves unpaid pending orders older than cutoff that still hold their products
+func (os *OrderService) GetExpiredPendingOrders(ctx context.Context, cutoff time.Time, limit int) ([]tables.Order, error) {
+	orders, err := database.Query[tables.Order](os.db).
+		Where("status", tables.OrderStatusPending).
+		Where("payment_status", tables.PaymentStatusUnpaid).
+		WhereOp("created_at", "<", cutoff).
+		WhereNull("reservation_released_at").
+		WhereRaw("deleted_at IS NULL").
+		OrderBy("created_at", database.ASC).
+		Limit(limit).
+		All(ctx)
+	if err != nil {
+		return nil, lib.MapPgError(err)
+	}
+
+	return orders, nil
+}
+
+// ReleaseOrderReservation hands the products held by an unpaid pending order back to the shop and optionally
+// cancels it, recording the cancellation in the status history. A released order can no longer be paid or sent a
+// payment link. It returns the released product IDs, or nil when the order was paid, cancelled or released concurrently
+func (os *OrderService) ReleaseOrderReservation(ctx context.Context, orderId uuid.UUID, cancel bool) ([]uuid.UUID, error) {
+	var released []uuid.UUID
+	err := database.Transaction(os.db, ctx, func(tx bun.Tx) error {
+		released = nil
+
+		// Lock the order so a concurrent payment waits for the release, or the release sees the payment
+		order, err := os.lockOrder(ctx, tx, orderId)
+		if err != nil {
+			return err
+		}
+		if order.Status != tables.OrderStatusPending || order.PaymentStatus != tables.PaymentStatusUnpaid || order.ReservationReleasedAt != nil {
+			return nil
+		}
+
+		_, err = tx.NewUpdate().
+			Model((*tables.Order)(nil)).
+			Set("reservation_released_at = ?", time.Now()).
+			Set("updated_at = ?", time.Now()).
+			Where("id = ?", orderId).
+			Exec(ctx)
+		if err != nil {
+			return lib.MapPgError(err)
+		}
+
+		if cancel {
+			if err := os.applyStatusChange(ctx, tx, order, tables.OrderStatusCancelled, nil); err != nil {
+				return err
+			}
+		}
+
+		productIds, err := releaseReservedProducts(ctx, tx, orderId, nil)
+		if err != nil {
+			return err
+		}
+		released = append([]uuid.UUID{}, productIds...)
+		return nil
+	})
+	if err != nil {
+		return nil, err
+	}
+	if cancel && released != nil {
+		os.invalidateOrderSummary(ctx, orderId)
+	}
+
+	return released, nil
+}
+
+// releaseReservedProducts reactivates the products the order deactivated when it was placed, or only productId
+// when set, and returns their IDs. Products an admin edited since no longer point at the order and stay as they are
+func releaseReservedProducts(ctx context.Context, tx bun.Tx, orderId uuid.UUID, productId *uuid.UUID) ([]uuid.UUID, error) {
+	update := tx.NewUpdate().
+		Model((*tables.Product)(nil)).
+		Set("is_active = ?", true).
+		Set("reserved_order_id = NULL").
+		Set("updated_at = ?", time.Now()).
+		Where("reserved_order_id = ?", orderId)
+	if productId != nil {
+		update = update.Where("id = ?", *productId)
+	}
+
+	var productIds []uuid.UUID
+	if _, err := update.Returning("id").Exec(ctx, &productIds); err != nil {
+		return nil, lib.MapPgError(err)
+	}
+	return productIds, nil
+}
+
+// ReleaseExpiredReservations releases the products of unpaid pending orders older than the configured TTL
+// It processes orders in batches and returns the number of orders released
+func (os *OrderService) ReleaseExpiredReservations(ctx context.Context) (int, error) {
+	cutoff := time.Now().Add(-os.cfg.Orders.PendingTTL)
+	batchSize := os.cfg.Orders.SweepBatchSize
+	cancel := os.cfg.Orders.CancelExpired
+	releasedCount := 0
+
+	for {
+		orders, err := os.GetExpiredPendingOrders(ctx, cutoff, batchSize)
+		if err != nil {
+			return releasedCount, err
+		}
+
+		batchReleased := 0
+		var releasedProducts []uuid.UUID
+		for _, order := range orders {
+			productIds, err := os.ReleaseOrderReservation(ctx, order.Id, cancel)
+			if err != nil {
+				os.logger.Error("Failed to release order reservation",
+					gecho.Field("error", err),
+					gecho.Field("order_id", order.Id))
+				continue
+			}
+			if productIds == nil {
+				continue
+			}
+			batchReleased++
+			releasedProducts = append(releasedProducts, productIds...)
+
+			os.logger.Info("Released reservation for expired pending order",
+				gecho.Field("order_id", order.Id),
+				gecho.Field("order_number", order.OrderNumber),
+				gecho.Field("products", len(productIds)),
+				gecho.Field("cancelled", cancel))
+
+			if cancel {
+				os.notifyOrderCancelled(order)
+			}
+		}
+		releasedCount += batchReleased
+
+		// One invalidation per batch instead of one per order
+		if cacheErr := os.productService.cacheService.InvalidateProductCachesBulk(releasedProducts); cacheErr != nil {
+			os.logger.Warn("Failed to invalidate product caches after releasing reservations",
+				gecho.Field("error", cacheErr),
+				gecho.Field("products", len(releasedProducts)))
+		}
+
+		// Stop when the last batch was partial or nothing could be released (avoids spinning on failures)
+		if len(orders) < batchSize || batchReleased == 0 {
+			break
+		}
+	}
+
+	return releasedCount, nil
+}
+
+// notifyOrderCancelled sends the cancellation email for an order asynchronously
+func (os *OrderService) notifyOrderCancelled(order tables.Order) {
+	go func() {
+		email, err := lib.Decrypt(order.Email, os.cfg.Encryption.Key)
+		if err != nil {
+			os.logger.Error("Failed to decrypt email for cancellation notice", gecho.Field("error", err), gecho.Field("order_id", order.Id))
+			return
+		}
+		name, err := lib.Decrypt(order.Name, os.cfg.Encryption.Key)
+		if err != nil {
+			os.logger.Warn("Failed to decrypt name for cancellation notice", gecho.Field("error", err), gecho.Field("order_id", order.Id))
+			name = ""
+		}
+
+		if err := os.emailService.SendOrderCancelledEmail(email, name, order.OrderNumber); err != nil {
+			os.logger.Error("Failed to queue order cancellation email",
+				gecho.Field("error", err),
+				gecho.Field("order_id", order.Id))
+		}
+	}()
+}
+
+// isOrderEditable reports whether the lines of an order may still be changed (nothing has shipped yet)
+func isOrderEditable(status tables.OrderStatus) bool {
+	switch status {
+	case tables.OrderStatusPending, tables.OrderStatusPaid, tables.OrderStatusProcessing:
+		return true
+	default:
+		return false
+	}
+}
+
+// lockOrder locks the order row for the rest of tx and returns it
+func (os *OrderService) lockOrder(ctx context.Context, tx bun.Tx, orderId uuid.UUID) (*tables.Order, error) {
+	order := new(tables.Order)
+	err := tx.NewSelect().
+		Model(order).
+		Where("id = ?", orderId).
+		Where("deleted_at IS NULL").
+		For("UPDATE").
+		Scan(ctx)
+	if err != nil {
+		return nil, lib.MapPgError(err)
+	}
+
+	return order, nil
+}
+
+// lockEditableOrder locks the order row for the rest of tx and checks that its lines may still be changed
+func (os *OrderService) lockEditableOrder(ctx context.Context, tx bun.Tx, orderId uuid.UUID) (*tables.Order, error) {
+	order, err := os.lockOrder(ctx, tx, orderId)
+	if err != nil {
+		return nil, err
+	}
+
+	if !isOrderEditable(order.Status) {
+		return nil, lib.ErrOrderNotEditable
+	}
+
+	return order, nil
+}
+
+// UpdateOrderLineQuantity changes the quantity of an order line before shipment, recomputes the order total and
+// records the change. The new quantity must be in stock and stay within the order limits
+func (os *OrderService) UpdateOrderLineQuantity(ctx context.Context, orderId, lineId uuid.UUID, quantity int, changedBy *uuid.UUID) (*tables.OrderLine, error) {
+	var line *tables.OrderLine
+	var oldQuantity int
+	err := database.Transaction(os.db, ctx, func(tx bun.Tx) error {
+		if _, err := os.lockEditableOrder(ctx, tx, orderId); err != nil {
+			return err
+		}
+
+		line = new(tables.OrderLine)
+		err := tx.NewSelect().
+			Model(line).
+			Where("id = ?", lineId).
+			Where("order_id = ?", orderId).
+			Scan(ctx)
+		if err != nil {
+			return lib.MapPgError(err)
+		}
+		oldQuantity = line.Quantity
+
+		if maxQuantity := os.cfg.Orders.MaxLineQuantity; maxQuantity > 0 && quantity > maxQuantity {
+			return &lib.OrderLimitError{Limit: lib.OrderLimitLineQuantity, Max: uint64(maxQuantity), Actual: uint64(quantity), ProductId: &line.ProductId}
+		}
+		if quantity > oldQuantity {
+			if err := os.checkLineStock(ctx, tx, orderId, line, quantity); err != nil {
+				return err
+			}
+		}
+
+		line.Quantity = quantity
+		line.LineTotal = uint64(quantity) * line.UnitSubtotal
+
+		_, err = tx.NewUpdate().
+			Model(line).
+			Column("quantity", "line_total").
+			WherePK().
+			Exec(ctx)
+		if err != nil {
+			return lib.MapPgError(err)
+		}
+
+		if err := os.checkOrderTotal(ctx, tx, orderId); err != nil {
+			return err
+		}
+		if err := updateOrderTotal(ctx, tx, orderId); err != nil {
+			return err
+		}
+
+		return recordLineChange(ctx, tx, line, oldQuantity, quantity, changedBy)
+	})
+	if err != nil {
+		return nil, err
+	}
+	os.invalidateOrderSummary(ctx, orderId)
+
+	os.logger.Info("Order line quantity updated",
+		gecho.Field("order_id", orderId),
+		gecho.Field("line_id", lineId),
+		gecho.Field("old_quantity", oldQuantity),
+		gecho.Field("new_quantity", quantity))
+
+	return line, nil
+}
+
+// checkLineStock locks the line's product and checks that it can supply quantity. A one-of-a-kind product
+// reserved by this order is held by it, so it counts as available even though it is inactive
+func (os *OrderService) checkLineStock(ctx context.Context, tx bun.Tx, orderId uuid.UUID, line *tables.OrderLine, quantity int) error {
+	product := new(tables.Product)
+	err := tx.NewSelect().
+		Model(product).
+		Where("id = ?", line.ProductId).
+		For("UPDATE").
+		Scan(ctx)
+	if err != nil {
+		return lib.MapPgError(err)
+	}
+
+	available := line.Quantity
+	heldByOrder := product.ReservedOrderId != nil && *product.ReservedOrderId == orderId
+	if product.IsActive || heldByOrder {
+		available = max(available, product.AvailableQuantity(quantity))
+	}
+	if quantity > available {
+		return &lib.InsufficientStockError{ProductId: product.ID, Requested: quantity, Available: available}
+	}
+
+	return nil
+}
+
+// checkOrderTotal rejects a line edit that takes the sum of the line totals above the configured maximum,
+// the same limit CreateOrder applies
+func (os *OrderService) checkOrderTotal(ctx context.Context, tx bun.Tx, orderId uuid.UUID) error {
+	maxTotal := os.cfg.Orders.MaxOrderTotal
+	if maxTotal == 0 {
+		return nil
+	}
+
+	var total uint64
+	err := tx.NewSelect().
+		Model((*tables.OrderLine)(nil)).
+		ColumnExpr("coalesce(sum(line_total), 0)").
+		Where("order_id = ?", orderId).
+		Scan(ctx, &total)
+	if err != nil {
+		return lib.MapPgError(err)
+	}
+	if total > maxTotal {
+		return &lib.OrderLimitError{Limit: lib.OrderLimitTotal, Max: maxTotal, Actual: total}
+	}
+
+	return nil
+}
+
+// recordLineChange writes the audit entry for an order line edit within tx
+func recordLineChange(ctx context.Context, tx bun.Tx, line *tables.OrderLine, fromQuantity, toQuantity int, changedBy *uuid.UUID) error {
+	change := &tables.OrderLineChange{
+		OrderId:      line.OrderId,
+		LineId:       line.Id,
+		ProductId:    line.ProductId,
+		FromQuantity: fromQuantity,
+		ToQuantity:   toQuantity,
+		ChangedBy:    changedBy,
+	}
+	if _, err := tx.NewInsert().Model(change).Exec(ctx); err != nil {
+		return lib.MapPgError(err)
+	}
+	return nil
+}
+
+// DeleteOrderLine removes a line from an order before shipment and makes its product available again
+func (os *OrderService) DeleteOrderLine(ctx context.Context, orderId, lineId uuid.UUID, changedBy *uuid.UUID) error {
+	productId, err := os.deleteOrderLine(ctx, orderId, lineId, changedBy)
+	if err != nil {
+		return err
+	}
+	os.invalidateOrderSummary(ctx, orderId)
+
+	if cacheErr := os.productService.cacheService.InvalidateProductCaches(productId); cacheErr != nil {
+		os.logger.Warn("Failed to invalidate product cache after removing order line",
+			gecho.Field("error", cacheErr),
+			gecho.Field("product_id", productId))
+	}
+
+	os.logger.Info("Order line removed",
+		gecho.Field("order_id", orderId),
+		gecho.Field("line_id", lineId),
+		gecho.Field("product_id", productId))
+
+	return nil
+}
+
+// deleteOrderLine deletes the line, reactivates its product and records the removal in one transaction,
+// returning the product ID
+func (os *OrderService) deleteOrderLine(ctx context.Context, orderId, lineId uuid.UUID, changedBy *uuid.UUID) (uuid.UUID, error) {
+	var productId uuid.UUID
+	err := database.Transaction(os.db, ctx, func(tx bun.Tx) error {
+		if _, err := os.lockEditableOrder(ctx, tx, orderId); err != nil {
+			return err
+		}
+
+		lineCount, err := tx.NewSelect().
+			Model((*tables.OrderLine)(nil)).
+			Where("order_id = ?", orderId).
+			Count(ctx)
+		if err != nil {
+			return lib.MapPgError(err)
+		}
+
+		line := new(tables.OrderLine)
+		_, err = tx.NewDelete().
+			Model(line).
+			Where("id = ?", lineId).
+			Where("order_id = ?", orderId).
+			Returning("*").
+			Exec(ctx)
+		if err != nil {
+			return lib.MapPgError(err)
+		}
+		if line.Id == uuid.Nil {
+			return lib.ErrNotFound
+		}
+		if lineCount <= 1 {
+			// Cancelling the order is the way to drop everything
+			return lib.ErrLastOrderLine
+		}
+
+		// Products are reserved by deactivating them on purchase, so hand this one back to the shop.
+		// Made-to-order products were never deactivated and products an admin hid since are left as they are
+		if _, err := releaseReservedProducts(ctx, tx, orderId, &line.ProductId); err != nil {
+			return err
+		}
+
+		if err := updateOrderTotal(ctx, tx, orderId); err != nil {
+			return err
+		}
+
+		productId = line.ProductId
+		return recordLineChange(ctx, tx, line, line.Quantity, 0, changedBy)
+	})
+	if err != nil {
+		return uuid.Nil, err
+	}
+
+	return productId, nil
+}
+
+// updateOrderTotal recomputes the stored order total from its lines and discount after they were changed within tx
+func updateOrderTotal(ctx context.Context, tx bun.Tx, orderId uuid.UUID) error {
+	_, err := tx.NewUpdate().
+		Model((*tables.Order)(nil)).
+		Set("total = greatest((SELECT coalesce(sum(ol.line_total), 0) FROM order_lines AS ol WHERE ol.order_id = ?) - discount_cents, 0)", orderId).
+		Set("updated_at = ?", time.Now()).
+		Where("id = ?", orderId).
+		Exec(ctx)
+	if err != nil {
+		return lib.MapPgError(err)
+	}
+	return nil
+}
+
+// AnonymizeExpiredGuestOrders wipes the customer data of delivered or cancelled guest orders older than the retention period
+// Financial data (lines, totals, status) is kept. It processes orders in batches and returns the number anonymized
+func (os *OrderService) AnonymizeExpiredGuestOrders(ctx context.Context) (int, error) {
+	cutoff := time.Now().Add(-os.cfg.Orders.GuestRetention)
+	batchSize := os.cfg.Orders.AnonymizeBatchSize
+	anonymizedCount := 0
+
+	for {
+		var orders []tables.Order
+		err := os.db.NewSelect().
+			Model(&orders).
+			Column("o.id", "o.address_id").
+			Join("JOIN addresses AS a ON a.id = o.address_id").
+			Where("a.user_id IS NULL").
+			Where("o.status IN (?)", bun.In([]tables.OrderStatus{tables.OrderStatusDelivered, tables.OrderStatusCancelled})).
+			Where("o.anonymized_at IS NULL").
+			Where("o.created_at < ?", cutoff).
+			OrderExpr("o.created_at ASC").
+			Limit(batchSize).
+			Scan(ctx)
+		if err != nil {
+			return anonymizedCount, lib.MapPgError(err)
+		}
+		if len(orders) == 0 {
+			break
+		}
+
+		if err := os.anonymizeOrders(ctx, orders); err != nil {
+			return anonymizedCount, err
+		}
+		anonymizedCount += len(orders)
+
+		if len(orders) < batchSize {
+			break
+		}
+	}
+
+	return anonymizedCount, nil
+}
+
+// anonymizeOrders clears the customer data of the given orders and their guest addresses in one transaction
+func (os *OrderService) anonymizeOrders(ctx context.Context, orders []tables.Order) error {
+	orderIds := make([]uuid.UUID, len(orders))
+	addressIds := make([]uuid.UUID, len(orders))
+	for i, order := range orders {
+		orderIds[i] = order.Id
+		addressIds[i] = order.AddressId
+	}
+
+	return database.Transaction(os.db, ctx, func(tx bun.Tx) error {
+		now := time.Now()
+
+		// Empty strings decrypt to empty strings, so reads keep working on anonymized orders
+		_, err := tx.NewUpdate().
+			Model((*tables.Order)(nil)).
+			Set("name = ''").
+			Set("email = ''").
+			Set("phone = ''").
+			Set("note = ''").
+			Set("payment_link = ''").
+			Set("anonymized_at = ?", now).
+			Set("updated_at = ?", now).
+			Where("id IN (?)", bun.In(orderIds)).
+			Where("anonymized_at IS NULL").
+			Exec(ctx)
+		if err != nil {
+			return lib.MapPgError(err)
+		}
+
+		// The email audit trail holds the same customer data
+		_, err = tx.NewUpdate().
+			Model((*tables.OrderEmailChange)(nil)).
+			Set("from_email = ''").
+			Set("to_email = ''").
+			Where("order_id IN (?)", bun.In(orderIds)).
+			Exec(ctx)
+		if err != nil {
+			return lib.MapPgError(err)
+		}
+
+		// City and country are kept for reporting
+		_, err = tx.NewUpdate().
+			Model((*tables.Address)(nil)).
+			Set("street = ''").
+			Set("house_no = ''").
+			Set("postal_code = ''").
+			Set("updated_at = ?", now).
+			Where("id IN (?)", bun.In(addressIds)).
+			Where("user_id IS NULL").
+			Exec(ctx)
+		if err != nil {
+			return lib.MapPgError(err)
+		}
+
+		return nil
+	})
+}
+
+// OrderStatusSnapshot is the part of an order a customer polls while waiting for a payment to come through
+type OrderStatusSnapshot struct {
+	OrderId       uuid.UUID            `json:"order_id"`
+	Status        tables.OrderStatus   `json:"status"`
+	PaymentStatus tables.PaymentStatus `json:"payment_status"`
+	UpdatedAt     time.Time            `json:"updated_at"`
+}
+
+// GetOwnedOrderStatus returns the status of an order owned by userId, served from a short-lived cache when possible
+// Ownership errors are those of GetOwnedOrder
+func (os *OrderService) GetOwnedOrderStatus(ctx context.Context, orderId, userId uuid.UUID) (*OrderStatusSnapshot, error) {
+	cacheService := os.productService.cacheService
+
+	cached, err := cacheService.GetOrderStatus(userId, orderId)
+	if err != nil {
+		os.logger.Warn("Failed to get order status from cache", gecho.Field("error", err), gecho.Field("order_id", orderId))
+	} else if cached != nil {
+		return cached, nil
+	}
+
+	order, _, err := os.GetOwnedOrder(ctx, orderId, userId)
+	if err != nil {
+		return nil, err
+	}
+
+	status := &OrderStatusSnapshot{
+		OrderId:       order.Id,
+		Status:        order.Status,
+		PaymentStatus: order.PaymentStatus,
+		UpdatedAt:     order.UpdatedAt,
+	}
+
+	if err := cacheService.SetOrderStatus(userId, status); err != nil {
+		os.logger.Warn("Failed to cache order status", gecho.Field("error", err), gecho.Field("order_id", orderId))
+	}
+
+	return status, nil
+}
+
+// UserOrderSummary holds a user's lifetime order statistics
+type UserOrderSummary struct {
+	OrderCount int               `json:"order_count"`
+	Totals     map[string]uint64 `json:"totals"` // Lifetime spend in cents per currency: the stored order totals (discounts applied, shipping excluded) of orders not cancelled or refunded
+}
+
+// GetUserOrderSummary returns the order count and lifetime spend of a user, served from cache when possible
+func (os *OrderService) GetUserOrderSummary(ctx context.Context, userId uuid.UUID) (*UserOrderSummary, error) {
+	cacheService := os.productService.cacheService
+
+	cached, err := cacheService.GetUserOrderSummary(userId)
+	if err != nil {
+		os.logger.Warn("Failed to get order summary from cache", gecho.Field("error", err), gecho.Field("user_id", userId))
+	} else if cached != nil {
+		return cached, nil
+	}
+
+	var rows []struct {
+		Currency   string `bun:"currency"`
+		OrderCount int    `bun:"order_count"`
+		Total      uint64 `bun:"total"`
+	}
+	err = os.db.NewSelect().
+		TableExpr("orders AS o").
+		Join("JOIN addresses AS a ON a.id = o.address_id").
+		ColumnExpr("o.currency").
+		ColumnExpr("count(*) AS order_count").
+		ColumnExpr("coalesce(sum(o.total) FILTER (WHERE o.status NOT IN (?)), 0) AS total",
+			bun.In([]tables.OrderStatus{tables.OrderStatusCancelled, tables.OrderStatusRefunded})).
+		Where("a.user_id = ?", userId).
+		Where("o.deleted_at IS NULL").
+		GroupExpr("o.currency").
+		Scan(ctx, &rows)
+	if err != nil {
+		return nil, lib.MapPgError(err)
+	}
+
+	summary := &UserOrderSummary{Totals: make(map[string]uint64, len(rows))}
+	for _, row := range rows {
+		summary.OrderCount += row.OrderCount
+		if row.Total > 0 {
+			summary.Totals[row.Currency] = row.Total
+		}
+	}
+
+	if err := cacheService.SetUserOrderSummary(userId, summary); err != nil {
+		os.logger.Warn("Failed to cache order summary", gecho.Field("error", err), gecho.Field("user_id", userId))
+	}
+
+	return summary, nil
+}
+
+// invalidateOrderSummary drops the cached order summary of the order's owner after its status or total changed.
+// Guest orders have no summary
+func (os *OrderService) invalidateOrderSummary(ctx context.Context, orderId uuid.UUID) {
+	var userId uuid.NullUUID
+	err := os.db.NewSelect().
+		TableExpr("orders AS o").
+		Join("JOIN addresses AS a ON a.id = o.address_id").
+		ColumnExpr("a.user_id").
+		Where("o.id = ?", orderId).
+		Scan(ctx, &userId)
+	if err != nil {
+		os.logger.Warn("Failed to find order owner to invalidate order summary", gecho.Field("error", err), gecho.Field("order_id", orderId))
+		return
+	}
+	if !userId.Valid {
+		return
+	}
+
+	if err := os.productService.cacheService.InvalidateUserOrderSummary(userId.UUID); err != nil {
+		os.logger.Warn("Failed to invalidate order summary cache", gecho.Field("error", err), gecho.Field("user_id", userId.UUID))
+	}
+}
+
+// GetUserOrderLimit returns the number of orders a user may place per window
+func (os *OrderService) GetUserOrderLimit() (int, time.Duration) {
+	return os.cfg.Orders.UserOrderLimit, os.cfg.Orders.UserOrderWindow
+}
+
+// ReserveUserOrder takes one of the user's orders for the window, or returns ErrOrderRateLimited once the user
+// placed the maximum number of orders within it. The check and the reservation are a single cache script, so
+// parallel requests cannot get past the limit. The returned release gives the slot back and must be called when
+// the order is not created, so orders rejected for stock, currency or unknown products don't use up the quota.
+// Admins and configured trusted accounts are exempt. Like the IP rate limiter it fails open when the cache is unavailable
+func (os *OrderService) ReserveUserOrder(userId uuid.UUID, role string) (func(), error) {
+	release := func() {}
+	if !os.userOrderLimitApplies(userId, role) {
+		return release, nil
+	}
+
+	cacheService := os.productService.cacheService
+	limit := os.cfg.Orders.UserOrderLimit
+	member := uuid.NewString()
+	count, err := cacheService.ReserveRateLimit(userId.String(), "orders", member, limit, os.cfg.Orders.UserOrderWindow)
+	if err != nil {
+		os.logger.Warn("Order limit cache error, allowing order", gecho.Field("error", err), gecho.Field("user_id", userId))
+		return release, nil
+	}
+
+	if count > limit {
+		os.logger.Warn("User order limit exceeded",
+			gecho.Field("user_id", userId),
+			gecho.Field("limit", limit))
+		return release, lib.ErrOrderRateLimited
+	}
+
+	return func() {
+		if err := cacheService.ReleaseRateLimit(userId.String(), "orders", member); err != nil {
+			os.logger.Warn("Failed to release order limit slot", gecho.Field("error", err), gecho.Field("user_id", userId))
+		}
+	}, nil
+}
+
+// UserOrderRetryAfter returns how long until the user's oldest order in the window ages out and frees a slot.
+// It falls back to the whole window when the cache is unavailable
+func (os *OrderService) UserOrderRetryAfter(userId uuid.UUID) time.Duration {
+	window := os.cfg.Orders.UserOrderWindow
+	resetIn, err := os.productService.cacheService.RateLimitResetIn(userId.String(), "orders", window)
+	if err != nil || resetIn <= 0 {
+		return window
+	}
+	return resetIn
+}
+
+// userOrderLimitApplies reports whether the user is subject to the per-window order limit
+func (os *OrderService) userOrderLimitApplies(userId uuid.UUID, role string) bool {
+	return os.cfg.Orders.UserOrderLimit != 0 && role != "admin" && !slices.Contains(os.cfg.Orders.UserLimitBypass, userId.String())
+}
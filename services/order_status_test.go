@@ -0,0 +1,82 @@
+package services
+
+import (
+	"context"
+	"errors"
+	"mamabloemetjes_server/lib"
+	"mamabloemetjes_server/structs/tables"
+	"slices"
+	"testing"
+	"time"
+
+	"github.com/google/uuid"
+)
+
+func TestBulkUpdateOrderStatusWithOneInvalidTransition(t *testing.T) {
+	ts := newTestServices(t)
+	ctx := context.Background()
+	admin := uuid.New()
+
+	first := ts.seedOrder(t, time.Now(), ts.seedProduct(t, 2500, true))
+	second := ts.seedOrder(t, time.Now(), ts.seedProduct(t, 2500, true))
+	invalid := ts.seedOrder(t, time.Now(), ts.seedProduct(t, 2500, true))
+
+	// A pending order cannot be delivered straight away
+	results := ts.orders.BulkUpdateOrderStatus(ctx, map[uuid.UUID]tables.OrderStatus{
+		first.Id:   tables.OrderStatusProcessing,
+		second.Id:  tables.OrderStatusCancelled,
+		invalid.Id: tables.OrderStatusDelivered,
+	}, &admin)
+
+	if len(results) != 3 {
+		t.Fatalf("expected a result per order, got %d", len(results))
+	}
+	for _, result := range results {
+		if result.OrderId == invalid.Id {
+			if !errors.Is(result.Err, lib.ErrInvalidStatusTransition) {
+				t.Fatalf("expected ErrInvalidStatusTransition for the invalid order, got %v", result.Err)
+			}
+			continue
+		}
+		if result.Err != nil {
+			t.Fatalf("expected order %s to be updated, got %v", result.OrderId, result.Err)
+		}
+	}
+
+	if status := ts.reloadOrder(t, first.Id).Status; status != tables.OrderStatusProcessing {
+		t.Fatalf("expected the first order to be processing, got %s", status)
+	}
+	if history := ts.statusHistory(t, second.Id); !slices.Equal(history, []string{"pending->cancelled"}) {
+		t.Fatalf("expected the cancellation in the status history, got %v", history)
+	}
+	if status := ts.reloadOrder(t, invalid.Id).Status; status != tables.OrderStatusPending {
+		t.Fatalf("expected the invalid order to stay pending, got %s", status)
+	}
+	if history := ts.statusHistory(t, invalid.Id); len(history) != 0 {
+		t.Fatalf("expected no history for the invalid order, got %v", history)
+	}
+}
+
+func TestMarkOrderAsPaidRecordsHistory(t *testing.T) {
+	ts := newTestServices(t)
+	ctx := context.Background()
+	admin := uuid.New()
+
+	order := ts.seedOrder(t, time.Now(), ts.seedProduct(t, 2500, false))
+
+	if err := ts.orders.MarkOrderAsPaid(ctx, order.Id, &admin); err != nil {
+		t.Fatalf("MarkOrderAsPaid: %v", err)
+	}
+	reloaded := ts.reloadOrder(t, order.Id)
+	if reloaded.Status != tables.OrderStatusPaid || reloaded.PaymentStatus != tables.PaymentStatusPaid {
+		t.Fatalf("expected the order to be paid, got status %s payment %s", reloaded.Status, reloaded.PaymentStatus)
+	}
+	if history := ts.statusHistory(t, order.Id); !slices.Equal(history, []string{"pending->paid"}) {
+		t.Fatalf("expected the payment in the status history, got %v", history)
+	}
+
+	// Paying twice is not a valid transition
+	if err := ts.orders.MarkOrderAsPaid(ctx, order.Id, &admin); !errors.Is(err, lib.ErrInvalidStatusTransition) {
+		t.Fatalf("expected ErrInvalidStatusTransition paying twice, got %v", err)
+	}
+}
@@ -0,0 +1,74 @@
+package services
+
+import (
+	"context"
+	"errors"
+	"mamabloemetjes_server/lib"
+	"mamabloemetjes_server/structs"
+	"mamabloemetjes_server/structs/tables"
+	"sync"
+	"testing"
+)
+
+// orderRequest returns a valid checkout request for one unit of each product
+func orderRequest(products ...*tables.Product) *structs.OrderRequest {
+	quantities := make(map[string]int, len(products))
+	for _, product := range products {
+		quantities[product.ID.String()] = 1
+	}
+	return &structs.OrderRequest{
+		Name:       "Jan Jansen",
+		Email:      "jan@example.com",
+		Phone:      "0612345678",
+		Street:     "Dorpsstraat",
+		HouseNo:    "1",
+		PostalCode: "1234 AB",
+		City:       "Utrecht",
+		Country:    "NL",
+		Products:   quantities,
+	}
+}
+
+func TestConcurrentOrdersForLastUnit(t *testing.T) {
+	ts := newTestServices(t)
+	ctx := context.Background()
+	product := ts.seedProduct(t, 2500, false)
+
+	const buyers = 5
+	errs := make([]error, buyers)
+	var wg sync.WaitGroup
+	for i := range buyers {
+		wg.Add(1)
+		go func() {
+			defer wg.Done()
+			_, errs[i] = ts.orders.CreateOrderFromRequest(ctx, orderRequest(product), nil)
+		}()
+	}
+	wg.Wait()
+
+	succeeded := 0
+	for _, err := range errs {
+		switch {
+		case err == nil:
+			succeeded++
+		case errors.Is(err, lib.ErrInsufficientStock), errors.Is(err, lib.ErrProductUnavailable):
+			// Sold out, either seen when the products were read or once the row was locked
+		default:
+			t.Fatalf("expected the losing orders to be sold out, got %v", err)
+		}
+	}
+	if succeeded != 1 {
+		t.Fatalf("expected exactly one order for the last unit, got %d", succeeded)
+	}
+
+	orders, err := ts.db.NewSelect().Model((*tables.Order)(nil)).Count(ctx)
+	if err != nil {
+		t.Fatalf("failed to count orders: %v", err)
+	}
+	if orders != 1 {
+		t.Fatalf("expected one stored order, got %d", orders)
+	}
+	if reloaded := ts.reloadProduct(t, product.ID); reloaded.IsActive || reloaded.ReservedOrderId == nil {
+		t.Fatal("expected the product to be reserved by the winning order")
+	}
+}
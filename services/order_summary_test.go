@@ -0,0 +1,96 @@
+package services
+
+import (
+	"context"
+	"mamabloemetjes_server/structs/tables"
+	"testing"
+	"time"
+
+	"github.com/google/uuid"
+)
+
+// assignToUser gives the order an address of the user, as if the user had placed it
+func (ts *testServices) assignToUser(t *testing.T, order *tables.Order, userId uuid.UUID) {
+	t.Helper()
+	ctx := context.Background()
+	address := &tables.Address{
+		Id:         uuid.New(),
+		UserId:     &userId,
+		Street:     "Dorpsstraat",
+		HouseNo:    "1",
+		PostalCode: "1234 AB",
+		City:       "Utrecht",
+		Country:    "NL",
+	}
+	if _, err := ts.db.NewInsert().Model(address).Exec(ctx); err != nil {
+		t.Fatalf("failed to seed address: %v", err)
+	}
+	if _, err := ts.db.NewUpdate().Model((*tables.Order)(nil)).Set("address_id = ?", address.Id).Where("id = ?", order.Id).Exec(ctx); err != nil {
+		t.Fatalf("failed to assign order: %v", err)
+	}
+	order.AddressId = address.Id
+}
+
+// summaryTotal returns the user's lifetime spend in euro cents, caching the summary
+func (ts *testServices) summaryTotal(t *testing.T, userId uuid.UUID) uint64 {
+	t.Helper()
+	summary, err := ts.orders.GetUserOrderSummary(context.Background(), userId)
+	if err != nil {
+		t.Fatalf("GetUserOrderSummary: %v", err)
+	}
+	return summary.Totals["EUR"]
+}
+
+func TestUserOrderSummaryFollowsOrderChanges(t *testing.T) {
+	ts := newTestServices(t)
+	ctx := context.Background()
+	userId := uuid.New()
+
+	first := ts.seedProduct(t, 2000, true)
+	second := ts.seedProduct(t, 3000, true)
+	order := ts.seedOrder(t, time.Now(), first, second)
+	other := ts.seedOrder(t, time.Now(), ts.seedProduct(t, 1000, true))
+	ts.assignToUser(t, order, userId)
+	ts.assignToUser(t, other, userId)
+
+	// The stored totals, shipping excluded
+	if total := ts.summaryTotal(t, userId); total != 6000 {
+		t.Fatalf("expected a lifetime spend of 6000, got %d", total)
+	}
+
+	if _, err := ts.orders.ApplyOrderDiscount(ctx, order.Id, tables.OrderAdjustmentFixed, 500, "Wilted flowers", nil); err != nil {
+		t.Fatalf("ApplyOrderDiscount: %v", err)
+	}
+	if total := ts.summaryTotal(t, userId); total != 5500 {
+		t.Fatalf("expected the discount to be reflected, got %d", total)
+	}
+
+	lines := ts.orderLines(t, order.Id)
+	if _, err := ts.orders.UpdateOrderLineQuantity(ctx, order.Id, lines[first.ID].Id, 2, nil); err != nil {
+		t.Fatalf("UpdateOrderLineQuantity: %v", err)
+	}
+	if total := ts.summaryTotal(t, userId); total != 7500 {
+		t.Fatalf("expected the line edit to be reflected, got %d", total)
+	}
+
+	if err := ts.orders.DeleteOrderLine(ctx, order.Id, lines[second.ID].Id, nil); err != nil {
+		t.Fatalf("DeleteOrderLine: %v", err)
+	}
+	if total := ts.summaryTotal(t, userId); total != 4500 {
+		t.Fatalf("expected the removed line to be reflected, got %d", total)
+	}
+
+	if err := ts.orders.UpdateOrderStatus(ctx, other.Id, tables.OrderStatusCancelled, nil); err != nil {
+		t.Fatalf("UpdateOrderStatus: %v", err)
+	}
+	if total := ts.summaryTotal(t, userId); total != 3500 {
+		t.Fatalf("expected the admin cancellation to be reflected, got %d", total)
+	}
+
+	if _, err := ts.orders.ReleaseOrderReservation(ctx, order.Id, true); err != nil {
+		t.Fatalf("ReleaseOrderReservation: %v", err)
+	}
+	if total := ts.summaryTotal(t, userId); total != 0 {
+		t.Fatalf("expected the sweeper cancellation to be reflected, got %d", total)
+	}
+}
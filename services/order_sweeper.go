@@ -0,0 +1,81 @@
+package services
+
+import (
+	"context"
+	"mamabloemetjes_server/structs"
+	"time"
+
+	"github.com/MonkyMars/gecho"
+)
+
+const pendingOrderSweeperLock = "order-sweeper"
+
+// OrderSweeper periodically releases products held by abandoned (unpaid) pending orders
+type OrderSweeper struct {
+	logger       *gecho.Logger
+	cfg          *structs.Config
+	orderService *OrderService
+	cacheService *CacheService
+}
+
+func NewOrderSweeper(logger *gecho.Logger, cfg *structs.Config, orderService *OrderService, cacheService *CacheService) *OrderSweeper {
+	return &OrderSweeper{
+		logger:       logger,
+		cfg:          cfg,
+		orderService: orderService,
+		cacheService: cacheService,
+	}
+}
+
+// Start runs the sweeper until ctx is cancelled
+func (s *OrderSweeper) Start(ctx context.Context) {
+	if !s.cfg.Orders.SweepEnabled {
+		s.logger.Info("Pending order sweeper disabled")
+		return
+	}
+
+	ticker := time.NewTicker(s.cfg.Orders.SweepInterval)
+	defer ticker.Stop()
+
+	s.logger.Info("Pending order sweeper started",
+		gecho.Field("interval", s.cfg.Orders.SweepInterval.String()),
+		gecho.Field("pending_ttl", s.cfg.Orders.PendingTTL.String()))
+
+	for {
+		s.RunOnce(ctx)
+
+		select {
+		case <-ctx.Done():
+			s.logger.Info("Pending order sweeper stopped")
+			return
+		case <-ticker.C:
+		}
+	}
+}
+
+// RunOnce performs a single sweep, guarded by a distributed lock so only one instance sweeps at a time
+func (s *OrderSweeper) RunOnce(ctx context.Context) {
+	token, acquired, err := s.cacheService.AcquireLock(pendingOrderSweeperLock, s.cfg.Orders.SweepInterval)
+	if err != nil {
+		s.logger.Warn("Failed to acquire pending order sweeper lock", gecho.Field("error", err))
+		return
+	}
+	if !acquired {
+		s.logger.Debug("Pending order sweep skipped, lock held by another instance")
+		return
+	}
+	defer func() {
+		if err := s.cacheService.ReleaseLock(pendingOrderSweeperLock, token); err != nil {
+			s.logger.Warn("Failed to release pending order sweeper lock", gecho.Field("error", err))
+		}
+	}()
+
+	released, err := s.orderService.ReleaseExpiredReservations(ctx)
+	if err != nil {
+		s.logger.Error("Pending order sweep failed", gecho.Field("error", err), gecho.Field("released", released))
+		return
+	}
+	if released > 0 {
+		s.logger.Info("Pending order sweep completed", gecho.Field("released", released))
+	}
+}
@@ -0,0 +1,91 @@
+package services
+
+import (
+	"context"
+	"mamabloemetjes_server/structs/tables"
+	"testing"
+
+	"github.com/google/uuid"
+)
+
+func TestBuildOrderLinesSnapshotsPricing(t *testing.T) {
+	ts := newTestServices(t)
+	rozen := ts.seedProduct(t, 2500, true)
+	tulpen := ts.seedProduct(t, 1250, true)
+	products := map[uuid.UUID]*tables.Product{rozen.ID: rozen, tulpen.ID: tulpen}
+	orderId := uuid.New()
+
+	lines, total := ts.orders.BuildOrderLines(orderId, products,
+		map[string]int{rozen.ID.String(): 2, tulpen.ID.String(): 3, "not-a-uuid": 1, uuid.NewString(): 4})
+
+	if total != 2*2500+3*1250 {
+		t.Fatalf("expected total %d, got %d", 2*2500+3*1250, total)
+	}
+	if len(lines) != 2 {
+		t.Fatalf("expected a line per known product, got %d", len(lines))
+	}
+	for _, line := range lines {
+		product := products[line.ProductId]
+		if product == nil || line.OrderId != orderId {
+			t.Fatalf("unexpected line %+v", line)
+		}
+		if line.UnitPrice != product.Price || line.UnitSubtotal != product.Subtotal ||
+			line.ProductName != product.Name || line.ProductSKU != product.SKU {
+			t.Fatalf("expected the line to snapshot %s, got %+v", product.Name, line)
+		}
+		if line.LineTotal != product.Subtotal*uint64(line.Quantity) {
+			t.Fatalf("expected line total %d, got %d", product.Subtotal*uint64(line.Quantity), line.LineTotal)
+		}
+	}
+}
+
+func TestCreateOrderStoresLineTotals(t *testing.T) {
+	ts := newTestServices(t)
+	rozen := ts.seedProduct(t, 2500, true)
+	tulpen := ts.seedProduct(t, 1250, true)
+
+	req := orderRequest(rozen, tulpen)
+	req.Products[rozen.ID.String()] = 2
+	req.ShippingCents = -1 // Ignored, shipping comes from the config
+	created, err := ts.orders.CreateOrderFromRequest(context.Background(), req, nil)
+	if err != nil {
+		t.Fatalf("CreateOrderFromRequest: %v", err)
+	}
+
+	lines := ts.orderLines(t, created.Order.Id)
+	if got := lines[rozen.ID]; got == nil || got.Quantity != 2 || got.LineTotal != 5000 {
+		t.Fatalf("expected a stored line of 2 x 2500, got %+v", got)
+	}
+	if got := lines[tulpen.ID]; got == nil || got.Quantity != 1 || got.LineTotal != 1250 {
+		t.Fatalf("expected a stored line of 1 x 1250, got %+v", got)
+	}
+	// Shipping is kept apart from the total
+	shipping := uint64(ts.orders.cfg.Orders.ShippingCents)
+	order := ts.reloadOrder(t, created.Order.Id)
+	if order.Total != 6250 || order.ShippingCents != shipping || created.Order.ShippingCents != shipping {
+		t.Fatalf("expected total 6250 and the configured shipping %d, got %d, stored %d and returned %d", shipping, order.Total, order.ShippingCents, created.Order.ShippingCents)
+	}
+}
+
+func TestBuildOrderLinesHasNoNilLines(t *testing.T) {
+	os := &OrderService{}
+	for _, n := range []int{1, 2, 5} {
+		products := make(map[uuid.UUID]*tables.Product, n)
+		quantities := make(map[string]int, n)
+		for range n {
+			product := &tables.Product{ID: uuid.New(), Name: "Bouquet", Price: 1000, Subtotal: 1000}
+			products[product.ID] = product
+			quantities[product.ID.String()] = 1
+		}
+
+		lines, _ := os.BuildOrderLines(uuid.New(), products, quantities)
+		if len(lines) != n {
+			t.Fatalf("expected %d order lines, got %d", n, len(lines))
+		}
+		for i, line := range lines {
+			if line == nil {
+				t.Fatalf("expected no nil order lines for %d products, line %d is nil", n, i)
+			}
+		}
+	}
+}
@@ -0,0 +1,208 @@
+package services
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"mamabloemetjes_server/lib"
+	"mamabloemetjes_server/structs"
+	"mamabloemetjes_server/structs/tables"
+	"mamabloemetjes_server/testutil"
+	"strings"
+	"testing"
+	"time"
+)
+
+// newPasswordAuthService returns an auth service for hashing and verifying passwords, without a database
+func newPasswordAuthService() *AuthService {
+	return NewAuthService(testutil.Config(), testutil.Logger(), nil, nil)
+}
+
+// withHashParams replaces the m, t and p parameters of an encoded argon2 hash, keeping a pepper version
+func withHashParams(t *testing.T, encoded string, memory, passes, threads int) string {
+	t.Helper()
+	parts := strings.Split(encoded, "$")
+	if len(parts) != 6 {
+		t.Fatalf("unexpected hash format %q", encoded)
+	}
+	_, pepper, _ := strings.Cut(parts[3], ",pv=")
+	parts[3] = fmt.Sprintf("m=%d,t=%d,p=%d", memory, passes, threads)
+	if pepper != "" {
+		parts[3] += ",pv=" + pepper
+	}
+	return strings.Join(parts, "$")
+}
+
+func TestVerifyPasswordRejectsOutOfBoundsHash(t *testing.T) {
+	as := newPasswordAuthService()
+	auth := as.cfg.Auth
+
+	hash, err := as.HashPassword(testPassword, as.argonParams)
+	if err != nil {
+		t.Fatalf("HashPassword: %v", err)
+	}
+	if valid, err := as.VerifyPassword(testPassword, hash); err != nil || !valid {
+		t.Fatalf("expected the password to verify, got %v (err %v)", valid, err)
+	}
+
+	memory, passes, threads := int(as.argonParams.Memory), int(as.argonParams.Time), int(as.argonParams.Threads)
+	tests := []struct {
+		name                    string
+		memory, passes, threads int
+	}{
+		{"memory", 4 * 1024 * 1024, passes, threads},
+		{"passes", memory, auth.PasswordMaxTime * 1000, threads},
+		{"threads", memory, passes, auth.PasswordMaxThreads + 1},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			crafted := withHashParams(t, hash, tt.memory, tt.passes, tt.threads)
+
+			// Running argon2 with these parameters would take seconds and gigabytes
+			start := time.Now()
+			valid, err := as.VerifyPassword(testPassword, crafted)
+			if !errors.Is(err, lib.ErrHashParamsExceeded) || valid {
+				t.Fatalf("expected ErrHashParamsExceeded, got %v (err %v)", valid, err)
+			}
+			if elapsed := time.Since(start); elapsed > 100*time.Millisecond {
+				t.Fatalf("expected the hash to be rejected before hashing, took %v", elapsed)
+			}
+		})
+	}
+
+	// Parameters at the limits are still accepted
+	if _, err := as.VerifyPassword(testPassword, withHashParams(t, hash, memory, passes, auth.PasswordMaxThreads)); errors.Is(err, lib.ErrHashParamsExceeded) {
+		t.Fatalf("expected parameters within the limits to be accepted, got %v", err)
+	}
+}
+
+// newPepperedAuthService returns a password auth service hashing with the given pepper version and peppers
+func newPepperedAuthService(version int, peppers map[int]string) *AuthService {
+	cfg := *testutil.Config()
+	auth := *cfg.Auth
+	auth.PasswordPepperVersion = version
+	auth.PasswordPeppers = peppers
+	cfg.Auth = &auth
+	return NewAuthService(&cfg, testutil.Logger(), nil, nil)
+}
+
+func TestVerifyPasswordWithPepper(t *testing.T) {
+	peppered := newPepperedAuthService(1, map[int]string{1: "first-pepper"})
+	hash, err := peppered.HashPassword(testPassword, peppered.argonParams)
+	if err != nil {
+		t.Fatalf("HashPassword: %v", err)
+	}
+	if !strings.Contains(hash, ",pv=1$") {
+		t.Fatalf("expected the hash to record pepper version 1, got %s", hash)
+	}
+
+	if valid, err := peppered.VerifyPassword(testPassword, hash); err != nil || !valid {
+		t.Fatalf("expected the password to verify with the correct pepper, got %v (err %v)", valid, err)
+	}
+	if valid, _ := peppered.VerifyPassword("wrong password", hash); valid {
+		t.Fatal("expected a wrong password to be rejected")
+	}
+
+	wrongPepper := newPepperedAuthService(1, map[int]string{1: "other-pepper"})
+	if valid, err := wrongPepper.VerifyPassword(testPassword, hash); err != nil || valid {
+		t.Fatalf("expected a wrong pepper to fail verification, got %v (err %v)", valid, err)
+	}
+
+	absentPepper := newPepperedAuthService(0, nil)
+	if valid, err := absentPepper.VerifyPassword(testPassword, hash); !errors.Is(err, lib.ErrUnknownPepper) || valid {
+		t.Fatalf("expected ErrUnknownPepper without the pepper, got %v (err %v)", valid, err)
+	}
+	unpeppered := strings.Replace(hash, ",pv=1", "", 1)
+	if valid, err := absentPepper.VerifyPassword(testPassword, unpeppered); err != nil || valid {
+		t.Fatalf("expected the hash to fail verification without its pepper, got %v (err %v)", valid, err)
+	}
+
+	// After rotating, old hashes still verify and are marked for an upgrade
+	rotated := newPepperedAuthService(2, map[int]string{1: "first-pepper", 2: "second-pepper"})
+	if valid, err := rotated.VerifyPassword(testPassword, hash); err != nil || !valid {
+		t.Fatalf("expected a hash of the previous pepper to verify after rotation, got %v (err %v)", valid, err)
+	}
+	if !rotated.needsRehash(hash) || peppered.needsRehash(hash) {
+		t.Fatal("expected only a hash of a previous pepper version to need a rehash")
+	}
+}
+
+// weakParams returns the active argon parameters with every cost lowered
+func weakParams(as *AuthService) *structs.ArgonParams {
+	weak := *as.argonParams
+	weak.Memory /= 2
+	weak.Time = 1
+	weak.SaltLen /= 2
+	return &weak
+}
+
+func TestNeedsRehashWeakerParams(t *testing.T) {
+	as := newPasswordAuthService()
+	current, err := as.HashPassword(testPassword, as.argonParams)
+	if err != nil {
+		t.Fatalf("HashPassword: %v", err)
+	}
+	if as.needsRehash(current) {
+		t.Fatal("expected a hash with the active parameters to be kept")
+	}
+
+	weak, err := as.HashPassword(testPassword, weakParams(as))
+	if err != nil {
+		t.Fatalf("HashPassword: %v", err)
+	}
+	if !as.needsRehash(weak) {
+		t.Fatal("expected a hash with weaker parameters to need a rehash")
+	}
+
+	// Stronger parameters are left as they are, the cost is only ever raised
+	memory, passes, threads := int(as.argonParams.Memory), int(as.argonParams.Time), int(as.argonParams.Threads)
+	if as.needsRehash(withHashParams(t, current, memory*2, passes+1, threads)) {
+		t.Fatal("expected a hash with stronger parameters to be kept")
+	}
+	if !as.needsRehash(withHashParams(t, current, memory/2, passes, threads)) {
+		t.Fatal("expected a hash with less memory to need a rehash")
+	}
+}
+
+func TestLoginUpgradesWeakHash(t *testing.T) {
+	ts := newTestServices(t)
+	ctx := context.Background()
+	user := ts.registerUser(t, "jan@example.com")
+
+	weak, err := ts.auth.HashPassword(testPassword, weakParams(ts.auth))
+	if err != nil {
+		t.Fatalf("HashPassword: %v", err)
+	}
+	if _, err := ts.db.NewUpdate().Model((*tables.User)(nil)).Set("password_hash = ?", weak).Where("id = ?", user.Id).Exec(ctx); err != nil {
+		t.Fatalf("failed to store the weak hash: %v", err)
+	}
+	storedHash := func() string {
+		t.Helper()
+		var hash string
+		if err := ts.db.NewSelect().Model((*tables.User)(nil)).Column("password_hash").Where("id = ?", user.Id).Scan(ctx, &hash); err != nil {
+			t.Fatalf("failed to read the password hash: %v", err)
+		}
+		return hash
+	}
+
+	if _, err := ts.auth.Login(&structs.AuthRequest{Email: "jan@example.com", Password: testPassword}); err != nil {
+		t.Fatalf("Login: %v", err)
+	}
+	upgraded := storedHash()
+	parts, err := lib.DecodeArgon2Hash(upgraded)
+	if err != nil {
+		t.Fatalf("DecodeArgon2Hash: %v", err)
+	}
+	params := ts.auth.argonParams
+	if upgraded == weak || parts.Memory != params.Memory || parts.Time != params.Time || uint32(len(parts.Salt)) != params.SaltLen {
+		t.Fatalf("expected the hash to be upgraded to %+v, got %s", *params, upgraded)
+	}
+
+	// The upgraded hash verifies and is kept on the next login
+	if _, err := ts.auth.Login(&structs.AuthRequest{Email: "jan@example.com", Password: testPassword}); err != nil {
+		t.Fatalf("Login with the upgraded hash: %v", err)
+	}
+	if storedHash() != upgraded {
+		t.Fatal("expected a current hash not to be rehashed again")
+	}
+}
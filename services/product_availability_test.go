@@ -0,0 +1,95 @@
+package services
+
+import (
+	"context"
+	"mamabloemetjes_server/structs/tables"
+	"slices"
+	"testing"
+	"time"
+
+	"github.com/google/uuid"
+)
+
+// withAvailabilityWindow sets the availability window of a seeded product; nil leaves a side open
+func (ts *testServices) withAvailabilityWindow(t *testing.T, product *tables.Product, from, until *time.Time) {
+	t.Helper()
+	_, err := ts.db.NewUpdate().Model((*tables.Product)(nil)).
+		Set("available_from = ?", from).
+		Set("available_until = ?", until).
+		Where("id = ?", product.ID).
+		Exec(context.Background())
+	if err != nil {
+		t.Fatalf("failed to set the availability window: %v", err)
+	}
+}
+
+func TestProductAvailabilityWindowBounds(t *testing.T) {
+	from := time.Date(2026, 2, 1, 0, 0, 0, 0, time.UTC)
+	until := time.Date(2026, 2, 15, 0, 0, 0, 0, time.UTC)
+	valentine := &tables.Product{IsActive: true, AvailableFrom: &from, AvailableUntil: &until}
+
+	tests := []struct {
+		name     string
+		at       time.Time
+		expected bool
+	}{
+		{"before the window", from.Add(-time.Second), false},
+		{"at the start of the window", from, true},
+		{"in the window", from.Add(7 * 24 * time.Hour), true},
+		{"at the end of the window", until, false},
+		{"after the window", until.Add(24 * time.Hour), false},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			if got := (ProductScope{}).includes(valentine, tt.at); got != tt.expected {
+				t.Fatalf("expected %v, got %v", tt.expected, got)
+			}
+			if !(ProductScope{IncludeUnavailable: true}).includes(valentine, tt.at) {
+				t.Fatal("expected the preview to include the product at any time")
+			}
+		})
+	}
+}
+
+func TestActiveProductsFollowAvailabilityWindow(t *testing.T) {
+	ts := newTestServices(t)
+	ctx := context.Background()
+	now := time.Now()
+	lastWeek, yesterday, tomorrow, nextWeek := now.Add(-7*24*time.Hour), now.Add(-24*time.Hour), now.Add(24*time.Hour), now.Add(7*24*time.Hour)
+
+	upcoming := ts.seedProduct(t, 2500, true)
+	ts.withAvailabilityWindow(t, upcoming, &tomorrow, &nextWeek)
+	current := ts.seedProduct(t, 2500, true)
+	ts.withAvailabilityWindow(t, current, &yesterday, &tomorrow)
+	ended := ts.seedProduct(t, 2500, true)
+	ts.withAvailabilityWindow(t, ended, &lastWeek, &yesterday)
+	always := ts.seedProduct(t, 2500, true)
+
+	ids := func(scope ProductScope) []uuid.UUID {
+		t.Helper()
+		result, err := ts.products.GetActiveProducts(ctx, 1, 50, false, "", scope)
+		if err != nil {
+			t.Fatalf("GetActiveProducts: %v", err)
+		}
+		found := make([]uuid.UUID, 0, len(result.Products))
+		for _, product := range result.Products {
+			found = append(found, product.ID)
+		}
+		return found
+	}
+
+	public := ids(ProductScope{})
+	if !slices.Contains(public, current.ID) || !slices.Contains(public, always.ID) {
+		t.Fatalf("expected the products in their window to be listed, got %v", public)
+	}
+	if slices.Contains(public, upcoming.ID) || slices.Contains(public, ended.ID) {
+		t.Fatalf("expected the products before and after their window to be hidden, got %v", public)
+	}
+
+	preview := ids(ProductScope{IncludeUnavailable: true})
+	for _, product := range []*tables.Product{upcoming, current, ended, always} {
+		if !slices.Contains(preview, product.ID) {
+			t.Fatalf("expected the admin preview to list %s, got %v", product.Name, preview)
+		}
+	}
+}
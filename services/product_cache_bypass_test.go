@@ -0,0 +1,38 @@
+package services
+
+import (
+	"context"
+	"mamabloemetjes_server/database"
+	"mamabloemetjes_server/structs/tables"
+	"testing"
+)
+
+func TestGetProductByIDCacheBypass(t *testing.T) {
+	ts := newTestServices(t)
+	product := ts.seedProduct(t, 2500, false)
+
+	// The cache holds a copy from before an admin edit
+	stale := *product
+	stale.Name = "Stale bouquet"
+	if err := ts.cache.SetProductByID(&stale, false); err != nil {
+		t.Fatalf("SetProductByID: %v", err)
+	}
+
+	read := func(bypassCache bool) (*tables.Product, int) {
+		var got *tables.Product
+		queries := database.CountQueries(context.Background(), func(ctx context.Context) {
+			var err error
+			if got, err = ts.products.GetProductByID(ctx, product.ID, false, bypassCache); err != nil {
+				t.Fatalf("GetProductByID: %v", err)
+			}
+		})
+		return got, queries
+	}
+
+	if public, queries := read(false); public.Name != stale.Name || queries != 0 {
+		t.Fatalf("expected a public read to come from the cache, got %q after %d queries", public.Name, queries)
+	}
+	if admin, queries := read(true); admin.Name != product.Name || queries == 0 {
+		t.Fatalf("expected an admin read to hit the database, got %q after %d queries", admin.Name, queries)
+	}
+}
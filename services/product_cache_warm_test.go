@@ -0,0 +1,67 @@
+package services
+
+import (
+	"context"
+	"errors"
+	"mamabloemetjes_server/lib"
+	"mamabloemetjes_server/structs/tables"
+	"mamabloemetjes_server/testutil"
+	"testing"
+)
+
+func TestWarmCacheRejectsConcurrentRun(t *testing.T) {
+	cs := newTestCacheService(t)
+	ps := NewProductService(testutil.Logger(), testutil.Config(), nil, cs)
+
+	// Another instance is warming: the lock is taken before the database is reached
+	token, acquired, err := cs.AcquireLock(productCacheWarmLock, productCacheWarmLockTTL)
+	if err != nil || !acquired {
+		t.Fatalf("failed to take the warm lock: %v", err)
+	}
+	if _, err := ps.WarmCache(context.Background()); !errors.Is(err, lib.ErrCacheWarmInProgress) {
+		t.Fatalf("expected ErrCacheWarmInProgress, got %v", err)
+	}
+
+	// The rejected run leaves the lock of the running warmup alone
+	if _, acquired, err := cs.AcquireLock(productCacheWarmLock, productCacheWarmLockTTL); err != nil || acquired {
+		t.Fatalf("expected the lock to stay held, got acquired %v (err %v)", acquired, err)
+	}
+	if err := cs.ReleaseLock(productCacheWarmLock, token); err != nil {
+		t.Fatalf("ReleaseLock: %v", err)
+	}
+}
+
+func TestWarmCachePopulatesProductCaches(t *testing.T) {
+	ts := newTestServices(t)
+	rozen := ts.seedProduct(t, 2500, true)
+	tulpen := ts.seedProduct(t, 1250, true)
+
+	result, err := ts.products.WarmCache(context.Background())
+	if err != nil {
+		t.Fatalf("WarmCache: %v", err)
+	}
+	// One page for all products and one per product type, each with and without images
+	if pages := (1 + len(tables.ProductTypes)) * 2; result.Pages != pages || result.Products != 2 || result.Counts != 1 {
+		t.Fatalf("expected %d pages, 2 products and 1 count, got %+v", pages, result)
+	}
+
+	for _, includeImages := range []bool{false, true} {
+		products, pagination, err := ts.cache.GetActiveProductsList(1, lib.DefaultPageSize, includeImages, "")
+		if err != nil || len(products) != 2 || pagination == nil || pagination.Total != 2 {
+			t.Fatalf("expected the first page (images %v) to be cached, got %d products and %+v (err %v)", includeImages, len(products), pagination, err)
+		}
+
+		cached, missing, err := ts.cache.GetProducts([]string{rozen.SKU, tulpen.SKU}, includeImages)
+		if err != nil || len(cached) != 2 || len(missing) != 0 {
+			t.Fatalf("expected both products cached by SKU (images %v), missing %v (err %v)", includeImages, missing, err)
+		}
+	}
+	if count, err := ts.cache.GetProductCount(ActiveProductCountKey); err != nil || count == nil || *count != 2 {
+		t.Fatalf("expected the active product count to be cached, got %v (err %v)", count, err)
+	}
+
+	// The lock is released once the warmup is done
+	if _, err := ts.products.WarmCache(context.Background()); err != nil {
+		t.Fatalf("expected a second warmup to run, got %v", err)
+	}
+}
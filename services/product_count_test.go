@@ -0,0 +1,48 @@
+package services
+
+import (
+	"context"
+	"testing"
+)
+
+func TestActiveProductCountFollowsActivation(t *testing.T) {
+	ts := newTestServices(t)
+	ctx := context.Background()
+
+	first := ts.seedProduct(t, 2500, false)
+	ts.seedProduct(t, 2500, false)
+
+	// activeCount reads the count twice, so the second read comes from the cache
+	activeCount := func() int {
+		t.Helper()
+		count, err := ts.products.GetActiveProductCount(ctx)
+		if err != nil {
+			t.Fatalf("GetActiveProductCount: %v", err)
+		}
+		if cached, err := ts.products.GetActiveProductCount(ctx); err != nil || cached != count {
+			t.Fatalf("expected the cached count %d, got %d (err %v)", count, cached, err)
+		}
+		return count
+	}
+
+	if count := activeCount(); count != 2 {
+		t.Fatalf("expected 2 active products, got %d", count)
+	}
+
+	setActive := func(active bool) {
+		t.Helper()
+		if err := ts.products.UpdateProduct(ctx, first.ID, &UpdateProductRequest{IsActive: &active}); err != nil {
+			t.Fatalf("UpdateProduct: %v", err)
+		}
+	}
+
+	setActive(false)
+	if count := activeCount(); count != 1 {
+		t.Fatalf("expected the deactivated product to drop out of the count, got %d", count)
+	}
+
+	setActive(true)
+	if count := activeCount(); count != 2 {
+		t.Fatalf("expected the reactivated product to count again, got %d", count)
+	}
+}
@@ -0,0 +1,71 @@
+package services
+
+import (
+	"errors"
+	"fmt"
+	"mamabloemetjes_server/lib"
+	"mamabloemetjes_server/testutil"
+	"testing"
+)
+
+// newFilterLimitedProductService returns a product service allowing maxSKUs per SKU filter and searches of minSearch characters
+func newFilterLimitedProductService(maxSKUs, minSearch int) *ProductService {
+	cfg := *testutil.Config()
+	products := *cfg.Products
+	products.MaxFilterSKUs = maxSKUs
+	products.MinSearchLength = minSearch
+	cfg.Products = &products
+	return &ProductService{logger: testutil.Logger(), cfg: &cfg}
+}
+
+// skuList returns n distinct SKUs
+func skuList(n int) []string {
+	skus := make([]string, n)
+	for i := range skus {
+		skus[i] = fmt.Sprintf("SKU-%03d", i)
+	}
+	return skus
+}
+
+func TestCheckFilterLimits(t *testing.T) {
+	ps := newFilterLimitedProductService(3, 3)
+	minPrice := uint64(1000)
+
+	tests := []struct {
+		name  string
+		opts  ProductListOptions
+		field string // empty when the filters are allowed
+	}{
+		{"skus at the cap", ProductListOptions{SKUs: skuList(3)}, ""},
+		{"skus above the cap", ProductListOptions{SKUs: skuList(4)}, "skus"},
+		{"exclude skus at the cap", ProductListOptions{ExcludeSKUs: skuList(3)}, ""},
+		{"exclude skus above the cap", ProductListOptions{ExcludeSKUs: skuList(4)}, "exclude_skus"},
+		{"search at the minimum length", ProductListOptions{SearchTerm: "roz"}, ""},
+		{"short search on its own", ProductListOptions{SearchTerm: "ro"}, "search"},
+		{"short search counts characters, not bytes", ProductListOptions{SearchTerm: "éé"}, "search"},
+		{"short search with a product type", ProductListOptions{SearchTerm: "ro", ProductType: "wedding"}, ""},
+		{"short search with a price filter", ProductListOptions{SearchTerm: "ro", MinPrice: &minPrice}, ""},
+		{"short search with skus", ProductListOptions{SearchTerm: "ro", SKUs: skuList(1)}, ""},
+		{"short search with only excluded skus", ProductListOptions{SearchTerm: "ro", ExcludeSKUs: skuList(1)}, "search"},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			err := ps.checkFilterLimits(&tt.opts)
+			if tt.field == "" {
+				if err != nil {
+					t.Fatalf("expected the filters to be allowed, got %v", err)
+				}
+				return
+			}
+			var validationErr *lib.ValidationError
+			if !errors.As(err, &validationErr) || len(validationErr.Errors) != 1 || validationErr.Errors[0].Field != tt.field {
+				t.Fatalf("expected a validation error on %s, got %v", tt.field, err)
+			}
+		})
+	}
+
+	disabled := newFilterLimitedProductService(3, 0)
+	if err := disabled.checkFilterLimits(&ProductListOptions{SearchTerm: "r"}); err != nil {
+		t.Fatalf("expected a zero minimum to disable the short-search guard, got %v", err)
+	}
+}
@@ -0,0 +1,145 @@
+package services
+
+import (
+	"context"
+	"mamabloemetjes_server/structs/tables"
+	"strings"
+	"testing"
+
+	"github.com/google/uuid"
+)
+
+const (
+	oldImageHost = "https://images.example.com/"
+	newImageHost = "https://images.example.com/v2/"
+)
+
+// seedImage inserts an image of the product with the given id and URL
+func (ts *testServices) seedImage(t *testing.T, product *tables.Product, id uuid.UUID, url string, primary bool) {
+	t.Helper()
+	image := &tables.ProductImage{ID: id, ProductID: product.ID, URL: url, IsPrimary: primary}
+	if _, err := ts.db.NewInsert().Model(image).Exec(context.Background()); err != nil {
+		t.Fatalf("failed to seed image: %v", err)
+	}
+}
+
+// imageURL reads the URL of an image back from the database
+func (ts *testServices) imageURL(t *testing.T, id uuid.UUID) string {
+	t.Helper()
+	var url string
+	err := ts.db.NewSelect().Model((*tables.ProductImage)(nil)).Column("url").Where("id = ?", id).Scan(context.Background(), &url)
+	if err != nil {
+		t.Fatalf("failed to read image %s: %v", id, err)
+	}
+	return url
+}
+
+func TestMigrateImageHostSkipsMigratedURLs(t *testing.T) {
+	ts := newTestServices(t)
+	ctx := context.Background()
+
+	product := ts.seedProduct(t, 2500, false)
+	pending, migrated := uuid.New(), uuid.New()
+	ts.seedImage(t, product, pending, oldImageHost+"roses.jpg", true)
+	ts.seedImage(t, product, migrated, newImageHost+"tulips.jpg", true)
+
+	// The new host extends the old one, so the migrated image also starts with the old prefix
+	for run := range 2 {
+		result, err := ts.products.MigrateImageHost(ctx, oldImageHost, newImageHost, false, 1)
+		if err != nil {
+			t.Fatalf("MigrateImageHost run %d: %v", run+1, err)
+		}
+		if expected := 1 - run; result.Matched != expected || result.Updated != expected {
+			t.Fatalf("run %d: expected %d matched and updated, got %+v", run+1, expected, result)
+		}
+	}
+
+	if url := ts.imageURL(t, pending); url != newImageHost+"roses.jpg" {
+		t.Fatalf("expected the pending image to be migrated once, got %s", url)
+	}
+	if url := ts.imageURL(t, migrated); url != newImageHost+"tulips.jpg" {
+		t.Fatalf("expected the migrated image to be left alone, got %s", url)
+	}
+}
+
+func TestMigrateImageHostInvalidatesCommittedBatchesOnError(t *testing.T) {
+	ts := newTestServices(t)
+	ctx := context.Background()
+
+	// Batches run in id order, so the committed image sorts first
+	committed := ts.seedProduct(t, 2500, false)
+	failing := ts.seedProduct(t, 2500, false)
+	ts.seedImage(t, committed, uuid.MustParse("00000000-0000-4000-8000-000000000001"), oldImageHost+"a.jpg", true)
+	ts.seedImage(t, failing, uuid.MustParse("ffffffff-ffff-4fff-bfff-ffffffffffff"), oldImageHost+"a-much-longer-name.jpg", true)
+
+	// Fail the second batch by refusing its rewritten URL
+	maxLength := len(newImageHost + "a.jpg")
+	if _, err := ts.db.ExecContext(ctx, "ALTER TABLE product_images ADD CONSTRAINT test_url_length CHECK (length(url) <= ?) NOT VALID", maxLength); err != nil {
+		t.Fatalf("failed to add the URL length check: %v", err)
+	}
+	t.Cleanup(func() {
+		_, _ = ts.db.ExecContext(context.Background(), "ALTER TABLE product_images DROP CONSTRAINT IF EXISTS test_url_length")
+	})
+
+	for _, product := range []*tables.Product{committed, failing} {
+		if err := ts.cache.SetProductByID(product, true); err != nil {
+			t.Fatalf("SetProductByID: %v", err)
+		}
+	}
+
+	result, err := ts.products.MigrateImageHost(ctx, oldImageHost, newImageHost, false, 1)
+	if err == nil {
+		t.Fatal("expected the second batch to fail")
+	}
+	if result.Updated != 1 {
+		t.Fatalf("expected the first batch to be committed, got %+v", result)
+	}
+
+	if cached, _ := ts.cache.GetProductByID(committed.ID, true); cached != nil {
+		t.Fatal("expected the cache of the product in the committed batch to be invalidated")
+	}
+	if cached, _ := ts.cache.GetProductByID(failing.ID, true); cached == nil {
+		t.Fatal("expected the cache of the product in the failed batch to be kept")
+	}
+}
+
+func TestMigrateImageHostDryRunCountsMatches(t *testing.T) {
+	ts := newTestServices(t)
+	ctx := context.Background()
+
+	first := ts.seedProduct(t, 2500, false)
+	second := ts.seedProduct(t, 2500, false)
+	matching := []uuid.UUID{uuid.New(), uuid.New(), uuid.New()}
+	ts.seedImage(t, first, matching[0], oldImageHost+"roses.jpg", true)
+	ts.seedImage(t, first, matching[1], oldImageHost+"roses-side.jpg", false)
+	ts.seedImage(t, second, matching[2], oldImageHost+"tulips.jpg", true)
+	elsewhere := uuid.New()
+	ts.seedImage(t, second, elsewhere, "https://cdn.example.org/tulips-side.jpg", false)
+
+	dryRun, err := ts.products.MigrateImageHost(ctx, oldImageHost, newImageHost, true, 2)
+	if err != nil {
+		t.Fatalf("MigrateImageHost dry run: %v", err)
+	}
+	if !dryRun.DryRun || dryRun.Matched != 3 || dryRun.Updated != 0 || dryRun.Products != 2 {
+		t.Fatalf("expected 3 matches over 2 products and nothing updated, got %+v", dryRun)
+	}
+	if url := ts.imageURL(t, matching[0]); url != oldImageHost+"roses.jpg" {
+		t.Fatalf("expected a dry run to leave the URLs alone, got %s", url)
+	}
+
+	result, err := ts.products.MigrateImageHost(ctx, oldImageHost, newImageHost, false, 2)
+	if err != nil {
+		t.Fatalf("MigrateImageHost: %v", err)
+	}
+	if result.Matched != 3 || result.Updated != 3 || result.Products != 2 {
+		t.Fatalf("expected 3 images over 2 products rewritten, got %+v", result)
+	}
+	for _, id := range matching {
+		if url := ts.imageURL(t, id); !strings.HasPrefix(url, newImageHost) {
+			t.Fatalf("expected image %s to move to the new host, got %s", id, url)
+		}
+	}
+	if url := ts.imageURL(t, elsewhere); url != "https://cdn.example.org/tulips-side.jpg" {
+		t.Fatalf("expected an image on another host to be left alone, got %s", url)
+	}
+}
@@ -0,0 +1,153 @@
+package services
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"mamabloemetjes_server/lib"
+	"mamabloemetjes_server/structs/tables"
+	"mamabloemetjes_server/testutil"
+	"testing"
+
+	"github.com/google/uuid"
+)
+
+func TestValidateImageCount(t *testing.T) {
+	ps := &ProductService{logger: testutil.Logger(), cfg: testutil.Config()}
+	maxImages := ps.cfg.Products.MaxImages
+
+	if err := ps.validateImageCount(maxImages); err != nil {
+		t.Fatalf("expected %d images to be allowed, got %v", maxImages, err)
+	}
+
+	err := ps.validateImageCount(maxImages + 1)
+	var validationErr *lib.ValidationError
+	if !errors.As(err, &validationErr) || len(validationErr.Errors) != 1 || validationErr.Errors[0].Field != "images" {
+		t.Fatalf("expected a field error on images, got %v", err)
+	}
+
+	// The limit is checked before anything is written
+	product := &tables.Product{Name: "Bouquet", SKU: "SKU-IMAGES", Price: 2500, Images: make([]tables.ProductImage, maxImages+1)}
+	if _, err := ps.CreateProduct(context.Background(), product); !errors.As(err, &validationErr) {
+		t.Fatalf("expected CreateProduct to reject %d images, got %v", maxImages+1, err)
+	}
+}
+
+func TestCreateProductDuplicateSKULeavesNoRows(t *testing.T) {
+	ts := newTestServices(t)
+	ctx := context.Background()
+
+	newProduct := func(name string) *tables.Product {
+		return &tables.Product{
+			Name:        name,
+			SKU:         "SKU-DUPLICATE",
+			Price:       2500,
+			Description: "A hand-tied bouquet of seasonal flowers",
+			ProductType: tables.ProductTypeWedding,
+			IsActive:    true,
+			Images: []tables.ProductImage{
+				{URL: "https://images.example.com/" + name + "-1.jpg", IsPrimary: true},
+				{URL: "https://images.example.com/" + name + "-2.jpg"},
+			},
+		}
+	}
+
+	original, err := ts.products.CreateProduct(ctx, newProduct("original"))
+	if err != nil {
+		t.Fatalf("CreateProduct: %v", err)
+	}
+
+	duplicate := newProduct("duplicate")
+	if _, err := ts.products.CreateProduct(ctx, duplicate); !errors.Is(err, lib.ErrDuplicateSKU) {
+		t.Fatalf("expected ErrDuplicateSKU, got %v", err)
+	}
+
+	if count, err := ts.db.NewSelect().Model((*tables.Product)(nil)).Count(ctx); err != nil || count != 1 {
+		t.Fatalf("expected only the original product, got %d products (err %v)", count, err)
+	}
+	var productIDs []uuid.UUID
+	if err := ts.db.NewSelect().Model((*tables.ProductImage)(nil)).Column("product_id").Scan(ctx, &productIDs); err != nil {
+		t.Fatalf("failed to read images: %v", err)
+	}
+	if len(productIDs) != 2 {
+		t.Fatalf("expected only the 2 original images, got %d", len(productIDs))
+	}
+	for _, productID := range productIDs {
+		if productID != original.ID {
+			t.Fatalf("expected no images of the duplicate, found one of %s", productID)
+		}
+	}
+}
+
+func TestSelectPrimaryImage(t *testing.T) {
+	tests := []struct {
+		name     string
+		declared []bool
+		want     []bool
+	}{
+		{"none declared promotes the first", []bool{false, false, false}, []bool{true, false, false}},
+		{"one declared is kept", []bool{false, true, false}, []bool{false, true, false}},
+		{"first declared wins", []bool{false, true, true}, []bool{false, true, false}},
+		{"all declared keeps the first", []bool{true, true, true}, []bool{true, false, false}},
+		{"no images", nil, nil},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			images := make([]tables.ProductImage, len(tt.declared))
+			for i, primary := range tt.declared {
+				images[i].IsPrimary = primary
+			}
+			selectPrimaryImage(images)
+			for i := range images {
+				if images[i].IsPrimary != tt.want[i] {
+					t.Fatalf("expected primaries %v, got image %d primary %v", tt.want, i, images[i].IsPrimary)
+				}
+			}
+		})
+	}
+}
+
+func TestImageWritesStoreOnePrimary(t *testing.T) {
+	ts := newTestServices(t)
+	ctx := context.Background()
+
+	images := func(name string, primaries ...bool) []tables.ProductImage {
+		images := make([]tables.ProductImage, len(primaries))
+		for i, primary := range primaries {
+			images[i] = tables.ProductImage{URL: fmt.Sprintf("https://images.example.com/%s-%d.jpg", name, i), IsPrimary: primary}
+		}
+		return images
+	}
+	// storedPrimaries returns the URLs of the stored primary images of the product
+	storedPrimaries := func(productID uuid.UUID) []string {
+		t.Helper()
+		var urls []string
+		if err := ts.db.NewSelect().Model((*tables.ProductImage)(nil)).Column("url").Where("product_id = ? AND is_primary", productID).Scan(ctx, &urls); err != nil {
+			t.Fatalf("failed to read primary images: %v", err)
+		}
+		return urls
+	}
+
+	created, err := ts.products.CreateProduct(ctx, &tables.Product{
+		Name:        "Rozenboeket",
+		SKU:         "SKU-PRIMARY",
+		Price:       2500,
+		Description: "A hand-tied bouquet of red roses",
+		ProductType: tables.ProductTypeWedding,
+		IsActive:    true,
+		Images:      images("create", false, true, true),
+	})
+	if err != nil {
+		t.Fatalf("CreateProduct: %v", err)
+	}
+	if urls := storedPrimaries(created.ID); len(urls) != 1 || urls[0] != "https://images.example.com/create-1.jpg" {
+		t.Fatalf("expected the first declared primary to be stored as the only one, got %v", urls)
+	}
+
+	if err := ts.products.UpdateProduct(ctx, created.ID, &UpdateProductRequest{Images: images("update", false, false)}); err != nil {
+		t.Fatalf("UpdateProduct: %v", err)
+	}
+	if urls := storedPrimaries(created.ID); len(urls) != 1 || urls[0] != "https://images.example.com/update-0.jpg" {
+		t.Fatalf("expected the first image to be promoted on update, got %v", urls)
+	}
+}
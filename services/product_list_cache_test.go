@@ -0,0 +1,45 @@
+package services
+
+import (
+	"context"
+	"testing"
+	"time"
+)
+
+func TestCachedActiveProductsKeepDatabaseTotals(t *testing.T) {
+	ts := newTestServices(t)
+	ctx := context.Background()
+	for range 5 {
+		ts.seedProduct(t, 2500, true)
+	}
+
+	for page := 1; page <= 3; page++ {
+		fromDB, err := ts.products.GetActiveProducts(ctx, page, 2, false, "", ProductScope{})
+		if err != nil {
+			t.Fatalf("GetActiveProducts(page %d): %v", page, err)
+		}
+		if fromDB.Pagination.Total != 5 || fromDB.Pagination.TotalPages != 3 {
+			t.Fatalf("page %d: expected 5 products on 3 pages, got %+v", page, fromDB.Pagination)
+		}
+
+		// The page is cached in the background
+		deadline := time.Now().Add(5 * time.Second)
+		for {
+			if cached, _, _ := ts.cache.GetActiveProductsList(page, 2, false, ""); cached != nil {
+				break
+			}
+			if time.Now().After(deadline) {
+				t.Fatalf("page %d: expected the page to be cached", page)
+			}
+			time.Sleep(10 * time.Millisecond)
+		}
+
+		fromCache, err := ts.products.GetActiveProducts(ctx, page, 2, false, "", ProductScope{})
+		if err != nil {
+			t.Fatalf("GetActiveProducts(page %d) from the cache: %v", page, err)
+		}
+		if fromCache.Pagination != fromDB.Pagination || len(fromCache.Products) != len(fromDB.Products) {
+			t.Fatalf("page %d: expected the cached pagination %+v, got %+v", page, fromDB.Pagination, fromCache.Pagination)
+		}
+	}
+}
@@ -0,0 +1,66 @@
+package services
+
+import (
+	"context"
+	"errors"
+	"mamabloemetjes_server/lib"
+	"slices"
+	"testing"
+
+	"github.com/google/uuid"
+)
+
+func TestMadeToOrderProductStaysInStock(t *testing.T) {
+	ts := newTestServices(t)
+	ctx := context.Background()
+	madeToOrder := ts.seedProduct(t, 2500, true)
+	unique := ts.seedProduct(t, 3500, false)
+
+	req := orderRequest(madeToOrder, unique)
+	req.Products[madeToOrder.ID.String()] = 3
+	if _, err := ts.orders.CreateOrderFromRequest(ctx, req, nil); err != nil {
+		t.Fatalf("CreateOrderFromRequest: %v", err)
+	}
+
+	if !ts.reloadProduct(t, madeToOrder.ID).IsActive {
+		t.Fatal("expected the made-to-order product to stay in stock")
+	}
+	if ts.reloadProduct(t, unique.ID).IsActive {
+		t.Fatal("expected the one-of-a-kind product to be sold out")
+	}
+
+	active := true
+	list, err := ts.products.GetAllProducts(ctx, &ProductListOptions{IsActive: &active, PageSize: 50})
+	if err != nil {
+		t.Fatalf("GetAllProducts: %v", err)
+	}
+	listed := make([]uuid.UUID, 0, len(list.Products))
+	for _, product := range list.Products {
+		listed = append(listed, product.ID)
+	}
+	if !slices.Equal(listed, []uuid.UUID{madeToOrder.ID}) {
+		t.Fatalf("expected only the made-to-order product to be listed, got %v", listed)
+	}
+
+	// The made-to-order product can be ordered again, the sold one cannot
+	if _, err := ts.orders.CreateOrderFromRequest(ctx, orderRequest(madeToOrder), nil); err != nil {
+		t.Fatalf("expected the made-to-order product to be orderable again, got %v", err)
+	}
+	if _, err := ts.orders.CreateOrderFromRequest(ctx, orderRequest(unique), nil); !errors.Is(err, lib.ErrProductUnavailable) && !errors.Is(err, lib.ErrInsufficientStock) {
+		t.Fatalf("expected the sold product to be unavailable, got %v", err)
+	}
+}
+
+func TestOneOfAKindProductHasSingleUnit(t *testing.T) {
+	ts := newTestServices(t)
+	unique := ts.seedProduct(t, 3500, false)
+
+	req := orderRequest(unique)
+	req.Products[unique.ID.String()] = 2
+	if _, err := ts.orders.CreateOrderFromRequest(context.Background(), req, nil); !errors.Is(err, lib.ErrInsufficientStock) {
+		t.Fatalf("expected two units of a one-of-a-kind product to be rejected, got %v", err)
+	}
+	if !ts.reloadProduct(t, unique.ID).IsActive {
+		t.Fatal("expected the rejected order to leave the product in stock")
+	}
+}
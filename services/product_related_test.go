@@ -0,0 +1,59 @@
+package services
+
+import (
+	"context"
+	"mamabloemetjes_server/lib"
+	"mamabloemetjes_server/structs/tables"
+	"slices"
+	"testing"
+
+	"github.com/google/uuid"
+)
+
+func TestGetRelatedProducts(t *testing.T) {
+	ts := newTestServices(t)
+	ctx := context.Background()
+
+	target := ts.seedProduct(t, 2500, true)
+	closest := ts.seedProduct(t, 2600, true)
+	cheaper := ts.seedProduct(t, 1500, true)
+	pricier := ts.seedProduct(t, 4000, true)
+	soldOut := ts.seedProduct(t, 2500, false)
+	otherType := ts.seedProduct(t, 2500, true)
+	if _, err := ts.db.NewUpdate().Model((*tables.Product)(nil)).Set("is_active = false").Where("id = ?", soldOut.ID).Exec(ctx); err != nil {
+		t.Fatalf("failed to deactivate the product: %v", err)
+	}
+	if _, err := ts.db.NewUpdate().Model((*tables.Product)(nil)).Set("product_type = ?", tables.ProductTypeFuneral).Where("id = ?", otherType.ID).Exec(ctx); err != nil {
+		t.Fatalf("failed to change the product type: %v", err)
+	}
+
+	relatedIds := func(limit int) []uuid.UUID {
+		t.Helper()
+		related, err := ts.products.GetRelatedProducts(ctx, target.ID, limit)
+		if err != nil {
+			t.Fatalf("GetRelatedProducts: %v", err)
+		}
+		ids := make([]uuid.UUID, 0, len(related))
+		for _, product := range related {
+			ids = append(ids, product.ID)
+		}
+		return ids
+	}
+
+	// Active products of the same type, the closest in price first, never the product itself
+	if ids := relatedIds(10); !slices.Equal(ids, []uuid.UUID{closest.ID, cheaper.ID, pricier.ID}) {
+		t.Fatalf("expected the same-type products by price distance, got %v", ids)
+	}
+	if ids := relatedIds(2); !slices.Equal(ids, []uuid.UUID{closest.ID, cheaper.ID}) {
+		t.Fatalf("expected the limit to keep the closest two, got %v", ids)
+	}
+
+	// The result is cached per product and limit
+	if cached, err := ts.cache.GetRelatedProducts(target.ID, 2); err != nil || len(cached) != 2 {
+		t.Fatalf("expected the related products to be cached, got %d (err %v)", len(cached), err)
+	}
+
+	if _, err := ts.products.GetRelatedProducts(ctx, uuid.New(), 10); !lib.IsNotFound(err) {
+		t.Fatalf("expected an unknown product to be not found, got %v", err)
+	}
+}
@@ -0,0 +1,80 @@
+package services
+
+import (
+	"context"
+	"mamabloemetjes_server/structs/tables"
+	"slices"
+	"testing"
+	"time"
+)
+
+func TestProductScopeIncludes(t *testing.T) {
+	now := time.Now()
+	later := now.Add(24 * time.Hour)
+	active := &tables.Product{IsActive: true}
+	inactive := &tables.Product{IsActive: false}
+	upcoming := &tables.Product{IsActive: true, AvailableFrom: &later}
+
+	tests := []struct {
+		name     string
+		scope    ProductScope
+		product  *tables.Product
+		expected bool
+	}{
+		{"public scope returns active products", ProductScope{}, active, true},
+		{"public scope skips inactive products", ProductScope{}, inactive, false},
+		{"public scope skips products outside their window", ProductScope{}, upcoming, false},
+		{"inactive override returns inactive products", ProductScope{IncludeInactive: true}, inactive, true},
+		{"preview returns products outside their window", ProductScope{IncludeUnavailable: true}, upcoming, true},
+		{"preview still skips inactive products", ProductScope{IncludeUnavailable: true}, inactive, false},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			if got := tt.scope.includes(tt.product, now); got != tt.expected {
+				t.Fatalf("expected %v, got %v", tt.expected, got)
+			}
+		})
+	}
+}
+
+func TestGetProductsBySKUsScope(t *testing.T) {
+	ts := newTestServices(t)
+	ctx := context.Background()
+
+	active := ts.seedProduct(t, 2500, true)
+	sold := ts.seedProduct(t, 2500, false)
+	ts.seedOrder(t, time.Now(), sold) // Reserving the product deactivates it
+	skus := []string{active.SKU, sold.SKU}
+
+	// Once straight from the database, then with the inactive product served from the cache
+	for _, source := range []string{"database", "cache"} {
+		if source == "cache" {
+			if err := ts.cache.SetProducts([]tables.Product{*ts.reloadProduct(t, sold.ID)}, false); err != nil {
+				t.Fatalf("SetProducts: %v", err)
+			}
+		}
+
+		public, err := ts.products.GetProductsBySKUs(ctx, skus, false, ProductScope{})
+		if err != nil {
+			t.Fatalf("GetProductsBySKUs: %v", err)
+		}
+		if len(public) != 1 || public[0].ID != active.ID {
+			t.Fatalf("%s: expected only the active product by default, got %d products", source, len(public))
+		}
+
+		admin, err := ts.products.GetProductsBySKUs(ctx, skus, false, ProductScope{IncludeInactive: true})
+		if err != nil {
+			t.Fatalf("GetProductsBySKUs: %v", err)
+		}
+		found := make([]string, 0, len(admin))
+		for _, product := range admin {
+			found = append(found, product.SKU)
+		}
+		slices.Sort(found)
+		expected := slices.Clone(skus)
+		slices.Sort(expected)
+		if !slices.Equal(found, expected) {
+			t.Fatalf("%s: expected both products with the override, got %v", source, found)
+		}
+	}
+}
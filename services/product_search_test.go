@@ -0,0 +1,121 @@
+package services
+
+import (
+	"context"
+	"fmt"
+	"mamabloemetjes_server/database"
+	"mamabloemetjes_server/structs/tables"
+	"mamabloemetjes_server/testutil"
+	"testing"
+
+	"github.com/google/uuid"
+)
+
+// addSearchColumn runs the shipped migration adding products.search_vector, which the bun models leave out
+func addSearchColumn(t testing.TB, ctx context.Context, db *database.DB) {
+	t.Helper()
+	migration := testutil.SQLSection(t, "products_table.sql", "ALTER TABLE public.products ADD COLUMN IF NOT EXISTS search_vector", "    ) STORED;")
+	if _, err := db.ExecContext(ctx, migration); err != nil {
+		t.Fatalf("failed to add the search column: %v", err)
+	}
+}
+
+// searchProducts lists the products matching term in mode, in result order
+func searchProducts(t testing.TB, ps *ProductService, term string, mode SearchMode) (*ProductListResult, []uuid.UUID) {
+	t.Helper()
+	result, err := ps.GetAllProducts(context.Background(), &ProductListOptions{SearchTerm: term, SearchMode: mode, PageSize: 50})
+	if err != nil {
+		t.Fatalf("GetAllProducts(%s): %v", mode, err)
+	}
+	ids := make([]uuid.UUID, 0, len(result.Products))
+	for _, product := range result.Products {
+		ids = append(ids, product.ID)
+	}
+	return result, ids
+}
+
+func TestFullTextSearchRanksByRelevance(t *testing.T) {
+	ts := newTestServices(t)
+	ctx := context.Background()
+	addSearchColumn(t, ctx, ts.db)
+
+	// Seeded first, so it would sort last by creation date; full-text ranks the name match first
+	named := ts.seedNamedProduct(t, "Red roses", true)
+	described := ts.seedNamedProduct(t, "Summer mix", true)
+	if _, err := ts.db.NewUpdate().Model((*tables.Product)(nil)).Set("description = ?", "Tulips with a few roses").Where("id = ?", described.ID).Exec(ctx); err != nil {
+		t.Fatalf("failed to update the description: %v", err)
+	}
+	ts.seedNamedProduct(t, "Tulips", true)
+
+	result, ids := searchProducts(t, ts.products, "roses", SearchModeFullText)
+	if result.Filters.SearchMode != SearchModeFullText {
+		t.Fatalf("expected full-text search with the column present, got %s", result.Filters.SearchMode)
+	}
+	if len(ids) != 2 || ids[0] != named.ID || ids[1] != described.ID {
+		t.Fatalf("expected the name match ranked above the description match, got %v", ids)
+	}
+
+	// Full-text search matches words, ILIKE matches any substring
+	if _, ids := searchProducts(t, ts.products, "ros", SearchModeFullText); len(ids) != 0 {
+		t.Fatalf("expected no full-text match on a word fragment, got %v", ids)
+	}
+	if _, ids := searchProducts(t, ts.products, "ros", SearchModeILike); len(ids) != 2 {
+		t.Fatalf("expected ILIKE to match the fragment in both products, got %v", ids)
+	}
+}
+
+func TestFullTextSearchFallsBackWithoutColumn(t *testing.T) {
+	ts := newTestServices(t)
+	ts.seedNamedProduct(t, "Red roses", true)
+
+	// As if the migration had not run: the column check is done and found nothing
+	ps := NewProductService(testutil.Logger(), testutil.Config(), ts.db, ts.cache)
+	ps.searchColumnOnce.Do(func() {})
+
+	result, ids := searchProducts(t, ps, "ros", SearchModeFullText)
+	if result.Filters.SearchMode != SearchModeILike || len(ids) != 1 {
+		t.Fatalf("expected an ILIKE search matching the fragment, got %s with %d products", result.Filters.SearchMode, len(ids))
+	}
+}
+
+// BenchmarkProductSearch compares the ILIKE and full-text search paths on a seeded catalogue.
+// Run with TEST_DATABASE_URL set: go test ./services -run '^$' -bench ProductSearch
+func BenchmarkProductSearch(b *testing.B) {
+	db := testutil.DB(b)
+	testutil.Redis(b)
+	ctx := context.Background()
+	addSearchColumn(b, ctx, db)
+
+	words := []string{"roses", "tulips", "peonies", "lilies", "orchids", "sunflowers", "daisies", "lavender"}
+	products := make([]*tables.Product, 2000)
+	for i := range products {
+		id := uuid.New()
+		products[i] = &tables.Product{
+			ID:          id,
+			Name:        fmt.Sprintf("Bouquet of %s %d", words[i%len(words)], i),
+			SKU:         "SKU-" + id.String()[:8],
+			Price:       2500,
+			Subtotal:    2500,
+			Currency:    "EUR",
+			Description: fmt.Sprintf("Hand-tied %s with %s", words[(i+3)%len(words)], words[(i+5)%len(words)]),
+			ProductType: tables.ProductTypeWedding,
+			IsActive:    true,
+			MadeToOrder: true,
+		}
+	}
+	if _, err := db.NewInsert().Model(&products).Exec(ctx); err != nil {
+		b.Fatalf("failed to seed products: %v", err)
+	}
+	if _, err := db.ExecContext(ctx, "ANALYZE products"); err != nil {
+		b.Fatalf("failed to analyze products: %v", err)
+	}
+
+	ps := NewProductService(testutil.Logger(), testutil.Config(), db, NewCacheService(testutil.Logger(), testutil.Config()))
+	for _, mode := range []SearchMode{SearchModeILike, SearchModeFullText} {
+		b.Run(string(mode), func(b *testing.B) {
+			for b.Loop() {
+				searchProducts(b, ps, "peonies", mode)
+			}
+		})
+	}
+}
@@ -4,8 +4,13 @@ import (
 	"context"
 	"fmt"
 	"mamabloemetjes_server/database"
+	"mamabloemetjes_server/lib"
+	"mamabloemetjes_server/structs"
 	"mamabloemetjes_server/structs/tables"
+	"strings"
+	"sync"
 	"time"
+	"unicode/utf8"
 
 	"github.com/MonkyMars/gecho"
 	"github.com/google/uuid"
@@ -14,13 +19,18 @@ import (
 
 type ProductService struct {
 	logger       *gecho.Logger
+	cfg          *structs.Config
 	db           *database.DB
 	cacheService *CacheService
+
+	searchColumnOnce sync.Once
+	hasSearchColumn  bool // products.search_vector exists, so full-text search is available
 }
 
-func NewProductService(logger *gecho.Logger, db *database.DB, cacheService *CacheService) *ProductService {
+func NewProductService(logger *gecho.Logger, cfg *structs.Config, db *database.DB, cacheService *CacheService) *ProductService {
 	return &ProductService{
 		logger:       logger,
+		cfg:          cfg,
 		db:           db,
 		cacheService: cacheService,
 	}
@@ -37,12 +47,16 @@ type ProductListOptions struct {
 	MinPrice      *uint64    `json:"min_price,omitempty"`      // Minimum price in cents
 	MaxPrice      *uint64    `json:"max_price,omitempty"`      // Maximum price in cents
 	SearchTerm    string     `json:"search_term,omitempty"`    // Search in name, description, SKU
+	SearchMode    SearchMode `json:"search_mode,omitempty"`    // How SearchTerm is matched, ilike when empty
 	SKUs          []string   `json:"skus,omitempty"`           // Filter by specific SKUs
 	ExcludeSKUs   []string   `json:"exclude_skus,omitempty"`   // Exclude specific SKUs
 	CreatedAfter  *time.Time `json:"created_after,omitempty"`  // Products created after this date
 	CreatedBefore *time.Time `json:"created_before,omitempty"` // Products created before this date
 	ProductType   string     `json:"product_type,omitempty"`   // Product type filter - funeral or wedding
 
+	// Active products are limited to their availability window unless IncludeUnavailable is set (admin preview)
+	IncludeUnavailable bool `json:"include_unavailable,omitempty"`
+
 	// Sorting
 	SortBy        string `json:"sort_by"`        // Field to sort by (created_at, price, name)
 	SortDirection string `json:"sort_direction"` // ASC or DESC
@@ -54,12 +68,27 @@ type ProductListOptions struct {
 	Timeout time.Duration `json:"-"` // Query timeout (not exposed in JSON)
 }
 
+// SearchMode selects how a product search term is matched
+type SearchMode string
+
+const (
+	// SearchModeILike matches the term anywhere in the name, description or SKU
+	SearchModeILike SearchMode = "ilike"
+	// SearchModeFullText matches words against the search_vector column and ranks the results by relevance
+	SearchModeFullText SearchMode = "fulltext"
+)
+
+// productSearchQuery is the tsquery built from a full-text search term
+const productSearchQuery = "plainto_tsquery('english', ?)"
+
 // ProductListResult wraps the product list response with metadata
 type ProductListResult struct {
 	Products   []tables.Product    `json:"products"`
 	Pagination database.Pagination `json:"pagination"`
 	Filters    ProductListOptions  `json:"filters"`
 	QueryTime  time.Duration       `json:"query_time"`
+
+	Links *lib.PaginationLinks `json:"links,omitempty"` // Set by handlers when the client asks for links
 }
 
 // GetAllProducts retrieves products with comprehensive filtering, pagination, and error handling
@@ -78,6 +107,7 @@ func (ps *ProductService) GetAllProducts(ctx context.Context, opts *ProductListO
 		ps.logger.Error("Invalid product list options", gecho.Field("error", err), gecho.Field("options", opts))
 		return nil, fmt.Errorf("invalid options: %w", err)
 	}
+	ps.resolveSearchMode(ctx, opts)
 
 	// Add query timeout if not set
 	queryCtx := ctx
@@ -87,8 +117,8 @@ func (ps *ProductService) GetAllProducts(ctx context.Context, opts *ProductListO
 		defer cancel()
 	}
 
-	// Build the query
-	query := database.Query[tables.Product](ps.db)
+	// Build the query; the timeout also bounds each statement of the paginated query
+	query := database.Query[tables.Product](ps.db).Timeout(opts.Timeout)
 
 	// Apply filters
 	query = ps.applyFilters(query, opts)
@@ -131,16 +161,20 @@ func (ps *ProductService) GetAllProducts(ctx context.Context, opts *ProductListO
 }
 
 // GetProductByID retrieves a single product by ID with optional image preloading
-func (ps *ProductService) GetProductByID(ctx context.Context, id uuid.UUID, includeImages bool) (*tables.Product, error) {
+// With bypassCache the cached copy is skipped (used for admins, who need to see their edits immediately);
+// the fresh result is still written back so public reads pick it up too
+func (ps *ProductService) GetProductByID(ctx context.Context, id uuid.UUID, includeImages, bypassCache bool) (*tables.Product, error) {
 	startTime := time.Now()
 
 	// Try to get from cache first
-	cachedProduct, err := ps.cacheService.GetProductByID(id, includeImages)
-	if err != nil {
-		ps.logger.Warn("Failed to get product from cache", gecho.Field("error", err), gecho.Field("id", id))
-	} else if cachedProduct != nil {
-		ps.logger.Debug("Product retrieved from cache", gecho.Field("id", id), gecho.Field("duration", time.Since(startTime)))
-		return cachedProduct, nil
+	if !bypassCache {
+		cachedProduct, err := ps.cacheService.GetProductByID(id, includeImages)
+		if err != nil {
+			ps.logger.Warn("Failed to get product from cache", gecho.Field("error", err), gecho.Field("id", id))
+		} else if cachedProduct != nil {
+			ps.logger.Debug("Product retrieved from cache", gecho.Field("id", id), gecho.Field("duration", time.Since(startTime)))
+			return cachedProduct, nil
+		}
 	}
 
 	// Cache miss - fetch from database
@@ -154,6 +188,10 @@ func (ps *ProductService) GetProductByID(ctx context.Context, id uuid.UUID, incl
 
 	product, err := query.First(ctx)
 
+	if err = lib.MapPgError(err); lib.IsNotFound(err) {
+		ps.logger.Warn("Product not found", gecho.Field("id", id))
+		return nil, lib.ErrNotFound
+	}
 	if err != nil {
 		ps.logger.Error("Failed to fetch product by ID",
 			gecho.Field("id", id),
@@ -172,7 +210,7 @@ func (ps *ProductService) GetProductByID(ctx context.Context, id uuid.UUID, incl
 
 	if product == nil {
 		ps.logger.Warn("Product not found", gecho.Field("id", id))
-		return nil, fmt.Errorf("product not found")
+		return nil, lib.ErrNotFound
 	}
 
 	// Cache the product asynchronously
@@ -190,11 +228,19 @@ func (ps *ProductService) GetProductByID(ctx context.Context, id uuid.UUID, incl
 }
 
 // GetActiveProducts is a convenience method to get only active products with caching
-func (ps *ProductService) GetActiveProducts(ctx context.Context, page, pageSize int, includeImages bool, productType string) (*ProductListResult, error) {
+// Products outside their availability window are left out unless the scope includes them; such previews skip the cache
+func (ps *ProductService) GetActiveProducts(ctx context.Context, page, pageSize int, includeImages bool, productType string, scope ProductScope) (*ProductListResult, error) {
 	startTime := time.Now()
+	page, pageSize = lib.ClampPagination(page, pageSize)
+	preview := scope.IncludeUnavailable
 
 	// Try to get from cache first
-	cachedProducts, err := ps.cacheService.GetActiveProductsList(page, pageSize, includeImages, productType)
+	var cachedProducts []tables.Product
+	var cachedPagination *database.Pagination
+	var err error
+	if !preview {
+		cachedProducts, cachedPagination, err = ps.cacheService.GetActiveProductsList(page, pageSize, includeImages, productType)
+	}
 	if err != nil {
 		ps.logger.Warn("Failed to get active products from cache", gecho.Field("error", err))
 	} else if cachedProducts != nil {
@@ -206,41 +252,33 @@ func (ps *ProductService) GetActiveProducts(ctx context.Context, page, pageSize
 
 		// Build result from cache
 		return &ProductListResult{
-			Products: cachedProducts,
-			Pagination: database.Pagination{
-				Page:     page,
-				PageSize: pageSize,
-				Total:    len(cachedProducts),
-			},
+			Products:   cachedProducts,
+			Pagination: *cachedPagination,
 			Filters: ProductListOptions{
 				Page:          page,
 				PageSize:      pageSize,
 				IncludeImages: includeImages,
+				ProductType:   productType,
 			},
 			QueryTime: time.Duration(time.Since(startTime).Milliseconds()),
 		}, nil
 	}
 
 	// Cache miss - fetch from database
-	isActive := true
-	opts := &ProductListOptions{
-		Page:          page,
-		PageSize:      pageSize,
-		IsActive:      &isActive,
-		IncludeImages: includeImages,
-		SortBy:        "created_at",
-		SortDirection: "DESC",
-		ProductType:   productType,
-	}
+	opts := activeProductsOptions(page, pageSize, includeImages, productType)
+	opts.IncludeUnavailable = preview
 
 	result, err := ps.GetAllProducts(ctx, opts)
 	if err != nil {
 		return nil, err
 	}
+	if preview {
+		return result, nil
+	}
 
 	// Cache the products asynchronously
 	go func() {
-		if err := ps.cacheService.SetActiveProductsList(page, pageSize, includeImages, result.Products, ""); err != nil {
+		if err := ps.cacheService.SetActiveProductsList(page, pageSize, includeImages, result.Products, result.Pagination, productType); err != nil {
 			ps.logger.Warn("Failed to cache active products", gecho.Field("error", err))
 		}
 	}()
@@ -248,6 +286,20 @@ func (ps *ProductService) GetActiveProducts(ctx context.Context, page, pageSize
 	return result, nil
 }
 
+// activeProductsOptions returns the list options behind a cached page of active products
+func activeProductsOptions(page, pageSize int, includeImages bool, productType string) *ProductListOptions {
+	isActive := true
+	return &ProductListOptions{
+		Page:          page,
+		PageSize:      pageSize,
+		IsActive:      &isActive,
+		IncludeImages: includeImages,
+		SortBy:        "created_at",
+		SortDirection: "DESC",
+		ProductType:   productType,
+	}
+}
+
 // GetProductsByIds retrieves multiple products by their IDs
 func (ps *ProductService) GetProductsByIds(ctx context.Context, ids []uuid.UUID) ([]*tables.Product, error) {
 	startTime := time.Now()
@@ -285,17 +337,51 @@ func (ps *ProductService) GetProductsByIds(ctx context.Context, ids []uuid.UUID)
 	return result, nil
 }
 
-// GetProductsBySKUs retrieves multiple products by their SKUs
-func (ps *ProductService) GetProductsBySKUs(ctx context.Context, skus []string, includeImages bool) ([]tables.Product, error) {
+// ProductScope controls which products a lookup may return; the zero value is the public scope
+// Products are never soft deleted; public reads return active products inside their availability window
+type ProductScope struct {
+	IncludeInactive    bool // Admin override: also return deactivated (e.g. sold) products
+	IncludeUnavailable bool // Admin override: also return products outside their availability window (preview)
+}
+
+// includes reports whether a product is visible within the scope at time t
+func (s ProductScope) includes(product *tables.Product, t time.Time) bool {
+	if s.IncludeInactive {
+		return true
+	}
+	return product.IsActive && (s.IncludeUnavailable || product.IsAvailableAt(t))
+}
+
+// GetProductsBySKUs retrieves multiple products by their SKUs within the given scope
+func (ps *ProductService) GetProductsBySKUs(ctx context.Context, skus []string, includeImages bool, scope ProductScope) ([]tables.Product, error) {
 	startTime := time.Now()
 
 	if len(skus) == 0 {
 		return []tables.Product{}, nil
 	}
 
+	// Serve what the cache has; an unreachable cache means every SKU is fetched
+	cached, missing, err := ps.cacheService.GetProducts(skus, includeImages)
+	if err != nil {
+		ps.logger.Warn("Failed to get products by SKUs from cache", gecho.Field("error", err))
+		cached, missing = nil, skus
+	}
+
+	// Cached products are stored regardless of scope, so the scope is checked here
+	now := time.Now()
+	products := make([]tables.Product, 0, len(skus))
+	for _, product := range cached {
+		if scope.includes(product, now) {
+			products = append(products, *product)
+		}
+	}
+	if len(missing) == 0 {
+		return products, nil
+	}
+
 	// Convert SKUs to interface slice
-	skuInterfaces := make([]any, len(skus))
-	for i, sku := range skus {
+	skuInterfaces := make([]any, len(missing))
+	for i, sku := range missing {
 		skuInterfaces[i] = sku
 	}
 
@@ -303,21 +389,35 @@ func (ps *ProductService) GetProductsBySKUs(ctx context.Context, skus []string,
 		WhereIn("sku", skuInterfaces).
 		Timeout(10 * time.Second)
 
+	if !scope.IncludeInactive {
+		query = query.Where("is_active", true)
+		if !scope.IncludeUnavailable {
+			query = whereAvailableAt(query, now)
+		}
+	}
+
 	if includeImages {
 		query = query.Relation("Images")
 	}
 
-	products, err := query.All(ctx)
+	fetched, err := query.All(ctx)
 	if err != nil {
 		ps.logger.Error("Failed to fetch products by SKUs",
-			gecho.Field("skus", skus),
+			gecho.Field("skus", missing),
 			gecho.Field("error", err),
 			gecho.Field("duration", time.Since(startTime)),
 		)
 		return nil, fmt.Errorf("failed to fetch products by SKUs: %w", err)
 	}
 
-	return products, nil
+	// Cache the fetched products asynchronously
+	go func() {
+		if err := ps.cacheService.SetProducts(fetched, includeImages); err != nil {
+			ps.logger.Warn("Failed to cache products by SKUs", gecho.Field("error", err))
+		}
+	}()
+
+	return append(products, fetched...), nil
 }
 
 // GetProductCount returns the total count of products matching the filters
@@ -325,8 +425,12 @@ func (ps *ProductService) GetProductCount(ctx context.Context, opts *ProductList
 	if opts == nil {
 		opts = &ProductListOptions{}
 	}
+	if err := ps.checkFilterLimits(opts); err != nil {
+		return 0, err
+	}
+	ps.resolveSearchMode(ctx, opts)
 
-	query := database.Query[tables.Product](ps.db)
+	query := database.Query[tables.Product](ps.db).Timeout(ps.cfg.Products.ListQueryTimeout)
 	query = ps.applyFilters(query, opts)
 
 	count, err := query.Count(ctx)
@@ -338,17 +442,30 @@ func (ps *ProductService) GetProductCount(ctx context.Context, opts *ProductList
 	return count, nil
 }
 
-// applyDefaultOptions sets default values for unspecified options
-func (ps *ProductService) applyDefaultOptions(opts *ProductListOptions) {
-	if opts.Page < 1 {
-		opts.Page = 1
+// GetActiveProductCount returns the number of active products, served from the count cache when possible
+// The cached value is dropped together with the other product counts whenever a product changes
+func (ps *ProductService) GetActiveProductCount(ctx context.Context) (int, error) {
+	cached, err := ps.cacheService.GetProductCount(ActiveProductCountKey)
+	if err == nil && cached != nil {
+		return *cached, nil
 	}
-	if opts.PageSize < 1 {
-		opts.PageSize = 20
+
+	count, err := whereAvailableAt(database.Query[tables.Product](ps.db).Where("is_active", true), time.Now()).Count(ctx)
+	if err != nil {
+		ps.logger.Error("Failed to count active products", gecho.Field("error", err))
+		return 0, fmt.Errorf("failed to count active products: %w", err)
 	}
-	if opts.PageSize > 100 {
-		opts.PageSize = 100 // Max page size for performance
+
+	if err := ps.cacheService.SetProductCount(ActiveProductCountKey, count); err != nil {
+		ps.logger.Warn("Failed to cache active product count", gecho.Field("error", err))
 	}
+
+	return count, nil
+}
+
+// applyDefaultOptions sets default values for unspecified options
+func (ps *ProductService) applyDefaultOptions(opts *ProductListOptions) {
+	opts.Page, opts.PageSize = lib.ClampPagination(opts.Page, opts.PageSize)
 	if opts.SortBy == "" {
 		opts.SortBy = "created_at"
 	}
@@ -356,27 +473,34 @@ func (ps *ProductService) applyDefaultOptions(opts *ProductListOptions) {
 		opts.SortDirection = "DESC"
 	}
 	if opts.Timeout == 0 {
-		opts.Timeout = 30 * time.Second // Default 30s timeout
+		opts.Timeout = ps.cfg.Products.ListQueryTimeout
 	}
+	if opts.SearchMode == "" {
+		opts.SearchMode = SearchModeILike
+	}
+}
+
+// ProductSortFields lists the columns products can be sorted by
+var ProductSortFields = map[string]bool{
+	"created_at": true,
+	"updated_at": true,
+	"price":      true,
+	"name":       true,
+	"sku":        true,
 }
 
 // validateOptions validates the provided options
 func (ps *ProductService) validateOptions(opts *ProductListOptions) error {
-	// Validate sort field
-	validSortFields := map[string]bool{
-		"created_at": true,
-		"updated_at": true,
-		"price":      true,
-		"name":       true,
-		"sku":        true,
+	if err := lib.ValidateSortField(opts.SortBy, ProductSortFields); err != nil {
+		return err
 	}
-	if !validSortFields[opts.SortBy] {
-		return fmt.Errorf("invalid sort field: %s", opts.SortBy)
+	if err := lib.ValidateSortDirection(opts.SortDirection); err != nil {
+		return err
 	}
 
-	// Validate sort direction
-	if opts.SortDirection != "ASC" && opts.SortDirection != "DESC" {
-		return fmt.Errorf("invalid sort direction: %s (must be ASC or DESC)", opts.SortDirection)
+	// Validate search mode
+	if opts.SearchMode != SearchModeILike && opts.SearchMode != SearchModeFullText {
+		return lib.NewFieldError("search_mode", "must be ilike or fulltext")
 	}
 
 	// Validate price range
@@ -384,14 +508,70 @@ func (ps *ProductService) validateOptions(opts *ProductListOptions) error {
 		return fmt.Errorf("min_price cannot be greater than max_price")
 	}
 
+	return ps.checkFilterLimits(opts)
+}
+
+// checkFilterLimits rejects filter combinations that are too expensive to run: oversized SKU lists,
+// and very short searches (which match most rows with a leading-wildcard ILIKE) without another selective filter
+func (ps *ProductService) checkFilterLimits(opts *ProductListOptions) error {
+	maxSKUs := ps.cfg.Products.MaxFilterSKUs
+	if len(opts.SKUs) > maxSKUs {
+		return lib.NewFieldError("skus", fmt.Sprintf("must contain at most %d SKUs", maxSKUs))
+	}
+	if len(opts.ExcludeSKUs) > maxSKUs {
+		return lib.NewFieldError("exclude_skus", fmt.Sprintf("must contain at most %d SKUs", maxSKUs))
+	}
+
+	minLength := ps.cfg.Products.MinSearchLength
+	if opts.SearchTerm != "" && utf8.RuneCountInString(opts.SearchTerm) < minLength {
+		selective := opts.ProductType != "" || len(opts.SKUs) > 0 ||
+			opts.MinPrice != nil || opts.MaxPrice != nil ||
+			opts.CreatedAfter != nil || opts.CreatedBefore != nil
+		if !selective {
+			return lib.NewFieldError("search", fmt.Sprintf("must be at least %d characters unless combined with another filter", minLength))
+		}
+	}
+
+	return nil
+}
+
+// validateImageCount enforces the configured maximum number of images per product
+func (ps *ProductService) validateImageCount(count int) error {
+	if maxImages := ps.cfg.Products.MaxImages; count > maxImages {
+		return lib.NewFieldError("images", fmt.Sprintf("must contain at most %d images", maxImages))
+	}
 	return nil
 }
 
+// selectPrimaryImage leaves exactly one primary image in a non-empty set: the first image declared primary wins
+// and later ones are demoted; when none is declared primary, the first image is promoted
+func selectPrimaryImage(images []tables.ProductImage) {
+	if len(images) == 0 {
+		return
+	}
+
+	hasPrimary := false
+	for i := range images {
+		if images[i].IsPrimary && !hasPrimary {
+			hasPrimary = true
+			continue
+		}
+		images[i].IsPrimary = false
+	}
+
+	if !hasPrimary {
+		images[0].IsPrimary = true
+	}
+}
+
 // applyFilters applies all filter conditions to the query
 func (ps *ProductService) applyFilters(query *database.QueryBuilder[tables.Product], opts *ProductListOptions) *database.QueryBuilder[tables.Product] {
 	// Filter by active status (default to active only if not specified)
 	if opts.IsActive != nil {
 		query = query.Where("is_active", *opts.IsActive)
+		if *opts.IsActive && !opts.IncludeUnavailable {
+			query = whereAvailableAt(query, time.Now())
+		}
 	}
 
 	// Filter by price range
@@ -403,7 +583,9 @@ func (ps *ProductService) applyFilters(query *database.QueryBuilder[tables.Produ
 	}
 
 	// Search in name, description, or SKU
-	if opts.SearchTerm != "" {
+	if opts.SearchTerm != "" && opts.SearchMode == SearchModeFullText {
+		query = query.WhereRaw("search_vector @@ "+productSearchQuery, opts.SearchTerm)
+	} else if opts.SearchTerm != "" {
 		searchPattern := "%" + opts.SearchTerm + "%"
 		query = query.WhereRaw(
 			"(name ILIKE ? OR description ILIKE ? OR sku ILIKE ?)",
@@ -435,16 +617,69 @@ func (ps *ProductService) applyFilters(query *database.QueryBuilder[tables.Produ
 	}
 
 	// Filter by creation date range
-	if opts.CreatedAfter != nil {
+	switch {
+	case opts.CreatedAfter != nil && opts.CreatedBefore != nil:
+		query = query.WhereBetween("created_at", *opts.CreatedAfter, *opts.CreatedBefore)
+	case opts.CreatedAfter != nil:
 		query = query.WhereOp("created_at", ">=", *opts.CreatedAfter)
-	}
-	if opts.CreatedBefore != nil {
+	case opts.CreatedBefore != nil:
 		query = query.WhereOp("created_at", "<=", *opts.CreatedBefore)
 	}
 
 	return query
 }
 
+// whereAvailableAt limits the query to products whose availability window contains t
+func whereAvailableAt(query *database.QueryBuilder[tables.Product], t time.Time) *database.QueryBuilder[tables.Product] {
+	return query.
+		WhereRaw("(available_from IS NULL OR available_from <= ?)", t).
+		WhereRaw("(available_until IS NULL OR available_until > ?)", t)
+}
+
+// validateAvailabilityWindow rejects a window that ends before it starts
+func validateAvailabilityWindow(from, until *time.Time) error {
+	if from != nil && until != nil && !until.After(*from) {
+		return lib.NewFieldError("available_until", "must be after available_from")
+	}
+	return nil
+}
+
+// normalizeProductType lowercases a product type and rejects anything outside tables.ProductTypes,
+// so filters comparing against the lowercase values keep matching
+func normalizeProductType(productType string) (string, error) {
+	normalized, ok := tables.NormalizeProductType(productType)
+	if !ok {
+		return "", lib.NewFieldError("product_type", "must be one of: "+strings.Join(tables.ProductTypes, " "))
+	}
+	return normalized, nil
+}
+
+// resolveSearchMode falls back to ILIKE search when full-text search is requested but the database
+// does not have the search_vector column yet (the migration has not been run)
+func (ps *ProductService) resolveSearchMode(ctx context.Context, opts *ProductListOptions) {
+	if opts.SearchMode != SearchModeFullText {
+		return
+	}
+
+	ps.searchColumnOnce.Do(func() {
+		exists, err := ps.db.NewSelect().
+			TableExpr("information_schema.columns").
+			Where("table_schema = current_schema()").
+			Where("table_name = ?", "products").
+			Where("column_name = ?", "search_vector").
+			Exists(ctx)
+		if err != nil {
+			ps.logger.Warn("Failed to check for the product search column, using ILIKE search", gecho.Field("error", err))
+			return
+		}
+		ps.hasSearchColumn = exists
+	})
+
+	if !ps.hasSearchColumn {
+		opts.SearchMode = SearchModeILike
+	}
+}
+
 // applySorting applies sorting to the query
 func (ps *ProductService) applySorting(query *database.QueryBuilder[tables.Product], opts *ProductListOptions) *database.QueryBuilder[tables.Product] {
 	var direction database.OrderDirection
@@ -454,6 +689,11 @@ func (ps *ProductService) applySorting(query *database.QueryBuilder[tables.Produ
 		direction = database.DESC
 	}
 
+	// Full-text results are ranked by relevance first
+	if opts.SearchTerm != "" && opts.SearchMode == SearchModeFullText {
+		query = query.OrderByRaw("ts_rank(search_vector, "+productSearchQuery+") DESC", opts.SearchTerm)
+	}
+
 	query = query.OrderBy(opts.SortBy, direction)
 
 	// Add secondary sort by ID for consistent ordering
@@ -467,6 +707,20 @@ func (ps *ProductService) applySorting(query *database.QueryBuilder[tables.Produ
 func (ps *ProductService) CreateProduct(ctx context.Context, product *tables.Product) (*tables.Product, error) {
 	startTime := time.Now()
 
+	if err := ps.validateImageCount(len(product.Images)); err != nil {
+		return nil, err
+	}
+	if err := validateAvailabilityWindow(product.AvailableFrom, product.AvailableUntil); err != nil {
+		return nil, err
+	}
+	if product.ProductType != "" {
+		productType, err := normalizeProductType(product.ProductType)
+		if err != nil {
+			return nil, err
+		}
+		product.ProductType = productType
+	}
+
 	// Generate UUID for product if not set (needed for image references)
 	if product.ID == uuid.Nil {
 		product.ID = uuid.New()
@@ -475,39 +729,62 @@ func (ps *ProductService) CreateProduct(ctx context.Context, product *tables.Pro
 	// Calculate subtotal
 	product.Subtotal = product.Price - product.Discount + product.Tax
 
+	// Default to the shop currency
+	if product.Currency == "" {
+		product.Currency = ps.cfg.Orders.DefaultCurrency
+	}
+
 	// Store images separately to insert them after product creation
 	images := product.Images
 	product.Images = nil // Remove images from product to avoid relation insert issues
 
-	// Insert product into database
-	product, err := database.Query[tables.Product](ps.db).Insert(ctx, product)
-	if err != nil {
-		ps.logger.Error("Failed to create product",
-			gecho.Field("error", err),
-			gecho.Field("product_name", product.Name),
-			gecho.Field("duration", time.Since(startTime)),
-		)
-		return nil, fmt.Errorf("failed to create product: %w", err)
+	for i := range images {
+		// Generate UUID for image if not set
+		if images[i].ID == uuid.Nil {
+			images[i].ID = uuid.New()
+		}
+		images[i].ProductID = product.ID
 	}
+	selectPrimaryImage(images)
+
+	// Product and images are written together so a failure never leaves orphaned rows
+	err := database.Transaction(ps.db, ctx, func(tx bun.Tx) error {
+		// Check the SKU up front instead of relying on the unique violation after the insert
+		exists, err := tx.NewSelect().
+			Model((*tables.Product)(nil)).
+			Where("sku = ?", product.SKU).
+			Exists(ctx)
+		if err != nil {
+			return lib.MapPgError(err)
+		}
+		if exists {
+			return lib.ErrDuplicateSKU
+		}
 
-	// Insert images if any
-	if len(images) > 0 {
-		for i := range images {
-			// Generate UUID for image if not set
-			if images[i].ID == uuid.Nil {
-				images[i].ID = uuid.New()
+		if _, err := tx.NewInsert().Model(product).Exec(ctx); err != nil {
+			// A concurrent create with the same SKU can still slip past the check above
+			if lib.IsUniqueViolation(lib.MapPgError(err)) {
+				return lib.ErrDuplicateSKU
 			}
-			images[i].ProductID = product.ID
+			return fmt.Errorf("failed to create product: %w", err)
 		}
 
-		_, imgErr := database.Query[tables.ProductImage](ps.db).InsertMany(ctx, images)
-		if imgErr != nil {
-			ps.logger.Error("Failed to insert product images",
-				gecho.Field("error", imgErr),
-				gecho.Field("product_id", product.ID),
-			)
-			return nil, fmt.Errorf("failed to insert product images: %w", imgErr)
+		if len(images) > 0 {
+			if _, err := tx.NewInsert().Model(&images).Exec(ctx); err != nil {
+				return fmt.Errorf("failed to insert product images: %w", err)
+			}
 		}
+
+		return nil
+	})
+	if err != nil {
+		ps.logger.Error("Failed to create product",
+			gecho.Field("error", err),
+			gecho.Field("product_name", product.Name),
+			gecho.Field("sku", product.SKU),
+			gecho.Field("duration", time.Since(startTime)),
+		)
+		return nil, err
 	}
 
 	// Restore images to the product object for the response
@@ -538,12 +815,80 @@ type UpdateProductRequest struct {
 	Discount    *uint64               `json:"discount,omitempty" validate:"omitempty,gte=0"`
 	Tax         *uint64               `json:"tax,omitempty" validate:"omitempty,gte=0"`
 	Description *string               `json:"description,omitempty" validate:"omitempty,min=10,max=2000"`
-	ProductType *string               `json:"product_type,omitempty" validate:"omitempty,min=2,max=100"`
+	ProductType *string               `json:"product_type,omitempty" validate:"omitempty,product_type"`
+	Currency    *string               `json:"currency,omitempty" validate:"omitempty,len=3,uppercase"`
 	IsActive    *bool                 `json:"is_active,omitempty"`
+	MadeToOrder *bool                 `json:"made_to_order,omitempty"`
 	Images      []tables.ProductImage `json:"images,omitempty" validate:"omitempty,dive"`
+
+	AvailableFrom     *time.Time `json:"available_from,omitempty"`
+	AvailableUntil    *time.Time `json:"available_until,omitempty"`
+	ClearAvailability bool       `json:"clear_availability,omitempty"` // Remove the availability window; the product is always available again
 }
 
+// UpdateProduct applies a partial update to a product and invalidates its caches once committed
 func (ps *ProductService) UpdateProduct(ctx context.Context, productID uuid.UUID, req *UpdateProductRequest) error {
+	if err := ps.updateProduct(ctx, productID, req); err != nil {
+		return err
+	}
+
+	// Invalidate product caches asynchronously
+	go func() {
+		if err := ps.cacheService.InvalidateProductCaches(productID); err != nil {
+			ps.logger.Warn("Failed to invalidate product caches after update",
+				gecho.Field("error", err),
+				gecho.Field("product_id", productID),
+			)
+		}
+	}()
+
+	return nil
+}
+
+// UpdateProducts applies many product updates, each in its own transaction so one failure does not block the rest.
+// It returns the error per product (nil when updated); caches of the updated products are invalidated in one go
+func (ps *ProductService) UpdateProducts(ctx context.Context, updates map[uuid.UUID]*UpdateProductRequest) map[uuid.UUID]error {
+	results := make(map[uuid.UUID]error, len(updates))
+	updated := make([]uuid.UUID, 0, len(updates))
+
+	for productID, req := range updates {
+		err := ps.updateProduct(ctx, productID, req)
+		results[productID] = err
+		if err == nil {
+			updated = append(updated, productID)
+		}
+	}
+
+	if len(updated) > 0 {
+		go func() {
+			if err := ps.cacheService.InvalidateProductCachesBulk(updated); err != nil {
+				ps.logger.Warn("Failed to invalidate product caches after bulk update",
+					gecho.Field("error", err),
+					gecho.Field("products", len(updated)),
+				)
+			}
+		}()
+	}
+
+	return results
+}
+
+// updateProduct writes a partial product update in a transaction without touching the cache
+func (ps *ProductService) updateProduct(ctx context.Context, productID uuid.UUID, req *UpdateProductRequest) error {
+	if err := ps.validateImageCount(len(req.Images)); err != nil {
+		return err
+	}
+	if err := validateAvailabilityWindow(req.AvailableFrom, req.AvailableUntil); err != nil {
+		return err
+	}
+	if req.ProductType != nil {
+		productType, err := normalizeProductType(*req.ProductType)
+		if err != nil {
+			return err
+		}
+		req.ProductType = &productType
+	}
+
 	return database.Transaction(ps.db, ctx, func(tx bun.Tx) error {
 		// Build update map with only provided fields
 		updateData := make(map[string]any)
@@ -568,11 +913,31 @@ func (ps *ProductService) UpdateProduct(ctx context.Context, productID uuid.UUID
 		}
 		if req.IsActive != nil {
 			updateData["is_active"] = *req.IsActive
+			// The admin decides from here on, so releasing the order that held it no longer changes it
+			updateData["reserved_order_id"] = nil
+		}
+		if req.MadeToOrder != nil {
+			updateData["made_to_order"] = *req.MadeToOrder
 		}
 
 		if req.ProductType != nil {
 			updateData["product_type"] = *req.ProductType
 		}
+		if req.Currency != nil {
+			updateData["currency"] = *req.Currency
+		}
+
+		// A window set in the same request wins over clearing it
+		if req.ClearAvailability {
+			updateData["available_from"] = nil
+			updateData["available_until"] = nil
+		}
+		if req.AvailableFrom != nil {
+			updateData["available_from"] = *req.AvailableFrom
+		}
+		if req.AvailableUntil != nil {
+			updateData["available_until"] = *req.AvailableUntil
+		}
 
 		// Handle images update if provided
 		if req.Images != nil {
@@ -583,24 +948,13 @@ func (ps *ProductService) UpdateProduct(ctx context.Context, productID uuid.UUID
 
 			// Insert new images if any provided
 			if len(req.Images) > 0 {
-				hasPrimary := false
 				for i := range req.Images {
 					if req.Images[i].ID == uuid.Nil {
 						req.Images[i].ID = uuid.New()
 					}
 					req.Images[i].ProductID = productID
-					if req.Images[i].IsPrimary {
-						if hasPrimary {
-							req.Images[i].IsPrimary = false
-						} else {
-							hasPrimary = true
-						}
-					}
-				}
-
-				if !hasPrimary && len(req.Images) > 0 {
-					req.Images[0].IsPrimary = true
 				}
+				selectPrimaryImage(req.Images)
 
 				if _, err := ps.db.NewInsert().Model(&req.Images).Exec(ctx); err != nil {
 					return fmt.Errorf("failed to insert new images: %w", err)
@@ -638,22 +992,313 @@ func (ps *ProductService) UpdateProduct(ctx context.Context, productID uuid.UUID
 			}
 		}
 
-		// Invalidate product caches asynchronously
-		go func() {
-			if err := ps.cacheService.InvalidateProductCaches(productID); err != nil {
-				ps.logger.Warn("Failed to invalidate product caches after update",
-					gecho.Field("error", err),
-					gecho.Field("product_id", productID),
-				)
+		return nil
+	})
+}
+
+// SuggestProducts returns active products whose name or SKU starts with query, for search-as-you-type
+// Results are cached per prefix for a short TTL
+func (ps *ProductService) SuggestProducts(ctx context.Context, query string) ([]tables.Product, error) {
+	prefix := strings.ToLower(strings.TrimSpace(query))
+	if prefix == "" {
+		return []tables.Product{}, nil
+	}
+
+	if cached, err := ps.cacheService.GetProductSuggestions(prefix); err == nil && cached != nil {
+		return cached, nil
+	}
+
+	// Escape LIKE wildcards so the input is matched literally; a plain prefix keeps the text_pattern_ops index usable
+	pattern := likeEscaper.Replace(prefix) + "%"
+
+	products, err := whereAvailableAt(database.Query[tables.Product](ps.db).Where("is_active", true), time.Now()).
+		WhereRaw("(lower(name) LIKE ? OR lower(sku) LIKE ?)", pattern, pattern).
+		OrderBy("name", database.ASC).
+		Limit(ps.cfg.Products.SuggestLimit).
+		All(ctx)
+	if err != nil {
+		return nil, fmt.Errorf("failed to fetch product suggestions: %w", err)
+	}
+
+	if err := ps.cacheService.SetProductSuggestions(prefix, products); err != nil {
+		ps.logger.Warn("Failed to cache product suggestions",
+			gecho.Field("error", err),
+			gecho.Field("prefix", prefix),
+		)
+	}
+
+	return products, nil
+}
+
+// likeEscaper escapes the LIKE wildcards in user input
+var likeEscaper = strings.NewReplacer(`\`, `\\`, `%`, `\%`, `_`, `\_`)
+
+// RecordView records a product view for trending, best-effort and non-blocking
+func (ps *ProductService) RecordView(ctx context.Context, productID uuid.UUID) {
+	go func() {
+		if err := ps.cacheService.IncrementProductView(productID); err != nil {
+			ps.logger.Debug("Failed to record product view", gecho.Field("error", err), gecho.Field("product_id", productID))
+		}
+	}()
+}
+
+// GetTrendingProducts returns active products ranked by recent views
+func (ps *ProductService) GetTrendingProducts(ctx context.Context, limit int) ([]*tables.Product, error) {
+	// Fetch extra ids so inactive products can be skipped without shrinking the list
+	ids, err := ps.cacheService.GetTrendingProductIDs(limit * 2)
+	if err != nil {
+		return nil, fmt.Errorf("failed to get trending product ids: %w", err)
+	}
+	if len(ids) == 0 {
+		return []*tables.Product{}, nil
+	}
+
+	products, err := ps.GetProductsByIds(ctx, ids)
+	if err != nil {
+		return nil, err
+	}
+
+	productMap := make(map[uuid.UUID]*tables.Product, len(products))
+	for _, product := range products {
+		productMap[product.ID] = product
+	}
+
+	// Keep the ranking order from Redis
+	now := time.Now()
+	result := make([]*tables.Product, 0, limit)
+	for _, id := range ids {
+		product, ok := productMap[id]
+		if !ok || !product.IsActive || !product.IsAvailableAt(now) {
+			continue
+		}
+		result = append(result, product)
+		if len(result) == limit {
+			break
+		}
+	}
+
+	return result, nil
+}
+
+// GetRelatedProducts returns up to limit active products of the same product type as the given product,
+// the closest in price first. Products have no further attributes to match on, so one without a type has no related products
+func (ps *ProductService) GetRelatedProducts(ctx context.Context, id uuid.UUID, limit int) ([]tables.Product, error) {
+	if cached, err := ps.cacheService.GetRelatedProducts(id, limit); err == nil && cached != nil {
+		return cached, nil
+	}
+
+	product, err := ps.GetProductByID(ctx, id, false, false)
+	if err != nil {
+		return nil, err
+	}
+
+	related := []tables.Product{}
+	if product.ProductType != "" {
+		related, err = whereAvailableAt(database.Query[tables.Product](ps.db).Where("is_active", true), time.Now()).
+			Where("product_type", product.ProductType).
+			WhereNot("id", product.ID).
+			OrderByRaw("abs(price::bigint - ?::bigint)", product.Price).
+			OrderBy("name", database.ASC).
+			Limit(limit).
+			Relation("Images", func(q *bun.SelectQuery) *bun.SelectQuery {
+				return q.OrderExpr("is_primary DESC")
+			}).
+			All(ctx)
+		if err != nil {
+			return nil, fmt.Errorf("failed to fetch related products: %w", err)
+		}
+	}
+
+	if err := ps.cacheService.SetRelatedProducts(id, limit, related); err != nil {
+		ps.logger.Warn("Failed to cache related products",
+			gecho.Field("error", err),
+			gecho.Field("product_id", id),
+		)
+	}
+
+	return related, nil
+}
+
+// ImageHostMigrationResult reports what a product image URL migration changed (or would change on a dry run)
+type ImageHostMigrationResult struct {
+	Matched  int  `json:"matched"`  // Images whose URL starts with the old prefix
+	Updated  int  `json:"updated"`  // Images rewritten, always 0 on a dry run
+	Products int  `json:"products"` // Distinct products owning a matched image
+	DryRun   bool `json:"dry_run"`
+}
+
+// MigrateImageHost rewrites product image URLs starting with oldPrefix to start with newPrefix instead
+// Images are processed in batches with one transaction per batch; a dry run only counts the matches.
+// When newPrefix extends oldPrefix, URLs already starting with newPrefix are left alone, so a rerun
+// does not apply the new prefix twice. The caches of products in committed batches are invalidated
+// even when a later batch fails
+func (ps *ProductService) MigrateImageHost(ctx context.Context, oldPrefix, newPrefix string, dryRun bool, batchSize int) (*ImageHostMigrationResult, error) {
+	result := &ImageHostMigrationResult{DryRun: dryRun}
+	affectedProducts := make(map[uuid.UUID]struct{})
+	updatedProducts := make(map[uuid.UUID]struct{})
+	skipMigrated := strings.HasPrefix(newPrefix, oldPrefix)
+
+	// Rewritten rows still match the new prefix, so the offset-based batches keep a stable row set
+	query := database.Query[tables.ProductImage](ps.db).
+		WhereRaw("(url LIKE ? OR url LIKE ?)", likeEscaper.Replace(oldPrefix)+"%", likeEscaper.Replace(newPrefix)+"%").
+		OrderBy("id", database.ASC)
+
+	err := database.BatchProcess(ctx, query, batchSize, func(images []tables.ProductImage) error {
+		var ids []uuid.UUID
+		batchProducts := make(map[uuid.UUID]struct{})
+		for _, image := range images {
+			if !strings.HasPrefix(image.URL, oldPrefix) {
+				continue
 			}
-			if err := ps.cacheService.InvalidateActiveProductsListCache(); err != nil {
-				ps.logger.Warn("Failed to invalidate active products list cache after product update",
-					gecho.Field("error", err),
-					gecho.Field("product_id", productID),
-				)
+			if skipMigrated && strings.HasPrefix(image.URL, newPrefix) {
+				continue
 			}
-		}()
+			ids = append(ids, image.ID)
+			affectedProducts[image.ProductID] = struct{}{}
+			batchProducts[image.ProductID] = struct{}{}
+		}
+		result.Matched += len(ids)
+
+		if dryRun || len(ids) == 0 {
+			return nil
+		}
+
+		err := database.Transaction(ps.db, ctx, func(tx bun.Tx) error {
+			update := tx.NewUpdate().
+				Model((*tables.ProductImage)(nil)).
+				Set("url = ? || substr(url, ?)", newPrefix, utf8.RuneCountInString(oldPrefix)+1).
+				Where("id IN (?)", bun.In(ids)).
+				Where("starts_with(url, ?)", oldPrefix)
+			if skipMigrated {
+				update = update.Where("NOT starts_with(url, ?)", newPrefix)
+			}
+			res, err := update.Exec(ctx)
+			if err != nil {
+				return lib.MapPgError(err)
+			}
+			affected, _ := res.RowsAffected()
+			result.Updated += int(affected)
+			return nil
+		})
+		if err != nil {
+			return err
+		}
 
+		for productID := range batchProducts {
+			updatedProducts[productID] = struct{}{}
+		}
 		return nil
 	})
+	result.Products = len(affectedProducts)
+
+	if !dryRun {
+		productIDs := make([]uuid.UUID, 0, len(updatedProducts))
+		for productID := range updatedProducts {
+			productIDs = append(productIDs, productID)
+		}
+		if err := ps.cacheService.InvalidateProductCachesBulk(productIDs); err != nil {
+			ps.logger.Warn("Failed to invalidate product caches after image host migration",
+				gecho.Field("error", err),
+				gecho.Field("products", len(productIDs)),
+			)
+		}
+	}
+
+	if err != nil {
+		return result, err
+	}
+
+	ps.logger.Info("Product image host migration finished",
+		gecho.Field("old_prefix", oldPrefix),
+		gecho.Field("new_prefix", newPrefix),
+		gecho.Field("dry_run", dryRun),
+		gecho.Field("matched", result.Matched),
+		gecho.Field("updated", result.Updated),
+		gecho.Field("products", result.Products),
+	)
+
+	return result, nil
+}
+
+const (
+	productCacheWarmLock    = "product-cache-warm"
+	productCacheWarmLockTTL = 5 * time.Minute
+)
+
+// CacheWarmResult reports what a cache warmup stored
+type CacheWarmResult struct {
+	Pages    int    `json:"pages"`    // Product list pages cached, over every product type and with and without images
+	Products int    `json:"products"` // Distinct products cached by SKU
+	Counts   int    `json:"counts"`   // Product counts cached
+	Duration string `json:"duration"`
+}
+
+// WarmCache fills the product caches the storefront reads: the first Cache.WarmPages pages of active products
+// for every product type, the products on them by SKU and the active product count. The caches are written
+// synchronously, so they are populated when this returns. A distributed lock allows one warmup at a time,
+// a concurrent call returns lib.ErrCacheWarmInProgress
+func (ps *ProductService) WarmCache(ctx context.Context) (*CacheWarmResult, error) {
+	token, acquired, err := ps.cacheService.AcquireLock(productCacheWarmLock, productCacheWarmLockTTL)
+	if err != nil {
+		return nil, fmt.Errorf("failed to acquire cache warm lock: %w", err)
+	}
+	if !acquired {
+		return nil, lib.ErrCacheWarmInProgress
+	}
+	defer func() {
+		if err := ps.cacheService.ReleaseLock(productCacheWarmLock, token); err != nil {
+			ps.logger.Warn("Failed to release cache warm lock", gecho.Field("error", err))
+		}
+	}()
+
+	startTime := time.Now()
+	result := &CacheWarmResult{}
+	skus := make(map[string]struct{})
+	productTypes := append([]string{""}, tables.ProductTypes...)
+
+	for _, productType := range productTypes {
+		for _, includeImages := range []bool{false, true} {
+			for page := 1; page <= ps.cfg.Cache.WarmPages; page++ {
+				list, err := ps.GetAllProducts(ctx, activeProductsOptions(page, lib.DefaultPageSize, includeImages, productType))
+				if err != nil {
+					return result, err
+				}
+
+				if err := ps.cacheService.SetActiveProductsList(page, lib.DefaultPageSize, includeImages, list.Products, list.Pagination, productType); err != nil {
+					return result, fmt.Errorf("failed to cache active products: %w", err)
+				}
+				result.Pages++
+
+				if err := ps.cacheService.SetProducts(list.Products, includeImages); err != nil {
+					return result, fmt.Errorf("failed to cache products by SKU: %w", err)
+				}
+				for _, product := range list.Products {
+					skus[product.SKU] = struct{}{}
+				}
+
+				if !list.Pagination.HasNext {
+					break
+				}
+			}
+		}
+	}
+	result.Products = len(skus)
+
+	count, err := whereAvailableAt(database.Query[tables.Product](ps.db).Where("is_active", true), time.Now()).Count(ctx)
+	if err != nil {
+		return result, fmt.Errorf("failed to count active products: %w", err)
+	}
+	if err := ps.cacheService.SetProductCount(ActiveProductCountKey, count); err != nil {
+		return result, fmt.Errorf("failed to cache active product count: %w", err)
+	}
+	result.Counts++
+
+	result.Duration = time.Since(startTime).String()
+	ps.logger.Info("Product cache warmed",
+		gecho.Field("pages", result.Pages),
+		gecho.Field("products", result.Products),
+		gecho.Field("duration", result.Duration),
+	)
+
+	return result, nil
 }
@@ -0,0 +1,60 @@
+package services
+
+import (
+	"context"
+	"mamabloemetjes_server/database"
+	"testing"
+	"time"
+)
+
+func TestGetProductsBySKUsServesRepeatsFromCache(t *testing.T) {
+	ts := newTestServices(t)
+	rozen := ts.seedProduct(t, 2500, true)
+	tulpen := ts.seedProduct(t, 1250, true)
+
+	lookup := func(skus ...string) (int, int) {
+		t.Helper()
+		var found int
+		queries := database.CountQueries(context.Background(), func(ctx context.Context) {
+			products, err := ts.products.GetProductsBySKUs(ctx, skus, false, ProductScope{})
+			if err != nil {
+				t.Fatalf("GetProductsBySKUs: %v", err)
+			}
+			found = len(products)
+		})
+		return found, queries
+	}
+	// The fetched products are cached in the background
+	waitCached := func(sku string) {
+		t.Helper()
+		deadline := time.Now().Add(5 * time.Second)
+		for {
+			if products, _, _ := ts.cache.GetProducts([]string{sku}, false); products[sku] != nil {
+				return
+			}
+			if time.Now().After(deadline) {
+				t.Fatalf("expected %s to be cached", sku)
+			}
+			time.Sleep(10 * time.Millisecond)
+		}
+	}
+
+	if found, queries := lookup(rozen.SKU); found != 1 || queries != 1 {
+		t.Fatalf("expected the first basket view to query once, got %d products in %d queries", found, queries)
+	}
+	waitCached(rozen.SKU)
+
+	if found, queries := lookup(rozen.SKU); found != 1 || queries != 0 {
+		t.Fatalf("expected a repeated basket view to be served from the cache, got %d products in %d queries", found, queries)
+	}
+
+	// Only the miss is fetched
+	if found, queries := lookup(rozen.SKU, tulpen.SKU); found != 2 || queries != 1 {
+		t.Fatalf("expected the partial hit to query once for the miss, got %d products in %d queries", found, queries)
+	}
+	waitCached(tulpen.SKU)
+
+	if found, queries := lookup(rozen.SKU, tulpen.SKU); found != 2 || queries != 0 {
+		t.Fatalf("expected both products from the cache, got %d products in %d queries", found, queries)
+	}
+}
@@ -0,0 +1,71 @@
+package services
+
+import (
+	"context"
+	"fmt"
+	"mamabloemetjes_server/structs/tables"
+	"testing"
+)
+
+// seedNamedProduct inserts an active made-to-order product with the given name
+func (ts *testServices) seedNamedProduct(t *testing.T, name string, active bool) *tables.Product {
+	t.Helper()
+	product := ts.seedProduct(t, 2500, true)
+	_, err := ts.db.NewUpdate().Model((*tables.Product)(nil)).
+		Set("name = ?", name).
+		Set("is_active = ?", active).
+		Where("id = ?", product.ID).
+		Exec(context.Background())
+	if err != nil {
+		t.Fatalf("failed to rename product: %v", err)
+	}
+	product.Name, product.IsActive = name, active
+	return product
+}
+
+func TestSuggestProductsMatchesPrefix(t *testing.T) {
+	ts := newTestServices(t)
+	ctx := context.Background()
+
+	ts.seedNamedProduct(t, "Rozenboeket", true)
+	ts.seedNamedProduct(t, "rozenstruik", true)
+	ts.seedNamedProduct(t, "Boeket met rozen", true)
+	ts.seedNamedProduct(t, "Rozen van vorig jaar", false)
+
+	suggestions, err := ts.products.SuggestProducts(ctx, " ROZ ")
+	if err != nil {
+		t.Fatalf("SuggestProducts: %v", err)
+	}
+	var names []string
+	for _, product := range suggestions {
+		names = append(names, product.Name)
+	}
+	if len(names) != 2 || names[0] != "Rozenboeket" || names[1] != "rozenstruik" {
+		t.Fatalf("expected the two active products starting with roz, got %v", names)
+	}
+
+	// Wildcards in the query are matched literally
+	if wildcard, err := ts.products.SuggestProducts(ctx, "%"); err != nil || len(wildcard) != 0 {
+		t.Fatalf("expected no products for a literal %%, got %d (err %v)", len(wildcard), err)
+	}
+}
+
+func TestSuggestProductsCapsResults(t *testing.T) {
+	ts := newTestServices(t)
+	limit := ts.products.cfg.Products.SuggestLimit
+
+	for i := range limit + 3 {
+		ts.seedNamedProduct(t, fmt.Sprintf("Pioenboeket %02d", i), true)
+	}
+
+	suggestions, err := ts.products.SuggestProducts(context.Background(), "pioen")
+	if err != nil {
+		t.Fatalf("SuggestProducts: %v", err)
+	}
+	if len(suggestions) != limit {
+		t.Fatalf("expected %d suggestions, got %d", limit, len(suggestions))
+	}
+	if suggestions[0].Name != "Pioenboeket 00" {
+		t.Fatalf("expected suggestions sorted by name, got %s first", suggestions[0].Name)
+	}
+}
@@ -0,0 +1,93 @@
+package services
+
+import (
+	"context"
+	"errors"
+	"mamabloemetjes_server/lib"
+	"mamabloemetjes_server/structs/tables"
+	"testing"
+
+	"github.com/google/uuid"
+)
+
+// isValidationError reports whether err rejects the request input
+func isValidationError(err error) bool {
+	var validationErr *lib.ValidationError
+	return errors.As(err, &validationErr)
+}
+
+func TestNormalizeProductType(t *testing.T) {
+	tests := []struct {
+		name     string
+		input    string
+		expected string
+		valid    bool
+	}{
+		{"enum value", "wedding", "wedding", true},
+		{"different case", "Funeral", "funeral", true},
+		{"surrounding spaces", " BIRTH ", "birth", true},
+		{"unknown value", "Bouquet", "", false},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			got, err := normalizeProductType(tt.input)
+			if !tt.valid {
+				var validationErr *lib.ValidationError
+				if !errors.As(err, &validationErr) || validationErr.Errors[0].Field != "product_type" {
+					t.Fatalf("expected a validation error on product_type, got %q (err %v)", got, err)
+				}
+				return
+			}
+			if err != nil || got != tt.expected {
+				t.Fatalf("expected %q, got %q (err %v)", tt.expected, got, err)
+			}
+		})
+	}
+}
+
+func TestProductTypeNormalizedOnCreateAndUpdate(t *testing.T) {
+	ts := newTestServices(t)
+	ctx := context.Background()
+
+	newProduct := func(productType string) *tables.Product {
+		id := uuid.New()
+		return &tables.Product{
+			ID:          id,
+			Name:        "Bouquet " + id.String()[:8],
+			SKU:         "SKU-" + id.String()[:8],
+			Price:       2500,
+			Subtotal:    2500,
+			Currency:    "EUR",
+			Description: "A hand-tied bouquet of seasonal flowers",
+			ProductType: productType,
+			IsActive:    true,
+			MadeToOrder: true,
+		}
+	}
+
+	created, err := ts.products.CreateProduct(ctx, newProduct("Wedding"))
+	if err != nil {
+		t.Fatalf("CreateProduct: %v", err)
+	}
+	if stored := ts.reloadProduct(t, created.ID).ProductType; stored != tables.ProductTypeWedding {
+		t.Fatalf("expected the product type stored as %q, got %q", tables.ProductTypeWedding, stored)
+	}
+	if _, err := ts.products.CreateProduct(ctx, newProduct("XL")); !isValidationError(err) {
+		t.Fatalf("expected an unknown product type to be rejected, got %v", err)
+	}
+
+	funeral := "FUNERAL"
+	if err := ts.products.UpdateProduct(ctx, created.ID, &UpdateProductRequest{ProductType: &funeral}); err != nil {
+		t.Fatalf("UpdateProduct: %v", err)
+	}
+	if stored := ts.reloadProduct(t, created.ID).ProductType; stored != tables.ProductTypeFuneral {
+		t.Fatalf("expected the product type updated to %q, got %q", tables.ProductTypeFuneral, stored)
+	}
+	unknown := "bouquet"
+	if err := ts.products.UpdateProduct(ctx, created.ID, &UpdateProductRequest{ProductType: &unknown}); !isValidationError(err) {
+		t.Fatalf("expected an unknown product type to be rejected, got %v", err)
+	}
+	if stored := ts.reloadProduct(t, created.ID).ProductType; stored != tables.ProductTypeFuneral {
+		t.Fatalf("expected the rejected update to keep %q, got %q", tables.ProductTypeFuneral, stored)
+	}
+}
@@ -0,0 +1,48 @@
+package services
+
+import (
+	"slices"
+	"testing"
+	"time"
+
+	"github.com/google/uuid"
+)
+
+func TestTrendingProductsRankedByViews(t *testing.T) {
+	cs := newTestCacheService(t)
+	popular, steady, rare := uuid.New(), uuid.New(), uuid.New()
+
+	views := map[uuid.UUID]int{popular: 3, steady: 2, rare: 1}
+	for productID, count := range views {
+		for range count {
+			if err := cs.IncrementProductView(productID); err != nil {
+				t.Fatalf("IncrementProductView: %v", err)
+			}
+		}
+	}
+
+	ranked, err := cs.GetTrendingProductIDs(10)
+	if err != nil {
+		t.Fatalf("GetTrendingProductIDs: %v", err)
+	}
+	if !slices.Equal(ranked, []uuid.UUID{popular, steady, rare}) {
+		t.Fatalf("expected the products ranked by views, got %v", ranked)
+	}
+	if top, _ := cs.GetTrendingProductIDs(2); !slices.Equal(top, []uuid.UUID{popular, steady}) {
+		t.Fatalf("expected the top 2, got %v", top)
+	}
+
+	// Views from earlier in the window add up, views from before it no longer count
+	earlier := productViewsKey(time.Now().Add(-2 * time.Hour))
+	expired := productViewsKey(time.Now().Add(-cs.config.Cache.TrendingWindow - time.Hour))
+	cs.client.ZIncrBy(redisCtx, earlier, 3, rare.String())
+	cs.client.ZIncrBy(redisCtx, expired, 100, steady.String())
+
+	ranked, err = cs.GetTrendingProductIDs(10)
+	if err != nil {
+		t.Fatalf("GetTrendingProductIDs: %v", err)
+	}
+	if !slices.Equal(ranked, []uuid.UUID{rare, popular, steady}) {
+		t.Fatalf("expected views across the window to be summed, got %v", ranked)
+	}
+}
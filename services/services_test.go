@@ -0,0 +1,172 @@
+package services
+
+import (
+	"context"
+	"fmt"
+	"mamabloemetjes_server/database"
+	"mamabloemetjes_server/lib"
+	"mamabloemetjes_server/structs/tables"
+	"mamabloemetjes_server/testutil"
+	"testing"
+	"time"
+
+	"github.com/google/uuid"
+)
+
+// testServices wires the services against the test database and the in-memory Redis
+type testServices struct {
+	db       *database.DB
+	cache    *CacheService
+	auth     *AuthService
+	email    *EmailService
+	products *ProductService
+	orders   *OrderService
+}
+
+// newTestServices returns the services for a database test, skipping it unless TEST_DATABASE_URL is set.
+// Emails are queued but never sent, since no queue worker runs
+func newTestServices(t *testing.T) *testServices {
+	t.Helper()
+	db := testutil.DB(t)
+	cfg := testutil.Config()
+	logger := testutil.Logger()
+
+	cache := newTestCacheService(t)
+	auth := NewAuthService(cfg, logger, db, cache)
+	email := NewEmailService(logger, cfg, db, auth)
+	products := NewProductService(logger, cfg, db, cache)
+	orders := NewOrderService(logger, cfg, db, products, email)
+
+	return &testServices{db: db, cache: cache, auth: auth, email: email, products: products, orders: orders}
+}
+
+// seedProduct inserts an active product priced at price cents
+func (ts *testServices) seedProduct(t *testing.T, price uint64, madeToOrder bool) *tables.Product {
+	t.Helper()
+	id := uuid.New()
+	product := &tables.Product{
+		ID:          id,
+		Name:        "Bouquet " + id.String()[:8],
+		SKU:         "SKU-" + id.String()[:8],
+		Price:       price,
+		Subtotal:    price,
+		Currency:    "EUR",
+		Description: "A hand-tied bouquet of seasonal flowers",
+		ProductType: tables.ProductTypeWedding,
+		IsActive:    true,
+		MadeToOrder: madeToOrder,
+	}
+	if _, err := ts.db.NewInsert().Model(product).Exec(context.Background()); err != nil {
+		t.Fatalf("failed to seed product: %v", err)
+	}
+	return product
+}
+
+// seedOrder inserts a pending, unpaid order placed at createdAt with one line per product. Like a real order
+// it deactivates the one-of-a-kind products and holds them
+func (ts *testServices) seedOrder(t *testing.T, createdAt time.Time, products ...*tables.Product) *tables.Order {
+	t.Helper()
+	ctx := context.Background()
+	key := testutil.Config().Encryption.Key
+
+	encrypt := func(value string) string {
+		encrypted, err := lib.Encrypt(value, key)
+		if err != nil {
+			t.Fatalf("failed to encrypt %q: %v", value, err)
+		}
+		return encrypted
+	}
+
+	order := &tables.Order{
+		Id:            uuid.New(),
+		OrderNumber:   lib.GenerateOrderNumber(),
+		Name:          encrypt("Jan Jansen"),
+		Email:         encrypt("jan@example.com"),
+		Phone:         encrypt("0612345678"),
+		AddressId:     uuid.New(),
+		PaymentStatus: tables.PaymentStatusUnpaid,
+		Status:        tables.OrderStatusPending,
+		ShippingCents: 495,
+		Currency:      "EUR",
+		CreatedAt:     createdAt,
+		UpdatedAt:     createdAt,
+	}
+
+	lines := make([]*tables.OrderLine, 0, len(products))
+	for _, product := range products {
+		lines = append(lines, &tables.OrderLine{
+			Id:           uuid.New(),
+			OrderId:      order.Id,
+			ProductId:    product.ID,
+			Quantity:     1,
+			UnitPrice:    product.Price,
+			UnitSubtotal: product.Subtotal,
+			LineTotal:    product.Subtotal,
+			ProductName:  product.Name,
+			ProductSKU:   product.SKU,
+		})
+		order.Total += product.Subtotal
+	}
+
+	if _, err := ts.db.NewInsert().Model(order).Exec(ctx); err != nil {
+		t.Fatalf("failed to seed order: %v", err)
+	}
+	if len(lines) > 0 {
+		if _, err := ts.db.NewInsert().Model(&lines).Exec(ctx); err != nil {
+			t.Fatalf("failed to seed order lines: %v", err)
+		}
+	}
+
+	for _, product := range products {
+		if product.MadeToOrder {
+			continue
+		}
+		_, err := ts.db.NewUpdate().
+			Model((*tables.Product)(nil)).
+			Set("is_active = ?", false).
+			Set("reserved_order_id = ?", order.Id).
+			Where("id = ?", product.ID).
+			Exec(ctx)
+		if err != nil {
+			t.Fatalf("failed to reserve product: %v", err)
+		}
+	}
+
+	return order
+}
+
+// reloadProduct reads a product back from the database
+func (ts *testServices) reloadProduct(t *testing.T, id uuid.UUID) *tables.Product {
+	t.Helper()
+	product := new(tables.Product)
+	if err := ts.db.NewSelect().Model(product).Where("id = ?", id).Scan(context.Background()); err != nil {
+		t.Fatalf("failed to reload product %s: %v", id, err)
+	}
+	return product
+}
+
+// reloadOrder reads an order back from the database, without decrypting it
+func (ts *testServices) reloadOrder(t *testing.T, id uuid.UUID) *tables.Order {
+	t.Helper()
+	order := new(tables.Order)
+	if err := ts.db.NewSelect().Model(order).Where("id = ?", id).Scan(context.Background()); err != nil {
+		t.Fatalf("failed to reload order %s: %v", id, err)
+	}
+	return order
+}
+
+// statusHistory returns the status changes recorded for an order as "from->to", oldest first
+func (ts *testServices) statusHistory(t *testing.T, orderId uuid.UUID) []string {
+	t.Helper()
+	var history []tables.OrderStatusHistory
+	err := ts.db.NewSelect().Model(&history).Where("order_id = ?", orderId).OrderExpr("created_at").Scan(context.Background())
+	if err != nil {
+		t.Fatalf("failed to read status history: %v", err)
+	}
+
+	changes := make([]string, 0, len(history))
+	for _, entry := range history {
+		changes = append(changes, fmt.Sprintf("%s->%s", entry.FromStatus, entry.ToStatus))
+	}
+	return changes
+}
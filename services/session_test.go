@@ -0,0 +1,80 @@
+package services
+
+import (
+	"strings"
+	"testing"
+	"unicode/utf8"
+)
+
+func TestTruncateKeepsWholeCharacters(t *testing.T) {
+	tests := []struct {
+		s    string
+		n    int
+		want string
+	}{
+		{"Mozilla/5.0", 64, "Mozilla/5.0"},
+		{"Mozilla/5.0", 7, "Mozilla"},
+		{"bloemetjés", 9, "bloemetj"}, // é is two bytes, the first 9 bytes end halfway through it
+		{"🌷🌷", 5, "🌷"},
+		{"🌷", 3, ""},
+	}
+	for _, tt := range tests {
+		got := truncate(tt.s, tt.n)
+		if got != tt.want || !utf8.ValidString(got) {
+			t.Fatalf("truncate(%q, %d): expected %q, got %q", tt.s, tt.n, tt.want, got)
+		}
+	}
+}
+
+func TestListSessions(t *testing.T) {
+	ts := newTestServices(t)
+	user := ts.registerUser(t, "jan@example.com")
+	other := ts.registerUser(t, "piet@example.com")
+
+	longAgent := strings.Repeat("é", 300) // 600 bytes, over the 512 byte column
+	if _, err := ts.auth.GenerateRefreshToken(user, "phone", "1.2.3.4"); err != nil {
+		t.Fatalf("GenerateRefreshToken: %v", err)
+	}
+	laptop, err := ts.auth.GenerateRefreshToken(user, longAgent, "5.6.7.8")
+	if err != nil {
+		t.Fatalf("GenerateRefreshToken: %v", err)
+	}
+	if _, err := ts.auth.GenerateRefreshToken(other, "tablet", "9.9.9.9"); err != nil {
+		t.Fatalf("GenerateRefreshToken: %v", err)
+	}
+
+	sessions, err := ts.auth.ListSessions(user.Id)
+	if err != nil {
+		t.Fatalf("ListSessions: %v", err)
+	}
+	if len(sessions) != 2 {
+		t.Fatalf("expected the user's 2 sessions, got %d", len(sessions))
+	}
+	newest := sessions[0]
+	if newest.UserId != user.Id || newest.Ip != "5.6.7.8" || newest.ExpiresAt.Before(newest.CreatedAt) {
+		t.Fatalf("expected the laptop session first with its metadata, got %+v", newest)
+	}
+	if len(newest.UserAgent) != 512 || !utf8.ValidString(newest.UserAgent) || !strings.HasPrefix(longAgent, newest.UserAgent) {
+		t.Fatalf("expected the user agent cut to 512 bytes of whole characters, got %d bytes", len(newest.UserAgent))
+	}
+	if sessions[1].UserAgent != "phone" || sessions[1].Ip != "1.2.3.4" {
+		t.Fatalf("expected the phone session second, got %+v", sessions[1])
+	}
+
+	// A rotated session leaves the list, its replacement takes its place
+	if _, err := ts.auth.RefreshAccessToken(laptop, "laptop", "5.6.7.8"); err != nil {
+		t.Fatalf("RefreshAccessToken: %v", err)
+	}
+	sessions, err = ts.auth.ListSessions(user.Id)
+	if err != nil {
+		t.Fatalf("ListSessions: %v", err)
+	}
+	if len(sessions) != 2 || sessions[0].UserAgent != "laptop" {
+		t.Fatalf("expected the rotated session replaced by the new one, got %+v", sessions)
+	}
+	for _, session := range sessions {
+		if session.RevokedAt != nil {
+			t.Fatalf("expected only live sessions, got %+v", session)
+		}
+	}
+}
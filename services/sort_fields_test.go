@@ -0,0 +1,68 @@
+package services
+
+import (
+	"context"
+	"errors"
+	"mamabloemetjes_server/lib"
+	"mamabloemetjes_server/testutil"
+	"maps"
+	"testing"
+)
+
+func TestListSortFields(t *testing.T) {
+	ctx := context.Background()
+	// Both sort parameters are checked before the database is queried, so the services need none
+	products := &ProductService{logger: testutil.Logger(), cfg: testutil.Config()}
+	orders := &OrderService{}
+
+	models := []struct {
+		name     string
+		allowed  map[string]bool
+		rejected []string
+		list     func(sortBy, sortDirection string) error
+	}{
+		{
+			name:     "products",
+			allowed:  ProductSortFields,
+			rejected: []string{"status", "password_hash", "price DESC"},
+			list: func(sortBy, sortDirection string) error {
+				_, err := products.GetAllProducts(ctx, &ProductListOptions{SortBy: sortBy, SortDirection: sortDirection})
+				return err
+			},
+		},
+		{
+			name:     "orders",
+			allowed:  OrderSortFields,
+			rejected: []string{"sku", "email", "total; DROP TABLE orders"},
+			list: func(sortBy, sortDirection string) error {
+				_, err := orders.GetAllOrders(ctx, nil, nil, 1, 20, sortBy, sortDirection)
+				return err
+			},
+		},
+	}
+
+	// rejectedField returns the field named by a single field error
+	rejectedField := func(err error) string {
+		var validationErr *lib.ValidationError
+		if !errors.As(err, &validationErr) || len(validationErr.Errors) != 1 {
+			return ""
+		}
+		return validationErr.Errors[0].Field
+	}
+
+	for _, model := range models {
+		t.Run(model.name, func(t *testing.T) {
+			// An invalid direction stops the listing once the field has been accepted
+			for field := range maps.Keys(model.allowed) {
+				if got := rejectedField(model.list(field, "RANDOM()")); got != "sort_direction" {
+					t.Fatalf("expected %q to be accepted, got a rejected %q", field, got)
+				}
+			}
+			for _, field := range model.rejected {
+				if got := rejectedField(model.list(field, "ASC")); got != "sort_by" {
+					t.Fatalf("expected %q to be rejected on sort_by, got %q", field, got)
+				}
+			}
+		})
+	}
+}
@@ -0,0 +1,56 @@
+-- ============================================================================
+-- ORDER ADJUSTMENTS TABLE
+-- ============================================================================
+CREATE TABLE IF NOT EXISTS public.order_adjustments (
+    -- Primary Key
+    id UUID PRIMARY KEY DEFAULT gen_random_uuid(),
+
+    -- Foreign Key to Orders
+    order_id UUID NOT NULL,
+
+    -- Adjustment as entered: cents for fixed, whole percent for percent
+    kind TEXT NOT NULL,
+    value BIGINT NOT NULL,
+
+    -- Discount in cents the adjustment resulted in
+    discount_cents BIGINT NOT NULL,
+
+    -- Why the discount was given
+    reason TEXT NOT NULL,
+
+    -- Admin who made the adjustment
+    adjusted_by UUID,
+
+    -- Timestamps
+    created_at TIMESTAMP WITH TIME ZONE NOT NULL DEFAULT NOW(),
+
+    -- Constraints
+    CONSTRAINT check_order_adjustment_kind CHECK (kind IN ('fixed', 'percent')),
+    CONSTRAINT check_order_adjustment_value CHECK (value >= 0 AND (kind <> 'percent' OR value <= 100)),
+    CONSTRAINT check_order_adjustment_discount CHECK (discount_cents >= 0),
+
+    -- Foreign Key Constraints
+    CONSTRAINT order_adjustments_order_id_fkey
+        FOREIGN KEY (order_id)
+        REFERENCES public.orders (id)
+        ON DELETE CASCADE,
+
+    CONSTRAINT order_adjustments_adjusted_by_fkey
+        FOREIGN KEY (adjusted_by)
+        REFERENCES public.users (id)
+        ON DELETE SET NULL
+) TABLESPACE pg_default;
+
+-- ============================================================================
+-- INDEXES FOR ORDER ADJUSTMENTS TABLE
+-- ============================================================================
+
+-- Timeline lookup for a single order
+CREATE INDEX IF NOT EXISTS idx_order_adjustments_order_id
+    ON public.order_adjustments USING btree (order_id, created_at DESC)
+    TABLESPACE pg_default;
+
+COMMENT ON TABLE public.order_adjustments IS
+    'Audit trail of manual discounts applied to orders by admins';
+COMMENT ON COLUMN public.order_adjustments.discount_cents IS
+    'Discount in cents stored on the order by this adjustment; percent adjustments are converted when applied';
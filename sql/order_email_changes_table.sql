@@ -0,0 +1,50 @@
+-- ============================================================================
+-- ORDER EMAIL CHANGES TABLE
+-- ============================================================================
+CREATE TABLE IF NOT EXISTS public.order_email_changes (
+    -- Primary Key
+    id UUID PRIMARY KEY DEFAULT gen_random_uuid(),
+
+    -- Foreign Key to Orders
+    order_id UUID NOT NULL,
+
+    -- Change (encrypted like orders.email)
+    from_email TEXT NOT NULL,
+    to_email TEXT NOT NULL,
+
+    -- Whether the order confirmation was re-sent to the new address
+    resent BOOLEAN NOT NULL DEFAULT FALSE,
+
+    -- Admin who made the change
+    changed_by UUID,
+
+    -- Timestamps
+    created_at TIMESTAMP WITH TIME ZONE NOT NULL DEFAULT NOW(),
+
+    -- Foreign Key Constraints
+    CONSTRAINT order_email_changes_order_id_fkey
+        FOREIGN KEY (order_id)
+        REFERENCES public.orders (id)
+        ON DELETE CASCADE,
+
+    CONSTRAINT order_email_changes_changed_by_fkey
+        FOREIGN KEY (changed_by)
+        REFERENCES public.users (id)
+        ON DELETE SET NULL
+) TABLESPACE pg_default;
+
+-- ============================================================================
+-- INDEXES FOR ORDER EMAIL CHANGES TABLE
+-- ============================================================================
+
+-- Timeline lookup for a single order
+CREATE INDEX IF NOT EXISTS idx_order_email_changes_order_id
+    ON public.order_email_changes USING btree (order_id, created_at DESC)
+    TABLESPACE pg_default;
+
+COMMENT ON TABLE public.order_email_changes IS
+    'Audit trail of customer email corrections made by admins';
+COMMENT ON COLUMN public.order_email_changes.from_email IS
+    'Encrypted previous email, emptied when the order is anonymized';
+COMMENT ON COLUMN public.order_email_changes.to_email IS
+    'Encrypted new email, emptied when the order is anonymized';
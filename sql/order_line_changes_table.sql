@@ -0,0 +1,52 @@
+-- ============================================================================
+-- ORDER LINE CHANGES TABLE
+-- ============================================================================
+CREATE TABLE IF NOT EXISTS public.order_line_changes (
+    -- Primary Key
+    id UUID PRIMARY KEY DEFAULT gen_random_uuid(),
+
+    -- Foreign Key to Orders
+    order_id UUID NOT NULL,
+
+    -- Edited line; no foreign key so the record outlives a deleted line
+    line_id UUID NOT NULL,
+    product_id UUID NOT NULL,
+
+    -- Quantity before and after the edit, 0 when the line was removed
+    from_quantity INTEGER NOT NULL,
+    to_quantity INTEGER NOT NULL,
+
+    -- Admin who made the change
+    changed_by UUID,
+
+    -- Timestamps
+    created_at TIMESTAMP WITH TIME ZONE NOT NULL DEFAULT NOW(),
+
+    -- Constraints
+    CONSTRAINT check_order_line_change_quantities CHECK (from_quantity > 0 AND to_quantity >= 0),
+
+    -- Foreign Key Constraints
+    CONSTRAINT order_line_changes_order_id_fkey
+        FOREIGN KEY (order_id)
+        REFERENCES public.orders (id)
+        ON DELETE CASCADE,
+
+    CONSTRAINT order_line_changes_changed_by_fkey
+        FOREIGN KEY (changed_by)
+        REFERENCES public.users (id)
+        ON DELETE SET NULL
+) TABLESPACE pg_default;
+
+-- ============================================================================
+-- INDEXES FOR ORDER LINE CHANGES TABLE
+-- ============================================================================
+
+-- Timeline lookup for a single order
+CREATE INDEX IF NOT EXISTS idx_order_line_changes_order_id
+    ON public.order_line_changes USING btree (order_id, created_at DESC)
+    TABLESPACE pg_default;
+
+COMMENT ON TABLE public.order_line_changes IS
+    'Audit trail of order line quantity changes and removals made by admins before shipment';
+COMMENT ON COLUMN public.order_line_changes.to_quantity IS
+    'Quantity after the change; 0 means the line was removed';
@@ -0,0 +1,45 @@
+-- ============================================================================
+-- ORDER STATUS HISTORY TABLE
+-- ============================================================================
+CREATE TABLE IF NOT EXISTS public.order_status_history (
+    -- Primary Key
+    id UUID PRIMARY KEY DEFAULT gen_random_uuid(),
+
+    -- Foreign Key to Orders
+    order_id UUID NOT NULL,
+
+    -- Transition
+    from_status order_status NOT NULL,
+    to_status order_status NOT NULL,
+
+    -- Admin who made the change (NULL for system changes)
+    changed_by UUID,
+
+    -- Timestamps
+    created_at TIMESTAMP WITH TIME ZONE NOT NULL DEFAULT NOW(),
+
+    -- Foreign Key Constraints
+    CONSTRAINT order_status_history_order_id_fkey
+        FOREIGN KEY (order_id)
+        REFERENCES public.orders (id)
+        ON DELETE CASCADE,
+
+    CONSTRAINT order_status_history_changed_by_fkey
+        FOREIGN KEY (changed_by)
+        REFERENCES public.users (id)
+        ON DELETE SET NULL
+) TABLESPACE pg_default;
+
+-- ============================================================================
+-- INDEXES FOR ORDER STATUS HISTORY TABLE
+-- ============================================================================
+
+-- Timeline lookup for a single order
+CREATE INDEX IF NOT EXISTS idx_order_status_history_order_id
+    ON public.order_status_history USING btree (order_id, created_at DESC)
+    TABLESPACE pg_default;
+
+COMMENT ON TABLE public.order_status_history IS
+    'Audit trail of order status changes';
+COMMENT ON COLUMN public.order_status_history.changed_by IS
+    'Admin user that applied the change, NULL when changed by the system';
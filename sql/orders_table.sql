@@ -60,6 +60,15 @@ CREATE TABLE IF NOT EXISTS public.orders (
     payment_link TEXT, -- Nullable initially, attached later
     payment_status payment_status NOT NULL DEFAULT 'unpaid',
 
+    -- Currency shared by all order lines (ISO 4217), always set by the application (ORDER_DEFAULT_CURRENCY)
+    currency CHAR(3) NOT NULL,
+
+    -- Manual discount in cents applied by an admin
+    discount_cents BIGINT NOT NULL DEFAULT 0,
+
+    -- Sum of the line totals minus the discount in cents, shipping excluded
+    total BIGINT NOT NULL DEFAULT 0,
+
     -- Order Status
     status order_status NOT NULL DEFAULT 'pending',
 
@@ -67,6 +76,8 @@ CREATE TABLE IF NOT EXISTS public.orders (
     created_at TIMESTAMP WITH TIME ZONE NOT NULL DEFAULT CURRENT_TIMESTAMP,
     updated_at TIMESTAMP WITH TIME ZONE NOT NULL DEFAULT CURRENT_TIMESTAMP,
     deleted_at TIMESTAMP WITH TIME ZONE, -- Soft delete support
+    reservation_released_at TIMESTAMP WITH TIME ZONE, -- Set when reserved products are released
+    anonymized_at TIMESTAMP WITH TIME ZONE, -- Set when guest customer data is wiped after the retention period
 
     -- Constraints
     -- NOTE: Constraints on name, email, phone, and note removed to support encryption
@@ -127,6 +138,22 @@ CREATE INDEX IF NOT EXISTS idx_orders_not_deleted
     TABLESPACE pg_default
     WHERE deleted_at IS NULL;
 
+-- Pending order sweeper (unpaid orders still holding products)
+CREATE INDEX IF NOT EXISTS idx_orders_pending_reservations
+    ON public.orders USING btree (created_at ASC)
+    TABLESPACE pg_default
+    WHERE status = 'pending'
+      AND payment_status = 'unpaid'
+      AND reservation_released_at IS NULL
+      AND deleted_at IS NULL;
+
+-- Index for the guest order retention job
+CREATE INDEX IF NOT EXISTS idx_orders_anonymize_candidates
+    ON public.orders USING btree (created_at)
+    TABLESPACE pg_default
+    WHERE status IN ('delivered', 'cancelled')
+      AND anonymized_at IS NULL;
+
 -- Index for deleted orders (for recovery/audit)
 CREATE INDEX IF NOT EXISTS idx_orders_deleted_at
     ON public.orders USING btree (deleted_at DESC)
@@ -210,6 +237,33 @@ COMMENT ON COLUMN public.orders.updated_at IS
 COMMENT ON COLUMN public.orders.deleted_at IS
     'Soft delete timestamp - NULL for active orders, set to deletion time for deleted orders';
 
+COMMENT ON COLUMN public.orders.reservation_released_at IS
+    'Timestamp when the products held by this unpaid order were released by the pending order sweeper';
+
+COMMENT ON COLUMN public.orders.anonymized_at IS
+    'Timestamp when the customer data of this guest order was anonymized by the retention job';
+
+COMMENT ON COLUMN public.orders.currency IS
+    'ISO 4217 currency code shared by all order lines';
+
+COMMENT ON COLUMN public.orders.discount_cents IS
+    'Manual discount in cents set by an admin; replaced by each new adjustment (see order_adjustments)';
+
+COMMENT ON COLUMN public.orders.total IS
+    'Sum of order_lines.line_total minus discount_cents (never below zero) in cents, shipping excluded; kept in sync when lines are edited';
+
+-- Migration for existing databases
+ALTER TABLE public.orders ADD COLUMN IF NOT EXISTS reservation_released_at TIMESTAMP WITH TIME ZONE;
+-- Orders placed before currencies were tracked were all in euros; new orders get theirs from the application
+ALTER TABLE public.orders ADD COLUMN IF NOT EXISTS currency CHAR(3) NOT NULL DEFAULT 'EUR';
+ALTER TABLE public.orders ALTER COLUMN currency DROP DEFAULT;
+ALTER TABLE public.orders ADD COLUMN IF NOT EXISTS anonymized_at TIMESTAMP WITH TIME ZONE;
+ALTER TABLE public.orders ADD COLUMN IF NOT EXISTS total BIGINT NOT NULL DEFAULT 0;
+ALTER TABLE public.orders ADD COLUMN IF NOT EXISTS discount_cents BIGINT NOT NULL DEFAULT 0;
+UPDATE public.orders AS o
+SET total = greatest((SELECT coalesce(sum(ol.line_total), 0) FROM public.order_lines AS ol WHERE ol.order_id = o.id) - o.discount_cents, 0)
+WHERE o.total = 0;
+
 -- ============================================================================
 -- ANALYTICS/MONITORING VIEWS (Optional but recommended)
 -- ============================================================================
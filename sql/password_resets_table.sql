@@ -0,0 +1,64 @@
+-- ============================================================================
+-- Password Resets Table Schema
+-- ============================================================================
+-- Single-use tokens emailed by POST /auth/forgot-password. Only the SHA-256 of
+-- a token is stored, so a leaked table cannot be used to reset passwords.
+-- ============================================================================
+
+-- ============================================================================
+-- PASSWORD RESETS TABLE
+-- ============================================================================
+CREATE TABLE IF NOT EXISTS public.password_resets (
+    -- Primary Key
+    id UUID PRIMARY KEY DEFAULT gen_random_uuid(),
+
+    -- Foreign Key to Users
+    user_id UUID NOT NULL,
+
+    -- SHA-256 of the emailed token (hex)
+    token_hash TEXT NOT NULL UNIQUE,
+
+    -- Expiration & Status
+    expires_at TIMESTAMP WITH TIME ZONE NOT NULL,
+    used_at TIMESTAMP WITH TIME ZONE,
+
+    -- Timestamps
+    created_at TIMESTAMP WITH TIME ZONE NOT NULL DEFAULT NOW(),
+
+    -- Foreign Key Constraint with CASCADE delete
+    CONSTRAINT password_resets_user_id_fkey
+        FOREIGN KEY (user_id)
+        REFERENCES public.users (id)
+        ON DELETE CASCADE,
+
+    CONSTRAINT check_password_reset_expires_after_creation
+        CHECK (expires_at > created_at)
+) TABLESPACE pg_default;
+
+-- ============================================================================
+-- INDEXES FOR PASSWORD RESETS TABLE
+-- ============================================================================
+
+-- Foreign key index (critical for CASCADE deletes and clearing a user's tokens)
+CREATE INDEX IF NOT EXISTS idx_password_resets_user_id
+    ON public.password_resets USING btree (user_id)
+    TABLESPACE pg_default;
+
+-- Index for cleanup of expired tokens
+CREATE INDEX IF NOT EXISTS idx_password_resets_expires
+    ON public.password_resets USING btree (expires_at)
+    TABLESPACE pg_default;
+
+-- ============================================================================
+-- COMMENTS (Documentation)
+-- ============================================================================
+
+COMMENT ON TABLE public.password_resets IS
+    'Single-use password reset tokens';
+
+COMMENT ON COLUMN public.password_resets.used_at IS
+    'Set when the token resets the password; used tokens are rejected';
+
+-- ============================================================================
+-- END OF SCHEMA
+-- ============================================================================
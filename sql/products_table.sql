@@ -27,9 +27,23 @@ CREATE TABLE IF NOT EXISTS public.products (
     discount BIGINT NOT NULL DEFAULT 0 CHECK (discount >= 0),
     tax BIGINT NOT NULL DEFAULT 0 CHECK (tax >= 0),
     subtotal BIGINT NOT NULL DEFAULT 0 CHECK (subtotal >= 0),
+    currency CHAR(3) NOT NULL, -- ISO 4217 currency code, defaults to ORDER_DEFAULT_CURRENCY in the application
 
     -- Status
     is_active BOOLEAN NOT NULL DEFAULT true,
+    made_to_order BOOLEAN NOT NULL DEFAULT false, -- Not reserved (deactivated) when ordered
+    reserved_order_id UUID, -- Order that deactivated this one-of-a-kind product, cleared when it is released or edited by an admin
+
+    -- Availability window for seasonal products, open-ended when NULL
+    available_from TIMESTAMP WITH TIME ZONE,
+    available_until TIMESTAMP WITH TIME ZONE,
+
+    -- Full-text search document, name and SKU weigh more than the description
+    search_vector TSVECTOR GENERATED ALWAYS AS (
+        setweight(to_tsvector('english', coalesce(name, '')), 'A') ||
+        setweight(to_tsvector('english', coalesce(sku, '')), 'A') ||
+        setweight(to_tsvector('english', coalesce(description, '')), 'B')
+    ) STORED,
 
     -- Timestamps
     created_at TIMESTAMP WITH TIME ZONE NOT NULL DEFAULT NOW(),
@@ -39,6 +53,10 @@ CREATE TABLE IF NOT EXISTS public.products (
     -- Subtotal = Price - Discount + Tax
     CONSTRAINT check_price_calculation CHECK (
         subtotal = (price - discount + tax)
+    ),
+
+    CONSTRAINT check_availability_window CHECK (
+        available_from IS NULL OR available_until IS NULL OR available_until > available_from
     )
 ) TABLESPACE pg_default;
 
@@ -94,12 +112,16 @@ CREATE INDEX IF NOT EXISTS idx_products_price
     TABLESPACE pg_default
     WHERE is_active = true;
 
--- Full-text search index for name and description
-CREATE INDEX IF NOT EXISTS idx_products_search
-    ON public.products USING gin (
-        to_tsvector('english', name || ' ' || description || ' ' || sku)
-    )
-    TABLESPACE pg_default;
+-- Prefix indexes for search-as-you-type (lower(col) LIKE 'prefix%')
+CREATE INDEX IF NOT EXISTS idx_products_name_prefix
+    ON public.products USING btree (lower(name) text_pattern_ops)
+    TABLESPACE pg_default
+    WHERE is_active = true;
+
+CREATE INDEX IF NOT EXISTS idx_products_sku_prefix
+    ON public.products USING btree (lower(sku) text_pattern_ops)
+    TABLESPACE pg_default
+    WHERE is_active = true;
 
 -- ============================================================================
 -- INDEXES FOR PRODUCT IMAGES TABLE
@@ -187,6 +209,74 @@ COMMENT ON COLUMN public.products.subtotal IS
 COMMENT ON COLUMN public.products.sku IS
     'Stock Keeping Unit - unique identifier for inventory management';
 
+COMMENT ON COLUMN public.products.currency IS
+    'ISO 4217 currency code of the price fields; all lines of an order must share one currency';
+
+-- Migration for existing databases
+-- Products created before currencies were tracked were all priced in euros
+ALTER TABLE public.products ADD COLUMN IF NOT EXISTS currency CHAR(3) NOT NULL DEFAULT 'EUR';
+ALTER TABLE public.products ALTER COLUMN currency DROP DEFAULT;
+
+COMMENT ON COLUMN public.products.available_from IS
+    'Start of the availability window (inclusive); the product is listed from this moment on, always when NULL';
+
+COMMENT ON COLUMN public.products.available_until IS
+    'End of the availability window (exclusive); the product is no longer listed from this moment on, never ends when NULL';
+
+-- Migration for existing databases
+ALTER TABLE public.products ADD COLUMN IF NOT EXISTS available_from TIMESTAMP WITH TIME ZONE;
+ALTER TABLE public.products ADD COLUMN IF NOT EXISTS available_until TIMESTAMP WITH TIME ZONE;
+ALTER TABLE public.products DROP CONSTRAINT IF EXISTS check_availability_window;
+ALTER TABLE public.products ADD CONSTRAINT check_availability_window CHECK (
+    available_from IS NULL OR available_until IS NULL OR available_until > available_from
+);
+
+COMMENT ON COLUMN public.products.made_to_order IS
+    'Made-to-order products are not one-of-a-kind: ordering them does not deactivate them, so they stay in stock';
+
+-- Migration for existing databases
+ALTER TABLE public.products ADD COLUMN IF NOT EXISTS made_to_order BOOLEAN NOT NULL DEFAULT false;
+
+COMMENT ON COLUMN public.products.search_vector IS
+    'Generated full-text search document (name and SKU weight A, description weight B), used by search_mode=fulltext';
+
+-- Migration for existing databases
+ALTER TABLE public.products ADD COLUMN IF NOT EXISTS search_vector TSVECTOR GENERATED ALWAYS AS (
+        setweight(to_tsvector('english', coalesce(name, '')), 'A') ||
+        setweight(to_tsvector('english', coalesce(sku, '')), 'A') ||
+        setweight(to_tsvector('english', coalesce(description, '')), 'B')
+    ) STORED;
+
+-- Full-text search index, replaces the expression index on name, description and SKU
+DROP INDEX IF EXISTS idx_products_search;
+CREATE INDEX IF NOT EXISTS idx_products_search_vector
+    ON public.products USING gin (search_vector)
+    TABLESPACE pg_default;
+
+COMMENT ON COLUMN public.products.reserved_order_id IS
+    'Unpaid or paid order that deactivated this one-of-a-kind product; releasing that order reactivates only the products it still holds, never ones an admin deactivated';
+
+-- Migration for existing databases
+ALTER TABLE public.products ADD COLUMN IF NOT EXISTS reserved_order_id UUID;
+-- Products held by unpaid pending orders placed before the column existed
+UPDATE public.products AS p
+SET reserved_order_id = ol.order_id
+FROM public.order_lines AS ol
+JOIN public.orders AS o ON o.id = ol.order_id
+WHERE ol.product_id = p.id
+  AND p.reserved_order_id IS NULL
+  AND p.is_active = false
+  AND p.made_to_order = false
+  AND o.status = 'pending'
+  AND o.payment_status = 'unpaid'
+  AND o.reservation_released_at IS NULL
+  AND o.deleted_at IS NULL;
+
+CREATE INDEX IF NOT EXISTS idx_products_reserved_order_id
+    ON public.products USING btree (reserved_order_id)
+    TABLESPACE pg_default
+    WHERE reserved_order_id IS NOT NULL;
+
 COMMENT ON TABLE public.product_images IS
     'Product images with support for multiple images per product';
 
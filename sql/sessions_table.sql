@@ -0,0 +1,77 @@
+-- ============================================================================
+-- Sessions Table Schema
+-- ============================================================================
+-- Server-side record of every issued refresh token. A refresh token is only
+-- accepted while its jti maps to a session that is neither revoked nor expired,
+-- which makes individual sessions listable and revocable per user.
+-- ============================================================================
+
+-- ============================================================================
+-- SESSIONS TABLE
+-- ============================================================================
+CREATE TABLE IF NOT EXISTS public.sessions (
+    -- Primary Key
+    id UUID PRIMARY KEY DEFAULT gen_random_uuid(),
+
+    -- Foreign Key to Users
+    user_id UUID NOT NULL,
+
+    -- Refresh token identifier (jti claim)
+    jti UUID NOT NULL UNIQUE,
+
+    -- Client information at the time the session was created
+    user_agent TEXT NOT NULL DEFAULT '',
+    ip TEXT NOT NULL DEFAULT '',
+
+    -- Lifetime
+    created_at TIMESTAMP WITH TIME ZONE NOT NULL DEFAULT NOW(),
+    expires_at TIMESTAMP WITH TIME ZONE NOT NULL,
+    revoked_at TIMESTAMP WITH TIME ZONE,
+
+    -- Hash of the CSRF token issued to this session
+    csrf_token_hash TEXT,
+
+    -- Foreign Key Constraint with CASCADE delete
+    CONSTRAINT sessions_user_id_fkey
+        FOREIGN KEY (user_id)
+        REFERENCES public.users (id)
+        ON DELETE CASCADE,
+
+    CONSTRAINT check_session_expires_after_creation
+        CHECK (expires_at > created_at)
+) TABLESPACE pg_default;
+
+-- ============================================================================
+-- INDEXES FOR SESSIONS TABLE
+-- ============================================================================
+
+-- Live sessions per user (listing and revoking all sessions)
+CREATE INDEX IF NOT EXISTS idx_sessions_user_live
+    ON public.sessions USING btree (user_id, created_at DESC)
+    TABLESPACE pg_default
+    WHERE revoked_at IS NULL;
+
+-- Index for cleanup of expired sessions
+CREATE INDEX IF NOT EXISTS idx_sessions_expires
+    ON public.sessions USING btree (expires_at)
+    TABLESPACE pg_default;
+
+-- ============================================================================
+-- COMMENTS (Documentation)
+-- ============================================================================
+
+COMMENT ON TABLE public.sessions IS
+    'Server-side sessions backing JWT refresh tokens';
+
+COMMENT ON COLUMN public.sessions.jti IS
+    'jti claim of the refresh token issued for this session; rotated tokens get a new session';
+
+COMMENT ON COLUMN public.sessions.revoked_at IS
+    'Set on logout, token rotation or explicit revocation; revoked sessions cannot be refreshed';
+
+COMMENT ON COLUMN public.sessions.csrf_token_hash IS
+    'SHA-256 of the CSRF token issued to this session; state-changing requests must present the matching token';
+
+-- ============================================================================
+-- END OF SCHEMA
+-- ============================================================================
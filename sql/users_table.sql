@@ -28,12 +28,17 @@ CREATE TABLE IF NOT EXISTS public.users (
     email_verified BOOLEAN NOT NULL DEFAULT false,
     last_login TIMESTAMP WITH TIME ZONE,
 
+    -- Token Invalidation
+    -- Embedded in issued tokens; bumping it invalidates every token issued before
+    -- Migration for existing databases:
+    -- ALTER TABLE public.users ADD COLUMN IF NOT EXISTS token_version INTEGER NOT NULL DEFAULT 0;
+    token_version INTEGER NOT NULL DEFAULT 0,
+
     -- Timestamps
     created_at TIMESTAMP WITH TIME ZONE NOT NULL DEFAULT NOW(),
     updated_at TIMESTAMP WITH TIME ZONE NOT NULL DEFAULT NOW()
 
-    -- NOTE: Constraints removed to support encryption
-    -- Email and username are now encrypted for privacy and cannot be validated with regex/length checks
+    -- NOTE: Email and username are validated by the application, not by constraints
 ) TABLESPACE pg_default;
 
 -- ============================================================================
@@ -50,8 +55,29 @@ CREATE UNIQUE INDEX IF NOT EXISTS idx_users_email
     ON public.users USING btree (email)
     TABLESPACE pg_default;
 
--- NOTE: Case-insensitive indexes removed - fields are encrypted
--- LOWER() cannot be used on encrypted data
+-- Emails are normalized (trimmed + lowercased) by the application before storing;
+-- this index guards against mixed-case duplicates slipping in through other paths
+-- Migration for existing databases
+-- Accounts whose emails differ only in case or surrounding spaces cannot all keep their address. The verified,
+-- most recently used, oldest account keeps it; the others are signed out and renamed to
+-- '<email>#duplicate-<id>' (never a valid login) so support can still find and merge them
+WITH ranked AS (
+    SELECT id, row_number() OVER (
+        PARTITION BY LOWER(TRIM(email))
+        ORDER BY email_verified DESC, last_login DESC NULLS LAST, created_at ASC, id ASC
+    ) AS position
+    FROM public.users
+)
+UPDATE public.users AS u
+SET email = LOWER(TRIM(u.email)) || '#duplicate-' || u.id,
+    token_version = u.token_version + 1
+FROM ranked
+WHERE ranked.id = u.id
+  AND ranked.position > 1;
+UPDATE public.users SET email = LOWER(TRIM(email)) WHERE email <> LOWER(TRIM(email));
+CREATE UNIQUE INDEX IF NOT EXISTS idx_users_email_lower
+    ON public.users USING btree (LOWER(email))
+    TABLESPACE pg_default;
 
 -- Active users composite index
 CREATE INDEX IF NOT EXISTS idx_users_active_created
@@ -103,7 +129,7 @@ CREATE TRIGGER set_updated_at
     EXECUTE FUNCTION update_users_updated_at_column();
 
 -- NOTE: normalize_user_fields function and trigger removed
--- Fields are now encrypted and normalization happens in application layer before encryption
+-- Normalization happens in the application layer (see lib.NormalizeEmail)
 
 -- ============================================================================
 -- COMMENTS (Documentation)
@@ -116,7 +142,7 @@ COMMENT ON COLUMN public.users.username IS
     'User full name for display purposes (2-100 characters, non-unique)';
 
 COMMENT ON COLUMN public.users.email IS
-    'Unique email address for authentication and communication';
+    'Unique email address for authentication and communication, stored trimmed and lowercased';
 
 COMMENT ON COLUMN public.users.password_hash IS
     'Argon hashed password - never store plain text passwords';
@@ -133,6 +159,9 @@ COMMENT ON COLUMN public.users.email_verified IS
 COMMENT ON COLUMN public.users.last_login IS
     'Timestamp of the user''s last successful login';
 
+COMMENT ON COLUMN public.users.token_version IS
+    'Version embedded in access and refresh tokens, bumped on password change and reset to invalidate older tokens';
+
 -- ============================================================================
 -- GRANTS (Adjust based on your user roles)
 -- ============================================================================
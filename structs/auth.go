@@ -21,6 +21,7 @@ type AuthClaims struct {
 	Iat   time.Time `json:"iat"`
 	Exp   time.Time `json:"exp"`
 	Jti   uuid.UUID `json:"jti"`
+	Ver   int       `json:"ver"` // Token version of the user when the token was issued, see tables.User.TokenVersion
 }
 
 type AuthRequest struct {
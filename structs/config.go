@@ -1,30 +1,40 @@
 package structs
 
-import "time"
+import (
+	"net/http"
+	"time"
+)
 
 type Config struct {
 	Server     *ServerConfig     `validate:"required"`
 	Cors       *CorsConfig       `validate:"required"`
+	Cookie     *CookieConfig     `validate:"required"`
 	Database   *DatabaseConfig   `validate:"required"`
 	Auth       *AuthConfig       `validate:"required"`
+	Argon      *ArgonConfig      `validate:"required"`
 	Cache      *CacheConfig      `validate:"required"`
 	RateLimit  *RateLimitConfig  `validate:"required"`
 	Email      *EmailConfig      `validate:"required"`
 	Encryption *EncryptionConfig `validate:"required"`
+	Orders     *OrderConfig      `validate:"required"`
+	Products   *ProductConfig    `validate:"required"`
+	Features   *FeatureConfig    `validate:"required"`
 }
 
 type ServerConfig struct {
-	AppName           string        `validate:"required,min=2,max=100"`                // Mamabloemetjes
-	Environment       string        `validate:"required,oneof=development production"` // development, production
-	Port              string        `validate:"required,min=4,max=10"`                 // :8081
-	LogLevel          string        `validate:"required,oneof=debug info warn error"`  // debug, info, warn, error
-	ServerURL         string        `validate:"required,url"`                          // Base URL of the server
-	FrontendURL       string        `validate:"required,url"`                          // Base URL of the frontend
-	ReadTimeout       time.Duration `validate:"required,min=1s"`                       // in seconds
-	WriteTimeout      time.Duration `validate:"required,min=1s"`                       // in seconds
-	IdleTimeout       time.Duration `validate:"required,min=1s"`                       // in seconds
-	ReadHeaderTimeout time.Duration `validate:"required,min=1s"`                       // in seconds
-	MaxHeaderBytes    int           `validate:"required,min=1024"`                     // in bytes
+	AppName              string        `validate:"required,min=2,max=100"`                // Mamabloemetjes
+	Environment          string        `validate:"required,oneof=development production"` // development, production
+	Port                 string        `validate:"required,min=4,max=10"`                 // :8081
+	LogLevel             string        `validate:"required,oneof=debug info warn error"`  // debug, info, warn, error
+	ServerURL            string        `validate:"required,url"`                          // Base URL of the server
+	FrontendURL          string        `validate:"required,url"`                          // Base URL of the frontend
+	ReadTimeout          time.Duration `validate:"required,min=1s"`                       // in seconds
+	WriteTimeout         time.Duration `validate:"required,min=1s"`                       // in seconds
+	IdleTimeout          time.Duration `validate:"required,min=1s"`                       // in seconds
+	ReadHeaderTimeout    time.Duration `validate:"required,min=1s"`                       // in seconds
+	MaxHeaderBytes       int           `validate:"required,min=1024"`                     // in bytes
+	SlowRequestThreshold time.Duration `validate:"min=0"`                                 // warn when a handler exceeds this, 0 disables
+	EnableH2C            bool          // accept unencrypted HTTP/2 (h2c), for use behind a proxy that speaks it
 }
 
 type CorsConfig struct {
@@ -32,8 +42,16 @@ type CorsConfig struct {
 	AllowedMethods   []string `validate:"required,min=1,dive,required"`
 	AllowedHeaders   []string `validate:"required,min=1,dive,required"`
 	ExposedHeaders   []string `validate:"omitempty,dive,required"`
-	AllowCredentials bool
-	MaxAge           int `validate:"required,min=0"` // in seconds
+	AllowCredentials bool     // Cannot be combined with a "*" origin
+	MaxAge           int      `validate:"min=0"` // Access-Control-Max-Age for preflight responses, in seconds
+	AllowLocalhost   bool     // also reflect any localhost origin, set by the development preset
+}
+
+// CookieConfig holds the attributes of the auth and CSRF cookies, derived from the environment
+type CookieConfig struct {
+	Domain   string        // empty scopes cookies to the API host
+	Secure   bool          // send over HTTPS only
+	SameSite http.SameSite // None is needed for cross-subdomain requests and requires Secure
 }
 
 type DatabaseConfig struct {
@@ -57,6 +75,32 @@ type AuthConfig struct {
 	RefreshTokenExpiry time.Duration `validate:"required,min=1m"`
 	CacheUserTTL       time.Duration `validate:"required,min=1s"`
 	BlacklistCacheTTL  time.Duration `validate:"required,min=1s"`
+	TokenLeeway        time.Duration `validate:"min=0,max=5m"` // Clock skew tolerated on exp/iat/nbf between hosts
+
+	// Optional iss and aud claims. When set they are added to new tokens and required on incoming ones;
+	// empty leaves the claim out and unchecked, so tokens issued before they were configured keep working until then
+	TokenIssuer   string `validate:"omitempty,max=255"`
+	TokenAudience string `validate:"omitempty,max=255"`
+
+	// Upper bounds for the argon2 parameters embedded in a stored hash; hashes above them are rejected unverified
+	PasswordMaxMemory  int `validate:"required,min=65536"` // KiB, must cover Argon.Memory
+	PasswordMaxTime    int `validate:"required,min=1"`
+	PasswordMaxThreads int `validate:"required,min=1,max=255"`
+
+	// Server-side secrets mixed into passwords before hashing, keyed by version so they can be rotated.
+	// New hashes use PasswordPepperVersion (0 disables peppering); older versions stay here until their hashes are upgraded
+	PasswordPepperVersion int `validate:"min=0"`
+	PasswordPeppers       map[int]string
+}
+
+// ArgonConfig holds the argon2id cost parameters used for new password hashes.
+// Stored hashes with weaker parameters are rehashed on the next login
+type ArgonConfig struct {
+	Memory  int `validate:"required,min=8192"` // KiB
+	Time    int `validate:"required,min=1"`    // iterations
+	Threads int `validate:"required,min=1,max=255"`
+	KeyLen  int `validate:"required,min=16,max=128"` // bytes
+	SaltLen int `validate:"required,min=16,max=64"`  // bytes
 }
 
 type CacheConfig struct {
@@ -77,6 +121,11 @@ type CacheConfig struct {
 	MaxRetryBackoff time.Duration `validate:"required,min=1ms"`
 	ProductListTTL  time.Duration `validate:"required,min=1s"`
 	ProductCountTTL time.Duration `validate:"required,min=1s"`
+	TrendingWindow  time.Duration `validate:"required,min=1h"` // How far back product views count towards trending
+	SuggestTTL      time.Duration `validate:"required,min=1s"` // Short TTL so repeated keystrokes on the same prefix hit the cache
+	ProfileTTL      time.Duration `validate:"required,min=1s"` // Order summaries shown on the user profile; status changes only show up after expiry
+	OrderStatusTTL  time.Duration `validate:"required,min=1s"` // Polled order statuses; kept short, changes only show up after expiry
+	WarmPages       int           `validate:"required,min=1"`  // Product list pages per product type stored by a cache warmup
 }
 
 type RateLimitConfig struct {
@@ -96,18 +145,112 @@ type RateLimitConfig struct {
 	AdminLimit  int           `validate:"required,min=1"`
 	AdminWindow time.Duration `validate:"required,min=1s"`
 
+	// Admin customer lookups - strict, shared by every looked-up user
+	UserLookupLimit  int           `validate:"required,min=1"`
+	UserLookupWindow time.Duration `validate:"required,min=1s"`
+
+	// Admin cache warmups - each run queries every warmed page, shared by all admins
+	CacheWarmLimit  int           `validate:"required,min=1"`
+	CacheWarmWindow time.Duration `validate:"required,min=1s"`
+
+	// Count authenticated requests per user instead of per client IP, so users behind a shared NAT
+	// do not throttle each other and abuse is tracked per account
+	PerUser bool
+
 	// Enable/disable rate limiting
 	Enabled bool
 }
 
 type EmailConfig struct {
-	ApiKey                  string        `validate:"required,min=10"`
-	From                    string        `validate:"required"`
-	VerificationTokenExpiry time.Duration `validate:"required,min=1m"`
-	OrderConfirmationFrom   string        `validate:"required"` // Email address for order confirmations
-	SupportEmail            string        `validate:"required"` // Support email to show in order emails
+	ApiKey                  string            `validate:"required,min=10"`
+	From                    string            `validate:"required"`
+	VerificationTokenExpiry time.Duration     `validate:"required,min=1m"`
+	PasswordResetExpiry     time.Duration     `validate:"required,min=1m"`
+	OrderConfirmationFrom   string            `validate:"required"`                // Email address for order confirmations
+	SupportEmail            string            `validate:"required"`                // Support email to show in order emails
+	AdminEmails             []string          `validate:"omitempty,dive,email"`    // Recipients of admin notifications; users with the admin role when empty
+	FromOverrides           map[string]string `validate:"omitempty,dive,required"` // Sender per email type, falls back to From
+	ReplyToOverrides        map[string]string `validate:"omitempty,dive,required"` // Reply-to per email type, none when unset
+
+	// Retries of a send that failed with a provider error (5xx), rate limit (429) or network error
+	SendMaxAttempts   int           `validate:"required,min=1,max=10"`
+	SendRetryDelay    time.Duration `validate:"required,min=10ms"` // First backoff delay, doubled per attempt
+	SendMaxRetryDelay time.Duration `validate:"required,min=10ms"` // Backoff cap; a longer Retry-After from the provider gives up
+
+	// In-process queue for emails sent in the background
+	QueueSize         int           `validate:"required,min=1"`
+	QueueWorkers      int           `validate:"required,min=1,max=32"`
+	QueueDrainTimeout time.Duration `validate:"required,min=1s"` // How long shutdown keeps sending queued emails
+}
+
+// EmailType identifies the kind of email being sent, for per-type sender overrides
+type EmailType string
+
+const (
+	EmailTypeVerification      EmailType = "verification"
+	EmailTypeOrderConfirmation EmailType = "order_confirmation"
+	EmailTypePaymentLink       EmailType = "payment_link"
+	EmailTypeOrderCancelled    EmailType = "order_cancelled"
+	EmailTypeAdminNotification EmailType = "admin_notification"
+	EmailTypePasswordReset     EmailType = "password_reset"
+)
+
+// EmailTypes lists every EmailType, used to validate the override keys
+var EmailTypes = []EmailType{
+	EmailTypeVerification,
+	EmailTypeOrderConfirmation,
+	EmailTypePaymentLink,
+	EmailTypeOrderCancelled,
+	EmailTypeAdminNotification,
+	EmailTypePasswordReset,
 }
 
 type EncryptionConfig struct {
 	Key string `validate:"required,len=32"` // AES-256 encryption key (32 bytes)
 }
+
+type OrderConfig struct {
+	PendingTTL     time.Duration `validate:"required,min=1m"` // Unpaid pending orders older than this release their reserved products
+	SweepInterval  time.Duration `validate:"required,min=1m"` // How often the pending order sweeper runs
+	SweepBatchSize int           `validate:"required,min=1,max=500"`
+	SweepEnabled   bool          // Enable/disable the background sweeper
+	CancelExpired  bool          // Also cancel the expired orders (and notify the customer)
+
+	DefaultCurrency string `validate:"required,len=3,uppercase"` // ISO 4217 code used when a product has no currency set
+
+	// Customer data of delivered/cancelled guest orders is wiped after this period; totals and lines are kept
+	GuestRetention     time.Duration `validate:"required,min=24h"`
+	AnonymizeInterval  time.Duration `validate:"required,min=1m"` // How often the retention job runs
+	AnonymizeBatchSize int           `validate:"required,min=1,max=500"`
+	AnonymizeEnabled   bool          // Enable/disable the retention job
+
+	// Orders a signed-in user may place per window, 0 disables the limit. Admins are never limited
+	UserOrderLimit  int           `validate:"min=0"`
+	UserOrderWindow time.Duration `validate:"required,min=1m"`
+	UserLimitBypass []string      `validate:"dive,uuid"` // IDs of trusted accounts exempt from the limit
+
+	ShippingCents int `validate:"min=0,max=10000"` // Flat shipping charged on every order, in cents; the client never sets it
+
+	// Fraud guard: orders above these limits are rejected and logged for review, 0 disables a limit
+	MaxOrderTotal   uint64 // Cents, compared with the sum of the line totals (shipping excluded)
+	MaxLineQuantity int    `validate:"min=0"`
+
+	// How to answer when a user requests an order they don't own:
+	// "not_found" (uniform 404, doesn't reveal the order exists) or "forbidden" (403)
+	OwnershipPolicy string `validate:"required,oneof=not_found forbidden"`
+}
+
+type ProductConfig struct {
+	MaxImages    int `validate:"required,min=1,max=100"` // Maximum number of images per product
+	SuggestLimit int `validate:"required,min=1,max=50"`  // Maximum number of search-as-you-type suggestions
+
+	// Guards against expensive list queries
+	MaxFilterSKUs    int           `validate:"required,min=1,max=1000"` // Maximum SKUs in the skus and exclude_skus filters each
+	MinSearchLength  int           `validate:"min=0,max=20"`            // Shorter searches need another selective filter, 0 disables
+	ListQueryTimeout time.Duration `validate:"required,min=100ms"`      // Timeout of product list and count queries
+}
+
+type FeatureConfig struct {
+	Defaults map[string]bool // Used for flags that have not been set through the admin API
+	CacheTTL time.Duration   `validate:"required,min=1s"` // Per-instance in-memory cache; pub/sub invalidation usually clears it sooner
+}
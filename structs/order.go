@@ -15,6 +15,8 @@ type OrderRequest struct {
 	Country    string `json:"country" validate:"omitempty,len=2"` // ISO country code
 
 	// Order data
-	Products      map[string]int `json:"products" validate:"required,min=1,dive,keys,uuid4,endkeys,required,min=1"` // productID -> quantity
-	ShippingCents int            `json:"shipping_cents"`
+	Products map[string]int `json:"products" validate:"required,min=1,dive,keys,uuid4,endkeys,required,min=1"` // productID -> quantity
+
+	// Deprecated: ignored, shipping is charged from the server config. Still accepted so older clients that send it keep working
+	ShippingCents int `json:"shipping_cents"`
 }
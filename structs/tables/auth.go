@@ -21,6 +21,7 @@ type User struct {
 	Role          string    `json:"role" bun:"role,notnull,default:'user'" validate:"required,oneof=user admin"`
 	LastLogin     time.Time `json:"last_login" bun:"last_login,default:now()"`
 	EmailVerified bool      `json:"email_verified" bun:"email_verified,notnull,default:false"`
+	TokenVersion  int       `json:"token_version" bun:"token_version,notnull,default:0"` // Bumped on password change; tokens carrying an older version are rejected
 	CreatedAt     time.Time `json:"created_at" bun:"created_at,notnull,default:now()"`
 }
 
@@ -36,3 +37,17 @@ type Address struct {
 	CreatedAt  time.Time  `bun:"created_at,notnull,default:now()" json:"created_at"`
 	UpdatedAt  time.Time  `bun:"updated_at,notnull,default:now()" json:"updated_at"`
 }
+
+// Session backs a refresh token; the refresh token's jti must match a live (not revoked, not expired) session
+type Session struct {
+	tableName     struct{}   `bun:"table:sessions,alias:s"`
+	Id            uuid.UUID  `bun:"id,pk,type:uuid,default:gen_random_uuid()" json:"id" validate:"omitempty,uuid4"`
+	UserId        uuid.UUID  `bun:"user_id,notnull,type:uuid" json:"user_id" validate:"required,uuid4"`
+	Jti           uuid.UUID  `bun:"jti,notnull,unique,type:uuid" json:"-" validate:"required,uuid4"`
+	UserAgent     string     `bun:"user_agent,notnull" json:"user_agent" validate:"omitempty,max=512"`
+	Ip            string     `bun:"ip,notnull" json:"ip" validate:"omitempty,max=64"`
+	CreatedAt     time.Time  `bun:"created_at,notnull,default:current_timestamp" json:"created_at"`
+	ExpiresAt     time.Time  `bun:"expires_at,notnull" json:"expires_at" validate:"required"`
+	RevokedAt     *time.Time `bun:"revoked_at,nullzero" json:"revoked_at,omitempty"`
+	CSRFTokenHash string     `bun:"csrf_token_hash,nullzero" json:"-"`
+}
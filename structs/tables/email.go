@@ -16,3 +16,15 @@ type EmailVerification struct {
 	CreatedAt time.Time `bun:"created_at,notnull,default:current_timestamp"`
 	User      *User     `bun:"rel:belongs-to,join:user_id=id,on_delete:cascade" validate:"omitempty"`
 }
+
+// PasswordReset is a single-use password reset token; only the SHA-256 of the emailed token is stored
+type PasswordReset struct {
+	tableName struct{}   `bun:"table:password_resets,alias:pr"`
+	Id        uuid.UUID  `bun:"id,pk,type:uuid,default:gen_random_uuid()" validate:"omitempty,uuid4"`
+	UserId    uuid.UUID  `bun:"user_id,notnull,type:uuid" validate:"required,uuid4"`
+	TokenHash string     `bun:"token_hash,notnull,unique" validate:"required,len=64"`
+	ExpiresAt time.Time  `bun:"expires_at,notnull" validate:"required"`
+	UsedAt    *time.Time `bun:"used_at,nullzero"`
+	CreatedAt time.Time  `bun:"created_at,notnull,default:current_timestamp"`
+	User      *User      `bun:"rel:belongs-to,join:user_id=id,on_delete:cascade" validate:"omitempty"`
+}
@@ -28,11 +28,26 @@ type Order struct {
 	// Shipping
 	ShippingCents uint64 `bun:"shipping_cents" json:"shipping_cents"`
 
+	// Manual discount in cents applied by an admin, taken off the line totals
+	DiscountCents uint64 `bun:"discount_cents,notnull,default:0" json:"discount_cents"`
+
+	// Sum of the line totals minus the discount in cents, shipping excluded
+	Total uint64 `bun:"total,notnull,default:0" json:"total"`
+
+	// Currency shared by all order lines (ISO 4217)
+	Currency string `bun:"currency,notnull" json:"currency" validate:"omitempty,len=3,uppercase"`
+
 	// Order Data
 	Status    OrderStatus `bun:"status,notnull,default:'pending'" json:"status" validate:"required,oneof=pending paid processing shipped delivered cancelled refunded"`
 	CreatedAt time.Time   `bun:"created_at,notnull,default:current_timestamp" json:"created_at"`
 	UpdatedAt time.Time   `bun:"updated_at,notnull,default:current_timestamp" json:"updated_at"`
 	DeletedAt *time.Time  `bun:"deleted_at,nullzero" json:"deleted_at,omitempty"`
+
+	// Set once the products held by an unpaid order have been released back to the shop
+	ReservationReleasedAt *time.Time `bun:"reservation_released_at,nullzero" json:"reservation_released_at,omitempty"`
+
+	// Set once the customer data of a guest order has been wiped by the retention job
+	AnonymizedAt *time.Time `bun:"anonymized_at,nullzero" json:"anonymized_at,omitempty"`
 }
 
 type OrderLine struct {
@@ -52,6 +67,93 @@ type OrderLine struct {
 	// Keep reference to product for name/SKU changes
 	ProductName string `bun:"product_name,notnull" json:"product_name" validate:"required,min=2,max=200"` // Name when ordered
 	ProductSKU  string `bun:"product_sku,notnull" json:"product_sku" validate:"required,min=3,max=50"`    // SKU when ordered
+
+	// Current product details, only populated when preloaded (see GetOrderLinesWithProducts)
+	Product *Product `bun:"rel:belongs-to,join:product_id=id" json:"product,omitempty" validate:"-"`
+}
+
+// OrderStatusHistory records every status change applied to an order
+type OrderStatusHistory struct {
+	tableName  struct{}    `bun:"table:order_status_history,alias:osh"`
+	Id         uuid.UUID   `bun:"id,pk,type:uuid,default:gen_random_uuid()" json:"id" validate:"omitempty,uuid4"`
+	OrderId    uuid.UUID   `bun:"order_id,notnull,type:uuid" json:"order_id" validate:"required,uuid4"`
+	FromStatus OrderStatus `bun:"from_status,notnull" json:"from_status" validate:"required"`
+	ToStatus   OrderStatus `bun:"to_status,notnull" json:"to_status" validate:"required"`
+	ChangedBy  *uuid.UUID  `bun:"changed_by,type:uuid,nullzero" json:"changed_by,omitempty" validate:"omitempty,uuid4"` // Admin user, nil for system changes
+	CreatedAt  time.Time   `bun:"created_at,notnull,default:current_timestamp" json:"created_at"`
+}
+
+// OrderEmailChange records an admin correcting the customer email of an order
+type OrderEmailChange struct {
+	tableName struct{}   `bun:"table:order_email_changes,alias:oec"`
+	Id        uuid.UUID  `bun:"id,pk,type:uuid,default:gen_random_uuid()" json:"id" validate:"omitempty,uuid4"`
+	OrderId   uuid.UUID  `bun:"order_id,notnull,type:uuid" json:"order_id" validate:"required,uuid4"`
+	FromEmail string     `bun:"from_email,notnull" json:"-"` // Encrypted, like the order email
+	ToEmail   string     `bun:"to_email,notnull" json:"-"`   // Encrypted, like the order email
+	Resent    bool       `bun:"resent,notnull,default:false" json:"resent"`
+	ChangedBy *uuid.UUID `bun:"changed_by,type:uuid,nullzero" json:"changed_by,omitempty" validate:"omitempty,uuid4"`
+	CreatedAt time.Time  `bun:"created_at,notnull,default:current_timestamp" json:"created_at"`
+}
+
+// OrderLineChange records an admin edit of an order line; a removed line has ToQuantity 0
+type OrderLineChange struct {
+	tableName    struct{}   `bun:"table:order_line_changes,alias:olc"`
+	Id           uuid.UUID  `bun:"id,pk,type:uuid,default:gen_random_uuid()" json:"id" validate:"omitempty,uuid4"`
+	OrderId      uuid.UUID  `bun:"order_id,notnull,type:uuid" json:"order_id" validate:"required,uuid4"`
+	LineId       uuid.UUID  `bun:"line_id,notnull,type:uuid" json:"line_id" validate:"required,uuid4"` // Kept after the line is deleted
+	ProductId    uuid.UUID  `bun:"product_id,notnull,type:uuid" json:"product_id" validate:"required,uuid4"`
+	FromQuantity int        `bun:"from_quantity,notnull" json:"from_quantity"`
+	ToQuantity   int        `bun:"to_quantity,notnull" json:"to_quantity"`
+	ChangedBy    *uuid.UUID `bun:"changed_by,type:uuid,nullzero" json:"changed_by,omitempty" validate:"omitempty,uuid4"`
+	CreatedAt    time.Time  `bun:"created_at,notnull,default:current_timestamp" json:"created_at"`
+}
+
+type OrderAdjustmentKind string
+
+const (
+	OrderAdjustmentFixed   OrderAdjustmentKind = "fixed"
+	OrderAdjustmentPercent OrderAdjustmentKind = "percent"
+)
+
+// OrderAdjustment records an admin applying a manual discount to an order
+type OrderAdjustment struct {
+	tableName     struct{}            `bun:"table:order_adjustments,alias:oa"`
+	Id            uuid.UUID           `bun:"id,pk,type:uuid,default:gen_random_uuid()" json:"id" validate:"omitempty,uuid4"`
+	OrderId       uuid.UUID           `bun:"order_id,notnull,type:uuid" json:"order_id" validate:"required,uuid4"`
+	Kind          OrderAdjustmentKind `bun:"kind,notnull" json:"kind" validate:"required,oneof=fixed percent"`
+	Value         uint64              `bun:"value,notnull" json:"value"`                   // Cents for fixed, whole percent for percent
+	DiscountCents uint64              `bun:"discount_cents,notnull" json:"discount_cents"` // Resulting discount on the order
+	Reason        string              `bun:"reason,notnull" json:"reason" validate:"required,max=500"`
+	AdjustedBy    *uuid.UUID          `bun:"adjusted_by,type:uuid,nullzero" json:"adjusted_by,omitempty" validate:"omitempty,uuid4"`
+	CreatedAt     time.Time           `bun:"created_at,notnull,default:current_timestamp" json:"created_at"`
+}
+
+// OrderTotals breaks the amount due for an order down into its parts, all in cents
+type OrderTotals struct {
+	Subtotal uint64 `json:"subtotal"` // Sum of the line totals
+	Discount uint64 `json:"discount"` // Manual discount, never more than the subtotal
+	Total    uint64 `json:"total"`    // Subtotal minus discount, shipping excluded
+	Shipping uint64 `json:"shipping"`
+	Due      uint64 `json:"due"` // Total plus shipping
+}
+
+// Totals computes the totals breakdown of the order from its lines
+func (o *Order) Totals(lines []*OrderLine) OrderTotals {
+	var subtotal uint64
+	for _, line := range lines {
+		subtotal += line.LineTotal
+	}
+
+	discount := min(o.DiscountCents, subtotal)
+	total := subtotal - discount
+
+	return OrderTotals{
+		Subtotal: subtotal,
+		Discount: discount,
+		Total:    total,
+		Shipping: o.ShippingCents,
+		Due:      total + o.ShippingCents,
+	}
 }
 
 type OrderStatus string
@@ -66,6 +168,25 @@ const (
 	OrderStatusRefunded   OrderStatus = "refunded"
 )
 
+// orderStatusTransitions lists the statuses an order may move to from each status
+var orderStatusTransitions = map[OrderStatus][]OrderStatus{
+	OrderStatusPending:    {OrderStatusPaid, OrderStatusProcessing, OrderStatusCancelled},
+	OrderStatusPaid:       {OrderStatusProcessing, OrderStatusShipped, OrderStatusCancelled, OrderStatusRefunded},
+	OrderStatusProcessing: {OrderStatusShipped, OrderStatusCancelled, OrderStatusRefunded},
+	OrderStatusShipped:    {OrderStatusDelivered, OrderStatusRefunded},
+	OrderStatusDelivered:  {OrderStatusRefunded},
+}
+
+// CanTransitionTo reports whether an order in status s may be moved to next
+func (s OrderStatus) CanTransitionTo(next OrderStatus) bool {
+	for _, allowed := range orderStatusTransitions[s] {
+		if allowed == next {
+			return true
+		}
+	}
+	return false
+}
+
 type PaymentStatus string
 
 const (
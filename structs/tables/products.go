@@ -1,26 +1,73 @@
 package tables
 
 import (
+	"strings"
 	"time"
 
 	"github.com/google/uuid"
 )
 
 type Product struct {
-	tableName   struct{}       `bun:"table:products,alias:p"`
-	ID          uuid.UUID      `bun:"id,pk,type:uuid" json:"id" validate:"omitempty,uuid4"`
-	Name        string         `bun:"name,notnull" json:"name" validate:"required,min=2,max=200"`
-	SKU         string         `bun:"sku,notnull" json:"sku" validate:"omitempty,min=3,max=50"`
-	Price       uint64         `bun:"price,notnull" json:"price" validate:"required,gte=0"`          // stored in cents
-	Discount    uint64         `bun:"discount" json:"discount,omitempty" validate:"omitempty,gte=0"` // stored in cents
-	Tax         uint64         `bun:"tax,notnull" json:"tax" validate:"gte=0"`                       // stored in cents
-	Subtotal    uint64         `bun:"subtotal,notnull" json:"subtotal" validate:"omitempty,gte=0"`   // computed: Price - Discount + Tax
-	Description string         `bun:"description,notnull" json:"description" validate:"required,min=10,max=2000"`
-	ProductType string         `bun:"product_type" json:"product_type" validate:"omitempty,oneof='wedding' 'funeral' 'birth'"`
-	IsActive    bool           `bun:"is_active,notnull" json:"is_active"`
-	CreatedAt   time.Time      `bun:"created_at,notnull,default:now()" json:"created_at"`
-	UpdatedAt   time.Time      `bun:"updated_at,notnull,default:now()" json:"updated_at"`
-	Images      []ProductImage `bun:"rel:has-many,join:id=product_id" json:"images,omitempty" validate:"omitempty,dive"` // slice is nil if no images
+	tableName       struct{}       `bun:"table:products,alias:p"`
+	ID              uuid.UUID      `bun:"id,pk,type:uuid" json:"id" validate:"omitempty,uuid4"`
+	Name            string         `bun:"name,notnull" json:"name" validate:"required,min=2,max=200"`
+	SKU             string         `bun:"sku,notnull" json:"sku" validate:"omitempty,min=3,max=50"`
+	Price           uint64         `bun:"price,notnull" json:"price" validate:"required,gte=0"`                  // stored in cents
+	Discount        uint64         `bun:"discount" json:"discount,omitempty" validate:"omitempty,gte=0"`         // stored in cents
+	Tax             uint64         `bun:"tax,notnull" json:"tax" validate:"gte=0"`                               // stored in cents
+	Subtotal        uint64         `bun:"subtotal,notnull" json:"subtotal" validate:"omitempty,gte=0"`           // computed: Price - Discount + Tax
+	Currency        string         `bun:"currency,notnull" json:"currency" validate:"omitempty,len=3,uppercase"` // ISO 4217, defaults to the configured currency
+	Description     string         `bun:"description,notnull" json:"description" validate:"required,min=10,max=2000"`
+	ProductType     string         `bun:"product_type" json:"product_type" validate:"omitempty,product_type"` // one of ProductTypes, stored lowercase
+	IsActive        bool           `bun:"is_active,notnull" json:"is_active"`
+	MadeToOrder     bool           `bun:"made_to_order,notnull,default:false" json:"made_to_order"`  // stays listed and orderable in any quantity after a purchase
+	AvailableFrom   *time.Time     `bun:"available_from,nullzero" json:"available_from,omitempty"`   // start of the seasonal window, inclusive; nil means always
+	AvailableUntil  *time.Time     `bun:"available_until,nullzero" json:"available_until,omitempty"` // end of the seasonal window, exclusive; nil means never
+	ReservedOrderId *uuid.UUID     `bun:"reserved_order_id,type:uuid,nullzero" json:"-"`             // order that deactivated this product, nil once released or edited by an admin
+	CreatedAt       time.Time      `bun:"created_at,notnull,default:now()" json:"created_at"`
+	UpdatedAt       time.Time      `bun:"updated_at,notnull,default:now()" json:"updated_at"`
+	Images          []ProductImage `bun:"rel:has-many,join:id=product_id" json:"images,omitempty" validate:"omitempty,dive"` // slice is nil if no images
+}
+
+// Product types; stored and filtered on in lowercase
+const (
+	ProductTypeWedding = "wedding"
+	ProductTypeFuneral = "funeral"
+	ProductTypeBirth   = "birth"
+)
+
+// ProductTypes lists every valid product type
+var ProductTypes = []string{ProductTypeWedding, ProductTypeFuneral, ProductTypeBirth}
+
+// NormalizeProductType returns the lowercase product type for s and whether it is a known type
+func NormalizeProductType(s string) (string, bool) {
+	normalized := strings.ToLower(strings.TrimSpace(s))
+	for _, productType := range ProductTypes {
+		if normalized == productType {
+			return normalized, true
+		}
+	}
+	return normalized, false
+}
+
+// IsAvailableAt reports whether t falls inside the product's availability window
+func (p *Product) IsAvailableAt(t time.Time) bool {
+	if p.AvailableFrom != nil && t.Before(*p.AvailableFrom) {
+		return false
+	}
+	if p.AvailableUntil != nil && !t.Before(*p.AvailableUntil) {
+		return false
+	}
+	return true
+}
+
+// AvailableQuantity returns how many units of an active product can be ordered when requested are asked for.
+// One-of-a-kind products have a single unit; made-to-order products are made for every order
+func (p *Product) AvailableQuantity(requested int) int {
+	if p.MadeToOrder {
+		return requested
+	}
+	return 1
 }
 
 // ProductImage represents an image for a product
@@ -0,0 +1,238 @@
+// Package testutil holds the setup shared by the tests: the configuration, a quiet logger, an in-memory Redis
+// and, when TEST_DATABASE_URL is set, a Postgres database
+package testutil
+
+import (
+	"context"
+	"fmt"
+	"io"
+	"mamabloemetjes_server/config"
+	"mamabloemetjes_server/database"
+	"mamabloemetjes_server/structs"
+	"mamabloemetjes_server/structs/tables"
+	"net/http"
+	"net/http/httptest"
+	"os"
+	"path/filepath"
+	"runtime"
+	"strings"
+	"sync"
+	"testing"
+
+	"github.com/MonkyMars/gecho"
+	"github.com/alicebob/miniredis/v2"
+)
+
+// testEnv fills in the settings without a usable default, plus cheaper argon parameters.
+// Values already in the environment win, except CACHE_ADDRESS which always points at the in-memory Redis
+var testEnv = map[string]string{
+	"APP_LOG_LEVEL":             "error",
+	"AUTH_ACCESS_TOKEN_SECRET":  "test-access-token-secret-0123456789",
+	"AUTH_REFRESH_TOKEN_SECRET": "test-refresh-token-secret-0123456789",
+	"ENCRYPTION_KEY":            "0123456789abcdef0123456789abcdef",
+	"ARGON_MEMORY":              "8192",
+	"EMAIL_SEND_RETRY_DELAY":    "10ms",
+	"ORDER_SWEEP_ENABLED":       "false",
+	"ORDER_ANONYMIZE_ENABLED":   "false",
+}
+
+// schemaModels lists the tables the tests create, in dependency order. They are created from the bun models
+// rather than sql/, so the tests run against exactly the columns the code reads and writes
+var schemaModels = []any{
+	(*tables.User)(nil),
+	(*tables.Address)(nil),
+	(*tables.Session)(nil),
+	(*tables.EmailVerification)(nil),
+	(*tables.PasswordReset)(nil),
+	(*tables.Product)(nil),
+	(*tables.ProductImage)(nil),
+	(*tables.Order)(nil),
+	(*tables.OrderLine)(nil),
+	(*tables.OrderStatusHistory)(nil),
+	(*tables.OrderEmailChange)(nil),
+	(*tables.OrderAdjustment)(nil),
+	(*tables.OrderLineChange)(nil),
+}
+
+// schemaIndexes adds the expression indexes the models cannot express
+var schemaIndexes = []string{
+	"CREATE UNIQUE INDEX idx_users_email_lower ON public.users (LOWER(email))",
+}
+
+// dbLockKey is the advisory lock serializing database tests, since go test runs packages in parallel
+const dbLockKey = 72310417
+
+var (
+	setupOnce   sync.Once
+	redisServer *miniredis.Miniredis
+
+	dbOnce sync.Once
+	testDB *database.DB
+	dbErr  error
+
+	schemaOnce sync.Once
+	schemaErr  error
+)
+
+// Config returns the application config for tests. The first call points the cache at an in-memory Redis and
+// fills in testEnv, so it must run before anything else calls config.GetConfig
+func Config() *structs.Config {
+	setupOnce.Do(func() {
+		redisServer = miniredis.NewMiniRedis()
+		if err := redisServer.Start(); err != nil {
+			panic(fmt.Sprintf("testutil: failed to start miniredis: %v", err))
+		}
+
+		_ = os.Setenv("CACHE_ADDRESS", redisServer.Addr())
+		for key, value := range testEnv {
+			if _, ok := os.LookupEnv(key); !ok {
+				_ = os.Setenv(key, value)
+			}
+		}
+	})
+	return config.GetConfig()
+}
+
+// Redis returns the in-memory Redis behind the cache, emptied for the calling test
+func Redis(t testing.TB) *miniredis.Miniredis {
+	t.Helper()
+	Config()
+	redisServer.FlushAll()
+	return redisServer
+}
+
+// Logger returns a logger that discards its output
+func Logger() *gecho.Logger {
+	return gecho.NewLogger(gecho.NewConfig(
+		gecho.WithLogLevel(gecho.LogLevelError),
+		gecho.WithOutput(io.Discard),
+		gecho.WithErrorOutput(io.Discard),
+	))
+}
+
+// LoggerTo returns a logger writing every level to w, for tests asserting what gets logged
+func LoggerTo(w io.Writer) *gecho.Logger {
+	return gecho.NewLogger(gecho.NewConfig(
+		gecho.WithLogLevel(gecho.LogLevelDebug),
+		gecho.WithColorize(false),
+		gecho.WithOutput(w),
+		gecho.WithErrorOutput(w),
+	))
+}
+
+// DB returns a database with every table emptied, skipping the test unless TEST_DATABASE_URL is set.
+// The database is wiped: its public schema is recreated once per test binary.
+// The calling test holds an advisory lock until it ends, so database tests never overlap
+func DB(t testing.TB) *database.DB {
+	t.Helper()
+
+	dsn := os.Getenv("TEST_DATABASE_URL")
+	if dsn == "" {
+		t.Skip("TEST_DATABASE_URL not set")
+	}
+	Config()
+
+	dbOnce.Do(func() {
+		testDB, dbErr = database.ConnectDSN(Logger(), dsn)
+	})
+	if dbErr != nil {
+		t.Fatalf("failed to connect to the test database: %v", dbErr)
+	}
+
+	ctx := context.Background()
+	conn, err := testDB.DB.DB.Conn(ctx)
+	if err != nil {
+		t.Fatalf("failed to get a test database connection: %v", err)
+	}
+	if _, err := conn.ExecContext(ctx, fmt.Sprintf("SELECT pg_advisory_lock(%d)", dbLockKey)); err != nil {
+		t.Fatalf("failed to lock the test database: %v", err)
+	}
+	t.Cleanup(func() {
+		_, _ = conn.ExecContext(ctx, fmt.Sprintf("SELECT pg_advisory_unlock(%d)", dbLockKey))
+		_ = conn.Close()
+	})
+
+	schemaOnce.Do(func() {
+		schemaErr = applySchema(ctx, testDB)
+	})
+	if schemaErr != nil {
+		t.Fatalf("failed to apply the schema: %v", schemaErr)
+	}
+
+	if err := truncateTables(ctx, testDB); err != nil {
+		t.Fatalf("failed to empty the test database: %v", err)
+	}
+
+	return testDB
+}
+
+// applySchema recreates the public schema with the tables in schemaModels
+func applySchema(ctx context.Context, db *database.DB) error {
+	if _, err := db.ExecContext(ctx, "DROP SCHEMA public CASCADE; CREATE SCHEMA public"); err != nil {
+		return err
+	}
+
+	for _, model := range schemaModels {
+		if _, err := db.NewCreateTable().Model(model).Exec(ctx); err != nil {
+			return fmt.Errorf("create table for %T: %w", model, err)
+		}
+	}
+	for _, index := range schemaIndexes {
+		if _, err := db.ExecContext(ctx, index); err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+// truncateTables empties every table in the public schema
+func truncateTables(ctx context.Context, db *database.DB) error {
+	var tables string
+	err := db.DB.DB.QueryRowContext(ctx,
+		"SELECT coalesce(string_agg(format('%I.%I', schemaname, tablename), ', '), '') FROM pg_tables WHERE schemaname = 'public'",
+	).Scan(&tables)
+	if err != nil || tables == "" {
+		return err
+	}
+	_, err = db.DB.DB.ExecContext(ctx, "TRUNCATE TABLE "+tables+" RESTART IDENTITY CASCADE")
+	return err
+}
+
+// SQLSection returns the statements of sql/<file> from the line starting with from through the first line after it
+// starting with through, so tests can run a migration exactly as shipped
+func SQLSection(t testing.TB, file, from, through string) string {
+	t.Helper()
+	_, self, _, _ := runtime.Caller(0)
+	content, err := os.ReadFile(filepath.Join(filepath.Dir(self), "..", "sql", file))
+	if err != nil {
+		t.Fatalf("failed to read %s: %v", file, err)
+	}
+
+	lines := strings.Split(string(content), "\n")
+	start := -1
+	for i, line := range lines {
+		if start < 0 && strings.HasPrefix(line, from) {
+			start = i
+			continue
+		}
+		if start >= 0 && strings.HasPrefix(line, through) {
+			return strings.Join(lines[start:i+1], "\n")
+		}
+	}
+	t.Fatalf("no section from %q through %q in %s", from, through, file)
+	return ""
+}
+
+// ServeWithQueryBudget serves r with handler under a query counter and fails the test when the handler ran more
+// than budget queries, to catch N+1 regressions. Only queries run with the request context are counted
+func ServeWithQueryBudget(t testing.TB, handler http.Handler, r *http.Request, budget int) *httptest.ResponseRecorder {
+	t.Helper()
+	r = r.WithContext(database.WithQueryCounter(r.Context()))
+	w := httptest.NewRecorder()
+	handler.ServeHTTP(w, r)
+
+	if queries := database.QueryCount(r.Context()); queries > budget {
+		t.Fatalf("%s %s ran %d queries, over its budget of %d", r.Method, r.URL.Path, queries, budget)
+	}
+	return w
+}